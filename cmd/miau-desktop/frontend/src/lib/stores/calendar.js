@@ -415,6 +415,23 @@ export async function syncFromGoogle(calendarId = 'primary') {
   }
 }
 
+// Sync the primary Google calendar both ways: Google changes come down,
+// local events (follow-ups and task deadlines included) go up
+export async function syncGoogle() {
+  googleSyncLoading.set(true);
+  try {
+    const result = await window.go.desktop.App.SyncGoogleCalendar();
+    await loadWeekEvents();
+    await loadCalendarConflicts();
+    return result;
+  } catch (err) {
+    console.error('Failed to sync Google Calendar:', err);
+    throw err;
+  } finally {
+    googleSyncLoading.set(false);
+  }
+}
+
 // Get events directly from Google Calendar (without saving locally)
 export async function getGoogleEvents(calendarId, weekStart) {
   try {
@@ -425,3 +442,67 @@ export async function getGoogleEvents(calendarId, weekStart) {
     return [];
   }
 }
+
+// === CalDAV Sync ===
+
+// CalDAV connection status (caldav.url set on the account)
+export const caldavConnected = writable(false);
+
+// CalDAV sync loading state
+export const caldavSyncLoading = writable(false);
+
+// Events changed both here and on the server: [{ local, remote }]
+export const calendarConflicts = writable([]);
+
+// Check if the account has a CalDAV calendar
+export async function checkCalDAVConnection() {
+  try {
+    const connected = await window.go.desktop.App.IsCalDAVConnected();
+    caldavConnected.set(connected);
+    return connected;
+  } catch (err) {
+    console.error('Failed to check CalDAV connection:', err);
+    caldavConnected.set(false);
+    return false;
+  }
+}
+
+// Sync the calendar with the account's CalDAV server
+export async function syncCalDAV() {
+  caldavSyncLoading.set(true);
+  try {
+    const result = await window.go.desktop.App.SyncCalDAV();
+    await loadWeekEvents();
+    await loadCalendarConflicts();
+    return result;
+  } catch (err) {
+    console.error('Failed to sync CalDAV calendar:', err);
+    throw err;
+  } finally {
+    caldavSyncLoading.set(false);
+  }
+}
+
+// Load the events in conflict
+export async function loadCalendarConflicts() {
+  try {
+    if (window.go?.desktop?.App) {
+      const conflicts = await window.go.desktop.App.GetCalendarConflicts();
+      calendarConflicts.set(conflicts || []);
+    }
+  } catch (err) {
+    console.error('Failed to load calendar conflicts:', err);
+  }
+}
+
+// Keep the local version of an event in conflict (keepMine) or the server's
+export async function resolveConflict(eventId, keepMine) {
+  try {
+    await window.go.desktop.App.ResolveCalendarConflict(eventId, keepMine);
+    calendarConflicts.update(list => list.filter(c => c.local.id !== eventId));
+    await loadWeekEvents();
+  } catch (err) {
+    console.error('Failed to resolve conflict:', err);
+    throw err;
+  }
+}
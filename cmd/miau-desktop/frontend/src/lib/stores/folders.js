@@ -4,6 +4,11 @@ import { currentFolder, loadEmails } from './emails.js';
 // Folder list
 export const folders = writable([]);
 
+// Gmail labels (opened as the folder LABEL_PREFIX + name)
+export const labels = writable([]);
+
+export const LABEL_PREFIX = 'label:';
+
 // Loading state
 export const foldersLoading = writable(false);
 
@@ -24,6 +29,36 @@ export async function loadFolders() {
   } finally {
     foldersLoading.set(false);
   }
+  await loadLabels();
+}
+
+// Load Gmail labels from backend
+export async function loadLabels() {
+  try {
+    if (window.go?.desktop?.App) {
+      const result = await window.go.desktop.App.GetLabels();
+      labels.set(result || []);
+    }
+  } catch (err) {
+    console.error('Failed to load labels:', err);
+    labels.set([]);
+  }
+}
+
+// Folder name of a label
+export function labelFolder(name) {
+  return LABEL_PREFIX + name;
+}
+
+// Add or remove a label on an email, then refresh the label counts
+export async function setEmailLabel(emailId, name, add) {
+  if (!window.go?.desktop?.App) return;
+  if (add) {
+    await window.go.desktop.App.AddLabel(emailId, name);
+  } else {
+    await window.go.desktop.App.RemoveLabel(emailId, name);
+  }
+  await loadLabels();
 }
 
 // Select a folder
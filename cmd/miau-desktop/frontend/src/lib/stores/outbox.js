@@ -0,0 +1,88 @@
+import { writable } from 'svelte/store';
+import { showCompose } from './ui.js';
+import { info, warn, error as logError } from './debug.js';
+
+// Emails waiting in the outbox (send server unreachable), failed ones included
+export const outboxItems = writable([]);
+export const showOutbox = writable(false);
+
+// Email held for the undo-send window: { id, sendAt, to, subject }
+export const pendingSend = writable(null);
+
+// Show the undo-send notice for a pending send result
+export function holdSend(result, to, subject) {
+  pendingSend.set({ id: result.outboxId, sendAt: new Date(result.sendAt), to, subject });
+  loadOutbox();
+}
+
+// Undo send: cancel the held email (it is kept as a draft)
+export async function cancelPendingSend(id) {
+  pendingSend.set(null);
+  try {
+    await window.go.desktop.App.CancelPendingSend(id);
+    info('Envio cancelado, salvo como rascunho');
+  } catch (err) {
+    warn(`Tarde demais para cancelar: ${err}`);
+  }
+  await loadOutbox();
+}
+
+// Load the outbox
+export async function loadOutbox() {
+  try {
+    if (window.go?.desktop?.App) {
+      const items = await window.go.desktop.App.ListOutbox();
+      outboxItems.set(items || []);
+    }
+  } catch (err) {
+    console.error('Failed to load outbox:', err);
+  }
+}
+
+// Send an email on the next round, resetting its attempts
+export async function retryOutbox(id) {
+  try {
+    await window.go.desktop.App.RetryOutbox(id);
+    info(`Outbox: reenviando #${id}`);
+  } catch (err) {
+    logError('Falha ao reenviar', err);
+  }
+  await loadOutbox();
+}
+
+// Take an email out of the outbox (it is kept as a draft)
+export async function cancelOutbox(id) {
+  try {
+    await window.go.desktop.App.CancelOutbox(id);
+    info(`Outbox: envio #${id} cancelado, salvo como rascunho`);
+  } catch (err) {
+    logError('Falha ao cancelar envio', err);
+  }
+  await loadOutbox();
+}
+
+// Open an outbox email in the compose window; sending updates and requeues it
+export async function editOutbox(id) {
+  try {
+    const draft = await window.go.desktop.App.GetDraft(id);
+    if (!draft) return;
+    window.composeContext = { mode: 'outbox', draft };
+    showOutbox.set(false);
+    showCompose.set(true);
+  } catch (err) {
+    logError('Falha ao abrir email da outbox', err);
+  }
+}
+
+// Keep the outbox fresh as the background worker sends or retries
+export function setupOutboxEvents() {
+  if (typeof window !== 'undefined' && window.runtime) {
+    window.runtime.EventsOn('outbox:changed', async (draftId, status) => {
+      if (status === 'sent')
+        info(`Outbox: email #${draftId} enviado`);
+      // The held email started going out: the undo window is over
+      pendingSend.update(p => (p && p.id === draftId && status !== 'queued') ? null : p);
+      await loadOutbox();
+    });
+  }
+}
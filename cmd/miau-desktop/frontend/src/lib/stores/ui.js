@@ -4,6 +4,7 @@ import { toggleDebug, info, warn, error as logError, debug as logDebug } from '.
 import { toggleSelectionMode, selectAll, someSelected, exitSelectionMode, toggleSelection } from './selection.js';
 import { toggleLayoutMode, toggleSidebar, layoutMode } from './layout.js';
 import { showCalendarPanel } from './calendar.js';
+import { loadLabels } from './folders.js';
 
 // UI State
 export const showSearch = writable(false);
@@ -578,6 +579,10 @@ export function setupWailsEvents() {
       syncing.set(false);
       console.error('Sync error:', error);
     });
+
+    window.runtime.EventsOn('labels:synced', async () => {
+      await loadLabels();
+    });
   }
 }
 
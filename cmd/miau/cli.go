@@ -0,0 +1,1189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/opik/miau/internal/app"
+	"github.com/opik/miau/internal/config"
+	"github.com/opik/miau/internal/pgp"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/rules"
+	"github.com/opik/miau/internal/server"
+	"github.com/opik/miau/internal/sieve"
+	"github.com/opik/miau/internal/smime"
+)
+
+// Exit codes estáveis para scripts e cron
+const (
+	exitOK       = 0
+	exitError    = 1 // falha em tempo de execução (IMAP, SMTP, banco)
+	exitUsage    = 2 // argumentos inválidos
+	exitNotFound = 3 // email inexistente
+)
+
+// unreadScanLimit é quantos emails recentes o "list --unread" examina
+const unreadScanLimit = 1000
+
+// pendingSendGrace é quanto o "send" espera pelo envio depois da janela de
+// desfazer envio; passado isso o email fica na outbox
+const pendingSendGrace = 30 * time.Second
+
+// cliCommand é um subcomando não interativo
+type cliCommand struct {
+	usage   string
+	connect bool // precisa de conexão IMAP
+	run     func(c *cliContext, args []string) int
+}
+
+var cliCommands = map[string]cliCommand{
+	"sync":     {usage: "sync [pasta...]", connect: true, run: cmdSync},
+	"list":     {usage: "list [--folder INBOX] [--unread] [--limit 50] [--json]", run: cmdList},
+	"search":   {usage: "search [--folder pasta] [--limit 50] [--json] <consulta>", run: cmdSearch},
+	"show":     {usage: "show [--json] <id>", run: cmdShow},
+	"send":     {usage: "send --to email [--cc] [--bcc] [--subject] [--body texto | --body-file arquivo|-] [--html] [--attach arquivo] [--sign] [--encrypt] [--json]", run: cmdSend},
+	"archive":  {usage: "archive [--json] <id>...", connect: true, run: cmdArchive},
+	"snooze":   {usage: "snooze [--json] <id> <later_today|tomorrow|this_weekend|next_week|next_month|duração|RFC3339>", run: cmdSnooze},
+	"tasks":    {usage: "tasks [--status pending|completed|all] [--limit 50] [--json]", run: cmdTasks},
+	"sieve":    {usage: "sieve list | generate | push [--name miau] [--no-activate] | pull [--name script] [--raw] [--import [--replace]] [--json]", run: cmdSieve},
+	"pgp":      {usage: "pgp list [--json] | import [arquivo|-] | export [--secret] <email|fingerprint> | delete <fingerprint>", run: cmdPGP},
+	"smime":    {usage: "smime list [--json] | import [arquivo|-] | delete <fingerprint>", run: cmdSMIME},
+	"contacts": {usage: "contacts import [--format vcard|google|outlook] [--json] [arquivo|-] | export [--format vcard|google|outlook]", run: cmdContacts},
+}
+
+// errNotFound marca emails inexistentes (exit code 3)
+var errNotFound = errors.New("não encontrado")
+
+// cliContext é o ambiente de um subcomando
+type cliContext struct {
+	ctx     context.Context
+	app     *app.Application
+	account *config.Account
+	out     io.Writer // stdout real; os.Stdout aponta para stderr durante o comando
+	name    string
+	usage   string
+	json    bool
+}
+
+// runCLI executa um subcomando e retorna o exit code
+func runCLI(name string, cmd cliCommand, args []string) int {
+	// Logs de diagnóstico dos serviços vão para stderr: stdout fica só com a saída
+	var out = os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = out }()
+
+	var cfg, err = config.Load()
+	if err != nil || cfg == nil || len(cfg.Accounts) == 0 {
+		fmt.Fprintln(os.Stderr, "miau: nenhuma conta configurada")
+		return exitError
+	}
+
+	var account = cfg.ActiveAccount()
+	var application, appErr = app.New(cfg, account, cfg.UI.Debug)
+	if appErr != nil {
+		return cliError(appErr)
+	}
+	if err := application.Start(); err != nil {
+		return cliError(err)
+	}
+	defer application.Stop()
+
+	var ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cmd.connect {
+		if err := application.Sync().Connect(ctx); err != nil {
+			return cliError(fmt.Errorf("conexão IMAP: %w", err))
+		}
+		defer application.Sync().Disconnect(context.Background())
+	}
+
+	return cmd.run(&cliContext{ctx: ctx, app: application, account: account, out: out, name: name, usage: cmd.usage}, args)
+}
+
+// flags cria o FlagSet do subcomando com --json
+func (c *cliContext) flags() *flag.FlagSet {
+	var fs = flag.NewFlagSet("miau "+c.name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.BoolVar(&c.json, "json", false, "saída em JSON (padrão: TSV)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "uso: miau %s\n", c.usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse aceita flags antes ou depois dos argumentos posicionais
+func parse(fs *flag.FlagSet, args []string) ([]string, int, bool) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, exitOK, false
+			}
+			return nil, exitUsage, false
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, exitOK, true
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), exitOK, true
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// usageError imprime a mensagem e o uso do subcomando
+func usageError(fs *flag.FlagSet, msg string) int {
+	fmt.Fprintf(os.Stderr, "miau: %s\n", msg)
+	fs.Usage()
+	return exitUsage
+}
+
+func cliError(err error) int {
+	fmt.Fprintf(os.Stderr, "miau: %v\n", err)
+	if errors.Is(err, errNotFound) {
+		return exitNotFound
+	}
+	return exitError
+}
+
+// emailError identifica o email na mensagem e traduz sql.ErrNoRows
+func emailError(id int64, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("email %d: %w", id, errNotFound)
+	}
+	return fmt.Errorf("email %d: %w", id, err)
+}
+
+// writeJSON imprime v indentado
+func (c *cliContext) writeJSON(v interface{}) {
+	var enc = json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeTSV imprime uma linha; tabs e quebras de linha dos campos viram espaço
+func (c *cliContext) writeTSV(fields ...string) {
+	for i, f := range fields {
+		fields[i] = strings.Join(strings.Fields(f), " ")
+	}
+	fmt.Fprintln(c.out, strings.Join(fields, "\t"))
+}
+
+// emailRow é a linha TSV de um email: id, data, remetente, flags, assunto.
+// Flags: N não lido, S com estrela, R respondido, A com anexos.
+func (c *cliContext) emailRow(e *server.EmailDTO) {
+	var flags strings.Builder
+	if !e.IsRead {
+		flags.WriteByte('N')
+	}
+	if e.IsStarred {
+		flags.WriteByte('S')
+	}
+	if e.IsReplied {
+		flags.WriteByte('R')
+	}
+	if e.HasAttachments {
+		flags.WriteByte('A')
+	}
+	if flags.Len() == 0 {
+		flags.WriteByte('-')
+	}
+	c.writeTSV(
+		strconv.FormatInt(e.ID, 10),
+		e.Date.Format(time.RFC3339),
+		e.FromEmail,
+		flags.String(),
+		e.Subject,
+	)
+}
+
+func parseIDs(args []string) ([]int64, error) {
+	var ids = make([]int64, 0, len(args))
+	for _, arg := range args {
+		// Aceita "1 2 3" e "1,2,3"
+		for _, part := range strings.Split(arg, ",") {
+			if part == "" {
+				continue
+			}
+			var id, err = strconv.ParseInt(part, 10, 64)
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("id inválido: %q", part)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// listFlag é uma flag repetível que também aceita valores separados por vírgula
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// SUBCOMANDOS
+// ============================================================================
+
+func cmdSync(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var folders, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(folders) == 0 {
+		folders = []string{"INBOX"}
+	}
+
+	var results = make([]server.SyncResultDTO, 0, len(folders))
+	var exit = exitOK
+	for _, folder := range folders {
+		var result, err = c.app.Sync().SyncFolder(c.ctx, folder)
+		if err != nil {
+			exit = cliError(fmt.Errorf("%s: %w", folder, err))
+			continue
+		}
+		var dto = server.NewSyncResultDTO(folder, result)
+		for _, e := range dto.Errors {
+			fmt.Fprintf(os.Stderr, "miau: %s: %s\n", folder, e)
+			exit = exitError
+		}
+		results = append(results, dto)
+	}
+
+	if c.json {
+		c.writeJSON(results)
+		return exit
+	}
+	for _, r := range results {
+		c.writeTSV(r.Folder, strconv.Itoa(r.NewEmails), strconv.Itoa(r.DeletedEmails), strconv.Itoa(r.FlagUpdates))
+	}
+	return exit
+}
+
+func cmdList(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var folder = fs.String("folder", "INBOX", "pasta")
+	var unread = fs.Bool("unread", false, "apenas não lidos")
+	var limit = fs.Int("limit", 50, "quantidade máxima")
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(rest) > 0 {
+		return usageError(fs, "argumento inesperado: "+rest[0])
+	}
+	if *limit <= 0 {
+		return usageError(fs, "--limit deve ser positivo")
+	}
+
+	var fetch = *limit
+	if *unread {
+		fetch = max(fetch, unreadScanLimit)
+	}
+	var emails, err = c.app.Email().GetEmails(c.ctx, *folder, fetch)
+	if err != nil {
+		return cliError(err)
+	}
+
+	var result = make([]server.EmailDTO, 0, len(emails))
+	for i := range emails {
+		if *unread && emails[i].IsRead {
+			continue
+		}
+		result = append(result, server.NewEmailDTO(&emails[i]))
+		if len(result) == *limit {
+			break
+		}
+	}
+
+	if c.json {
+		c.writeJSON(result)
+		return exitOK
+	}
+	for i := range result {
+		c.emailRow(&result[i])
+	}
+	return exitOK
+}
+
+func cmdSearch(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var folder = fs.String("folder", "", "limita a busca a uma pasta")
+	var limit = fs.Int("limit", 50, "quantidade máxima")
+	var terms, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	var query = strings.TrimSpace(strings.Join(terms, " "))
+	if query == "" {
+		return usageError(fs, "consulta vazia")
+	}
+	if *limit <= 0 {
+		return usageError(fs, "--limit deve ser positivo")
+	}
+
+	var result *ports.SearchResult
+	var err error
+	if *folder != "" {
+		result, err = c.app.Search().SearchInFolder(c.ctx, *folder, query, *limit)
+	} else {
+		result, err = c.app.Search().Search(c.ctx, query, *limit)
+	}
+	if err != nil {
+		return cliError(err)
+	}
+
+	var emails = []server.EmailDTO{}
+	if result != nil {
+		emails = server.NewEmailDTOs(result.Emails)
+	}
+	if c.json {
+		c.writeJSON(emails)
+		return exitOK
+	}
+	for i := range emails {
+		c.emailRow(&emails[i])
+	}
+	return exitOK
+}
+
+func cmdShow(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(rest) != 1 {
+		return usageError(fs, "informe um id")
+	}
+	var ids, err = parseIDs(rest)
+	if err != nil || len(ids) != 1 {
+		return usageError(fs, "id inválido: "+rest[0])
+	}
+
+	var email, getErr = c.app.Email().GetEmail(c.ctx, ids[0])
+	if getErr != nil {
+		return cliError(emailError(ids[0], getErr))
+	}
+	var dto = server.NewEmailDetailDTO(email)
+	if c.json {
+		c.writeJSON(dto)
+		return exitOK
+	}
+
+	// Cabeçalhos em TSV (campo, valor), linha em branco e o corpo em texto
+	c.writeTSV("id", strconv.FormatInt(dto.ID, 10))
+	c.writeTSV("folder", dto.Folder)
+	c.writeTSV("date", dto.Date.Format(time.RFC3339))
+	c.writeTSV("from", strings.TrimSpace(dto.FromName+" <"+dto.FromEmail+">"))
+	c.writeTSV("to", dto.ToAddresses)
+	if dto.CcAddresses != "" {
+		c.writeTSV("cc", dto.CcAddresses)
+	}
+	c.writeTSV("subject", dto.Subject)
+	for _, a := range dto.Attachments {
+		c.writeTSV("attachment", a.Filename, a.ContentType, strconv.FormatInt(a.Size, 10))
+	}
+	fmt.Fprintln(c.out)
+	fmt.Fprintln(c.out, dto.BodyText)
+	return exitOK
+}
+
+func cmdSend(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var to, cc, bcc, attach listFlag
+	fs.Var(&to, "to", "destinatário (repetível ou separado por vírgula)")
+	fs.Var(&cc, "cc", "cópia")
+	fs.Var(&bcc, "bcc", "cópia oculta")
+	fs.Var(&attach, "attach", "arquivo anexo (repetível)")
+	var subject = fs.String("subject", "", "assunto")
+	var body = fs.String("body", "", "corpo da mensagem")
+	var bodyFile = fs.String("body-file", "", `lê o corpo de um arquivo ("-" = stdin)`)
+	var html = fs.Bool("html", false, "o corpo é HTML")
+	var sign = fs.Bool("sign", false, "assina (S/MIME se a conta tiver identidade S/MIME, senão PGP/MIME)")
+	var encrypt = fs.Bool("encrypt", false, "criptografa para todos os destinatários (S/MIME ou PGP/MIME)")
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(rest) > 0 {
+		return usageError(fs, "argumento inesperado: "+rest[0])
+	}
+	if len(to) == 0 {
+		return usageError(fs, "--to é obrigatório")
+	}
+	if *body != "" && *bodyFile != "" {
+		return usageError(fs, "use --body ou --body-file, não ambos")
+	}
+
+	var content = *body
+	if *bodyFile != "" {
+		var data []byte
+		var err error
+		if *bodyFile == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(*bodyFile)
+		}
+		if err != nil {
+			return cliError(err)
+		}
+		content = string(data)
+	}
+
+	var req = &ports.SendRequest{
+		To:      to,
+		Cc:      cc,
+		Bcc:     bcc,
+		Subject: *subject,
+		Sign:    *sign,
+		Encrypt: *encrypt,
+	}
+	if *html {
+		req.BodyHTML = content
+	} else {
+		req.BodyText = content
+	}
+
+	for _, path := range attach {
+		var att, err = readAttachment(path)
+		if err != nil {
+			return cliError(err)
+		}
+		req.Attachments = append(req.Attachments, *att)
+	}
+
+	// Com janela de desfazer envio o email sai em background: escuta a
+	// outbox antes de enviar para saber quando ele saiu
+	var outboxEvents = make(chan ports.OutboxChangedEvent, 16)
+	var unsubscribe = c.app.Events().Subscribe(ports.EventTypeOutboxChanged, func(evt ports.Event) {
+		if e, ok := evt.(ports.OutboxChangedEvent); ok {
+			select {
+			case outboxEvents <- e:
+			default:
+			}
+		}
+	})
+	defer unsubscribe()
+
+	var result, err = c.app.Send().Send(c.ctx, req)
+	if err == nil && result != nil && result.Pending {
+		result, err = c.waitPendingSend(result, outboxEvents)
+	}
+	if err == nil && result != nil && !result.Success && result.Error != nil {
+		err = result.Error
+	}
+	if err != nil {
+		if c.json {
+			c.writeJSON(server.SendResultDTO{Success: false, Error: err.Error()})
+		}
+		return cliError(err)
+	}
+
+	// Sem conexão com o servidor de envio: ficou na outbox do app
+	if result.Queued {
+		if c.json {
+			c.writeJSON(server.SendResultDTO{Queued: true, OutboxID: result.OutboxID})
+			return exitOK
+		}
+		c.writeTSV("queued", strconv.FormatInt(result.OutboxID, 10))
+		return exitOK
+	}
+
+	if c.json {
+		c.writeJSON(server.SendResultDTO{Success: true, MessageID: result.MessageID})
+		return exitOK
+	}
+	c.writeTSV("sent", result.MessageID)
+	return exitOK
+}
+
+// waitPendingSend aguarda o envio de um email segurado pela janela de
+// desfazer envio. Ctrl+C desfaz o envio; se o email não sair (sem conexão,
+// nova tentativa), o resultado é Queued e ele continua na outbox.
+func (c *cliContext) waitPendingSend(pending *ports.SendResult, events chan ports.OutboxChangedEvent) (*ports.SendResult, error) {
+	var id = pending.OutboxID
+	var queued = &ports.SendResult{Queued: true, OutboxID: id}
+	fmt.Fprintf(os.Stderr, "miau: enviando em %ds (Ctrl+C desfaz o envio)\n", int(time.Until(pending.SendAt).Round(time.Second).Seconds()))
+
+	var timeout = time.NewTimer(time.Until(pending.SendAt) + pendingSendGrace)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			if err := c.app.Send().CancelPendingSend(context.Background(), id); err != nil {
+				return nil, fmt.Errorf("tarde demais para desfazer: %w", err)
+			}
+			return nil, fmt.Errorf("envio desfeito, salvo como rascunho #%d", id)
+
+		case e := <-events:
+			if e.DraftID != id {
+				continue
+			}
+			switch e.Status {
+			case ports.DraftStatusSent:
+				return &ports.SendResult{Success: true, MessageID: e.MessageID}, nil
+			case ports.DraftStatusDraft:
+				return nil, fmt.Errorf("envio cancelado, salvo como rascunho #%d", id)
+			}
+
+		case <-timeout.C:
+			// Eventos podem chegar fora de ordem: o estado vem da outbox
+			var items, err = c.app.Outbox().List(c.ctx)
+			if err != nil {
+				return nil, err
+			}
+			var item *ports.OutboxItem
+			for i := range items {
+				if items[i].ID == id {
+					item = &items[i]
+				}
+			}
+
+			switch {
+			case item != nil && item.Status == ports.DraftStatusSending:
+				timeout.Reset(pendingSendGrace)
+			case item != nil:
+				if item.LastError != "" {
+					fmt.Fprintf(os.Stderr, "miau: envio falhou (%s)\n", item.LastError)
+				}
+				return queued, nil
+			default:
+				// Saiu da outbox: foi enviado ou cancelado
+				if draft, err := c.app.Draft().GetDraft(c.ctx, id); err == nil && draft.Status == ports.DraftStatusSent {
+					return &ports.SendResult{Success: true}, nil
+				}
+				return nil, fmt.Errorf("envio cancelado, salvo como rascunho #%d", id)
+			}
+		}
+	}
+}
+
+// readAttachment carrega um arquivo local como anexo
+func readAttachment(path string) (*ports.Attachment, error) {
+	var data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var contentType = mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return &ports.Attachment{
+		Filename:    filepath.Base(path),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Data:        data,
+	}, nil
+}
+
+// actionResult é o resultado por email de comandos em lote
+type actionResult struct {
+	ID    int64  `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func cmdArchive(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	var ids, err = parseIDs(rest)
+	if err != nil {
+		return usageError(fs, err.Error())
+	}
+	if len(ids) == 0 {
+		return usageError(fs, "informe ao menos um id")
+	}
+
+	// Continua nos demais ids; o exit code reflete a primeira falha
+	var results = make([]actionResult, 0, len(ids))
+	var exit = exitOK
+	for _, id := range ids {
+		var result = actionResult{ID: id, OK: true}
+		if err := c.app.Email().Archive(c.ctx, id); err != nil {
+			err = emailError(id, err)
+			result.OK = false
+			result.Error = err.Error()
+			if code := cliError(err); exit == exitOK {
+				exit = code
+			}
+		}
+		results = append(results, result)
+	}
+
+	if c.json {
+		c.writeJSON(results)
+		return exit
+	}
+	for _, r := range results {
+		var status = "archived"
+		if !r.OK {
+			status = "error"
+		}
+		c.writeTSV(strconv.FormatInt(r.ID, 10), status)
+	}
+	return exit
+}
+
+func cmdSnooze(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(rest) != 2 {
+		return usageError(fs, "informe o id e quando")
+	}
+	var ids, err = parseIDs(rest[:1])
+	if err != nil || len(ids) != 1 {
+		return usageError(fs, "id inválido: "+rest[0])
+	}
+	var id = ids[0]
+
+	// O email precisa existir localmente
+	if _, err := c.app.Email().GetEmail(c.ctx, id); err != nil {
+		return cliError(emailError(id, err))
+	}
+
+	var until time.Time
+	var preset = ports.SnoozePreset(rest[1])
+	for _, p := range c.app.Snooze().GetSnoozePresets() {
+		if p.Preset == preset {
+			until = p.Time
+		}
+	}
+
+	if !until.IsZero() {
+		err = c.app.Snooze().SnoozeEmailPreset(c.ctx, id, preset)
+	} else {
+		until, err = parseSnoozeTime(rest[1], time.Now())
+		if err != nil {
+			return usageError(fs, err.Error())
+		}
+		preset = ports.SnoozeCustom
+		err = c.app.Snooze().SnoozeEmail(c.ctx, id, until)
+	}
+	if err != nil {
+		return cliError(err)
+	}
+
+	if c.json {
+		c.writeJSON(server.SnoozedEmailDTO{
+			EmailID:     id,
+			SnoozedAt:   time.Now(),
+			SnoozeUntil: until,
+			Preset:      string(preset),
+		})
+		return exitOK
+	}
+	c.writeTSV(strconv.FormatInt(id, 10), until.Format(time.RFC3339), string(preset))
+	return exitOK
+}
+
+// parseSnoozeTime aceita uma duração ("2h", "90m") ou um horário RFC3339
+func parseSnoozeTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("duração deve ser positiva: %q", value)
+		}
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		if !t.After(now) {
+			return time.Time{}, fmt.Errorf("horário no passado: %q", value)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("quando inválido: %q (use um preset, uma duração ou RFC3339)", value)
+}
+
+func cmdTasks(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var status = fs.String("status", "pending", "pending, completed ou all")
+	var limit = fs.Int("limit", 50, "quantidade máxima de concluídas")
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(rest) > 0 {
+		return usageError(fs, "argumento inesperado: "+rest[0])
+	}
+
+	var account = c.app.GetCurrentAccount()
+	if account == nil {
+		return cliError(fmt.Errorf("nenhuma conta ativa"))
+	}
+
+	var tasks []ports.TaskInfo
+	var err error
+	switch *status {
+	case "pending":
+		tasks, err = c.app.Tasks().GetPendingTasks(c.ctx, account.ID)
+	case "completed":
+		tasks, err = c.app.Tasks().GetCompletedTasks(c.ctx, account.ID, *limit)
+	case "all":
+		tasks, err = c.app.Tasks().GetTasks(c.ctx, account.ID)
+	default:
+		return usageError(fs, "--status deve ser pending, completed ou all")
+	}
+	if err != nil {
+		return cliError(err)
+	}
+
+	var result = make([]server.TaskDTO, 0, len(tasks))
+	for i := range tasks {
+		result = append(result, server.NewTaskDTO(&tasks[i]))
+	}
+	if c.json {
+		c.writeJSON(result)
+		return exitOK
+	}
+	// id, concluída (0/1), prioridade, vencimento, título
+	for _, t := range result {
+		var done, due = "0", ""
+		if t.IsCompleted {
+			done = "1"
+		}
+		if t.DueDate != nil {
+			due = t.DueDate.Format(time.RFC3339)
+		}
+		c.writeTSV(strconv.FormatInt(t.ID, 10), done, strconv.Itoa(t.Priority), due, t.Title)
+	}
+	return exitOK
+}
+
+// sieveScriptDTO é a saída JSON de "sieve list" e "sieve push"
+type sieveScriptDTO struct {
+	Name    string   `json:"name"`
+	Active  bool     `json:"active"`
+	Rules   int      `json:"rules,omitempty"`
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// cmdSieve sincroniza as regras com o servidor via ManageSieve
+func cmdSieve(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var name = fs.String("name", sieve.ScriptName(c.account), "nome do script no servidor")
+	var noActivate = fs.Bool("no-activate", false, "push: envia sem ativar o script")
+	var raw = fs.Bool("raw", false, "pull: imprime o script Sieve sem converter")
+	var doImport = fs.Bool("import", false, "pull: importa as regras do script")
+	var replace = fs.Bool("replace", false, "pull --import: substitui as regras atuais")
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(rest) != 1 {
+		return usageError(fs, "informe a ação: list, generate, push ou pull")
+	}
+	var nameSet = false
+	fs.Visit(func(f *flag.Flag) { nameSet = nameSet || f.Name == "name" })
+
+	switch rest[0] {
+	case "generate":
+		var list, err = c.app.Rules().GetRules(c.ctx)
+		if err != nil {
+			return cliError(err)
+		}
+		var script, skipped = sieve.Generate(list)
+		for _, s := range skipped {
+			fmt.Fprintf(os.Stderr, "miau: ignorado: %s\n", s)
+		}
+		fmt.Fprint(c.out, script)
+		return exitOK
+	case "list", "push", "pull":
+	default:
+		return usageError(fs, "ação desconhecida: "+rest[0])
+	}
+
+	var client, err = sieve.DialAccount(c.account)
+	if err != nil {
+		return cliError(err)
+	}
+	defer client.Logout()
+
+	switch rest[0] {
+	case "list":
+		var scripts, err = client.ListScripts()
+		if err != nil {
+			return cliError(err)
+		}
+		var result = make([]sieveScriptDTO, 0, len(scripts))
+		for _, s := range scripts {
+			result = append(result, sieveScriptDTO{Name: s.Name, Active: s.Active})
+		}
+		if c.json {
+			c.writeJSON(result)
+			return exitOK
+		}
+		// nome, ativo (0/1)
+		for _, s := range result {
+			var active = "0"
+			if s.Active {
+				active = "1"
+			}
+			c.writeTSV(s.Name, active)
+		}
+		return exitOK
+
+	case "push":
+		var list, err = c.app.Rules().GetRules(c.ctx)
+		if err != nil {
+			return cliError(err)
+		}
+		var script, skipped = sieve.Generate(list)
+		for _, s := range skipped {
+			fmt.Fprintf(os.Stderr, "miau: ignorado: %s\n", s)
+		}
+		if err := client.CheckScript(script); err != nil {
+			return cliError(fmt.Errorf("servidor rejeitou o script: %w", err))
+		}
+		if err := client.PutScript(*name, script); err != nil {
+			return cliError(err)
+		}
+		if !*noActivate {
+			if err := client.SetActive(*name); err != nil {
+				return cliError(err)
+			}
+		}
+		var result = sieveScriptDTO{Name: *name, Active: !*noActivate, Rules: len(list), Skipped: skipped}
+		if c.json {
+			c.writeJSON(result)
+			return exitOK
+		}
+		var active = "0"
+		if result.Active {
+			active = "1"
+		}
+		c.writeTSV(result.Name, active)
+		return exitOK
+	}
+
+	// pull: sem --name usa o script ativo
+	var scriptName = *name
+	if !nameSet {
+		var active, err = client.ActiveScript()
+		if err != nil {
+			return cliError(err)
+		}
+		if active == "" {
+			return cliError(fmt.Errorf("nenhum script ativo no servidor: %w", errNotFound))
+		}
+		scriptName = active
+	}
+	var script, err2 = client.GetScript(scriptName)
+	if err2 != nil {
+		var respErr *sieve.ResponseError
+		if errors.As(err2, &respErr) && respErr.Code == "NONEXISTENT" {
+			return cliError(fmt.Errorf("script %q: %w", scriptName, errNotFound))
+		}
+		return cliError(err2)
+	}
+	if *raw {
+		fmt.Fprint(c.out, script)
+		return exitOK
+	}
+
+	var list, err3 = sieve.Parse(script)
+	if err3 != nil {
+		return cliError(fmt.Errorf("script %q: %w", scriptName, err3))
+	}
+	if !*doImport {
+		fmt.Fprint(c.out, rules.Format(list))
+		return exitOK
+	}
+
+	var imported, err4 = c.app.Rules().ImportRules(c.ctx, rules.Format(list), *replace)
+	if err4 != nil {
+		return cliError(err4)
+	}
+	if c.json {
+		c.writeJSON(sieveScriptDTO{Name: scriptName, Rules: len(imported)})
+		return exitOK
+	}
+	c.writeTSV(scriptName, strconv.Itoa(len(imported)))
+	return exitOK
+}
+
+// cmdPGP gerencia o chaveiro OpenPGP local
+func cmdPGP(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var secret = fs.Bool("secret", false, "export: inclui a chave secreta (continua protegida pela senha)")
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(rest) == 0 {
+		return usageError(fs, "informe a ação: list, import, export ou delete")
+	}
+
+	var keyring = c.app.PGP()
+	switch rest[0] {
+	case "list":
+		if len(rest) != 1 {
+			return usageError(fs, "argumento inesperado: "+rest[1])
+		}
+		var keys, err = keyring.ListKeys(c.ctx)
+		if err != nil {
+			return cliError(err)
+		}
+		c.writePGPKeys(keys)
+		return exitOK
+
+	case "import":
+		if len(rest) > 2 {
+			return usageError(fs, "argumento inesperado: "+rest[2])
+		}
+		var data []byte
+		var err error
+		if len(rest) == 1 || rest[1] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(rest[1])
+		}
+		if err != nil {
+			return cliError(err)
+		}
+		keys, err := keyring.ImportKeys(c.ctx, data)
+		if err != nil {
+			return cliError(err)
+		}
+		c.writePGPKeys(keys)
+		return exitOK
+
+	case "export":
+		if len(rest) != 2 {
+			return usageError(fs, "informe o email ou fingerprint da chave")
+		}
+		var armored, err = keyring.ExportKey(c.ctx, rest[1], *secret)
+		if err != nil {
+			return cliError(keyError(rest[1], err))
+		}
+		c.out.Write(armored)
+		return exitOK
+
+	case "delete":
+		if len(rest) != 2 {
+			return usageError(fs, "informe o fingerprint da chave")
+		}
+		if err := keyring.DeleteKey(c.ctx, rest[1]); err != nil {
+			return cliError(keyError(rest[1], err))
+		}
+		if c.json {
+			c.writeJSON(map[string]string{"deleted": rest[1]})
+			return exitOK
+		}
+		c.writeTSV("deleted", rest[1])
+		return exitOK
+	}
+	return usageError(fs, "ação desconhecida: "+rest[0])
+}
+
+// keyError identifica a chave na mensagem; chave inexistente vira exit code 3
+func keyError(query string, err error) error {
+	if errors.Is(err, pgp.ErrKeyNotFound) {
+		return fmt.Errorf("chave %s: %w", query, errNotFound)
+	}
+	return err
+}
+
+// writePGPKeys imprime chaves como JSON ou TSV
+func (c *cliContext) writePGPKeys(keys []ports.PGPKey) {
+	if c.json {
+		c.writeJSON(keys)
+		return
+	}
+	// fingerprint, secreta (0/1), emails separados por vírgula
+	for _, k := range keys {
+		var kind = "0"
+		if k.Secret {
+			kind = "1"
+		}
+		c.writeTSV(k.Fingerprint, kind, strings.Join(k.Emails, ","))
+	}
+}
+
+// cmdSMIME gerencia os certificados S/MIME dos correspondentes
+func cmdSMIME(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(rest) == 0 {
+		return usageError(fs, "informe a ação: list, import ou delete")
+	}
+
+	var certs = c.app.SMIME()
+	switch rest[0] {
+	case "list":
+		if len(rest) != 1 {
+			return usageError(fs, "argumento inesperado: "+rest[1])
+		}
+		var list, err = certs.ListCertificates(c.ctx)
+		if err != nil {
+			return cliError(err)
+		}
+		c.writeSMIMECertificates(list)
+		return exitOK
+
+	case "import":
+		if len(rest) > 2 {
+			return usageError(fs, "argumento inesperado: "+rest[2])
+		}
+		var data []byte
+		var err error
+		if len(rest) == 1 || rest[1] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(rest[1])
+		}
+		if err != nil {
+			return cliError(err)
+		}
+		list, err := certs.ImportCertificates(c.ctx, data)
+		if err != nil {
+			return cliError(err)
+		}
+		c.writeSMIMECertificates(list)
+		return exitOK
+
+	case "delete":
+		if len(rest) != 2 {
+			return usageError(fs, "informe o fingerprint do certificado")
+		}
+		if err := certs.DeleteCertificate(c.ctx, rest[1]); err != nil {
+			if errors.Is(err, smime.ErrCertNotFound) {
+				err = fmt.Errorf("certificado %s: %w", rest[1], errNotFound)
+			}
+			return cliError(err)
+		}
+		if c.json {
+			c.writeJSON(map[string]string{"deleted": rest[1]})
+			return exitOK
+		}
+		c.writeTSV("deleted", rest[1])
+		return exitOK
+	}
+	return usageError(fs, "ação desconhecida: "+rest[0])
+}
+
+// writeSMIMECertificates imprime certificados como JSON ou TSV
+func (c *cliContext) writeSMIMECertificates(certs []ports.SMIMECertificate) {
+	if c.json {
+		c.writeJSON(certs)
+		return
+	}
+	// fingerprint, próprio (0/1), validade, emails separados por vírgula
+	for _, cert := range certs {
+		var own = "0"
+		if cert.Own {
+			own = "1"
+		}
+		c.writeTSV(cert.Fingerprint, own, cert.NotAfter.Format("2006-01-02"), strings.Join(cert.Emails, ","))
+	}
+}
+
+// contactImportDTO é a saída JSON de "contacts import"
+type contactImportDTO struct {
+	Created int `json:"created"`
+	Merged  int `json:"merged"`
+	Skipped int `json:"skipped"`
+}
+
+// cmdContacts importa e exporta contatos em vCard ou CSV (Google, Outlook)
+func cmdContacts(c *cliContext, args []string) int {
+	var fs = c.flags()
+	var format = fs.String("format", "", "vcard, google ou outlook (import: detecta pelo conteúdo; export: vcard)")
+	var rest, code, ok = parse(fs, args)
+	if !ok {
+		return code
+	}
+	if len(rest) == 0 {
+		return usageError(fs, "informe a ação: import ou export")
+	}
+	switch ports.ContactFormat(*format) {
+	case "", ports.ContactFormatVCard, ports.ContactFormatGoogleCSV, ports.ContactFormatOutlookCSV:
+	default:
+		return usageError(fs, "--format deve ser vcard, google ou outlook")
+	}
+
+	var account = c.app.GetCurrentAccount()
+	if account == nil {
+		return cliError(fmt.Errorf("nenhuma conta ativa"))
+	}
+
+	switch rest[0] {
+	case "import":
+		if len(rest) > 2 {
+			return usageError(fs, "argumento inesperado: "+rest[2])
+		}
+		var data []byte
+		var err error
+		if len(rest) == 1 || rest[1] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(rest[1])
+		}
+		if err != nil {
+			return cliError(err)
+		}
+		result, err := c.app.Contacts().ImportContacts(c.ctx, account.ID, data, ports.ContactFormat(*format))
+		if err != nil {
+			return cliError(err)
+		}
+		if c.json {
+			c.writeJSON(contactImportDTO{Created: result.Created, Merged: result.Merged, Skipped: result.Skipped})
+			return exitOK
+		}
+		c.writeTSV("created", strconv.Itoa(result.Created))
+		c.writeTSV("merged", strconv.Itoa(result.Merged))
+		c.writeTSV("skipped", strconv.Itoa(result.Skipped))
+		return exitOK
+
+	case "export":
+		if len(rest) != 1 {
+			return usageError(fs, "argumento inesperado: "+rest[1])
+		}
+		if *format == "" {
+			*format = string(ports.ContactFormatVCard)
+		}
+		var data, err = c.app.Contacts().ExportContacts(c.ctx, account.ID, ports.ContactFormat(*format))
+		if err != nil {
+			return cliError(err)
+		}
+		c.out.Write(data)
+		return exitOK
+	}
+	return usageError(fs, "ação desconhecida: "+rest[0])
+}
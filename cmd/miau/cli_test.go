@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDs(t *testing.T) {
+	var cases = []struct {
+		name    string
+		args    []string
+		want    []int64
+		wantErr bool
+	}{
+		{name: "separate args", args: []string{"1", "2"}, want: []int64{1, 2}},
+		{name: "comma separated", args: []string{"1,2,3"}, want: []int64{1, 2, 3}},
+		{name: "mixed with empty parts", args: []string{"4,", ",5", "6"}, want: []int64{4, 5, 6}},
+		{name: "none", args: nil, want: []int64{}},
+		{name: "zero", args: []string{"0"}, wantErr: true},
+		{name: "negative", args: []string{"-3"}, wantErr: true},
+		{name: "not a number", args: []string{"1,abc"}, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ids, err = parseIDs(tc.args)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, ids)
+		})
+	}
+}
+
+func TestParseSnoozeTime(t *testing.T) {
+	var now = time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)
+	var cases = []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2h", want: now.Add(2 * time.Hour)},
+		{value: "90m", want: now.Add(90 * time.Minute)},
+		{value: "2026-05-05T09:00:00Z", want: time.Date(2026, 5, 5, 9, 0, 0, 0, time.UTC)},
+		{value: "-1h", wantErr: true},
+		{value: "0s", wantErr: true},
+		{value: "2026-05-04T09:00:00Z", wantErr: true}, // in the past
+		{value: "someday", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.value, func(t *testing.T) {
+			var until, err = parseSnoozeTime(tc.value, now)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tc.want.Equal(until), "got %s", until)
+		})
+	}
+}
+
+func TestListFlag(t *testing.T) {
+	var cases = []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{name: "repeated", values: []string{"a@x.com", "b@x.com"}, want: []string{"a@x.com", "b@x.com"}},
+		{name: "comma separated", values: []string{"a@x.com, b@x.com"}, want: []string{"a@x.com", "b@x.com"}},
+		{name: "empty parts", values: []string{",a@x.com,,", " "}, want: []string{"a@x.com"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var l listFlag
+			for _, v := range tc.values {
+				require.NoError(t, l.Set(v))
+			}
+			assert.Equal(t, tc.want, []string(l))
+			assert.Equal(t, strings.Join(tc.want, ","), l.String())
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	var cases = []struct {
+		name     string
+		args     []string
+		wantRest []string
+		wantCode int
+		wantOK   bool
+		wantJSON bool
+	}{
+		{name: "flags after arguments", args: []string{"1", "--json", "2"}, wantRest: []string{"1", "2"}, wantOK: true, wantJSON: true},
+		{name: "double dash", args: []string{"--", "--json"}, wantRest: []string{"--json"}, wantOK: true},
+		{name: "help", args: []string{"-h"}, wantCode: exitOK},
+		{name: "unknown flag", args: []string{"--nope"}, wantCode: exitUsage},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var c = &cliContext{name: "test", usage: "test"}
+			var rest, code, ok = parse(c.flags(), tc.args)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.wantCode, code)
+			assert.Equal(t, tc.wantRest, rest)
+			assert.Equal(t, tc.wantJSON, c.json)
+		})
+	}
+}
+
+func TestOutput(t *testing.T) {
+	var date = time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)
+	var cases = []struct {
+		name  string
+		write func(c *cliContext)
+		want  string
+	}{
+		{
+			name:  "TSV fields lose tabs and newlines",
+			write: func(c *cliContext) { c.writeTSV("1", "a\tb", "line\nbreak  here") },
+			want:  "1\ta b\tline break here\n",
+		},
+		{
+			name: "email row flags",
+			write: func(c *cliContext) {
+				c.emailRow(&server.EmailDTO{ID: 7, Date: date, FromEmail: "a@x.com", IsStarred: true, IsReplied: true, HasAttachments: true, Subject: "Hi"})
+				c.emailRow(&server.EmailDTO{ID: 8, Date: date, FromEmail: "b@x.com", IsRead: true, Subject: "Re:\tHi"})
+			},
+			want: "7\t2026-05-04T10:00:00Z\ta@x.com\tNSRA\tHi\n" +
+				"8\t2026-05-04T10:00:00Z\tb@x.com\t-\tRe: Hi\n",
+		},
+		{
+			name: "JSON is indented",
+			write: func(c *cliContext) {
+				c.writeJSON([]actionResult{{ID: 1, OK: false, Error: "email 1: não encontrado"}})
+			},
+			want: "[\n  {\n    \"id\": 1,\n    \"ok\": false,\n    \"error\": \"email 1: não encontrado\"\n  }\n]\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			tc.write(&cliContext{out: &out})
+			assert.Equal(t, tc.want, out.String())
+		})
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	var cases = []struct {
+		name string
+		code func() int
+		want int
+	}{
+		{name: "missing email", code: func() int { return cliError(emailError(5, sql.ErrNoRows)) }, want: exitNotFound},
+		{name: "wrapped not found", code: func() int { return cliError(fmt.Errorf("chave x: %w", errNotFound)) }, want: exitNotFound},
+		{name: "runtime error", code: func() int { return cliError(emailError(5, errors.New("imap: connection reset"))) }, want: exitError},
+		{name: "usage error", code: func() int {
+			var c = &cliContext{name: "test", usage: "test"}
+			return usageError(c.flags(), "informe o id")
+		}, want: exitUsage},
+
+		// Invalid arguments are rejected before the app is touched
+		{name: "archive without ids", code: func() int { return cmdArchive(&cliContext{name: "archive"}, nil) }, want: exitUsage},
+		{name: "archive with a bad id", code: func() int { return cmdArchive(&cliContext{name: "archive"}, []string{"1,x"}) }, want: exitUsage},
+		{name: "snooze without when", code: func() int { return cmdSnooze(&cliContext{name: "snooze"}, []string{"1"}) }, want: exitUsage},
+		{name: "unknown flag", code: func() int { return cmdArchive(&cliContext{name: "archive"}, []string{"--nope", "1"}) }, want: exitUsage},
+		{name: "help", code: func() int { return cmdArchive(&cliContext{name: "archive"}, []string{"--help"}) }, want: exitOK},
+		{name: "contacts without action", code: func() int { return cmdContacts(&cliContext{name: "contacts"}, nil) }, want: exitUsage},
+		{name: "contacts with a bad format", code: func() int {
+			return cmdContacts(&cliContext{name: "contacts"}, []string{"export", "--format", "json"})
+		}, want: exitUsage},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.code())
+		})
+	}
+}
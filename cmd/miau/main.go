@@ -69,21 +69,24 @@ func initialModel(debugMode bool) model {
 			m.cfg = cfg
 
 			// Create Application for centralized services
-			var application, appErr = app.New(cfg, &cfg.Accounts[0], debugMode)
+			var account = cfg.ActiveAccount()
+			var application, appErr = app.New(cfg, account, debugMode)
 			if appErr == nil {
 				m.application = application
 				// Start the application (initializes services)
 				var startErr = application.Start()
 				if startErr == nil {
+					// Demais contas sincronizam em background (caixa unificada)
+					application.StartBackgroundSync()
 					// Pass Application to inbox for centralized service access
-					m.inboxModel = inbox.New(&cfg.Accounts[0], debugMode, application)
+					m.inboxModel = inbox.New(account, debugMode, application)
 				} else {
 					// Start failed, use legacy mode
-					m.inboxModel = inbox.New(&cfg.Accounts[0], debugMode)
+					m.inboxModel = inbox.New(account, debugMode)
 				}
 			} else {
 				// Fallback: create inbox without Application (legacy mode)
-				m.inboxModel = inbox.New(&cfg.Accounts[0], debugMode)
+				m.inboxModel = inbox.New(account, debugMode)
 			}
 			return m
 		}
@@ -125,17 +128,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.cfg = cfg
 
 			// Create Application for centralized services
-			var application, appErr = app.New(cfg, &cfg.Accounts[0], m.debugMode)
+			var account = cfg.ActiveAccount()
+			var application, appErr = app.New(cfg, account, m.debugMode)
 			if appErr == nil {
 				m.application = application
 				var startErr = application.Start()
 				if startErr == nil {
-					m.inboxModel = inbox.New(&cfg.Accounts[0], m.debugMode, application)
+					application.StartBackgroundSync()
+					m.inboxModel = inbox.New(account, m.debugMode, application)
 				} else {
-					m.inboxModel = inbox.New(&cfg.Accounts[0], m.debugMode)
+					m.inboxModel = inbox.New(account, m.debugMode)
 				}
 			} else {
-				m.inboxModel = inbox.New(&cfg.Accounts[0], m.debugMode)
+				m.inboxModel = inbox.New(account, m.debugMode)
 			}
 
 			m.state = stateInbox
@@ -179,6 +184,19 @@ func main() {
 		return
 	}
 
+	// API REST local para scripts e automações
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+
+	// Subcomandos não interativos (sync, list, search, show, send, ...)
+	if len(os.Args) > 1 {
+		if cmd, ok := cliCommands[os.Args[1]]; ok {
+			os.Exit(runCLI(os.Args[1], cmd, os.Args[2:]))
+		}
+	}
+
 	// Verifica flag --debug (flag tem prioridade sobre config)
 	var debugMode = false
 	var debugFlagSet = false
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/opik/miau/internal/app"
+	"github.com/opik/miau/internal/config"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/server"
+)
+
+// runServe inicia a API REST local (miau serve [--addr 127.0.0.1:8421])
+func runServe(args []string) {
+	var flags = flag.NewFlagSet("serve", flag.ExitOnError)
+	var addr = flags.String("addr", "", "endereço de escuta (apenas loopback)")
+	var debug = flags.Bool("debug", false, "logs de debug")
+	flags.Parse(args)
+
+	var cfg, err = config.Load()
+	if err != nil || cfg == nil || len(cfg.Accounts) == 0 {
+		fmt.Println("❌ Nenhuma conta configurada")
+		os.Exit(1)
+	}
+
+	if *addr == "" {
+		*addr = cfg.Server.Addr
+	}
+
+	// Token: config > variável de ambiente > arquivo gerado
+	var token = cfg.Server.Token
+	var tokenSource = "config.yaml"
+	if token == "" {
+		token = os.Getenv("MIAU_SERVER_TOKEN")
+		tokenSource = "MIAU_SERVER_TOKEN"
+	}
+	if token == "" {
+		var tokenPath = filepath.Join(config.GetConfigPath(), "server.token")
+		token, err = server.LoadOrCreateToken(tokenPath)
+		if err != nil {
+			fmt.Printf("❌ Erro ao gerar token: %v\n", err)
+			os.Exit(1)
+		}
+		tokenSource = tokenPath
+	}
+
+	var application, appErr = app.New(cfg, cfg.ActiveAccount(), *debug || cfg.UI.Debug)
+	if appErr != nil {
+		fmt.Printf("❌ Erro ao iniciar: %v\n", appErr)
+		os.Exit(1)
+	}
+	if err := application.Start(); err != nil {
+		fmt.Printf("❌ Erro ao iniciar: %v\n", err)
+		os.Exit(1)
+	}
+	defer application.Stop()
+
+	var srv, srvErr = server.New(application, server.Options{Addr: *addr, Token: token})
+	if srvErr != nil {
+		fmt.Printf("❌ %v\n", srvErr)
+		os.Exit(1)
+	}
+
+	var ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Conecta em background: a API já responde (dados locais) enquanto o IMAP conecta
+	go func() {
+		if err := application.Sync().Connect(ctx); err != nil {
+			log.Printf("[serve] connect failed: %v", err)
+			return
+		}
+		if err := application.Sync().StartIdle(ctx); err != nil {
+			log.Printf("[serve] push sync not started: %v", err)
+		}
+	}()
+	// As demais contas sincronizam em background (caixa unificada)
+	application.StartBackgroundSync()
+
+	fmt.Println("🐱 miau - API local")
+	fmt.Printf("📧 Conta: %s\n", cfg.ActiveAccount().Email)
+	if len(cfg.Accounts) > 1 {
+		fmt.Printf("📬 %d contas conectadas (caixa unificada: %q)\n", len(cfg.Accounts), ports.UnifiedInbox)
+	}
+	fmt.Printf("🌐 http://%s/api/v1 (OpenAPI em /api/v1/openapi.json)\n", *addr)
+	fmt.Printf("🔑 Token: %s\n", tokenSource)
+
+	if err := srv.ListenAndServe(ctx); err != nil {
+		fmt.Printf("❌ Erro no servidor: %v\n", err)
+		application.Stop()
+		os.Exit(1)
+	}
+}
@@ -63,30 +63,43 @@ func NewGmailAPIAdapter(account *config.Account, configDir string) *GmailAPIAdap
 	}
 }
 
+// SyncBackend returns a sync backend on the Gmail API for the account, an
+// alternative to IMAP (sync_backend: gmail_api)
+func (a *GmailAPIAdapter) SyncBackend(accountID int64) *gmail.SyncBackend {
+	return gmail.NewSyncBackend(a.client, NewGmailSyncState(accountID))
+}
+
 // Send sends an email via Gmail API
 func (a *GmailAPIAdapter) Send(ctx context.Context, req *ports.SendRequest) (*ports.SendResult, error) {
 	if a.client == nil {
 		return nil, fmt.Errorf("Gmail API client not initialized")
 	}
 
-	// Determine body to send (prefer HTML if available)
+	// Determine body to send (prefer HTML if available, keep text as alternative)
 	var body = req.BodyText
+	var textBody string
 	var isHTML = false
 	if req.BodyHTML != "" {
 		body = req.BodyHTML
+		textBody = req.BodyText
 		isHTML = true
 	}
 
 	// Convert ports.SendRequest to gmail.SendRequest
 	var gmailReq = &gmail.SendRequest{
-		To:         req.To,
-		Cc:         req.Cc,
-		Bcc:        req.Bcc,
-		Subject:    req.Subject,
-		Body:       body,
-		InReplyTo:  req.InReplyTo,
-		References: req.ReferenceIDs,
-		IsHTML:     isHTML,
+		To:             req.To,
+		Cc:             req.Cc,
+		Bcc:            req.Bcc,
+		Subject:        req.Subject,
+		Body:           body,
+		TextBody:       textBody,
+		InReplyTo:      req.InReplyTo,
+		References:     req.ReferenceIDs,
+		IsHTML:         isHTML,
+		Attachments:    toMessageAttachments(req.Attachments),
+		Calendar:       req.Calendar,
+		CalendarMethod: req.CalendarMethod,
+		Protect:        req.Protect,
 	}
 
 	var result, err = a.client.SendMessage(gmailReq)
@@ -97,6 +110,7 @@ func (a *GmailAPIAdapter) Send(ctx context.Context, req *ports.SendRequest) (*po
 	return &ports.SendResult{
 		Success:   true,
 		MessageID: result.ID,
+		ThreadID:  result.ThreadID,
 	}, nil
 }
 
@@ -124,6 +138,65 @@ func (a *GmailAPIAdapter) Archive(ctx context.Context, messageID string) error {
 	return a.client.ArchiveMessage(gmailID)
 }
 
+// ListLabels returns the system and user labels of the mailbox
+func (a *GmailAPIAdapter) ListLabels(ctx context.Context) ([]ports.Label, error) {
+	if a.client == nil {
+		return nil, fmt.Errorf("Gmail API client not initialized")
+	}
+
+	var labels, err = a.client.ListLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make([]ports.Label, 0, len(labels))
+	for _, l := range labels {
+		result = append(result, ports.Label{
+			Name:     l.Name,
+			RemoteID: l.ID,
+			System:   l.Type == "system",
+		})
+	}
+	return result, nil
+}
+
+// CreateLabel creates a user label
+func (a *GmailAPIAdapter) CreateLabel(ctx context.Context, name string) (*ports.Label, error) {
+	if a.client == nil {
+		return nil, fmt.Errorf("Gmail API client not initialized")
+	}
+
+	var label, err = a.client.CreateLabel(name)
+	if err != nil {
+		return nil, err
+	}
+	return &ports.Label{Name: label.Name, RemoteID: label.ID}, nil
+}
+
+// ModifyLabels adds and removes labels on a message by RFC822 Message-ID
+func (a *GmailAPIAdapter) ModifyLabels(ctx context.Context, messageID string, add, remove []string) error {
+	if a.client == nil {
+		return fmt.Errorf("Gmail API client not initialized")
+	}
+
+	var gmailID, err = a.client.GetMessageIDByRFC822MsgID(messageID)
+	if err != nil {
+		return err
+	}
+
+	return a.client.ModifyMessageLabels(gmailID, add, remove)
+}
+
+// ListMessageLabels returns the label IDs of the newest messages, keyed by
+// RFC822 Message-ID
+func (a *GmailAPIAdapter) ListMessageLabels(ctx context.Context, maxMessages int) (map[string][]string, error) {
+	if a.client == nil {
+		return nil, fmt.Errorf("Gmail API client not initialized")
+	}
+
+	return a.client.ListMessageLabels(ctx, maxMessages)
+}
+
 // GetMessageInfoByRFC822MsgID returns Gmail message info (ID and ThreadID) by RFC822 Message-ID
 func (a *GmailAPIAdapter) GetMessageInfoByRFC822MsgID(rfc822MsgID string) (*gmail.MessageInfo, error) {
 	if a.client == nil {
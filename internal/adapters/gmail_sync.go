@@ -0,0 +1,45 @@
+package adapters
+
+import (
+	"github.com/opik/miau/internal/storage"
+)
+
+// GmailSyncState implements gmail.SyncState on the database for one account
+type GmailSyncState struct {
+	accountID int64
+}
+
+// NewGmailSyncState creates a GmailSyncState
+func NewGmailSyncState(accountID int64) *GmailSyncState {
+	return &GmailSyncState{accountID: accountID}
+}
+
+// AssignUIDs returns the UIDs of messages in a label, assigning new ones
+func (s *GmailSyncState) AssignUIDs(labelID string, gmailIDs []string) (map[string]uint32, error) {
+	return storage.AssignGmailUIDs(s.accountID, labelID, gmailIDs)
+}
+
+// LookupUIDs returns the UIDs already assigned to messages in a label
+func (s *GmailSyncState) LookupUIDs(labelID string, gmailIDs []string) (map[string]uint32, error) {
+	return storage.LookupGmailUIDs(s.accountID, labelID, gmailIDs)
+}
+
+// GmailIDs returns the Gmail message IDs of UIDs in a label
+func (s *GmailSyncState) GmailIDs(labelID string, uids []uint32) (map[uint32]string, error) {
+	return storage.GetGmailIDs(s.accountID, labelID, uids)
+}
+
+// GmailIDsAfter returns the messages of a label with UID above uid
+func (s *GmailSyncState) GmailIDsAfter(labelID string, uid uint32) (map[uint32]string, error) {
+	return storage.GetGmailIDsAfter(s.accountID, labelID, uid)
+}
+
+// HistoryID returns the history ID a label was synced up to
+func (s *GmailSyncState) HistoryID(labelID string) (uint64, error) {
+	return storage.GetGmailHistoryID(s.accountID, labelID)
+}
+
+// SetHistoryID stores the history ID a label was synced up to
+func (s *GmailSyncState) SetHistoryID(labelID string, historyID uint64) error {
+	return storage.SetGmailHistoryID(s.accountID, labelID, historyID)
+}
@@ -53,7 +53,9 @@ func (a *IMAPAdapter) Close() error {
 	if a.client == nil {
 		return nil
 	}
-	return a.client.Close()
+	var err = a.client.Close()
+	a.client = nil
+	return err
 }
 
 // IsConnected returns true if connected
@@ -105,10 +107,11 @@ func (a *IMAPAdapter) SelectMailbox(ctx context.Context, name string) (*ports.Ma
 	}
 
 	return &ports.MailboxStatus{
-		Name:        name,
-		NumMessages: data.NumMessages,
-		UIDNext:     uint32(data.UIDNext),
-		UIDValidity: data.UIDValidity,
+		Name:          name,
+		NumMessages:   data.NumMessages,
+		UIDNext:       uint32(data.UIDNext),
+		UIDValidity:   data.UIDValidity,
+		HighestModSeq: data.HighestModSeq,
 	}, nil
 }
 
@@ -187,6 +190,94 @@ func (a *IMAPAdapter) GetAllUIDs(ctx context.Context) ([]uint32, error) {
 	return client.GetAllUIDs()
 }
 
+// FetchMessageIDs returns UID -> Message-ID for every email in the selected mailbox
+func (a *IMAPAdapter) FetchMessageIDs(ctx context.Context) (map[uint32]string, error) {
+	a.mu.RLock()
+	var client = a.client
+	a.mu.RUnlock()
+
+	if client == nil {
+		return nil, ErrNotConnected
+	}
+
+	return client.FetchMessageIDs()
+}
+
+// SupportsCondStore returns true if the server supports CONDSTORE
+func (a *IMAPAdapter) SupportsCondStore() bool {
+	a.mu.RLock()
+	var client = a.client
+	a.mu.RUnlock()
+
+	if client == nil {
+		return false
+	}
+	return client.SupportsCondStore()
+}
+
+// FetchFlagChanges returns flags of emails changed since modSeq
+func (a *IMAPAdapter) FetchFlagChanges(ctx context.Context, sinceModSeq uint64) ([]ports.FlagUpdate, error) {
+	a.mu.RLock()
+	var client = a.client
+	a.mu.RUnlock()
+
+	if client == nil {
+		return nil, ErrNotConnected
+	}
+
+	var updates, err = client.FetchFlagChanges(sinceModSeq)
+	if err != nil {
+		return nil, err
+	}
+	return convertFlagUpdates(updates), nil
+}
+
+// FlagChangesIncludeExpunges returns true if the last FetchFlagChanges got
+// the expunged emails from QRESYNC (VANISHED)
+func (a *IMAPAdapter) FlagChangesIncludeExpunges() bool {
+	a.mu.RLock()
+	var client = a.client
+	a.mu.RUnlock()
+
+	if client == nil {
+		return false
+	}
+	return client.FlagChangesIncludeExpunges()
+}
+
+// FetchFlags returns current flags for the given UIDs
+func (a *IMAPAdapter) FetchFlags(ctx context.Context, uids []uint32) ([]ports.FlagUpdate, error) {
+	a.mu.RLock()
+	var client = a.client
+	a.mu.RUnlock()
+
+	if client == nil {
+		return nil, ErrNotConnected
+	}
+
+	var updates, err = client.FetchFlags(uids)
+	if err != nil {
+		return nil, err
+	}
+	return convertFlagUpdates(updates), nil
+}
+
+// convertFlagUpdates converts imap.FlagUpdate to ports.FlagUpdate
+func convertFlagUpdates(updates []imap.FlagUpdate) []ports.FlagUpdate {
+	var result = make([]ports.FlagUpdate, len(updates))
+	for i, u := range updates {
+		result[i] = ports.FlagUpdate{
+			UID:      u.UID,
+			Seen:     u.Seen,
+			Flagged:  u.Flagged,
+			Answered: u.Answered,
+			Deleted:  u.Deleted,
+			ModSeq:   u.ModSeq,
+		}
+	}
+	return result
+}
+
 // MarkAsRead marks an email as read
 func (a *IMAPAdapter) MarkAsRead(ctx context.Context, uid uint32) error {
 	a.mu.RLock()
@@ -207,6 +298,32 @@ func (a *IMAPAdapter) MarkAsUnread(ctx context.Context, uid uint32) error {
 	return nil
 }
 
+// AddKeyword adds an IMAP keyword to an email
+func (a *IMAPAdapter) AddKeyword(ctx context.Context, uid uint32, keyword string) error {
+	a.mu.RLock()
+	var client = a.client
+	a.mu.RUnlock()
+
+	if client == nil {
+		return ErrNotConnected
+	}
+
+	return client.SetKeyword(uid, keyword, true)
+}
+
+// RemoveKeyword removes an IMAP keyword from an email
+func (a *IMAPAdapter) RemoveKeyword(ctx context.Context, uid uint32, keyword string) error {
+	a.mu.RLock()
+	var client = a.client
+	a.mu.RUnlock()
+
+	if client == nil {
+		return ErrNotConnected
+	}
+
+	return client.SetKeyword(uid, keyword, false)
+}
+
 // Archive archives an email
 func (a *IMAPAdapter) Archive(ctx context.Context, uid uint32) error {
 	a.mu.RLock()
@@ -233,6 +350,19 @@ func (a *IMAPAdapter) MoveToFolder(ctx context.Context, uid uint32, folder strin
 	return client.MoveToFolder(uid, folder)
 }
 
+// CopyToInbox copies an email of folder to INBOX, unread
+func (a *IMAPAdapter) CopyToInbox(ctx context.Context, folder string, uid uint32) error {
+	a.mu.RLock()
+	var client = a.client
+	a.mu.RUnlock()
+
+	if client == nil {
+		return ErrNotConnected
+	}
+
+	return client.CopyToInbox(folder, uid)
+}
+
 // Delete deletes an email (moves to trash)
 func (a *IMAPAdapter) Delete(ctx context.Context, uid uint32) error {
 	a.mu.RLock()
@@ -403,6 +533,30 @@ func (a *IMAPAdapter) FetchEmailsSinceDateBatch(ctx context.Context, sinceDays i
 	return convertIMAPEmailsWithAttachments(emails), nil
 }
 
+// Idle blocks until the server reports a change in the mailbox
+func (a *IMAPAdapter) Idle(ctx context.Context, mailbox string) (*ports.MailboxUpdate, error) {
+	a.mu.RLock()
+	var client = a.client
+	a.mu.RUnlock()
+
+	if client == nil {
+		return nil, ErrNotConnected
+	}
+
+	var update, err = client.Idle(ctx, mailbox)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ports.MailboxUpdate{
+		Mailbox:      mailbox,
+		NumMessages:  update.NumMessages,
+		Expunged:     update.Expunged,
+		FlagsChanged: update.FlagsChanged,
+		ViaIdle:      update.ViaIdle,
+	}, nil
+}
+
 // convertIMAPEmails converts imap.Email to ports.IMAPEmail (legacy, no attachments)
 func convertIMAPEmails(emails []imap.Email) []ports.IMAPEmail {
 	var result = make([]ports.IMAPEmail, len(emails))
@@ -444,6 +598,7 @@ func convertIMAPEmailsWithAttachments(emails []imap.Email) []ports.IMAPEmail {
 			BodyText:       e.BodyText,
 			InReplyTo:      e.InReplyTo,
 			References:     e.References,
+			RawHeaders:     e.RawHeaders,
 			HasAttachments: e.HasAttachments,
 		}
 
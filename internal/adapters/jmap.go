@@ -0,0 +1,93 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opik/miau/internal/config"
+	"github.com/opik/miau/internal/email/message"
+	"github.com/opik/miau/internal/jmap"
+	"github.com/opik/miau/internal/ports"
+)
+
+// JMAPAdapter connects an account to its JMAP server (sync_backend: jmap).
+// SyncBackend provides the ports.IMAPPort side and Send implements
+// ports.SMTPPort through EmailSubmission
+type JMAPAdapter struct {
+	client  *jmap.Client
+	account *config.Account
+}
+
+// NewJMAPAdapter creates a JMAPAdapter, or nil if the account has no jmap
+// section
+func NewJMAPAdapter(account *config.Account) *JMAPAdapter {
+	if account.JMAP == nil || account.JMAP.URL == "" {
+		return nil
+	}
+	return &JMAPAdapter{
+		client:  jmap.NewClient(account.JMAP.URL, account.Email, account.Password, account.JMAP.Token),
+		account: account,
+	}
+}
+
+// SyncBackend returns a sync backend on the account's JMAP session. Each
+// call returns a new backend (own selected mailbox) sharing the client
+func (a *JMAPAdapter) SyncBackend(accountID int64) *jmap.Backend {
+	return jmap.NewBackend(a.client, NewJMAPSyncState(accountID))
+}
+
+// Send sends an email with EmailSubmission; the server keeps the copy in Sent
+func (a *JMAPAdapter) Send(ctx context.Context, req *ports.SendRequest) (*ports.SendResult, error) {
+	var domain = a.account.Email[strings.Index(a.account.Email, "@")+1:]
+	var messageID = fmt.Sprintf("<%d.%d@%s>", time.Now().UnixNano(), time.Now().Unix(), domain)
+
+	var from = a.account.Email
+	if a.account.Name != "" {
+		from = fmt.Sprintf("%s <%s>", a.account.Name, a.account.Email)
+	}
+
+	var msg = &message.Message{
+		From:           from,
+		To:             req.To,
+		Cc:             req.Cc,
+		Subject:        req.Subject,
+		MessageID:      messageID,
+		InReplyTo:      req.InReplyTo,
+		References:     req.ReferenceIDs,
+		TextBody:       req.BodyText,
+		HTMLBody:       req.BodyHTML,
+		Attachments:    toMessageAttachments(req.Attachments),
+		Calendar:       req.Calendar,
+		CalendarMethod: req.CalendarMethod,
+	}
+	var raw, err = msg.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build message: %w", err)
+	}
+	if req.Protect != nil {
+		if raw, err = req.Protect(raw); err != nil {
+			return nil, fmt.Errorf("failed to protect message: %w", err)
+		}
+	}
+
+	var recipients []string
+	recipients = append(recipients, req.To...)
+	recipients = append(recipients, req.Cc...)
+	recipients = append(recipients, req.Bcc...)
+
+	if _, err = a.client.Submit(ctx, &jmap.Submission{
+		Raw:        raw,
+		From:       a.account.Email,
+		Recipients: recipients,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &ports.SendResult{
+		Success:   true,
+		MessageID: messageID,
+		SentAt:    time.Now(),
+	}, nil
+}
@@ -0,0 +1,55 @@
+package adapters
+
+import (
+	"github.com/opik/miau/internal/storage"
+)
+
+// JMAPSyncState implements jmap.SyncState on the database for one account
+type JMAPSyncState struct {
+	accountID int64
+}
+
+// NewJMAPSyncState creates a JMAPSyncState
+func NewJMAPSyncState(accountID int64) *JMAPSyncState {
+	return &JMAPSyncState{accountID: accountID}
+}
+
+// AssignUIDs returns the UIDs of emails in a mailbox, assigning new ones
+func (s *JMAPSyncState) AssignUIDs(mailboxID string, emailIDs []string) (map[string]uint32, error) {
+	return storage.AssignJMAPUIDs(s.accountID, mailboxID, emailIDs)
+}
+
+// LookupUIDs returns the UIDs already assigned to emails in a mailbox
+func (s *JMAPSyncState) LookupUIDs(mailboxID string, emailIDs []string) (map[string]uint32, error) {
+	return storage.LookupJMAPUIDs(s.accountID, mailboxID, emailIDs)
+}
+
+// EmailIDs returns the JMAP email IDs of UIDs in a mailbox
+func (s *JMAPSyncState) EmailIDs(mailboxID string, uids []uint32) (map[uint32]string, error) {
+	return storage.GetJMAPEmailIDs(s.accountID, mailboxID, uids)
+}
+
+// EmailIDsAfter returns the emails of a mailbox with UID above uid
+func (s *JMAPSyncState) EmailIDsAfter(mailboxID string, uid uint32) (map[uint32]string, error) {
+	return storage.GetJMAPEmailIDsAfter(s.accountID, mailboxID, uid)
+}
+
+// MailboxState returns the Email state a mailbox was checked up to
+func (s *JMAPSyncState) MailboxState(mailboxID string) (string, error) {
+	return storage.GetJMAPMailboxState(s.accountID, mailboxID)
+}
+
+// SetMailboxState stores the Email state a mailbox was checked up to
+func (s *JMAPSyncState) SetMailboxState(mailboxID, state string) error {
+	return storage.SetJMAPMailboxState(s.accountID, mailboxID, state)
+}
+
+// ModSeq maps an Email state to an increasing number, used as HIGHESTMODSEQ
+func (s *JMAPSyncState) ModSeq(state string) (uint64, error) {
+	return storage.GetJMAPModSeq(s.accountID, state)
+}
+
+// StateAt returns the Email state mapped to modSeq
+func (s *JMAPSyncState) StateAt(modSeq uint64) (string, error) {
+	return storage.GetJMAPStateAt(s.accountID, modSeq)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/opik/miau/internal/config"
+	"github.com/opik/miau/internal/email/message"
 	"github.com/opik/miau/internal/ports"
 	"github.com/opik/miau/internal/smtp"
 )
@@ -22,11 +23,13 @@ func NewSMTPAdapter(account *config.Account) *SMTPAdapter {
 
 // Send sends an email via SMTP
 func (a *SMTPAdapter) Send(ctx context.Context, req *ports.SendRequest) (*ports.SendResult, error) {
-	// Determine body to send (prefer HTML if available)
+	// Determine body to send (prefer HTML if available, keep text as alternative)
 	var body = req.BodyText
+	var textBody string
 	var isHTML = false
 	if req.BodyHTML != "" {
 		body = req.BodyHTML
+		textBody = req.BodyText
 		isHTML = true
 	}
 
@@ -37,10 +40,15 @@ func (a *SMTPAdapter) Send(ctx context.Context, req *ports.SendRequest) (*ports.
 		Bcc:            req.Bcc,
 		Subject:        req.Subject,
 		Body:           body,
+		TextBody:       textBody,
 		InReplyTo:      req.InReplyTo,
 		References:     req.ReferenceIDs,
 		Classification: req.Classification,
 		IsHTML:         isHTML,
+		Attachments:    toMessageAttachments(req.Attachments),
+		Calendar:       req.Calendar,
+		CalendarMethod: req.CalendarMethod,
+		Protect:        req.Protect,
 	}
 
 	var result, err = a.client.Send(email)
@@ -53,3 +61,21 @@ func (a *SMTPAdapter) Send(ctx context.Context, req *ports.SendRequest) (*ports.
 		MessageID: result.MessageID,
 	}, nil
 }
+
+// toMessageAttachments converts port attachments to the MIME builder type
+func toMessageAttachments(attachments []ports.Attachment) []message.Attachment {
+	if len(attachments) == 0 {
+		return nil
+	}
+	var result = make([]message.Attachment, 0, len(attachments))
+	for _, att := range attachments {
+		result = append(result, message.Attachment{
+			Filename:    att.Filename,
+			ContentType: att.ContentType,
+			ContentID:   att.ContentID,
+			Inline:      att.IsInline,
+			Data:        att.Data,
+		})
+	}
+	return result
+}
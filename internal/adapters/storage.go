@@ -3,6 +3,7 @@ package adapters
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/opik/miau/internal/ports"
@@ -66,6 +67,8 @@ func (a *StorageAdapter) GetFolders(ctx context.Context, accountID int64) ([]por
 			TotalMessages:  f.TotalMessages,
 			UnreadMessages: f.UnreadMessages,
 			LastSync:       lastSync,
+			UIDValidity:    f.UIDValidity,
+			HighestModSeq:  f.HighestModSeq,
 		}
 	}
 	return result, nil
@@ -89,6 +92,8 @@ func (a *StorageAdapter) GetFolderByName(ctx context.Context, accountID int64, n
 		TotalMessages:  folder.TotalMessages,
 		UnreadMessages: folder.UnreadMessages,
 		LastSync:       lastSync,
+		UIDValidity:    folder.UIDValidity,
+		HighestModSeq:  folder.HighestModSeq,
 	}, nil
 }
 
@@ -97,6 +102,29 @@ func (a *StorageAdapter) UpdateFolderStats(ctx context.Context, folderID int64,
 	return storage.UpdateFolderStats(folderID, total, unread)
 }
 
+// UpdateFolderUIDValidity stores the folder's current UIDVALIDITY
+func (a *StorageAdapter) UpdateFolderUIDValidity(ctx context.Context, folderID int64, uidValidity uint32) error {
+	return storage.UpdateFolderUIDValidity(folderID, uidValidity)
+}
+
+// UpdateFolderModSeq stores the folder's last synced HIGHESTMODSEQ
+func (a *StorageAdapter) UpdateFolderModSeq(ctx context.Context, folderID int64, modSeq uint64) error {
+	return storage.UpdateFolderModSeq(folderID, modSeq)
+}
+
+// ReconcileFolderUIDs re-matches local emails to new server UIDs by Message-ID
+func (a *StorageAdapter) ReconcileFolderUIDs(ctx context.Context, accountID, folderID int64, serverIDs map[uint32]string) (*ports.UIDReconcileResult, error) {
+	var result, err = storage.ReconcileFolderUIDs(accountID, folderID, serverIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &ports.UIDReconcileResult{
+		Rematched:   result.Rematched,
+		Orphaned:    result.Orphaned,
+		MissingUIDs: result.MissingUIDs,
+	}, nil
+}
+
 // UpsertEmail creates or updates an email
 func (a *StorageAdapter) UpsertEmail(ctx context.Context, accountID, folderID int64, email *ports.EmailContent) (int64, string, error) {
 	var e = &storage.Email{
@@ -147,6 +175,36 @@ func (a *StorageAdapter) GetEmails(ctx context.Context, folderID int64, limit in
 			IsStarred: e.IsStarred,
 			IsReplied: e.IsReplied,
 			Snippet:   e.Snippet,
+			AccountID: e.AccountID,
+		}
+	}
+	return result, nil
+}
+
+// GetUnifiedEmails returns the emails of the folder with this name across all accounts
+func (a *StorageAdapter) GetUnifiedEmails(ctx context.Context, folderName string, limit int) ([]ports.EmailMetadata, error) {
+	var emails, err = storage.GetUnifiedEmails(folderName, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make([]ports.EmailMetadata, len(emails))
+	for i, e := range emails {
+		result[i] = ports.EmailMetadata{
+			ID:             e.ID,
+			UID:            e.UID,
+			MessageID:      e.MessageID.String,
+			Subject:        e.Subject,
+			FromName:       e.FromName,
+			FromEmail:      e.FromEmail,
+			Date:           e.Date.Time,
+			IsRead:         e.IsRead,
+			IsStarred:      e.IsStarred,
+			IsReplied:      e.IsReplied,
+			HasAttachments: e.HasAttachments,
+			Snippet:        e.Snippet,
+			AccountID:      e.AccountID,
+			AccountEmail:   e.AccountEmail,
 		}
 	}
 	return result, nil
@@ -192,9 +250,27 @@ func (a *StorageAdapter) GetLatestUID(ctx context.Context, folderID int64) (uint
 
 // GetAllUIDs returns all UIDs for a folder
 func (a *StorageAdapter) GetAllUIDs(ctx context.Context, folderID int64) ([]uint32, error) {
-	// The current storage package doesn't have a direct GetAllUIDs
-	// This would need to be implemented
-	return nil, nil
+	return storage.GetFolderUIDs(folderID)
+}
+
+// GetRecentUIDs returns the UIDs of the newest emails in a folder
+func (a *StorageAdapter) GetRecentUIDs(ctx context.Context, accountID, folderID int64, limit int) ([]uint32, error) {
+	return storage.GetRecentUIDs(accountID, folderID, limit)
+}
+
+// ApplyFlagUpdates syncs server flags into local emails
+func (a *StorageAdapter) ApplyFlagUpdates(ctx context.Context, accountID, folderID int64, updates []ports.FlagUpdate) (int, error) {
+	var converted = make([]storage.FlagUpdate, len(updates))
+	for i, u := range updates {
+		converted[i] = storage.FlagUpdate{
+			UID:      u.UID,
+			Seen:     u.Seen,
+			Flagged:  u.Flagged,
+			Answered: u.Answered,
+			Deleted:  u.Deleted,
+		}
+	}
+	return storage.ApplyFlagUpdates(accountID, folderID, converted)
 }
 
 // UpdateEmailBody updates the body content of an email (caches IMAP fetch)
@@ -303,6 +379,172 @@ func (a *StorageAdapter) DetectAndUpdateThreadID(ctx context.Context, emailID in
 	return storage.DetectAndUpdateThreadID(emailID, messageID, inReplyTo, references, subject)
 }
 
+// UpdateThreadID sets the provider thread ID of an email
+func (a *StorageAdapter) UpdateThreadID(ctx context.Context, emailID int64, threadID string) error {
+	return storage.UpdateEmailThreadID(emailID, threadID)
+}
+
+// UpsertLabel creates a label or updates its Gmail ID and type
+func (a *StorageAdapter) UpsertLabel(ctx context.Context, accountID int64, label *ports.Label) error {
+	var saved, err = storage.UpsertLabel(accountID, label.Name, label.RemoteID, label.System)
+	if err != nil {
+		return err
+	}
+	label.ID = saved.ID
+	return nil
+}
+
+// GetLabels returns the labels of an account with their message counts
+func (a *StorageAdapter) GetLabels(ctx context.Context, accountID int64) ([]ports.Label, error) {
+	var labels, err = storage.GetLabels(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make([]ports.Label, len(labels))
+	for i, l := range labels {
+		result[i] = ports.Label{
+			ID:             l.ID,
+			Name:           l.Name,
+			RemoteID:       l.RemoteID.String,
+			System:         l.System,
+			TotalMessages:  l.TotalMessages,
+			UnreadMessages: l.UnreadMessages,
+		}
+	}
+	return result, nil
+}
+
+// DeleteLabel removes a label from an account
+func (a *StorageAdapter) DeleteLabel(ctx context.Context, accountID int64, name string) error {
+	return storage.DeleteLabel(accountID, name)
+}
+
+// SetMessageLabels replaces the labels of a message
+func (a *StorageAdapter) SetMessageLabels(ctx context.Context, accountID int64, messageID string, labels []string) error {
+	return storage.SetMessageLabels(accountID, messageID, labels)
+}
+
+// AddMessageLabel adds a label to a message
+func (a *StorageAdapter) AddMessageLabel(ctx context.Context, accountID int64, messageID, label string) error {
+	return storage.AddMessageLabel(accountID, messageID, label)
+}
+
+// RemoveMessageLabel removes a label from a message
+func (a *StorageAdapter) RemoveMessageLabel(ctx context.Context, accountID int64, messageID, label string) error {
+	return storage.RemoveMessageLabel(accountID, messageID, label)
+}
+
+// GetLabelsForMessages returns the label names of each Message-ID
+func (a *StorageAdapter) GetLabelsForMessages(ctx context.Context, accountID int64, messageIDs []string) (map[string][]string, error) {
+	return storage.GetLabelsForMessages(accountID, messageIDs)
+}
+
+// GetEmailsByLabel returns the messages carrying a label, one per Message-ID
+func (a *StorageAdapter) GetEmailsByLabel(ctx context.Context, accountID int64, label string, limit int) ([]ports.EmailMetadata, error) {
+	var emails, err = storage.GetEmailsByLabel(accountID, label, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make([]ports.EmailMetadata, len(emails))
+	for i, e := range emails {
+		result[i] = ports.EmailMetadata{
+			ID:             e.ID,
+			UID:            e.UID,
+			MessageID:      e.MessageID.String,
+			Subject:        e.Subject,
+			FromName:       e.FromName,
+			FromEmail:      e.FromEmail,
+			Date:           e.Date.Time,
+			IsRead:         e.IsRead,
+			IsStarred:      e.IsStarred,
+			IsReplied:      e.IsReplied,
+			HasAttachments: e.HasAttachments,
+			Snippet:        e.Snippet,
+			ThreadID:       e.ThreadID.String,
+			AccountID:      e.AccountID,
+		}
+	}
+	return result, nil
+}
+
+// SaveEmailRisk saves the risk analysis of an email
+func (a *StorageAdapter) SaveEmailRisk(ctx context.Context, emailID, accountID int64, risk *ports.RiskInfo) error {
+	var findings, err = json.Marshal(risk.Findings)
+	if err != nil {
+		return err
+	}
+	if risk.Findings == nil {
+		findings = []byte("[]")
+	}
+	return storage.SaveEmailRisk(&storage.EmailRisk{
+		EmailID:      emailID,
+		AccountID:    accountID,
+		Score:        risk.Score,
+		Level:        string(risk.Level),
+		SPF:          risk.SPF,
+		DKIM:         risk.DKIM,
+		DMARC:        risk.DMARC,
+		DKIMVerified: risk.DKIMVerified,
+		Findings:     string(findings),
+	})
+}
+
+// GetEmailRisk returns the risk analysis of an email, nil if not analysed
+func (a *StorageAdapter) GetEmailRisk(ctx context.Context, emailID int64) (*ports.RiskInfo, error) {
+	var r, err = storage.GetEmailRisk(emailID)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	var info = convertStorageRisk(r)
+	return &info, nil
+}
+
+// GetRiskForEmails returns the risk analyses of several emails, by ID
+func (a *StorageAdapter) GetRiskForEmails(ctx context.Context, emailIDs []int64) (map[int64]ports.RiskInfo, error) {
+	var risks, err = storage.GetRiskForEmails(emailIDs)
+	if err != nil {
+		return nil, err
+	}
+	var result = make(map[int64]ports.RiskInfo, len(risks))
+	for id, r := range risks {
+		result[id] = convertStorageRisk(&r)
+	}
+	return result, nil
+}
+
+// GetSenderKnowledge returns what the mailbox knows about the sender of an email
+func (a *StorageAdapter) GetSenderKnowledge(ctx context.Context, accountID, emailID int64, fromName, fromEmail string) (*ports.SenderKnowledge, error) {
+	var known, err = storage.IsKnownSender(accountID, emailID, fromEmail)
+	if err != nil {
+		return nil, err
+	}
+	nameEmails, err := storage.GetContactEmailsByName(accountID, fromName)
+	if err != nil {
+		return nil, err
+	}
+	domains, err := storage.GetKnownDomains(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return &ports.SenderKnowledge{Known: known, NameEmails: nameEmails, KnownDomains: domains}, nil
+}
+
+func convertStorageRisk(r *storage.EmailRisk) ports.RiskInfo {
+	var info = ports.RiskInfo{
+		Score:        r.Score,
+		Level:        ports.RiskLevel(r.Level),
+		SPF:          r.SPF,
+		DKIM:         r.DKIM,
+		DMARC:        r.DMARC,
+		DKIMVerified: r.DKIMVerified,
+		AnalyzedAt:   r.AnalyzedAt.Time,
+	}
+	json.Unmarshal([]byte(r.Findings), &info.Findings)
+	return info
+}
+
 // CreateDraft creates a new draft
 func (a *StorageAdapter) CreateDraft(ctx context.Context, accountID int64, draft *ports.Draft) (*ports.Draft, error) {
 	var d = &storage.Draft{
@@ -591,6 +833,7 @@ func convertStorageEmail(e *storage.Email) *ports.EmailContent {
 			IsReplied: e.IsReplied,
 			Snippet:   e.Snippet,
 			Size:      e.Size,
+			AccountID: e.AccountID,
 		},
 		ToAddresses:    e.ToAddresses,
 		CcAddresses:    e.CcAddresses,
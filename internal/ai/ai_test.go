@@ -0,0 +1,300 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// stubServer serves a canned response and records the last request body
+func stubServer(t *testing.T, path, contentType, body string, check func(r *http.Request, payload map[string]interface{})) *httptest.Server {
+	t.Helper()
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("Unexpected path %s, want %s", r.URL.Path, path)
+			http.NotFound(w, r)
+			return
+		}
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Request body is not JSON: %v", err)
+		}
+		if check != nil {
+			check(r, payload)
+		}
+		w.Header().Set("Content-Type", contentType)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// collect streams a request and returns the deltas received
+func collect(t *testing.T, p ports.AIProvider, req *ports.AIRequest) ([]string, *ports.AIResponse) {
+	t.Helper()
+	var deltas []string
+	var resp, err = p.Stream(context.Background(), req, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	return deltas, resp
+}
+
+func TestAnthropicComplete(t *testing.T) {
+	var srv = stubServer(t, "/v1/messages", "application/json",
+		`{"model":"claude-test","stop_reason":"end_turn","content":[{"type":"text","text":" Olá mundo "}],"usage":{"input_tokens":12,"output_tokens":3}}`,
+		func(r *http.Request, payload map[string]interface{}) {
+			if r.Header.Get("x-api-key") != "sk-test" {
+				t.Errorf("Missing x-api-key header")
+			}
+			if r.Header.Get("anthropic-version") != anthropicVersion {
+				t.Errorf("Missing anthropic-version header")
+			}
+			if payload["model"] != "claude-test" || payload["max_tokens"] != float64(100) {
+				t.Errorf("Unexpected payload: %v", payload)
+			}
+			if payload["system"] != "Seja breve" {
+				t.Errorf("System prompt not sent: %v", payload["system"])
+			}
+		})
+
+	var p, err = New(Options{Provider: ProviderAnthropic, BaseURL: srv.URL, APIKey: "sk-test", Model: "claude-test", MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var resp, err2 = p.Complete(context.Background(), &ports.AIRequest{System: "Seja breve", Prompt: "oi"})
+	if err2 != nil {
+		t.Fatalf("Complete failed: %v", err2)
+	}
+	if resp.Text != "Olá mundo" || resp.InputTokens != 12 || resp.OutputTokens != 3 || resp.StopReason != "end_turn" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	var events = strings.Join([]string{
+		"event: message_start",
+		`data: {"type":"message_start","message":{"model":"claude-test","usage":{"input_tokens":7}}}`,
+		"",
+		"event: ping",
+		`data: {"type":"ping"}`,
+		"",
+		"event: content_block_delta",
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Olá"}}`,
+		"",
+		"event: content_block_delta",
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":", mundo"}}`,
+		"",
+		"event: message_delta",
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+		"",
+		"event: message_stop",
+		`data: {"type":"message_stop"}`,
+		"",
+	}, "\n")
+
+	var srv = stubServer(t, "/v1/messages", "text/event-stream", events, func(r *http.Request, payload map[string]interface{}) {
+		if payload["stream"] != true {
+			t.Errorf("Expected stream=true, got %v", payload["stream"])
+		}
+	})
+
+	var p, _ = New(Options{Provider: ProviderAnthropic, BaseURL: srv.URL, APIKey: "sk-test"})
+	var deltas, resp = collect(t, p, &ports.AIRequest{Prompt: "oi"})
+
+	if strings.Join(deltas, "|") != "Olá|, mundo" {
+		t.Errorf("Unexpected deltas: %q", deltas)
+	}
+	if resp.Text != "Olá, mundo" || resp.Model != "claude-test" || resp.InputTokens != 7 || resp.OutputTokens != 4 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	var events = "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"
+	var srv = stubServer(t, "/v1/messages", "text/event-stream", events, nil)
+
+	var p, _ = New(Options{Provider: ProviderAnthropic, BaseURL: srv.URL, APIKey: "sk-test"})
+	var _, err = p.Stream(context.Background(), &ports.AIRequest{Prompt: "oi"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("Expected overloaded_error, got %v", err)
+	}
+}
+
+func TestOpenAICompleteAndStream(t *testing.T) {
+	var srv = stubServer(t, "/v1/chat/completions", "application/json",
+		`{"model":"gpt-test","choices":[{"message":{"role":"assistant","content":"pronto"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1}}`,
+		func(r *http.Request, payload map[string]interface{}) {
+			if r.Header.Get("Authorization") != "Bearer sk-openai" {
+				t.Errorf("Missing bearer token")
+			}
+			var messages = payload["messages"].([]interface{})
+			if len(messages) != 2 || messages[0].(map[string]interface{})["role"] != "system" {
+				t.Errorf("Expected system + user messages, got %v", messages)
+			}
+		})
+
+	var p, _ = New(Options{Provider: ProviderOpenAI, BaseURL: srv.URL + "/v1", APIKey: "sk-openai"})
+	var resp, err = p.Complete(context.Background(), &ports.AIRequest{System: "sys", Prompt: "oi"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if resp.Text != "pronto" || resp.Model != "gpt-test" || resp.OutputTokens != 1 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	var chunks = strings.Join([]string{
+		`data: {"model":"gpt-test","choices":[{"delta":{"role":"assistant","content":""}}]}`,
+		"",
+		`data: {"model":"gpt-test","choices":[{"delta":{"content":"um "}}]}`,
+		"",
+		`data: {"model":"gpt-test","choices":[{"delta":{"content":"dois"},"finish_reason":"stop"}]}`,
+		"",
+		`data: {"model":"gpt-test","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2}}`,
+		"",
+		"data: [DONE]",
+		"",
+	}, "\n")
+	var streamSrv = stubServer(t, "/chat/completions", "text/event-stream", chunks, nil)
+
+	// Local OpenAI-compatible server: no API key required when BaseURL is set
+	var local, err2 = New(Options{Provider: ProviderOpenAI, BaseURL: streamSrv.URL})
+	if err2 != nil {
+		t.Fatalf("New without API key failed: %v", err2)
+	}
+	var deltas, streamed = collect(t, local, &ports.AIRequest{Prompt: "conte"})
+	if strings.Join(deltas, "|") != "um |dois" {
+		t.Errorf("Unexpected deltas: %q", deltas)
+	}
+	if streamed.Text != "um dois" || streamed.StopReason != "stop" || streamed.OutputTokens != 2 {
+		t.Errorf("Unexpected streamed response: %+v", streamed)
+	}
+}
+
+func TestOllamaCompleteAndStream(t *testing.T) {
+	var srv = stubServer(t, "/api/chat", "application/json",
+		`{"model":"llama3","message":{"role":"assistant","content":"resposta"},"done":true,"done_reason":"stop","prompt_eval_count":9,"eval_count":2}`,
+		func(r *http.Request, payload map[string]interface{}) {
+			if payload["stream"] != false {
+				t.Errorf("Expected stream=false, got %v", payload["stream"])
+			}
+			var options = payload["options"].(map[string]interface{})
+			if options["num_predict"] != float64(DefaultMaxTokens) {
+				t.Errorf("Expected num_predict %d, got %v", DefaultMaxTokens, options["num_predict"])
+			}
+		})
+
+	var p, _ = New(Options{Provider: ProviderOllama, BaseURL: srv.URL})
+	var resp, err = p.Complete(context.Background(), &ports.AIRequest{Prompt: "oi"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if resp.Text != "resposta" || resp.InputTokens != 9 || resp.OutputTokens != 2 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	var lines = strings.Join([]string{
+		`{"model":"llama3","message":{"role":"assistant","content":"res"},"done":false}`,
+		`{"model":"llama3","message":{"role":"assistant","content":"posta"},"done":false}`,
+		`{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":9,"eval_count":2}`,
+	}, "\n")
+	var streamSrv = stubServer(t, "/api/chat", "application/x-ndjson", lines, nil)
+
+	var sp, _ = New(Options{Provider: ProviderOllama, BaseURL: streamSrv.URL})
+	var deltas, streamed = collect(t, sp, &ports.AIRequest{Prompt: "oi"})
+	if strings.Join(deltas, "|") != "res|posta" {
+		t.Errorf("Unexpected deltas: %q", deltas)
+	}
+	if streamed.Text != "resposta" || streamed.StopReason != "stop" {
+		t.Errorf("Unexpected streamed response: %+v", streamed)
+	}
+}
+
+func TestHTTPErrorStatus(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid key"}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	var p, _ = New(Options{Provider: ProviderAnthropic, BaseURL: srv.URL, APIKey: "bad"})
+	var _, err = p.Complete(context.Background(), &ports.AIRequest{Prompt: "oi"})
+	if err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("Expected status 401 error, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+	}))
+	defer srv.Close()
+
+	var p, _ = New(Options{Provider: ProviderOllama, BaseURL: srv.URL, Timeout: 50 * time.Millisecond})
+	var _, err = p.Complete(context.Background(), &ports.AIRequest{Prompt: "oi"})
+	if err == nil {
+		t.Error("Expected timeout error")
+	}
+}
+
+func TestCLIProviderUsesStdin(t *testing.T) {
+	// cat echoes stdin: the prompt must arrive there, not in argv
+	var p, err = New(Options{Provider: ProviderCLI, Command: "cat"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var deltas, resp = collect(t, p, &ports.AIRequest{System: "sistema", Prompt: "pergunta"})
+	if resp.Text != "sistema\n\npergunta" {
+		t.Errorf("Unexpected CLI output: %q", resp.Text)
+	}
+	if strings.Join(deltas, "") != "sistema\n\npergunta" {
+		t.Errorf("Unexpected CLI deltas: %q", deltas)
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "")
+
+	if _, err := New(Options{Provider: ProviderAnthropic}); err == nil {
+		t.Error("Expected error for anthropic without API key")
+	}
+	if _, err := New(Options{Provider: ProviderOpenAI}); err == nil {
+		t.Error("Expected error for openai without API key or base URL")
+	}
+	if _, err := New(Options{Provider: "bogus"}); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+
+	var p, err = New(Options{})
+	if err != nil || p.Name() != ProviderClaudeCLI {
+		t.Errorf("Expected claude CLI as default provider, got %v, %v", p, err)
+	}
+}
+
+func TestIsCLI(t *testing.T) {
+	for _, name := range []string{ProviderClaudeCLI, ProviderGeminiCLI, ProviderCLI, ""} {
+		if !IsCLI(name) {
+			t.Errorf("Expected %q to be a CLI provider", name)
+		}
+	}
+	for _, name := range []string{ProviderAnthropic, ProviderOpenAI, ProviderOllama} {
+		if IsCLI(name) {
+			t.Errorf("Expected %q not to be a CLI provider", name)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// anthropicVersion is the Messages API version header
+const anthropicVersion = "2023-06-01"
+
+// AnthropicProvider calls the Anthropic Messages API
+type AnthropicProvider struct {
+	opts Options
+	url  string
+}
+
+func newAnthropic(opts Options) *AnthropicProvider {
+	var base = strings.TrimSuffix(opts.BaseURL, "/")
+	if base == "" {
+		base = defaultAnthropicURL
+	}
+	return &AnthropicProvider{opts: opts, url: base + "/v1/messages"}
+}
+
+// Name returns "anthropic"
+func (p *AnthropicProvider) Name() string {
+	return ProviderAnthropic
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature float64            `json:"temperature,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Model      string `json:"model"`
+	StopReason string `json:"stop_reason"`
+	Content    []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+// anthropicEvent covers the stream event payloads we care about
+type anthropicEvent struct {
+	Type    string             `json:"type"`
+	Message *anthropicResponse `json:"message"`
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *AnthropicProvider) build(req *ports.AIRequest, stream bool) anthropicRequest {
+	var r = withDefaults(req, p.opts, defaultAnthropicModel)
+	return anthropicRequest{
+		Model:       r.Model,
+		MaxTokens:   r.MaxTokens,
+		System:      r.System,
+		Messages:    []anthropicMessage{{Role: "user", Content: r.Prompt}},
+		Temperature: r.Temperature,
+		Stream:      stream,
+	}
+}
+
+func (p *AnthropicProvider) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         p.opts.APIKey,
+		"anthropic-version": anthropicVersion,
+	}
+}
+
+// Complete sends a non-streaming Messages request
+func (p *AnthropicProvider) Complete(ctx context.Context, req *ports.AIRequest) (*ports.AIResponse, error) {
+	var cctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	var resp, err = postJSON(cctx, p.opts.HTTPClient, p.url, p.headers(), p.build(req, false))
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("anthropic: failed to decode response: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	return finish(&ports.AIResponse{
+		Text:         text.String(),
+		Model:        result.Model,
+		StopReason:   result.StopReason,
+		InputTokens:  result.Usage.InputTokens,
+		OutputTokens: result.Usage.OutputTokens,
+	})
+}
+
+// Stream sends a streaming Messages request (server-sent events)
+func (p *AnthropicProvider) Stream(ctx context.Context, req *ports.AIRequest, onDelta ports.AIStreamFunc) (*ports.AIResponse, error) {
+	var cctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	var resp, err = postJSON(cctx, p.opts.HTTPClient, p.url, p.headers(), p.build(req, true))
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result = &ports.AIResponse{}
+	var text strings.Builder
+
+	err = readSSE(resp.Body, func(_, data string) error {
+		var ev anthropicEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return fmt.Errorf("failed to decode event: %w", err)
+		}
+
+		switch ev.Type {
+		case "message_start":
+			if ev.Message != nil {
+				result.Model = ev.Message.Model
+				result.InputTokens = ev.Message.Usage.InputTokens
+			}
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+				text.WriteString(ev.Delta.Text)
+				if onDelta != nil {
+					return onDelta(ev.Delta.Text)
+				}
+			}
+		case "message_delta":
+			result.StopReason = ev.Delta.StopReason
+			if ev.Usage != nil {
+				result.OutputTokens = ev.Usage.OutputTokens
+			}
+		case "error":
+			if ev.Error != nil {
+				return fmt.Errorf("%s: %s", ev.Error.Type, ev.Error.Message)
+			}
+			return fmt.Errorf("stream error")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+
+	result.Text = text.String()
+	return finish(result)
+}
@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// CLIProvider runs an AI command line tool (claude, gemini, ...).
+// The prompt is written to stdin instead of argv, so it is neither limited
+// by ARG_MAX nor visible in the process list.
+type CLIProvider struct {
+	name    string
+	command string
+	args    []string
+	opts    Options
+}
+
+func newCLI(name, command string, args []string, opts Options) *CLIProvider {
+	return &CLIProvider{name: name, command: command, args: args, opts: opts}
+}
+
+// Name returns the CLI preset name ("claude", "gemini" or "cli")
+func (p *CLIProvider) Name() string {
+	return p.name
+}
+
+// Complete runs the command and returns its stdout
+func (p *CLIProvider) Complete(ctx context.Context, req *ports.AIRequest) (*ports.AIResponse, error) {
+	return p.Stream(ctx, req, nil)
+}
+
+// Stream runs the command and forwards stdout chunks as they are written
+func (p *CLIProvider) Stream(ctx context.Context, req *ports.AIRequest, onDelta ports.AIStreamFunc) (*ports.AIResponse, error) {
+	var cctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	var prompt = req.Prompt
+	if req.System != "" {
+		// CLIs have no separate system prompt: prepend it
+		prompt = req.System + "\n\n" + prompt
+	}
+
+	var cmd = exec.CommandContext(cctx, p.command, p.args...)
+	cmd.Stdin = strings.NewReader(prompt)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	var stdout, err = cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%s CLI not found: %w", p.command, err)
+		}
+		return nil, fmt.Errorf("failed to start %s: %w", p.command, err)
+	}
+
+	var text strings.Builder
+	var buf = make([]byte, 4096)
+	var callbackErr error
+	for {
+		var n, readErr = stdout.Read(buf)
+		if n > 0 {
+			var chunk = string(buf[:n])
+			text.WriteString(chunk)
+			if onDelta != nil && callbackErr == nil {
+				if callbackErr = onDelta(chunk); callbackErr != nil {
+					cancel()
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	var waitErr = cmd.Wait()
+	if callbackErr != nil {
+		return nil, callbackErr
+	}
+	if waitErr != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if cctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s timed out after %s", p.command, p.opts.Timeout)
+		}
+		return nil, fmt.Errorf("AI command failed: %w - stderr: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return finish(&ports.AIResponse{Text: text.String(), Model: p.opts.Model})
+}
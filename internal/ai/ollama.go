@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// OllamaProvider calls the Ollama REST API (/api/chat)
+type OllamaProvider struct {
+	opts Options
+	url  string
+}
+
+func newOllama(opts Options) *OllamaProvider {
+	var base = strings.TrimSuffix(opts.BaseURL, "/")
+	if base == "" {
+		base = defaultOllamaURL
+	}
+	return &OllamaProvider{opts: opts, url: base + "/api/chat"}
+}
+
+// Name returns "ollama"
+func (p *OllamaProvider) Name() string {
+	return ProviderOllama
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaOptions struct {
+	NumPredict  int     `json:"num_predict,omitempty"`
+	Temperature float64 `json:"temperature,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (p *OllamaProvider) build(req *ports.AIRequest, stream bool) ollamaRequest {
+	var r = withDefaults(req, p.opts, defaultOllamaModel)
+	var messages []ollamaMessage
+	if r.System != "" {
+		messages = append(messages, ollamaMessage{Role: "system", Content: r.System})
+	}
+	messages = append(messages, ollamaMessage{Role: "user", Content: r.Prompt})
+
+	return ollamaRequest{
+		Model:    r.Model,
+		Messages: messages,
+		Stream:   stream,
+		Options:  ollamaOptions{NumPredict: r.MaxTokens, Temperature: r.Temperature},
+	}
+}
+
+// Complete sends a non-streaming chat request
+func (p *OllamaProvider) Complete(ctx context.Context, req *ports.AIRequest) (*ports.AIResponse, error) {
+	var cctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	var resp, err = postJSON(cctx, p.opts.HTTPClient, p.url, nil, p.build(req, false))
+	if err != nil {
+		return nil, fmt.Errorf("ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("ollama: failed to decode response: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", result.Error)
+	}
+
+	return finish(&ports.AIResponse{
+		Text:         result.Message.Content,
+		Model:        result.Model,
+		StopReason:   result.DoneReason,
+		InputTokens:  result.PromptEvalCount,
+		OutputTokens: result.EvalCount,
+	})
+}
+
+// Stream sends a streaming chat request (newline-delimited JSON)
+func (p *OllamaProvider) Stream(ctx context.Context, req *ports.AIRequest, onDelta ports.AIStreamFunc) (*ports.AIResponse, error) {
+	var cctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	var resp, err = postJSON(cctx, p.opts.HTTPClient, p.url, nil, p.build(req, true))
+	if err != nil {
+		return nil, fmt.Errorf("ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result = &ports.AIResponse{}
+	var text strings.Builder
+
+	err = readLines(resp.Body, func(line []byte) error {
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("failed to decode chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("%s", chunk.Error)
+		}
+
+		result.Model = chunk.Model
+		if chunk.Done {
+			result.StopReason = chunk.DoneReason
+			result.InputTokens = chunk.PromptEvalCount
+			result.OutputTokens = chunk.EvalCount
+		}
+		if chunk.Message.Content == "" {
+			return nil
+		}
+		text.WriteString(chunk.Message.Content)
+		if onDelta != nil {
+			return onDelta(chunk.Message.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ollama: %w", err)
+	}
+
+	result.Text = text.String()
+	return finish(result)
+}
@@ -0,0 +1,183 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// OpenAIProvider calls an OpenAI-compatible Chat Completions API
+// (OpenAI, Azure-style proxies, LM Studio, vLLM, Groq, etc.)
+type OpenAIProvider struct {
+	opts Options
+	url  string
+}
+
+func newOpenAI(opts Options) *OpenAIProvider {
+	var base = strings.TrimSuffix(opts.BaseURL, "/")
+	if base == "" {
+		base = defaultOpenAIURL
+	}
+	return &OpenAIProvider{opts: opts, url: base + "/chat/completions"}
+}
+
+// Name returns "openai"
+func (p *OpenAIProvider) Name() string {
+	return ProviderOpenAI
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Temperature   float64              `json:"temperature,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *OpenAIProvider) build(req *ports.AIRequest, stream bool) openAIRequest {
+	var r = withDefaults(req, p.opts, defaultOpenAIModel)
+	var messages []openAIMessage
+	if r.System != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: r.System})
+	}
+	messages = append(messages, openAIMessage{Role: "user", Content: r.Prompt})
+
+	var body = openAIRequest{
+		Model:       r.Model,
+		Messages:    messages,
+		MaxTokens:   r.MaxTokens,
+		Temperature: r.Temperature,
+		Stream:      stream,
+	}
+	if stream {
+		body.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	return body
+}
+
+func (p *OpenAIProvider) headers() map[string]string {
+	if p.opts.APIKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + p.opts.APIKey}
+}
+
+// Complete sends a non-streaming chat completion request
+func (p *OpenAIProvider) Complete(ctx context.Context, req *ports.AIRequest) (*ports.AIResponse, error) {
+	var cctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	var resp, err = postJSON(cctx, p.opts.HTTPClient, p.url, p.headers(), p.build(req, false))
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("openai: failed to decode response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("openai: %w", ErrEmptyResponse)
+	}
+
+	var out = &ports.AIResponse{
+		Text:       result.Choices[0].Message.Content,
+		Model:      result.Model,
+		StopReason: result.Choices[0].FinishReason,
+	}
+	if result.Usage != nil {
+		out.InputTokens = result.Usage.PromptTokens
+		out.OutputTokens = result.Usage.CompletionTokens
+	}
+	return finish(out)
+}
+
+// Stream sends a streaming chat completion request (server-sent events)
+func (p *OpenAIProvider) Stream(ctx context.Context, req *ports.AIRequest, onDelta ports.AIStreamFunc) (*ports.AIResponse, error) {
+	var cctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	var resp, err = postJSON(cctx, p.opts.HTTPClient, p.url, p.headers(), p.build(req, true))
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result = &ports.AIResponse{}
+	var text strings.Builder
+
+	err = readSSE(resp.Body, func(_, data string) error {
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to decode chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("%s", chunk.Error.Message)
+		}
+
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.InputTokens = chunk.Usage.PromptTokens
+			result.OutputTokens = chunk.Usage.CompletionTokens
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				result.StopReason = choice.FinishReason
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			text.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				if err := onDelta(choice.Delta.Content); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+
+	result.Text = text.String()
+	return finish(result)
+}
@@ -0,0 +1,182 @@
+// Package ai provides AI text generation backends implementing ports.AIProvider:
+// native HTTP clients for the Anthropic API, OpenAI-compatible APIs and the
+// Ollama REST API, plus CLI tools (claude, gemini) fed through stdin.
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// Provider identifiers accepted by New
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+	ProviderOllama    = "ollama"
+	ProviderClaudeCLI = "claude"
+	ProviderGeminiCLI = "gemini"
+	ProviderCLI       = "cli"
+)
+
+// IsCLI returns true for providers that run a local CLI agent. These can use
+// tools on the machine, so they must never be fed untrusted text unattended.
+func IsCLI(provider string) bool {
+	switch strings.ToLower(provider) {
+	case ProviderClaudeCLI, ProviderGeminiCLI, ProviderCLI, "":
+		return true
+	}
+	return false
+}
+
+// Defaults used when Options leaves a field empty
+const (
+	DefaultTimeout   = 2 * time.Minute
+	DefaultMaxTokens = 2048
+
+	defaultAnthropicModel = "claude-sonnet-4-5"
+	defaultOpenAIModel    = "gpt-4o-mini"
+	defaultOllamaModel    = "llama3"
+
+	defaultAnthropicURL = "https://api.anthropic.com"
+	defaultOpenAIURL    = "https://api.openai.com/v1"
+	defaultOllamaURL    = "http://localhost:11434"
+)
+
+// Options configures a provider
+type Options struct {
+	Provider    string        // one of the Provider* constants (default: claude CLI)
+	Model       string        // model name (provider default when empty)
+	BaseURL     string        // API base URL (for proxies, local servers and tests)
+	APIKey      string        // falls back to ANTHROPIC_API_KEY / OPENAI_API_KEY
+	Timeout     time.Duration // per request (default: 2m)
+	MaxTokens   int           // response limit (default: 2048)
+	Temperature float64       // 0 = provider default
+	Command     string        // CLI provider: executable
+	Args        []string      // CLI provider: arguments (prompt goes to stdin)
+	HTTPClient  *http.Client  // optional custom client
+}
+
+// New creates the provider selected by opts.Provider
+func New(opts Options) (ports.AIProvider, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	if opts.HTTPClient == nil {
+		// No client timeout: it would cut long streams. Requests use a context deadline.
+		opts.HTTPClient = &http.Client{}
+	}
+
+	switch strings.ToLower(opts.Provider) {
+	case ProviderAnthropic:
+		if opts.APIKey == "" {
+			opts.APIKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		if opts.APIKey == "" {
+			return nil, fmt.Errorf("anthropic provider requires an API key (ai.api_key or ANTHROPIC_API_KEY)")
+		}
+		return newAnthropic(opts), nil
+	case ProviderOpenAI:
+		if opts.APIKey == "" {
+			opts.APIKey = os.Getenv("OPENAI_API_KEY")
+		}
+		// API key is optional for OpenAI-compatible local servers
+		if opts.APIKey == "" && opts.BaseURL == "" {
+			return nil, fmt.Errorf("openai provider requires an API key (ai.api_key or OPENAI_API_KEY)")
+		}
+		return newOpenAI(opts), nil
+	case ProviderOllama:
+		return newOllama(opts), nil
+	case ProviderCLI:
+		if opts.Command == "" {
+			return nil, fmt.Errorf("cli provider requires ai.command")
+		}
+		return newCLI(ProviderCLI, opts.Command, opts.Args, opts), nil
+	case ProviderGeminiCLI:
+		var args []string
+		if opts.Model != "" {
+			args = append(args, "-m", opts.Model)
+		}
+		return newCLI(ProviderGeminiCLI, "gemini", args, opts), nil
+	case ProviderClaudeCLI, "":
+		var args = []string{"-p", "--permission-mode", "bypassPermissions"}
+		if opts.Model != "" {
+			args = append(args, "--model", opts.Model)
+		}
+		return newCLI(ProviderClaudeCLI, "claude", args, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider: %s", opts.Provider)
+	}
+}
+
+// withDefaults fills model and max tokens from the provider options
+func withDefaults(req *ports.AIRequest, opts Options, defaultModel string) ports.AIRequest {
+	var r = *req
+	if r.Model == "" {
+		r.Model = opts.Model
+	}
+	if r.Model == "" {
+		r.Model = defaultModel
+	}
+	if r.MaxTokens <= 0 {
+		r.MaxTokens = opts.MaxTokens
+	}
+	if r.Temperature == 0 {
+		r.Temperature = opts.Temperature
+	}
+	return r
+}
+
+// postJSON sends body as JSON and returns the response when the status is 2xx
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body interface{}) (*http.Response, error) {
+	var payload, err = json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var req, reqErr = http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	var resp, respErr = client.Do(req)
+	if respErr != nil {
+		return nil, respErr
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var respBody, _ = io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return resp, nil
+}
+
+// ErrEmptyResponse is returned when a provider answers with no text
+var ErrEmptyResponse = errors.New("AI returned empty response")
+
+// finish trims the text and rejects empty responses
+func finish(resp *ports.AIResponse) (*ports.AIResponse, error) {
+	resp.Text = strings.TrimSpace(resp.Text)
+	if resp.Text == "" {
+		return nil, ErrEmptyResponse
+	}
+	return resp, nil
+}
@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxLineSize bounds a single SSE/NDJSON line
+const maxLineSize = 1024 * 1024
+
+// readSSE parses a text/event-stream body and calls fn for each event.
+// Multi-line data fields are joined with "\n" as per the SSE spec.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	var event string
+	var data []string
+
+	var dispatch = func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		var err = fn(event, strings.Join(data, "\n"))
+		event = ""
+		data = data[:0]
+		return err
+	}
+
+	for scanner.Scan() {
+		var line = scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// Stream ended without a trailing blank line
+	return dispatch()
+}
+
+// readLines calls fn for each non-empty line (NDJSON streams)
+func readLines(r io.Reader, fn func(line []byte) error) error {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var line = scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
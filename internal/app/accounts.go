@@ -0,0 +1,297 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/opik/miau/internal/adapters"
+	"github.com/opik/miau/internal/caldav"
+	"github.com/opik/miau/internal/carddav"
+	"github.com/opik/miau/internal/config"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/services"
+)
+
+// defaultSyncInterval is used when sync.interval is missing or invalid
+const defaultSyncInterval = 5 * time.Minute
+
+// accountRuntime is one configured account, kept connected and synced
+// alongside the others: its own IMAP connection (or Gmail API / JMAP
+// backend), sync service (with push sync on a dedicated connection), filter
+// rules and send adapters.
+type accountRuntime struct {
+	cfg    *config.Account
+	info   *ports.AccountInfo
+	imap   ports.IMAPPort
+	smtp   ports.SMTPPort
+	gmail  *adapters.GmailAPIAdapter
+	sync   *services.SyncService
+	rules  *services.RuleService
+	snooze *services.SnoozeService // used by rules; the app's one follows the current account
+}
+
+// newAccountRuntime creates the adapters and sync service of an account.
+// Rules are wired later, once the services they act through exist.
+func (a *Application) newAccountRuntime(account *config.Account) (*accountRuntime, error) {
+	var info, err = a.storageAdapter.GetOrCreateAccount(context.Background(), account.Email, account.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get/create account %s: %w", account.Email, err)
+	}
+
+	var rt = &accountRuntime{
+		cfg:   account,
+		info:  info,
+		imap:  adapters.NewIMAPAdapter(account),
+		smtp:  adapters.NewSMTPAdapter(account),
+		gmail: adapters.NewGmailAPIAdapter(account, config.GetConfigPath()),
+	}
+	var idle ports.IMAPPort = adapters.NewIMAPAdapter(account)
+
+	// sync_backend: gmail_api skips IMAP entirely (needs an OAuth2 token)
+	if account.SyncBackend == config.SyncBackendGmailAPI {
+		if rt.gmail != nil {
+			rt.imap = rt.gmail.SyncBackend(info.ID)
+			idle = rt.gmail.SyncBackend(info.ID)
+		} else {
+			log.Printf("[Application] %s: sync_backend gmail_api needs OAuth2, using IMAP", account.Email)
+		}
+	}
+
+	// sync_backend: jmap syncs and sends through the JMAP server
+	if account.SyncBackend == config.SyncBackendJMAP {
+		var jmapAdapter = adapters.NewJMAPAdapter(account)
+		if jmapAdapter != nil {
+			rt.imap = jmapAdapter.SyncBackend(info.ID)
+			rt.smtp = jmapAdapter
+			idle = jmapAdapter.SyncBackend(info.ID)
+		} else {
+			log.Printf("[Application] %s: sync_backend jmap needs jmap.url, using IMAP", account.Email)
+		}
+	}
+
+	rt.sync = services.NewSyncService(rt.imap, a.storageAdapter, a.eventBus)
+	rt.sync.SetAccount(info)
+	rt.sync.SetIdleAdapter(idle)
+	var syncConfig = rt.sync.GetSyncConfig()
+	syncConfig.IdleEnabled = a.cfg.Sync.Idle
+	rt.sync.SetSyncConfig(syncConfig)
+
+	return rt, nil
+}
+
+// smtpPort returns the SMTP adapter as a port (nil-safe, see Start)
+func (rt *accountRuntime) smtpPort() ports.SMTPPort {
+	if rt.smtp == nil {
+		return nil
+	}
+	return rt.smtp
+}
+
+// gmailPort returns the Gmail API adapter as a port (nil-safe, see Start)
+func (rt *accountRuntime) gmailPort() ports.GmailAPIPort {
+	if rt.gmail == nil {
+		return nil
+	}
+	return rt.gmail
+}
+
+func (rt *accountRuntime) sendMethod() ports.SendMethod {
+	// JMAP accounts send through the SMTP port (EmailSubmission)
+	if rt.cfg.SendMethod == config.SendMethodGmailAPI && rt.cfg.SyncBackend != config.SyncBackendJMAP {
+		return ports.SendMethodGmailAPI
+	}
+	return ports.SendMethodSMTP
+}
+
+// caldavClient returns the CalDAV client of the account's calendar (nil if
+// it has none); the login defaults to the email credentials
+func (rt *accountRuntime) caldavClient() *caldav.Client {
+	if rt.cfg.CalDAV == nil || rt.cfg.CalDAV.URL == "" {
+		return nil
+	}
+	var username, password = rt.cfg.CalDAV.Username, rt.cfg.CalDAV.Password
+	if username == "" {
+		username = rt.cfg.Email
+	}
+	if password == "" {
+		password = rt.cfg.Password
+	}
+	var client, err = caldav.NewClient(rt.cfg.CalDAV.URL, username, password)
+	if err != nil {
+		log.Printf("[Application] %s: invalid caldav.url: %v", rt.cfg.Email, err)
+		return nil
+	}
+	return client
+}
+
+// carddavClient returns the CardDAV client of the account's address book
+// (nil if it has none); the login defaults to the email credentials
+func (rt *accountRuntime) carddavClient() *carddav.Client {
+	if rt.cfg.CardDAV == nil || rt.cfg.CardDAV.URL == "" {
+		return nil
+	}
+	var username, password = rt.cfg.CardDAV.Username, rt.cfg.CardDAV.Password
+	if username == "" {
+		username = rt.cfg.Email
+	}
+	if password == "" {
+		password = rt.cfg.Password
+	}
+	var client, err = carddav.NewClient(rt.cfg.CardDAV.URL, username, password)
+	if err != nil {
+		log.Printf("[Application] %s: invalid carddav.url: %v", rt.cfg.Email, err)
+		return nil
+	}
+	return client
+}
+
+// sendEndpoint returns the host:port the account sends email through
+func (rt *accountRuntime) sendEndpoint() string {
+	if rt.cfg.SyncBackend == config.SyncBackendJMAP && rt.cfg.JMAP != nil && rt.cfg.JMAP.URL != "" {
+		var host = rt.cfg.JMAP.URL
+		if u, err := url.Parse(host); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "443")
+		}
+		return host
+	}
+	if rt.sendMethod() == ports.SendMethodGmailAPI {
+		return "gmail.googleapis.com:443"
+	}
+	var port = rt.cfg.SMTP.Port
+	if port == 0 {
+		port = 587
+	}
+	return net.JoinHostPort(rt.cfg.SMTP.Host, strconv.Itoa(port))
+}
+
+// sendReachable reports whether the send server of an account accepts
+// connections; the outbox only tries accounts whose server does
+func (a *Application) sendReachable(ctx context.Context, accountID int64) bool {
+	for _, rt := range a.runtimes {
+		if rt.info.ID != accountID {
+			continue
+		}
+		var dialer = net.Dialer{Timeout: 5 * time.Second}
+		var conn, err = dialer.DialContext(ctx, "tcp", rt.sendEndpoint())
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+	return true // unknown account: let the send itself fail
+}
+
+// runtimeFor returns the runtime of an account by email (nil if unknown)
+func (a *Application) runtimeFor(email string) *accountRuntime {
+	for _, rt := range a.runtimes {
+		if rt.cfg.Email == email {
+			return rt
+		}
+	}
+	return nil
+}
+
+// isCurrent reports whether rt is the account the UI is showing
+func (a *Application) isCurrent(rt *accountRuntime) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.account != nil && a.account.Email == rt.cfg.Email
+}
+
+// StartBackgroundSync keeps every account other than the current one
+// connected and its INBOX synced, so the unified inbox stays fresh: push
+// sync when sync.idle is on, otherwise polling every sync.interval. The
+// current account is synced by the UI; once the user switches away from an
+// account, its background loop takes over.
+func (a *Application) StartBackgroundSync() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.started || a.bgCancel != nil || len(a.runtimes) < 2 {
+		return
+	}
+
+	var interval, err = time.ParseDuration(a.cfg.Sync.Interval)
+	if err != nil || interval <= 0 {
+		interval = defaultSyncInterval
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	a.bgCancel = cancel
+	for _, rt := range a.runtimes {
+		a.bgWait.Add(1)
+		go func(rt *accountRuntime) {
+			defer a.bgWait.Done()
+			a.syncInBackground(ctx, rt, interval)
+		}(rt)
+	}
+}
+
+// syncInBackground is the loop of one account in StartBackgroundSync
+func (a *Application) syncInBackground(ctx context.Context, rt *accountRuntime, interval time.Duration) {
+	for {
+		if !a.isCurrent(rt) && !rt.sync.IsIdling() {
+			if _, err := a.syncRuntime(ctx, rt, "INBOX"); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("[Application] background sync of %s failed: %v", rt.cfg.Email, err)
+			} else if err := rt.sync.StartIdle(ctx); err != nil {
+				log.Printf("[Application] push sync of %s failed: %v", rt.cfg.Email, err)
+			}
+		}
+
+		var timer = time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// syncRuntime connects an account if needed and syncs one folder
+func (a *Application) syncRuntime(ctx context.Context, rt *accountRuntime, folder string) (*ports.SyncResult, error) {
+	if !rt.sync.IsConnected() {
+		if err := rt.sync.Connect(ctx); err != nil {
+			return nil, err
+		}
+		if _, err := rt.sync.LoadFolders(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return rt.sync.SyncFolder(ctx, folder)
+}
+
+// SyncAccounts syncs a folder on every configured account concurrently,
+// connecting the ones that aren't yet. Results follow the config order.
+func (a *Application) SyncAccounts(ctx context.Context, folder string) []ports.AccountSyncResult {
+	a.mu.RLock()
+	var runtimes = append([]*accountRuntime(nil), a.runtimes...)
+	a.mu.RUnlock()
+
+	var results = make([]ports.AccountSyncResult, len(runtimes))
+	var wg sync.WaitGroup
+	for i, rt := range runtimes {
+		wg.Add(1)
+		go func(i int, rt *accountRuntime) {
+			defer wg.Done()
+			var result, err = a.syncRuntime(ctx, rt, folder)
+			results[i] = ports.AccountSyncResult{Account: *rt.info, Result: result, Err: err}
+		}(i, rt)
+	}
+	wg.Wait()
+
+	return results
+}
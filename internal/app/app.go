@@ -5,10 +5,13 @@ package app
 import (
 	"context"
 	"fmt"
+	"net"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/opik/miau/internal/adapters"
+	"github.com/opik/miau/internal/ai"
 	"github.com/opik/miau/internal/config"
 	"github.com/opik/miau/internal/imap"
 	"github.com/opik/miau/internal/plugins/basecamp"
@@ -28,9 +31,9 @@ type Application struct {
 	appConfig ports.AppConfig
 
 	// Ports (adapters)
-	imapAdapter    *adapters.IMAPAdapter
+	imapAdapter    ports.IMAPPort
 	storageAdapter *adapters.StorageAdapter
-	smtpAdapter    *adapters.SMTPAdapter
+	smtpAdapter    ports.SMTPPort
 	gmailAdapter   *adapters.GmailAPIAdapter
 
 	// Services
@@ -53,12 +56,25 @@ type Application struct {
 	basecampService   *services.BasecampService
 	snoozeService     *services.SnoozeService
 	scheduleService   *services.ScheduleService
+	ruleService       *services.RuleService
+	pgpService        *services.PGPService
+	smimeService      *services.SMIMEService
+	riskService       *services.RiskService
+	outboxService     *services.OutboxService
+	followUpService   *services.FollowUpService
+	inviteService     *services.InviteService
 
 	// Plugin system
 	pluginStorage  *storage.PluginStorage
 	pluginRegistry *services.PluginRegistry
 	pluginService  *services.PluginService
 
+	// Every configured account, in config order; the services above point
+	// at the current one
+	runtimes []*accountRuntime
+	bgCancel context.CancelFunc
+	bgWait   sync.WaitGroup
+
 	// State
 	accountInfo *ports.AccountInfo
 	started     bool
@@ -90,7 +106,7 @@ func New(cfg *config.Config, account *config.Account, debugMode bool) (*Applicat
 		app.appConfig.AuthType = ports.AuthTypePassword
 	}
 
-	if account.SendMethod == config.SendMethodGmailAPI {
+	if account.SendMethod == config.SendMethodGmailAPI && account.SyncBackend != config.SyncBackendJMAP {
 		app.appConfig.SendMethod = ports.SendMethodGmailAPI
 	} else {
 		app.appConfig.SendMethod = ports.SendMethodSMTP
@@ -113,45 +129,51 @@ func (a *Application) Start() error {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	// Create adapters
-	a.imapAdapter = adapters.NewIMAPAdapter(a.account)
 	a.storageAdapter = adapters.NewStorageAdapter()
-	a.smtpAdapter = adapters.NewSMTPAdapter(a.account)
-	a.gmailAdapter = adapters.NewGmailAPIAdapter(a.account, config.GetConfigPath())
 
 	// Create event bus
 	a.eventBus = services.NewEventBus()
 
-	// Get or create account in storage
-	var accountInfo, err = a.storageAdapter.GetOrCreateAccount(context.Background(), a.account.Email, a.account.Name)
-	if err != nil {
-		return fmt.Errorf("failed to get/create account: %w", err)
+	// Create one runtime (connections + sync) per configured account
+	a.runtimes = nil
+	for i := range a.cfg.Accounts {
+		var rt, err = a.newAccountRuntime(&a.cfg.Accounts[i])
+		if err != nil {
+			return err
+		}
+		a.runtimes = append(a.runtimes, rt)
+	}
+	var current = a.runtimeFor(a.account.Email)
+	if current == nil {
+		var rt, err = a.newAccountRuntime(a.account)
+		if err != nil {
+			return err
+		}
+		a.runtimes = append(a.runtimes, rt)
+		current = rt
 	}
-	a.accountInfo = accountInfo
+
+	// Services act on the current account
+	a.imapAdapter = current.imap
+	a.smtpAdapter = current.smtp
+	a.gmailAdapter = current.gmail
+	a.syncService = current.sync
+	a.accountInfo = current.info
+	var accountInfo = current.info
 
 	// Create undo service first (needed by other services)
 	a.undoService = services.NewUndoService(a.storageAdapter, a.imapAdapter)
 	a.undoService.SetAccount(accountInfo)
 
 	// Create services
-	a.syncService = services.NewSyncService(a.imapAdapter, a.storageAdapter, a.eventBus)
-	a.syncService.SetAccount(accountInfo)
-
 	a.emailService = services.NewEmailService(a.imapAdapter, a.storageAdapter, a.eventBus, a.undoService)
 	a.emailService.SetAccount(accountInfo)
 
 	// IMPORTANT: We must explicitly check for nil before assigning to interface
 	// to avoid the "nil interface containing nil pointer" gotcha in Go.
 	// An interface is only truly nil if both type and value are nil.
-	var smtpPort ports.SMTPPort
-	if a.smtpAdapter != nil {
-		smtpPort = a.smtpAdapter
-	}
-	var gmailPort ports.GmailAPIPort
-	if a.gmailAdapter != nil {
-		gmailPort = a.gmailAdapter
-	}
-	a.sendService = services.NewSendService(smtpPort, gmailPort, a.storageAdapter, a.eventBus)
+	// (accountRuntime.smtpPort and gmailPort do the same check)
+	a.sendService = services.NewSendService(current.smtpPort(), current.gmailPort(), a.storageAdapter, a.eventBus)
 	a.sendService.SetAccount(accountInfo)
 	a.sendService.SetSendMethod(a.appConfig.SendMethod)
 
@@ -199,6 +221,12 @@ func (a *Application) Start() error {
 	// Create AI service
 	a.aiService = services.NewAIService(a.storageAdapter, a.eventBus)
 	a.aiService.SetAccount(accountInfo)
+	a.aiService.SetProviderFactory(a.newAIProvider)
+	if aiProvider, err := a.newAIProvider(""); err != nil {
+		fmt.Printf("[App.Start] AI provider %q unavailable, using claude CLI: %v\n", a.cfg.AI.Provider, err)
+	} else {
+		a.aiService.SetProvider(aiProvider)
+	}
 
 	// Create Basecamp service
 	a.basecampService = services.NewBasecampService(a.eventBus)
@@ -211,6 +239,110 @@ func (a *Application) Start() error {
 	a.scheduleService = services.NewScheduleService(a.storageAdapter, a.sendService, a.eventBus)
 	a.scheduleService.SetAccount(accountInfo)
 
+	// Create rule services (filter rules applied to new INBOX emails at sync time).
+	// Rules of every account run in the background, so each one snoozes
+	// through its own account; tasks, forwards and classify take the account
+	// from the rule itself.
+	for _, rt := range a.runtimes {
+		rt.snooze = services.NewSnoozeService(a.storageAdapter, a.eventBus)
+		rt.snooze.SetAccount(rt.info)
+		rt.rules = services.NewRuleService(rt.imap, a.storageAdapter, a.eventBus, a.undoService)
+		rt.rules.SetAccount(rt.info)
+		rt.rules.SetActionServices(rt.snooze, a.taskService, a.sendService, a.aiService)
+		rt.sync.SetRules(rt.rules)
+	}
+	a.ruleService = current.rules
+
+	// Create PGP service (keyring in ~/.config/miau/pgp, per-account keys)
+	a.pgpService = services.NewPGPService(filepath.Join(config.GetConfigPath(), "pgp"))
+	for _, rt := range a.runtimes {
+		if pgp := rt.cfg.PGP; pgp != nil {
+			a.pgpService.SetAccount(rt.cfg.Email, services.PGPAccount{
+				Key:        pgp.Key,
+				Passphrase: pgp.Passphrase,
+				Sign:       pgp.Sign,
+				Encrypt:    pgp.Encrypt,
+			})
+		}
+	}
+	a.emailService.SetPGP(a.pgpService)
+	a.sendService.SetPGP(a.pgpService)
+
+	// Create S/MIME service (correspondents' certificates in ~/.config/miau/smime)
+	a.smimeService = services.NewSMIMEService(filepath.Join(config.GetConfigPath(), "smime"))
+	for _, rt := range a.runtimes {
+		if smime := rt.cfg.SMIME; smime != nil && smime.Identity != "" {
+			a.smimeService.SetAccount(rt.cfg.Email, services.SMIMEAccount{
+				Identity: configFile(smime.Identity),
+				Password: smime.Password,
+				CA:       configFile(smime.CA),
+				Sign:     smime.Sign,
+				Encrypt:  smime.Encrypt,
+			})
+		}
+	}
+	a.emailService.SetSMIME(a.smimeService)
+	a.sendService.SetSMIME(a.smimeService)
+
+	// Create risk service (SPF/DKIM/DMARC and phishing heuristics); DKIM keys
+	// are looked up in DNS
+	a.riskService = services.NewRiskService(a.storageAdapter, net.DefaultResolver)
+	a.emailService.SetRisk(a.riskService)
+	for _, rt := range a.runtimes {
+		rt.sync.SetRisk(a.riskService)
+	}
+
+	// Create outbox (emails queued while the send server is unreachable,
+	// sent in the background once it answers again)
+	a.outboxService = services.NewOutboxService(a.sendService, a.eventBus)
+	a.outboxService.SetConnectivityCheck(a.sendReachable)
+	a.sendService.SetOutbox(a.outboxService)
+
+	// Undo send: every send waits in the outbox for compose.send_delay_seconds
+	// and can be cancelled meanwhile, from any UI or through Undo
+	a.sendService.SetSendDelay(a.cfg.Compose.SendDelay())
+	a.sendService.SetUndo(a.undoService)
+	a.undoService.SetSendService(a.sendService)
+
+	// Follow-ups: remind about sent emails nobody replied to (resurface the
+	// conversation, create a task and optionally an AI nudge draft)
+	a.followUpService = services.NewFollowUpService(a.threadService, a.taskService, a.eventBus)
+	a.followUpService.SetAccount(accountInfo)
+	a.followUpService.SetNudge(a.aiService, a.cfg.Compose.FollowUpNudge)
+	a.sendService.SetFollowUps(a.followUpService)
+	// Every account's follow-ups are processed, each looking for replies in
+	// its own threads and resurfacing over its own connection
+	for _, rt := range a.runtimes {
+		var threads = services.NewThreadService(a.storageAdapter, a.eventBus)
+		threads.SetAccount(rt.info)
+		a.followUpService.AddAccount(rt.info, threads, rt.imap)
+	}
+
+	// Meeting invites: imported into the calendar when the email is opened,
+	// answered with an iTIP REPLY to the organizer
+	a.inviteService = services.NewInviteService(a.sendService)
+	a.inviteService.SetAccount(accountInfo)
+	a.emailService.SetInvites(a.inviteService)
+
+	// Let services reach every account: unified inbox, search across
+	// accounts, replies sent from the account the email was received on
+	for _, rt := range a.runtimes {
+		a.emailService.AddAccount(rt.info, rt.imap)
+		a.emailService.SetGmailAPI(rt.info.ID, rt.gmailPort())
+		a.searchService.AddAccount(rt.info, rt.imap)
+		a.attachmentService.AddAccount(rt.info, rt.imap)
+		a.sendService.AddIdentity(rt.info, rt.smtpPort(), rt.gmailPort(), rt.sendMethod())
+		if client := rt.caldavClient(); client != nil {
+			a.calendarService.SetCalDAV(rt.info.ID, client)
+		}
+		if client := rt.carddavClient(); client != nil {
+			a.contactService.SetCardDAV(rt.info.ID, client)
+		}
+		if rt.gmail != nil && rt.gmail.CalendarClient() != nil {
+			a.calendarService.SetGoogleCalendar(rt.info.ID, rt.gmail.CalendarClient())
+		}
+	}
+
 	// Wire up bidirectional Task ↔ Calendar sync
 	a.taskService.SetCalendarSync(a.calendarService)
 
@@ -229,6 +361,15 @@ func (a *Application) Start() error {
 	// Register built-in plugins
 	a.pluginRegistry.Register(basecamp.New())
 
+	a.outboxService.Start(context.Background())
+
+	// Send scheduled drafts and the occurrences of recurring sends
+	a.scheduleService.Start(context.Background())
+	a.followUpService.Start(context.Background())
+
+	// Two-way sync of the CalDAV and Google calendars
+	a.calendarService.Start(context.Background())
+
 	a.started = true
 	return nil
 }
@@ -242,9 +383,24 @@ func (a *Application) Stop() error {
 		return nil
 	}
 
-	// Disconnect IMAP
-	if a.imapAdapter != nil {
-		a.imapAdapter.Close()
+	// Stop the schedule, follow-up, calendar sync and outbox workers (a send
+	// in progress finishes first)
+	a.scheduleService.Stop()
+	a.followUpService.Stop()
+	a.calendarService.Stop()
+	a.outboxService.Stop()
+
+	// Stop background sync of the other accounts
+	if a.bgCancel != nil {
+		a.bgCancel()
+		a.bgCancel = nil
+	}
+
+	for _, rt := range a.runtimes {
+		// Stop push sync (closes its dedicated connection)
+		rt.sync.StopIdle()
+		// Disconnect IMAP
+		rt.imap.Close()
 	}
 
 	a.started = false
@@ -281,11 +437,38 @@ func (a *Application) Notification() ports.NotificationService {
 	return a.notifyService
 }
 
-// Sync returns the sync service
+// Sync returns the sync service of the current account
 func (a *Application) Sync() ports.SyncService {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	return a.syncService
 }
 
+// newAIProvider builds an AI provider from the ai config section.
+// An empty name uses ai.provider; model, base URL, API key and command
+// only apply to the configured provider, others get their defaults.
+func (a *Application) newAIProvider(name string) (ports.AIProvider, error) {
+	var cfg = a.cfg.AI
+	var timeout, _ = time.ParseDuration(cfg.Timeout)
+
+	var opts = ai.Options{
+		Provider:    name,
+		Timeout:     timeout,
+		MaxTokens:   cfg.MaxTokens,
+		Temperature: cfg.Temperature,
+	}
+	if name == "" || name == cfg.Provider {
+		opts.Provider = cfg.Provider
+		opts.Model = cfg.Model
+		opts.BaseURL = cfg.BaseURL
+		opts.APIKey = cfg.APIKey
+		opts.Command = cfg.Command
+		opts.Args = cfg.Args
+	}
+
+	return ai.New(opts)
+}
+
 // AI returns the AI service
 func (a *Application) AI() ports.AIService {
 	return a.aiService
@@ -341,6 +524,43 @@ func (a *Application) Schedule() ports.ScheduleService {
 	return a.scheduleService
 }
 
+// Rules returns the mail filter rule service of the current account
+func (a *Application) Rules() ports.RuleService {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.ruleService
+}
+
+// PGP returns the OpenPGP service
+func (a *Application) PGP() ports.PGPService {
+	return a.pgpService
+}
+
+// SMIME returns the S/MIME service
+func (a *Application) SMIME() ports.SMIMEService {
+	return a.smimeService
+}
+
+// Risk returns the risk service
+func (a *Application) Risk() ports.RiskService {
+	return a.riskService
+}
+
+// Outbox returns the outbox service
+func (a *Application) Outbox() ports.OutboxService {
+	return a.outboxService
+}
+
+// FollowUps returns the follow-up service
+func (a *Application) FollowUps() ports.FollowUpService {
+	return a.followUpService
+}
+
+// Invites returns the meeting invite service
+func (a *Application) Invites() ports.InviteService {
+	return a.inviteService
+}
+
 // Plugins returns the plugin service
 func (a *Application) Plugins() ports.PluginService {
 	return a.pluginService
@@ -365,12 +585,11 @@ func (a *Application) GetAllAccounts() []ports.AccountInfo {
 
 	var accounts []ports.AccountInfo
 	for _, acc := range a.cfg.Accounts {
-		// Get the account ID from storage if available
+		// Get the account ID from the runtime, or storage before Start
 		var id int64
-		if acc.Email == a.account.Email && a.accountInfo != nil {
-			id = a.accountInfo.ID
-		} else {
-			// Try to get from storage
+		if rt := a.runtimeFor(acc.Email); rt != nil {
+			id = rt.info.ID
+		} else if a.storageAdapter != nil {
 			var accInfo, err = a.storageAdapter.GetOrCreateAccount(context.Background(), acc.Email, acc.Name)
 			if err == nil && accInfo != nil {
 				id = accInfo.ID
@@ -385,21 +604,16 @@ func (a *Application) GetAllAccounts() []ports.AccountInfo {
 	return accounts
 }
 
-// SetCurrentAccount switches to a different account
-// This disconnects the current IMAP connection and reinitializes all adapters
+// SetCurrentAccount switches the services to a different account.
+// Every account keeps its own connections, so nothing is disconnected:
+// the account switched away from goes on syncing in the background.
 func (a *Application) SetCurrentAccount(email string) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	// Find account by email
-	var newAccount *config.Account
-	for i := range a.cfg.Accounts {
-		if a.cfg.Accounts[i].Email == email {
-			newAccount = &a.cfg.Accounts[i]
-			break
-		}
-	}
-	if newAccount == nil {
+	var rt = a.runtimeFor(email)
+	if rt == nil {
 		return fmt.Errorf("account not found: %s", email)
 	}
 
@@ -410,16 +624,12 @@ func (a *Application) SetCurrentAccount(email string) error {
 
 	// Save previous email for event
 	var previousEmail = a.account.Email
+	var newAccount = rt.cfg
 
-	// Step 1: Disconnect current IMAP
-	if a.imapAdapter != nil {
-		a.imapAdapter.Close()
-	}
-
-	// Step 2: Update current account reference
+	// Step 1: Update current account reference
 	a.account = newAccount
 
-	// Step 3: Update app config
+	// Step 2: Update app config
 	a.appConfig.AccountEmail = newAccount.Email
 	a.appConfig.AccountName = newAccount.Name
 	a.appConfig.IMAPHost = newAccount.IMAP.Host
@@ -429,27 +639,20 @@ func (a *Application) SetCurrentAccount(email string) error {
 	} else {
 		a.appConfig.AuthType = ports.AuthTypePassword
 	}
-	if newAccount.SendMethod == config.SendMethodGmailAPI {
-		a.appConfig.SendMethod = ports.SendMethodGmailAPI
-	} else {
-		a.appConfig.SendMethod = ports.SendMethodSMTP
-	}
+	a.appConfig.SendMethod = rt.sendMethod()
 
-	// Step 4: Reinitialize adapters for new account
-	a.imapAdapter = adapters.NewIMAPAdapter(newAccount)
-	a.smtpAdapter = adapters.NewSMTPAdapter(newAccount)
-	a.gmailAdapter = adapters.NewGmailAPIAdapter(newAccount, config.GetConfigPath())
+	// Step 3: Point at the adapters of the account's runtime
+	a.imapAdapter = rt.imap
+	a.smtpAdapter = rt.smtp
+	a.gmailAdapter = rt.gmail
+	a.syncService = rt.sync
+	a.ruleService = rt.rules
+	a.accountInfo = rt.info
+	var accountInfo = rt.info
 
-	// Step 5: Get or create account info in storage
-	var accountInfo, err = a.storageAdapter.GetOrCreateAccount(context.Background(), newAccount.Email, newAccount.Name)
-	if err != nil {
-		return fmt.Errorf("failed to get/create account in storage: %w", err)
-	}
-	a.accountInfo = accountInfo
-
-	// Step 6: Update all services with new account
+	// Step 4: Update all services with new account
+	a.undoService.SetIMAPAdapter(a.imapAdapter)
 	a.undoService.SetAccount(accountInfo)
-	a.syncService.SetAccount(accountInfo)
 	a.emailService.SetAccount(accountInfo)
 	a.sendService.SetAccount(accountInfo)
 	a.sendService.SetSendMethod(a.appConfig.SendMethod)
@@ -464,25 +667,22 @@ func (a *Application) SetCurrentAccount(email string) error {
 	a.pluginService.SetAccount(accountInfo)
 	a.snoozeService.SetAccount(accountInfo)
 	a.scheduleService.SetAccount(accountInfo)
+	a.followUpService.SetAccount(accountInfo)
+	a.followUpService.Wake()
+	a.inviteService.SetAccount(accountInfo)
 
-	// Step 7: Update IMAP and Gmail in services that need them
-	a.syncService.SetIMAPAdapter(a.imapAdapter)
+	// Step 5: Update IMAP, SMTP and Gmail in services that need them
 	a.searchService.SetIMAP(a.imapAdapter)
 	a.attachmentService.SetIMAPAdapter(a.imapAdapter)
-
-	// Update Gmail adapter in send service
-	var gmailPort ports.GmailAPIPort
-	if a.gmailAdapter != nil {
-		gmailPort = a.gmailAdapter
-	}
-	a.sendService.SetGmailAPI(gmailPort)
+	a.sendService.SetSMTP(rt.smtpPort())
+	a.sendService.SetGmailAPI(rt.gmailPort())
 
 	// Update calendar client if available
 	if a.gmailAdapter != nil && a.gmailAdapter.CalendarClient() != nil {
 		a.calendarService.SetGoogleCalendarClient(a.gmailAdapter.CalendarClient())
 	}
 
-	// Step 8: Emit account switched event
+	// Step 6: Emit account switched event
 	if a.eventBus != nil {
 		a.eventBus.Publish(ports.NewAccountSwitchedEvent(previousEmail, email, accountInfo.ID))
 	}
@@ -503,9 +703,11 @@ func (a *Application) GetRawConfig() *config.Account {
 	return a.account
 }
 
-// GetIMAPAdapter returns the IMAP adapter for direct access (backward compatibility)
+// GetIMAPAdapter returns the IMAP adapter for direct access (backward compatibility).
+// Nil when the account syncs through the Gmail API.
 func (a *Application) GetIMAPAdapter() *adapters.IMAPAdapter {
-	return a.imapAdapter
+	var adapter, _ = a.imapAdapter.(*adapters.IMAPAdapter)
+	return adapter
 }
 
 // GetStorageAdapter returns the storage adapter for direct access (backward compatibility)
@@ -525,10 +727,12 @@ func (a *Application) SetIMAPClient(client interface{}) {
 	if !ok {
 		return
 	}
-	if a.imapAdapter == nil {
+	// Only IMAP accounts: the Gmail API backend has no IMAP connection to share
+	var adapter, isIMAP = a.imapAdapter.(*adapters.IMAPAdapter)
+	if !isIMAP {
 		return
 	}
-	a.imapAdapter.SetClient(imapClient)
+	adapter.SetClient(imapClient)
 }
 
 // Connect connects to the IMAP server
@@ -562,6 +766,11 @@ func (a *Application) ReinitializeGmailAdapter() error {
 	// We need to check for nil before passing to avoid the nil interface gotcha
 	var gmailPort ports.GmailAPIPort = a.gmailAdapter
 	a.sendService.SetGmailAPI(gmailPort)
+	if rt := a.runtimeFor(a.account.Email); rt != nil {
+		rt.gmail = a.gmailAdapter
+		a.sendService.AddIdentity(rt.info, rt.smtpPort(), gmailPort, rt.sendMethod())
+		a.calendarService.SetGoogleCalendar(rt.info.ID, a.gmailAdapter.CalendarClient())
+	}
 
 	// Update the calendar service with the new calendar client
 	if a.gmailAdapter.CalendarClient() != nil {
@@ -689,3 +898,11 @@ func (a *Application) SyncThreadIDsFromGmailWithLimit(ctx context.Context, maxEm
 
 	return updated, nil
 }
+
+// configFile resolves a path from the config file against the config directory
+func configFile(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(config.GetConfigPath(), path)
+}
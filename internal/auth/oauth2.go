@@ -21,7 +21,8 @@ var GmailScopes = []string{
 	"https://www.googleapis.com/auth/gmail.send",              // Gmail API send
 	"https://www.googleapis.com/auth/contacts.readonly",       // People API contacts (read-only)
 	"https://www.googleapis.com/auth/contacts.other.readonly", // People API "Other Contacts"
-	"https://www.googleapis.com/auth/calendar.readonly",       // Google Calendar (read-only)
+	"https://www.googleapis.com/auth/calendar.readonly",       // Google Calendar (lista de calendários)
+	"https://www.googleapis.com/auth/calendar.events",         // Google Calendar (eventos, leitura e escrita)
 }
 
 type OAuth2Config struct {
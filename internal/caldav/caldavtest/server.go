@@ -0,0 +1,262 @@
+// Package caldavtest provides an in-process CalDAV server for tests: one
+// user with a calendar home holding a task list and an event calendar.
+package caldavtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Paths of the server
+const (
+	PrincipalPath = "/principals/me/"
+	HomePath      = "/calendars/me/"
+	TasksPath     = "/calendars/me/tasks/"
+	CalendarPath  = "/calendars/me/personal/"
+)
+
+// Credentials accepted by the server
+const (
+	Username = "me"
+	Password = "secret"
+)
+
+// Server is the fake CalDAV server. It answers PROPFIND (discovery, ctag
+// and listings), REPORT calendar-multiget, GET, PUT and DELETE with
+// If-Match / If-None-Match preconditions.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string]*object // href -> object
+	version int                // source of ETags and ctags
+	ctag    int
+	puts    int
+}
+
+type object struct {
+	data []byte
+	etag string
+}
+
+// NewServer starts a server; Close stops it
+func NewServer() *Server {
+	var s = &Server{objects: map[string]*object{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Add stores an object in the calendar, as another client would, and
+// returns its href and ETag
+func (s *Server) Add(name string, data []byte) (href, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	href = CalendarPath + name
+	return href, s.store(href, data)
+}
+
+// Remove deletes an object, as another client would
+func (s *Server) Remove(href string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, href)
+	s.version++
+	s.ctag = s.version
+}
+
+// Object returns the data and ETag of an object (nil if none)
+func (s *Server) Object(href string) ([]byte, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var o = s.objects[href]
+	if o == nil {
+		return nil, ""
+	}
+	return o.data, o.etag
+}
+
+// Hrefs returns the hrefs of the objects, sorted
+func (s *Server) Hrefs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hrefs = make([]string, 0, len(s.objects))
+	for href := range s.objects {
+		hrefs = append(hrefs, href)
+	}
+	sort.Strings(hrefs)
+	return hrefs
+}
+
+// Puts returns how many PUTs the server accepted
+func (s *Server) Puts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.puts
+}
+
+func (s *Server) store(href string, data []byte) string {
+	s.version++
+	s.ctag = s.version
+	var etag = `"` + strconv.Itoa(s.version) + `"`
+	s.objects[href] = &object{data: data, etag: etag}
+	return etag
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != Username || password != Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case "PROPFIND":
+		s.propfind(w, r)
+	case "REPORT":
+		s.report(w, r)
+	case http.MethodGet:
+		var o = s.objects[r.URL.Path]
+		if o == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", o.etag)
+		w.Write(o.data)
+	case http.MethodPut:
+		var o = s.objects[r.URL.Path]
+		if !s.precondition(w, r, o) {
+			return
+		}
+		var data, _ = io.ReadAll(r.Body)
+		s.puts++
+		w.Header().Set("ETag", s.store(r.URL.Path, data))
+		if o == nil {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case http.MethodDelete:
+		var o = s.objects[r.URL.Path]
+		if o == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if !s.precondition(w, r, o) {
+			return
+		}
+		delete(s.objects, r.URL.Path)
+		s.version++
+		s.ctag = s.version
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// precondition checks If-Match and If-None-Match against the object
+func (s *Server) precondition(w http.ResponseWriter, r *http.Request, o *object) bool {
+	var ifMatch, ifNoneMatch = r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if (ifMatch != "" && (o == nil || ifMatch != o.etag)) || (ifNoneMatch == "*" && o != nil) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// propfind answers with every property the client may ask for; the
+// request body is not read
+func (s *Server) propfind(w http.ResponseWriter, r *http.Request) {
+	var depth = r.Header.Get("Depth")
+	var responses []string
+	switch r.URL.Path {
+	case "/", "/.well-known/caldav":
+		responses = append(responses, response(r.URL.Path, `<D:resourcetype><D:collection/></D:resourcetype>`+
+			`<D:current-user-principal><D:href>`+PrincipalPath+`</D:href></D:current-user-principal>`))
+	case PrincipalPath:
+		responses = append(responses, response(PrincipalPath, `<D:resourcetype><D:principal/></D:resourcetype>`+
+			`<C:calendar-home-set><D:href>`+HomePath+`</D:href></C:calendar-home-set>`))
+	case HomePath:
+		responses = append(responses, response(HomePath, `<D:resourcetype><D:collection/></D:resourcetype>`))
+		if depth == "1" {
+			responses = append(responses,
+				response(TasksPath, calendarProps("VTODO", "1")),
+				response(CalendarPath, calendarProps("VEVENT", strconv.Itoa(s.ctag))))
+		}
+	case CalendarPath:
+		responses = append(responses, response(CalendarPath, calendarProps("VEVENT", strconv.Itoa(s.ctag))))
+		if depth == "1" {
+			var hrefs = make([]string, 0, len(s.objects))
+			for href := range s.objects {
+				hrefs = append(hrefs, href)
+			}
+			sort.Strings(hrefs)
+			for _, href := range hrefs {
+				responses = append(responses, response(href, `<D:resourcetype/><D:getetag>`+escape(s.objects[href].etag)+`</D:getetag>`))
+			}
+		}
+	default:
+		var o = s.objects[r.URL.Path]
+		if o == nil {
+			http.NotFound(w, r)
+			return
+		}
+		responses = append(responses, response(r.URL.Path, `<D:resourcetype/><D:getetag>`+escape(o.etag)+`</D:getetag>`))
+	}
+	multistatus(w, responses)
+}
+
+// report answers a calendar-multiget
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	var query struct {
+		Hrefs []string `xml:"DAV: href"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var responses []string
+	for _, href := range query.Hrefs {
+		var o = s.objects[href]
+		if o == nil {
+			responses = append(responses, `<D:response><D:href>`+escape(href)+`</D:href><D:status>HTTP/1.1 404 Not Found</D:status></D:response>`)
+			continue
+		}
+		responses = append(responses, response(href,
+			`<D:getetag>`+escape(o.etag)+`</D:getetag><C:calendar-data>`+escape(string(o.data))+`</C:calendar-data>`))
+	}
+	multistatus(w, responses)
+}
+
+func calendarProps(component, ctag string) string {
+	return `<D:resourcetype><D:collection/><C:calendar/></D:resourcetype>` +
+		`<C:supported-calendar-component-set><C:comp name="` + component + `"/></C:supported-calendar-component-set>` +
+		`<CS:getctag>` + ctag + `</CS:getctag>`
+}
+
+func response(href, props string) string {
+	return fmt.Sprintf(`<D:response><D:href>%s</D:href><D:propstat><D:prop>%s</D:prop>`+
+		`<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`, escape(href), props)
+}
+
+func multistatus(w http.ResponseWriter, responses []string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+
+		`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:CS="http://calendarserver.org/ns/">`+
+		strings.Join(responses, "")+`</D:multistatus>`)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
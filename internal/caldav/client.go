@@ -0,0 +1,420 @@
+// Package caldav is a CalDAV (RFC 4791) client for the calendar servers
+// miau syncs with: Nextcloud, Radicale, Baïkal, iCloud and the like. It
+// finds the user's calendar from a server, principal or calendar URL, and
+// reads and writes its calendar objects with their ETags, so that changes
+// made on both sides are detected instead of overwritten.
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestTimeout bounds every request
+const requestTimeout = 60 * time.Second
+
+// Object is a calendar object resource: one .ics file of the calendar
+type Object struct {
+	Href string // path on the server, as it names the object
+	ETag string
+	Data []byte // iCalendar; nil when only listed
+}
+
+// HTTPError is a non-2xx response from the server
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("caldav: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// IsPreconditionFailed reports whether a write failed because the object
+// changed on the server since the ETag given (or exists, for a new one)
+func IsPreconditionFailed(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusPreconditionFailed
+}
+
+// IsNotFound reports whether the object is not on the server
+func IsNotFound(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound
+}
+
+// Client talks to a CalDAV server. Authenticate sets the Authorization
+// header of every request.
+type Client struct {
+	httpClient   *http.Client
+	baseURL      *url.URL
+	authenticate func(*http.Request)
+
+	mu         sync.Mutex
+	collection *url.URL
+}
+
+// NewClient creates a client with basic auth. rawURL is the server, the
+// user's principal or the calendar collection itself.
+func NewClient(rawURL, username, password string) (*Client, error) {
+	return NewClientWithHTTP(&http.Client{}, rawURL, func(r *http.Request) { r.SetBasicAuth(username, password) })
+}
+
+// NewClientWithHTTP creates a client on an existing http.Client (tests use
+// an httptest server)
+func NewClientWithHTTP(httpClient *http.Client, rawURL string, authenticate func(*http.Request)) (*Client, error) {
+	var u, err = url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("caldav: invalid URL %q", rawURL)
+	}
+	if authenticate == nil {
+		authenticate = func(*http.Request) {}
+	}
+	return &Client{httpClient: httpClient, baseURL: u, authenticate: authenticate}, nil
+}
+
+// SetCollection sets the calendar collection found by an earlier Discover,
+// skipping discovery
+func (c *Client) SetCollection(rawURL string) error {
+	var u, err = c.baseURL.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	c.setCollection(u)
+	return nil
+}
+
+// Discover finds the calendar collection (RFC 4791 section 6, RFC 6764):
+// the URL itself when it is a calendar, otherwise the first calendar that
+// holds events in the user's calendar home (the URL, or the home of the
+// principal)
+func (c *Client) Discover(ctx context.Context) (string, error) {
+	var start = c.baseURL
+	if strings.Trim(start.Path, "/") == "" {
+		if found, err := c.wellKnown(ctx); err == nil {
+			start = found
+		}
+	}
+
+	var ms, err = c.propfind(ctx, start, "0", discoverProps)
+	if err != nil {
+		return "", fmt.Errorf("failed to discover calendar: %w", err)
+	}
+	var props = ms.first()
+	if props.ResourceType.Calendar != nil {
+		return c.setCollection(start), nil
+	}
+
+	var home = props.CalendarHomeSet.Href
+	if home == "" && props.CurrentUserPrincipal.Href != "" {
+		var principal, err = start.Parse(props.CurrentUserPrincipal.Href)
+		if err != nil {
+			return "", err
+		}
+		var ms, err2 = c.propfind(ctx, principal, "0", discoverProps)
+		if err2 != nil {
+			return "", fmt.Errorf("failed to read principal: %w", err2)
+		}
+		home = ms.first().CalendarHomeSet.Href
+	}
+	if home == "" && props.ResourceType.Collection != nil {
+		// The calendar home itself
+		home = start.String()
+	}
+	if home == "" {
+		return "", fmt.Errorf("caldav: no calendar home at %s", start)
+	}
+
+	var homeURL, err2 = start.Parse(home)
+	if err2 != nil {
+		return "", err2
+	}
+	ms, err = c.propfind(ctx, homeURL, "1", discoverProps)
+	if err != nil {
+		return "", fmt.Errorf("failed to list calendars: %w", err)
+	}
+	for _, r := range ms.Responses {
+		var props = r.props()
+		if props.ResourceType.Calendar == nil || !props.ComponentSet.has("VEVENT") {
+			continue
+		}
+		var calendar, err = homeURL.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		return c.setCollection(calendar), nil
+	}
+	return "", fmt.Errorf("caldav: no event calendar in %s", homeURL)
+}
+
+// wellKnown follows /.well-known/caldav, which redirects to the context
+// path of the server
+func (c *Client) wellKnown(ctx context.Context) (*url.URL, error) {
+	var u, _ = c.baseURL.Parse("/.well-known/caldav")
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err = http.NewRequestWithContext(ctx2, "PROPFIND", u.String(), strings.NewReader(discoverProps))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "0")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	c.authenticate(req)
+
+	// PROPFIND must not become a GET on redirect
+	var noRedirect = *c.httpClient
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	var resp, err2 = noRedirect.Do(req)
+	if err2 != nil {
+		return nil, err2
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != "":
+		return u.Parse(resp.Header.Get("Location"))
+	case resp.StatusCode == http.StatusMultiStatus:
+		return u, nil
+	}
+	return nil, &HTTPError{StatusCode: resp.StatusCode}
+}
+
+// setCollection remembers the calendar collection and returns its URL
+func (c *Client) setCollection(u *url.URL) string {
+	var collection = *u
+	if !strings.HasSuffix(collection.Path, "/") {
+		collection.Path += "/"
+		collection.RawPath = ""
+	}
+	c.mu.Lock()
+	c.collection = &collection
+	c.mu.Unlock()
+	return collection.String()
+}
+
+// current returns the calendar collection, discovering it once
+func (c *Client) current(ctx context.Context) (*url.URL, error) {
+	c.mu.Lock()
+	var collection = c.collection
+	c.mu.Unlock()
+	if collection != nil {
+		return collection, nil
+	}
+	if _, err := c.Discover(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.collection, nil
+}
+
+// CTag returns the collection tag, which changes with every change to the
+// calendar ("" when the server has none)
+func (c *Client) CTag(ctx context.Context) (string, error) {
+	var collection, err = c.current(ctx)
+	if err != nil {
+		return "", err
+	}
+	var ms, err2 = c.propfind(ctx, collection, "0", ctagProps)
+	if err2 != nil {
+		return "", fmt.Errorf("failed to read ctag: %w", err2)
+	}
+	return ms.first().CTag, nil
+}
+
+// List returns the href and ETag of every object of the calendar
+func (c *Client) List(ctx context.Context) ([]Object, error) {
+	var collection, err = c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ms, err2 = c.propfind(ctx, collection, "1", etagProps)
+	if err2 != nil {
+		return nil, fmt.Errorf("failed to list calendar: %w", err2)
+	}
+
+	var objects []Object
+	for _, r := range ms.Responses {
+		var props = r.props()
+		if props.ResourceType.Collection != nil || props.ETag == "" {
+			continue
+		}
+		objects = append(objects, Object{Href: c.path(collection, r.Href), ETag: props.ETag})
+	}
+	return objects, nil
+}
+
+// Get fetches objects by href (calendar-multiget). Objects gone from the
+// server are left out.
+func (c *Client) Get(ctx context.Context, hrefs []string) ([]Object, error) {
+	if len(hrefs) == 0 {
+		return nil, nil
+	}
+	var collection, err = c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">` +
+		`<D:prop><D:getetag/><C:calendar-data/></D:prop>`)
+	for _, href := range hrefs {
+		body.WriteString("<D:href>")
+		xml.EscapeText(&body, []byte(href))
+		body.WriteString("</D:href>")
+	}
+	body.WriteString(`</C:calendar-multiget>`)
+
+	var ms, err2 = c.request(ctx, "REPORT", collection, "1", body.String())
+	if err2 != nil {
+		return nil, fmt.Errorf("failed to fetch events: %w", err2)
+	}
+
+	var objects []Object
+	for _, r := range ms.Responses {
+		var props = r.props()
+		if props.CalendarData == "" {
+			continue
+		}
+		objects = append(objects, Object{Href: c.path(collection, r.Href), ETag: props.ETag, Data: []byte(props.CalendarData)})
+	}
+	return objects, nil
+}
+
+// NewHref returns the href of a new object of the calendar
+func (c *Client) NewHref(ctx context.Context, uid string) (string, error) {
+	var collection, err = c.current(ctx)
+	if err != nil {
+		return "", err
+	}
+	var u = collection.JoinPath(uid + ".ics")
+	return u.EscapedPath(), nil
+}
+
+// Put writes an object and returns its new ETag. With an etag the write
+// only happens if the object is still at that version; without one, only
+// if the object doesn't exist yet. Either way a failed precondition is an
+// HTTPError with status 412 (see IsPreconditionFailed).
+func (c *Client) Put(ctx context.Context, href string, data []byte, etag string) (string, error) {
+	var u, err = c.baseURL.Parse(href)
+	if err != nil {
+		return "", err
+	}
+
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err2 = http.NewRequestWithContext(ctx2, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err2 != nil {
+		return "", err2
+	}
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	} else {
+		req.Header.Set("If-None-Match", "*")
+	}
+
+	var resp, err3 = c.do(req)
+	if err3 != nil {
+		return "", err3
+	}
+	resp.Body.Close()
+
+	// Servers that change the object as they store it send no ETag
+	if newETag := resp.Header.Get("ETag"); newETag != "" {
+		return newETag, nil
+	}
+	var ms, err4 = c.propfind(ctx, u, "0", etagProps)
+	if err4 != nil {
+		return "", fmt.Errorf("failed to read new etag: %w", err4)
+	}
+	return ms.first().ETag, nil
+}
+
+// Delete removes an object, only if it is still at the version etag
+// ("" deletes any version)
+func (c *Client) Delete(ctx context.Context, href, etag string) error {
+	var u, err = c.baseURL.Parse(href)
+	if err != nil {
+		return err
+	}
+
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err2 = http.NewRequestWithContext(ctx2, http.MethodDelete, u.String(), nil)
+	if err2 != nil {
+		return err2
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	var resp, err3 = c.do(req)
+	if err3 != nil {
+		return err3
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// path returns an href of a response as a path on the server
+func (c *Client) path(base *url.URL, href string) string {
+	var u, err = base.Parse(href)
+	if err != nil {
+		return href
+	}
+	return u.EscapedPath()
+}
+
+// propfind sends a PROPFIND with the depth
+func (c *Client) propfind(ctx context.Context, u *url.URL, depth, body string) (*multistatus, error) {
+	return c.request(ctx, "PROPFIND", u, depth, body)
+}
+
+// request sends a WebDAV request and decodes its multistatus response
+func (c *Client) request(ctx context.Context, method string, u *url.URL, depth, body string) (*multistatus, error) {
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err = http.NewRequestWithContext(ctx2, method, u.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	var resp, err2 = c.do(req)
+	if err2 != nil {
+		return nil, err2
+	}
+	defer resp.Body.Close()
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("caldav: invalid %s response: %w", method, err)
+	}
+	return &ms, nil
+}
+
+// do authenticates and sends a request, failing on non-2xx responses
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	c.authenticate(req)
+	var resp, err = c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var body, _ = io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return resp, nil
+}
@@ -0,0 +1,102 @@
+package caldav
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/opik/miau/internal/caldav/caldavtest"
+)
+
+const testEvent = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:test@example.org\r\nDTSTART:20261027T090000Z\r\nSUMMARY:Test\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func newTestClient(t *testing.T, rawURL string) *Client {
+	var client, err = NewClient(rawURL, caldavtest.Username, caldavtest.Password)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+// TestDiscover tests finding the event calendar from each kind of URL
+func TestDiscover(t *testing.T) {
+	var server = caldavtest.NewServer()
+	defer server.Close()
+
+	for _, path := range []string{"", caldavtest.PrincipalPath, caldavtest.HomePath, caldavtest.CalendarPath} {
+		var client = newTestClient(t, server.URL+path)
+		var collection, err = client.Discover(context.Background())
+		if err != nil {
+			t.Errorf("Failed to discover from %q: %v", path, err)
+			continue
+		}
+		if collection != server.URL+caldavtest.CalendarPath {
+			t.Errorf("Expected the event calendar from %q, got %s", path, collection)
+		}
+	}
+
+	var client, _ = NewClient(server.URL, caldavtest.Username, "wrong")
+	var _, err = client.Discover(context.Background())
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401, got %v", err)
+	}
+}
+
+// TestObjects tests reading and writing calendar objects with ETags
+func TestObjects(t *testing.T) {
+	var server = caldavtest.NewServer()
+	defer server.Close()
+	var ctx = context.Background()
+	var client = newTestClient(t, server.URL)
+
+	var remoteHref, _ = server.Add("remote.ics", []byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"))
+	var ctag, err = client.CTag(ctx)
+	if err != nil || ctag == "" {
+		t.Fatalf("Expected a ctag, got %q, %v", ctag, err)
+	}
+
+	var href, _ = client.NewHref(ctx, "new@miau")
+	if href != caldavtest.CalendarPath+"new@miau.ics" {
+		t.Errorf("Unexpected href %s", href)
+	}
+	var etag, errPut = client.Put(ctx, href, []byte(testEvent), "")
+	if errPut != nil || etag == "" {
+		t.Fatalf("Failed to create object: %q, %v", etag, errPut)
+	}
+	if _, err := client.Put(ctx, href, []byte(testEvent), ""); !IsPreconditionFailed(err) {
+		t.Errorf("Expected creating an existing object to fail, got %v", err)
+	}
+	if newCTag, _ := client.CTag(ctx); newCTag == ctag {
+		t.Errorf("Expected the ctag to change")
+	}
+
+	var objects, errList = client.List(ctx)
+	if errList != nil || len(objects) != 2 {
+		t.Fatalf("Expected 2 objects, got %+v, %v", objects, errList)
+	}
+	if objects[0].Href != href || objects[0].ETag != etag || objects[1].Href != remoteHref {
+		t.Errorf("Unexpected listing: %+v", objects)
+	}
+
+	objects, err = client.Get(ctx, []string{href, caldavtest.CalendarPath + "gone.ics"})
+	if err != nil || len(objects) != 1 || string(objects[0].Data) != testEvent || objects[0].ETag != etag {
+		t.Fatalf("Unexpected multiget: %+v, %v", objects, err)
+	}
+
+	// Changed on the server since etag
+	server.Add("new@miau.ics", []byte(testEvent))
+	if _, err := client.Put(ctx, href, []byte(testEvent), etag); !IsPreconditionFailed(err) {
+		t.Errorf("Expected a precondition failure, got %v", err)
+	}
+	if err := client.Delete(ctx, href, etag); !IsPreconditionFailed(err) {
+		t.Errorf("Expected a precondition failure, got %v", err)
+	}
+	if err := client.Delete(ctx, href, ""); err != nil {
+		t.Errorf("Failed to delete: %v", err)
+	}
+	if err := client.Delete(ctx, href, ""); !IsNotFound(err) {
+		t.Errorf("Expected a 404, got %v", err)
+	}
+}
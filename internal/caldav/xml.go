@@ -0,0 +1,92 @@
+package caldav
+
+import "strings"
+
+// PROPFIND bodies
+const (
+	discoverProps = `<?xml version="1.0" encoding="utf-8"?>` +
+		`<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop>` +
+		`<D:resourcetype/><D:current-user-principal/><C:calendar-home-set/><C:supported-calendar-component-set/>` +
+		`</D:prop></D:propfind>`
+	ctagProps = `<?xml version="1.0" encoding="utf-8"?>` +
+		`<D:propfind xmlns:D="DAV:" xmlns:CS="http://calendarserver.org/ns/"><D:prop>` +
+		`<CS:getctag/>` +
+		`</D:prop></D:propfind>`
+	etagProps = `<?xml version="1.0" encoding="utf-8"?>` +
+		`<D:propfind xmlns:D="DAV:"><D:prop>` +
+		`<D:resourcetype/><D:getetag/>` +
+		`</D:prop></D:propfind>`
+)
+
+// multistatus is a 207 Multi-Status response (RFC 4918 section 13)
+type multistatus struct {
+	Responses []response `xml:"DAV: response"`
+}
+
+// first returns the found properties of the first response
+func (ms *multistatus) first() prop {
+	if len(ms.Responses) == 0 {
+		return prop{}
+	}
+	return ms.Responses[0].props()
+}
+
+type response struct {
+	Href      string     `xml:"DAV: href"`
+	Propstats []propstat `xml:"DAV: propstat"`
+}
+
+// props returns the properties the server found (those of the 200
+// propstat; the others list properties it doesn't have)
+func (r *response) props() prop {
+	for _, ps := range r.Propstats {
+		if ps.Status == "" || strings.Contains(ps.Status, " 200") {
+			return ps.Prop
+		}
+	}
+	return prop{}
+}
+
+type propstat struct {
+	Prop   prop   `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type prop struct {
+	ResourceType         resourceType `xml:"DAV: resourcetype"`
+	ETag                 string       `xml:"DAV: getetag"`
+	CurrentUserPrincipal hrefProp     `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      hrefProp     `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	ComponentSet         componentSet `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+	CalendarData         string       `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+	CTag                 string       `xml:"http://calendarserver.org/ns/ getctag"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"DAV: collection"`
+	Calendar   *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+}
+
+type hrefProp struct {
+	Href string `xml:"DAV: href"`
+}
+
+type componentSet struct {
+	Components []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"urn:ietf:params:xml:ns:caldav comp"`
+}
+
+// has reports whether the calendar holds a component type; servers that
+// don't say hold any
+func (s componentSet) has(name string) bool {
+	if len(s.Components) == 0 {
+		return true
+	}
+	for _, c := range s.Components {
+		if strings.EqualFold(c.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,317 @@
+// Package carddavtest provides an in-process CardDAV server for tests: one
+// user with an address book home holding one address book.
+package carddavtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Paths of the server
+const (
+	PrincipalPath   = "/principals/me/"
+	HomePath        = "/addressbooks/me/"
+	AddressbookPath = "/addressbooks/me/contacts/"
+)
+
+// Credentials accepted by the server
+const (
+	Username = "me"
+	Password = "secret"
+)
+
+// tokenPrefix starts the sync tokens, followed by the version they were
+// issued at
+const tokenPrefix = "http://miau.test/sync/"
+
+// Server is the fake CardDAV server. It answers PROPFIND (discovery and
+// listings), REPORT sync-collection and addressbook-multiget, GET, PUT and
+// DELETE with If-Match / If-None-Match preconditions.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	objects  map[string]*object // href -> object
+	removed  map[string]int     // href -> version it was removed at
+	version  int                // source of ETags and sync tokens
+	minToken int                // tokens issued before are invalid
+	puts     int
+}
+
+type object struct {
+	data    []byte
+	etag    string
+	version int // of the last change
+}
+
+// NewServer starts a server; Close stops it
+func NewServer() *Server {
+	var s = &Server{objects: map[string]*object{}, removed: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Add stores an object in the address book, as another client would, and
+// returns its href and ETag
+func (s *Server) Add(name string, data []byte) (href, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	href = AddressbookPath + name
+	return href, s.store(href, data)
+}
+
+// Remove deletes an object, as another client would
+func (s *Server) Remove(href string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(href)
+}
+
+// ExpireTokens makes the server forget the sync tokens issued so far
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.minToken = s.version
+}
+
+// Object returns the data and ETag of an object (nil if none)
+func (s *Server) Object(href string) ([]byte, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var o = s.objects[href]
+	if o == nil {
+		return nil, ""
+	}
+	return o.data, o.etag
+}
+
+// Hrefs returns the hrefs of the objects, sorted
+func (s *Server) Hrefs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hrefs()
+}
+
+// Puts returns how many PUTs the server accepted
+func (s *Server) Puts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.puts
+}
+
+func (s *Server) hrefs() []string {
+	var hrefs = make([]string, 0, len(s.objects))
+	for href := range s.objects {
+		hrefs = append(hrefs, href)
+	}
+	sort.Strings(hrefs)
+	return hrefs
+}
+
+func (s *Server) store(href string, data []byte) string {
+	s.version++
+	var etag = `"` + strconv.Itoa(s.version) + `"`
+	s.objects[href] = &object{data: data, etag: etag, version: s.version}
+	delete(s.removed, href)
+	return etag
+}
+
+func (s *Server) remove(href string) {
+	s.version++
+	delete(s.objects, href)
+	s.removed[href] = s.version
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != Username || password != Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case "PROPFIND":
+		s.propfind(w, r)
+	case "REPORT":
+		s.report(w, r)
+	case http.MethodGet:
+		var o = s.objects[r.URL.Path]
+		if o == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", o.etag)
+		w.Write(o.data)
+	case http.MethodPut:
+		var o = s.objects[r.URL.Path]
+		if !s.precondition(w, r, o) {
+			return
+		}
+		var data, _ = io.ReadAll(r.Body)
+		s.puts++
+		w.Header().Set("ETag", s.store(r.URL.Path, data))
+		if o == nil {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case http.MethodDelete:
+		var o = s.objects[r.URL.Path]
+		if o == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if !s.precondition(w, r, o) {
+			return
+		}
+		s.remove(r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// precondition checks If-Match and If-None-Match against the object
+func (s *Server) precondition(w http.ResponseWriter, r *http.Request, o *object) bool {
+	var ifMatch, ifNoneMatch = r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if (ifMatch != "" && (o == nil || ifMatch != o.etag)) || (ifNoneMatch == "*" && o != nil) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// propfind answers with every property the client may ask for; the
+// request body is not read
+func (s *Server) propfind(w http.ResponseWriter, r *http.Request) {
+	var depth = r.Header.Get("Depth")
+	var responses []string
+	switch r.URL.Path {
+	case "/", "/.well-known/carddav":
+		responses = append(responses, response(r.URL.Path, `<D:resourcetype><D:collection/></D:resourcetype>`+
+			`<D:current-user-principal><D:href>`+PrincipalPath+`</D:href></D:current-user-principal>`))
+	case PrincipalPath:
+		responses = append(responses, response(PrincipalPath, `<D:resourcetype><D:principal/></D:resourcetype>`+
+			`<CR:addressbook-home-set><D:href>`+HomePath+`</D:href></CR:addressbook-home-set>`))
+	case HomePath:
+		responses = append(responses, response(HomePath, `<D:resourcetype><D:collection/></D:resourcetype>`))
+		if depth == "1" {
+			responses = append(responses, response(AddressbookPath, addressbookProps))
+		}
+	case AddressbookPath:
+		responses = append(responses, response(AddressbookPath, addressbookProps))
+		if depth == "1" {
+			for _, href := range s.hrefs() {
+				responses = append(responses, response(href, `<D:resourcetype/><D:getetag>`+escape(s.objects[href].etag)+`</D:getetag>`))
+			}
+		}
+	default:
+		var o = s.objects[r.URL.Path]
+		if o == nil {
+			http.NotFound(w, r)
+			return
+		}
+		responses = append(responses, response(r.URL.Path, `<D:resourcetype/><D:getetag>`+escape(o.etag)+`</D:getetag>`))
+	}
+	multistatus(w, responses, "")
+}
+
+// report answers a sync-collection or an addressbook-multiget
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	var query struct {
+		XMLName   xml.Name
+		SyncToken *string  `xml:"DAV: sync-token"`
+		Hrefs     []string `xml:"DAV: href"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if query.XMLName.Local == "sync-collection" {
+		s.syncCollection(w, *query.SyncToken)
+		return
+	}
+
+	var responses []string
+	for _, href := range query.Hrefs {
+		var o = s.objects[href]
+		if o == nil {
+			responses = append(responses, `<D:response><D:href>`+escape(href)+`</D:href><D:status>HTTP/1.1 404 Not Found</D:status></D:response>`)
+			continue
+		}
+		responses = append(responses, response(href,
+			`<D:getetag>`+escape(o.etag)+`</D:getetag><CR:address-data>`+escape(string(o.data))+`</CR:address-data>`))
+	}
+	multistatus(w, responses, "")
+}
+
+// syncCollection answers the changes since a token (RFC 6578): changed
+// objects with their ETags, removed ones with a 404
+func (s *Server) syncCollection(w http.ResponseWriter, token string) {
+	var since = 0
+	if token != "" {
+		var n, err = strconv.Atoi(strings.TrimPrefix(token, tokenPrefix))
+		if err != nil || !strings.HasPrefix(token, tokenPrefix) || n < s.minToken {
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><D:error xmlns:D="DAV:"><D:valid-sync-token/></D:error>`)
+			return
+		}
+		since = n
+	}
+
+	var responses []string
+	for _, href := range s.hrefs() {
+		if o := s.objects[href]; o.version > since {
+			responses = append(responses, response(href, `<D:getetag>`+escape(o.etag)+`</D:getetag>`))
+		}
+	}
+	if token != "" {
+		var removed []string
+		for href, version := range s.removed {
+			if version > since {
+				removed = append(removed, href)
+			}
+		}
+		sort.Strings(removed)
+		for _, href := range removed {
+			responses = append(responses, `<D:response><D:href>`+escape(href)+`</D:href><D:status>HTTP/1.1 404 Not Found</D:status></D:response>`)
+		}
+	}
+	multistatus(w, responses, tokenPrefix+strconv.Itoa(s.version))
+}
+
+const addressbookProps = `<D:resourcetype><D:collection/><CR:addressbook/></D:resourcetype>`
+
+func response(href, props string) string {
+	return fmt.Sprintf(`<D:response><D:href>%s</D:href><D:propstat><D:prop>%s</D:prop>`+
+		`<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`, escape(href), props)
+}
+
+func multistatus(w http.ResponseWriter, responses []string, syncToken string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	var token string
+	if syncToken != "" {
+		token = `<D:sync-token>` + escape(syncToken) + `</D:sync-token>`
+	}
+	io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+
+		`<D:multistatus xmlns:D="DAV:" xmlns:CR="urn:ietf:params:xml:ns:carddav">`+
+		strings.Join(responses, "")+token+`</D:multistatus>`)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,499 @@
+// Package carddav is a CardDAV (RFC 6352) client for the address books
+// miau syncs contacts with: Nextcloud, Radicale, Baïkal, iCloud, Fastmail
+// and the like. Like package caldav, it finds the user's address book from
+// a server, principal or address book URL and writes vCards with their
+// ETags; changes are read incrementally with WebDAV sync (RFC 6578).
+package carddav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestTimeout bounds every request
+const requestTimeout = 60 * time.Second
+
+// maxPhotoSize bounds the photos downloaded from links
+const maxPhotoSize = 5 * 1024 * 1024
+
+// ErrSyncTokenInvalid is returned by Sync when the server no longer knows
+// the sync token: the address book must be listed again
+var ErrSyncTokenInvalid = errors.New("carddav: sync token no longer valid")
+
+// Object is an address object resource: one .vcf file of the address book
+type Object struct {
+	Href string // path on the server, as it names the object
+	ETag string
+	Data []byte // vCard; nil when only listed
+}
+
+// Changes are the changes of the address book since a sync token
+type Changes struct {
+	Updated   []Object // new or changed objects, without data
+	Removed   []string // hrefs
+	SyncToken string   // for the next Sync ("" if the server has none)
+	Full      bool     // Updated lists every object: the others are gone
+}
+
+// HTTPError is a non-2xx response from the server
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("carddav: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// IsPreconditionFailed reports whether a write failed because the object
+// changed on the server since the ETag given (or exists, for a new one)
+func IsPreconditionFailed(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusPreconditionFailed
+}
+
+// IsNotFound reports whether the object is not on the server
+func IsNotFound(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound
+}
+
+// Client talks to a CardDAV server. Authenticate sets the Authorization
+// header of every request.
+type Client struct {
+	httpClient   *http.Client
+	baseURL      *url.URL
+	authenticate func(*http.Request)
+
+	mu         sync.Mutex
+	collection *url.URL
+}
+
+// NewClient creates a client with basic auth. rawURL is the server, the
+// user's principal or the address book itself.
+func NewClient(rawURL, username, password string) (*Client, error) {
+	return NewClientWithHTTP(&http.Client{}, rawURL, func(r *http.Request) { r.SetBasicAuth(username, password) })
+}
+
+// NewClientWithHTTP creates a client on an existing http.Client (tests use
+// an httptest server)
+func NewClientWithHTTP(httpClient *http.Client, rawURL string, authenticate func(*http.Request)) (*Client, error) {
+	var u, err = url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("carddav: invalid URL %q", rawURL)
+	}
+	if authenticate == nil {
+		authenticate = func(*http.Request) {}
+	}
+	return &Client{httpClient: httpClient, baseURL: u, authenticate: authenticate}, nil
+}
+
+// SetCollection sets the address book found by an earlier Discover,
+// skipping discovery
+func (c *Client) SetCollection(rawURL string) error {
+	var u, err = c.baseURL.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	c.setCollection(u)
+	return nil
+}
+
+// Discover finds the address book (RFC 6352 section 7, RFC 6764): the URL
+// itself when it is one, otherwise the first address book of the user's
+// address book home (the URL, or the home of the principal)
+func (c *Client) Discover(ctx context.Context) (string, error) {
+	var start = c.baseURL
+	if strings.Trim(start.Path, "/") == "" {
+		if found, err := c.wellKnown(ctx); err == nil {
+			start = found
+		}
+	}
+
+	var ms, err = c.request(ctx, "PROPFIND", start, "0", discoverProps)
+	if err != nil {
+		return "", fmt.Errorf("failed to discover address book: %w", err)
+	}
+	var props = ms.first()
+	if props.ResourceType.Addressbook != nil {
+		return c.setCollection(start), nil
+	}
+
+	var home = props.AddressbookHomeSet.Href
+	if home == "" && props.CurrentUserPrincipal.Href != "" {
+		var principal, err = start.Parse(props.CurrentUserPrincipal.Href)
+		if err != nil {
+			return "", err
+		}
+		var ms, err2 = c.request(ctx, "PROPFIND", principal, "0", discoverProps)
+		if err2 != nil {
+			return "", fmt.Errorf("failed to read principal: %w", err2)
+		}
+		home = ms.first().AddressbookHomeSet.Href
+	}
+	if home == "" && props.ResourceType.Collection != nil {
+		// The address book home itself
+		home = start.String()
+	}
+	if home == "" {
+		return "", fmt.Errorf("carddav: no address book home at %s", start)
+	}
+
+	var homeURL, err2 = start.Parse(home)
+	if err2 != nil {
+		return "", err2
+	}
+	ms, err = c.request(ctx, "PROPFIND", homeURL, "1", discoverProps)
+	if err != nil {
+		return "", fmt.Errorf("failed to list address books: %w", err)
+	}
+	for _, r := range ms.Responses {
+		if r.props().ResourceType.Addressbook == nil {
+			continue
+		}
+		var book, err = homeURL.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		return c.setCollection(book), nil
+	}
+	return "", fmt.Errorf("carddav: no address book in %s", homeURL)
+}
+
+// wellKnown follows /.well-known/carddav, which redirects to the context
+// path of the server
+func (c *Client) wellKnown(ctx context.Context) (*url.URL, error) {
+	var u, _ = c.baseURL.Parse("/.well-known/carddav")
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err = http.NewRequestWithContext(ctx2, "PROPFIND", u.String(), strings.NewReader(discoverProps))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "0")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	c.authenticate(req)
+
+	// PROPFIND must not become a GET on redirect
+	var noRedirect = *c.httpClient
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	var resp, err2 = noRedirect.Do(req)
+	if err2 != nil {
+		return nil, err2
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != "":
+		return u.Parse(resp.Header.Get("Location"))
+	case resp.StatusCode == http.StatusMultiStatus:
+		return u, nil
+	}
+	return nil, &HTTPError{StatusCode: resp.StatusCode}
+}
+
+// setCollection remembers the address book and returns its URL
+func (c *Client) setCollection(u *url.URL) string {
+	var collection = *u
+	if !strings.HasSuffix(collection.Path, "/") {
+		collection.Path += "/"
+		collection.RawPath = ""
+	}
+	c.mu.Lock()
+	c.collection = &collection
+	c.mu.Unlock()
+	return collection.String()
+}
+
+// current returns the address book, discovering it once
+func (c *Client) current(ctx context.Context) (*url.URL, error) {
+	c.mu.Lock()
+	var collection = c.collection
+	c.mu.Unlock()
+	if collection != nil {
+		return collection, nil
+	}
+	if _, err := c.Discover(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.collection, nil
+}
+
+// Sync returns the changes since the sync token (a sync-collection
+// report); with no token, every object. Servers without WebDAV sync are
+// listed in full every time. A token the server no longer knows is
+// ErrSyncTokenInvalid.
+func (c *Client) Sync(ctx context.Context, syncToken string) (*Changes, error) {
+	var collection, err = c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes = &Changes{Full: syncToken == "", SyncToken: syncToken}
+	for {
+		var body bytes.Buffer
+		body.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+			`<D:sync-collection xmlns:D="DAV:"><D:sync-token>`)
+		xml.EscapeText(&body, []byte(changes.SyncToken))
+		body.WriteString(`</D:sync-token><D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop></D:sync-collection>`)
+
+		var ms, err = c.request(ctx, "REPORT", collection, "0", body.String())
+		var httpErr *HTTPError
+		switch {
+		case err == nil:
+		case syncToken == "" && errors.As(err, &httpErr):
+			return c.list(ctx, collection)
+		case syncToken != "" && errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusForbidden ||
+			httpErr.StatusCode == http.StatusConflict || httpErr.StatusCode == http.StatusGone ||
+			strings.Contains(httpErr.Body, "valid-sync-token")):
+			return nil, ErrSyncTokenInvalid
+		default:
+			return nil, fmt.Errorf("failed to sync address book: %w", err)
+		}
+
+		// A 507 on the address book itself: the server sent part of the
+		// changes, the rest come with the new token
+		var truncated = false
+		for _, r := range ms.Responses {
+			var href = c.path(collection, r.Href)
+			switch {
+			case href == collection.EscapedPath():
+				truncated = r.hasStatus("507")
+			case r.hasStatus("404"):
+				changes.Removed = append(changes.Removed, href)
+			default:
+				var props = r.props()
+				if props.ResourceType.Collection == nil && props.ETag != "" {
+					changes.Updated = append(changes.Updated, Object{Href: href, ETag: props.ETag})
+				}
+			}
+		}
+		if !truncated || ms.SyncToken == "" || ms.SyncToken == changes.SyncToken {
+			changes.SyncToken = ms.SyncToken
+			return changes, nil
+		}
+		changes.SyncToken = ms.SyncToken
+	}
+}
+
+// list returns every object of the address book, for servers without
+// WebDAV sync
+func (c *Client) list(ctx context.Context, collection *url.URL) (*Changes, error) {
+	var ms, err = c.request(ctx, "PROPFIND", collection, "1", etagProps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list address book: %w", err)
+	}
+	var changes = &Changes{Full: true}
+	for _, r := range ms.Responses {
+		var props = r.props()
+		if props.ResourceType.Collection != nil || props.ETag == "" {
+			continue
+		}
+		changes.Updated = append(changes.Updated, Object{Href: c.path(collection, r.Href), ETag: props.ETag})
+	}
+	return changes, nil
+}
+
+// Get fetches objects by href (addressbook-multiget). Objects gone from
+// the server are left out.
+func (c *Client) Get(ctx context.Context, hrefs []string) ([]Object, error) {
+	if len(hrefs) == 0 {
+		return nil, nil
+	}
+	var collection, err = c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<CR:addressbook-multiget xmlns:D="DAV:" xmlns:CR="urn:ietf:params:xml:ns:carddav">` +
+		`<D:prop><D:getetag/><CR:address-data/></D:prop>`)
+	for _, href := range hrefs {
+		body.WriteString("<D:href>")
+		xml.EscapeText(&body, []byte(href))
+		body.WriteString("</D:href>")
+	}
+	body.WriteString(`</CR:addressbook-multiget>`)
+
+	var ms, err2 = c.request(ctx, "REPORT", collection, "1", body.String())
+	if err2 != nil {
+		return nil, fmt.Errorf("failed to fetch contacts: %w", err2)
+	}
+
+	var objects []Object
+	for _, r := range ms.Responses {
+		var props = r.props()
+		if props.AddressData == "" {
+			continue
+		}
+		objects = append(objects, Object{Href: c.path(collection, r.Href), ETag: props.ETag, Data: []byte(props.AddressData)})
+	}
+	return objects, nil
+}
+
+// NewHref returns the href of a new object of the address book
+func (c *Client) NewHref(ctx context.Context, uid string) (string, error) {
+	var collection, err = c.current(ctx)
+	if err != nil {
+		return "", err
+	}
+	var u = collection.JoinPath(uid + ".vcf")
+	return u.EscapedPath(), nil
+}
+
+// Put writes an object and returns its new ETag. With an etag the write
+// only happens if the object is still at that version; without one, only
+// if the object doesn't exist yet. Either way a failed precondition is an
+// HTTPError with status 412 (see IsPreconditionFailed).
+func (c *Client) Put(ctx context.Context, href string, data []byte, etag string) (string, error) {
+	var u, err = c.baseURL.Parse(href)
+	if err != nil {
+		return "", err
+	}
+
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err2 = http.NewRequestWithContext(ctx2, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err2 != nil {
+		return "", err2
+	}
+	req.Header.Set("Content-Type", "text/vcard; charset=utf-8")
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	} else {
+		req.Header.Set("If-None-Match", "*")
+	}
+
+	var resp, err3 = c.do(req)
+	if err3 != nil {
+		return "", err3
+	}
+	resp.Body.Close()
+
+	// Servers that change the object as they store it send no ETag
+	if newETag := resp.Header.Get("ETag"); newETag != "" {
+		return newETag, nil
+	}
+	var ms, err4 = c.request(ctx, "PROPFIND", u, "0", etagProps)
+	if err4 != nil {
+		return "", fmt.Errorf("failed to read new etag: %w", err4)
+	}
+	return ms.first().ETag, nil
+}
+
+// Delete removes an object, only if it is still at the version etag
+// ("" deletes any version)
+func (c *Client) Delete(ctx context.Context, href, etag string) error {
+	var u, err = c.baseURL.Parse(href)
+	if err != nil {
+		return err
+	}
+
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err2 = http.NewRequestWithContext(ctx2, http.MethodDelete, u.String(), nil)
+	if err2 != nil {
+		return err2
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	var resp, err3 = c.do(req)
+	if err3 != nil {
+		return err3
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Download fetches the photo a vCard links to. Only links to the server
+// itself are sent the credentials.
+func (c *Client) Download(ctx context.Context, rawURL string) ([]byte, error) {
+	var u, err = c.baseURL.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err2 = http.NewRequestWithContext(ctx2, http.MethodGet, u.String(), nil)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	var resp *http.Response
+	if u.Host == c.baseURL.Host {
+		resp, err = c.do(req)
+	} else {
+		resp, err = c.httpClient.Do(req)
+		if err == nil && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+			resp.Body.Close()
+			return nil, &HTTPError{StatusCode: resp.StatusCode}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(io.LimitReader(resp.Body, maxPhotoSize))
+}
+
+// path returns an href of a response as a path on the server
+func (c *Client) path(base *url.URL, href string) string {
+	var u, err = base.Parse(href)
+	if err != nil {
+		return href
+	}
+	return u.EscapedPath()
+}
+
+// request sends a WebDAV request and decodes its multistatus response
+func (c *Client) request(ctx context.Context, method string, u *url.URL, depth, body string) (*multistatus, error) {
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err = http.NewRequestWithContext(ctx2, method, u.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	var resp, err2 = c.do(req)
+	if err2 != nil {
+		return nil, err2
+	}
+	defer resp.Body.Close()
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("carddav: invalid %s response: %w", method, err)
+	}
+	return &ms, nil
+}
+
+// do authenticates and sends a request, failing on non-2xx responses
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	c.authenticate(req)
+	var resp, err = c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var body, _ = io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return resp, nil
+}
@@ -0,0 +1,124 @@
+package carddav
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/opik/miau/internal/carddav/carddavtest"
+)
+
+const testCard = "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:test@example.org\r\nFN:Test\r\nEND:VCARD\r\n"
+
+func newTestClient(t *testing.T, rawURL string) *Client {
+	var client, err = NewClient(rawURL, carddavtest.Username, carddavtest.Password)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+// TestDiscover tests finding the address book from each kind of URL
+func TestDiscover(t *testing.T) {
+	var server = carddavtest.NewServer()
+	defer server.Close()
+
+	for _, path := range []string{"", carddavtest.PrincipalPath, carddavtest.HomePath, carddavtest.AddressbookPath} {
+		var client = newTestClient(t, server.URL+path)
+		var collection, err = client.Discover(context.Background())
+		if err != nil {
+			t.Errorf("Failed to discover from %q: %v", path, err)
+			continue
+		}
+		if collection != server.URL+carddavtest.AddressbookPath {
+			t.Errorf("Expected the address book from %q, got %s", path, collection)
+		}
+	}
+
+	var client, _ = NewClient(server.URL, carddavtest.Username, "wrong")
+	var _, err = client.Discover(context.Background())
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401, got %v", err)
+	}
+}
+
+// TestSync tests reading the changes of the address book by sync token
+func TestSync(t *testing.T) {
+	var server = carddavtest.NewServer()
+	defer server.Close()
+	var ctx = context.Background()
+	var client = newTestClient(t, server.URL)
+
+	var aliceHref, aliceETag = server.Add("alice.vcf", []byte(testCard))
+	var bobHref, _ = server.Add("bob.vcf", []byte(testCard))
+
+	var changes, err = client.Sync(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if !changes.Full || len(changes.Updated) != 2 || changes.Updated[0].Href != aliceHref || changes.Updated[0].ETag != aliceETag || changes.SyncToken == "" {
+		t.Fatalf("Unexpected first sync: %+v", changes)
+	}
+
+	var token = changes.SyncToken
+	if changes, err = client.Sync(ctx, token); err != nil || len(changes.Updated) != 0 || len(changes.Removed) != 0 || changes.Full {
+		t.Errorf("Expected no changes, got %+v, %v", changes, err)
+	}
+
+	server.Add("bob.vcf", []byte(testCard))
+	server.Remove(aliceHref)
+	changes, err = client.Sync(ctx, token)
+	if err != nil || len(changes.Updated) != 1 || changes.Updated[0].Href != bobHref || len(changes.Removed) != 1 || changes.Removed[0] != aliceHref {
+		t.Errorf("Unexpected changes: %+v, %v", changes, err)
+	}
+
+	server.ExpireTokens()
+	if _, err := client.Sync(ctx, changes.SyncToken); !errors.Is(err, ErrSyncTokenInvalid) {
+		t.Errorf("Expected an invalid token, got %v", err)
+	}
+}
+
+// TestObjects tests reading and writing vCards with ETags
+func TestObjects(t *testing.T) {
+	var server = carddavtest.NewServer()
+	defer server.Close()
+	var ctx = context.Background()
+	var client = newTestClient(t, server.URL)
+
+	var href, _ = client.NewHref(ctx, "new@miau")
+	if href != carddavtest.AddressbookPath+"new@miau.vcf" {
+		t.Errorf("Unexpected href %s", href)
+	}
+	var etag, errPut = client.Put(ctx, href, []byte(testCard), "")
+	if errPut != nil || etag == "" {
+		t.Fatalf("Failed to create object: %q, %v", etag, errPut)
+	}
+	if _, err := client.Put(ctx, href, []byte(testCard), ""); !IsPreconditionFailed(err) {
+		t.Errorf("Expected creating an existing object to fail, got %v", err)
+	}
+
+	var objects, err = client.Get(ctx, []string{href, carddavtest.AddressbookPath + "gone.vcf"})
+	if err != nil || len(objects) != 1 || string(objects[0].Data) != testCard || objects[0].ETag != etag {
+		t.Fatalf("Unexpected multiget: %+v, %v", objects, err)
+	}
+	if data, err := client.Download(ctx, href); err != nil || string(data) != testCard {
+		t.Errorf("Unexpected download: %q, %v", data, err)
+	}
+
+	// Changed on the server since etag
+	server.Add("new@miau.vcf", []byte(testCard))
+	if _, err := client.Put(ctx, href, []byte(testCard), etag); !IsPreconditionFailed(err) {
+		t.Errorf("Expected a precondition failure, got %v", err)
+	}
+	if err := client.Delete(ctx, href, etag); !IsPreconditionFailed(err) {
+		t.Errorf("Expected a precondition failure, got %v", err)
+	}
+	if err := client.Delete(ctx, href, ""); err != nil {
+		t.Errorf("Failed to delete: %v", err)
+	}
+	if err := client.Delete(ctx, href, ""); !IsNotFound(err) {
+		t.Errorf("Expected a 404, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package carddav
+
+import "strings"
+
+// PROPFIND bodies
+const (
+	discoverProps = `<?xml version="1.0" encoding="utf-8"?>` +
+		`<D:propfind xmlns:D="DAV:" xmlns:CR="urn:ietf:params:xml:ns:carddav"><D:prop>` +
+		`<D:resourcetype/><D:current-user-principal/><CR:addressbook-home-set/>` +
+		`</D:prop></D:propfind>`
+	etagProps = `<?xml version="1.0" encoding="utf-8"?>` +
+		`<D:propfind xmlns:D="DAV:"><D:prop>` +
+		`<D:resourcetype/><D:getetag/>` +
+		`</D:prop></D:propfind>`
+)
+
+// multistatus is a 207 Multi-Status response (RFC 4918 section 13); a
+// sync-collection report adds the new sync token (RFC 6578)
+type multistatus struct {
+	Responses []response `xml:"DAV: response"`
+	SyncToken string     `xml:"DAV: sync-token"`
+}
+
+// first returns the found properties of the first response
+func (ms *multistatus) first() prop {
+	if len(ms.Responses) == 0 {
+		return prop{}
+	}
+	return ms.Responses[0].props()
+}
+
+type response struct {
+	Href      string     `xml:"DAV: href"`
+	Status    string     `xml:"DAV: status"` // a member removed since the sync token: 404
+	Propstats []propstat `xml:"DAV: propstat"`
+}
+
+// props returns the properties the server found (those of the 200
+// propstat; the others list properties it doesn't have)
+func (r *response) props() prop {
+	for _, ps := range r.Propstats {
+		if ps.Status == "" || strings.Contains(ps.Status, " 200") {
+			return ps.Prop
+		}
+	}
+	return prop{}
+}
+
+// hasStatus reports whether the response status has the code
+func (r *response) hasStatus(code string) bool {
+	return strings.Contains(r.Status, " "+code)
+}
+
+type propstat struct {
+	Prop   prop   `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type prop struct {
+	ResourceType         resourceType `xml:"DAV: resourcetype"`
+	ETag                 string       `xml:"DAV: getetag"`
+	CurrentUserPrincipal hrefProp     `xml:"DAV: current-user-principal"`
+	AddressbookHomeSet   hrefProp     `xml:"urn:ietf:params:xml:ns:carddav addressbook-home-set"`
+	AddressData          string       `xml:"urn:ietf:params:xml:ns:carddav address-data"`
+}
+
+type resourceType struct {
+	Collection  *struct{} `xml:"DAV: collection"`
+	Addressbook *struct{} `xml:"urn:ietf:params:xml:ns:carddav addressbook"`
+}
+
+type hrefProp struct {
+	Href string `xml:"DAV: href"`
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
@@ -22,6 +23,15 @@ const (
 	SendMethodGmailAPI SendMethod = "gmail_api"
 )
 
+// SyncBackend define por onde os emails são sincronizados
+type SyncBackend string
+
+const (
+	SyncBackendIMAP     SyncBackend = "imap"
+	SyncBackendGmailAPI SyncBackend = "gmail_api" // só com auth_type oauth2
+	SyncBackendJMAP     SyncBackend = "jmap"      // Fastmail, Stalwart... (ver JMAPConfig)
+)
+
 type OAuth2Config struct {
 	ClientID     string `yaml:"client_id" mapstructure:"client_id"`
 	ClientSecret string `yaml:"client_secret" mapstructure:"client_secret"`
@@ -38,6 +48,63 @@ type SMTPConfig struct {
 	Port int    `yaml:"port" mapstructure:"port"`
 }
 
+// JMAPConfig aponta para o servidor JMAP (RFC 8620). URL é o recurso de
+// sessão (ex: https://api.fastmail.com/jmap/session) ou só o host, que é
+// resolvido por /.well-known/jmap. Sem token, usa email e password (basic auth).
+type JMAPConfig struct {
+	URL   string `yaml:"url" mapstructure:"url"`
+	Token string `yaml:"token,omitempty" mapstructure:"token"`
+}
+
+// CalDAVConfig aponta para o calendário CalDAV (Nextcloud, Radicale, iCloud...)
+// sincronizado com o calendário do miau. URL pode ser o servidor, o principal
+// ou o próprio calendário. Username e Password têm como padrão o email e a
+// senha da conta (no iCloud, use uma senha de app).
+type CalDAVConfig struct {
+	URL      string `yaml:"url" mapstructure:"url"`
+	Username string `yaml:"username,omitempty" mapstructure:"username"`
+	Password string `yaml:"password,omitempty" mapstructure:"password"`
+}
+
+// CardDAVConfig aponta para o catálogo de endereços CardDAV (Nextcloud,
+// Radicale, iCloud, Fastmail...) sincronizado com os contatos do miau, como
+// CalDAVConfig: URL pode ser o servidor, o principal ou o próprio catálogo,
+// e o login tem como padrão o email e a senha da conta.
+type CardDAVConfig struct {
+	URL      string `yaml:"url" mapstructure:"url"`
+	Username string `yaml:"username,omitempty" mapstructure:"username"`
+	Password string `yaml:"password,omitempty" mapstructure:"password"`
+}
+
+// SieveConfig aponta para o servidor ManageSieve (padrão: host IMAP, porta 4190)
+type SieveConfig struct {
+	Host   string `yaml:"host,omitempty" mapstructure:"host"`
+	Port   int    `yaml:"port,omitempty" mapstructure:"port"`
+	Script string `yaml:"script,omitempty" mapstructure:"script"` // nome do script enviado (padrão "miau")
+}
+
+// PGPConfig escolhe a chave OpenPGP da conta no keyring local
+// (~/.config/miau/pgp). Key é fingerprint, key ID ou email (padrão: email da
+// conta). Sign e Encrypt ligam assinatura/criptografia em todo envio.
+type PGPConfig struct {
+	Key        string `yaml:"key,omitempty" mapstructure:"key"`
+	Passphrase string `yaml:"passphrase,omitempty" mapstructure:"passphrase"`
+	Sign       bool   `yaml:"sign,omitempty" mapstructure:"sign"`
+	Encrypt    bool   `yaml:"encrypt,omitempty" mapstructure:"encrypt"`
+}
+
+// SMIMEConfig aponta para a identidade S/MIME da conta: um arquivo PKCS#12
+// (.p12/.pfx) com chave privada, certificado e cadeia. CA é um PEM opcional
+// com CAs extras confiáveis (ex: a CA interna da empresa). Sign e Encrypt
+// ligam assinatura/criptografia em todo envio.
+type SMIMEConfig struct {
+	Identity string `yaml:"identity" mapstructure:"identity"`
+	Password string `yaml:"password,omitempty" mapstructure:"password"`
+	CA       string `yaml:"ca,omitempty" mapstructure:"ca"`
+	Sign     bool   `yaml:"sign,omitempty" mapstructure:"sign"`
+	Encrypt  bool   `yaml:"encrypt,omitempty" mapstructure:"encrypt"`
+}
+
 type SignatureConfig struct {
 	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
 	HTML    string `yaml:"html" mapstructure:"html"`
@@ -45,15 +112,22 @@ type SignatureConfig struct {
 }
 
 type Account struct {
-	Name       string           `yaml:"name" mapstructure:"name"`
-	Email      string           `yaml:"email" mapstructure:"email"`
-	AuthType   AuthType         `yaml:"auth_type" mapstructure:"auth_type"`
-	Password   string           `yaml:"password,omitempty" mapstructure:"password"`
-	OAuth2     *OAuth2Config    `yaml:"oauth2,omitempty" mapstructure:"oauth2"`
-	IMAP       ImapConfig       `yaml:"imap" mapstructure:"imap"`
-	SMTP       SMTPConfig       `yaml:"smtp,omitempty" mapstructure:"smtp"`
-	SendMethod SendMethod       `yaml:"send_method,omitempty" mapstructure:"send_method"`
-	Signature  *SignatureConfig `yaml:"signature,omitempty" mapstructure:"signature"`
+	Name        string           `yaml:"name" mapstructure:"name"`
+	Email       string           `yaml:"email" mapstructure:"email"`
+	AuthType    AuthType         `yaml:"auth_type" mapstructure:"auth_type"`
+	Password    string           `yaml:"password,omitempty" mapstructure:"password"`
+	OAuth2      *OAuth2Config    `yaml:"oauth2,omitempty" mapstructure:"oauth2"`
+	IMAP        ImapConfig       `yaml:"imap" mapstructure:"imap"`
+	SMTP        SMTPConfig       `yaml:"smtp,omitempty" mapstructure:"smtp"`
+	SendMethod  SendMethod       `yaml:"send_method,omitempty" mapstructure:"send_method"`
+	SyncBackend SyncBackend      `yaml:"sync_backend,omitempty" mapstructure:"sync_backend"`
+	JMAP        *JMAPConfig      `yaml:"jmap,omitempty" mapstructure:"jmap"`
+	Signature   *SignatureConfig `yaml:"signature,omitempty" mapstructure:"signature"`
+	Sieve       *SieveConfig     `yaml:"sieve,omitempty" mapstructure:"sieve"`
+	CalDAV      *CalDAVConfig    `yaml:"caldav,omitempty" mapstructure:"caldav"`
+	CardDAV     *CardDAVConfig   `yaml:"carddav,omitempty" mapstructure:"carddav"`
+	PGP         *PGPConfig       `yaml:"pgp,omitempty" mapstructure:"pgp"`
+	SMIME       *SMIMEConfig     `yaml:"smime,omitempty" mapstructure:"smime"`
 }
 
 type StorageConfig struct {
@@ -64,6 +138,7 @@ type StorageConfig struct {
 type SyncConfig struct {
 	Interval    string `yaml:"interval" mapstructure:"interval"`
 	InitialDays int    `yaml:"initial_days" mapstructure:"initial_days"`
+	Idle        bool   `yaml:"idle" mapstructure:"idle"` // push via IMAP IDLE na INBOX
 }
 
 type UIConfig struct {
@@ -76,6 +151,22 @@ type UIConfig struct {
 type ComposeConfig struct {
 	Format           string `yaml:"format" mapstructure:"format"`                       // "html" ou "plain"
 	SendDelaySeconds int    `yaml:"send_delay_seconds" mapstructure:"send_delay_seconds"` // 0-60, default 30
+	FollowUpNudge    bool   `yaml:"follow_up_nudge" mapstructure:"follow_up_nudge"`       // follow-ups geram rascunho de cobrança com IA
+}
+
+// MaxSendDelaySeconds é o maior valor aceito em send_delay_seconds
+const MaxSendDelaySeconds = 60
+
+// SendDelay retorna a janela de "desfazer envio", limitada a 0-60 segundos
+func (c ComposeConfig) SendDelay() time.Duration {
+	var seconds = c.SendDelaySeconds
+	if seconds < 0 {
+		seconds = 0
+	}
+	if seconds > MaxSendDelaySeconds {
+		seconds = MaxSendDelaySeconds
+	}
+	return time.Duration(seconds) * time.Second
 }
 
 // BasecampConfig holds Basecamp API integration settings
@@ -86,6 +177,25 @@ type BasecampConfig struct {
 	AccountID    string `yaml:"account_id" mapstructure:"account_id"` // Basecamp account ID (number)
 }
 
+// AIConfig selects the AI provider used by summaries, replies and the AI chat
+type AIConfig struct {
+	Provider    string   `yaml:"provider" mapstructure:"provider"`                 // claude, gemini, cli, anthropic, openai, ollama
+	Model       string   `yaml:"model,omitempty" mapstructure:"model"`             // vazio = padrão do provider
+	BaseURL     string   `yaml:"base_url,omitempty" mapstructure:"base_url"`       // API compatível / servidor local
+	APIKey      string   `yaml:"api_key,omitempty" mapstructure:"api_key"`         // ou ANTHROPIC_API_KEY / OPENAI_API_KEY
+	Timeout     string   `yaml:"timeout" mapstructure:"timeout"`                   // ex: "2m"
+	MaxTokens   int      `yaml:"max_tokens" mapstructure:"max_tokens"`             // limite de tokens da resposta
+	Temperature float64  `yaml:"temperature,omitempty" mapstructure:"temperature"` // 0 = padrão do provider
+	Command     string   `yaml:"command,omitempty" mapstructure:"command"`         // provider "cli": executável
+	Args        []string `yaml:"args,omitempty" mapstructure:"args"`               // provider "cli": argumentos (prompt via stdin)
+}
+
+// ServerConfig configures the local REST API started by "miau serve"
+type ServerConfig struct {
+	Addr  string `yaml:"addr" mapstructure:"addr"`             // apenas loopback, ex: "127.0.0.1:8421"
+	Token string `yaml:"token,omitempty" mapstructure:"token"` // vazio = MIAU_SERVER_TOKEN ou server.token
+}
+
 type Config struct {
 	Accounts       []Account       `yaml:"accounts" mapstructure:"accounts"`
 	CurrentAccount string          `yaml:"current_account,omitempty" mapstructure:"current_account"` // Email of current account
@@ -93,6 +203,8 @@ type Config struct {
 	Sync           SyncConfig      `yaml:"sync" mapstructure:"sync"`
 	UI             UIConfig        `yaml:"ui" mapstructure:"ui"`
 	Compose        ComposeConfig   `yaml:"compose" mapstructure:"compose"`
+	AI             AIConfig        `yaml:"ai" mapstructure:"ai"`
+	Server         ServerConfig    `yaml:"server" mapstructure:"server"`
 	Basecamp       *BasecampConfig `yaml:"basecamp,omitempty" mapstructure:"basecamp"`
 }
 
@@ -126,12 +238,17 @@ func Load() (*Config, error) {
 	viper.SetDefault("storage.database", filepath.Join(GetConfigPath(), "data", "miau.db"))
 	viper.SetDefault("sync.interval", "5m")
 	viper.SetDefault("sync.initial_days", 30)
+	viper.SetDefault("sync.idle", true)
 	viper.SetDefault("ui.theme", "dark")
 	viper.SetDefault("ui.show_preview", true)
 	viper.SetDefault("ui.page_size", 50)
 	viper.SetDefault("ui.debug", false)
 	viper.SetDefault("compose.format", "html")
 	viper.SetDefault("compose.send_delay_seconds", 30)
+	viper.SetDefault("ai.provider", "claude")
+	viper.SetDefault("ai.timeout", "2m")
+	viper.SetDefault("ai.max_tokens", 2048)
+	viper.SetDefault("server.addr", "127.0.0.1:8421")
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
@@ -148,6 +265,20 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// ActiveAccount retorna a conta atual (current_account) ou a primeira.
+// Retorna nil se não houver contas.
+func (c *Config) ActiveAccount() *Account {
+	if len(c.Accounts) == 0 {
+		return nil
+	}
+	for i := range c.Accounts {
+		if c.Accounts[i].Email == c.CurrentAccount {
+			return &c.Accounts[i]
+		}
+	}
+	return &c.Accounts[0]
+}
+
 func Save(c *Config) error {
 	var configPath = GetConfigPath()
 	if err := os.MkdirAll(configPath, 0700); err != nil {
@@ -172,6 +303,7 @@ func DefaultConfig() *Config {
 		Sync: SyncConfig{
 			Interval:    "5m",
 			InitialDays: 30,
+			Idle:        true,
 		},
 		UI: UIConfig{
 			Theme:       "dark",
@@ -183,6 +315,14 @@ func DefaultConfig() *Config {
 			Format:           "html",
 			SendDelaySeconds: 30,
 		},
+		AI: AIConfig{
+			Provider:  "claude",
+			Timeout:   "2m",
+			MaxTokens: 2048,
+		},
+		Server: ServerConfig{
+			Addr: "127.0.0.1:8421",
+		},
 		Basecamp: nil, // Disabled by default
 	}
 }
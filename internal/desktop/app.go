@@ -57,16 +57,8 @@ func (a *App) startup() {
 	}
 
 	// Find the current account (saved preference or first account)
-	a.account = &a.cfg.Accounts[0] // default to first
-	if a.cfg.CurrentAccount != "" {
-		for i := range a.cfg.Accounts {
-			if a.cfg.Accounts[i].Email == a.cfg.CurrentAccount {
-				a.account = &a.cfg.Accounts[i]
-				slog.Info("Using saved current account", "email", a.account.Email)
-				break
-			}
-		}
-	}
+	a.account = a.cfg.ActiveAccount()
+	slog.Info("Using account", "email", a.account.Email)
 
 	// Create application instance
 	a.application, err = app.New(a.cfg, a.account, false)
@@ -81,6 +73,12 @@ func (a *App) startup() {
 		return
 	}
 
+	// Keep the other accounts synced for the unified inbox
+	a.application.StartBackgroundSync()
+
+	// Pull Gmail labels in the background (OAuth2 accounts only)
+	go a.syncLabels()
+
 	// Pre-load signature to avoid crash when opening compose modal
 	// This moves the API call to startup instead of UI interaction
 	go func() {
@@ -117,45 +115,51 @@ func (a *App) setupEventForwarding() {
 		}
 
 		switch e := evt.(type) {
-		case *ports.NewEmailEvent:
+		case ports.NewEmailEvent:
 			a.wailsApp.Event.Emit("email:new", a.emailMetadataToDTO(&e.Email))
-		case *ports.EmailReadEvent:
+		case ports.EmailReadEvent:
 			a.wailsApp.Event.Emit("email:read", e.EmailID, e.Read)
-		case *ports.SyncStartedEvent:
+		case ports.SyncStartedEvent:
 			a.wailsApp.Event.Emit("sync:started", e.Folder)
-		case *ports.SyncCompletedEvent:
+		case ports.SyncCompletedEvent:
 			var newCount = 0
 			if e.Result != nil {
 				newCount = e.Result.NewEmails
 			}
 			a.wailsApp.Event.Emit("sync:completed", e.Folder, newCount)
-		case *ports.SyncErrorEvent:
+		case ports.SyncErrorEvent:
 			a.wailsApp.Event.Emit("sync:error", e.Error.Error())
-		case *ports.ConnectedEvent:
+		case ports.UIDValidityChangedEvent:
+			a.wailsApp.Event.Emit("sync:uidvalidity", e.Folder, e.Rematched, e.Orphaned, e.Fetched)
+		case ports.ConnectedEvent:
 			a.mu.Lock()
 			a.connected = true
 			a.mu.Unlock()
 			a.wailsApp.Event.Emit("connection:connected")
-		case *ports.DisconnectedEvent:
+		case ports.DisconnectedEvent:
 			a.mu.Lock()
 			a.connected = false
 			a.mu.Unlock()
 			a.wailsApp.Event.Emit("connection:disconnected", e.Reason)
-		case *ports.ConnectErrorEvent:
+		case ports.ConnectErrorEvent:
 			a.wailsApp.Event.Emit("connection:error", e.Error.Error())
-		case *ports.SendCompletedEvent:
+		case ports.SendCompletedEvent:
 			var messageID = ""
 			if e.Result != nil {
 				messageID = e.Result.MessageID
 			}
 			a.wailsApp.Event.Emit("send:completed", messageID)
-		case *ports.BounceEvent:
+		case ports.OutboxChangedEvent:
+			a.wailsApp.Event.Emit("outbox:changed", e.DraftID, string(e.Status))
+		case ports.FollowUpDueEvent:
+			a.wailsApp.Event.Emit("followup:due", e.FollowUp.ID, e.FollowUp.Subject, e.FollowUp.EmailID)
+		case ports.BounceEvent:
 			a.wailsApp.Event.Emit("bounce:detected", e.Bounce.OriginalMessageID, e.Bounce.Reason)
-		case *ports.BatchCreatedEvent:
+		case ports.BatchCreatedEvent:
 			if e.Operation != nil {
 				a.wailsApp.Event.Emit("batch:created", e.Operation.ID, e.Operation.Description)
 			}
-		case *ports.IndexProgressEvent:
+		case ports.IndexProgressEvent:
 			a.wailsApp.Event.Emit("index:progress", e.Current, e.Total)
 		case ports.AccountSwitchedEvent:
 			a.wailsApp.Event.Emit("account:switched", e.NewEmail, e.NewAccountID)
@@ -181,6 +185,10 @@ func (a *App) emailMetadataToDTO(email *ports.EmailMetadata) EmailDTO {
 		Snippet:        email.Snippet,
 		ThreadID:       email.ThreadID,
 		ThreadCount:    email.ThreadCount,
+		AccountID:      email.AccountID,
+		AccountEmail:   email.AccountEmail,
+		Labels:         email.Labels,
+		RiskLevel:      string(email.RiskLevel),
 	}
 }
 
@@ -224,6 +232,79 @@ func (a *App) emailContentToDTO(email *ports.EmailContent) *EmailDetailDTO {
 		BodyText:     email.BodyText,
 		BodyHTML:     email.BodyHTML,
 		Attachments:  attachments,
+		Security:     securityToDTO(email.Security),
+		Risk:         riskToDTO(email.Risk),
+		Invite:       inviteToDTO(email.Invite),
+	}
+}
+
+// inviteToDTO converts the meeting invite of an email
+func inviteToDTO(invite *ports.Invite) *InviteDTO {
+	if invite == nil {
+		return nil
+	}
+	var dto = &InviteDTO{
+		EventID:     invite.EventID,
+		Method:      invite.Method,
+		Kind:        invite.Kind,
+		Title:       invite.Title,
+		Description: invite.Description,
+		Location:    invite.Location,
+		Start:       invite.Start,
+		End:         invite.End,
+		AllDay:      invite.AllDay,
+		TimeZone:    invite.TimeZone,
+		RRule:       invite.RRule,
+		Organizer:   inviteAttendeeToDTO(invite.Organizer),
+		Attendees:   []InviteAttendeeDTO{},
+		Cancelled:   invite.Cancelled,
+		Outdated:    invite.Outdated,
+		Response:    string(invite.Response),
+		CanRespond:  invite.CanRespond,
+	}
+	for _, a := range invite.Attendees {
+		dto.Attendees = append(dto.Attendees, inviteAttendeeToDTO(a))
+	}
+	return dto
+}
+
+func inviteAttendeeToDTO(a ports.InviteAttendee) InviteAttendeeDTO {
+	return InviteAttendeeDTO{Email: a.Email, Name: a.Name, Response: string(a.Response), Optional: a.Optional}
+}
+
+// riskToDTO converts the risk analysis of an email
+func riskToDTO(risk *ports.RiskInfo) *RiskDTO {
+	if risk == nil {
+		return nil
+	}
+	var dto = &RiskDTO{
+		Score:        risk.Score,
+		Level:        string(risk.Level),
+		SPF:          risk.SPF,
+		DKIM:         risk.DKIM,
+		DMARC:        risk.DMARC,
+		DKIMVerified: risk.DKIMVerified,
+		Findings:     []RiskFindingDTO{},
+	}
+	for _, f := range risk.Findings {
+		dto.Findings = append(dto.Findings, RiskFindingDTO{Reason: f.Reason, Detail: f.Detail})
+	}
+	return dto
+}
+
+// securityToDTO converts the PGP/MIME or S/MIME status of an email
+func securityToDTO(sec *ports.SecurityInfo) *SecurityDTO {
+	if sec == nil {
+		return nil
+	}
+	return &SecurityDTO{
+		Protocol:  string(sec.Protocol),
+		Encrypted: sec.Encrypted,
+		Signature: string(sec.Signature),
+		Signer:    sec.Signer,
+		KeyID:     sec.KeyID,
+		Chain:     string(sec.Chain),
+		Error:     sec.Error,
 	}
 }
 
@@ -488,6 +569,9 @@ func (a *App) SetCurrentAccount(email string) error {
 		// Don't return error - account switch worked, just preference not saved
 	}
 
+	// Labels are per account
+	go a.syncLabels()
+
 	slog.Info("Account switched successfully", "email", email)
 	return nil
 }
@@ -855,6 +939,99 @@ func (a *App) GetScheduledDraftsCount() (int, error) {
 	return a.application.Schedule().GetScheduledDraftsCount(context.Background())
 }
 
+// ScheduleDraft schedules a draft to be sent once. With recipientTZ the time
+// is read in the time zone of the recipient's contact.
+func (a *App) ScheduleDraft(draftID int64, sendAt string, recipientTZ bool) (time.Time, error) {
+	if a.application == nil {
+		return time.Time{}, fmt.Errorf("application not initialized")
+	}
+
+	var at, err = parseLocalTime(sendAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var opts = ports.ScheduleOptions{RecipientTimeZone: recipientTZ}
+	return a.application.Schedule().ScheduleDraftAt(context.Background(), draftID, at, opts)
+}
+
+// GetRecurrencePresets returns ready-made recurrence rules
+func (a *App) GetRecurrencePresets() []RecurrencePresetDTO {
+	if a.application == nil {
+		return nil
+	}
+
+	var result []RecurrencePresetDTO
+	for _, p := range a.application.Schedule().GetRecurrencePresets() {
+		result = append(result, RecurrencePresetDTO{Label: p.Label, Rule: p.Rule})
+	}
+	return result
+}
+
+// ScheduleRecurring turns a draft into the template of a recurring send.
+// rule is an RRULE; startsAt may be empty (now).
+func (a *App) ScheduleRecurring(draftID int64, rule string, startsAt string, recipientTZ bool) (*RecurringScheduleDTO, error) {
+	if a.application == nil {
+		return nil, fmt.Errorf("application not initialized")
+	}
+
+	var start time.Time
+	if startsAt != "" {
+		var err error
+		if start, err = parseLocalTime(startsAt); err != nil {
+			return nil, err
+		}
+	}
+	var opts = ports.ScheduleOptions{RecipientTimeZone: recipientTZ}
+	var schedule, err = a.application.Schedule().ScheduleRecurring(context.Background(), draftID, rule, start, opts)
+	if err != nil {
+		return nil, err
+	}
+	var dto = recurringToDTO(*schedule)
+	return &dto, nil
+}
+
+// GetRecurringSchedules returns the recurring sends of the account
+func (a *App) GetRecurringSchedules() ([]RecurringScheduleDTO, error) {
+	if a.application == nil {
+		return nil, fmt.Errorf("application not initialized")
+	}
+
+	var list, err = a.application.Schedule().GetRecurringSchedules(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var result []RecurringScheduleDTO
+	for _, r := range list {
+		result = append(result, recurringToDTO(r))
+	}
+	return result, nil
+}
+
+// CancelRecurring stops a recurring send (its template goes back to drafts)
+func (a *App) CancelRecurring(id int64) error {
+	if a.application == nil {
+		return fmt.Errorf("application not initialized")
+	}
+
+	return a.application.Schedule().CancelRecurring(context.Background(), id)
+}
+
+// recurringToDTO converts a recurring send to DTO
+func recurringToDTO(r ports.RecurringSchedule) RecurringScheduleDTO {
+	return RecurringScheduleDTO{
+		ID:          r.ID,
+		DraftID:     r.DraftID,
+		To:          r.ToAddresses,
+		Subject:     r.Subject,
+		Rule:        r.Rule,
+		TimeZone:    r.TimeZone,
+		StartsAt:    r.StartsAt,
+		NextRunAt:   r.NextRunAt,
+		LastRunAt:   r.LastRunAt,
+		Occurrences: r.Occurrences,
+	}
+}
+
 // Helper to parse time from frontend format
 func parseTime(timeStr string) (time.Time, error) {
 	// Try multiple formats
@@ -874,3 +1051,15 @@ func parseTime(timeStr string) (time.Time, error) {
 
 	return time.Time{}, fmt.Errorf("unable to parse time: %s", timeStr)
 }
+
+// parseLocalTime is parseTime reading times without a zone as local time
+func parseLocalTime(timeStr string) (time.Time, error) {
+	var t, err = parseTime(timeStr)
+	if err != nil {
+		return t, err
+	}
+	if _, errZone := time.Parse(time.RFC3339, timeStr); errZone != nil {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+	}
+	return t, nil
+}
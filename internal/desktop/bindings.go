@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/opik/miau/internal/app"
+	"github.com/opik/miau/internal/config"
 	"github.com/opik/miau/internal/ports"
 	"github.com/opik/miau/internal/services"
 	"github.com/opik/miau/internal/storage"
@@ -38,6 +39,12 @@ func (a *App) GetFolders() (result []FolderDTO, err error) {
 		return nil, ferr
 	}
 
+	// With several accounts, the unified inbox goes on top
+	if len(a.application.GetAllAccounts()) > 1 {
+		var unread, _ = storage.CountUnifiedUnread("INBOX")
+		result = append(result, FolderDTO{Name: ports.UnifiedInbox, UnreadMessages: unread})
+	}
+
 	for _, f := range folders {
 		result = append(result, a.folderToDTO(&f))
 	}
@@ -56,6 +63,14 @@ func (a *App) SelectFolder(name string) (result *FolderDTO, err error) {
 		return nil, nil
 	}
 
+	// Labels only exist locally as views, there's nothing to select
+	if isLabelFolder(name) {
+		a.mu.Lock()
+		a.currentFolder = name
+		a.mu.Unlock()
+		return &FolderDTO{Name: name}, nil
+	}
+
 	var folder, ferr = a.application.Email().SelectFolder(context.Background(), name)
 	if ferr != nil {
 		return nil, ferr
@@ -76,6 +91,103 @@ func (a *App) GetCurrentFolder() string {
 	return a.currentFolder
 }
 
+// ============================================================================
+// LABEL OPERATIONS
+// ============================================================================
+
+// LabelFolderPrefix is the folder name prefix the frontend uses to open a label
+const LabelFolderPrefix = "label:"
+
+func isLabelFolder(folder string) bool {
+	return strings.HasPrefix(folder, LabelFolderPrefix)
+}
+
+func labelName(folder string) string {
+	return strings.TrimPrefix(folder, LabelFolderPrefix)
+}
+
+// GetLabels returns the Gmail labels of the current account
+func (a *App) GetLabels() (result []LabelDTO, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[GetLabels] PANIC recovered: %v", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	if a.application == nil {
+		return nil, nil
+	}
+
+	var labels, lerr = a.application.Email().GetLabels(context.Background())
+	if lerr != nil {
+		return nil, lerr
+	}
+
+	for _, l := range labels {
+		// INBOX and SENT are already folders
+		if l.Name == "INBOX" || l.Name == "SENT" {
+			continue
+		}
+		result = append(result, LabelDTO{
+			ID:             l.ID,
+			Name:           l.Name,
+			System:         l.System,
+			TotalMessages:  l.TotalMessages,
+			UnreadMessages: l.UnreadMessages,
+		})
+	}
+	return result, nil
+}
+
+// SyncLabels pulls the labels of the current account from Gmail
+func (a *App) SyncLabels() (updated int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[SyncLabels] PANIC recovered: %v", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	if a.application == nil {
+		return 0, nil
+	}
+	return a.application.Email().SyncLabels(context.Background())
+}
+
+// syncLabels runs SyncLabels at startup and tells the frontend when done
+func (a *App) syncLabels() {
+	a.mu.RLock()
+	var account = a.account
+	a.mu.RUnlock()
+	if account == nil || account.AuthType != config.AuthTypeOAuth2 {
+		return
+	}
+
+	var updated, err = a.SyncLabels()
+	if err != nil {
+		log.Printf("[syncLabels] failed: %v", err)
+		return
+	}
+	if a.wailsApp != nil {
+		a.wailsApp.Event.Emit("labels:synced", updated)
+	}
+}
+
+// AddLabel adds a Gmail label to an email (creating the label if needed)
+func (a *App) AddLabel(id int64, label string) error {
+	if a.application == nil {
+		return nil
+	}
+	return a.application.Email().AddLabel(context.Background(), id, label)
+}
+
+// RemoveLabel removes a Gmail label from an email
+func (a *App) RemoveLabel(id int64, label string) error {
+	if a.application == nil {
+		return nil
+	}
+	return a.application.Email().RemoveLabel(context.Background(), id, label)
+}
+
 // ============================================================================
 // EMAIL OPERATIONS
 // ============================================================================
@@ -98,13 +210,26 @@ func (a *App) GetEmails(folder string, limit int) (result []EmailDTO, err error)
 
 	var ctx = context.Background()
 
+	// A label lists each of its messages once, whatever folders hold them
+	if isLabelFolder(folder) {
+		var emails, ferr = a.application.Email().GetEmailsByLabel(ctx, labelName(folder), limit)
+		if ferr != nil {
+			return nil, ferr
+		}
+		for _, e := range emails {
+			result = append(result, a.emailMetadataToDTO(&e))
+		}
+		return result, nil
+	}
+
 	var emails, ferr = a.application.Email().GetEmails(ctx, folder, limit)
 	if ferr != nil {
 		return nil, ferr
 	}
 
-	// Verify these emails still exist on server (purge deleted ones)
-	if len(emails) > 0 {
+	// Verify these emails still exist on server (purge deleted ones); the
+	// unified inbox spans accounts, whose syncs purge on their own
+	if len(emails) > 0 && folder != ports.UnifiedInbox {
 		var uids = make([]uint32, len(emails))
 		for i, e := range emails {
 			uids[i] = e.UID
@@ -141,6 +266,12 @@ func (a *App) GetEmailsThreaded(folder string, limit int) (result []EmailDTO, er
 		return nil, nil
 	}
 
+	// Threads are per account and per folder; the unified inbox and
+	// labels are listed flat
+	if folder == ports.UnifiedInbox || isLabelFolder(folder) {
+		return a.GetEmails(folder, limit)
+	}
+
 	if a.account == nil {
 		return nil, fmt.Errorf("no account set")
 	}
@@ -468,7 +599,15 @@ func (a *App) Connect() (err error) {
 	if a.application == nil {
 		return nil
 	}
-	return a.application.Sync().Connect(context.Background())
+	if err := a.application.Sync().Connect(context.Background()); err != nil {
+		return err
+	}
+
+	// Push sync: novos emails chegam via IDLE sem esperar o polling
+	if err := a.application.Sync().StartIdle(context.Background()); err != nil {
+		log.Printf("[Connect] push sync not started: %v", err)
+	}
+	return nil
 }
 
 // Disconnect disconnects from the email server
@@ -508,6 +647,29 @@ func (a *App) SyncFolder(folder string) (result *SyncResultDTO, err error) {
 
 	var ctx = context.Background()
 
+	// The unified inbox syncs the INBOX of every account
+	if folder == ports.UnifiedInbox {
+		var dto = &SyncResultDTO{}
+		for _, r := range a.application.SyncAccounts(ctx, "INBOX") {
+			if r.Err != nil {
+				log.Printf("[SyncFolder] %s failed: %v", r.Account.Email, r.Err)
+				continue
+			}
+			dto.NewEmails += r.Result.NewEmails
+			dto.DeletedEmails += r.Result.DeletedEmails
+		}
+		return dto, nil
+	}
+
+	// Labels come from the Gmail API
+	if isLabelFolder(folder) {
+		var updated, lerr = a.application.Email().SyncLabels(ctx)
+		if lerr != nil {
+			return nil, lerr
+		}
+		return &SyncResultDTO{NewEmails: updated}, nil
+	}
+
 	// 1. Sync new emails from server
 	var syncResult, syncErr = a.application.Sync().SyncFolder(ctx, folder)
 	log.Printf("[SyncFolder] sync completed, err=%v", syncErr)
@@ -842,6 +1004,8 @@ func (a *App) SendEmail(req SendRequest) (*SendResult, error) {
 		Bcc:      req.Bcc,
 		Subject:  req.Subject,
 		BodyText: req.Body,
+		Sign:     req.Sign,
+		Encrypt:  req.Encrypt,
 	}
 
 	if req.IsHTML {
@@ -849,6 +1013,10 @@ func (a *App) SendEmail(req SendRequest) (*SendResult, error) {
 		portsReq.BodyText = "" // TODO: generate text version
 	}
 
+	if req.FollowUpDays > 0 {
+		portsReq.FollowUpAfter = time.Duration(req.FollowUpDays) * 24 * time.Hour
+	}
+
 	if req.ReplyTo > 0 {
 		portsReq.ReplyToEmailID = &req.ReplyTo
 	}
@@ -862,6 +1030,10 @@ func (a *App) SendEmail(req SendRequest) (*SendResult, error) {
 		Success:   result.Success,
 		MessageID: result.MessageID,
 		Error:     a.getError(result.Error),
+		Queued:    result.Queued,
+		OutboxID:  result.OutboxID,
+		Pending:   result.Pending,
+		SendAt:    formatSendAt(result),
 	}, nil
 }
 
@@ -997,16 +1169,180 @@ func (a *App) SendDraft(id int64) (*SendResult, error) {
 		Success:   result.Success,
 		MessageID: result.MessageID,
 		Error:     a.getError(result.Error),
+		Queued:    result.Queued,
+		OutboxID:  result.OutboxID,
+		Pending:   result.Pending,
+		SendAt:    formatSendAt(result),
 	}, nil
 }
 
+// formatSendAt returns when a pending email goes out, "" for other results
+func formatSendAt(result *ports.SendResult) string {
+	if !result.Pending {
+		return ""
+	}
+	return result.SendAt.Format(time.RFC3339)
+}
+
+// CancelPendingSend stops an email held for the undo-send window (or waiting
+// in the outbox); it is kept as a draft
+func (a *App) CancelPendingSend(id int64) error {
+	if a.application == nil {
+		return fmt.Errorf("application not initialized")
+	}
+	return a.application.Send().CancelPendingSend(context.Background(), id)
+}
+
+// ============================================================================
+// OUTBOX (emails waiting for the send server to be reachable)
+// ============================================================================
+
+// ListOutbox returns the emails waiting in the outbox, failed ones included
+func (a *App) ListOutbox() ([]OutboxItemDTO, error) {
+	if a.application == nil {
+		return nil, nil
+	}
+
+	var items, err = a.application.Outbox().List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make([]OutboxItemDTO, 0, len(items))
+	for _, item := range items {
+		result = append(result, OutboxItemDTO{
+			ID:            item.ID,
+			AccountID:     item.AccountID,
+			To:            item.To,
+			Subject:       item.Subject,
+			Status:        string(item.Status),
+			Attempts:      item.Attempts,
+			NextAttemptAt: item.NextAttemptAt,
+			LastError:     item.LastError,
+			QueuedAt:      item.QueuedAt,
+		})
+	}
+	return result, nil
+}
+
+// RetryOutbox sends an outbox email on the next round, resetting its attempts
+func (a *App) RetryOutbox(id int64) error {
+	if a.application == nil {
+		return fmt.Errorf("application not initialized")
+	}
+	return a.application.Outbox().Retry(context.Background(), id)
+}
+
+// CancelOutbox takes an email out of the outbox; it is kept as a draft
+func (a *App) CancelOutbox(id int64) error {
+	if a.application == nil {
+		return fmt.Errorf("application not initialized")
+	}
+	return a.application.Outbox().Cancel(context.Background(), id)
+}
+
+// UpdateOutbox edits an outbox email (recipients, subject, body) and queues
+// it again
+func (a *App) UpdateOutbox(id int64, req SendRequest) error {
+	if a.application == nil {
+		return fmt.Errorf("application not initialized")
+	}
+
+	var portsReq = &ports.SendRequest{
+		To:       req.To,
+		Cc:       req.Cc,
+		Bcc:      req.Bcc,
+		Subject:  req.Subject,
+		BodyText: req.Body,
+	}
+	if req.IsHTML {
+		portsReq.BodyHTML = req.Body
+		portsReq.BodyText = ""
+	}
+	return a.application.Outbox().Update(context.Background(), id, portsReq)
+}
+
+// ============================================================================
+// FOLLOW-UPS (remind me if nobody replies)
+// ============================================================================
+
+// GetFollowUps returns the follow-ups that haven't fired yet
+func (a *App) GetFollowUps() ([]FollowUpDTO, error) {
+	if a.application == nil {
+		return nil, nil
+	}
+
+	var list, err = a.application.FollowUps().List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make([]FollowUpDTO, 0, len(list))
+	for _, f := range list {
+		result = append(result, FollowUpDTO{
+			ID:       f.ID,
+			DraftID:  f.DraftID,
+			To:       f.To,
+			Subject:  f.Subject,
+			Days:     int(f.After / (24 * time.Hour)),
+			Status:   string(f.Status),
+			SentAt:   f.SentAt,
+			RemindAt: f.RemindAt,
+		})
+	}
+	return result, nil
+}
+
+// SetDraftFollowUp sets a follow-up on a draft (0 days removes it)
+func (a *App) SetDraftFollowUp(draftID int64, days int) error {
+	if a.application == nil {
+		return fmt.Errorf("application not initialized")
+	}
+	return a.application.FollowUps().FollowUpDraft(context.Background(), draftID, time.Duration(days)*24*time.Hour)
+}
+
+// CancelFollowUp drops a follow-up that hasn't fired yet
+func (a *App) CancelFollowUp(id int64) error {
+	if a.application == nil {
+		return fmt.Errorf("application not initialized")
+	}
+	return a.application.FollowUps().Cancel(context.Background(), id)
+}
+
+// ============================================================================
+// MEETING INVITES
+// ============================================================================
+
+// RespondToInvite answers the meeting invite of an email ("ACCEPTED",
+// "TENTATIVE" or "DECLINED"): the reply goes to the organizer and the
+// response is recorded on the calendar event
+func (a *App) RespondToInvite(emailID int64, response string) (*InviteDTO, error) {
+	if a.application == nil {
+		return nil, fmt.Errorf("application not initialized")
+	}
+	var invite, err = a.application.Invites().Respond(context.Background(), emailID, ports.InviteResponse(response))
+	if err != nil {
+		return nil, err
+	}
+	return inviteToDTO(invite), nil
+}
+
 // ============================================================================
 // AI INTEGRATION
 // ============================================================================
 
-// AskAI sends a question to a CLI-based AI provider
+// AskAI sends a question to an AI provider ("" = configured provider).
+// The answer is streamed to the frontend as "ai:chunk" events and also returned in full.
 func (a *App) AskAI(provider, question, emailContextJSON string) (string, error) {
-	var cmd *exec.Cmd
+	if a.application == nil {
+		return "", fmt.Errorf("application not initialized")
+	}
+
+	var aiService = a.application.AI()
+	if aiService == nil {
+		return "", fmt.Errorf("AI service not available")
+	}
+
 	var prompt = question
 
 	// Add email context if provided
@@ -1014,34 +1350,21 @@ func (a *App) AskAI(provider, question, emailContextJSON string) (string, error)
 		prompt = fmt.Sprintf("Contexto do email:\n%s\n\nPergunta: %s", emailContextJSON, question)
 	}
 
-	switch provider {
-	case "claude":
-		// Claude Code CLI - uses stdin for prompt
-		// --dangerously-skip-permissions allows sqlite3 without asking
-		cmd = exec.Command("claude", "-p", "--dangerously-skip-permissions", prompt)
-	case "gemini":
-		// Gemini CLI
-		cmd = exec.Command("gemini", prompt)
-	case "ollama":
-		// Ollama with llama3
-		cmd = exec.Command("ollama", "run", "llama3", prompt)
-	case "openai":
-		// OpenAI CLI (if installed)
-		cmd = exec.Command("openai", "api", "chat.completions.create", "-m", "gpt-4", "-g", "user", prompt)
-	default:
-		return "", fmt.Errorf("provider não suportado: %s", provider)
-	}
-
-	var output, err = cmd.CombinedOutput()
+	var response, err = aiService.AskStream(context.Background(), provider, prompt, func(delta string) error {
+		if a.wailsApp != nil {
+			a.wailsApp.Event.Emit("ai:chunk", delta)
+		}
+		return nil
+	})
 	if err != nil {
 		// Try to provide helpful error message
 		if strings.Contains(err.Error(), "executable file not found") {
 			return "", fmt.Errorf("%s CLI não encontrado. Instale com: %s", provider, getInstallHint(provider))
 		}
-		return "", fmt.Errorf("erro ao executar %s: %v\nOutput: %s", provider, err, string(output))
+		return "", fmt.Errorf("erro ao executar %s: %v", provider, err)
 	}
 
-	return strings.TrimSpace(string(output)), nil
+	return response, nil
 }
 
 // getInstallHint returns installation instructions for AI CLIs
@@ -1707,8 +2030,12 @@ func (a *App) GetSettings() (*SettingsDTO, error) {
 		composeFormat = "html"
 	}
 
+	// The undo-send window lives in config.yaml (compose.send_delay_seconds),
+	// shared with the TUI and the CLI
 	var composeSendDelay = 30
-	if val, ok := settings["compose_send_delay"]; ok {
+	if a.cfg != nil {
+		composeSendDelay = int(a.cfg.Compose.SendDelay().Seconds())
+	} else if val, ok := settings["compose_send_delay"]; ok {
 		fmt.Sscanf(val, "%d", &composeSendDelay)
 	}
 
@@ -1752,6 +2079,17 @@ func (a *App) SaveSettings(settings SettingsDTO) error {
 	storage.SetSetting(dbAccount.ID, "compose_send_delay", fmt.Sprintf("%d", settings.ComposeSendDelay))
 	storage.SetSetting(dbAccount.ID, "sync_interval", settings.SyncInterval)
 
+	// Undo-send window: saved to config.yaml and applied to the send service
+	if a.cfg != nil && a.cfg.Compose.SendDelaySeconds != settings.ComposeSendDelay {
+		a.cfg.Compose.SendDelaySeconds = settings.ComposeSendDelay
+		if err := config.Save(a.cfg); err != nil {
+			return err
+		}
+		if a.application != nil {
+			a.application.Send().SetSendDelay(a.cfg.Compose.SendDelay())
+		}
+	}
+
 	return nil
 }
 
@@ -2234,7 +2572,7 @@ func (a *App) GetTopContacts(limit int) ([]ContactDTO, error) {
 	return result, nil
 }
 
-// SyncContacts syncs contacts from Gmail
+// SyncContacts syncs contacts with the CardDAV address book and from Gmail
 func (a *App) SyncContacts(fullSync bool) error {
 	if a.application == nil || a.application.Contacts() == nil {
 		return fmt.Errorf("contacts service not available")
@@ -2256,6 +2594,91 @@ func (a *App) SyncContacts(fullSync bool) error {
 	return nil
 }
 
+// SetContactTimeZone sets the IANA time zone of a contact (e.g.
+// America/New_York), used to schedule sends at the recipient's local time
+func (a *App) SetContactTimeZone(contactID int64, timeZone string) error {
+	if a.application == nil || a.application.Contacts() == nil {
+		return fmt.Errorf("contacts service not available")
+	}
+
+	return a.application.Contacts().SetContactTimeZone(context.Background(), contactID, timeZone)
+}
+
+// ImportContactsDialog asks for a vCard (.vcf) or Google/Outlook CSV file
+// and imports its contacts, merging duplicates by email (nil if cancelled)
+func (a *App) ImportContactsDialog() (*ContactImportDTO, error) {
+	if a.application == nil || a.application.Contacts() == nil {
+		return nil, fmt.Errorf("contacts service not available")
+	}
+	var account = a.application.GetCurrentAccount()
+	if account == nil {
+		return nil, fmt.Errorf("no account selected")
+	}
+	if a.wailsApp == nil {
+		return nil, fmt.Errorf("wails app not initialized")
+	}
+
+	var path, dialogErr = a.wailsApp.Dialog.OpenFile().
+		SetTitle("Import contacts").
+		AddFilter("Contacts (*.vcf, *.csv)", "*.vcf;*.vcard;*.csv").
+		PromptForSingleSelection()
+	if dialogErr != nil {
+		return nil, dialogErr
+	}
+	if path == "" {
+		return nil, nil // User cancelled
+	}
+
+	var data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result, err := a.application.Contacts().ImportContacts(context.Background(), account.ID, data, "")
+	if err != nil {
+		log.Printf("[ImportContactsDialog] import failed: %v", err)
+		return nil, err
+	}
+	return &ContactImportDTO{Created: result.Created, Merged: result.Merged, Skipped: result.Skipped}, nil
+}
+
+// ExportContactsDialog saves all contacts as vCards ("vcard") or Google
+// ("google") or Outlook ("outlook") CSV; returns the path ("" if cancelled)
+func (a *App) ExportContactsDialog(format string) (string, error) {
+	if a.application == nil || a.application.Contacts() == nil {
+		return "", fmt.Errorf("contacts service not available")
+	}
+	var account = a.application.GetCurrentAccount()
+	if account == nil {
+		return "", fmt.Errorf("no account selected")
+	}
+	if a.wailsApp == nil {
+		return "", fmt.Errorf("wails app not initialized")
+	}
+
+	var data, err = a.application.Contacts().ExportContacts(context.Background(), account.ID, ports.ContactFormat(format))
+	if err != nil {
+		return "", err
+	}
+
+	var filename = "contacts.vcf"
+	if format != string(ports.ContactFormatVCard) {
+		filename = "contacts-" + format + ".csv"
+	}
+	savePath, dialogErr := a.wailsApp.Dialog.SaveFile().
+		SetFilename(filename).
+		PromptForSingleSelection()
+	if dialogErr != nil {
+		return "", dialogErr
+	}
+	if savePath == "" {
+		return "", nil // User cancelled
+	}
+	if err := os.WriteFile(savePath, data, 0600); err != nil {
+		return "", err
+	}
+	return savePath, nil
+}
+
 // GetContactSyncStatus returns the current contact sync status
 func (a *App) GetContactSyncStatus() (*ContactSyncStatusDTO, error) {
 	if a.application == nil || a.application.Contacts() == nil {
@@ -2302,6 +2725,7 @@ func (a *App) contactToDTO(c *ports.ContactInfo) ContactDTO {
 		PhotoURL:         c.PhotoURL,
 		PhotoPath:        c.PhotoPath,
 		IsStarred:        c.IsStarred,
+		TimeZone:         c.TimeZone,
 		InteractionCount: c.InteractionCount,
 	}
 
@@ -2867,6 +3291,37 @@ func (a *App) SyncFromGoogleCalendar(calendarID string) (int, error) {
 	return count, nil
 }
 
+// SyncGoogleCalendar syncs the current account's primary Google calendar
+// both ways: Google changes come down, local events (follow-ups and task
+// deadlines included) go up
+func (a *App) SyncGoogleCalendar() (*CalendarSyncResultDTO, error) {
+	if a.application == nil || a.application.Calendar() == nil {
+		return nil, fmt.Errorf("calendar service not available")
+	}
+
+	var account = a.application.GetCurrentAccount()
+	if account == nil {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	var result, err = a.application.Calendar().SyncGoogleCalendar(context.Background(), account.ID)
+	if err != nil {
+		log.Printf("[SyncGoogleCalendar] error: %v", err)
+		return nil, err
+	}
+	return calendarSyncResultToDTO(result), nil
+}
+
+// calendarSyncResultToDTO converts ports.CalendarSyncResult to CalendarSyncResultDTO
+func calendarSyncResultToDTO(result *ports.CalendarSyncResult) *CalendarSyncResultDTO {
+	return &CalendarSyncResultDTO{
+		Pulled:    result.Pulled,
+		Pushed:    result.Pushed,
+		Deleted:   result.Deleted,
+		Conflicts: result.Conflicts,
+	}
+}
+
 // GetGoogleCalendarEvents returns events from Google Calendar for a week
 func (a *App) GetGoogleCalendarEvents(calendarID, weekStartDate string) ([]GoogleEventDTO, error) {
 	if a.application == nil || a.application.Calendar() == nil {
@@ -2905,3 +3360,376 @@ func (a *App) GetGoogleCalendarEvents(calendarID, weekStartDate string) ([]Googl
 
 	return result, nil
 }
+
+// ============================================================================
+// CALDAV SYNC
+// ============================================================================
+
+// IsCalDAVConnected returns true if the current account has a CalDAV calendar
+func (a *App) IsCalDAVConnected() bool {
+	if a.application == nil || a.application.Calendar() == nil {
+		return false
+	}
+	var account = a.application.GetCurrentAccount()
+	if account == nil {
+		return false
+	}
+	var calService = a.application.Calendar().(*services.CalendarService)
+	return calService.IsCalDAVConnected(account.ID)
+}
+
+// SyncCalDAV syncs the current account's calendar with its CalDAV server
+func (a *App) SyncCalDAV() (*CalendarSyncResultDTO, error) {
+	if a.application == nil || a.application.Calendar() == nil {
+		return nil, fmt.Errorf("calendar service not available")
+	}
+
+	var account = a.application.GetCurrentAccount()
+	if account == nil {
+		return nil, fmt.Errorf("no account selected")
+	}
+
+	var result, err = a.application.Calendar().SyncCalDAV(context.Background(), account.ID)
+	if err != nil {
+		log.Printf("[SyncCalDAV] error: %v", err)
+		return nil, err
+	}
+	return calendarSyncResultToDTO(result), nil
+}
+
+// GetCalendarConflicts returns the events changed both locally and on the
+// server, waiting for the user to pick a version
+func (a *App) GetCalendarConflicts() ([]CalendarConflictDTO, error) {
+	if a.application == nil || a.application.Calendar() == nil {
+		return nil, nil
+	}
+
+	var account = a.application.GetCurrentAccount()
+	if account == nil {
+		return nil, nil
+	}
+
+	var conflicts, err = a.application.Calendar().GetSyncConflicts(context.Background(), account.ID)
+	if err != nil {
+		log.Printf("[GetCalendarConflicts] error: %v", err)
+		return nil, err
+	}
+
+	var dtos = make([]CalendarConflictDTO, len(conflicts))
+	for i, c := range conflicts {
+		dtos[i].Local = a.calendarEventToDTO(&c.Local)
+		if c.Remote != nil {
+			var remote = a.calendarEventToDTO(c.Remote)
+			dtos[i].Remote = &remote
+		}
+	}
+	return dtos, nil
+}
+
+// ResolveCalendarConflict keeps the local version of an event (keepMine)
+// or the server's
+func (a *App) ResolveCalendarConflict(eventID int64, keepMine bool) (*CalendarEventDTO, error) {
+	if a.application == nil || a.application.Calendar() == nil {
+		return nil, fmt.Errorf("calendar service not available")
+	}
+
+	var resolution = ports.ConflictKeepTheirs
+	if keepMine {
+		resolution = ports.ConflictKeepMine
+	}
+	var event, err = a.application.Calendar().ResolveSyncConflict(context.Background(), eventID, resolution)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, nil // deleted on the server
+	}
+	var dto = a.calendarEventToDTO(event)
+	return &dto, nil
+}
+
+// ============================================================================
+// PGP
+// ============================================================================
+
+// ListPGPKeys returns the keys of the OpenPGP keyring
+func (a *App) ListPGPKeys() ([]PGPKeyDTO, error) {
+	if a.application == nil || a.application.PGP() == nil {
+		return nil, nil
+	}
+
+	var keys, err = a.application.PGP().ListKeys(context.Background())
+	if err != nil {
+		log.Printf("[ListPGPKeys] error: %v", err)
+		return nil, err
+	}
+	return pgpKeysToDTO(keys), nil
+}
+
+// ImportPGPKeys adds ASCII-armored keys to the keyring
+func (a *App) ImportPGPKeys(armored string) ([]PGPKeyDTO, error) {
+	if a.application == nil || a.application.PGP() == nil {
+		return nil, fmt.Errorf("PGP service not available")
+	}
+
+	var keys, err = a.application.PGP().ImportKeys(context.Background(), []byte(armored))
+	if err != nil {
+		return nil, err
+	}
+	return pgpKeysToDTO(keys), nil
+}
+
+// ExportPGPKey returns the ASCII-armored key matching a fingerprint, key ID or email
+func (a *App) ExportPGPKey(query string, secret bool) (string, error) {
+	if a.application == nil || a.application.PGP() == nil {
+		return "", fmt.Errorf("PGP service not available")
+	}
+
+	var armored, err = a.application.PGP().ExportKey(context.Background(), query, secret)
+	if err != nil {
+		return "", err
+	}
+	return string(armored), nil
+}
+
+// DeletePGPKey removes a key from the keyring
+func (a *App) DeletePGPKey(fingerprint string) error {
+	if a.application == nil || a.application.PGP() == nil {
+		return fmt.Errorf("PGP service not available")
+	}
+	return a.application.PGP().DeleteKey(context.Background(), fingerprint)
+}
+
+// pgpKeysToDTO converts keyring keys
+func pgpKeysToDTO(keys []ports.PGPKey) []PGPKeyDTO {
+	var result = make([]PGPKeyDTO, 0, len(keys))
+	for _, k := range keys {
+		var dto = PGPKeyDTO{
+			Fingerprint: k.Fingerprint,
+			KeyID:       k.KeyID,
+			UserIDs:     k.UserIDs,
+			Emails:      k.Emails,
+			Secret:      k.Secret,
+			CanEncrypt:  k.CanEncrypt,
+			Revoked:     k.Revoked,
+			Created:     k.Created,
+		}
+		if !k.Expires.IsZero() {
+			var expires = k.Expires
+			dto.Expires = &expires
+		}
+		result = append(result, dto)
+	}
+	return result
+}
+
+// ============================================================================
+// S/MIME
+// ============================================================================
+
+// ListSMIMECertificates returns the account identities and the certificates
+// of correspondents
+func (a *App) ListSMIMECertificates() ([]SMIMECertificateDTO, error) {
+	if a.application == nil || a.application.SMIME() == nil {
+		return nil, nil
+	}
+
+	var certs, err = a.application.SMIME().ListCertificates(context.Background())
+	if err != nil {
+		log.Printf("[ListSMIMECertificates] error: %v", err)
+		return nil, err
+	}
+	return smimeCertificatesToDTO(certs), nil
+}
+
+// ImportSMIMECertificates adds PEM certificates of correspondents
+func (a *App) ImportSMIMECertificates(pemData string) ([]SMIMECertificateDTO, error) {
+	if a.application == nil || a.application.SMIME() == nil {
+		return nil, fmt.Errorf("S/MIME service not available")
+	}
+
+	var certs, err = a.application.SMIME().ImportCertificates(context.Background(), []byte(pemData))
+	if err != nil {
+		return nil, err
+	}
+	return smimeCertificatesToDTO(certs), nil
+}
+
+// DeleteSMIMECertificate removes a correspondent certificate
+func (a *App) DeleteSMIMECertificate(fingerprint string) error {
+	if a.application == nil || a.application.SMIME() == nil {
+		return fmt.Errorf("S/MIME service not available")
+	}
+	return a.application.SMIME().DeleteCertificate(context.Background(), fingerprint)
+}
+
+// smimeCertificatesToDTO converts certificates
+func smimeCertificatesToDTO(certs []ports.SMIMECertificate) []SMIMECertificateDTO {
+	var result = make([]SMIMECertificateDTO, 0, len(certs))
+	for _, c := range certs {
+		result = append(result, SMIMECertificateDTO{
+			Fingerprint: c.Fingerprint,
+			Subject:     c.Subject,
+			Issuer:      c.Issuer,
+			Emails:      c.Emails,
+			NotBefore:   c.NotBefore,
+			NotAfter:    c.NotAfter,
+			Own:         c.Own,
+		})
+	}
+	return result
+}
+
+// ============================================================================
+// RULES
+// ============================================================================
+
+// GetRules returns the mail filter rules of the current account
+func (a *App) GetRules() ([]RuleDTO, error) {
+	if a.application == nil || a.application.Rules() == nil {
+		return nil, nil
+	}
+
+	var list, err = a.application.Rules().GetRules(context.Background())
+	if err != nil {
+		log.Printf("[GetRules] error: %v", err)
+		return nil, err
+	}
+
+	var result = make([]RuleDTO, 0, len(list))
+	for i := range list {
+		result = append(result, a.ruleToDTO(&list[i]))
+	}
+	return result, nil
+}
+
+// SaveRule creates (id 0) or updates a rule
+func (a *App) SaveRule(input RuleDTO) (*RuleDTO, error) {
+	if a.application == nil || a.application.Rules() == nil {
+		return nil, fmt.Errorf("rule service not available")
+	}
+
+	var rule = &ports.Rule{
+		ID:       input.ID,
+		Name:     input.Name,
+		Enabled:  input.Enabled,
+		Position: input.Position,
+		MatchAny: input.MatchAny,
+		Stop:     input.Stop,
+	}
+	for _, c := range input.Conditions {
+		rule.Conditions = append(rule.Conditions, ports.RuleCondition{
+			Field:    ports.RuleField(c.Field),
+			Header:   c.Header,
+			Operator: ports.RuleOperator(c.Operator),
+			Value:    c.Value,
+			Negate:   c.Negate,
+		})
+	}
+	for _, act := range input.Actions {
+		rule.Actions = append(rule.Actions, ports.RuleAction{Type: ports.RuleActionType(act.Type), Value: act.Value})
+	}
+
+	var saved, err = a.application.Rules().SaveRule(context.Background(), rule)
+	if err != nil {
+		return nil, err
+	}
+	var dto = a.ruleToDTO(saved)
+	return &dto, nil
+}
+
+// DeleteRule removes a rule
+func (a *App) DeleteRule(id int64) error {
+	if a.application == nil || a.application.Rules() == nil {
+		return fmt.Errorf("rule service not available")
+	}
+	return a.application.Rules().DeleteRule(context.Background(), id)
+}
+
+// SetRuleEnabled enables or disables a rule
+func (a *App) SetRuleEnabled(id int64, enabled bool) error {
+	if a.application == nil || a.application.Rules() == nil {
+		return fmt.Errorf("rule service not available")
+	}
+	return a.application.Rules().SetRuleEnabled(context.Background(), id, enabled)
+}
+
+// ImportRules parses rules in the text format and saves them
+func (a *App) ImportRules(text string, replace bool) ([]RuleDTO, error) {
+	if a.application == nil || a.application.Rules() == nil {
+		return nil, fmt.Errorf("rule service not available")
+	}
+
+	var list, err = a.application.Rules().ImportRules(context.Background(), text, replace)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make([]RuleDTO, 0, len(list))
+	for i := range list {
+		result = append(result, a.ruleToDTO(&list[i]))
+	}
+	return result, nil
+}
+
+// ExportRules returns all rules in the text format
+func (a *App) ExportRules() (string, error) {
+	if a.application == nil || a.application.Rules() == nil {
+		return "", fmt.Errorf("rule service not available")
+	}
+	return a.application.Rules().ExportRules(context.Background())
+}
+
+// RunRules applies the enabled rules to the newest emails of a folder
+func (a *App) RunRules(folder string, limit int) (*RuleRunResultDTO, error) {
+	if a.application == nil || a.application.Rules() == nil {
+		return nil, fmt.Errorf("rule service not available")
+	}
+	if limit <= 0 {
+		limit = 200
+	}
+
+	var result, err = a.application.Rules().RunRules(context.Background(), folder, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var dto = &RuleRunResultDTO{
+		Checked: result.Checked,
+		Matched: result.Matched,
+		Actions: result.Actions,
+	}
+	for _, e := range result.Errors {
+		dto.Errors = append(dto.Errors, e.Error())
+	}
+	return dto, nil
+}
+
+// ruleToDTO converts ports.Rule to RuleDTO
+func (a *App) ruleToDTO(r *ports.Rule) RuleDTO {
+	var dto = RuleDTO{
+		ID:         r.ID,
+		Name:       r.Name,
+		Enabled:    r.Enabled,
+		Position:   r.Position,
+		MatchAny:   r.MatchAny,
+		Stop:       r.Stop,
+		Conditions: make([]RuleConditionDTO, 0, len(r.Conditions)),
+		Actions:    make([]RuleActionDTO, 0, len(r.Actions)),
+		HitCount:   r.HitCount,
+		LastHitAt:  r.LastHitAt,
+	}
+	for _, c := range r.Conditions {
+		dto.Conditions = append(dto.Conditions, RuleConditionDTO{
+			Field:    string(c.Field),
+			Header:   c.Header,
+			Operator: string(c.Operator),
+			Value:    c.Value,
+			Negate:   c.Negate,
+		})
+	}
+	for _, act := range r.Actions {
+		dto.Actions = append(dto.Actions, RuleActionDTO{Type: string(act.Type), Value: act.Value})
+	}
+	return dto
+}
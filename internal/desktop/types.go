@@ -18,6 +18,10 @@ type EmailDTO struct {
 	Snippet        string    `json:"snippet"`
 	ThreadID       string    `json:"threadId,omitempty"`
 	ThreadCount    int       `json:"threadCount,omitempty"` // Number of emails in thread (for grouped view)
+	AccountID      int64     `json:"accountId,omitempty"`
+	AccountEmail   string    `json:"accountEmail,omitempty"` // set in the unified inbox and search
+	Labels         []string  `json:"labels,omitempty"`       // Gmail labels of the message
+	RiskLevel      string    `json:"riskLevel,omitempty"`    // "low", "medium" or "high"
 }
 
 // EmailDetailDTO represents full email details for the frontend
@@ -28,6 +32,66 @@ type EmailDetailDTO struct {
 	BodyText     string          `json:"bodyText"`
 	BodyHTML     string          `json:"bodyHtml"`
 	Attachments  []AttachmentDTO `json:"attachments"`
+	Security     *SecurityDTO    `json:"security,omitempty"`
+	Risk         *RiskDTO        `json:"risk,omitempty"`
+	Invite       *InviteDTO      `json:"invite,omitempty"`
+}
+
+// InviteDTO is a meeting invitation (text/calendar part) of an email
+type InviteDTO struct {
+	EventID     int64               `json:"eventId,omitempty"`
+	Method      string              `json:"method"` // REQUEST, CANCEL, REPLY, PUBLISH...
+	Kind        string              `json:"kind"`   // VEVENT or VTODO
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Location    string              `json:"location,omitempty"`
+	Start       time.Time           `json:"start"`
+	End         *time.Time          `json:"end,omitempty"`
+	AllDay      bool                `json:"allDay"`
+	TimeZone    string              `json:"timeZone,omitempty"`
+	RRule       string              `json:"rrule,omitempty"`
+	Organizer   InviteAttendeeDTO   `json:"organizer"`
+	Attendees   []InviteAttendeeDTO `json:"attendees"`
+	Cancelled   bool                `json:"cancelled"`
+	Outdated    bool                `json:"outdated"`
+	Response    string              `json:"response"` // NEEDS-ACTION, ACCEPTED, TENTATIVE or DECLINED
+	CanRespond  bool                `json:"canRespond"`
+}
+
+// InviteAttendeeDTO is an attendee or the organizer of an invite
+type InviteAttendeeDTO struct {
+	Email    string `json:"email"`
+	Name     string `json:"name,omitempty"`
+	Response string `json:"response,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// RiskDTO is the phishing/spoofing analysis of an email
+type RiskDTO struct {
+	Score        int              `json:"score"` // 0-100
+	Level        string           `json:"level"` // "", "low", "medium" or "high"
+	SPF          string           `json:"spf,omitempty"`
+	DKIM         string           `json:"dkim,omitempty"`
+	DMARC        string           `json:"dmarc,omitempty"`
+	DKIMVerified bool             `json:"dkimVerified"`
+	Findings     []RiskFindingDTO `json:"findings"`
+}
+
+// RiskFindingDTO is one suspicious signal of an email
+type RiskFindingDTO struct {
+	Reason string `json:"reason"` // e.g. "dmarc_fail", "lookalike_domain", "link_mismatch"
+	Detail string `json:"detail,omitempty"`
+}
+
+// SecurityDTO is the PGP/MIME or S/MIME status of an email
+type SecurityDTO struct {
+	Protocol  string `json:"protocol"` // "pgp" or "smime"
+	Encrypted bool   `json:"encrypted"`
+	Signature string `json:"signature"` // "", "valid", "invalid", "unknown_key" or "mismatch"
+	Signer    string `json:"signer,omitempty"`
+	KeyID     string `json:"keyId,omitempty"`
+	Chain     string `json:"chain,omitempty"` // S/MIME: "trusted", "untrusted", "expired" or "invalid"
+	Error     string `json:"error,omitempty"`
 }
 
 // AttachmentDTO represents an email attachment
@@ -50,6 +114,16 @@ type FolderDTO struct {
 	UnreadMessages int   `json:"unreadMessages"`
 }
 
+// LabelDTO represents a Gmail label. The frontend opens it as the folder
+// LabelFolderPrefix + name.
+type LabelDTO struct {
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	System         bool   `json:"system"`
+	TotalMessages  int    `json:"totalMessages"`
+	UnreadMessages int    `json:"unreadMessages"`
+}
+
 // AccountDTO represents an email account
 type AccountDTO struct {
 	Email string `json:"email"`
@@ -80,6 +154,10 @@ type SendRequest struct {
 	Body    string   `json:"body"`
 	IsHTML  bool     `json:"isHtml"`
 	ReplyTo int64    `json:"replyTo,omitempty"`
+	Sign    bool     `json:"sign,omitempty"`    // S/MIME or PGP/MIME sign
+	Encrypt bool     `json:"encrypt,omitempty"` // S/MIME or PGP/MIME encrypt
+
+	FollowUpDays int `json:"followUpDays,omitempty"` // remind if nobody replies within N days
 }
 
 // SendResult represents the result of sending an email
@@ -87,6 +165,35 @@ type SendResult struct {
 	Success   bool   `json:"success"`
 	MessageID string `json:"messageId"`
 	Error     string `json:"error,omitempty"`
+	Queued    bool   `json:"queued,omitempty"`   // server unreachable: waiting in the outbox
+	OutboxID  int64  `json:"outboxId,omitempty"` // draft ID of the queued email
+	Pending   bool   `json:"pending,omitempty"`  // held for the undo-send window
+	SendAt    string `json:"sendAt,omitempty"`   // when a pending email goes out (RFC 3339)
+}
+
+// OutboxItemDTO is an email waiting in the outbox
+type OutboxItemDTO struct {
+	ID            int64      `json:"id"`
+	AccountID     int64      `json:"accountId"`
+	To            string     `json:"to"`
+	Subject       string     `json:"subject"`
+	Status        string     `json:"status"` // queued, sending or failed
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	QueuedAt      time.Time  `json:"queuedAt"`
+}
+
+// FollowUpDTO is a "remind me if nobody replies" on a sent email
+type FollowUpDTO struct {
+	ID       int64      `json:"id"`
+	DraftID  int64      `json:"draftId,omitempty"`
+	To       string     `json:"to"`
+	Subject  string     `json:"subject"`
+	Days     int        `json:"days"`
+	Status   string     `json:"status"` // waiting (not sent yet) or pending
+	SentAt   *time.Time `json:"sentAt,omitempty"`
+	RemindAt *time.Time `json:"remindAt,omitempty"` // nil until the email is sent
 }
 
 // DraftDTO represents a draft email
@@ -268,6 +375,7 @@ type ContactDTO struct {
 	PhotoURL         string             `json:"photoUrl,omitempty"`
 	PhotoPath        string             `json:"photoPath,omitempty"`
 	IsStarred        bool               `json:"isStarred"`
+	TimeZone         string             `json:"timeZone,omitempty"`
 	InteractionCount int                `json:"interactionCount"`
 	Emails           []ContactEmailDTO  `json:"emails"`
 	Phones           []ContactPhoneDTO  `json:"phones,omitempty"`
@@ -295,6 +403,13 @@ type ContactSyncStatusDTO struct {
 	Error         string    `json:"error,omitempty"`
 }
 
+// ContactImportDTO summarizes a contact import
+type ContactImportDTO struct {
+	Created int `json:"created"`
+	Merged  int `json:"merged"`
+	Skipped int `json:"skipped"`
+}
+
 // ============================================================================
 // TASK DTOs
 // ============================================================================
@@ -381,6 +496,21 @@ type CalendarEventCountsDTO struct {
 	Total     int `json:"total"`
 }
 
+// CalendarSyncResultDTO represents the outcome of a CalDAV or Google Calendar sync
+type CalendarSyncResultDTO struct {
+	Pulled    int `json:"pulled"`
+	Pushed    int `json:"pushed"`
+	Deleted   int `json:"deleted"`
+	Conflicts int `json:"conflicts"`
+}
+
+// CalendarConflictDTO represents an event changed both locally and on the
+// server; remote is null when the server deleted it
+type CalendarConflictDTO struct {
+	Local  CalendarEventDTO  `json:"local"`
+	Remote *CalendarEventDTO `json:"remote,omitempty"`
+}
+
 // GoogleCalendarDTO represents a Google Calendar
 type GoogleCalendarDTO struct {
 	ID              string `json:"id"`
@@ -546,3 +676,88 @@ type ScheduledDraftDTO struct {
 	Status          string     `json:"status"`
 	CreatedAt       time.Time  `json:"createdAt"`
 }
+
+// RecurrencePresetDTO represents a ready-made recurrence rule
+type RecurrencePresetDTO struct {
+	Label string `json:"label"`
+	Rule  string `json:"rule"`
+}
+
+// RecurringScheduleDTO represents a recurring send
+type RecurringScheduleDTO struct {
+	ID          int64      `json:"id"`
+	DraftID     int64      `json:"draftId"`
+	To          string     `json:"to"`
+	Subject     string     `json:"subject"`
+	Rule        string     `json:"rule"`
+	TimeZone    string     `json:"timeZone,omitempty"`
+	StartsAt    time.Time  `json:"startsAt"`
+	NextRunAt   *time.Time `json:"nextRunAt"`
+	LastRunAt   *time.Time `json:"lastRunAt,omitempty"`
+	Occurrences int        `json:"occurrences"`
+}
+
+// ============================================================================
+// RULES DTOs
+// ============================================================================
+
+// RuleConditionDTO represents a single rule test
+type RuleConditionDTO struct {
+	Field    string `json:"field"`
+	Header   string `json:"header,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Negate   bool   `json:"negate,omitempty"`
+}
+
+// RuleActionDTO represents a single rule action
+type RuleActionDTO struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+// RuleDTO represents a mail filter rule
+type RuleDTO struct {
+	ID         int64              `json:"id"`
+	Name       string             `json:"name"`
+	Enabled    bool               `json:"enabled"`
+	Position   int                `json:"position"`
+	MatchAny   bool               `json:"matchAny"`
+	Stop       bool               `json:"stop"`
+	Conditions []RuleConditionDTO `json:"conditions"`
+	Actions    []RuleActionDTO    `json:"actions"`
+	HitCount   int                `json:"hitCount"`
+	LastHitAt  *time.Time         `json:"lastHitAt,omitempty"`
+}
+
+// RuleRunResultDTO represents the result of running rules on a folder
+type RuleRunResultDTO struct {
+	Checked int      `json:"checked"`
+	Matched int      `json:"matched"`
+	Actions int      `json:"actions"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// PGPKeyDTO represents a key in the OpenPGP keyring
+type PGPKeyDTO struct {
+	Fingerprint string     `json:"fingerprint"`
+	KeyID       string     `json:"keyId"`
+	UserIDs     []string   `json:"userIds"`
+	Emails      []string   `json:"emails"`
+	Secret      bool       `json:"secret"`
+	CanEncrypt  bool       `json:"canEncrypt"`
+	Revoked     bool       `json:"revoked"`
+	Created     time.Time  `json:"created"`
+	Expires     *time.Time `json:"expires,omitempty"`
+}
+
+// SMIMECertificateDTO represents an S/MIME certificate
+type SMIMECertificateDTO struct {
+	Fingerprint string    `json:"fingerprint"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	Emails      []string  `json:"emails"`
+	NotBefore   time.Time `json:"notBefore"`
+	NotAfter    time.Time `json:"notAfter"`
+	Own         bool      `json:"own"`
+}
@@ -0,0 +1,243 @@
+package authresults
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+const gmailResults = "mx.google.com;\r\n" +
+	"       dkim=pass header.i=@example.com header.s=sel1 header.b=abc123;\r\n" +
+	"       spf=pass (google.com: domain of alice@example.com designates 192.0.2.1 as permitted sender) smtp.mailfrom=alice@example.com;\r\n" +
+	"       dmarc=fail (p=REJECT sp=REJECT dis=QUARANTINE) header.from=example.com"
+
+func TestParseAuthenticationResults(t *testing.T) {
+	var ar, err = ParseAuthenticationResults(strings.ReplaceAll(gmailResults, "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ar.AuthServID != "mx.google.com" || len(ar.Results) != 3 {
+		t.Fatalf("Unexpected results: %+v", ar)
+	}
+	var dkim, spf, dmarc = ar.Results[0], ar.Results[1], ar.Results[2]
+	if dkim.Method != "dkim" || dkim.Verdict != VerdictPass || dkim.Props["header.i"] != "@example.com" {
+		t.Errorf("Unexpected dkim result: %+v", dkim)
+	}
+	if spf.Verdict != VerdictPass || spf.Props["smtp.mailfrom"] != "alice@example.com" {
+		t.Errorf("Unexpected spf result: %+v", spf)
+	}
+	if dmarc.Verdict != VerdictFail || dmarc.Props["header.from"] != "example.com" {
+		t.Errorf("Unexpected dmarc result: %+v", dmarc)
+	}
+
+	// Microsoft style, with a version, reason and a bare property
+	ar, err = ParseAuthenticationResults(`spf.protection.outlook.com 1; dkim=none (message not signed) header.d=none;dmarc=none action=none header.from=example.org;compauth=fail reason="601 spoof"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ar.Results) != 3 || ar.Results[2].Reason != "601 spoof" || ar.Results[1].Props["action"] != "none" {
+		t.Errorf("Unexpected results: %+v", ar.Results)
+	}
+
+	if ar, _ := ParseAuthenticationResults("example.net; none"); ar == nil || len(ar.Results) != 0 {
+		t.Errorf("Expected no results, got %+v", ar)
+	}
+}
+
+func TestParseReceivedSPF(t *testing.T) {
+	var r = ParseReceivedSPF(`SoftFail (mx.example.net: domain of transitioning bob@example.com does not designate 192.0.2.9 as permitted sender) client-ip=192.0.2.9; envelope-from="bob@example.com"; helo=mail.example.com;`)
+	if r.Verdict != VerdictSoftFail || r.Props["client-ip"] != "192.0.2.9" || r.Props["envelope-from"] != "bob@example.com" {
+		t.Errorf("Unexpected result: %+v", r)
+	}
+}
+
+// stubResolver serves DKIM keys from a map
+type stubResolver map[string]string
+
+func (r stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := r[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+const testMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: bob@example.net\r\n" +
+	"Subject:   Invoice\r\n" +
+	"\tfor March\r\n" +
+	"\r\n" +
+	"Please find the invoice  attached.  \r\n" +
+	"\r\n" +
+	"\r\n"
+
+// sign adds a relaxed/relaxed DKIM-Signature to msg
+func sign(t *testing.T, msg, algorithm, selector string, key crypto.Signer) string {
+	t.Helper()
+	var fields = Fields([]byte(msg))
+	var _, body, _ = strings.Cut(msg, "\r\n\r\n")
+	var bodyHash = sha256.Sum256(relaxedBody([]byte(body)))
+
+	var value = fmt.Sprintf(" v=1; a=%s; c=relaxed/relaxed; d=example.com; s=%s;\r\n\th=from:to:subject; bh=%s;\r\n\tb=",
+		algorithm, selector, base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	var h = sha256.New()
+	for _, name := range []string{"from", "to", "subject"} {
+		for _, f := range fields {
+			if f.Name == name {
+				h.Write(relaxedHeader(f.Raw))
+			}
+		}
+	}
+	h.Write([]byte(strings.TrimSuffix(string(relaxedHeader([]byte("DKIM-Signature:"+value))), "\r\n")))
+
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, h.Sum(nil))
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, h.Sum(nil))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "DKIM-Signature:" + value + base64.StdEncoding.EncodeToString(sig) + "\r\n" + msg
+}
+
+func TestVerifyDKIM(t *testing.T) {
+	var rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rsaPub, _ = x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	var edPub, edKey, _ = ed25519.GenerateKey(rand.Reader)
+
+	var resolver = stubResolver{
+		"rsa._domainkey.example.com": "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPub),
+		"ed._domainkey.example.com":  "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPub),
+		"old._domainkey.example.com": "v=DKIM1; p=",
+	}
+	var ctx = context.Background()
+
+	for _, tc := range []struct {
+		algorithm, selector string
+		key                 crypto.Signer
+	}{
+		{"rsa-sha256", "rsa", rsaKey},
+		{"ed25519-sha256", "ed", edKey},
+	} {
+		var signed = sign(t, testMessage, tc.algorithm, tc.selector, tc.key)
+		var results = VerifyDKIM(ctx, []byte(signed), resolver)
+		if len(results) != 1 || results[0].Verdict != VerdictPass || results[0].Domain != "example.com" {
+			t.Errorf("%s: expected a pass, got %+v", tc.algorithm, results)
+		}
+
+		// Whitespace changes survive relaxed canonicalization
+		var rewrapped = strings.Replace(signed, "Subject:   Invoice", "Subject: Invoice ", 1)
+		if results := VerifyDKIM(ctx, []byte(rewrapped), resolver); results[0].Verdict != VerdictPass {
+			t.Errorf("%s: expected a pass after rewrapping, got %+v", tc.algorithm, results)
+		}
+
+		var tampered = strings.Replace(signed, "invoice", "payment", 1)
+		if results := VerifyDKIM(ctx, []byte(tampered), resolver); results[0].Verdict != VerdictFail {
+			t.Errorf("%s: expected a body hash failure, got %+v", tc.algorithm, results)
+		}
+
+		var forged = strings.Replace(signed, "From: Alice", "From: Mallory", 1)
+		if results := VerifyDKIM(ctx, []byte(forged), resolver); results[0].Verdict != VerdictFail {
+			t.Errorf("%s: expected a signature failure, got %+v", tc.algorithm, results)
+		}
+	}
+
+	// Revoked and missing keys
+	var revoked = sign(t, testMessage, "rsa-sha256", "old", rsaKey)
+	if results := VerifyDKIM(ctx, []byte(revoked), resolver); results[0].Verdict != VerdictPermError {
+		t.Errorf("Expected permerror for a revoked key, got %+v", results)
+	}
+	var missing = sign(t, testMessage, "rsa-sha256", "gone", rsaKey)
+	if results := VerifyDKIM(ctx, []byte(missing), resolver); results[0].Verdict != VerdictPermError {
+		t.Errorf("Expected permerror for a missing key, got %+v", results)
+	}
+
+	if results := VerifyDKIM(ctx, []byte(testMessage), resolver); len(results) != 0 {
+		t.Errorf("Expected no results for an unsigned message, got %+v", results)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	var analyzer = &Analyzer{}
+	var ctx = context.Background()
+
+	var clean = analyzer.Analyze(ctx, Message{
+		Raw:       []byte("Authentication-Results: mx.example.net; spf=pass smtp.mailfrom=example.com; dkim=pass header.d=example.com; dmarc=pass header.from=example.com\r\n"),
+		FromName:  "Alice",
+		FromEmail: "alice@example.com",
+		HTML:      `<a href="https://www.example.com/invoice">www.example.com</a>`,
+	}, Sender{Known: true, KnownDomains: []string{"example.com"}})
+	if clean.Score != 0 || clean.Level != LevelNone || clean.DMARC != VerdictPass {
+		t.Errorf("Expected a clean report, got %+v", clean)
+	}
+
+	var header = "Authentication-Results: mx.example.net; spf=softfail smtp.mailfrom=examp1e.com; dkim=none; dmarc=fail header.from=examp1e.com\r\n" +
+		// Added by the sender, below the receiving server's header: ignored
+		"Authentication-Results: evil.example; spf=pass; dkim=pass; dmarc=pass\r\n"
+	var phishing = analyzer.Analyze(ctx, Message{
+		Raw:       []byte(header),
+		FromName:  "Alice Smith",
+		FromEmail: "alice@examp1e.com",
+		HTML:      `<p>Pay <a href="http://203.0.113.7/pay">https://www.example.com/pay</a></p>`,
+	}, Sender{NameEmails: []string{"alice@example.com"}, KnownDomains: []string{"mail.example.com", "gmail.com"}})
+
+	if phishing.AuthServID != "mx.example.net" || phishing.DMARC != VerdictFail || phishing.SPF != VerdictSoftFail {
+		t.Errorf("Unexpected verdicts: %+v", phishing)
+	}
+	if phishing.Level != LevelHigh || phishing.Score != 100 {
+		t.Errorf("Expected a high risk, got %d (%s)", phishing.Score, phishing.Level)
+	}
+	var reasons = make(map[Reason]string)
+	for _, f := range phishing.Findings {
+		reasons[f.Reason] = f.Detail
+	}
+	for _, want := range []Reason{ReasonDMARCFail, ReasonSPFSoftFail, ReasonContactSpoof, ReasonLookalikeDomain, ReasonLinkMismatch, ReasonFirstTimeSender} {
+		if _, ok := reasons[want]; !ok {
+			t.Errorf("Missing finding %s in %+v", want, phishing.Findings)
+		}
+	}
+	if reasons[ReasonLookalikeDomain] != "example.com" {
+		t.Errorf("Lookalike of %q, want example.com", reasons[ReasonLookalikeDomain])
+	}
+
+	var firstTime = analyzer.Analyze(ctx, Message{FromName: "support@bank.com", FromEmail: "x@xn--bnk-sna.com"}, Sender{})
+	if firstTime.Level != LevelMedium {
+		t.Errorf("Expected a medium risk, got %+v", firstTime)
+	}
+}
+
+func TestConfusable(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"paypa1.com", "paypal.com", true},
+		{"rnicrosoft.com", "microsoft.com", true},
+		{"exmaple.com", "example.com", true},
+		{"example-billing.com", "example.com", true},
+		{"xn--pypal-4ve.com", "paypal.com", true}, // pаypal with a Cyrillic а
+		{"example.de", "example.com", false},
+		{"github.com", "gitlab.com", false},
+		{"amazon.co.uk", "amazon.com", false},
+	} {
+		if got := confusable(tc.a, tc.b); got != tc.want {
+			t.Errorf("confusable(%s, %s) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package authresults
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/opik/miau/internal/email/message"
+)
+
+// maxSignatures bounds the DKIM-Signature headers checked per message
+const maxSignatures = 5
+
+// Resolver looks up the DNS TXT records that hold DKIM keys. *net.Resolver
+// implements it; tests stub it.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DKIMResult is the outcome of verifying one DKIM signature
+type DKIMResult struct {
+	Domain   string
+	Selector string
+	Verdict  Verdict // pass, fail, neutral, temperror or permerror
+	Reason   string
+}
+
+// VerifyDKIM verifies the DKIM signatures of a whole message, looking the
+// keys up with r. A message without signatures has no results.
+func VerifyDKIM(ctx context.Context, raw []byte, r Resolver) []DKIMResult {
+	raw = message.Canonicalize(raw)
+	var _, body = message.SplitMessage(raw)
+	var fields = Fields(raw)
+
+	var results []DKIMResult
+	for i, f := range fields {
+		if f.Name != "dkim-signature" {
+			continue
+		}
+		if len(results) == maxSignatures {
+			break
+		}
+		results = append(results, verifySignature(ctx, fields, i, body, r))
+	}
+	return results
+}
+
+// verifySignature checks the signature in fields[index]
+func verifySignature(ctx context.Context, fields []Field, index int, body []byte, r Resolver) DKIMResult {
+	var sig, err = ParseSignature(fields[index].Value)
+	if err != nil {
+		return DKIMResult{Verdict: VerdictPermError, Reason: err.Error()}
+	}
+	var res = DKIMResult{Domain: sig.Domain, Selector: sig.Selector}
+	var fail = func(verdict Verdict, reason string) DKIMResult {
+		res.Verdict, res.Reason = verdict, reason
+		return res
+	}
+
+	if sig.Version != "1" {
+		return fail(VerdictPermError, "unsupported version "+sig.Version)
+	}
+	var newHash func() hash.Hash
+	var hashID crypto.Hash
+	switch sig.Algorithm {
+	case "rsa-sha256", "ed25519-sha256":
+		newHash, hashID = sha256.New, crypto.SHA256
+	case "rsa-sha1":
+		newHash, hashID = sha1.New, crypto.SHA1
+	default:
+		return fail(VerdictPermError, "unsupported algorithm "+sig.Algorithm)
+	}
+	if !contains(sig.Headers, "from") {
+		return fail(VerdictPermError, "From is not signed")
+	}
+	if sig.Identity != "" {
+		var _, domain, _ = strings.Cut(sig.Identity, "@")
+		domain = strings.ToLower(domain)
+		if domain != sig.Domain && !strings.HasSuffix(domain, "."+sig.Domain) {
+			return fail(VerdictPermError, "i= is not in d=")
+		}
+	}
+	if sig.Expiration > 0 && time.Now().Unix() > sig.Expiration {
+		return fail(VerdictPermError, "signature expired")
+	}
+
+	// Body hash first: it needs no DNS lookup
+	var canonBody []byte
+	switch sig.BodyCanon {
+	case "simple":
+		canonBody = simpleBody(body)
+	case "relaxed":
+		canonBody = relaxedBody(body)
+	default:
+		return fail(VerdictPermError, "unsupported body canonicalization "+sig.BodyCanon)
+	}
+	if sig.BodyLength >= 0 {
+		if sig.BodyLength > int64(len(canonBody)) {
+			return fail(VerdictPermError, "l= exceeds the body")
+		}
+		canonBody = canonBody[:sig.BodyLength]
+	}
+	var h = newHash()
+	h.Write(canonBody)
+	if !bytes.Equal(h.Sum(nil), sig.BodyHash) {
+		return fail(VerdictFail, "body hash mismatch")
+	}
+
+	var canonHeader func(f []byte) []byte
+	switch sig.HeaderCanon {
+	case "simple":
+		canonHeader = func(f []byte) []byte { return f }
+	case "relaxed":
+		canonHeader = relaxedHeader
+	default:
+		return fail(VerdictPermError, "unsupported header canonicalization "+sig.HeaderCanon)
+	}
+
+	key, verdict, err := lookupKey(ctx, r, sig)
+	if err != nil {
+		return fail(verdict, err.Error())
+	}
+
+	// Signed fields are taken bottom-up; a name listed more often than it
+	// occurs signs an empty field (RFC 6376 section 5.4.2)
+	h = newHash()
+	var used = make(map[int]bool)
+	for _, name := range sig.Headers {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if fields[i].Name == name && !used[i] && i != index {
+				used[i] = true
+				h.Write(canonHeader(fields[i].Raw))
+				break
+			}
+		}
+	}
+	var self = canonHeader(stripSignatureData(fields[index].Raw))
+	h.Write(bytes.TrimSuffix(self, []byte("\r\n")))
+	var digest = h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 1024 {
+			return fail(VerdictPermError, "RSA key too short")
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hashID, digest, sig.Data); err != nil {
+			return fail(VerdictFail, "signature mismatch")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, digest, sig.Data) {
+			return fail(VerdictFail, "signature mismatch")
+		}
+	}
+	res.Verdict = VerdictPass
+	return res
+}
+
+// lookupKey fetches the public key of a signature from DNS (RFC 6376
+// section 3.6.2)
+func lookupKey(ctx context.Context, r Resolver, sig *Signature) (crypto.PublicKey, Verdict, error) {
+	if r == nil {
+		return nil, VerdictTempError, errors.New("no DNS resolver")
+	}
+	var name = sig.Selector + "._domainkey." + sig.Domain
+	var records, err = r.LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, VerdictPermError, fmt.Errorf("no key at %s", name)
+		}
+		return nil, VerdictTempError, fmt.Errorf("key lookup failed: %w", err)
+	}
+
+	var lastErr = fmt.Errorf("no key at %s", name)
+	for _, record := range records {
+		var key, err = parseKey(record, sig.Algorithm)
+		if err == nil {
+			return key, "", nil
+		}
+		lastErr = err
+	}
+	return nil, VerdictPermError, lastErr
+}
+
+// parseKey parses a DKIM key record such as "v=DKIM1; k=rsa; p=MIIB..."
+func parseKey(record, algorithm string) (crypto.PublicKey, error) {
+	var tags, err = parseTags(record)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, fmt.Errorf("unsupported key version %s", v)
+	}
+	var p, ok = tags["p"]
+	if !ok {
+		return nil, errors.New("key record without p=")
+	}
+	if p = stripSpace(p); p == "" {
+		return nil, errors.New("key revoked")
+	}
+	data, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	var keyType = strings.ToLower(tags["k"])
+	if keyType == "" {
+		keyType = "rsa"
+	}
+	if !strings.HasPrefix(algorithm, keyType+"-") {
+		return nil, fmt.Errorf("%s key for an %s signature", keyType, algorithm)
+	}
+	switch keyType {
+	case "rsa":
+		if key, err := x509.ParsePKIXPublicKey(data); err == nil {
+			if rsaKey, ok := key.(*rsa.PublicKey); ok {
+				return rsaKey, nil
+			}
+			return nil, errors.New("not an RSA key")
+		}
+		return x509.ParsePKCS1PublicKey(data)
+	case "ed25519":
+		if len(data) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(data), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", keyType)
+}
+
+// signatureData matches the value of the b= tag of a DKIM-Signature field
+var signatureData = regexp.MustCompile(`((?:^[^:]*:|;)[ \t\r\n]*b[ \t\r\n]*=)[^;]*`)
+
+// stripSignatureData empties the b= tag, as the signature is computed over
+// the field without it
+func stripSignatureData(field []byte) []byte {
+	return signatureData.ReplaceAll(field, []byte("$1"))
+}
+
+// relaxedHeader canonicalizes a field with the "relaxed" algorithm:
+// lowercased name, unfolded value with whitespace runs reduced to a space
+func relaxedHeader(f []byte) []byte {
+	var i = bytes.IndexByte(f, ':')
+	if i < 0 {
+		return f
+	}
+	var name = strings.ToLower(strings.TrimSpace(string(f[:i])))
+	var value = strings.NewReplacer("\r\n", "", "\n", "").Replace(string(f[i+1:]))
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+	return []byte(name + ":" + value + "\r\n")
+}
+
+// relaxedBody canonicalizes a body with the "relaxed" algorithm
+func relaxedBody(body []byte) []byte {
+	var lines = bytes.Split(body, []byte("\r\n"))
+	var out bytes.Buffer
+	for _, line := range lines {
+		var fields = bytes.FieldsFunc(line, isWSP)
+		var collapsed = bytes.Join(fields, []byte(" "))
+		if len(fields) > 0 && isWSP(rune(line[0])) {
+			collapsed = append([]byte(" "), collapsed...)
+		}
+		out.Write(collapsed)
+		out.WriteString("\r\n")
+	}
+	var b = trimEmptyLines(out.Bytes())
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+// simpleBody canonicalizes a body with the "simple" algorithm
+func simpleBody(body []byte) []byte {
+	var b = body
+	if len(b) > 0 && !bytes.HasSuffix(b, []byte("\r\n")) {
+		b = append(append([]byte(nil), b...), '\r', '\n')
+	}
+	b = trimEmptyLines(b)
+	if len(b) == 0 {
+		return []byte("\r\n")
+	}
+	return b
+}
+
+// trimEmptyLines drops the empty lines at the end of a CRLF-terminated body
+func trimEmptyLines(b []byte) []byte {
+	for bytes.HasSuffix(b, []byte("\r\n\r\n")) {
+		b = b[:len(b)-2]
+	}
+	if bytes.Equal(b, []byte("\r\n")) {
+		return nil
+	}
+	return b
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+// Package authresults interprets the authentication headers of a message
+// (Authentication-Results, Received-SPF and DKIM-Signature), verifies DKIM
+// signatures and combines the verdicts with phishing heuristics into a risk
+// score.
+package authresults
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/opik/miau/internal/email/message"
+)
+
+// Verdict is the result of an authentication method (RFC 8601 section 2.7)
+type Verdict string
+
+const (
+	VerdictNone      Verdict = "none"
+	VerdictPass      Verdict = "pass"
+	VerdictFail      Verdict = "fail"
+	VerdictSoftFail  Verdict = "softfail"
+	VerdictNeutral   Verdict = "neutral"
+	VerdictPolicy    Verdict = "policy"
+	VerdictTempError Verdict = "temperror"
+	VerdictPermError Verdict = "permerror"
+)
+
+// Result is one method result of an Authentication-Results header, e.g.
+// "dkim=pass header.d=example.com"
+type Result struct {
+	Method  string            // lowercased: spf, dkim, dmarc, arc, ...
+	Verdict Verdict           // lowercased
+	Reason  string            // reason= value, if any
+	Props   map[string]string // ptype.property -> value: header.d, smtp.mailfrom, ...
+}
+
+// AuthResults is a parsed Authentication-Results header
+type AuthResults struct {
+	AuthServID string // the host that evaluated the results
+	Results    []Result
+}
+
+// Field is a header field of a message
+type Field struct {
+	Name  string // lowercased
+	Value string // unfolded and trimmed
+	Raw   []byte // as in the message, with folded lines and trailing CRLF
+}
+
+// Fields splits the header of a message into its fields, in order. raw may
+// be the whole message or just its header block.
+func Fields(raw []byte) []Field {
+	var split, _ = message.SplitMessage(message.Canonicalize(raw))
+	var fields = make([]Field, 0, len(split))
+	for _, f := range split {
+		var i = strings.IndexByte(string(f), ':')
+		if i < 0 {
+			continue
+		}
+		var value = strings.NewReplacer("\r\n", "", "\n", "").Replace(string(f[i+1:]))
+		fields = append(fields, Field{
+			Name:  strings.ToLower(strings.TrimSpace(string(f[:i]))),
+			Value: strings.TrimSpace(value),
+			Raw:   f,
+		})
+	}
+	return fields
+}
+
+// ParseAuthenticationResults parses the value of an Authentication-Results
+// header. Comments are dropped and unknown properties kept as they are.
+func ParseAuthenticationResults(value string) (*AuthResults, error) {
+	var segments = splitTokens(tokenize(value))
+	if len(segments) == 0 || len(segments[0]) == 0 {
+		return nil, fmt.Errorf("authresults: missing authserv-id")
+	}
+	var res = &AuthResults{AuthServID: strings.ToLower(segments[0][0].text)}
+
+	for _, seg := range segments[1:] {
+		var pairs, _ = keyValues(seg)
+		if len(pairs) == 0 {
+			continue // "none", or an empty segment
+		}
+		var method = strings.ToLower(pairs[0].key)
+		if i := strings.IndexByte(method, '/'); i >= 0 {
+			method = strings.TrimSpace(method[:i]) // method version
+		}
+		var r = Result{
+			Method:  method,
+			Verdict: Verdict(strings.ToLower(pairs[0].value)),
+			Props:   make(map[string]string),
+		}
+		for _, p := range pairs[1:] {
+			var key = strings.ToLower(p.key)
+			if key == "reason" {
+				r.Reason = p.value
+				continue
+			}
+			r.Props[key] = p.value
+		}
+		res.Results = append(res.Results, r)
+	}
+	return res, nil
+}
+
+// ParseReceivedSPF parses the value of a Received-SPF header (RFC 7208
+// section 9.1) into an spf Result; key-value pairs such as client-ip and
+// envelope-from become properties.
+func ParseReceivedSPF(value string) Result {
+	var r = Result{Method: "spf", Props: make(map[string]string)}
+	var tokens = tokenize(value)
+	if len(tokens) == 0 {
+		return r
+	}
+	r.Verdict = Verdict(strings.ToLower(tokens[0].text))
+	for _, seg := range splitTokens(tokens[1:]) {
+		var pairs, _ = keyValues(seg)
+		for _, p := range pairs {
+			r.Props[strings.ToLower(p.key)] = p.value
+		}
+	}
+	return r
+}
+
+// Signature is a parsed DKIM-Signature header (RFC 6376 section 3.5)
+type Signature struct {
+	Version     string
+	Algorithm   string   // rsa-sha256, rsa-sha1 or ed25519-sha256
+	HeaderCanon string   // simple or relaxed
+	BodyCanon   string   // simple or relaxed
+	Domain      string   // d=, lowercased
+	Selector    string   // s=
+	Identity    string   // i=
+	Headers     []string // h=, lowercased
+	BodyHash    []byte   // bh=
+	Data        []byte   // b=
+	BodyLength  int64    // l=, -1 when the whole body is signed
+	Expiration  int64    // x=, 0 when unset
+	Timestamp   int64    // t=, 0 when unset
+}
+
+// ParseSignature parses the value of a DKIM-Signature header
+func ParseSignature(value string) (*Signature, error) {
+	var tags, err = parseTags(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig = &Signature{
+		Version:     tags["v"],
+		Algorithm:   strings.ToLower(tags["a"]),
+		HeaderCanon: "simple",
+		BodyCanon:   "simple",
+		Domain:      strings.ToLower(tags["d"]),
+		Selector:    tags["s"],
+		Identity:    tags["i"],
+		BodyLength:  -1,
+	}
+	for _, required := range []string{"v", "a", "b", "bh", "d", "h", "s"} {
+		if _, ok := tags[required]; !ok {
+			return nil, fmt.Errorf("authresults: DKIM-Signature without %s=", required)
+		}
+	}
+
+	if c := strings.ToLower(tags["c"]); c != "" {
+		var header, body, _ = strings.Cut(c, "/")
+		sig.HeaderCanon = header
+		if body != "" {
+			sig.BodyCanon = body
+		}
+	}
+	for _, h := range strings.Split(tags["h"], ":") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			sig.Headers = append(sig.Headers, h)
+		}
+	}
+	if sig.BodyHash, err = base64.StdEncoding.DecodeString(stripSpace(tags["bh"])); err != nil {
+		return nil, fmt.Errorf("authresults: invalid bh=: %w", err)
+	}
+	if sig.Data, err = base64.StdEncoding.DecodeString(stripSpace(tags["b"])); err != nil {
+		return nil, fmt.Errorf("authresults: invalid b=: %w", err)
+	}
+	for tag, dst := range map[string]*int64{"l": &sig.BodyLength, "x": &sig.Expiration, "t": &sig.Timestamp} {
+		if v, ok := tags[tag]; ok {
+			if *dst, err = strconv.ParseInt(stripSpace(v), 10, 64); err != nil {
+				return nil, fmt.Errorf("authresults: invalid %s=: %w", tag, err)
+			}
+		}
+	}
+	return sig, nil
+}
+
+// parseTags parses a DKIM tag list ("a=1; b=2"), used by signatures and
+// key records
+func parseTags(value string) (map[string]string, error) {
+	var tags = make(map[string]string)
+	for _, spec := range strings.Split(value, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		var name, v, ok = strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("authresults: invalid tag %q", spec)
+		}
+		name = strings.TrimSpace(name)
+		if _, dup := tags[name]; dup {
+			return nil, fmt.Errorf("authresults: duplicate tag %s=", name)
+		}
+		tags[name] = strings.TrimSpace(v)
+	}
+	return tags, nil
+}
+
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// token is a word, quoted string or one of the separators ";", "=" of a
+// structured header value
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits a structured header value, dropping (nested) comments
+func tokenize(s string) []token {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '(':
+			var depth = 0
+			for ; i < len(s); i++ {
+				if s[i] == '\\' {
+					i++
+					continue
+				}
+				if s[i] == '(' {
+					depth++
+				} else if s[i] == ')' {
+					depth--
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		case c == ';' || c == '=':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"':
+			var b strings.Builder
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			i++
+			tokens = append(tokens, token{text: b.String(), quoted: true})
+		default:
+			var start = i
+			for i < len(s) && !strings.ContainsRune(" \t\r\n();=\"", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, token{text: s[start:i]})
+		}
+	}
+	return tokens
+}
+
+// splitTokens splits tokens at ";"
+func splitTokens(tokens []token) [][]token {
+	var segments = [][]token{nil}
+	for _, t := range tokens {
+		if t.text == ";" && !t.quoted {
+			segments = append(segments, nil)
+			continue
+		}
+		segments[len(segments)-1] = append(segments[len(segments)-1], t)
+	}
+	return segments
+}
+
+type keyValue struct {
+	key, value string
+}
+
+// keyValues reads "key=value" pairs, returning the words that are not part
+// of one separately
+func keyValues(tokens []token) (pairs []keyValue, words []string) {
+	for i := 0; i < len(tokens); i++ {
+		if i+2 < len(tokens) && tokens[i+1].text == "=" && !tokens[i+1].quoted {
+			pairs = append(pairs, keyValue{key: tokens[i].text, value: tokens[i+2].text})
+			i += 2
+			continue
+		}
+		words = append(words, tokens[i].text)
+	}
+	return pairs, words
+}
@@ -0,0 +1,449 @@
+package authresults
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/opik/miau/internal/email/message"
+	"golang.org/x/net/html"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Level groups risk scores for display
+type Level string
+
+const (
+	LevelNone   Level = ""       // nothing suspicious found
+	LevelLow    Level = "low"    // minor signals only, e.g. a first-time sender
+	LevelMedium Level = "medium" // worth a warning
+	LevelHigh   Level = "high"   // likely spoofed or phishing
+)
+
+// Reason identifies a finding that raises the risk score
+type Reason string
+
+const (
+	ReasonDMARCFail       Reason = "dmarc_fail"        // the From domain's DMARC policy failed
+	ReasonDKIMFail        Reason = "dkim_fail"         // a DKIM signature is broken
+	ReasonSPFFail         Reason = "spf_fail"          // the sending host is not allowed
+	ReasonSPFSoftFail     Reason = "spf_softfail"      // the sending host is probably not allowed
+	ReasonAddressInName   Reason = "address_in_name"   // display name shows another address
+	ReasonContactSpoof    Reason = "contact_spoof"     // display name of a contact, other address
+	ReasonLookalikeDomain Reason = "lookalike_domain"  // domain resembles a known one
+	ReasonPunycodeDomain  Reason = "punycode_domain"   // internationalized domain
+	ReasonLinkMismatch    Reason = "link_mismatch"     // link text shows another site than its target
+	ReasonFirstTimeSender Reason = "first_time_sender" // no earlier mail with this address
+)
+
+// weights are the score points of each reason; a report scores at most 100
+var weights = map[Reason]int{
+	ReasonDMARCFail:       40,
+	ReasonDKIMFail:        25,
+	ReasonSPFFail:         20,
+	ReasonSPFSoftFail:     10,
+	ReasonAddressInName:   30,
+	ReasonContactSpoof:    40,
+	ReasonLookalikeDomain: 40,
+	ReasonPunycodeDomain:  15,
+	ReasonLinkMismatch:    25,
+	ReasonFirstTimeSender: 10,
+}
+
+// Finding is one suspicious signal of a message
+type Finding struct {
+	Reason Reason `json:"reason"`
+	Detail string `json:"detail,omitempty"` // e.g. the look-alike domain or link target
+}
+
+// Message is the part of an email the analyser looks at
+type Message struct {
+	Raw       []byte // the whole message, or just its header block
+	FromName  string
+	FromEmail string
+	HTML      string // HTML body, for the link check
+}
+
+// Sender is what the mailbox knows about the sender of a message
+type Sender struct {
+	Known        bool     // earlier mail was exchanged with the address, or it is a contact
+	NameEmails   []string // addresses of the contacts named like the display name
+	KnownDomains []string // domains of contacts and of the user's own addresses
+}
+
+// Report is the outcome of an analysis
+type Report struct {
+	AuthServID   string  // host whose Authentication-Results were used
+	SPF          Verdict // "" when unknown
+	DKIM         Verdict
+	DMARC        Verdict
+	DKIMVerified bool // DKIM was verified here, not read from Authentication-Results
+	Score        int  // 0-100
+	Level        Level
+	Findings     []Finding
+}
+
+// Analyzer rates messages. With a Resolver, DKIM signatures of whole
+// messages are verified locally; otherwise Authentication-Results is trusted.
+type Analyzer struct {
+	Resolver Resolver
+}
+
+// Analyze rates a message
+func (a *Analyzer) Analyze(ctx context.Context, msg Message, sender Sender) *Report {
+	var fields = Fields(msg.Raw)
+	var report = authenticate(fields)
+
+	if a.Resolver != nil && hasBody(msg.Raw) {
+		a.verifyDKIM(ctx, msg.Raw, report)
+	}
+
+	if report.DMARC == VerdictFail {
+		report.add(ReasonDMARCFail, "")
+	}
+	if report.DKIM == VerdictFail {
+		report.add(ReasonDKIMFail, "")
+	}
+	switch report.SPF {
+	case VerdictFail:
+		report.add(ReasonSPFFail, "")
+	case VerdictSoftFail:
+		report.add(ReasonSPFSoftFail, "")
+	}
+
+	var from = strings.ToLower(strings.TrimSpace(msg.FromEmail))
+	for _, addr := range addressPattern.FindAllString(msg.FromName, -1) {
+		if !strings.EqualFold(addr, from) {
+			report.add(ReasonAddressInName, addr)
+			break
+		}
+	}
+	if len(sender.NameEmails) > 0 && !containsFold(sender.NameEmails, from) {
+		report.add(ReasonContactSpoof, sender.NameEmails[0])
+	}
+
+	if _, domain, ok := strings.Cut(from, "@"); ok && domain != "" {
+		var known, lookalike = matchDomain(domain, sender.KnownDomains)
+		if lookalike != "" {
+			report.add(ReasonLookalikeDomain, lookalike)
+		}
+		if !known && isPunycode(domain) {
+			var unicode, _ = idna.ToUnicode(domain)
+			report.add(ReasonPunycodeDomain, unicode)
+		}
+	}
+
+	if msg.HTML != "" {
+		if text, target, ok := mismatchedLink(msg.HTML); ok {
+			report.add(ReasonLinkMismatch, text+" → "+target)
+		}
+	}
+
+	if !sender.Known {
+		report.add(ReasonFirstTimeSender, "")
+	}
+
+	switch {
+	case report.Score >= 60:
+		report.Level = LevelHigh
+	case report.Score >= 30:
+		report.Level = LevelMedium
+	case report.Score > 0:
+		report.Level = LevelLow
+	}
+	return report
+}
+
+func (r *Report) add(reason Reason, detail string) {
+	r.Findings = append(r.Findings, Finding{Reason: reason, Detail: detail})
+	r.Score += weights[reason]
+	if r.Score > 100 {
+		r.Score = 100
+	}
+}
+
+// authenticate reads the verdicts from the Authentication-Results headers
+// added by the receiving server. Anyone can put such a header in a
+// message, so only the topmost one, which the last hop added, and others
+// from the same host are trusted. Received-SPF fills in a missing SPF result.
+func authenticate(fields []Field) *Report {
+	var report = &Report{}
+	for _, f := range fields {
+		if f.Name != "authentication-results" {
+			continue
+		}
+		var ar, err = ParseAuthenticationResults(f.Value)
+		if err != nil {
+			continue
+		}
+		if report.AuthServID == "" {
+			report.AuthServID = ar.AuthServID
+		}
+		if ar.AuthServID != report.AuthServID {
+			continue
+		}
+		for _, res := range ar.Results {
+			switch res.Method {
+			case "spf":
+				if report.SPF == "" {
+					report.SPF = res.Verdict
+				}
+			case "dkim":
+				// One passing signature is enough
+				if report.DKIM == "" || res.Verdict == VerdictPass {
+					report.DKIM = res.Verdict
+				}
+			case "dmarc":
+				if report.DMARC == "" {
+					report.DMARC = res.Verdict
+				}
+			}
+		}
+	}
+
+	if report.SPF == "" {
+		for _, f := range fields {
+			if f.Name == "received-spf" {
+				report.SPF = ParseReceivedSPF(f.Value).Verdict
+				break
+			}
+		}
+	}
+	return report
+}
+
+// verifyDKIM verifies the signatures of the message. A local pass always
+// wins; a local failure only overrides a result the server didn't pass,
+// since servers may legitimately alter a message after checking it.
+func (a *Analyzer) verifyDKIM(ctx context.Context, raw []byte, report *Report) {
+	var results = VerifyDKIM(ctx, raw, a.Resolver)
+	if len(results) == 0 {
+		return
+	}
+
+	var verdict Verdict
+	for _, res := range results {
+		switch res.Verdict {
+		case VerdictPass:
+			report.DKIM, report.DKIMVerified = VerdictPass, true
+			return
+		case VerdictFail:
+			verdict = VerdictFail
+		case VerdictPermError:
+			if verdict == "" {
+				verdict = VerdictPermError
+			}
+		}
+	}
+	if verdict != "" && report.DKIM != VerdictPass {
+		report.DKIM, report.DKIMVerified = verdict, true
+	}
+}
+
+// hasBody reports whether raw is a whole message rather than a header block
+func hasBody(raw []byte) bool {
+	raw = message.Canonicalize(raw)
+	var i = bytes.Index(raw, []byte("\r\n\r\n"))
+	return i >= 0 && i+4 < len(raw)
+}
+
+var addressPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// matchDomain compares a sender domain with the known ones: known reports
+// an exact match (by registrable domain), lookalike the known domain it
+// imitates.
+func matchDomain(domain string, knownDomains []string) (known bool, lookalike string) {
+	var site = registrable(domain)
+	for _, k := range knownDomains {
+		if registrable(strings.ToLower(k)) == site {
+			return true, ""
+		}
+	}
+	for _, k := range knownDomains {
+		var other = registrable(strings.ToLower(k))
+		if confusable(site, other) {
+			return false, other
+		}
+	}
+	return false, ""
+}
+
+// confusable reports whether two different registrable domains are easy to
+// mistake for each other: same skeleton after mapping look-alike characters,
+// one typo away, or the known name with a word glued on ("paypal-login").
+// The same name under another suffix (example.com, example.de) is not.
+func confusable(a, b string) bool {
+	var nameA, suffixA, _ = strings.Cut(a, ".")
+	var nameB, suffixB, _ = strings.Cut(b, ".")
+	if a == b || nameA == nameB {
+		return false
+	}
+	if skeleton(a) == skeleton(b) {
+		return true
+	}
+	if len(nameB) < 5 {
+		return false
+	}
+	if suffixA == suffixB && editDistance(nameA, nameB) == 1 {
+		return true
+	}
+	return strings.HasPrefix(nameA, nameB+"-") || strings.HasSuffix(nameA, "-"+nameB)
+}
+
+// homoglyphs maps characters to the ASCII letter they look like
+var homoglyphs = map[rune]rune{
+	'0': 'o', '1': 'l', '3': 'e', '5': 's',
+	'а': 'a', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'х': 'x', 'у': 'y', // Cyrillic
+	'і': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd', 'ӏ': 'l', 'һ': 'h',
+	'ο': 'o', 'α': 'a', 'ν': 'v', 'ρ': 'p', 'ι': 'i', 'κ': 'k', 'τ': 't', // Greek
+	'á': 'a', 'à': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'é': 'e', 'è': 'e', 'ê': 'e',
+	'í': 'i', 'ì': 'i', 'ï': 'i', 'ó': 'o', 'ò': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'ü': 'u', 'ç': 'c', 'ñ': 'n',
+}
+
+// skeleton reduces a domain to the letters it looks like
+func skeleton(domain string) string {
+	if unicode, err := idna.ToUnicode(domain); err == nil {
+		domain = unicode
+	}
+	var mapped = strings.Map(func(r rune) rune {
+		if m, ok := homoglyphs[r]; ok {
+			return m
+		}
+		return r
+	}, strings.ToLower(domain))
+	return strings.NewReplacer("rn", "m", "vv", "w").Replace(mapped)
+}
+
+// editDistance is the optimal string alignment distance: insertions,
+// deletions, substitutions and swaps of adjacent characters
+func editDistance(a, b string) int {
+	var s, t = []rune(a), []rune(b)
+	var d = make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			var cost = 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func isPunycode(domain string) bool {
+	for _, label := range strings.Split(domain, ".") {
+		if strings.HasPrefix(label, "xn--") {
+			return true
+		}
+	}
+	return false
+}
+
+// registrable returns the registrable part of a host name
+// (mail.example.co.uk -> example.co.uk)
+func registrable(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if site, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return site
+	}
+	return host
+}
+
+// urlText matches link texts that show an address, like "www.bank.com" or
+// "https://bank.com/login"
+var urlText = regexp.MustCompile(`(?i)^(https?://)?([a-z0-9-]+\.)+[a-z]{2,}(:\d+)?(/\S*)?$`)
+
+// mismatchedLink finds the first link whose text shows an address on
+// another site than the one it points to
+func mismatchedLink(body string) (text, target string, ok bool) {
+	var doc, err = html.Parse(strings.NewReader(body))
+	if err != nil {
+		return "", "", false
+	}
+
+	var walk func(n *html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			var href = attr(n, "href")
+			var label = strings.TrimSpace(nodeText(n))
+			if href != "" && urlText.MatchString(label) {
+				var shown, linked = hostOf(label), hostOf(href)
+				if shown != "" && linked != "" && isPublicHost(shown) && registrable(shown) != registrable(linked) {
+					text, target = label, linked
+					return true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	ok = walk(doc)
+	return text, target, ok
+}
+
+// hostOf returns the host of an http(s) address, "" for anything else
+func hostOf(s string) string {
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	var u, err = url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// isPublicHost reports whether the host ends in an ICANN suffix, so texts
+// like "report.final" are not taken for addresses
+func isPublicHost(host string) bool {
+	var _, icann = publicsuffix.PublicSuffix(host)
+	return icann
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
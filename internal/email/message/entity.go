@@ -0,0 +1,157 @@
+package message
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Helpers for wrapping and unwrapping the top-level MIME entity of a raw
+// message, shared by the PGP/MIME and S/MIME packages. They work on CRLF
+// line endings and keep the exact bytes of the entity, which is what
+// signatures cover.
+
+// FirstPart returns the exact bytes of the first body part of a multipart
+// body, which is what a multipart/signed signature covers, and the body
+// from the delimiter that ends it.
+func FirstPart(body []byte, boundary string) (part, rest []byte, ok bool) {
+	if boundary == "" {
+		return nil, nil, false
+	}
+	var delim = []byte("--" + boundary)
+	var start int
+	if bytes.HasPrefix(body, delim) {
+		start = len(delim)
+	} else {
+		var i = bytes.Index(body, append([]byte("\r\n"), delim...))
+		if i < 0 {
+			return nil, nil, false
+		}
+		start = i + 2 + len(delim)
+	}
+	var eol = bytes.Index(body[start:], []byte("\r\n"))
+	if eol < 0 {
+		return nil, nil, false
+	}
+	start += eol + 2
+
+	var end = bytes.Index(body[start:], append([]byte("\r\n"), delim...))
+	if end < 0 {
+		return nil, nil, false
+	}
+	return body[start : start+end], body[start+end+2:], true
+}
+
+// SplitMessage splits a message into its header fields, each with folded
+// lines and trailing CRLF, and its body.
+func SplitMessage(raw []byte) (fields [][]byte, body []byte) {
+	var header = raw
+	if i := bytes.Index(raw, []byte("\r\n\r\n")); i >= 0 {
+		header, body = raw[:i+2], raw[i+4:]
+	} else if bytes.HasPrefix(raw, []byte("\r\n")) {
+		return nil, raw[2:]
+	}
+	for len(header) > 0 {
+		var end = bytes.Index(header, []byte("\r\n"))
+		if end < 0 {
+			end = len(header)
+		} else {
+			end += 2
+		}
+		var line = header[:end]
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] = append(fields[len(fields)-1], line...)
+		} else {
+			fields = append(fields, append([]byte(nil), line...))
+		}
+		header = header[end:]
+	}
+	return fields, body
+}
+
+// SplitEntity separates the outer header fields of a message from its
+// top-level MIME entity: the Content-* fields plus the body.
+func SplitEntity(raw []byte) (outer [][]byte, entity []byte) {
+	var fields, body = SplitMessage(raw)
+	var content bytes.Buffer
+	for _, f := range fields {
+		if strings.HasPrefix(fieldName(f), "content-") {
+			content.Write(f)
+		} else {
+			outer = append(outer, f)
+		}
+	}
+	if content.Len() == 0 {
+		content.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	}
+	content.WriteString("\r\n")
+	content.Write(body)
+	return outer, content.Bytes()
+}
+
+// Assemble writes the outer fields, a new Content-Type and the body.
+func Assemble(outer [][]byte, contentType string, body []byte) []byte {
+	var buf bytes.Buffer
+	var hasVersion bool
+	for _, f := range outer {
+		if fieldName(f) == "mime-version" {
+			hasVersion = true
+		}
+		buf.Write(f)
+	}
+	if !hasVersion {
+		buf.WriteString("MIME-Version: 1.0\r\n")
+	}
+	buf.WriteString("Content-Type: " + contentType + "\r\n\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// AssembleEntity puts an entity (its own Content-* fields and body) back
+// under the outer fields of the message.
+func AssembleEntity(outer [][]byte, entity []byte) []byte {
+	var buf bytes.Buffer
+	for _, f := range outer {
+		if !strings.HasPrefix(fieldName(f), "content-") {
+			buf.Write(f)
+		}
+	}
+	buf.Write(entity)
+	return buf.Bytes()
+}
+
+// HeaderValue returns the unfolded value of the first field with the given
+// name.
+func HeaderValue(raw []byte, name string) string {
+	var fields, _ = SplitMessage(raw)
+	for _, f := range fields {
+		if fieldName(f) == strings.ToLower(name) {
+			var value = string(f[bytes.IndexByte(f, ':')+1:])
+			value = strings.NewReplacer("\r\n", "", "\n", "").Replace(value)
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func fieldName(f []byte) string {
+	var i = bytes.IndexByte(f, ':')
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(string(f[:i])))
+}
+
+// Canonicalize converts bare LF line endings to CRLF.
+func Canonicalize(b []byte) []byte {
+	if !bytes.Contains(b, []byte("\n")) {
+		return b
+	}
+	var out = make([]byte, 0, len(b)+bytes.Count(b, []byte("\n")))
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			out = append(out, '\r')
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,429 @@
+// Package message builds outgoing RFC 5322 / MIME messages.
+// It is shared by the SMTP client and the Gmail API client so both send
+// paths produce the same structure: multipart/alternative (text + HTML),
+// multipart/related for inline CID images and multipart/mixed for attachments.
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Attachment is a file attached to an outgoing message
+type Attachment struct {
+	Filename    string
+	ContentType string // defaults to the type guessed from the extension
+	ContentID   string // for inline images referenced as cid:xxx (without <>)
+	Inline      bool   // inline part of multipart/related (needs ContentID)
+	Data        []byte
+}
+
+// Header is an extra header written as-is (after encoding) in the given order
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Message is an outgoing email
+type Message struct {
+	From       string // "Name <email>" or just the address
+	To         []string
+	Cc         []string
+	Bcc        []string // only written when WriteBcc is set (SMTP uses the envelope)
+	ReplyTo    string
+	Subject    string
+	Date       time.Time // defaults to now
+	MessageID  string    // with angle brackets; omitted if empty
+	InReplyTo  string
+	References string
+	Headers    []Header
+
+	TextBody    string // plain-text version; derived from HTMLBody if empty
+	HTMLBody    string
+	Attachments []Attachment
+
+	// Calendar is an iCalendar object sent as an iMIP (RFC 6047) alternative
+	// of the bodies, with CalendarMethod (REQUEST, REPLY...) in its Content-Type
+	Calendar       []byte
+	CalendarMethod string
+
+	// WriteBcc includes the Bcc header (the Gmail API reads and strips it)
+	WriteBcc bool
+}
+
+// maxLineLen is the recommended header line length (RFC 5322 2.1.1)
+const maxLineLen = 78
+
+// Build renders the message with CRLF line endings
+func (m *Message) Build() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := m.write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// write renders the message into w
+func (m *Message) write(w io.Writer) error {
+	var date = m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var h strings.Builder
+	writeHeader(&h, "From", formatAddress(m.From))
+	if len(m.To) > 0 {
+		writeHeader(&h, "To", formatAddressList(m.To))
+	}
+	if len(m.Cc) > 0 {
+		writeHeader(&h, "Cc", formatAddressList(m.Cc))
+	}
+	if m.WriteBcc && len(m.Bcc) > 0 {
+		writeHeader(&h, "Bcc", formatAddressList(m.Bcc))
+	}
+	if m.ReplyTo != "" {
+		writeHeader(&h, "Reply-To", formatAddress(m.ReplyTo))
+	}
+	writeHeader(&h, "Subject", EncodeHeader(m.Subject))
+	writeHeader(&h, "Date", date.Format(time.RFC1123Z))
+	if m.MessageID != "" {
+		writeHeader(&h, "Message-ID", m.MessageID)
+	}
+	if m.InReplyTo != "" {
+		writeHeader(&h, "In-Reply-To", m.InReplyTo)
+	}
+	if m.References != "" {
+		writeHeader(&h, "References", m.References)
+	}
+	for _, extra := range m.Headers {
+		writeHeader(&h, extra.Key, EncodeHeader(extra.Value))
+	}
+	writeHeader(&h, "MIME-Version", "1.0")
+
+	if _, err := io.WriteString(w, h.String()); err != nil {
+		return err
+	}
+
+	return m.rootPart().write(w)
+}
+
+// part is a node of the MIME tree
+type part struct {
+	header   textproto.MIMEHeader
+	body     []byte // leaf content, already decoded
+	encoding string // "quoted-printable" or "base64" for leaves
+	children []*part
+	subtype  string // multipart subtype when children != nil
+}
+
+// rootPart arranges bodies and attachments into the MIME tree:
+//
+//	mixed
+//	├── related
+//	│   ├── alternative
+//	│   │   ├── text/plain
+//	│   │   ├── text/html
+//	│   │   └── text/calendar
+//	│   └── inline images
+//	└── attachments
+//
+// Levels with a single child are collapsed.
+func (m *Message) rootPart() *part {
+	var text = m.TextBody
+	if text == "" && m.HTMLBody != "" {
+		text = HTMLToText(m.HTMLBody)
+	}
+
+	var body = textLeaf("text/plain", text)
+	var alternatives = []*part{body}
+	if m.HTMLBody != "" {
+		alternatives = append(alternatives, textLeaf("text/html", m.HTMLBody))
+	}
+	if len(m.Calendar) > 0 {
+		alternatives = append(alternatives, calendarLeaf(m.Calendar, m.CalendarMethod))
+	}
+	if len(alternatives) > 1 {
+		body = &part{subtype: "alternative", children: alternatives}
+	}
+
+	var inline, attached []*part
+	for _, att := range m.Attachments {
+		// Inline só faz sentido com HTML referenciando o cid
+		if att.Inline && att.ContentID != "" && m.HTMLBody != "" {
+			inline = append(inline, attachmentLeaf(att, true))
+		} else {
+			attached = append(attached, attachmentLeaf(att, false))
+		}
+	}
+
+	if len(inline) > 0 {
+		body = &part{subtype: "related", children: append([]*part{body}, inline...)}
+	}
+	if len(attached) > 0 {
+		body = &part{subtype: "mixed", children: append([]*part{body}, attached...)}
+	}
+	return body
+}
+
+// textLeaf creates a UTF-8 quoted-printable text part
+func textLeaf(contentType, content string) *part {
+	var header = textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=UTF-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return &part{header: header, body: []byte(content), encoding: "quoted-printable"}
+}
+
+// calendarLeaf creates the text/calendar part of an iMIP message
+func calendarLeaf(data []byte, method string) *part {
+	var params = map[string]string{"charset": "UTF-8"}
+	if method != "" {
+		params["method"] = method
+	}
+
+	var header = textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType("text/calendar", params))
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return &part{header: header, body: data, encoding: "quoted-printable"}
+}
+
+// attachmentLeaf creates a base64 attachment part with RFC 2231 filename
+func attachmentLeaf(att Attachment, inline bool) *part {
+	var contentType = att.ContentType
+	if contentType == "" {
+		contentType = guessContentType(att.Filename)
+	}
+
+	var header = textproto.MIMEHeader{}
+	var disposition = "attachment"
+	if inline {
+		disposition = "inline"
+	}
+
+	if att.Filename != "" {
+		header.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"name": att.Filename}))
+		header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": att.Filename}))
+	} else {
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Disposition", disposition)
+	}
+	header.Set("Content-Transfer-Encoding", "base64")
+	if att.ContentID != "" {
+		header.Set("Content-ID", "<"+strings.Trim(att.ContentID, "<>")+">")
+	}
+	return &part{header: header, body: att.Data, encoding: "base64"}
+}
+
+// write renders the part as the top level of the message: its headers
+// continue the message header block.
+func (p *part) write(w io.Writer) error {
+	var h strings.Builder
+	var boundary string
+	if p.children == nil {
+		for _, key := range []string{"Content-Type", "Content-Transfer-Encoding", "Content-Disposition", "Content-ID"} {
+			if v := p.header.Get(key); v != "" {
+				writeHeader(&h, key, v)
+			}
+		}
+	} else {
+		boundary = newBoundary()
+		writeHeader(&h, "Content-Type", fmt.Sprintf("multipart/%s; boundary=%q", p.subtype, boundary))
+	}
+	h.WriteString("\r\n")
+	if _, err := io.WriteString(w, h.String()); err != nil {
+		return err
+	}
+
+	if p.children == nil {
+		return p.writeBody(w)
+	}
+	return p.writeMultipart(w, boundary)
+}
+
+// writeMultipart renders the children separated by boundary
+func (p *part) writeMultipart(w io.Writer, boundary string) error {
+	var mw = multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	for _, child := range p.children {
+		var header = child.header
+		var childBoundary string
+		if child.children != nil {
+			childBoundary = newBoundary()
+			header = textproto.MIMEHeader{}
+			header.Set("Content-Type", fmt.Sprintf("multipart/%s; boundary=%q", child.subtype, childBoundary))
+		}
+
+		var pw, err = mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		if child.children != nil {
+			err = child.writeMultipart(pw, childBoundary)
+		} else {
+			err = child.writeBody(pw)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// newBoundary returns a random multipart boundary
+func newBoundary() string {
+	return multipart.NewWriter(io.Discard).Boundary()
+}
+
+// writeBody encodes a leaf body
+func (p *part) writeBody(w io.Writer) error {
+	switch p.encoding {
+	case "base64":
+		return writeBase64(w, p.body)
+	default:
+		var qp = quotedprintable.NewWriter(w)
+		if _, err := qp.Write(normalizeNewlines(p.body)); err != nil {
+			return err
+		}
+		return qp.Close()
+	}
+}
+
+// writeBase64 writes base64 in 76-char lines (RFC 2045 6.8)
+func writeBase64(w io.Writer, data []byte) error {
+	var encoded = base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	if encoded == "" {
+		return nil
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+// normalizeNewlines converts bare LF to CRLF so quoted-printable keeps line breaks
+func normalizeNewlines(b []byte) []byte {
+	var s = strings.ReplaceAll(string(b), "\r\n", "\n")
+	return []byte(strings.ReplaceAll(s, "\n", "\r\n"))
+}
+
+// EncodeHeader encodes a header value with RFC 2047 when it has non-ASCII
+func EncodeHeader(value string) string {
+	return mime.QEncoding.Encode("UTF-8", value)
+}
+
+// formatAddress encodes the display name of an address (RFC 2047).
+// Values that don't parse as an address are written unchanged.
+func formatAddress(value string) string {
+	var addr, err = mail.ParseAddress(value)
+	if err != nil {
+		return value
+	}
+	return addr.String()
+}
+
+// formatAddressList formats a list of addresses
+func formatAddressList(values []string) string {
+	var formatted = make([]string, len(values))
+	for i, v := range values {
+		formatted[i] = formatAddress(v)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// writeHeader writes "Key: value" folding long lines at spaces
+func writeHeader(b *strings.Builder, key, value string) {
+	var line = key + ": " + value
+	for len(line) > maxLineLen {
+		// Procura o último espaço antes do limite (nunca no nome do header)
+		var cut = strings.LastIndex(line[:maxLineLen], " ")
+		if cut <= len(key)+1 {
+			cut = strings.Index(line[len(key)+2:], " ")
+			if cut < 0 {
+				break
+			}
+			cut += len(key) + 2
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n")
+		line = line[cut:] // continua com o espaço (folding whitespace)
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// guessContentType returns the MIME type for a filename extension
+func guessContentType(filename string) string {
+	if i := strings.LastIndex(filename, "."); i >= 0 {
+		if t := mime.TypeByExtension(strings.ToLower(filename[i:])); t != "" {
+			return t
+		}
+	}
+	return "application/octet-stream"
+}
+
+// HTMLToText derives a readable plain-text alternative from HTML
+func HTMLToText(s string) string {
+	var out strings.Builder
+	var inTag, skip bool
+	var tag strings.Builder
+
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+			tag.Reset()
+		case r == '>' && inTag:
+			inTag = false
+			var name = strings.ToLower(strings.Fields(tag.String() + " x")[0])
+			switch name {
+			case "script", "style":
+				skip = true
+			case "/script", "/style":
+				skip = false
+			case "br", "br/", "/p", "/div", "/li", "/tr", "/h1", "/h2", "/h3", "/h4", "/h5", "/h6":
+				out.WriteString("\n")
+			case "li":
+				out.WriteString("- ")
+			}
+		case inTag:
+			tag.WriteRune(r)
+		case !skip:
+			out.WriteRune(r)
+		}
+	}
+
+	// Colapsa linhas em branco repetidas
+	var lines = strings.Split(html.UnescapeString(out.String()), "\n")
+	var result []string
+	var blank = 0
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		result = append(result, line)
+	}
+	return strings.TrimSpace(strings.Join(result, "\n"))
+}
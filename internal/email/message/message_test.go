@@ -0,0 +1,229 @@
+package message
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// parse reads a built message back and returns the root media type and params
+func parse(t *testing.T, raw []byte) (*mail.Message, string, map[string]string) {
+	t.Helper()
+	var msg, err = mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Failed to parse message: %v\n%s", err, raw)
+	}
+	var mediaType, params, err2 = mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err2 != nil {
+		t.Fatalf("Bad Content-Type %q: %v", msg.Header.Get("Content-Type"), err2)
+	}
+	return msg, mediaType, params
+}
+
+// readParts returns the parts of a multipart body
+func readParts(t *testing.T, body io.Reader, boundary string) []*multipart.Part {
+	t.Helper()
+	var parts []*multipart.Part
+	var reader = multipart.NewReader(body, boundary)
+	for {
+		var p, err = reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read part: %v", err)
+		}
+		// Lê o conteúdo agora: o reader invalida a parte no próximo NextPart
+		var data, _ = io.ReadAll(p)
+		p.Header.Set("X-Test-Body", string(data))
+		parts = append(parts, p)
+	}
+	return parts
+}
+
+func TestBuildPlainText(t *testing.T) {
+	var m = &Message{
+		From:     "Zé Ninguém <ze@example.com>",
+		To:       []string{"ana@example.com"},
+		Subject:  "Relatório de ações",
+		TextBody: "Olá,\nsegue o relatório.",
+	}
+
+	var raw, err = m.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	var msg, mediaType, params = parse(t, raw)
+	if mediaType != "text/plain" || params["charset"] != "UTF-8" {
+		t.Errorf("Expected text/plain UTF-8, got %s %v", mediaType, params)
+	}
+
+	var dec = new(mime.WordDecoder)
+	var subject, _ = dec.DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Relatório de ações" {
+		t.Errorf("Subject not RFC 2047 round-tripped: %q", subject)
+	}
+	if strings.Contains(string(raw), "Relatório") {
+		t.Error("Raw headers must be ASCII-only")
+	}
+
+	var from, _ = mail.ParseAddress(msg.Header.Get("From"))
+	if from == nil || from.Name != "Zé Ninguém" {
+		t.Errorf("From display name not encoded correctly: %q", msg.Header.Get("From"))
+	}
+}
+
+func TestBuildAlternativeWithAttachments(t *testing.T) {
+	var m = &Message{
+		From:     "me@example.com",
+		To:       []string{"you@example.com"},
+		Bcc:      []string{"hidden@example.com"},
+		Subject:  "Fotos",
+		HTMLBody: `<p>Veja <img src="cid:logo123"></p>`,
+		Attachments: []Attachment{
+			{Filename: "logo.png", ContentType: "image/png", ContentID: "logo123", Inline: true, Data: []byte("png-bytes")},
+			{Filename: "orçamento 2025.pdf", Data: bytes.Repeat([]byte("x"), 200)},
+		},
+	}
+
+	var raw, err = m.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if strings.Contains(string(raw), "hidden@example.com") {
+		t.Error("Bcc must not be written unless WriteBcc is set")
+	}
+
+	var msg, mediaType, params = parse(t, raw)
+	if mediaType != "multipart/mixed" {
+		t.Fatalf("Expected multipart/mixed root, got %s", mediaType)
+	}
+
+	var mixed = readParts(t, msg.Body, params["boundary"])
+	if len(mixed) != 2 {
+		t.Fatalf("Expected 2 mixed parts, got %d", len(mixed))
+	}
+
+	// Attachment: RFC 2231 filename decodes back to the original name
+	var _, dispParams, _ = mime.ParseMediaType(mixed[1].Header.Get("Content-Disposition"))
+	if dispParams["filename"] != "orçamento 2025.pdf" {
+		t.Errorf("Filename not round-tripped: %q", mixed[1].Header.Get("Content-Disposition"))
+	}
+	if ct := mixed[1].Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/pdf") {
+		t.Errorf("Expected guessed application/pdf, got %q", ct)
+	}
+
+	// related -> alternative + inline image
+	var relType, relParams, _ = mime.ParseMediaType(mixed[0].Header.Get("Content-Type"))
+	if relType != "multipart/related" {
+		t.Fatalf("Expected multipart/related, got %s", relType)
+	}
+	var related = readParts(t, strings.NewReader(mixed[0].Header.Get("X-Test-Body")), relParams["boundary"])
+	if len(related) != 2 {
+		t.Fatalf("Expected 2 related parts, got %d", len(related))
+	}
+	if cid := related[1].Header.Get("Content-Id"); cid != "<logo123>" {
+		t.Errorf("Expected Content-ID <logo123>, got %q", cid)
+	}
+
+	var altType, altParams, _ = mime.ParseMediaType(related[0].Header.Get("Content-Type"))
+	if altType != "multipart/alternative" {
+		t.Fatalf("Expected multipart/alternative, got %s", altType)
+	}
+	var alternative = readParts(t, strings.NewReader(related[0].Header.Get("X-Test-Body")), altParams["boundary"])
+	if len(alternative) != 2 {
+		t.Fatalf("Expected text and HTML parts, got %d", len(alternative))
+	}
+	if !strings.HasPrefix(alternative[0].Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("First alternative must be text/plain, got %q", alternative[0].Header.Get("Content-Type"))
+	}
+	// multipart.Reader decodes quoted-printable transparently
+	if body := alternative[0].Header.Get("X-Test-Body"); !strings.Contains(body, "Veja") {
+		t.Errorf("Plain-text alternative not derived from HTML: %q", body)
+	}
+}
+
+func TestBuildWriteBcc(t *testing.T) {
+	var m = &Message{
+		From:     "me@example.com",
+		To:       []string{"you@example.com"},
+		Bcc:      []string{"hidden@example.com"},
+		Subject:  "Hi",
+		TextBody: "hi",
+		WriteBcc: true,
+	}
+	var raw, _ = m.Build()
+	var msg, _, _ = parse(t, raw)
+	if msg.Header.Get("Bcc") != "<hidden@example.com>" {
+		t.Errorf("Expected Bcc header, got %q", msg.Header.Get("Bcc"))
+	}
+}
+
+func TestHeaderFolding(t *testing.T) {
+	var m = &Message{
+		From:     "me@example.com",
+		To:       []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"},
+		Subject:  strings.Repeat("palavra ", 30),
+		TextBody: "x",
+	}
+	var raw, _ = m.Build()
+
+	var header = string(raw[:bytes.Index(raw, []byte("\r\n\r\n"))])
+	for _, line := range strings.Split(header, "\r\n") {
+		if len(line) > maxLineLen {
+			t.Errorf("Header line longer than %d: %q", maxLineLen, line)
+		}
+	}
+
+	var msg, _, _ = parse(t, raw)
+	if got := msg.Header.Get("Subject"); got != strings.TrimSpace(strings.Repeat("palavra ", 30)) {
+		t.Errorf("Folded subject not unfolded correctly: %q", got)
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	var got = HTMLToText("<style>p{}</style><p>Olá &amp; bem-vindo</p><ul><li>um</li><li>dois</li></ul>")
+	var want = "Olá & bem-vindo\n- um\n- dois"
+	if got != want {
+		t.Errorf("HTMLToText = %q, want %q", got, want)
+	}
+}
+
+func TestBuildCalendarReply(t *testing.T) {
+	var calendar = "BEGIN:VCALENDAR\r\nMETHOD:REPLY\r\nEND:VCALENDAR\r\n"
+	var m = &Message{
+		From:           "ze@example.com",
+		To:             []string{"ana@example.com"},
+		Subject:        "Accepted: Weekly sync",
+		TextBody:       "Zé accepted the invitation.",
+		Calendar:       []byte(calendar),
+		CalendarMethod: "REPLY",
+	}
+
+	var raw, err = m.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	var msg, mediaType, params = parse(t, raw)
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Expected multipart/alternative, got %s", mediaType)
+	}
+
+	var parts = readParts(t, msg.Body, params["boundary"])
+	if len(parts) != 2 {
+		t.Fatalf("Expected text and calendar parts, got %d", len(parts))
+	}
+	var calType, calParams, _ = mime.ParseMediaType(parts[1].Header.Get("Content-Type"))
+	if calType != "text/calendar" || calParams["method"] != "REPLY" {
+		t.Errorf("Expected text/calendar with method REPLY, got %s %v", calType, calParams)
+	}
+	if parts[1].Header.Get("X-Test-Body") != calendar {
+		t.Errorf("Calendar not round-tripped: %q", parts[1].Header.Get("X-Test-Body"))
+	}
+}
@@ -35,6 +35,7 @@ type ParsedEmail struct {
 	HTMLBody    string
 	Attachments []Attachment
 	CIDMap      map[string]string // Maps Content-ID to data URI
+	Calendar    []byte            // text/calendar part (meeting invitation), nil if none
 }
 
 // Parse extracts all content from raw email data
@@ -52,6 +53,7 @@ func Parse(rawData []byte) (*ParsedEmail, error) {
 	}
 
 	parsed.Attachments = ExtractAttachments(rawData)
+	parsed.Calendar = ExtractCalendar(rawData)
 
 	return parsed, nil
 }
@@ -388,6 +390,66 @@ func FindHTMLPart(r io.Reader, boundary string) string {
 	return ""
 }
 
+// ExtractCalendar extracts the first text/calendar part (an iMIP meeting
+// invitation, reply or cancellation) from raw email data, decoded to UTF-8
+func ExtractCalendar(rawData []byte) []byte {
+	var msg, err = mail.ReadMessage(bytes.NewReader(rawData))
+	if err != nil {
+		return nil
+	}
+
+	var mediaType, params, _ = mime.ParseMediaType(msg.Header.Get("Content-Type"))
+
+	// Direct calendar
+	if isCalendarType(mediaType) {
+		var body, _ = io.ReadAll(msg.Body)
+		return []byte(DecodeBodyWithCharset(body, msg.Header.Get("Content-Transfer-Encoding"), params["charset"]))
+	}
+
+	// Multipart - find the calendar part
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var boundary = params["boundary"]
+		if boundary != "" {
+			return findCalendarPart(msg.Body, boundary)
+		}
+	}
+
+	return nil
+}
+
+func findCalendarPart(r io.Reader, boundary string) []byte {
+	var mr = multipart.NewReader(r, boundary)
+	for {
+		var part, err = mr.NextPart()
+		if err != nil {
+			break
+		}
+
+		var mediaType, params, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+
+		if isCalendarType(mediaType) {
+			var body, _ = io.ReadAll(part)
+			return []byte(DecodeBodyWithCharset(body, part.Header.Get("Content-Transfer-Encoding"), params["charset"]))
+		}
+
+		// Nested multipart
+		if strings.HasPrefix(mediaType, "multipart/") {
+			var boundary = params["boundary"]
+			if boundary != "" {
+				if calendar := findCalendarPart(part, boundary); calendar != nil {
+					return calendar
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isCalendarType reports whether a media type carries iCalendar data
+func isCalendarType(mediaType string) bool {
+	return mediaType == "text/calendar" || mediaType == "application/ics"
+}
+
 // DecodeBody decodes body with transfer encoding
 func DecodeBody(body []byte, encoding string) string {
 	return DecodeBodyWithCharset(body, encoding, "")
@@ -16,6 +16,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/opik/miau/internal/email/message"
 	"golang.org/x/oauth2"
 )
 
@@ -131,10 +132,15 @@ type SendRequest struct {
 	Bcc             []string
 	Subject         string
 	Body            string
+	TextBody        string // Versão texto quando IsHTML (gerada do HTML se vazia)
 	InReplyTo       string
 	References      string
 	IsHTML          bool
+	Attachments     []message.Attachment
+	Calendar        []byte // iCalendar (iMIP) enviado como alternativa do corpo
+	CalendarMethod  string // método iTIP do Calendar (REQUEST, REPLY...)
 	ClassificationID string // ID do label de classificação (ex: "Label_123")
+	Protect         func(raw []byte) ([]byte, error) // transforma a mensagem montada antes do envio (PGP/MIME)
 }
 
 // SendResponse representa a resposta do envio
@@ -166,10 +172,18 @@ type GmailMessage struct {
 // SendMessage envia um email usando a Gmail API
 func (c *Client) SendMessage(req *SendRequest) (*SendResponse, error) {
 	// Constrói a mensagem RFC 2822
-	var rawMessage = c.buildRFC2822Message(req)
+	var rawMessage, errBuild = c.buildRFC2822Message(req)
+	if errBuild != nil {
+		return nil, fmt.Errorf("erro ao montar mensagem: %w", errBuild)
+	}
+	if req.Protect != nil {
+		if rawMessage, errBuild = req.Protect(rawMessage); errBuild != nil {
+			return nil, fmt.Errorf("erro ao proteger mensagem: %w", errBuild)
+		}
+	}
 
 	// Codifica em base64url
-	var encoded = base64.URLEncoding.EncodeToString([]byte(rawMessage))
+	var encoded = base64.URLEncoding.EncodeToString(rawMessage)
 
 	// Monta o payload
 	var message = GmailMessage{
@@ -219,46 +233,39 @@ func (c *Client) SendMessage(req *SendRequest) (*SendResponse, error) {
 	return &result, nil
 }
 
-// buildRFC2822Message constrói uma mensagem no formato RFC 2822
-func (c *Client) buildRFC2822Message(req *SendRequest) string {
-	var msg strings.Builder
-
-	// Headers obrigatórios
-	msg.WriteString(fmt.Sprintf("From: %s\r\n", c.email))
-	msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(req.To, ", ")))
-
-	if len(req.Cc) > 0 {
-		msg.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(req.Cc, ", ")))
+// buildRFC2822Message constrói uma mensagem no formato RFC 2822.
+// Usa o mesmo builder MIME do SMTP; o Bcc vai no header porque a API
+// o lê para definir os destinatários (e o remove antes de entregar).
+func (c *Client) buildRFC2822Message(req *SendRequest) ([]byte, error) {
+	var msg = &message.Message{
+		From:           c.email,
+		To:             req.To,
+		Cc:             req.Cc,
+		Bcc:            req.Bcc,
+		Subject:        req.Subject,
+		InReplyTo:      req.InReplyTo,
+		References:     req.References,
+		Attachments:    req.Attachments,
+		Calendar:       req.Calendar,
+		CalendarMethod: req.CalendarMethod,
+		WriteBcc:       true,
 	}
-
-	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", req.Subject))
-	msg.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
-
-	// Headers de threading
-	if req.InReplyTo != "" {
-		msg.WriteString(fmt.Sprintf("In-Reply-To: %s\r\n", req.InReplyTo))
-	}
-	if req.References != "" {
-		msg.WriteString(fmt.Sprintf("References: %s\r\n", req.References))
-	}
-
-	// MIME
-	msg.WriteString("MIME-Version: 1.0\r\n")
 	if req.IsHTML {
-		msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+		msg.HTMLBody = req.Body
+		msg.TextBody = req.TextBody
 	} else {
-		msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		msg.TextBody = req.Body
 	}
-
-	// Corpo
-	msg.WriteString("\r\n")
-	msg.WriteString(req.Body)
-
-	return msg.String()
+	return msg.Build()
 }
 
 // ListClassificationLabels lista os labels de classificação disponíveis
 func (c *Client) ListClassificationLabels() ([]Label, error) {
+	return c.ListLabels()
+}
+
+// ListLabels lista todos os labels da conta (de sistema e do usuário)
+func (c *Client) ListLabels() ([]Label, error) {
 	var url = "https://gmail.googleapis.com/gmail/v1/users/me/labels"
 
 	var resp, err = c.httpClient.Get(url)
@@ -289,6 +296,8 @@ type Label struct {
 	Type                  string `json:"type"`
 	MessageListVisibility string `json:"messageListVisibility,omitempty"`
 	LabelListVisibility   string `json:"labelListVisibility,omitempty"`
+	MessagesTotal         int    `json:"messagesTotal,omitempty"`  // só em labels.get
+	MessagesUnread        int    `json:"messagesUnread,omitempty"` // só em labels.get
 }
 
 // === ARCHIVE/TRASH OPERATIONS ===
@@ -524,10 +533,100 @@ type MessageThreadInfo struct {
 // Uses Gmail Batch API: POST https://www.googleapis.com/batch/gmail/v1
 // Returns a map of RFC822 Message-ID -> ThreadID
 func (c *Client) BatchGetMessageMetadata(messages []MessageInfo) (map[string]string, error) {
-	if len(messages) == 0 {
-		return make(map[string]string), nil
+	var metadata, err = c.batchGetMetadata(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make(map[string]string)
+	for _, m := range metadata {
+		// Map RFC822 Message-ID to ThreadID
+		if m.RFC822MsgID != "" && m.ThreadID != "" {
+			// Find the original message to get its threadID (from messages.list)
+			if m.Index < len(messages) {
+				result[m.RFC822MsgID] = messages[m.Index].ThreadID
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// batchMetadata is one message of a batchGetMetadata response
+type batchMetadata struct {
+	Index       int // position of the part in the batch response
+	ID          string
+	ThreadID    string
+	RFC822MsgID string
+	LabelIDs    []string
+}
+
+// batchGetMetadata fetches the metadata (Message-ID header, thread and
+// labels) of up to 100 messages in a single batch request
+func (c *Client) batchGetMetadata(messages []MessageInfo) ([]batchMetadata, error) {
+	var paths = make([]string, len(messages))
+	for i, msg := range messages {
+		paths[i] = fmt.Sprintf("/gmail/v1/users/me/messages/%s?format=metadata&metadataHeaders=Message-ID", msg.ID)
+	}
+
+	var parts, err = c.batchGet(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []batchMetadata
+	for _, part := range parts {
+		// Parse JSON response
+		var msgResp struct {
+			ID       string   `json:"id"`
+			ThreadID string   `json:"threadId"`
+			LabelIDs []string `json:"labelIds"`
+			Payload  struct {
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+			} `json:"payload"`
+		}
+
+		if err := json.Unmarshal(part.Body, &msgResp); err != nil {
+			continue
+		}
+
+		// Extract Message-ID header
+		var rfc822MsgID string
+		for _, h := range msgResp.Payload.Headers {
+			if h.Name == "Message-ID" || h.Name == "Message-Id" {
+				rfc822MsgID = strings.Trim(h.Value, "<>")
+				break
+			}
+		}
+
+		result = append(result, batchMetadata{
+			Index:       part.Index,
+			ID:          msgResp.ID,
+			ThreadID:    msgResp.ThreadID,
+			RFC822MsgID: rfc822MsgID,
+			LabelIDs:    msgResp.LabelIDs,
+		})
 	}
-	if len(messages) > 100 {
+
+	return result, nil
+}
+
+// batchPart is the JSON body of one response in a batch request
+type batchPart struct {
+	Index int // position of the part in the batch response
+	Body  []byte
+}
+
+// batchGet runs up to 100 GET requests (API paths) in a single batch
+// request and returns the JSON body of each response
+func (c *Client) batchGet(paths []string) ([]batchPart, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	if len(paths) > 100 {
 		return nil, fmt.Errorf("batch size exceeds 100 messages")
 	}
 
@@ -535,11 +634,11 @@ func (c *Client) BatchGetMessageMetadata(messages []MessageInfo) (map[string]str
 	var boundary = fmt.Sprintf("batch_%d", time.Now().UnixNano())
 	var body bytes.Buffer
 
-	for i, msg := range messages {
+	for i, path := range paths {
 		body.WriteString(fmt.Sprintf("--%s\r\n", boundary))
 		body.WriteString("Content-Type: application/http\r\n")
 		body.WriteString(fmt.Sprintf("Content-ID: <%d>\r\n\r\n", i))
-		body.WriteString(fmt.Sprintf("GET /gmail/v1/users/me/messages/%s?format=metadata&metadataHeaders=Message-ID\r\n\r\n", msg.ID))
+		body.WriteString(fmt.Sprintf("GET %s\r\n\r\n", path))
 	}
 	body.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
 
@@ -574,7 +673,7 @@ func (c *Client) BatchGetMessageMetadata(messages []MessageInfo) (map[string]str
 		return nil, fmt.Errorf("no boundary in response content-type")
 	}
 
-	var result = make(map[string]string)
+	var result []batchPart
 	var reader = multipart.NewReader(resp.Body, respBoundary)
 
 	for i := 0; ; i++ {
@@ -595,40 +694,8 @@ func (c *Client) BatchGetMessageMetadata(messages []MessageInfo) (map[string]str
 		if jsonStart == -1 {
 			continue
 		}
-		var jsonData = partBody[jsonStart:]
 
-		// Parse JSON response
-		var msgResp struct {
-			ID       string `json:"id"`
-			ThreadID string `json:"threadId"`
-			Payload  struct {
-				Headers []struct {
-					Name  string `json:"name"`
-					Value string `json:"value"`
-				} `json:"headers"`
-			} `json:"payload"`
-		}
-
-		if err := json.Unmarshal(jsonData, &msgResp); err != nil {
-			continue
-		}
-
-		// Extract Message-ID header
-		var rfc822MsgID string
-		for _, h := range msgResp.Payload.Headers {
-			if h.Name == "Message-ID" || h.Name == "Message-Id" {
-				rfc822MsgID = strings.Trim(h.Value, "<>")
-				break
-			}
-		}
-
-		// Map RFC822 Message-ID to ThreadID
-		if rfc822MsgID != "" && msgResp.ThreadID != "" {
-			// Find the original message to get its threadID (from messages.list)
-			if i < len(messages) {
-				result[rfc822MsgID] = messages[i].ThreadID
-			}
-		}
+		result = append(result, batchPart{Index: i, Body: partBody[jsonStart:]})
 	}
 
 	return result, nil
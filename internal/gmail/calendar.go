@@ -2,11 +2,13 @@ package gmail
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -45,6 +47,7 @@ type CalendarEventInfo struct {
 	RecurrenceRule  string
 	Created         time.Time
 	Updated         time.Time
+	ETag            string
 }
 
 // AttendeeInfo represents a calendar event attendee
@@ -186,6 +189,7 @@ func parseCalendarEvent(item *calendar.Event, calendarID string) CalendarEventIn
 		Status:      item.Status,
 		HtmlLink:    item.HtmlLink,
 		ColorID:     item.ColorId,
+		ETag:        item.Etag,
 	}
 
 	// Parse organizer
@@ -249,3 +253,137 @@ func parseCalendarEvent(item *calendar.Event, calendarID string) CalendarEventIn
 
 	return event
 }
+
+// === Two-way sync ===
+
+// ErrSyncTokenExpired is returned by ListChanges when Google no longer
+// accepts the sync token (410 Gone): the calendar has to be listed again
+var ErrSyncTokenExpired = errors.New("calendar sync token expired")
+
+// CalendarChanges is what changed in a calendar since a sync token
+type CalendarChanges struct {
+	Events        []CalendarEventInfo // deleted ones have Status "cancelled"
+	NextSyncToken string              // for the next ListChanges
+}
+
+// ListChanges returns the events changed since syncToken, or every event
+// ending after timeMin when syncToken is empty (a full sync)
+func (c *CalendarClient) ListChanges(ctx context.Context, calendarID, syncToken string, timeMin time.Time) (*CalendarChanges, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	var changes = &CalendarChanges{}
+	var pageToken string
+	for {
+		call := c.service.Events.List(calendarID).
+			Context(ctx).
+			MaxResults(250).
+			SingleEvents(true).
+			ShowDeleted(true)
+		if syncToken != "" {
+			call = call.SyncToken(syncToken)
+		} else {
+			call = call.TimeMin(timeMin.Format(time.RFC3339))
+		}
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+
+		resp, err := call.Do()
+		if err != nil {
+			var apiErr *googleapi.Error
+			if errors.As(err, &apiErr) && apiErr.Code == http.StatusGone {
+				return nil, ErrSyncTokenExpired
+			}
+			return nil, fmt.Errorf("failed to list event changes: %w", err)
+		}
+		for _, item := range resp.Items {
+			changes.Events = append(changes.Events, parseCalendarEvent(item, calendarID))
+		}
+		if resp.NextPageToken == "" {
+			changes.NextSyncToken = resp.NextSyncToken
+			return changes, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// InsertEvent creates an event and returns it as saved by Google
+func (c *CalendarClient) InsertEvent(ctx context.Context, calendarID string, event *CalendarEventInfo) (*CalendarEventInfo, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	item, err := c.service.Events.Insert(calendarID, toGoogleEvent(event)).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert event: %w", err)
+	}
+
+	saved := parseCalendarEvent(item, calendarID)
+	return &saved, nil
+}
+
+// PatchEvent writes the fields miau edits (summary, description, location,
+// start and end) and keeps the rest: attendees, reminders, recurrence...
+func (c *CalendarClient) PatchEvent(ctx context.Context, calendarID, eventID string, event *CalendarEventInfo) (*CalendarEventInfo, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	item, err := c.service.Events.Patch(calendarID, eventID, toGoogleEvent(event)).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch event: %w", err)
+	}
+
+	saved := parseCalendarEvent(item, calendarID)
+	return &saved, nil
+}
+
+// DeleteEvent deletes an event
+func (c *CalendarClient) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	if err := c.service.Events.Delete(calendarID, eventID).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	return nil
+}
+
+// IsEventGone reports whether an error means the event no longer exists
+// (404, or 410 once deleted)
+func IsEventGone(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone)
+}
+
+// toGoogleEvent converts the fields miau edits to a Google Calendar event.
+// All-day events take the dates of StartTime and EndTime (the end is
+// exclusive, so a one-day event ends the next day).
+func toGoogleEvent(event *CalendarEventInfo) *calendar.Event {
+	var item = &calendar.Event{
+		Summary:         event.Summary,
+		Description:     event.Description,
+		Location:        event.Location,
+		ForceSendFields: []string{"Summary", "Description", "Location"},
+	}
+
+	var end = event.EndTime
+	if event.AllDay {
+		if !end.After(event.StartTime) {
+			end = event.StartTime.AddDate(0, 0, 1)
+		}
+		item.Start = &calendar.EventDateTime{Date: event.StartTime.Format("2006-01-02"), NullFields: []string{"DateTime"}}
+		item.End = &calendar.EventDateTime{Date: end.Format("2006-01-02"), NullFields: []string{"DateTime"}}
+		return item
+	}
+
+	if end.IsZero() {
+		end = event.StartTime.Add(time.Hour)
+	}
+	item.Start = &calendar.EventDateTime{DateTime: event.StartTime.Format(time.RFC3339), NullFields: []string{"Date"}}
+	item.End = &calendar.EventDateTime{DateTime: end.Format(time.RFC3339), NullFields: []string{"Date"}}
+	return item
+}
@@ -0,0 +1,127 @@
+package gmail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// === LABELS ===
+
+// CreateLabel cria um label de usuário visível na lista de labels e de mensagens
+func (c *Client) CreateLabel(name string) (*Label, error) {
+	var payload, err = json.Marshal(map[string]string{
+		"name":                  name,
+		"labelListVisibility":   "labelShow",
+		"messageListVisibility": "show",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao serializar: %w", err)
+	}
+
+	var resp, err2 = c.httpClient.Post("https://gmail.googleapis.com/gmail/v1/users/me/labels", "application/json", bytes.NewReader(payload))
+	if err2 != nil {
+		return nil, fmt.Errorf("erro na requisição: %w", err2)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body, _ = io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("erro da API (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var label Label
+	if err := json.NewDecoder(resp.Body).Decode(&label); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar resposta: %w", err)
+	}
+	return &label, nil
+}
+
+// ModifyMessageLabels adiciona e remove labels (por ID) de uma mensagem
+func (c *Client) ModifyMessageLabels(messageID string, add, remove []string) error {
+	var url = fmt.Sprintf("https://gmail.googleapis.com/gmail/v1/users/me/messages/%s/modify", messageID)
+
+	var payload, err = json.Marshal(ModifyLabelsRequest{AddLabelIDs: add, RemoveLabelIDs: remove})
+	if err != nil {
+		return fmt.Errorf("erro ao serializar: %w", err)
+	}
+
+	var resp, err2 = c.httpClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err2 != nil {
+		return fmt.Errorf("erro na requisição: %w", err2)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body, _ = io.ReadAll(resp.Body)
+		return fmt.Errorf("erro da API (%d): %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// ListMessageLabels retorna os labels (IDs) das maxMessages mensagens mais
+// recentes da conta, indexados pelo Message-ID RFC822
+func (c *Client) ListMessageLabels(ctx context.Context, maxMessages int) (map[string][]string, error) {
+	var messages []MessageInfo
+	var pageToken = ""
+	for len(messages) < maxMessages {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var pageSize = maxMessages - len(messages)
+		if pageSize > 500 {
+			pageSize = 500
+		}
+		var listResp, err = c.ListAllMessages(pageSize, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao listar mensagens: %w", err)
+		}
+		messages = append(messages, listResp.Messages...)
+
+		if listResp.NextPageToken == "" {
+			break
+		}
+		pageToken = listResp.NextPageToken
+	}
+
+	return c.messageLabels(ctx, messages)
+}
+
+// messageLabels busca os labels das mensagens em lotes de 100
+func (c *Client) messageLabels(ctx context.Context, messages []MessageInfo) (map[string][]string, error) {
+	var result = make(map[string][]string)
+	for i := 0; i < len(messages); i += 100 {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		var end = i + 100
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		var metadata []batchMetadata
+		var err error
+		for retry := 0; retry < 3; retry++ {
+			if metadata, err = c.batchGetMetadata(messages[i:end]); err == nil {
+				break
+			}
+			time.Sleep(time.Duration(1<<retry) * time.Second)
+		}
+		if err != nil {
+			return result, err
+		}
+
+		for _, m := range metadata {
+			if m.RFC822MsgID != "" {
+				result[m.RFC822MsgID] = m.LabelIDs
+			}
+		}
+	}
+	return result, nil
+}
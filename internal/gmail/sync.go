@@ -0,0 +1,258 @@
+package gmail
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+)
+
+// === SYNC (history.list, messages.get, lotes) ===
+
+// apiBase é a raiz da API do Gmail para o usuário autenticado
+const apiBase = "https://gmail.googleapis.com/gmail/v1/users/me"
+
+// NewClientWithHTTP cria um cliente sobre um http.Client já autenticado
+// (ou sobre um fake com httptest nos testes)
+func NewClientWithHTTP(httpClient *http.Client, email string) *Client {
+	return &Client{
+		httpClient: httpClient,
+		email:      email,
+	}
+}
+
+// APIError é uma resposta de erro da API
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("erro da API (%d): %s", e.StatusCode, e.Body)
+}
+
+// IsNotFound indica se o erro é um 404 da API (mensagem apagada, history ID expirado)
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// getJSON faz um GET e decodifica a resposta em out
+func (c *Client) getJSON(url string, out interface{}) error {
+	var resp, err = c.httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("erro na requisição: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body, _ = io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("erro ao decodificar resposta: %w", err)
+	}
+	return nil
+}
+
+// Profile é o perfil da caixa postal
+type Profile struct {
+	EmailAddress  string `json:"emailAddress"`
+	MessagesTotal int    `json:"messagesTotal"`
+	ThreadsTotal  int    `json:"threadsTotal"`
+	HistoryID     string `json:"historyId"`
+}
+
+// GetProfile busca o perfil da caixa postal (com o history ID atual)
+func (c *Client) GetProfile() (*Profile, error) {
+	var profile Profile
+	if err := c.getJSON(apiBase+"/profile", &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// GetLabel busca um label com as contagens de mensagens
+func (c *Client) GetLabel(id string) (*Label, error) {
+	var label Label
+	if err := c.getJSON(apiBase+"/labels/"+neturl.PathEscape(id), &label); err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// ListLabelMessages lista as mensagens de um label ("" = todas, menos spam e
+// lixeira), das mais novas para as mais antigas, com uma busca opcional
+func (c *Client) ListLabelMessages(labelID, query string, maxResults int, pageToken string) (*ListMessagesResponse, error) {
+	var params = neturl.Values{}
+	params.Set("maxResults", strconv.Itoa(maxResults))
+	if labelID != "" {
+		params.Set("labelIds", labelID)
+	}
+	if labelID == "SPAM" || labelID == "TRASH" {
+		params.Set("includeSpamTrash", "true")
+	}
+	if query != "" {
+		params.Set("q", query)
+	}
+	if pageToken != "" {
+		params.Set("pageToken", pageToken)
+	}
+
+	var result ListMessagesResponse
+	if err := c.getJSON(apiBase+"/messages?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MessagePartHeader é um header de uma parte da mensagem
+type MessagePartHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// MessagePartBody é o conteúdo de uma parte (Data em base64url, ou
+// AttachmentID quando o conteúdo precisa ser buscado à parte)
+type MessagePartBody struct {
+	AttachmentID string `json:"attachmentId,omitempty"`
+	Size         int64  `json:"size"`
+	Data         string `json:"data,omitempty"`
+}
+
+// MessagePart é uma parte MIME da mensagem
+type MessagePart struct {
+	PartID   string              `json:"partId"`
+	MimeType string              `json:"mimeType"`
+	Filename string              `json:"filename"`
+	Headers  []MessagePartHeader `json:"headers"`
+	Body     MessagePartBody     `json:"body"`
+	Parts    []MessagePart       `json:"parts,omitempty"`
+}
+
+// Header retorna o valor de um header da parte ("" se não existir)
+func (p *MessagePart) Header(name string) string {
+	for _, h := range p.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// Message é uma mensagem da API (formatos full, metadata, minimal ou raw)
+type Message struct {
+	ID           string       `json:"id"`
+	ThreadID     string       `json:"threadId"`
+	LabelIDs     []string     `json:"labelIds"`
+	Snippet      string       `json:"snippet"`
+	HistoryID    string       `json:"historyId"`
+	InternalDate string       `json:"internalDate"`
+	SizeEstimate int64        `json:"sizeEstimate"`
+	Payload      *MessagePart `json:"payload,omitempty"`
+	Raw          string       `json:"raw,omitempty"`
+}
+
+// GetMessage busca uma mensagem no formato pedido (full, metadata, minimal, raw)
+func (c *Client) GetMessage(id, format string) (*Message, error) {
+	var message Message
+	if err := c.getJSON(apiBase+"/messages/"+neturl.PathEscape(id)+"?format="+format, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// GetAttachment baixa o conteúdo (decodificado) de um anexo
+func (c *Client) GetAttachment(messageID, attachmentID string) ([]byte, error) {
+	var result MessagePartBody
+	var url = fmt.Sprintf("%s/messages/%s/attachments/%s", apiBase, neturl.PathEscape(messageID), neturl.PathEscape(attachmentID))
+	if err := c.getJSON(url, &result); err != nil {
+		return nil, err
+	}
+	return DecodeData(result.Data)
+}
+
+// DecodeData decodifica o base64url usado pela API (com ou sem padding)
+func DecodeData(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+}
+
+// HistoryMessage é uma mensagem citada num registro do histórico
+type HistoryMessage struct {
+	Message struct {
+		ID       string   `json:"id"`
+		ThreadID string   `json:"threadId"`
+		LabelIDs []string `json:"labelIds"`
+	} `json:"message"`
+	LabelIDs []string `json:"labelIds,omitempty"` // labels adicionados/removidos
+}
+
+// History é um registro do histórico da caixa postal
+type History struct {
+	ID              string           `json:"id"`
+	MessagesAdded   []HistoryMessage `json:"messagesAdded,omitempty"`
+	MessagesDeleted []HistoryMessage `json:"messagesDeleted,omitempty"`
+	LabelsAdded     []HistoryMessage `json:"labelsAdded,omitempty"`
+	LabelsRemoved   []HistoryMessage `json:"labelsRemoved,omitempty"`
+}
+
+// HistoryResponse é uma página de history.list
+type HistoryResponse struct {
+	History       []History `json:"history"`
+	NextPageToken string    `json:"nextPageToken"`
+	HistoryID     string    `json:"historyId"`
+}
+
+// ListHistory lista as mudanças na caixa postal desde startHistoryID. Um
+// history ID antigo demais retorna 404 (ver IsNotFound): aí é preciso
+// sincronizar do zero.
+func (c *Client) ListHistory(startHistoryID uint64, pageToken string) (*HistoryResponse, error) {
+	var params = neturl.Values{}
+	params.Set("startHistoryId", strconv.FormatUint(startHistoryID, 10))
+	params.Set("maxResults", "500")
+	if pageToken != "" {
+		params.Set("pageToken", pageToken)
+	}
+
+	var result HistoryResponse
+	if err := c.getJSON(apiBase+"/history?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BatchGetMessages busca até 100 mensagens numa única requisição em lote.
+// Mensagens que não existem mais ficam de fora do resultado.
+func (c *Client) BatchGetMessages(ids []string, format string) ([]Message, error) {
+	var paths = make([]string, len(ids))
+	for i, id := range ids {
+		paths[i] = fmt.Sprintf("/gmail/v1/users/me/messages/%s?format=%s", id, format)
+	}
+
+	var parts, err = c.batchGet(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Message
+	for _, part := range parts {
+		var message Message
+		if err := json.Unmarshal(part.Body, &message); err != nil || message.ID == "" {
+			continue
+		}
+		result = append(result, message)
+	}
+	return result, nil
+}
+
+// ParseHistoryID converte o history ID da API (string) para número
+func ParseHistoryID(id string) uint64 {
+	var n, _ = strconv.ParseUint(id, 10, 64)
+	return n
+}
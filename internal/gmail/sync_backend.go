@@ -0,0 +1,1184 @@
+package gmail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// SyncState persists what SyncBackend needs between runs: the UID given to
+// each message in each label and how far each label has been synced.
+// Labels are keyed by Gmail label ID ("" for All Mail).
+type SyncState interface {
+	// AssignUIDs returns the UID of each message in the label, giving the
+	// next UIDs (in the given order) to messages that have none yet
+	AssignUIDs(labelID string, gmailIDs []string) (map[string]uint32, error)
+	// LookupUIDs returns the UIDs already given to messages in the label
+	LookupUIDs(labelID string, gmailIDs []string) (map[string]uint32, error)
+	// GmailIDs returns the Gmail message ID of each UID in the label
+	GmailIDs(labelID string, uids []uint32) (map[uint32]string, error)
+	// GmailIDsAfter returns UID -> Gmail message ID for the UIDs above uid
+	GmailIDsAfter(labelID string, uid uint32) (map[uint32]string, error)
+	// HistoryID returns the history ID the label was synced up to (0 = never)
+	HistoryID(labelID string) (uint64, error)
+	SetHistoryID(labelID string, historyID uint64) error
+}
+
+// AllMailFolder is the folder with every message (no label filter)
+const AllMailFolder = "[Gmail]/All Mail"
+
+// uidValidity is the UIDVALIDITY of every folder: UIDs are assigned locally
+// and never renumbered
+const uidValidity = 1
+
+// defaultPollInterval is how often Idle checks the history ID
+const defaultPollInterval = 30 * time.Second
+
+// systemFolders maps the Gmail IMAP folder names to system label IDs, so
+// folders keep their names when an account switches backends
+var systemFolders = []struct{ folder, label string }{
+	{"INBOX", "INBOX"},
+	{"[Gmail]/Starred", "STARRED"},
+	{"[Gmail]/Important", "IMPORTANT"},
+	{"[Gmail]/Sent Mail", "SENT"},
+	{"[Gmail]/Drafts", "DRAFT"},
+	{"[Gmail]/Spam", "SPAM"},
+	{"[Gmail]/Trash", "TRASH"},
+}
+
+// SyncBackend implements ports.IMAPPort on the Gmail REST API: labels are
+// folders, history.list drives incremental sync (history IDs play the role
+// of CONDSTORE mod-sequences) and messages are fetched in batches of 100.
+// Gmail has no \Answered flag, so flag updates always report it unset.
+type SyncBackend struct {
+	mu        sync.RWMutex
+	client    *Client
+	state     SyncState
+	connected bool
+	labels    map[string]Label  // label ID -> label
+	folders   map[string]string // folder name -> label ID
+	selected  *string           // label ID of the selected folder
+
+	// PollInterval is how often Idle polls for changes (the API only
+	// pushes through Cloud Pub/Sub)
+	PollInterval time.Duration
+}
+
+// NewSyncBackend creates a SyncBackend
+func NewSyncBackend(client *Client, state SyncState) *SyncBackend {
+	return &SyncBackend{
+		client:       client,
+		state:        state,
+		PollInterval: defaultPollInterval,
+	}
+}
+
+// Connect checks the token and loads the labels
+func (b *SyncBackend) Connect(ctx context.Context) error {
+	if _, err := b.client.GetProfile(); err != nil {
+		return fmt.Errorf("failed to reach Gmail API: %w", err)
+	}
+	if err := b.loadLabels(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.connected = true
+	b.mu.Unlock()
+	return nil
+}
+
+// Close forgets the selected folder (there is no connection to close)
+func (b *SyncBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connected = false
+	b.selected = nil
+	return nil
+}
+
+// IsConnected returns true after a successful Connect
+func (b *SyncBackend) IsConnected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.connected
+}
+
+// loadLabels refreshes the label and folder maps
+func (b *SyncBackend) loadLabels() error {
+	var labels, err = b.client.ListLabels()
+	if err != nil {
+		return fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	var byID = make(map[string]Label, len(labels))
+	var folders = map[string]string{AllMailFolder: ""}
+	for _, sf := range systemFolders {
+		folders[sf.folder] = sf.label
+	}
+	for _, l := range labels {
+		byID[l.ID] = l
+		if l.Type != "system" {
+			folders[l.Name] = l.ID
+		}
+	}
+
+	b.mu.Lock()
+	b.labels = byID
+	b.folders = folders
+	b.mu.Unlock()
+	return nil
+}
+
+// labelFor returns the label ID of a folder
+func (b *SyncBackend) labelFor(folder string) (string, error) {
+	b.mu.RLock()
+	var id, ok = b.folders[folder]
+	b.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	// Maybe a label created since Connect
+	if err := b.loadLabels(); err != nil {
+		return "", err
+	}
+	b.mu.RLock()
+	id, ok = b.folders[folder]
+	b.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("folder not found: %s", folder)
+	}
+	return id, nil
+}
+
+// selectedLabel returns the label ID of the selected folder
+func (b *SyncBackend) selectedLabel() (string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if !b.connected {
+		return "", fmt.Errorf("not connected")
+	}
+	if b.selected == nil {
+		return "", fmt.Errorf("no folder selected")
+	}
+	return *b.selected, nil
+}
+
+// ListMailboxes lists the system folders, All Mail and the user labels
+func (b *SyncBackend) ListMailboxes(ctx context.Context) ([]ports.MailboxInfo, error) {
+	if err := b.loadLabels(); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	var names []string
+	for _, sf := range systemFolders {
+		if _, ok := b.labels[sf.label]; ok {
+			names = append(names, sf.folder)
+		}
+	}
+	names = append(names, AllMailFolder)
+	var userLabels []string
+	for _, l := range b.labels {
+		if l.Type != "system" {
+			userLabels = append(userLabels, l.Name)
+		}
+	}
+	b.mu.RUnlock()
+	sort.Strings(userLabels)
+	names = append(names, userLabels...)
+
+	var result = make([]ports.MailboxInfo, 0, len(names))
+	for _, name := range names {
+		var total, unread, err = b.counts(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ports.MailboxInfo{Name: name, Messages: total, Unseen: unread})
+	}
+	return result, nil
+}
+
+// counts returns the message and unread counts of a folder
+func (b *SyncBackend) counts(folder string) (uint32, uint32, error) {
+	var id, err = b.labelFor(folder)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if id == "" {
+		var profile, err = b.client.GetProfile()
+		if err != nil {
+			return 0, 0, err
+		}
+		var unread, err2 = b.client.GetLabel("UNREAD")
+		if err2 != nil {
+			return 0, 0, err2
+		}
+		return uint32(profile.MessagesTotal), uint32(unread.MessagesTotal), nil
+	}
+
+	var label, err2 = b.client.GetLabel(id)
+	if err2 != nil {
+		return 0, 0, err2
+	}
+	return uint32(label.MessagesTotal), uint32(label.MessagesUnread), nil
+}
+
+// SelectMailbox selects a folder. HighestModSeq is the current history ID.
+func (b *SyncBackend) SelectMailbox(ctx context.Context, name string) (*ports.MailboxStatus, error) {
+	var id, err = b.labelFor(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile, err2 = b.client.GetProfile()
+	if err2 != nil {
+		return nil, err2
+	}
+	var total, unread, err3 = b.counts(name)
+	if err3 != nil {
+		return nil, err3
+	}
+
+	b.mu.Lock()
+	b.selected = &id
+	b.mu.Unlock()
+
+	return &ports.MailboxStatus{
+		Name:          name,
+		NumMessages:   total,
+		NumUnseen:     unread,
+		UIDValidity:   uidValidity,
+		HighestModSeq: ParseHistoryID(profile.HistoryID),
+	}, nil
+}
+
+// FetchEmails fetches the newest emails of the selected folder
+func (b *SyncBackend) FetchEmails(ctx context.Context, limit int) ([]ports.IMAPEmail, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, err2 = b.listIDs(label, "", limit)
+	if err2 != nil {
+		return nil, err2
+	}
+	return b.fetchNew(ctx, label, ids)
+}
+
+// FetchNewEmails fetches emails with UID above sinceUID
+func (b *SyncBackend) FetchNewEmails(ctx context.Context, sinceUID uint32, limit int) ([]ports.IMAPEmail, error) {
+	return b.FetchNewEmailsBatch(ctx, sinceUID, limit)
+}
+
+// FetchEmailRaw fetches the RFC 822 message
+func (b *SyncBackend) FetchEmailRaw(ctx context.Context, uid uint32) ([]byte, error) {
+	var id, err = b.gmailID(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var message, err2 = b.client.GetMessage(id, "raw")
+	if err2 != nil {
+		return nil, err2
+	}
+	return DecodeData(message.Raw)
+}
+
+// FetchEmailBody fetches the message body without headers (like IMAP BODY[TEXT])
+func (b *SyncBackend) FetchEmailBody(ctx context.Context, uid uint32) (string, error) {
+	var raw, err = b.FetchEmailRaw(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+
+	for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+		if i := bytes.Index(raw, []byte(sep)); i >= 0 {
+			return string(raw[i+len(sep):]), nil
+		}
+	}
+	return "", nil
+}
+
+// GetAllUIDs returns the UIDs of the synced messages still in the selected folder
+func (b *SyncBackend) GetAllUIDs(ctx context.Context) ([]uint32, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	var known, err2 = b.state.GmailIDsAfter(label, 0)
+	if err2 != nil {
+		return nil, err2
+	}
+	var server, err3 = b.listIDs(label, "", 0)
+	if err3 != nil {
+		return nil, err3
+	}
+
+	var onServer = make(map[string]bool, len(server))
+	for _, id := range server {
+		onServer[id] = true
+	}
+	var uids []uint32
+	for uid, id := range known {
+		if onServer[id] {
+			uids = append(uids, uid)
+		}
+	}
+	slices.Sort(uids)
+	return uids, nil
+}
+
+// FetchMessageIDs returns UID -> Message-ID for every message in the selected folder
+func (b *SyncBackend) FetchMessageIDs(ctx context.Context) (map[uint32]string, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, err2 = b.listIDs(label, "", 0)
+	if err2 != nil {
+		return nil, err2
+	}
+	slices.Reverse(ids) // oldest first, so UIDs follow arrival order
+	var uids, err3 = b.state.AssignUIDs(label, ids)
+	if err3 != nil {
+		return nil, err3
+	}
+
+	var messages, err4 = b.batchGet(ctx, ids, "metadata&metadataHeaders=Message-ID")
+	if err4 != nil {
+		return nil, err4
+	}
+	var result = make(map[uint32]string, len(messages))
+	for _, m := range messages {
+		if m.Payload != nil {
+			result[uids[m.ID]] = strings.Trim(m.Payload.Header("Message-ID"), "<>")
+		}
+	}
+	return result, nil
+}
+
+// SupportsCondStore is always true: history IDs work as mod-sequences
+func (b *SyncBackend) SupportsCondStore() bool {
+	return true
+}
+
+// FetchFlagChanges returns the flags of synced messages changed since the
+// history ID. Messages deleted or taken out of the folder are reported as
+// deleted (like an expunge). If the history ID expired, every synced
+// message of the folder is refreshed.
+func (b *SyncBackend) FetchFlagChanges(ctx context.Context, sinceModSeq uint64) ([]ports.FlagUpdate, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed = make(map[string]bool)
+	var deleted = make(map[string]bool)
+	var _, err2 = b.walkHistory(ctx, sinceModSeq, func(h History) {
+		for _, m := range h.MessagesDeleted {
+			deleted[m.Message.ID] = true
+		}
+		for _, list := range [][]HistoryMessage{h.MessagesAdded, h.LabelsAdded, h.LabelsRemoved} {
+			for _, m := range list {
+				changed[m.Message.ID] = true
+			}
+		}
+	})
+	if IsNotFound(err2) {
+		var known, err3 = b.state.GmailIDsAfter(label, 0)
+		if err3 != nil {
+			return nil, err3
+		}
+		for _, id := range known {
+			changed[id] = true
+		}
+	} else if err2 != nil {
+		return nil, err2
+	}
+
+	var ids = make([]string, 0, len(changed)+len(deleted))
+	for id := range changed {
+		ids = append(ids, id)
+	}
+	for id := range deleted {
+		if !changed[id] {
+			ids = append(ids, id)
+		}
+	}
+	var uids, err3 = b.state.LookupUIDs(label, ids)
+	if err3 != nil {
+		return nil, err3
+	}
+
+	var updates []ports.FlagUpdate
+	var toFetch []string
+	for id, uid := range uids {
+		if deleted[id] {
+			updates = append(updates, ports.FlagUpdate{UID: uid, Deleted: true})
+		} else {
+			toFetch = append(toFetch, id)
+		}
+	}
+
+	var messages, err4 = b.batchGet(ctx, toFetch, "minimal")
+	if err4 != nil {
+		return nil, err4
+	}
+	var found = make(map[string]bool, len(messages))
+	for _, m := range messages {
+		found[m.ID] = true
+		updates = append(updates, b.flagUpdate(label, uids[m.ID], m.LabelIDs))
+	}
+	for _, id := range toFetch {
+		if !found[id] {
+			updates = append(updates, ports.FlagUpdate{UID: uids[id], Deleted: true})
+		}
+	}
+
+	sort.Slice(updates, func(i, j int) bool { return updates[i].UID < updates[j].UID })
+	return updates, nil
+}
+
+// FlagChangesIncludeExpunges is always true: the history reports deleted
+// messages
+func (b *SyncBackend) FlagChangesIncludeExpunges() bool {
+	return true
+}
+
+// FetchFlags returns the current flags of the given UIDs
+func (b *SyncBackend) FetchFlags(ctx context.Context, uids []uint32) ([]ports.FlagUpdate, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, err2 = b.state.GmailIDs(label, uids)
+	if err2 != nil {
+		return nil, err2
+	}
+	var byID = make(map[string]uint32, len(ids))
+	var list = make([]string, 0, len(ids))
+	for uid, id := range ids {
+		byID[id] = uid
+		list = append(list, id)
+	}
+
+	var messages, err3 = b.batchGet(ctx, list, "minimal")
+	if err3 != nil {
+		return nil, err3
+	}
+	var updates []ports.FlagUpdate
+	for _, m := range messages {
+		var update = b.flagUpdate(label, byID[m.ID], m.LabelIDs)
+		if !update.Deleted {
+			updates = append(updates, update)
+		}
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].UID < updates[j].UID })
+	return updates, nil
+}
+
+// flagUpdate maps the labels of a message to IMAP flags
+func (b *SyncBackend) flagUpdate(label string, uid uint32, labelIDs []string) ports.FlagUpdate {
+	return ports.FlagUpdate{
+		UID:     uid,
+		Seen:    !slices.Contains(labelIDs, "UNREAD"),
+		Flagged: slices.Contains(labelIDs, "STARRED"),
+		Deleted: !inFolder(label, labelIDs),
+	}
+}
+
+// inFolder reports whether a message with these labels is in the folder
+func inFolder(label string, labelIDs []string) bool {
+	if label == "" {
+		return !slices.Contains(labelIDs, "SPAM") && !slices.Contains(labelIDs, "TRASH")
+	}
+	return slices.Contains(labelIDs, label)
+}
+
+// SearchText runs a Gmail search in the selected folder
+func (b *SyncBackend) SearchText(ctx context.Context, query string, limit int) ([]uint32, error) {
+	return b.search(query, limit)
+}
+
+// SearchSince returns the UIDs of messages received since the date
+func (b *SyncBackend) SearchSince(ctx context.Context, sinceDate time.Time) ([]uint32, error) {
+	return b.search("after:"+strconv.FormatInt(sinceDate.Unix(), 10), 0)
+}
+
+// search returns the UIDs of the messages matching a Gmail query
+func (b *SyncBackend) search(query string, limit int) ([]uint32, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, err2 = b.listIDs(label, query, limit)
+	if err2 != nil {
+		return nil, err2
+	}
+	slices.Reverse(ids)
+	var uids, err3 = b.state.AssignUIDs(label, ids)
+	if err3 != nil {
+		return nil, err3
+	}
+
+	var result = make([]uint32, 0, len(uids))
+	for _, uid := range uids {
+		result = append(result, uid)
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+// FetchEmailsBatch fetches emails by UID
+func (b *SyncBackend) FetchEmailsBatch(ctx context.Context, uids []uint32) ([]ports.IMAPEmail, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, err2 = b.state.GmailIDs(label, uids)
+	if err2 != nil {
+		return nil, err2
+	}
+	return b.fetchEmails(ctx, ids)
+}
+
+// FetchNewEmailsBatch fetches the emails added to the selected folder since
+// the last sync, found through history.list. Without a history ID (or when
+// it expired) the newest limit emails are listed instead.
+func (b *SyncBackend) FetchNewEmailsBatch(ctx context.Context, sinceUID uint32, limit int) ([]ports.IMAPEmail, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint, err2 = b.state.HistoryID(label)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	var ids []string
+	var latest uint64
+	if checkpoint > 0 {
+		ids, latest, err = b.addedSince(ctx, label, checkpoint)
+		if IsNotFound(err) {
+			checkpoint = 0 // history ID expired
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if checkpoint == 0 {
+		var profile, err3 = b.client.GetProfile()
+		if err3 != nil {
+			return nil, err3
+		}
+		latest = ParseHistoryID(profile.HistoryID)
+		if ids, err = b.listIDs(label, "", limit); err != nil {
+			return nil, err
+		}
+		slices.Reverse(ids)
+	}
+
+	// UIDs are stored before fetching: messages assigned but not fetched
+	// yet (errors, limit) are picked up by the next sync
+	if _, err := b.state.AssignUIDs(label, ids); err != nil {
+		return nil, err
+	}
+	var pending, err4 = b.state.GmailIDsAfter(label, sinceUID)
+	if err4 != nil {
+		return nil, err4
+	}
+	if limit > 0 && len(pending) > limit {
+		var uids = make([]uint32, 0, len(pending))
+		for uid := range pending {
+			uids = append(uids, uid)
+		}
+		slices.Sort(uids)
+		for _, uid := range uids[limit:] {
+			delete(pending, uid)
+		}
+	}
+
+	var emails, err5 = b.fetchEmails(ctx, pending)
+	if err5 != nil {
+		return nil, err5
+	}
+	if latest > 0 {
+		if err := b.state.SetHistoryID(label, latest); err != nil {
+			return nil, err
+		}
+	}
+	return emails, nil
+}
+
+// FetchEmailsSinceDateBatch fetches up to limit emails from the last
+// sinceDays days and starts incremental sync from now
+func (b *SyncBackend) FetchEmailsSinceDateBatch(ctx context.Context, sinceDays int, limit int) ([]ports.IMAPEmail, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	var profile, err2 = b.client.GetProfile()
+	if err2 != nil {
+		return nil, err2
+	}
+
+	var ids, err3 = b.listIDs(label, fmt.Sprintf("newer_than:%dd", sinceDays), limit)
+	if err3 != nil {
+		return nil, err3
+	}
+	slices.Reverse(ids)
+
+	var emails, err4 = b.fetchNew(ctx, label, ids)
+	if err4 != nil {
+		return nil, err4
+	}
+	if err := b.state.SetHistoryID(label, ParseHistoryID(profile.HistoryID)); err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// Idle polls the history ID until something changes in the mailbox. Any
+// change is reported as FlagsChanged: the next sync sorts out what it was.
+func (b *SyncBackend) Idle(ctx context.Context, mailbox string) (*ports.MailboxUpdate, error) {
+	var profile, err = b.client.GetProfile()
+	if err != nil {
+		return nil, err
+	}
+	var start = profile.HistoryID
+
+	var interval = b.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		var current, err = b.client.GetProfile()
+		if err != nil {
+			return nil, err
+		}
+		if current.HistoryID != start {
+			var total, _, _ = b.counts(mailbox)
+			return &ports.MailboxUpdate{
+				Mailbox:      mailbox,
+				NumMessages:  total,
+				FlagsChanged: true,
+			}, nil
+		}
+	}
+}
+
+// FetchAttachmentMetadata returns the attachments of an email
+func (b *SyncBackend) FetchAttachmentMetadata(ctx context.Context, uid uint32) ([]ports.AttachmentInfo, bool, error) {
+	var message, err = b.fullMessage(uid)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var attachments = attachmentsOf(message.Payload)
+	return attachments, len(attachments) > 0, nil
+}
+
+// FetchAttachmentPart returns an attachment part in its transfer encoding,
+// like IMAP does (callers decode it with the stored encoding)
+func (b *SyncBackend) FetchAttachmentPart(ctx context.Context, uid uint32, partNumber string) ([]byte, error) {
+	var message, err = b.fullMessage(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var part = findPart(message.Payload, partNumber)
+	if part == nil {
+		return nil, fmt.Errorf("part %s not found", partNumber)
+	}
+
+	var data []byte
+	if part.Body.AttachmentID != "" {
+		data, err = b.client.GetAttachment(message.ID, part.Body.AttachmentID)
+	} else {
+		data, err = DecodeData(part.Body.Data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return encodePart(data, part.Header("Content-Transfer-Encoding"))
+}
+
+// fullMessage fetches an email of the selected folder in full format
+func (b *SyncBackend) fullMessage(uid uint32) (*Message, error) {
+	var id, err = b.gmailID(uid)
+	if err != nil {
+		return nil, err
+	}
+	return b.client.GetMessage(id, "full")
+}
+
+// MarkAsRead removes the UNREAD label
+func (b *SyncBackend) MarkAsRead(ctx context.Context, uid uint32) error {
+	return b.modify(uid, nil, []string{"UNREAD"})
+}
+
+// MarkAsUnread adds the UNREAD label
+func (b *SyncBackend) MarkAsUnread(ctx context.Context, uid uint32) error {
+	return b.modify(uid, []string{"UNREAD"}, nil)
+}
+
+// Archive removes the INBOX label
+func (b *SyncBackend) Archive(ctx context.Context, uid uint32) error {
+	return b.modify(uid, nil, []string{"INBOX"})
+}
+
+// MoveToFolder adds the label of the folder and removes the selected one
+func (b *SyncBackend) MoveToFolder(ctx context.Context, uid uint32, folder string) error {
+	var target, err = b.labelFor(folder)
+	if err != nil {
+		return err
+	}
+	if target == "TRASH" {
+		return b.Delete(ctx, uid)
+	}
+
+	var current, err2 = b.selectedLabel()
+	if err2 != nil {
+		return err2
+	}
+	var add, remove []string
+	if target != "" {
+		add = []string{target}
+	}
+	if current != "" && current != target {
+		remove = []string{current}
+	}
+	return b.modify(uid, add, remove)
+}
+
+// CopyToInbox adds the INBOX and UNREAD labels to an email of folder
+func (b *SyncBackend) CopyToInbox(ctx context.Context, folder string, uid uint32) error {
+	var label, err = b.labelFor(folder)
+	if err != nil {
+		return err
+	}
+	var ids, err2 = b.state.GmailIDs(label, []uint32{uid})
+	if err2 != nil {
+		return err2
+	}
+	var id, ok = ids[uid]
+	if !ok {
+		return fmt.Errorf("unknown UID %d", uid)
+	}
+	return b.client.ModifyMessageLabels(id, []string{"INBOX", "UNREAD"}, nil)
+}
+
+// Delete moves an email to the trash
+func (b *SyncBackend) Delete(ctx context.Context, uid uint32) error {
+	var id, err = b.gmailID(uid)
+	if err != nil {
+		return err
+	}
+	return b.client.TrashMessage(id)
+}
+
+// Undelete takes an email out of the trash
+func (b *SyncBackend) Undelete(ctx context.Context, uid uint32) error {
+	var id, err = b.gmailID(uid)
+	if err != nil {
+		return err
+	}
+	return b.client.UntrashMessage(id)
+}
+
+// AddKeyword adds a user label (created if needed)
+func (b *SyncBackend) AddKeyword(ctx context.Context, uid uint32, keyword string) error {
+	var label, err = b.labelFor(keyword)
+	if err != nil {
+		var created, createErr = b.client.CreateLabel(keyword)
+		if createErr != nil {
+			return fmt.Errorf("failed to create label %s: %w", keyword, createErr)
+		}
+		if err := b.loadLabels(); err != nil {
+			return err
+		}
+		label = created.ID
+	}
+	return b.modify(uid, []string{label}, nil)
+}
+
+// RemoveKeyword removes a user label
+func (b *SyncBackend) RemoveKeyword(ctx context.Context, uid uint32, keyword string) error {
+	var label, err = b.labelFor(keyword)
+	if err != nil {
+		return nil // no such label, nothing to remove
+	}
+	return b.modify(uid, nil, []string{label})
+}
+
+// GetTrashFolder returns the trash folder name
+func (b *SyncBackend) GetTrashFolder() string {
+	return "[Gmail]/Trash"
+}
+
+// modify adds and removes labels on an email of the selected folder
+func (b *SyncBackend) modify(uid uint32, add, remove []string) error {
+	var id, err = b.gmailID(uid)
+	if err != nil {
+		return err
+	}
+	return b.client.ModifyMessageLabels(id, add, remove)
+}
+
+// gmailID returns the Gmail message ID of a UID in the selected folder
+func (b *SyncBackend) gmailID(uid uint32) (string, error) {
+	var label, err = b.selectedLabel()
+	if err != nil {
+		return "", err
+	}
+
+	var ids, err2 = b.state.GmailIDs(label, []uint32{uid})
+	if err2 != nil {
+		return "", err2
+	}
+	var id, ok = ids[uid]
+	if !ok {
+		return "", fmt.Errorf("unknown UID %d", uid)
+	}
+	return id, nil
+}
+
+// listIDs lists message IDs of a label, newest first (limit 0 = all)
+func (b *SyncBackend) listIDs(label, query string, limit int) ([]string, error) {
+	var ids []string
+	var pageToken = ""
+	for limit <= 0 || len(ids) < limit {
+		var pageSize = 500
+		if limit > 0 && limit-len(ids) < pageSize {
+			pageSize = limit - len(ids)
+		}
+
+		var resp, err = b.client.ListLabelMessages(label, query, pageSize, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range resp.Messages {
+			ids = append(ids, m.ID)
+		}
+
+		if resp.NextPageToken == "" {
+			break
+		}
+		pageToken = resp.NextPageToken
+	}
+	return ids, nil
+}
+
+// walkHistory calls fn for every history record since startHistoryID and
+// returns the current history ID
+func (b *SyncBackend) walkHistory(ctx context.Context, startHistoryID uint64, fn func(History)) (uint64, error) {
+	var latest uint64
+	var pageToken = ""
+	for {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+
+		var resp, err = b.client.ListHistory(startHistoryID, pageToken)
+		if err != nil {
+			return 0, err
+		}
+		for _, h := range resp.History {
+			fn(h)
+		}
+		latest = ParseHistoryID(resp.HistoryID)
+
+		if resp.NextPageToken == "" {
+			return latest, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
+
+// addedSince returns the messages that entered the label since the history
+// ID (oldest first) and the current history ID
+func (b *SyncBackend) addedSince(ctx context.Context, label string, startHistoryID uint64) ([]string, uint64, error) {
+	var ids []string
+	var seen = make(map[string]bool)
+	var add = func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	var latest, err = b.walkHistory(ctx, startHistoryID, func(h History) {
+		for _, m := range h.MessagesAdded {
+			if inFolder(label, m.Message.LabelIDs) {
+				add(m.Message.ID)
+			}
+		}
+		for _, m := range h.LabelsAdded {
+			if label != "" && slices.Contains(m.LabelIDs, label) {
+				add(m.Message.ID)
+			}
+		}
+		for _, m := range h.LabelsRemoved {
+			// Back from spam or trash: back in All Mail
+			if label == "" && inFolder(label, m.Message.LabelIDs) &&
+				(slices.Contains(m.LabelIDs, "SPAM") || slices.Contains(m.LabelIDs, "TRASH")) {
+				add(m.Message.ID)
+			}
+		}
+	})
+	return ids, latest, err
+}
+
+// fetchNew assigns UIDs to messages (oldest first) and fetches them
+func (b *SyncBackend) fetchNew(ctx context.Context, label string, ids []string) ([]ports.IMAPEmail, error) {
+	var uids, err = b.state.AssignUIDs(label, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var byUID = make(map[uint32]string, len(uids))
+	for id, uid := range uids {
+		byUID[uid] = id
+	}
+	return b.fetchEmails(ctx, byUID)
+}
+
+// fetchEmails fetches messages in batches and converts them, sorted by UID
+func (b *SyncBackend) fetchEmails(ctx context.Context, ids map[uint32]string) ([]ports.IMAPEmail, error) {
+	var uidOf = make(map[string]uint32, len(ids))
+	var list = make([]string, 0, len(ids))
+	for uid, id := range ids {
+		uidOf[id] = uid
+		list = append(list, id)
+	}
+
+	var messages, err = b.batchGet(ctx, list, "full")
+	if err != nil {
+		return nil, err
+	}
+
+	var emails = make([]ports.IMAPEmail, 0, len(messages))
+	for i := range messages {
+		emails = append(emails, b.toEmail(&messages[i], uidOf[messages[i].ID]))
+	}
+	sort.Slice(emails, func(i, j int) bool { return emails[i].UID < emails[j].UID })
+	return emails, nil
+}
+
+// batchGet fetches messages 100 at a time
+func (b *SyncBackend) batchGet(ctx context.Context, ids []string, format string) ([]Message, error) {
+	var result []Message
+	for i := 0; i < len(ids); i += 100 {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var end = min(i+100, len(ids))
+		var messages, err = b.client.BatchGetMessages(ids[i:end], format)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, messages...)
+	}
+	return result, nil
+}
+
+// toEmail converts a message in full format
+func (b *SyncBackend) toEmail(m *Message, uid uint32) ports.IMAPEmail {
+	var email = ports.IMAPEmail{
+		UID:      uid,
+		Seen:     !slices.Contains(m.LabelIDs, "UNREAD"),
+		Flagged:  slices.Contains(m.LabelIDs, "STARRED"),
+		Size:     m.SizeEstimate,
+		ThreadID: m.ThreadID,
+		Labels:   []string{},
+	}
+
+	b.mu.RLock()
+	for _, id := range m.LabelIDs {
+		if l, ok := b.labels[id]; ok {
+			email.Labels = append(email.Labels, l.Name)
+		}
+	}
+	b.mu.RUnlock()
+
+	if ms, err := strconv.ParseInt(m.InternalDate, 10, 64); err == nil {
+		email.Date = time.UnixMilli(ms)
+	}
+	if m.Payload == nil {
+		return email
+	}
+
+	var p = m.Payload
+	email.MessageID = strings.Trim(p.Header("Message-ID"), "<>")
+	email.Subject = decodeHeader(p.Header("Subject"))
+	email.To = decodeHeader(p.Header("To"))
+	email.InReplyTo = p.Header("In-Reply-To")
+	email.References = p.Header("References")
+	if date, err := mail.ParseDate(p.Header("Date")); err == nil {
+		email.Date = date
+	}
+
+	var from = p.Header("From")
+	if addr, err := mail.ParseAddress(from); err == nil {
+		email.FromName = addr.Name
+		email.FromEmail = addr.Address
+	} else {
+		email.FromEmail = from
+	}
+	if email.FromName == "" {
+		email.FromName = email.FromEmail
+	}
+
+	if text := findText(p, "text/plain"); text != nil {
+		if data, err := DecodeData(text.Body.Data); err == nil {
+			email.BodyText = string(data)
+		}
+	}
+
+	email.RawHeaders = authHeaders(p)
+	email.Attachments = attachmentsOf(p)
+	email.HasAttachments = len(email.Attachments) > 0
+	return email
+}
+
+// authHeaders returns the authentication and sender headers of a message,
+// in order, for the risk analysis
+func authHeaders(p *MessagePart) string {
+	var lines []string
+	for _, h := range p.Headers {
+		switch strings.ToLower(h.Name) {
+		case "authentication-results", "received-spf", "dkim-signature", "from", "reply-to", "return-path":
+			lines = append(lines, h.Name+": "+h.Value)
+		}
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+// decodeHeader decodes RFC 2047 encoded words (the API returns headers as sent)
+func decodeHeader(value string) string {
+	var decoded, err = new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// findText returns the first non-attachment part of the MIME type
+func findText(p *MessagePart, mimeType string) *MessagePart {
+	if p.Filename == "" && strings.EqualFold(p.MimeType, mimeType) && p.Body.Data != "" {
+		return p
+	}
+	for i := range p.Parts {
+		if found := findText(&p.Parts[i], mimeType); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// attachmentsOf lists the parts with a filename as attachments
+func attachmentsOf(p *MessagePart) []ports.AttachmentInfo {
+	if p == nil {
+		return nil
+	}
+
+	var result []ports.AttachmentInfo
+	if p.Filename != "" {
+		result = append(result, ports.AttachmentInfo{
+			PartNumber:  imapPartNumber(p.PartID),
+			Filename:    p.Filename,
+			ContentType: p.MimeType,
+			ContentID:   p.Header("Content-ID"),
+			Encoding:    strings.ToLower(p.Header("Content-Transfer-Encoding")),
+			Size:        p.Body.Size,
+			IsInline:    strings.HasPrefix(strings.ToLower(p.Header("Content-Disposition")), "inline"),
+		})
+	}
+	for i := range p.Parts {
+		result = append(result, attachmentsOf(&p.Parts[i])...)
+	}
+	return result
+}
+
+// imapPartNumber converts a Gmail part ID (0-based: "0.1") to an IMAP part
+// number (1-based: "1.2"). The root of a single-part message is "1".
+func imapPartNumber(partID string) string {
+	if partID == "" {
+		return "1"
+	}
+	var fields = strings.Split(partID, ".")
+	for i, f := range fields {
+		var n, _ = strconv.Atoi(f)
+		fields[i] = strconv.Itoa(n + 1)
+	}
+	return strings.Join(fields, ".")
+}
+
+// findPart finds a part by IMAP part number
+func findPart(p *MessagePart, partNumber string) *MessagePart {
+	if p == nil {
+		return nil
+	}
+	if imapPartNumber(p.PartID) == partNumber && (p.PartID != "" || len(p.Parts) == 0) {
+		return p
+	}
+	for i := range p.Parts {
+		if found := findPart(&p.Parts[i], partNumber); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// encodePart re-encodes decoded content in a Content-Transfer-Encoding
+func encodePart(data []byte, encoding string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return []byte(base64.StdEncoding.EncodeToString(data)), nil
+	case "quoted-printable":
+		var buf bytes.Buffer
+		var w = quotedprintable.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return data, nil
+	}
+}
@@ -0,0 +1,516 @@
+package gmail
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// fakeGmail is a minimal in-process Gmail API: profile, labels, messages
+// (list/get/modify/trash), history and the batch endpoint
+type fakeGmail struct {
+	mu         sync.Mutex
+	historyID  uint64
+	minHistory uint64 // history.list below this returns 404 (expired)
+	labels     []Label
+	messages   map[string]*fakeMessage
+	order      []string // message IDs, oldest first
+	history    []History
+}
+
+type fakeMessage struct {
+	id      string
+	thread  string
+	labels  []string
+	subject string
+	from    string
+	body    string
+}
+
+func newFakeGmail() *fakeGmail {
+	return &fakeGmail{
+		historyID: 100,
+		labels: []Label{
+			{ID: "INBOX", Name: "INBOX", Type: "system"},
+			{ID: "SENT", Name: "SENT", Type: "system"},
+			{ID: "TRASH", Name: "TRASH", Type: "system"},
+			{ID: "UNREAD", Name: "UNREAD", Type: "system"},
+			{ID: "STARRED", Name: "STARRED", Type: "system"},
+			{ID: "Label_1", Name: "Work", Type: "user"},
+		},
+		messages: map[string]*fakeMessage{},
+	}
+}
+
+// record bumps the history ID and logs a change
+func (f *fakeGmail) record(h History) {
+	f.historyID++
+	h.ID = strconv.FormatUint(f.historyID, 10)
+	f.history = append(f.history, h)
+}
+
+func historyMessage(m *fakeMessage, labels ...string) HistoryMessage {
+	var hm HistoryMessage
+	hm.Message.ID = m.id
+	hm.Message.ThreadID = m.thread
+	hm.Message.LabelIDs = slices.Clone(m.labels)
+	hm.LabelIDs = labels
+	return hm
+}
+
+func (f *fakeGmail) addMessage(id, subject string, labels ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var m = &fakeMessage{
+		id:      id,
+		thread:  "t-" + id,
+		labels:  labels,
+		subject: subject,
+		from:    "Ana <ana@example.com>",
+		body:    "body of " + subject,
+	}
+	f.messages[id] = m
+	f.order = append(f.order, id)
+	f.record(History{MessagesAdded: []HistoryMessage{historyMessage(m)}})
+}
+
+func (f *fakeGmail) removeLabel(id, label string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var m = f.messages[id]
+	m.labels = slices.DeleteFunc(m.labels, func(l string) bool { return l == label })
+	f.record(History{LabelsRemoved: []HistoryMessage{historyMessage(m, label)}})
+}
+
+func (f *fakeGmail) deleteMessage(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var m = f.messages[id]
+	delete(f.messages, id)
+	f.order = slices.DeleteFunc(f.order, func(o string) bool { return o == id })
+	f.record(History{MessagesDeleted: []HistoryMessage{historyMessage(m)}})
+}
+
+func (f *fakeGmail) expireHistory() {
+	f.mu.Lock()
+	f.minHistory = f.historyID + 1
+	f.mu.Unlock()
+}
+
+func (f *fakeGmail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/batch/gmail/v1" {
+		f.serveBatch(w, r)
+		return
+	}
+
+	var status, body = f.route(r.Method, r.URL, r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// route answers a single API call
+func (f *fakeGmail) route(method string, u *url.URL, reqBody io.Reader) (int, interface{}) {
+	var notFound = map[string]interface{}{"error": map[string]interface{}{"code": 404}}
+	var path = strings.TrimPrefix(u.Path, "/gmail/v1/users/me")
+	var q = u.Query()
+
+	switch {
+	case path == "/profile":
+		return 200, Profile{EmailAddress: "me@example.com", MessagesTotal: len(f.messages), HistoryID: strconv.FormatUint(f.historyID, 10)}
+
+	case path == "/labels":
+		return 200, map[string]interface{}{"labels": f.labels}
+
+	case strings.HasPrefix(path, "/labels/"):
+		var id = strings.TrimPrefix(path, "/labels/")
+		for _, l := range f.labels {
+			if l.ID == id {
+				for _, m := range f.messages {
+					if slices.Contains(m.labels, id) {
+						l.MessagesTotal++
+						if slices.Contains(m.labels, "UNREAD") {
+							l.MessagesUnread++
+						}
+					}
+				}
+				return 200, l
+			}
+		}
+		return 404, notFound
+
+	case path == "/messages":
+		var resp ListMessagesResponse
+		for i := len(f.order) - 1; i >= 0; i-- {
+			var m = f.messages[f.order[i]]
+			if label := q.Get("labelIds"); label != "" && !slices.Contains(m.labels, label) {
+				continue
+			}
+			resp.Messages = append(resp.Messages, MessageInfo{ID: m.id, ThreadID: m.thread})
+		}
+		return 200, resp
+
+	case path == "/history":
+		var start, _ = strconv.ParseUint(q.Get("startHistoryId"), 10, 64)
+		if start < f.minHistory {
+			return 404, notFound
+		}
+		var resp = HistoryResponse{HistoryID: strconv.FormatUint(f.historyID, 10)}
+		for _, h := range f.history {
+			if ParseHistoryID(h.ID) > start {
+				resp.History = append(resp.History, h)
+			}
+		}
+		return 200, resp
+
+	case strings.HasPrefix(path, "/messages/"):
+		var rest = strings.Split(strings.TrimPrefix(path, "/messages/"), "/")
+		var m, ok = f.messages[rest[0]]
+		if !ok {
+			return 404, notFound
+		}
+		if len(rest) == 2 && rest[1] == "modify" && method == http.MethodPost {
+			var req ModifyLabelsRequest
+			json.NewDecoder(reqBody).Decode(&req)
+			for _, l := range req.RemoveLabelIDs {
+				m.labels = slices.DeleteFunc(m.labels, func(o string) bool { return o == l })
+			}
+			m.labels = append(m.labels, req.AddLabelIDs...)
+			var h History
+			if len(req.AddLabelIDs) > 0 {
+				h.LabelsAdded = []HistoryMessage{historyMessage(m, req.AddLabelIDs...)}
+			}
+			if len(req.RemoveLabelIDs) > 0 {
+				h.LabelsRemoved = []HistoryMessage{historyMessage(m, req.RemoveLabelIDs...)}
+			}
+			f.record(h)
+			return 200, map[string]string{"id": m.id}
+		}
+		return 200, f.render(m, q.Get("format"))
+	}
+	return 404, notFound
+}
+
+// render returns a message in the requested format
+func (f *fakeGmail) render(m *fakeMessage, format string) Message {
+	var message = Message{ID: m.id, ThreadID: m.thread, LabelIDs: slices.Clone(m.labels), InternalDate: "1700000000000"}
+	var headers = []MessagePartHeader{
+		{Name: "Message-ID", Value: "<" + m.id + "@example.com>"},
+		{Name: "Subject", Value: m.subject},
+		{Name: "From", Value: m.from},
+		{Name: "To", Value: "me@example.com"},
+	}
+
+	switch format {
+	case "raw":
+		var raw strings.Builder
+		for _, h := range headers {
+			fmt.Fprintf(&raw, "%s: %s\r\n", h.Name, h.Value)
+		}
+		raw.WriteString("\r\n" + m.body)
+		message.Raw = base64.URLEncoding.EncodeToString([]byte(raw.String()))
+	case "full":
+		message.Payload = &MessagePart{
+			MimeType: "text/plain",
+			Headers:  headers,
+			Body:     MessagePartBody{Data: base64.RawURLEncoding.EncodeToString([]byte(m.body))},
+		}
+	case "metadata":
+		message.Payload = &MessagePart{Headers: headers[:1]}
+	}
+	return message
+}
+
+// serveBatch answers a multipart batch of GET requests
+func (f *fakeGmail) serveBatch(w http.ResponseWriter, r *http.Request) {
+	var _, params, _ = mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var reader = multipart.NewReader(r.Body, params["boundary"])
+
+	var out = multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+out.Boundary())
+	for {
+		var part, err = reader.NextPart()
+		if err != nil {
+			break
+		}
+		var data, _ = io.ReadAll(part)
+		var line = strings.Fields(strings.SplitN(string(data), "\r\n", 2)[0])
+		var u, _ = url.Parse(line[1])
+
+		var status, body = f.route(line[0], u, nil)
+		var payload, _ = json.Marshal(body)
+		var pw, _ = out.CreatePart(map[string][]string{"Content-Type": {"application/http"}})
+		fmt.Fprintf(pw, "HTTP/1.1 %d %s\r\nContent-Type: application/json\r\n\r\n%s", status, http.StatusText(status), payload)
+	}
+	out.Close()
+}
+
+// redirectTransport sends every request to the fake server
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// memState is an in-memory SyncState
+type memState struct {
+	uids    map[string]map[string]uint32
+	history map[string]uint64
+}
+
+func newMemState() *memState {
+	return &memState{uids: map[string]map[string]uint32{}, history: map[string]uint64{}}
+}
+
+func (s *memState) AssignUIDs(labelID string, gmailIDs []string) (map[string]uint32, error) {
+	if s.uids[labelID] == nil {
+		s.uids[labelID] = map[string]uint32{}
+	}
+	var label = s.uids[labelID]
+	var result = map[string]uint32{}
+	for _, id := range gmailIDs {
+		if _, ok := label[id]; !ok {
+			label[id] = uint32(len(label) + 1)
+		}
+		result[id] = label[id]
+	}
+	return result, nil
+}
+
+func (s *memState) LookupUIDs(labelID string, gmailIDs []string) (map[string]uint32, error) {
+	var result = map[string]uint32{}
+	for _, id := range gmailIDs {
+		if uid, ok := s.uids[labelID][id]; ok {
+			result[id] = uid
+		}
+	}
+	return result, nil
+}
+
+func (s *memState) GmailIDs(labelID string, uids []uint32) (map[uint32]string, error) {
+	var result = map[uint32]string{}
+	for id, uid := range s.uids[labelID] {
+		if slices.Contains(uids, uid) {
+			result[uid] = id
+		}
+	}
+	return result, nil
+}
+
+func (s *memState) GmailIDsAfter(labelID string, after uint32) (map[uint32]string, error) {
+	var result = map[uint32]string{}
+	for id, uid := range s.uids[labelID] {
+		if uid > after {
+			result[uid] = id
+		}
+	}
+	return result, nil
+}
+
+func (s *memState) HistoryID(labelID string) (uint64, error) {
+	return s.history[labelID], nil
+}
+
+func (s *memState) SetHistoryID(labelID string, historyID uint64) error {
+	s.history[labelID] = historyID
+	return nil
+}
+
+// newTestBackend connects a SyncBackend to a fake with three inbox messages
+// and selects INBOX
+func newTestBackend(t *testing.T) (*SyncBackend, *fakeGmail, *memState) {
+	var fake = newFakeGmail()
+	fake.addMessage("m1", "first", "INBOX", "UNREAD")
+	fake.addMessage("m2", "second", "INBOX", "STARRED", "Label_1")
+	fake.addMessage("m3", "third", "INBOX", "UNREAD")
+
+	var server = httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	var target, _ = url.Parse(server.URL)
+
+	var client = NewClientWithHTTP(&http.Client{Transport: redirectTransport{target: target}}, "me@example.com")
+	var state = newMemState()
+	var backend = NewSyncBackend(client, state)
+	if err := backend.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if _, err := backend.SelectMailbox(context.Background(), "INBOX"); err != nil {
+		t.Fatalf("SelectMailbox: %v", err)
+	}
+	return backend, fake, state
+}
+
+func subjects(emails []ports.IMAPEmail) []string {
+	var result []string
+	for _, e := range emails {
+		result = append(result, fmt.Sprintf("%d:%s", e.UID, e.Subject))
+	}
+	return result
+}
+
+func TestSyncBackendInitialSync(t *testing.T) {
+	var backend, fake, state = newTestBackend(t)
+
+	var emails, err = backend.FetchNewEmailsBatch(context.Background(), 0, 100)
+	if err != nil {
+		t.Fatalf("FetchNewEmailsBatch: %v", err)
+	}
+
+	var got = strings.Join(subjects(emails), ",")
+	if got != "1:first,2:second,3:third" {
+		t.Fatalf("emails = %s, want UIDs in arrival order", got)
+	}
+	var second = emails[1]
+	if !second.Seen || !second.Flagged || second.ThreadID != "t-m2" || second.MessageID != "m2@example.com" {
+		t.Errorf("second = %+v", second)
+	}
+	if !slices.Contains(second.Labels, "Work") || second.BodyText != "body of second" {
+		t.Errorf("second labels/body = %v %q", second.Labels, second.BodyText)
+	}
+	if emails[0].Seen || emails[0].FromName != "Ana" || emails[0].FromEmail != "ana@example.com" {
+		t.Errorf("first = %+v", emails[0])
+	}
+	if state.history["INBOX"] != fake.historyID {
+		t.Errorf("checkpoint = %d, want %d", state.history["INBOX"], fake.historyID)
+	}
+}
+
+func TestSyncBackendIncrementalSync(t *testing.T) {
+	var ctx = context.Background()
+	var backend, fake, _ = newTestBackend(t)
+	if _, err := backend.FetchNewEmailsBatch(ctx, 0, 100); err != nil {
+		t.Fatalf("initial sync: %v", err)
+	}
+	var status, _ = backend.SelectMailbox(ctx, "INBOX")
+
+	fake.addMessage("m4", "fourth", "INBOX", "UNREAD")
+	fake.addMessage("s1", "not in inbox", "SENT")
+	fake.removeLabel("m1", "UNREAD")
+	fake.deleteMessage("m2")
+	fake.removeLabel("m3", "INBOX") // archived
+
+	var emails, err = backend.FetchNewEmailsBatch(ctx, 3, 100)
+	if err != nil {
+		t.Fatalf("FetchNewEmailsBatch: %v", err)
+	}
+	if got := strings.Join(subjects(emails), ","); got != "4:fourth" {
+		t.Errorf("new emails = %s, want 4:fourth", got)
+	}
+
+	var updates, err2 = backend.FetchFlagChanges(ctx, status.HighestModSeq)
+	if err2 != nil {
+		t.Fatalf("FetchFlagChanges: %v", err2)
+	}
+	var byUID = map[uint32]ports.FlagUpdate{}
+	for _, u := range updates {
+		byUID[u.UID] = u
+	}
+	if u := byUID[1]; !u.Seen || u.Deleted {
+		t.Errorf("m1 = %+v, want seen", u)
+	}
+	if u := byUID[2]; !u.Deleted {
+		t.Errorf("m2 = %+v, want deleted", u)
+	}
+	if u := byUID[3]; !u.Deleted {
+		t.Errorf("m3 = %+v, want deleted (left INBOX)", u)
+	}
+
+	var uids, err3 = backend.GetAllUIDs(ctx)
+	if err3 != nil {
+		t.Fatalf("GetAllUIDs: %v", err3)
+	}
+	if !slices.Equal(uids, []uint32{1, 4}) {
+		t.Errorf("GetAllUIDs = %v, want [1 4]", uids)
+	}
+}
+
+func TestSyncBackendExpiredHistory(t *testing.T) {
+	var ctx = context.Background()
+	var backend, fake, state = newTestBackend(t)
+	if _, err := backend.FetchNewEmailsBatch(ctx, 0, 100); err != nil {
+		t.Fatalf("initial sync: %v", err)
+	}
+
+	fake.addMessage("m4", "fourth", "INBOX")
+	fake.expireHistory()
+
+	var emails, err = backend.FetchNewEmailsBatch(ctx, 3, 100)
+	if err != nil {
+		t.Fatalf("FetchNewEmailsBatch: %v", err)
+	}
+	if got := strings.Join(subjects(emails), ","); got != "4:fourth" {
+		t.Errorf("new emails = %s, want 4:fourth", got)
+	}
+	if state.history["INBOX"] != fake.historyID {
+		t.Errorf("checkpoint = %d, want %d", state.history["INBOX"], fake.historyID)
+	}
+
+	// Flags of every known message are refreshed
+	var updates, err2 = backend.FetchFlagChanges(ctx, 101)
+	if err2 != nil {
+		t.Fatalf("FetchFlagChanges: %v", err2)
+	}
+	if len(updates) != 4 {
+		t.Errorf("updates = %+v, want all 4 messages", updates)
+	}
+}
+
+func TestSyncBackendActions(t *testing.T) {
+	var ctx = context.Background()
+	var backend, fake, _ = newTestBackend(t)
+	if _, err := backend.FetchNewEmailsBatch(ctx, 0, 100); err != nil {
+		t.Fatalf("initial sync: %v", err)
+	}
+
+	if err := backend.MarkAsRead(ctx, 1); err != nil {
+		t.Fatalf("MarkAsRead: %v", err)
+	}
+	if err := backend.AddKeyword(ctx, 3, "Work"); err != nil {
+		t.Fatalf("AddKeyword: %v", err)
+	}
+	if slices.Contains(fake.messages["m1"].labels, "UNREAD") {
+		t.Errorf("m1 labels = %v, want no UNREAD", fake.messages["m1"].labels)
+	}
+	if !slices.Contains(fake.messages["m3"].labels, "Label_1") {
+		t.Errorf("m3 labels = %v, want Label_1", fake.messages["m3"].labels)
+	}
+
+	var body, err = backend.FetchEmailBody(ctx, 2)
+	if err != nil || body != "body of second" {
+		t.Errorf("FetchEmailBody = %q, %v", body, err)
+	}
+}
+
+func TestIMAPPartNumber(t *testing.T) {
+	var tests = map[string]string{"": "1", "0": "1", "1": "2", "1.0": "2.1", "2.3.0": "3.4.1"}
+	for partID, want := range tests {
+		if got := imapPartNumber(partID); got != want {
+			t.Errorf("imapPartNumber(%q) = %q, want %q", partID, got, want)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opik/miau/internal/rrule"
+)
+
+// ProdID identifies miau in the calendars it writes
+const ProdID = "-//miau//miau//EN"
+
+// Method is the iTIP method of a calendar (RFC 5546)
+type Method string
+
+const (
+	MethodPublish Method = "PUBLISH"
+	MethodRequest Method = "REQUEST"
+	MethodReply   Method = "REPLY"
+	MethodCancel  Method = "CANCEL"
+)
+
+// Kind is the type of a calendar component
+type Kind string
+
+const (
+	KindEvent Kind = "VEVENT"
+	KindTodo  Kind = "VTODO"
+)
+
+// PartStat is the participation status of an attendee
+type PartStat string
+
+const (
+	NeedsAction PartStat = "NEEDS-ACTION"
+	Accepted    PartStat = "ACCEPTED"
+	Tentative   PartStat = "TENTATIVE"
+	Declined    PartStat = "DECLINED"
+)
+
+// Attendee is an ATTENDEE or the ORGANIZER of a component
+type Attendee struct {
+	Email    string // without "mailto:"
+	Name     string // CN
+	Role     string // REQ-PARTICIPANT, OPT-PARTICIPANT, CHAIR...
+	PartStat PartStat
+	RSVP     bool // the organizer expects a reply
+}
+
+// Event is a VEVENT or a VTODO
+type Event struct {
+	Kind         Kind
+	UID          string
+	RecurrenceID string // exception of a recurring series, as UTC "20060102T150405Z" (a date for all-day); "" for the series
+	Sequence     int
+	Summary      string
+	Description  string
+	Location     string
+	Status       string // CONFIRMED, TENTATIVE, CANCELLED (VTODO: NEEDS-ACTION, COMPLETED...)
+	Start        time.Time
+	End          time.Time // VEVENT: DTEND, or DTSTART + DURATION; zero if none
+	Due          time.Time // VTODO
+	AllDay       bool
+	TimeZone     string // TZID of DTSTART; "" for UTC, floating times and dates
+	RRule        string
+	Organizer    Attendee
+	Attendees    []Attendee
+
+	component *Component
+}
+
+// Calendar is a parsed VCALENDAR
+type Calendar struct {
+	Method Method // "" when not an iTIP message
+	ProdID string
+	Events []Event // VEVENTs and VTODOs, in order
+
+	root *Component
+	tz   *timeZones
+}
+
+// Parse reads the VEVENTs and VTODOs of an iCalendar object. Floating
+// times and dates are read in the local time zone.
+func Parse(data []byte) (*Calendar, error) {
+	return parse(data, time.Local)
+}
+
+func parse(data []byte, local *time.Location) (*Calendar, error) {
+	var root, err = Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.Name != "VCALENDAR" {
+		return nil, fmt.Errorf("ical: expected VCALENDAR, got %s", root.Name)
+	}
+
+	var cal = &Calendar{
+		Method: Method(strings.ToUpper(root.Text("METHOD"))),
+		ProdID: root.Text("PRODID"),
+		root:   root,
+		tz:     newTimeZones(root, local),
+	}
+	for _, c := range root.Children {
+		if c.Name != string(KindEvent) && c.Name != string(KindTodo) {
+			continue
+		}
+		var e, err = parseEvent(c, cal.tz)
+		if err != nil {
+			return nil, err
+		}
+		if cal.Method == MethodCancel && e.Status == "" {
+			e.Status = "CANCELLED"
+		}
+		cal.Events = append(cal.Events, *e)
+	}
+	return cal, nil
+}
+
+// parseEvent reads a VEVENT or VTODO
+func parseEvent(c *Component, tz *timeZones) (*Event, error) {
+	var e = &Event{
+		Kind:        Kind(c.Name),
+		UID:         c.Text("UID"),
+		Summary:     c.Text("SUMMARY"),
+		Description: c.Text("DESCRIPTION"),
+		Location:    c.Text("LOCATION"),
+		Status:      strings.ToUpper(c.Text("STATUS")),
+		component:   c,
+	}
+	if e.UID == "" {
+		return nil, fmt.Errorf("ical: %s without UID", c.Name)
+	}
+	e.Sequence, _ = strconv.Atoi(c.Text("SEQUENCE"))
+
+	var err error
+	if p := c.Get("DTSTART"); p != nil {
+		if e.Start, e.AllDay, err = tz.parseTime(p); err != nil {
+			return nil, fmt.Errorf("ical: DTSTART: %w", err)
+		}
+		e.TimeZone = p.Param("TZID")
+	}
+	if p := c.Get("DTEND"); p != nil {
+		if e.End, _, err = tz.parseTime(p); err != nil {
+			return nil, fmt.Errorf("ical: DTEND: %w", err)
+		}
+	} else if p := c.Get("DURATION"); p != nil && !e.Start.IsZero() {
+		var d, err = parseDuration(p.Value)
+		if err != nil {
+			return nil, fmt.Errorf("ical: DURATION: %w", err)
+		}
+		e.End = e.Start.Add(d)
+	}
+	if p := c.Get("DUE"); p != nil {
+		if e.Due, _, err = tz.parseTime(p); err != nil {
+			return nil, fmt.Errorf("ical: DUE: %w", err)
+		}
+	}
+	if e.Kind == KindEvent && e.Start.IsZero() {
+		return nil, fmt.Errorf("ical: VEVENT %s without DTSTART", e.UID)
+	}
+
+	if p := c.Get("RECURRENCE-ID"); p != nil {
+		var rid, allDay, err = tz.parseTime(p)
+		if err != nil {
+			return nil, fmt.Errorf("ical: RECURRENCE-ID: %w", err)
+		}
+		if allDay {
+			e.RecurrenceID = rid.Format("20060102")
+		} else {
+			e.RecurrenceID = rid.UTC().Format("20060102T150405Z")
+		}
+	}
+	if p := c.Get("RRULE"); p != nil {
+		e.RRule = p.Value
+	}
+
+	if p := c.Get("ORGANIZER"); p != nil {
+		e.Organizer = parseAttendee(p)
+	}
+	for _, p := range c.GetAll("ATTENDEE") {
+		e.Attendees = append(e.Attendees, parseAttendee(&p))
+	}
+	return e, nil
+}
+
+// parseAttendee reads an ATTENDEE or ORGANIZER property
+func parseAttendee(p *Property) Attendee {
+	var a = Attendee{
+		Email:    mailAddress(p.Value),
+		Name:     p.Param("CN"),
+		Role:     strings.ToUpper(p.Param("ROLE")),
+		PartStat: PartStat(strings.ToUpper(p.Param("PARTSTAT"))),
+		RSVP:     strings.EqualFold(p.Param("RSVP"), "TRUE"),
+	}
+	if a.PartStat == "" {
+		a.PartStat = NeedsAction
+	}
+	return a
+}
+
+// mailAddress returns the address of a mailto: URI
+func mailAddress(uri string) string {
+	var value = strings.TrimSpace(uri)
+	if len(value) >= 7 && strings.EqualFold(value[:7], "mailto:") {
+		value = value[7:]
+	}
+	return value
+}
+
+// parseDuration parses a DURATION value such as "PT1H30M", "P1D" or "-P1W"
+func parseDuration(s string) (time.Duration, error) {
+	var value = strings.ToUpper(strings.TrimSpace(s))
+	var sign = time.Duration(1)
+	switch {
+	case strings.HasPrefix(value, "-"):
+		sign, value = -1, value[1:]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+	if !strings.HasPrefix(value, "P") || len(value) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var total time.Duration
+	var inTime = false
+	var n = 0
+	var digits = false
+	for _, ch := range value[1:] {
+		switch {
+		case ch >= '0' && ch <= '9':
+			n = n*10 + int(ch-'0')
+			digits = true
+			continue
+		case ch == 'T':
+			inTime = true
+			continue
+		}
+		if !digits {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		var unit time.Duration
+		switch {
+		case ch == 'W' && !inTime:
+			unit = 7 * 24 * time.Hour
+		case ch == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case ch == 'H' && inTime:
+			unit = time.Hour
+		case ch == 'M' && inTime:
+			unit = time.Minute
+		case ch == 'S' && inTime:
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total += time.Duration(n) * unit
+		n, digits = 0, false
+	}
+	if digits {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return sign * total, nil
+}
+
+// Main returns the component that represents the calendar: the first one
+// that is not an exception of a recurring series (nil if there is none)
+func (c *Calendar) Main() *Event {
+	for i := range c.Events {
+		if c.Events[i].RecurrenceID == "" {
+			return &c.Events[i]
+		}
+	}
+	if len(c.Events) > 0 {
+		return &c.Events[0]
+	}
+	return nil
+}
+
+// Find returns the component with the UID and RECURRENCE-ID, or nil
+func (c *Calendar) Find(uid, recurrenceID string) *Event {
+	for i := range c.Events {
+		if c.Events[i].UID == uid && c.Events[i].RecurrenceID == recurrenceID {
+			return &c.Events[i]
+		}
+	}
+	return nil
+}
+
+// Attendee returns the attendee with the address, or nil
+func (e *Event) Attendee(email string) *Attendee {
+	for i := range e.Attendees {
+		if strings.EqualFold(e.Attendees[i].Email, email) {
+			return &e.Attendees[i]
+		}
+	}
+	return nil
+}
+
+// Cancelled reports whether the event was cancelled by the organizer
+func (e *Event) Cancelled() bool {
+	return e.Status == "CANCELLED"
+}
+
+// Recurrence parses the RRULE of the event (nil if it doesn't repeat).
+// Occurrences follow the location of Start.
+func (e *Event) Recurrence() (*rrule.Rule, error) {
+	if e.RRule == "" {
+		return nil, nil
+	}
+	return rrule.Parse(e.RRule)
+}
+
+// replyProperties are copied from the invitation into a REPLY
+var replyProperties = []string{
+	"UID", "RECURRENCE-ID", "SEQUENCE", "DTSTART", "DTEND", "DURATION", "DUE", "SUMMARY", "LOCATION", "ORGANIZER",
+}
+
+// Reply builds the iTIP REPLY (RFC 5546 3.2.3) of an attendee to an event
+// of the calendar: the identity, times and organizer of the invitation and
+// only the replying attendee, with its PARTSTAT
+func (c *Calendar) Reply(e *Event, from Attendee, now time.Time) []byte {
+	var root = &Component{Name: "VCALENDAR"}
+	root.Add("PRODID", ProdID, nil)
+	root.Add("VERSION", "2.0", nil)
+	root.Add("METHOD", string(MethodReply), nil)
+
+	// The VTIMEZONEs the copied times may refer to
+	for _, child := range c.root.Children {
+		if child.Name == "VTIMEZONE" {
+			root.Children = append(root.Children, child)
+		}
+	}
+
+	var reply = &Component{Name: string(e.Kind)}
+	for _, name := range replyProperties {
+		reply.Properties = append(reply.Properties, e.component.GetAll(name)...)
+	}
+	reply.Add("DTSTAMP", now.UTC().Format("20060102T150405Z"), nil)
+
+	var params = map[string]string{"PARTSTAT": string(from.PartStat)}
+	if from.Name != "" {
+		params["CN"] = from.Name
+	}
+	reply.Add("ATTENDEE", "mailto:"+from.Email, params)
+
+	root.Children = append(root.Children, reply)
+	return root.Encode()
+}
+
+// New returns an empty calendar, as written by miau
+func New() *Calendar {
+	var root = &Component{Name: "VCALENDAR"}
+	root.Add("PRODID", ProdID, nil)
+	root.Add("VERSION", "2.0", nil)
+	return &Calendar{ProdID: ProdID, root: root, tz: newTimeZones(root, time.Local)}
+}
+
+// Update writes the fields of e a calendar client edits (summary,
+// description, location, times and status) into the component it was read
+// from, or into a new VEVENT added to the calendar when e is not from c.
+// Everything else the component carries (attendees, alarms, recurrence...)
+// is kept. now is the DTSTAMP.
+func (c *Calendar) Update(e *Event, now time.Time) {
+	if e.component == nil || !c.contains(e.component) {
+		var kind = e.Kind
+		if kind == "" {
+			kind = KindEvent
+		}
+		e.Kind = kind
+		e.component = &Component{Name: string(kind)}
+		e.component.Add("UID", e.UID, nil)
+		c.root.Children = append(c.root.Children, e.component)
+		c.Events = append(c.Events, *e)
+	}
+	var comp = e.component
+
+	comp.Set("DTSTAMP", now.UTC().Format("20060102T150405Z"), nil)
+	setText(comp, "SUMMARY", e.Summary)
+	setText(comp, "DESCRIPTION", e.Description)
+	setText(comp, "LOCATION", e.Location)
+	setText(comp, "STATUS", e.Status)
+	if e.Sequence > 0 {
+		comp.Set("SEQUENCE", strconv.Itoa(e.Sequence), nil)
+	}
+
+	var start = c.timeProperty(e.Start, e.AllDay, e.TimeZone)
+	comp.Set("DTSTART", start.Value, start.Params)
+	comp.Remove("DURATION")
+	if e.End.IsZero() {
+		comp.Remove("DTEND")
+	} else {
+		var end = c.timeProperty(e.End, e.AllDay, e.TimeZone)
+		comp.Set("DTEND", end.Value, end.Params)
+	}
+
+	// Keep the copy in Events in step
+	for i := range c.Events {
+		if c.Events[i].component == comp {
+			c.Events[i] = *e
+		}
+	}
+}
+
+// Encode writes the calendar
+func (c *Calendar) Encode() []byte {
+	return c.root.Encode()
+}
+
+// contains reports whether comp is a component of the calendar
+func (c *Calendar) contains(comp *Component) bool {
+	for _, child := range c.root.Children {
+		if child == comp {
+			return true
+		}
+	}
+	return false
+}
+
+// timeProperty returns the value and parameters of a DATE (allDay) or
+// DATE-TIME property: in the zone tzid when the calendar knows it,
+// otherwise in UTC
+func (c *Calendar) timeProperty(t time.Time, allDay bool, tzid string) Property {
+	if allDay {
+		return Property{Params: map[string]string{"VALUE": "DATE"}, Value: t.Format("20060102")}
+	}
+	if tzid != "" {
+		if wall, ok := c.tz.wallClock(tzid, t); ok {
+			return Property{Params: map[string]string{"TZID": tzid}, Value: wall.Format("20060102T150405")}
+		}
+	}
+	return Property{Value: t.UTC().Format("20060102T150405Z")}
+}
+
+// setText sets a TEXT property, or removes it when empty
+func setText(c *Component, name, value string) {
+	if value == "" {
+		c.Remove(name)
+		return
+	}
+	c.Set(name, EscapeText(value), nil)
+}
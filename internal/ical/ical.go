@@ -0,0 +1,328 @@
+// Package ical reads and writes iCalendar (RFC 5545) objects, as carried by
+// the text/calendar parts of meeting invitations (iTIP, RFC 5546, over
+// email as iMIP, RFC 6047).
+//
+// Decode and Encode work on the generic component tree (BEGIN/END blocks of
+// content lines); Parse builds on it to read the VEVENTs and VTODOs of a
+// calendar, resolving their times with the VTIMEZONEs it carries. Reply
+// writes the iTIP REPLY an attendee sends back to the organizer; New and
+// Update write the calendar objects kept on CalDAV servers.
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Property is a content line: NAME;PARAM=value:VALUE
+type Property struct {
+	Name   string
+	Params map[string]string // upper-case names; quotes removed
+	Value  string            // as written: TEXT escapes are not decoded
+}
+
+// Param returns a parameter of the property ("" if absent)
+func (p *Property) Param(name string) string {
+	return p.Params[name]
+}
+
+// Component is a BEGIN:NAME ... END:NAME block
+type Component struct {
+	Name       string
+	Properties []Property
+	Children   []*Component
+}
+
+// Get returns the first property with the name, or nil
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// GetAll returns every property with the name
+func (c *Component) GetAll(name string) []Property {
+	var list []Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// Text returns the unescaped TEXT value of a property ("" if absent)
+func (c *Component) Text(name string) string {
+	var p = c.Get(name)
+	if p == nil {
+		return ""
+	}
+	return UnescapeText(p.Value)
+}
+
+// Add appends a property
+func (c *Component) Add(name, value string, params map[string]string) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// Set replaces every property with the name by a single one, in the place
+// of the first
+func (c *Component) Set(name, value string, params map[string]string) {
+	var p = Property{Name: name, Params: params, Value: value}
+	var kept = c.Properties[:0]
+	var set bool
+	for _, old := range c.Properties {
+		if old.Name != name {
+			kept = append(kept, old)
+		} else if !set {
+			kept = append(kept, p)
+			set = true
+		}
+	}
+	c.Properties = kept
+	if !set {
+		c.Properties = append(c.Properties, p)
+	}
+}
+
+// Remove deletes every property with the name
+func (c *Component) Remove(name string) {
+	var kept = c.Properties[:0]
+	for _, p := range c.Properties {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	c.Properties = kept
+}
+
+// Decode parses an iCalendar stream and returns its first top-level
+// component (normally VCALENDAR)
+func Decode(data []byte) (*Component, error) {
+	var roots, err = decode(data, false)
+	if err != nil {
+		return nil, err
+	}
+	return roots[0], nil
+}
+
+// DecodeAll parses a stream of several top-level components, like the
+// VCARDs of a .vcf file
+func DecodeAll(data []byte) ([]*Component, error) {
+	return decode(data, true)
+}
+
+func decode(data []byte, all bool) ([]*Component, error) {
+	var stack []*Component
+	var roots []*Component
+	var root *Component
+
+	for n, line := range unfold(data) {
+		if line == "" {
+			continue
+		}
+		var prop, err = parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("ical: line %d: %w", n+1, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			var c = &Component{Name: strings.ToUpper(prop.Value)}
+			if len(stack) > 0 {
+				var parent = stack[len(stack)-1]
+				parent.Children = append(parent.Children, c)
+			} else if root == nil {
+				root = c
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("ical: line %d: unexpected END:%s", n+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 && root != nil {
+				roots = append(roots, root)
+				if !all {
+					return roots, nil
+				}
+				root = nil
+			}
+		default:
+			if len(stack) > 0 {
+				var c = stack[len(stack)-1]
+				c.Properties = append(c.Properties, prop)
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("ical: missing END:%s", stack[len(stack)-1].Name)
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("ical: no component found")
+	}
+	return roots, nil
+}
+
+// unfold splits data in logical lines, joining folded ones (a line break
+// followed by a space or tab continues the previous line)
+func unfold(data []byte) []string {
+	var lines []string
+	var scanner = bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var line = strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseLine parses a content line; quoted parameter values may contain
+// ':', ';' and ','
+func parseLine(line string) (Property, error) {
+	var prop = Property{Params: map[string]string{}}
+
+	var i = strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return prop, fmt.Errorf("invalid content line %q", line)
+	}
+	prop.Name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		var rest = line[i+1:]
+		var eq = strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return prop, fmt.Errorf("invalid parameter in %q", line)
+		}
+		var name = strings.ToUpper(rest[:eq])
+
+		var value strings.Builder
+		var j = eq + 1
+		var quoted = false
+		for ; j < len(rest); j++ {
+			var ch = rest[j]
+			if ch == '"' {
+				quoted = !quoted
+				continue
+			}
+			if !quoted && (ch == ';' || ch == ':') {
+				break
+			}
+			value.WriteByte(ch)
+		}
+		if j >= len(rest) {
+			return prop, fmt.Errorf("missing value in %q", line)
+		}
+		// A repeated parameter is a list (vCard: TYPE=work;TYPE=pref)
+		if prev, ok := prop.Params[name]; ok {
+			prop.Params[name] = prev + "," + value.String()
+		} else {
+			prop.Params[name] = value.String()
+		}
+		i += 1 + j
+	}
+
+	prop.Value = line[i+1:]
+	return prop, nil
+}
+
+// Encode writes the component as an iCalendar stream (CRLF line endings,
+// lines folded at 75 octets)
+func (c *Component) Encode() []byte {
+	var buf bytes.Buffer
+	c.encode(&buf)
+	return buf.Bytes()
+}
+
+func (c *Component) encode(buf *bytes.Buffer) {
+	writeLine(buf, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		writeLine(buf, formatProperty(p))
+	}
+	for _, child := range c.Children {
+		child.encode(buf)
+	}
+	writeLine(buf, "END:"+c.Name)
+}
+
+// formatProperty renders a content line; parameters are sorted so the
+// output is stable
+func formatProperty(p Property) string {
+	var b strings.Builder
+	b.WriteString(p.Name)
+
+	var names = make([]string, 0, len(p.Params))
+	for name := range p.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var value = p.Params[name]
+		var special = ":;,"
+		if name == "TYPE" {
+			special = ":;" // a list of types (vCard)
+		}
+		if strings.ContainsAny(value, special) {
+			value = `"` + strings.ReplaceAll(value, `"`, "") + `"`
+		}
+		b.WriteString(";" + name + "=" + value)
+	}
+
+	b.WriteString(":" + p.Value)
+	return b.String()
+}
+
+// writeLine writes a content line folded at 75 octets, never splitting a
+// UTF-8 sequence
+func writeLine(buf *bytes.Buffer, line string) {
+	var limit = 75
+	for len(line) > limit {
+		var cut = limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // the leading space counts
+	}
+	buf.WriteString(line + "\r\n")
+}
+
+// UnescapeText decodes a TEXT value (\n, \, \; and \\)
+func UnescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// EscapeText encodes a TEXT value
+func EscapeText(s string) string {
+	var r = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
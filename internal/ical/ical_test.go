@@ -0,0 +1,271 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// outlookInvite uses a Windows time zone name, defined by its VTIMEZONE
+const outlookInvite = "BEGIN:VCALENDAR\r\n" +
+	"METHOD:REQUEST\r\n" +
+	"PRODID:Microsoft Exchange Server 2010\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:W. Europe Standard Time\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:16010101T030000\r\n" +
+	"TZOFFSETFROM:+0200\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=10\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:16010101T020000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0200\r\n" +
+	"RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=3\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"ORGANIZER;CN=\"Doe, Jane\":mailto:jane@example.com\r\n" +
+	"ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE;CN=Me:mailto:\r\n" +
+	" me@example.org\r\n" +
+	"ATTENDEE;ROLE=OPT-PARTICIPANT;PARTSTAT=ACCEPTED;CN=Bob:MAILTO:bob@example.com\r\n" +
+	"DESCRIPTION;LANGUAGE=en-US:Agenda:\\n1. Budget\\, Q4\\n2. Hiring\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=TU;COUNT=4\r\n" +
+	"SUMMARY;LANGUAGE=en-US:Weekly sync\r\n" +
+	"DTSTART;TZID=W. Europe Standard Time:20261027T100000\r\n" +
+	"DTEND;TZID=W. Europe Standard Time:20261027T110000\r\n" +
+	"UID:040000008200E00074C5B7101A82E008\r\n" +
+	"SEQUENCE:2\r\n" +
+	"LOCATION:Room 4\r\n" +
+	"STATUS:CONFIRMED\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+// TestParseOutlookInvite tests a REQUEST with a VTIMEZONE-defined zone
+func TestParseOutlookInvite(t *testing.T) {
+	var cal, err = parse([]byte(outlookInvite), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to parse invite: %v", err)
+	}
+	if cal.Method != MethodRequest || len(cal.Events) != 1 {
+		t.Fatalf("Expected one REQUEST event, got %s with %d", cal.Method, len(cal.Events))
+	}
+
+	var e = cal.Main()
+	if e.Kind != KindEvent || e.UID != "040000008200E00074C5B7101A82E008" || e.Sequence != 2 {
+		t.Errorf("Unexpected identity: %+v", e)
+	}
+	if e.Summary != "Weekly sync" || e.Location != "Room 4" {
+		t.Errorf("Unexpected summary/location: %q, %q", e.Summary, e.Location)
+	}
+	if e.Description != "Agenda:\n1. Budget, Q4\n2. Hiring" {
+		t.Errorf("Unexpected description: %q", e.Description)
+	}
+
+	// 27 Oct 2026 is after the last Sunday of October: standard time (+01:00)
+	var start = time.Date(2026, 10, 27, 9, 0, 0, 0, time.UTC)
+	if !e.Start.Equal(start) || !e.End.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected %v-%v, got %v-%v", start, start.Add(time.Hour), e.Start, e.End)
+	}
+	if e.TimeZone != "W. Europe Standard Time" || e.AllDay {
+		t.Errorf("Unexpected zone: %q (all day %v)", e.TimeZone, e.AllDay)
+	}
+
+	if e.Organizer.Email != "jane@example.com" || e.Organizer.Name != "Doe, Jane" {
+		t.Errorf("Unexpected organizer: %+v", e.Organizer)
+	}
+	var me = e.Attendee("ME@example.org")
+	if me == nil || me.PartStat != NeedsAction || !me.RSVP || me.Role != "REQ-PARTICIPANT" {
+		t.Errorf("Unexpected attendee: %+v", me)
+	}
+	if bob := e.Attendee("bob@example.com"); bob == nil || bob.PartStat != Accepted || bob.RSVP {
+		t.Errorf("Unexpected attendee: %+v", bob)
+	}
+
+	var rule, errRule = e.Recurrence()
+	if errRule != nil || rule.Count != 4 {
+		t.Fatalf("Unexpected recurrence: %+v, %v", rule, errRule)
+	}
+	if next := rule.Next(e.Start, e.Start); !next.Equal(start.AddDate(0, 0, 7)) {
+		t.Errorf("Expected next occurrence a week later, got %v", next)
+	}
+}
+
+// TestTimeZones tests the ways a time can be written
+func TestTimeZones(t *testing.T) {
+	var tz = newTimeZones(mustDecode(t, outlookInvite), time.UTC)
+	var cases = []struct {
+		line   string
+		want   time.Time
+		allDay bool
+	}{
+		// Daylight saving time in the VTIMEZONE (+02:00)
+		{"DTSTART;TZID=W. Europe Standard Time:20260701T100000", time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC), false},
+		{"DTSTART;TZID=Europe/Berlin:20260701T100000", time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC), false},
+		{"DTSTART;TZID=/mozilla.org/20050126_1/America/New_York:20260105T090000", time.Date(2026, 1, 5, 14, 0, 0, 0, time.UTC), false},
+		{"DTSTART:20260105T090000Z", time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC), false},
+		{"DTSTART:20260105T090000", time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC), false},
+		{"DTSTART;VALUE=DATE:20260105", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), true},
+	}
+	for _, c := range cases {
+		var p, _ = parseLine(c.line)
+		var got, allDay, err = tz.parseTime(&p)
+		if err != nil || !got.Equal(c.want) || allDay != c.allDay {
+			t.Errorf("%s: expected %v (all day %v), got %v (%v), %v", c.line, c.want, c.allDay, got, allDay, err)
+		}
+	}
+}
+
+// TestParseTodoAndDuration tests a VTODO and a VEVENT ending by DURATION
+func TestParseTodoAndDuration(t *testing.T) {
+	var data = strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"METHOD:CANCEL",
+		"BEGIN:VEVENT",
+		"UID:a@example.com",
+		"RECURRENCE-ID;TZID=Europe/Berlin:20261103T100000",
+		"DTSTART:20261103T090000Z",
+		"DURATION:PT1H30M",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:b@example.com",
+		"SUMMARY:Send report",
+		"DUE;VALUE=DATE:20261110",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\n")
+
+	var cal, err = parse([]byte(data), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if len(cal.Events) != 2 {
+		t.Fatalf("Expected 2 components, got %d", len(cal.Events))
+	}
+
+	var e = cal.Find("a@example.com", "20261103T090000Z")
+	if e == nil || !e.Cancelled() {
+		t.Fatalf("Expected the cancelled exception, got %+v", e)
+	}
+	if !e.End.Equal(e.Start.Add(90 * time.Minute)) {
+		t.Errorf("Expected a 90 minute event, got %v-%v", e.Start, e.End)
+	}
+
+	// The todo is the main component: the event is an exception
+	var todo = cal.Main()
+	if todo.Kind != KindTodo || todo.Summary != "Send report" || !todo.Due.Equal(time.Date(2026, 11, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected todo: %+v", todo)
+	}
+}
+
+// TestReply tests the REPLY sent back to the organizer
+func TestReply(t *testing.T) {
+	var cal, _ = parse([]byte(outlookInvite), time.UTC)
+	var now = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	var data = cal.Reply(cal.Main(), Attendee{Email: "me@example.org", Name: "Me", PartStat: Accepted}, now)
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line longer than 75 octets: %q", line)
+		}
+	}
+
+	var reply, err = parse(data, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to parse reply: %v", err)
+	}
+	if reply.Method != MethodReply || len(reply.Events) != 1 {
+		t.Fatalf("Expected one REPLY event, got %s with %d", reply.Method, len(reply.Events))
+	}
+
+	var e = reply.Main()
+	var original = cal.Main()
+	if e.UID != original.UID || e.Sequence != 2 || !e.Start.Equal(original.Start) {
+		t.Errorf("Reply doesn't match the invite: %+v", e)
+	}
+	if e.Organizer.Email != "jane@example.com" || e.Organizer.Name != "Doe, Jane" {
+		t.Errorf("Unexpected organizer: %+v", e.Organizer)
+	}
+	if len(e.Attendees) != 1 || e.Attendees[0].Email != "me@example.org" || e.Attendees[0].PartStat != Accepted {
+		t.Errorf("Expected only the replying attendee, got %+v", e.Attendees)
+	}
+	if e.RRule != "" || e.Description != "" {
+		t.Errorf("Expected no recurrence or description in the reply, got %+v", e)
+	}
+}
+
+// TestUpdate tests writing edited events back into a calendar
+func TestUpdate(t *testing.T) {
+	var cal, _ = parse([]byte(outlookInvite), time.UTC)
+	var now = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	// Moved an hour later, in the zone defined by the VTIMEZONE
+	var e = cal.Main()
+	e.Summary = "Weekly sync; moved"
+	e.Location = ""
+	e.Start = e.Start.Add(time.Hour)
+	e.End = e.End.Add(time.Hour)
+	cal.Update(e, now)
+
+	var updated, err = parse(cal.Encode(), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to parse updated calendar: %v", err)
+	}
+	var u = updated.Main()
+	if u.Summary != "Weekly sync; moved" || u.Location != "" {
+		t.Errorf("Unexpected fields: %+v", u)
+	}
+	if u.TimeZone != "W. Europe Standard Time" || !u.Start.Equal(time.Date(2026, 10, 27, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 11:00 +0100 in the original zone, got %v (%s)", u.Start, u.TimeZone)
+	}
+	if len(u.Attendees) != 2 || u.RRule == "" || u.Sequence != 2 {
+		t.Errorf("Expected attendees, recurrence and sequence kept, got %+v", u)
+	}
+
+	// A new all-day event in an empty calendar
+	cal = New()
+	cal.Update(&Event{
+		UID:     "new@miau",
+		Summary: "Holiday",
+		Start:   time.Date(2026, 12, 25, 0, 0, 0, 0, time.UTC),
+		End:     time.Date(2026, 12, 26, 0, 0, 0, 0, time.UTC),
+		AllDay:  true,
+	}, now)
+	updated, err = parse(cal.Encode(), time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to parse new calendar: %v", err)
+	}
+	if len(updated.Events) != 1 || updated.ProdID != ProdID {
+		t.Fatalf("Expected one event written by miau, got %+v", updated)
+	}
+	u = updated.Main()
+	if u.UID != "new@miau" || !u.AllDay || u.Start.Day() != 25 || u.End.Day() != 26 {
+		t.Errorf("Unexpected new event: %+v", u)
+	}
+}
+
+// TestDecodeErrors tests malformed calendars
+func TestDecodeErrors(t *testing.T) {
+	var cases = []string{
+		"",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:x\n",
+		"BEGIN:VCALENDAR\nX-BROKEN\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:No UID\nDTSTART:20260101T100000Z\nEND:VEVENT\nEND:VCALENDAR\n",
+	}
+	for _, data := range cases {
+		if _, err := parse([]byte(data), time.UTC); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+}
+
+func mustDecode(t *testing.T, data string) *Component {
+	var c, err = Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	return c
+}
@@ -0,0 +1,235 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opik/miau/internal/rrule"
+)
+
+// timeZones resolves the TZIDs of a calendar: IANA names are loaded from
+// the system database; anything else (Outlook uses Windows names such as
+// "W. Europe Standard Time") is computed from the VTIMEZONE with that TZID.
+// Floating times and dates are read in local.
+type timeZones struct {
+	defs  map[string]*vtimezone
+	local *time.Location
+}
+
+// newTimeZones collects the VTIMEZONEs of a calendar
+func newTimeZones(root *Component, local *time.Location) *timeZones {
+	var tz = &timeZones{defs: map[string]*vtimezone{}, local: local}
+	for _, c := range root.Children {
+		if c.Name != "VTIMEZONE" {
+			continue
+		}
+		if def := parseVTimezone(c); def != nil {
+			tz.defs[def.id] = def
+		}
+	}
+	return tz
+}
+
+// parseTime reads a DATE or DATE-TIME property. allDay is set for DATE
+// values, which are returned as local midnight.
+func (tz *timeZones) parseTime(p *Property) (t time.Time, allDay bool, err error) {
+	var value = strings.TrimSpace(p.Value)
+	if p.Param("VALUE") == "DATE" || len(value) == 8 {
+		var d, err = time.ParseInLocation("20060102", value, tz.local)
+		return d, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	var wall time.Time
+	wall, err = time.Parse("20060102T150405", value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var tzid = p.Param("TZID")
+	if tzid == "" {
+		return inLocation(wall, tz.local), false, nil
+	}
+	return tz.resolve(tzid, wall), false, nil
+}
+
+// resolve returns the wall clock time (given in UTC) in the zone tzid
+func (tz *timeZones) resolve(tzid string, wall time.Time) time.Time {
+	if loc, err := loadLocation(tzid); err == nil {
+		return inLocation(wall, loc)
+	}
+	if def := tz.defs[tzid]; def != nil {
+		var offset, name = def.offset(wall)
+		return inLocation(wall, time.FixedZone(name, offset))
+	}
+	return inLocation(wall, tz.local)
+}
+
+// wallClock returns t as the wall clock time of the zone tzid; ok is false
+// when the zone is unknown
+func (tz *timeZones) wallClock(tzid string, t time.Time) (wall time.Time, ok bool) {
+	if loc, err := loadLocation(tzid); err == nil {
+		return t.In(loc), true
+	}
+	var def = tz.defs[tzid]
+	if def == nil {
+		return time.Time{}, false
+	}
+	// The offset depends on the wall clock time: guess it from UTC, then
+	// correct it once with the guess
+	var offset, _ = def.offset(t.UTC())
+	offset, _ = def.offset(t.UTC().Add(time.Duration(offset) * time.Second))
+	return t.UTC().Add(time.Duration(offset) * time.Second), true
+}
+
+// loadLocation loads an IANA zone, also in the "/Europe/Berlin" and
+// "/mozilla.org/.../Europe/Berlin" forms some producers write
+func loadLocation(tzid string) (*time.Location, error) {
+	var name = strings.Trim(tzid, "/ ")
+	if loc, err := time.LoadLocation(name); err == nil && name != "" && name != "Local" {
+		return loc, nil
+	}
+	if parts := strings.Split(name, "/"); len(parts) > 2 {
+		return time.LoadLocation(strings.Join(parts[len(parts)-2:], "/"))
+	}
+	return nil, fmt.Errorf("unknown time zone %q", tzid)
+}
+
+// inLocation returns the wall clock time (given in UTC) in loc
+func inLocation(wall time.Time, loc *time.Location) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+}
+
+// vtimezone is a VTIMEZONE: a set of STANDARD and DAYLIGHT observances,
+// each starting at its DTSTART and repeating by RRULE or RDATE
+type vtimezone struct {
+	id          string
+	observances []observance
+}
+
+type observance struct {
+	name       string
+	start      time.Time // wall clock, in UTC
+	rule       *rrule.Rule
+	rdates     []time.Time
+	offsetFrom int
+	offsetTo   int
+}
+
+// parseVTimezone reads a VTIMEZONE (nil if it has no usable observance)
+func parseVTimezone(c *Component) *vtimezone {
+	var def = &vtimezone{id: c.Text("TZID")}
+	for _, child := range c.Children {
+		if child.Name != "STANDARD" && child.Name != "DAYLIGHT" {
+			continue
+		}
+		var start, err = time.Parse("20060102T150405", child.Text("DTSTART"))
+		if err != nil {
+			continue
+		}
+		var from, errFrom = parseOffset(child.Text("TZOFFSETFROM"))
+		var to, errTo = parseOffset(child.Text("TZOFFSETTO"))
+		if errFrom != nil || errTo != nil {
+			continue
+		}
+
+		var o = observance{name: child.Text("TZNAME"), start: start, offsetFrom: from, offsetTo: to}
+		if p := child.Get("RRULE"); p != nil {
+			o.rule, _ = rrule.Parse(p.Value)
+		}
+		for _, p := range child.GetAll("RDATE") {
+			for _, v := range strings.Split(p.Value, ",") {
+				if d, err := time.Parse("20060102T150405", v); err == nil {
+					o.rdates = append(o.rdates, d)
+				}
+			}
+		}
+		def.observances = append(def.observances, o)
+	}
+	if def.id == "" || len(def.observances) == 0 {
+		return nil
+	}
+	return def
+}
+
+// offset returns the UTC offset (seconds) and zone name in effect at the
+// wall clock time: that of the observance with the latest onset up to it
+func (z *vtimezone) offset(wall time.Time) (int, string) {
+	var best *observance
+	var bestOnset time.Time
+	for i := range z.observances {
+		var o = &z.observances[i]
+		var onset, ok = o.lastOnset(wall)
+		if ok && (best == nil || onset.After(bestOnset)) {
+			best, bestOnset = o, onset
+		}
+	}
+	if best != nil {
+		return best.offsetTo, best.name
+	}
+
+	// Before every observance: the offset in effect before the first one
+	var first = &z.observances[0]
+	for i := range z.observances {
+		if z.observances[i].start.Before(first.start) {
+			first = &z.observances[i]
+		}
+	}
+	return first.offsetFrom, first.name
+}
+
+// lastOnset returns the latest onset of the observance up to wall
+func (o *observance) lastOnset(wall time.Time) (time.Time, bool) {
+	if o.start.After(wall) {
+		return time.Time{}, false
+	}
+
+	var onset = o.start
+	if o.rule != nil {
+		// Occurrences of the year before wall are enough to find the last one
+		var t = o.rule.Next(o.start, wall.AddDate(-1, 0, -1))
+		if t.IsZero() {
+			// The rule ended before that: walk it from the start
+			t = o.rule.Next(o.start, o.start)
+		}
+		for i := 0; !t.IsZero() && !t.After(wall) && i < 1000; i++ {
+			onset = t
+			t = o.rule.Next(o.start, t)
+		}
+	}
+	for _, d := range o.rdates {
+		if !d.After(wall) && d.After(onset) {
+			onset = d
+		}
+	}
+	return onset, true
+}
+
+// parseOffset parses a UTC offset such as "+0100" or "-053000"
+func parseOffset(s string) (int, error) {
+	if len(s) != 5 && len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("invalid UTC offset %q", s)
+	}
+	var hours, errH = strconv.Atoi(s[1:3])
+	var minutes, errM = strconv.Atoi(s[3:5])
+	if errH != nil || errM != nil {
+		return 0, fmt.Errorf("invalid UTC offset %q", s)
+	}
+	var seconds = hours*3600 + minutes*60
+	if len(s) == 7 {
+		var secs, err = strconv.Atoi(s[5:7])
+		if err != nil {
+			return 0, fmt.Errorf("invalid UTC offset %q", s)
+		}
+		seconds += secs
+	}
+	if s[0] == '-' {
+		seconds = -seconds
+	}
+	return seconds, nil
+}
@@ -1,8 +1,11 @@
 package imap
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -41,8 +44,32 @@ func (c *xoauth2Client) Next(challenge []byte) ([]byte, error) {
 type Client struct {
 	client  *imapclient.Client
 	account *config.Account
+	updates chan MailboxUpdate // mudanças não solicitadas (EXISTS/EXPUNGE/FETCH)
+
+	// Mailbox selecionada por SelectMailbox (usada pelo VANISHED do QRESYNC)
+	selected     *imap.SelectData
+	selectedName string
+	// qresyncOff desliga o QRESYNC após falha ao abrir a conexão dele
+	qresyncOff bool
+	// vanishedSynced indica se o último FetchFlagChanges incluiu os expunges
+	vanishedSynced bool
 }
 
+// MailboxUpdate descreve uma mudança reportada pelo servidor na mailbox selecionada
+type MailboxUpdate struct {
+	NumMessages  uint32 // novo total de mensagens (EXISTS), 0 se não informado
+	Expunged     int    // quantidade de EXPUNGE recebidos
+	FlagsChanged bool   // FETCH não solicitado (flags alteradas em outro cliente)
+	ViaIdle      bool   // true se detectado via IDLE, false se via polling NOOP
+}
+
+// idleRefresh é o tempo máximo de um IDLE antes de reemitir o comando
+// (RFC 2177 recomenda reemitir antes de 29 minutos)
+const idleRefresh = 25 * time.Minute
+
+// noopInterval é o intervalo de polling quando o servidor não suporta IDLE
+const noopInterval = 30 * time.Second
+
 type Mailbox struct {
 	Name     string
 	Messages uint32
@@ -63,6 +90,8 @@ type Email struct {
 	BodyText   string
 	InReplyTo  string
 	References string
+	// Cabeçalhos de autenticação (populado pelo batch fetch, análise de risco)
+	RawHeaders string
 	// Attachment metadata (populated by batch fetch)
 	HasAttachments bool
 	Attachments    []AttachmentInfo
@@ -72,7 +101,28 @@ type Email struct {
 func Connect(account *config.Account) (*Client, error) {
 	var addr = fmt.Sprintf("%s:%d", account.IMAP.Host, account.IMAP.Port)
 
-	var options = &imapclient.Options{}
+	var c = &Client{
+		account: account,
+		updates: make(chan MailboxUpdate, 64),
+	}
+
+	// Handler de dados não solicitados: alimenta o canal usado pelo IDLE
+	var options = &imapclient.Options{
+		UnilateralDataHandler: &imapclient.UnilateralDataHandler{
+			Expunge: func(seqNum uint32) {
+				c.notify(MailboxUpdate{Expunged: 1})
+			},
+			Mailbox: func(data *imapclient.UnilateralDataMailbox) {
+				if data.NumMessages != nil {
+					c.notify(MailboxUpdate{NumMessages: *data.NumMessages})
+				}
+			},
+			Fetch: func(msg *imapclient.FetchMessageData) {
+				msg.Collect()
+				c.notify(MailboxUpdate{FlagsChanged: true})
+			},
+		},
+	}
 
 	var client *imapclient.Client
 	var err error
@@ -99,10 +149,109 @@ func Connect(account *config.Account) (*Client, error) {
 		return nil, fmt.Errorf("erro na autenticação: %w", err)
 	}
 
-	return &Client{
-		client:  client,
-		account: account,
-	}, nil
+	c.client = client
+	return c, nil
+}
+
+// notify enfileira uma mudança sem bloquear o leitor da conexão
+func (c *Client) notify(update MailboxUpdate) {
+	if c.updates == nil {
+		return
+	}
+	select {
+	case c.updates <- update:
+	default:
+		// Canal cheio: já existe mudança pendente, o próximo sync pega tudo
+	}
+}
+
+// SupportsIdle retorna true se o servidor anuncia a capability IDLE
+func (c *Client) SupportsIdle() bool {
+	return c.client.Caps().Has(imap.CapIdle)
+}
+
+// Idle seleciona a mailbox e bloqueia até o servidor reportar uma mudança
+// (EXISTS, EXPUNGE ou FETCH). Usa IDLE quando suportado e cai para polling
+// com NOOP caso contrário. Retorna ctx.Err() se o contexto for cancelado.
+// A conexão deve ser dedicada: enquanto em IDLE nenhum outro comando pode rodar.
+func (c *Client) Idle(ctx context.Context, mailbox string) (*MailboxUpdate, error) {
+	if _, err := c.client.Select(mailbox, nil).Wait(); err != nil {
+		return nil, fmt.Errorf("erro ao selecionar %s: %w", mailbox, err)
+	}
+
+	if !c.SupportsIdle() {
+		return c.pollNoop(ctx)
+	}
+
+	for {
+		var idleCmd, err = c.client.Idle()
+		if err != nil {
+			return nil, fmt.Errorf("erro ao iniciar IDLE: %w", err)
+		}
+
+		var update *MailboxUpdate
+		var timer = time.NewTimer(idleRefresh)
+		select {
+		case u := <-c.updates:
+			update = &u
+		case <-timer.C:
+			// Reemite IDLE para não ser derrubado pelo servidor
+		case <-ctx.Done():
+		}
+		timer.Stop()
+
+		if err := idleCmd.Close(); err != nil {
+			return nil, fmt.Errorf("erro ao encerrar IDLE: %w", err)
+		}
+		if err := idleCmd.Wait(); err != nil {
+			return nil, fmt.Errorf("erro no IDLE: %w", err)
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if update != nil {
+			update.ViaIdle = true
+			c.drainUpdates(update)
+			return update, nil
+		}
+	}
+}
+
+// pollNoop faz polling com NOOP até o servidor reportar alguma mudança
+func (c *Client) pollNoop(ctx context.Context) (*MailboxUpdate, error) {
+	var ticker = time.NewTicker(noopInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case u := <-c.updates:
+			c.drainUpdates(&u)
+			return &u, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+			if err := c.client.Noop().Wait(); err != nil {
+				return nil, fmt.Errorf("erro no NOOP: %w", err)
+			}
+		}
+	}
+}
+
+// drainUpdates agrega mudanças já enfileiradas em uma só (evita syncs repetidos)
+func (c *Client) drainUpdates(update *MailboxUpdate) {
+	for {
+		select {
+		case u := <-c.updates:
+			if u.NumMessages > 0 {
+				update.NumMessages = u.NumMessages
+			}
+			update.Expunged += u.Expunged
+			update.FlagsChanged = update.FlagsChanged || u.FlagsChanged
+		default:
+			return
+		}
+	}
 }
 
 func authenticatePassword(client *imapclient.Client, account *config.Account) error {
@@ -173,7 +322,133 @@ func (c *Client) ListMailboxes() ([]Mailbox, error) {
 
 // SelectMailbox seleciona uma mailbox e retorna info
 func (c *Client) SelectMailbox(name string) (*imap.SelectData, error) {
-	return c.client.Select(name, nil).Wait()
+	// Com CONDSTORE o SELECT retorna HIGHESTMODSEQ (usado no delta sync de flags)
+	var options *imap.SelectOptions
+	if c.SupportsCondStore() {
+		options = &imap.SelectOptions{CondStore: true}
+	}
+	var data, err = c.client.Select(name, options).Wait()
+	if err != nil {
+		return nil, err
+	}
+	c.selected = data
+	c.selectedName = name
+	return data, nil
+}
+
+// FlagUpdate é o estado atual das flags de um email no servidor
+type FlagUpdate struct {
+	UID      uint32
+	Seen     bool
+	Flagged  bool
+	Answered bool
+	Deleted  bool
+	ModSeq   uint64 // 0 se o servidor não suporta CONDSTORE
+}
+
+// SupportsCondStore retorna true se o servidor anuncia CONDSTORE (RFC 7162)
+func (c *Client) SupportsCondStore() bool {
+	var caps = c.client.Caps()
+	return caps.Has(imap.CapCondStore) || caps.Has(imap.CapQResync)
+}
+
+// SupportsQResync retorna true se o servidor anuncia QRESYNC (RFC 7162) e a
+// conexão própria do VANISHED não falhou
+func (c *Client) SupportsQResync() bool {
+	return !c.qresyncOff && c.client.Caps().Has(imap.CapQResync)
+}
+
+// FlagChangesIncludeExpunges indica se o último FetchFlagChanges também
+// trouxe os emails expurgados (como Deleted)
+func (c *Client) FlagChangesIncludeExpunges() bool {
+	return c.vanishedSynced
+}
+
+// FetchFlagChanges busca as flags de todos os emails alterados desde modSeq
+// (UID FETCH 1:* (FLAGS) (CHANGEDSINCE modSeq)). Requer CONDSTORE e a mailbox
+// selecionada com SelectMailbox. Com QRESYNC os emails expurgados desde
+// modSeq (VANISHED) voltam com Deleted.
+func (c *Client) FetchFlagChanges(sinceModSeq uint64) ([]FlagUpdate, error) {
+	c.vanishedSynced = false
+
+	var uidSet = imap.UIDSet{}
+	uidSet.AddRange(1, 0) // 1:*
+
+	var fetchOptions = &imap.FetchOptions{
+		UID:          true,
+		Flags:        true,
+		ModSeq:       true,
+		ChangedSince: sinceModSeq,
+	}
+
+	var messages, err = c.client.Fetch(uidSet, fetchOptions).Collect()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar flags alteradas: %w", err)
+	}
+	var updates = toFlagUpdates(messages)
+
+	if c.SupportsQResync() {
+		var vanished, err = c.fetchVanished(sinceModSeq)
+		if err != nil {
+			// Sem VANISHED o sync acha os expunges comparando os UIDs
+			log.Printf("[imap] VANISHED de %s falhou: %v", c.selectedName, err)
+			c.qresyncOff = errors.Is(err, errQResyncSetup)
+			return updates, nil
+		}
+		for _, uid := range vanished {
+			updates = append(updates, FlagUpdate{UID: uid, Deleted: true})
+		}
+		c.vanishedSynced = true
+	}
+	return updates, nil
+}
+
+// FetchFlags busca as flags atuais dos UIDs informados (refresh sem CONDSTORE)
+func (c *Client) FetchFlags(uids []uint32) ([]FlagUpdate, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	var uidSet = imap.UIDSet{}
+	for _, uid := range uids {
+		uidSet.AddNum(imap.UID(uid))
+	}
+
+	var fetchOptions = &imap.FetchOptions{
+		UID:   true,
+		Flags: true,
+	}
+
+	var messages, err = c.client.Fetch(uidSet, fetchOptions).Collect()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar flags: %w", err)
+	}
+	return toFlagUpdates(messages), nil
+}
+
+// toFlagUpdates converte o resultado de um FETCH (FLAGS) em FlagUpdate
+func toFlagUpdates(messages []*imapclient.FetchMessageBuffer) []FlagUpdate {
+	var updates = make([]FlagUpdate, 0, len(messages))
+	for _, msg := range messages {
+		var update = FlagUpdate{
+			UID:    uint32(msg.UID),
+			ModSeq: msg.ModSeq,
+		}
+		for _, flag := range msg.Flags {
+			switch flag {
+			case imap.FlagSeen:
+				update.Seen = true
+			case imap.FlagFlagged:
+				update.Flagged = true
+			case imap.FlagAnswered:
+				update.Answered = true
+			case imap.FlagDeleted:
+				update.Deleted = true
+			}
+		}
+		updates = append(updates, update)
+	}
+	return updates
 }
 
 // FetchEmailsSeqNum busca emails por sequence number (mais confiável)
@@ -292,12 +567,13 @@ func (c *Client) FetchEmailsSince(sinceDays int) ([]Email, error) {
 		seqSet.AddNum(seq)
 	}
 
+	var headerSection = authHeaderSection()
 	var fetchOptions = &imap.FetchOptions{
 		Flags:       true,
 		Envelope:    true,
 		UID:         true,
 		RFC822Size:  true,
-		BodySection: []*imap.FetchItemBodySection{},
+		BodySection: []*imap.FetchItemBodySection{headerSection},
 	}
 
 	var fetchCmd = c.client.Fetch(seqSet, fetchOptions)
@@ -308,8 +584,9 @@ func (c *Client) FetchEmailsSince(sinceDays int) ([]Email, error) {
 
 	for _, msg := range messages {
 		var email = Email{
-			UID:  uint32(msg.UID),
-			Size: msg.RFC822Size,
+			UID:        uint32(msg.UID),
+			Size:       msg.RFC822Size,
+			RawHeaders: authHeaders(msg, headerSection),
 		}
 
 		if msg.Envelope != nil {
@@ -385,7 +662,7 @@ func (c *Client) FetchNewEmails(sinceUID uint32, limit int) ([]Email, error) {
 		return nil, err
 	}
 
-	var uids = searchData.AllUIDs()
+	var uids = uidsAfter(searchData.AllUIDs(), sinceUID)
 	if len(uids) == 0 {
 		return emails, nil
 	}
@@ -400,11 +677,13 @@ func (c *Client) FetchNewEmails(sinceUID uint32, limit int) ([]Email, error) {
 		uidSet.AddNum(uid)
 	}
 
+	var headerSection = authHeaderSection()
 	var fetchOptions = &imap.FetchOptions{
-		Flags:      true,
-		Envelope:   true,
-		UID:        true,
-		RFC822Size: true,
+		Flags:       true,
+		Envelope:    true,
+		UID:         true,
+		RFC822Size:  true,
+		BodySection: []*imap.FetchItemBodySection{headerSection},
 	}
 
 	var fetchCmd = c.client.Fetch(uidSet, fetchOptions)
@@ -415,8 +694,9 @@ func (c *Client) FetchNewEmails(sinceUID uint32, limit int) ([]Email, error) {
 
 	for _, msg := range messages {
 		var email = Email{
-			UID:  uint32(msg.UID),
-			Size: msg.RFC822Size,
+			UID:        uint32(msg.UID),
+			Size:       msg.RFC822Size,
+			RawHeaders: authHeaders(msg, headerSection),
 		}
 
 		if msg.Envelope != nil {
@@ -450,6 +730,18 @@ func (c *Client) FetchNewEmails(sinceUID uint32, limit int) ([]Email, error) {
 	return emails, nil
 }
 
+// uidsAfter descarta UIDs <= sinceUID: a faixa n:* sempre inclui a última
+// mensagem da mailbox, mesmo com UID menor que n (RFC 3501)
+func uidsAfter(uids []imap.UID, sinceUID uint32) []imap.UID {
+	var after = uids[:0]
+	for _, uid := range uids {
+		if uint32(uid) > sinceUID {
+			after = append(after, uid)
+		}
+	}
+	return after
+}
+
 // SearchText searches for emails containing text in headers or body (server-side search)
 // This is more powerful than local search because the server indexes full body content
 // Returns ALL matching UIDs (no limit) - caller should handle limiting after sorting by date
@@ -496,6 +788,32 @@ func (c *Client) SearchSince(sinceDate time.Time) ([]uint32, error) {
 	return result, nil
 }
 
+// authHeaderFields são os cabeçalhos buscados para a análise de risco
+// (SPF/DKIM/DMARC e remetente)
+var authHeaderFields = []string{
+	"Authentication-Results",
+	"Received-SPF",
+	"DKIM-Signature",
+	"From",
+	"Reply-To",
+	"Return-Path",
+}
+
+// authHeaderSection retorna a seção com os cabeçalhos de autenticação (sem
+// marcar o email como lido)
+func authHeaderSection() *imap.FetchItemBodySection {
+	return &imap.FetchItemBodySection{
+		Specifier:    imap.PartSpecifierHeader,
+		HeaderFields: authHeaderFields,
+		Peek:         true,
+	}
+}
+
+// authHeaders extrai os cabeçalhos de autenticação buscados com section
+func authHeaders(msg *imapclient.FetchMessageBuffer, section *imap.FetchItemBodySection) string {
+	return strings.TrimRight(string(msg.FindBodySection(section)), "\r\n")
+}
+
 // FetchEmailsBatch fetches multiple emails in a single IMAP request
 // Includes envelope, flags, size, AND bodystructure for attachments
 // This is the optimized method - 1 request for N emails instead of N+1
@@ -510,12 +828,14 @@ func (c *Client) FetchEmailsBatch(uids []uint32) ([]Email, error) {
 	}
 
 	// Single request with everything we need
+	var headerSection = authHeaderSection()
 	var fetchOptions = &imap.FetchOptions{
 		UID:           true,
 		Flags:         true,
 		Envelope:      true,
 		RFC822Size:    true,
 		BodyStructure: &imap.FetchItemBodyStructure{Extended: true},
+		BodySection:   []*imap.FetchItemBodySection{headerSection},
 	}
 
 	var fetchCmd = c.client.Fetch(uidSet, fetchOptions)
@@ -576,6 +896,8 @@ func (c *Client) FetchEmailsBatch(uids []uint32) ([]Email, error) {
 			email.Attachments = attachments
 		}
 
+		email.RawHeaders = authHeaders(msg, headerSection)
+
 		emails = append(emails, email)
 	}
 
@@ -596,7 +918,7 @@ func (c *Client) FetchNewEmailsBatch(sinceUID uint32, limit int) ([]Email, error
 		return nil, err
 	}
 
-	var uids = searchData.AllUIDs()
+	var uids = uidsAfter(searchData.AllUIDs(), sinceUID)
 	if len(uids) == 0 {
 		return nil, nil
 	}
@@ -715,6 +1037,26 @@ func (c *Client) MarkAsRead(uid uint32) error {
 	return err
 }
 
+// SetKeyword adiciona (add=true) ou remove uma keyword IMAP de um email
+// (usada como label pelas regras de filtro)
+func (c *Client) SetKeyword(uid uint32, keyword string, add bool) error {
+	var uidSet = imap.UIDSet{}
+	uidSet.AddNum(imap.UID(uid))
+
+	var op = imap.StoreFlagsAdd
+	if !add {
+		op = imap.StoreFlagsDel
+	}
+	var storeFlags = imap.StoreFlags{
+		Op:     op,
+		Silent: true,
+		Flags:  []imap.Flag{imap.Flag(keyword)},
+	}
+
+	var _, err = c.client.Store(uidSet, &storeFlags, nil).Collect()
+	return err
+}
+
 // GetAllUIDs retorna todos os UIDs da mailbox selecionada
 func (c *Client) GetAllUIDs() ([]uint32, error) {
 	var criteria = &imap.SearchCriteria{}
@@ -732,6 +1074,45 @@ func (c *Client) GetAllUIDs() ([]uint32, error) {
 	return result, nil
 }
 
+// FetchMessageIDs retorna o Message-ID de todos os emails da mailbox selecionada
+// (UID -> Message-ID). Busca só o ENVELOPE, usado para reconciliar UIDs quando
+// o UIDVALIDITY muda.
+func (c *Client) FetchMessageIDs() (map[uint32]string, error) {
+	var uids, err = c.GetAllUIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make(map[uint32]string, len(uids))
+	if len(uids) == 0 {
+		return result, nil
+	}
+
+	var uidSet = imap.UIDSet{}
+	for _, uid := range uids {
+		uidSet.AddNum(imap.UID(uid))
+	}
+
+	var fetchOptions = &imap.FetchOptions{
+		Envelope: true,
+		UID:      true,
+	}
+
+	var messages, err2 = c.client.Fetch(uidSet, fetchOptions).Collect()
+	if err2 != nil {
+		return nil, fmt.Errorf("erro ao buscar Message-IDs: %w", err2)
+	}
+
+	for _, msg := range messages {
+		var messageID = ""
+		if msg.Envelope != nil {
+			messageID = msg.Envelope.MessageID
+		}
+		result[uint32(msg.UID)] = messageID
+	}
+	return result, nil
+}
+
 // MoveToFolder move um email para outra pasta usando MOVE ou COPY+DELETE
 func (c *Client) MoveToFolder(uid uint32, targetFolder string) error {
 	var uidSet = imap.UIDSet{}
@@ -765,6 +1146,21 @@ func (c *Client) MoveToFolder(uid uint32, targetFolder string) error {
 	return err2
 }
 
+// CopyToInbox copia um email de folder para o INBOX, como não lido. Usa
+// uma conexão própria para não trocar a mailbox selecionada desta.
+func (c *Client) CopyToInbox(folder string, uid uint32) error {
+	var conn, saslClient, err = openRaw(c.account)
+	if err != nil {
+		return fmt.Errorf("erro ao conectar: %w", err)
+	}
+	defer conn.Close()
+
+	if err := copyToInboxSession(conn, saslClient, folder, uid); err != nil {
+		return fmt.Errorf("erro ao copiar email para o INBOX: %w", err)
+	}
+	return nil
+}
+
 // ArchiveEmail arquiva um email (remove do INBOX, mantém em All Mail)
 // Para Gmail: remove da pasta atual (fica automaticamente em All Mail)
 // Para outros: move para Archive ou All Mail
@@ -924,6 +1320,11 @@ func parseBodyStructure(bs imap.BodyStructure, partNum string, attachments *[]At
 			}
 		}
 
+		// Convites (text/calendar) costumam vir sem nome: lista como invite.ics
+		if info.Filename == "" && info.ContentType == "text/calendar" {
+			info.Filename = "invite.ics"
+		}
+
 		// Determine if this is an attachment
 		var isTextPlain = strings.HasPrefix(info.ContentType, "text/plain")
 		var isTextHTML = strings.HasPrefix(info.ContentType, "text/html")
@@ -0,0 +1,121 @@
+package imap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-sasl"
+)
+
+// O go-imap (v2 beta) não habilita QRESYNC nem entende respostas VANISHED.
+// Para pegar expunges pelo delta (RFC 7162) o comando é enviado numa conexão
+// crua (raw.go), que só lê UIDs: nenhuma mensagem é baixada.
+
+// vanishedLimit é o máximo de UIDs expurgados aceitos de uma vez; acima
+// disso o sync volta a comparar todos os UIDs
+const vanishedLimit = 100000
+
+// errQResyncSetup indica que a conexão QRESYNC não pôde ser aberta
+// (conexão, login ou ENABLE): não adianta tentar de novo nesta sessão
+var errQResyncSetup = errors.New("QRESYNC indisponível")
+
+// fetchVanished busca os UIDs expurgados da mailbox selecionada desde modSeq
+// (UID FETCH 1:* (UID) (CHANGEDSINCE modSeq VANISHED))
+func (c *Client) fetchVanished(sinceModSeq uint64) ([]uint32, error) {
+	if c.selected == nil {
+		return nil, fmt.Errorf("nenhuma mailbox selecionada")
+	}
+
+	var conn, saslClient, err = openRaw(c.account)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errQResyncSetup, err)
+	}
+	defer conn.Close()
+
+	return vanishedSession(conn, saslClient, c.selectedName, c.selected.UIDValidity, uint32(c.selected.UIDNext), sinceModSeq)
+}
+
+// vanishedSession conversa com o servidor em conn: autentica, habilita
+// QRESYNC, abre a mailbox só para leitura e coleta os UIDs das respostas
+// VANISHED. UIDs acima de uidNext são ignorados.
+func vanishedSession(conn io.ReadWriter, saslClient sasl.Client, mailbox string, uidValidity, uidNext uint32, sinceModSeq uint64) ([]uint32, error) {
+	var s, err = rawLogin(conn, saslClient)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errQResyncSetup, err)
+	}
+	if err := s.run("ENABLE QRESYNC", nil); err != nil {
+		return nil, fmt.Errorf("%w: %v", errQResyncSetup, err)
+	}
+	defer s.run("LOGOUT", nil)
+
+	var quoted, err2 = quoteMailbox(mailbox)
+	if err2 != nil {
+		return nil, err2
+	}
+	var currentValidity uint32
+	if err := s.run("EXAMINE "+quoted, func(line string) {
+		if v, ok := responseCode(line, "UIDVALIDITY"); ok {
+			currentValidity = uint32(v)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if currentValidity != uidValidity {
+		return nil, fmt.Errorf("UIDVALIDITY de %s mudou (%d -> %d)", mailbox, uidValidity, currentValidity)
+	}
+
+	var vanished []uint32
+	var parseErr error
+	var fetch = fmt.Sprintf("UID FETCH 1:* (UID) (CHANGEDSINCE %d VANISHED)", sinceModSeq)
+	if err := s.run(fetch, func(line string) {
+		var set, ok = strings.CutPrefix(line, "* VANISHED ")
+		if !ok || parseErr != nil {
+			return
+		}
+		set = strings.TrimPrefix(set, "(EARLIER) ")
+		vanished, parseErr = appendUIDSet(vanished, set, uidNext)
+	}); err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return vanished, nil
+}
+
+// appendUIDSet expande um sequence-set de UIDs ("41,43:116") em uids,
+// ignorando os UIDs >= uidNext (que a mailbox ainda não atribuiu)
+func appendUIDSet(uids []uint32, set string, uidNext uint32) ([]uint32, error) {
+	for _, part := range strings.Split(strings.TrimSpace(set), ",") {
+		var first, last, isRange = strings.Cut(part, ":")
+		var start, err = strconv.ParseUint(first, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("VANISHED inválido: %q", set)
+		}
+		var stop = start
+		if isRange {
+			if stop, err = strconv.ParseUint(last, 10, 32); err != nil {
+				return nil, fmt.Errorf("VANISHED inválido: %q", set)
+			}
+		}
+		if start > stop {
+			start, stop = stop, start
+		}
+		if uidNext > 0 && stop >= uint64(uidNext) {
+			stop = uint64(uidNext) - 1
+		}
+		if start > stop {
+			continue
+		}
+		if uint64(len(uids))+stop-start+1 > vanishedLimit {
+			return nil, fmt.Errorf("VANISHED com mais de %d UIDs", vanishedLimit)
+		}
+		for uid := start; uid <= stop; uid++ {
+			uids = append(uids, uint32(uid))
+		}
+	}
+	return uids, nil
+}
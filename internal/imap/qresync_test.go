@@ -0,0 +1,126 @@
+package imap
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/emersion/go-sasl"
+)
+
+func TestVanishedSession(t *testing.T) {
+	var client, server = net.Pipe()
+	var received = fakeServer(t, server, "* OK IMAP4rev1 ready", map[string][]string{
+		"AUTHENTICATE": {"TAG OK authenticated"},
+		"ENABLE":       {"* ENABLED QRESYNC", "TAG OK enabled"},
+		"EXAMINE": {
+			"* 3 EXISTS",
+			"* OK [UIDVALIDITY 7] UIDs valid",
+			"* LIST () \"/\" {5}", // literal no fim de uma resposta
+			"INBOX",
+			"TAG OK [READ-ONLY] done",
+		},
+		"UID": {
+			"* VANISHED (EARLIER) 41,43:45,90",
+			"* 1 FETCH (UID 40 MODSEQ (25))",
+			"TAG OK fetched",
+		},
+		"LOGOUT": {"* BYE", "TAG OK bye"},
+	})
+
+	var uids, err = vanishedSession(client, sasl.NewPlainClient("", "me", "pw"), `My "Box"`, 7, 60, 20)
+	client.Close()
+	if err != nil {
+		t.Fatalf("vanishedSession: %v", err)
+	}
+
+	// 90 é >= UIDNEXT: ainda não existia quando a mailbox foi selecionada
+	if want := []uint32{41, 43, 44, 45}; !reflect.DeepEqual(uids, want) {
+		t.Errorf("uids = %v, want %v", uids, want)
+	}
+	var commands = <-received
+	var want = []string{
+		"AUTHENTICATE PLAIN",
+		"ENABLE QRESYNC",
+		`EXAMINE "My \"Box\""`,
+		"UID FETCH 1:* (UID) (CHANGEDSINCE 20 VANISHED)",
+		"LOGOUT",
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("commands = %q, want %q", commands, want)
+	}
+}
+
+func TestVanishedSessionErrors(t *testing.T) {
+	var cases = []struct {
+		name      string
+		script    map[string][]string
+		wantSetup bool
+	}{
+		{
+			name:      "ENABLE refused",
+			script:    map[string][]string{"ENABLE": {"TAG BAD unknown extension"}},
+			wantSetup: true,
+		},
+		{
+			name: "UIDVALIDITY changed",
+			script: map[string][]string{
+				"ENABLE":  {"TAG OK enabled"},
+				"EXAMINE": {"* OK [UIDVALIDITY 8] UIDs valid", "TAG OK done"},
+				"LOGOUT":  {"TAG OK bye"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var client, server = net.Pipe()
+			fakeServer(t, server, "* PREAUTH ready", tc.script)
+
+			var _, err = vanishedSession(client, nil, "INBOX", 7, 60, 20)
+			client.Close()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := errors.Is(err, errQResyncSetup); got != tc.wantSetup {
+				t.Errorf("setup error = %v, want %v (%v)", got, tc.wantSetup, err)
+			}
+		})
+	}
+}
+
+func TestAppendUIDSet(t *testing.T) {
+	var cases = []struct {
+		set     string
+		uidNext uint32
+		want    []uint32
+		wantErr bool
+	}{
+		{set: "5", want: []uint32{5}},
+		{set: "3:1,7", want: []uint32{1, 2, 3, 7}},
+		{set: "8:12", uidNext: 10, want: []uint32{8, 9}},
+		{set: "10:12", uidNext: 10, want: nil},
+		{set: "1:200000", wantErr: true},
+		{set: "1:x", wantErr: true},
+		{set: "*", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.set, func(t *testing.T) {
+			var got, err = appendUIDSet(nil, tc.set, tc.uidNext)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("appendUIDSet: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package imap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	"github.com/opik/miau/internal/auth"
+	"github.com/opik/miau/internal/config"
+)
+
+// Conexões curtas e próprias, com comandos crus, para o que o go-imap não
+// faz ou que não pode trocar a mailbox selecionada da conexão principal.
+
+// rawTimeout limita uma conexão crua inteira (conectar, autenticar e os
+// comandos)
+const rawTimeout = 2 * time.Minute
+
+// openRaw conecta ao servidor IMAP da conta para uma sessão crua e retorna
+// o cliente SASL do login
+func openRaw(account *config.Account) (net.Conn, sasl.Client, error) {
+	var saslClient, err = rawSASL(account)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conn, err2 = dialRaw(account)
+	if err2 != nil {
+		return nil, nil, err2
+	}
+	conn.SetDeadline(time.Now().Add(rawTimeout))
+	return conn, saslClient, nil
+}
+
+// rawLogin lê a saudação do servidor e autentica (exceto com PREAUTH)
+func rawLogin(conn io.ReadWriter, saslClient sasl.Client) (*rawSession, error) {
+	var s = &rawSession{conn: conn, r: bufio.NewReader(conn)}
+	var greeting, err = s.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* PREAUTH") {
+		if err := s.authenticate(saslClient); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// copyToInboxSession autentica, seleciona a mailbox e copia o email uid
+// para o INBOX sem \Seen (no Gmail as flags valem para todas as labels, então
+// o original também fica não lido)
+func copyToInboxSession(conn io.ReadWriter, saslClient sasl.Client, mailbox string, uid uint32) error {
+	var s, err = rawLogin(conn, saslClient)
+	if err != nil {
+		return err
+	}
+	defer s.run("LOGOUT", nil)
+
+	var quoted, err2 = quoteMailbox(mailbox)
+	if err2 != nil {
+		return err2
+	}
+	for _, command := range []string{
+		"SELECT " + quoted,
+		fmt.Sprintf(`UID STORE %d -FLAGS.SILENT (\Seen)`, uid),
+		fmt.Sprintf("UID COPY %d INBOX", uid),
+	} {
+		if err := s.run(command, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dialRaw abre uma conexão com o servidor IMAP da conta, como o Connect
+func dialRaw(account *config.Account) (net.Conn, error) {
+	var addr = net.JoinHostPort(account.IMAP.Host, strconv.Itoa(account.IMAP.Port))
+	var dialer = &net.Dialer{Timeout: 30 * time.Second}
+	if account.IMAP.TLS {
+		return tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: account.IMAP.Host})
+	}
+	return dialer.Dial("tcp", addr)
+}
+
+// rawSASL retorna o cliente SASL da conta. Com OAuth2 só usa um token
+// válido (renovado se preciso): nunca abre o navegador.
+func rawSASL(account *config.Account) (sasl.Client, error) {
+	if account.AuthType != config.AuthTypeOAuth2 {
+		return sasl.NewPlainClient("", account.Email, account.Password), nil
+	}
+
+	var tokenPath = auth.GetTokenPath(config.GetConfigPath(), account.Email)
+	var oauthCfg = auth.GetOAuth2Config(account.OAuth2.ClientID, account.OAuth2.ClientSecret)
+	var token, err = auth.GetValidToken(oauthCfg, tokenPath)
+	if err != nil {
+		return nil, err
+	}
+	return newXOAuth2Client(account.Email, token.AccessToken), nil
+}
+
+// quoteMailbox escreve o nome da mailbox como quoted string. Nomes fora do
+// ASCII precisariam de UTF-7 modificado e ficam com o purge por UIDs.
+func quoteMailbox(name string) (string, error) {
+	for i := 0; i < len(name); i++ {
+		if name[i] < 0x20 || name[i] > 0x7e {
+			return "", fmt.Errorf("mailbox %q não é ASCII", name)
+		}
+	}
+	var escaped = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name)
+	return `"` + escaped + `"`, nil
+}
+
+// responseCode extrai o número de um código de resposta ("[UIDVALIDITY 42]")
+func responseCode(line, code string) (uint64, bool) {
+	var _, rest, ok = strings.Cut(line, "["+code+" ")
+	if !ok {
+		return 0, false
+	}
+	var value, _, _ = strings.Cut(rest, "]")
+	var n, err = strconv.ParseUint(value, 10, 64)
+	return n, err == nil
+}
+
+// rawSession é um cliente IMAP mínimo: comandos sem literais, respostas
+// lidas linha a linha
+type rawSession struct {
+	conn io.ReadWriter
+	r    *bufio.Reader
+	tag  int
+}
+
+// send envia um comando com uma nova tag e retorna a tag
+func (s *rawSession) send(command string) (string, error) {
+	s.tag++
+	var tag = fmt.Sprintf("m%d", s.tag)
+	var _, err = io.WriteString(s.conn, tag+" "+command+"\r\n")
+	return tag, err
+}
+
+// run envia um comando e passa cada resposta não marcada para untagged até
+// a resposta final. Retorna erro se ela não for OK.
+func (s *rawSession) run(command string, untagged func(line string)) error {
+	var tag, err = s.send(command)
+	if err != nil {
+		return err
+	}
+	for {
+		var line, err = s.readLine()
+		if err != nil {
+			return err
+		}
+		if status, ok := strings.CutPrefix(line, tag+" "); ok {
+			return statusError(command, status)
+		}
+		if untagged != nil && strings.HasPrefix(line, "* ") {
+			untagged(line)
+		}
+	}
+}
+
+// authenticate faz AUTHENTICATE com o mecanismo SASL do cliente
+func (s *rawSession) authenticate(client sasl.Client) error {
+	var mech, ir, err = client.Start()
+	if err != nil {
+		return err
+	}
+	var tag, err2 = s.send("AUTHENTICATE " + mech)
+	if err2 != nil {
+		return err2
+	}
+
+	var saslErr error
+	for {
+		var line, err = s.readLine()
+		if err != nil {
+			return err
+		}
+		if status, ok := strings.CutPrefix(line, tag+" "); ok {
+			if saslErr != nil {
+				return saslErr
+			}
+			return statusError("AUTHENTICATE", status)
+		}
+		if !strings.HasPrefix(line, "+") {
+			continue
+		}
+
+		var response = ir
+		if ir != nil {
+			ir = nil
+		} else {
+			var challenge, _ = base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(line, "+")))
+			if response, saslErr = client.Next(challenge); saslErr != nil {
+				// Cancela; o servidor responde com a tag
+				io.WriteString(s.conn, "*\r\n")
+				continue
+			}
+		}
+		if _, err := io.WriteString(s.conn, base64.StdEncoding.EncodeToString(response)+"\r\n"); err != nil {
+			return err
+		}
+	}
+}
+
+// readLine lê uma resposta. Literais ({n}) são lidos e descartados, com o
+// resto da resposta emendado na mesma linha.
+func (s *rawSession) readLine() (string, error) {
+	var sb strings.Builder
+	for {
+		var line, err = s.r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		var size, ok = literalSize(line)
+		if !ok {
+			sb.WriteString(line)
+			return sb.String(), nil
+		}
+		sb.WriteString(line[:strings.LastIndexByte(line, '{')])
+		if _, err := io.CopyN(io.Discard, s.r, size); err != nil {
+			return "", err
+		}
+	}
+}
+
+// literalSize retorna o tamanho do literal no fim da linha ("{12}" ou "{12+}")
+func literalSize(line string) (int64, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	var open = strings.LastIndexByte(line, '{')
+	if open < 0 {
+		return 0, false
+	}
+	var size, err = strconv.ParseInt(strings.TrimSuffix(line[open+1:len(line)-1], "+"), 10, 64)
+	return size, err == nil && size >= 0
+}
+
+// statusError converte a resposta final de um comando em erro (nil se OK)
+func statusError(command, status string) error {
+	if strings.HasPrefix(status, "OK") {
+		return nil
+	}
+	var verb, _, _ = strings.Cut(command, " ")
+	return fmt.Errorf("%s: %s", verb, status)
+}
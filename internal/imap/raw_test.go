@@ -0,0 +1,83 @@
+package imap
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-sasl"
+)
+
+// fakeServer responde a cada comando com as linhas do script, trocando TAG
+// pela tag do comando. Guarda os comandos recebidos.
+func fakeServer(t *testing.T, conn net.Conn, greeting string, script map[string][]string) chan []string {
+	t.Helper()
+	var received = make(chan []string, 1)
+	go func() {
+		defer conn.Close()
+		var commands []string
+		defer func() { received <- commands }()
+
+		var r = bufio.NewReader(conn)
+		conn.Write([]byte(greeting + "\r\n"))
+		for {
+			var line, err = r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			var tag, command, _ = strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+			commands = append(commands, command)
+			var verb, _, _ = strings.Cut(command, " ")
+			if verb == "AUTHENTICATE" {
+				conn.Write([]byte("+ \r\n"))
+				r.ReadString('\n') // resposta SASL
+			}
+			for _, reply := range script[verb] {
+				conn.Write([]byte(strings.ReplaceAll(reply, "TAG", tag) + "\r\n"))
+			}
+			if verb == "LOGOUT" {
+				return
+			}
+		}
+	}()
+	return received
+}
+
+func TestCopyToInboxSession(t *testing.T) {
+	var client, server = net.Pipe()
+	var received = fakeServer(t, server, "* OK ready", map[string][]string{
+		"AUTHENTICATE": {"TAG OK authenticated"},
+		"SELECT":       {"* 12 EXISTS", "TAG OK [READ-WRITE] done"},
+		"UID":          {"TAG OK done"},
+		"LOGOUT":       {"* BYE", "TAG OK bye"},
+	})
+
+	var err = copyToInboxSession(client, sasl.NewPlainClient("", "me", "pw"), "Sent", 7)
+	client.Close()
+	if err != nil {
+		t.Fatalf("copyToInboxSession: %v", err)
+	}
+
+	var commands = <-received
+	var want = []string{
+		"AUTHENTICATE PLAIN",
+		`SELECT "Sent"`,
+		`UID STORE 7 -FLAGS.SILENT (\Seen)`,
+		"UID COPY 7 INBOX",
+		"LOGOUT",
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("commands = %q, want %q", commands, want)
+	}
+}
+
+func TestQuoteMailbox(t *testing.T) {
+	if got, err := quoteMailbox(`a\b`); err != nil || got != `"a\\b"` {
+		t.Errorf(`quoteMailbox(a\b) = %q, %v`, got, err)
+	}
+	if _, err := quoteMailbox("Enviados/Caixa de saída"); err == nil {
+		t.Error("non-ASCII mailbox should be refused")
+	}
+}
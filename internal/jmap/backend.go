@@ -0,0 +1,1066 @@
+package jmap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// SyncState persists what Backend needs between runs: the UID given to each
+// email in each mailbox, how far each mailbox has been synced and the
+// mod-sequence standing in for each Email state string.
+type SyncState interface {
+	// AssignUIDs returns the UID of each email in the mailbox, giving the
+	// next UIDs (in the given order) to emails that have none yet
+	AssignUIDs(mailboxID string, emailIDs []string) (map[string]uint32, error)
+	// LookupUIDs returns the UIDs already given to emails in the mailbox
+	LookupUIDs(mailboxID string, emailIDs []string) (map[string]uint32, error)
+	// EmailIDs returns the JMAP email ID of each UID in the mailbox
+	EmailIDs(mailboxID string, uids []uint32) (map[uint32]string, error)
+	// EmailIDsAfter returns UID -> JMAP email ID for the UIDs above uid
+	EmailIDsAfter(mailboxID string, uid uint32) (map[uint32]string, error)
+	// MailboxState returns the Email state the mailbox was synced up to ("" = never)
+	MailboxState(mailboxID string) (string, error)
+	SetMailboxState(mailboxID, state string) error
+	// ModSeq returns an increasing number for an Email state (the same
+	// state always gets the same number)
+	ModSeq(state string) (uint64, error)
+	// StateAt returns the Email state of a ModSeq number ("" if unknown)
+	StateAt(modSeq uint64) (string, error)
+}
+
+// uidValidity is the UIDVALIDITY of every mailbox: UIDs are assigned
+// locally and never renumbered
+const uidValidity = 1
+
+// defaultPollInterval is how often Idle checks for changes when the server
+// has no event source
+const defaultPollInterval = 30 * time.Second
+
+// Backend implements ports.IMAPPort on JMAP (RFC 8621): mailboxes are
+// folders (by path, the inbox is INBOX), Email/query lists them and
+// Email/changes drives incremental sync, with Email states mapped to
+// mod-sequences. Sending goes through Client.Submit.
+type Backend struct {
+	mu        sync.RWMutex
+	client    *Client
+	state     SyncState
+	connected bool
+	mailboxes map[string]Mailbox // mailbox ID -> mailbox
+	folders   map[string]string  // folder path -> mailbox ID
+	selected  *string            // mailbox ID of the selected folder
+
+	// PollInterval is how often Idle polls without an event source
+	PollInterval time.Duration
+}
+
+// NewBackend creates a Backend
+func NewBackend(client *Client, state SyncState) *Backend {
+	return &Backend{
+		client:       client,
+		state:        state,
+		PollInterval: defaultPollInterval,
+	}
+}
+
+// Connect fetches the session and the mailboxes
+func (b *Backend) Connect(ctx context.Context) error {
+	if _, err := b.client.Session(ctx); err != nil {
+		return err
+	}
+	if err := b.loadMailboxes(ctx); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.connected = true
+	b.mu.Unlock()
+	return nil
+}
+
+// Close forgets the selected folder (there is no connection to close)
+func (b *Backend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connected = false
+	b.selected = nil
+	return nil
+}
+
+// IsConnected returns true after a successful Connect
+func (b *Backend) IsConnected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.connected
+}
+
+// loadMailboxes refreshes the mailbox and folder maps
+func (b *Backend) loadMailboxes(ctx context.Context) error {
+	var mailboxes, err = b.client.GetMailboxes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list mailboxes: %w", err)
+	}
+
+	var byID = make(map[string]Mailbox, len(mailboxes))
+	var folders = make(map[string]string, len(mailboxes))
+	for id, path := range mailboxPaths(mailboxes) {
+		folders[path] = id
+	}
+	for _, m := range mailboxes {
+		byID[m.ID] = m
+	}
+
+	b.mu.Lock()
+	b.mailboxes = byID
+	b.folders = folders
+	b.mu.Unlock()
+	return nil
+}
+
+// mailboxFor returns the mailbox ID of a folder
+func (b *Backend) mailboxFor(ctx context.Context, folder string) (string, error) {
+	b.mu.RLock()
+	var id, ok = b.folders[folder]
+	b.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	// Maybe a mailbox created since Connect
+	if err := b.loadMailboxes(ctx); err != nil {
+		return "", err
+	}
+	b.mu.RLock()
+	id, ok = b.folders[folder]
+	b.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("folder not found: %s", folder)
+	}
+	return id, nil
+}
+
+// mailboxByRole returns the ID of the mailbox with a role ("" if none)
+func (b *Backend) mailboxByRole(role string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for id, m := range b.mailboxes {
+		if m.Role == role {
+			return id
+		}
+	}
+	return ""
+}
+
+// folderName returns the folder path of a mailbox
+func (b *Backend) folderName(mailboxID string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for path, id := range b.folders {
+		if id == mailboxID {
+			return path
+		}
+	}
+	return ""
+}
+
+// selectedMailbox returns the mailbox ID of the selected folder
+func (b *Backend) selectedMailbox() (string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if !b.connected {
+		return "", fmt.Errorf("not connected")
+	}
+	if b.selected == nil {
+		return "", fmt.Errorf("no folder selected")
+	}
+	return *b.selected, nil
+}
+
+// ListMailboxes lists every mailbox by path, in the server's sort order
+func (b *Backend) ListMailboxes(ctx context.Context) ([]ports.MailboxInfo, error) {
+	if err := b.loadMailboxes(ctx); err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	var result = make([]ports.MailboxInfo, 0, len(b.folders))
+	var order = make(map[string]int, len(b.folders))
+	for path, id := range b.folders {
+		var m = b.mailboxes[id]
+		result = append(result, ports.MailboxInfo{Name: path, Messages: uint32(m.TotalEmails), Unseen: uint32(m.UnreadEmails)})
+		order[path] = m.SortOrder
+	}
+	b.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		var a, c = result[i].Name, result[j].Name
+		if (a == "INBOX") != (c == "INBOX") {
+			return a == "INBOX"
+		}
+		if order[a] != order[c] {
+			return order[a] < order[c]
+		}
+		return a < c
+	})
+	return result, nil
+}
+
+// SelectMailbox selects a folder. HighestModSeq is the mod-sequence of the
+// current Email state.
+func (b *Backend) SelectMailbox(ctx context.Context, name string) (*ports.MailboxStatus, error) {
+	var id, err = b.mailboxFor(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.loadMailboxes(ctx); err != nil {
+		return nil, err
+	}
+
+	var state, err2 = b.client.EmailState(ctx)
+	if err2 != nil {
+		return nil, err2
+	}
+	var modSeq, err3 = b.state.ModSeq(state)
+	if err3 != nil {
+		return nil, err3
+	}
+
+	b.mu.Lock()
+	b.selected = &id
+	var m = b.mailboxes[id]
+	b.mu.Unlock()
+
+	return &ports.MailboxStatus{
+		Name:          name,
+		NumMessages:   uint32(m.TotalEmails),
+		NumUnseen:     uint32(m.UnreadEmails),
+		UIDValidity:   uidValidity,
+		HighestModSeq: modSeq,
+	}, nil
+}
+
+// FetchEmails fetches the newest emails of the selected folder
+func (b *Backend) FetchEmails(ctx context.Context, limit int) ([]ports.IMAPEmail, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, err2 = b.client.QueryEmails(ctx, map[string]interface{}{"inMailbox": mailbox}, limit)
+	if err2 != nil {
+		return nil, err2
+	}
+	slices.Reverse(ids)
+	return b.fetchNew(ctx, mailbox, ids)
+}
+
+// FetchNewEmails fetches emails with UID above sinceUID
+func (b *Backend) FetchNewEmails(ctx context.Context, sinceUID uint32, limit int) ([]ports.IMAPEmail, error) {
+	return b.FetchNewEmailsBatch(ctx, sinceUID, limit)
+}
+
+// FetchEmailRaw downloads the RFC 822 message
+func (b *Backend) FetchEmailRaw(ctx context.Context, uid uint32) ([]byte, error) {
+	var id, err = b.emailID(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails, err2 = b.client.GetEmails(ctx, []string{id}, []string{"blobId"})
+	if err2 != nil {
+		return nil, err2
+	}
+	if len(emails) == 0 {
+		return nil, fmt.Errorf("email %d not found", uid)
+	}
+	return b.client.Download(ctx, emails[0].BlobID, "message/rfc822", "message.eml")
+}
+
+// FetchEmailBody fetches the message body without headers (like IMAP BODY[TEXT])
+func (b *Backend) FetchEmailBody(ctx context.Context, uid uint32) (string, error) {
+	var raw, err = b.FetchEmailRaw(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+
+	for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+		if i := bytes.Index(raw, []byte(sep)); i >= 0 {
+			return string(raw[i+len(sep):]), nil
+		}
+	}
+	return "", nil
+}
+
+// GetAllUIDs returns the UIDs of the synced emails still in the selected folder
+func (b *Backend) GetAllUIDs(ctx context.Context) ([]uint32, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return nil, err
+	}
+
+	var known, err2 = b.state.EmailIDsAfter(mailbox, 0)
+	if err2 != nil {
+		return nil, err2
+	}
+	var server, err3 = b.client.QueryEmails(ctx, map[string]interface{}{"inMailbox": mailbox}, 0)
+	if err3 != nil {
+		return nil, err3
+	}
+
+	var onServer = make(map[string]bool, len(server))
+	for _, id := range server {
+		onServer[id] = true
+	}
+	var uids []uint32
+	for uid, id := range known {
+		if onServer[id] {
+			uids = append(uids, uid)
+		}
+	}
+	slices.Sort(uids)
+	return uids, nil
+}
+
+// FetchMessageIDs returns UID -> Message-ID for every email in the selected folder
+func (b *Backend) FetchMessageIDs(ctx context.Context) (map[uint32]string, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, err2 = b.client.QueryEmails(ctx, map[string]interface{}{"inMailbox": mailbox}, 0)
+	if err2 != nil {
+		return nil, err2
+	}
+	slices.Reverse(ids) // oldest first, so UIDs follow arrival order
+	var uids, err3 = b.state.AssignUIDs(mailbox, ids)
+	if err3 != nil {
+		return nil, err3
+	}
+
+	var result = make(map[uint32]string, len(ids))
+	for start := 0; start < len(ids); start += 500 {
+		var emails, err = b.client.GetEmails(ctx, ids[start:min(start+500, len(ids))], []string{"id", "messageId"})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range emails {
+			if len(e.MessageID) > 0 {
+				result[uids[e.ID]] = e.MessageID[0]
+			}
+		}
+	}
+	return result, nil
+}
+
+// SupportsCondStore is always true: Email states work as mod-sequences
+func (b *Backend) SupportsCondStore() bool {
+	return true
+}
+
+// FetchFlagChanges returns the flags of synced emails changed since the
+// mod-sequence. Emails destroyed or moved out of the folder are reported as
+// deleted (like an expunge). If the server can't calculate the changes,
+// every synced email of the folder is refreshed.
+func (b *Backend) FetchFlagChanges(ctx context.Context, sinceModSeq uint64) ([]ports.FlagUpdate, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return nil, err
+	}
+
+	var since, err2 = b.state.StateAt(sinceModSeq)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	var changed []string
+	var destroyed = make(map[string]bool)
+	var changes *Changes
+	if since != "" {
+		changes, err = b.client.EmailChanges(ctx, since)
+		if err != nil && !IsCannotCalculateChanges(err) {
+			return nil, err
+		}
+	}
+	if changes != nil {
+		changed = append(changes.Created, changes.Updated...)
+		for _, id := range changes.Destroyed {
+			destroyed[id] = true
+		}
+	} else {
+		var known, err3 = b.state.EmailIDsAfter(mailbox, 0)
+		if err3 != nil {
+			return nil, err3
+		}
+		for _, id := range known {
+			changed = append(changed, id)
+		}
+	}
+
+	var uids, err4 = b.state.LookupUIDs(mailbox, append(changed, changes.destroyedIDs()...))
+	if err4 != nil {
+		return nil, err4
+	}
+
+	var updates []ports.FlagUpdate
+	var toFetch []string
+	for id, uid := range uids {
+		if destroyed[id] {
+			updates = append(updates, ports.FlagUpdate{UID: uid, Deleted: true})
+		} else {
+			toFetch = append(toFetch, id)
+		}
+	}
+
+	var found = make(map[string]bool, len(toFetch))
+	for start := 0; start < len(toFetch); start += 500 {
+		var emails, err = b.client.GetEmails(ctx, toFetch[start:min(start+500, len(toFetch))], []string{"id", "mailboxIds", "keywords"})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range emails {
+			found[e.ID] = true
+			updates = append(updates, flagUpdate(mailbox, uids[e.ID], &e))
+		}
+	}
+	for _, id := range toFetch {
+		if !found[id] {
+			updates = append(updates, ports.FlagUpdate{UID: uids[id], Deleted: true})
+		}
+	}
+
+	sort.Slice(updates, func(i, j int) bool { return updates[i].UID < updates[j].UID })
+	return updates, nil
+}
+
+// destroyedIDs returns the destroyed emails (nil-safe)
+func (c *Changes) destroyedIDs() []string {
+	if c == nil {
+		return nil
+	}
+	return c.Destroyed
+}
+
+// FlagChangesIncludeExpunges is always true: Email/changes reports destroyed
+// emails
+func (b *Backend) FlagChangesIncludeExpunges() bool {
+	return true
+}
+
+// FetchFlags returns the current flags of the given UIDs
+func (b *Backend) FetchFlags(ctx context.Context, uids []uint32) ([]ports.FlagUpdate, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, err2 = b.state.EmailIDs(mailbox, uids)
+	if err2 != nil {
+		return nil, err2
+	}
+	var byID = make(map[string]uint32, len(ids))
+	var list = make([]string, 0, len(ids))
+	for uid, id := range ids {
+		byID[id] = uid
+		list = append(list, id)
+	}
+
+	var updates []ports.FlagUpdate
+	for start := 0; start < len(list); start += 500 {
+		var emails, err = b.client.GetEmails(ctx, list[start:min(start+500, len(list))], []string{"id", "mailboxIds", "keywords"})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range emails {
+			var update = flagUpdate(mailbox, byID[e.ID], &e)
+			if !update.Deleted {
+				updates = append(updates, update)
+			}
+		}
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].UID < updates[j].UID })
+	return updates, nil
+}
+
+// flagUpdate maps the keywords of an email to IMAP flags
+func flagUpdate(mailbox string, uid uint32, e *Email) ports.FlagUpdate {
+	return ports.FlagUpdate{
+		UID:      uid,
+		Seen:     e.Keywords["$seen"],
+		Flagged:  e.Keywords["$flagged"],
+		Answered: e.Keywords["$answered"],
+		Deleted:  !e.MailboxIDs[mailbox],
+	}
+}
+
+// SearchText runs a full-text search in the selected folder
+func (b *Backend) SearchText(ctx context.Context, query string, limit int) ([]uint32, error) {
+	return b.search(ctx, map[string]interface{}{"text": query}, limit)
+}
+
+// SearchSince returns the UIDs of emails received since the date
+func (b *Backend) SearchSince(ctx context.Context, sinceDate time.Time) ([]uint32, error) {
+	return b.search(ctx, map[string]interface{}{"after": sinceDate.UTC().Format(time.RFC3339)}, 0)
+}
+
+// search returns the UIDs of the emails of the selected folder matching filter
+func (b *Backend) search(ctx context.Context, filter map[string]interface{}, limit int) ([]uint32, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return nil, err
+	}
+	filter["inMailbox"] = mailbox
+
+	var ids, err2 = b.client.QueryEmails(ctx, filter, limit)
+	if err2 != nil {
+		return nil, err2
+	}
+	slices.Reverse(ids)
+	var uids, err3 = b.state.AssignUIDs(mailbox, ids)
+	if err3 != nil {
+		return nil, err3
+	}
+
+	var result = make([]uint32, 0, len(uids))
+	for _, uid := range uids {
+		result = append(result, uid)
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+// FetchEmailsBatch fetches emails by UID
+func (b *Backend) FetchEmailsBatch(ctx context.Context, uids []uint32) ([]ports.IMAPEmail, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, err2 = b.state.EmailIDs(mailbox, uids)
+	if err2 != nil {
+		return nil, err2
+	}
+	return b.fetchEmails(ctx, ids)
+}
+
+// FetchNewEmailsBatch fetches the emails added to the selected folder since
+// the last sync, found through Email/changes. Without a stored state (or
+// when the server can't calculate the changes) the newest limit emails are
+// queried instead.
+func (b *Backend) FetchNewEmailsBatch(ctx context.Context, sinceUID uint32, limit int) ([]ports.IMAPEmail, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint, err2 = b.state.MailboxState(mailbox)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	var ids []string
+	var latest string
+	if checkpoint != "" {
+		ids, latest, err = b.addedSince(ctx, mailbox, checkpoint)
+		if IsCannotCalculateChanges(err) {
+			checkpoint = ""
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if checkpoint == "" {
+		// State first: anything arriving during the query shows up next time
+		if latest, err = b.client.EmailState(ctx); err != nil {
+			return nil, err
+		}
+		if ids, err = b.client.QueryEmails(ctx, map[string]interface{}{"inMailbox": mailbox}, limit); err != nil {
+			return nil, err
+		}
+		slices.Reverse(ids)
+	}
+
+	// UIDs are stored before fetching: emails assigned but not fetched
+	// yet (errors, limit) are picked up by the next sync
+	if _, err := b.state.AssignUIDs(mailbox, ids); err != nil {
+		return nil, err
+	}
+	var pending, err3 = b.state.EmailIDsAfter(mailbox, sinceUID)
+	if err3 != nil {
+		return nil, err3
+	}
+	if limit > 0 && len(pending) > limit {
+		var uids = make([]uint32, 0, len(pending))
+		for uid := range pending {
+			uids = append(uids, uid)
+		}
+		slices.Sort(uids)
+		for _, uid := range uids[limit:] {
+			delete(pending, uid)
+		}
+	}
+
+	var emails, err4 = b.fetchEmails(ctx, pending)
+	if err4 != nil {
+		return nil, err4
+	}
+	if latest != "" {
+		if err := b.state.SetMailboxState(mailbox, latest); err != nil {
+			return nil, err
+		}
+	}
+	return emails, nil
+}
+
+// FetchEmailsSinceDateBatch fetches up to limit emails from the last
+// sinceDays days and starts incremental sync from now
+func (b *Backend) FetchEmailsSinceDateBatch(ctx context.Context, sinceDays int, limit int) ([]ports.IMAPEmail, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return nil, err
+	}
+
+	var state, err2 = b.client.EmailState(ctx)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	var since = time.Now().AddDate(0, 0, -sinceDays).UTC().Format(time.RFC3339)
+	var ids, err3 = b.client.QueryEmails(ctx, map[string]interface{}{"inMailbox": mailbox, "after": since}, limit)
+	if err3 != nil {
+		return nil, err3
+	}
+	slices.Reverse(ids)
+
+	var emails, err4 = b.fetchNew(ctx, mailbox, ids)
+	if err4 != nil {
+		return nil, err4
+	}
+	if err := b.state.SetMailboxState(mailbox, state); err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// Idle waits until an email or mailbox changes: on the server's event
+// source when it has one, otherwise polling the Email state. Any change is
+// reported as FlagsChanged: the next sync sorts out what it was.
+func (b *Backend) Idle(ctx context.Context, mailbox string) (*ports.MailboxUpdate, error) {
+	var stream, err = b.client.EventSource(ctx, []string{"Email", "Mailbox"}, b.pollInterval())
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return b.poll(ctx, mailbox)
+	}
+	defer stream.Close()
+
+	// Server-sent events: the first "state" event is the current state
+	// (RFC 8620 section 7.3), the next one is a change
+	var scanner = bufio.NewScanner(stream)
+	var events = 0
+	for scanner.Scan() {
+		var line = scanner.Text()
+		if strings.HasPrefix(line, "event:") && strings.TrimSpace(line[len("event:"):]) == "state" {
+			events++
+			if events > 1 {
+				return b.mailboxUpdate(ctx, mailbox, true), nil
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// closeafter=state: the server closed the stream after a change
+	return b.mailboxUpdate(ctx, mailbox, true), nil
+}
+
+// poll checks the Email state every PollInterval until it changes
+func (b *Backend) poll(ctx context.Context, mailbox string) (*ports.MailboxUpdate, error) {
+	var start, err = b.client.EmailState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ticker = time.NewTicker(b.pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		var current, err = b.client.EmailState(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if current != start {
+			return b.mailboxUpdate(ctx, mailbox, false), nil
+		}
+	}
+}
+
+func (b *Backend) pollInterval() time.Duration {
+	if b.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return b.PollInterval
+}
+
+// mailboxUpdate reports a change with the current message count
+func (b *Backend) mailboxUpdate(ctx context.Context, mailbox string, viaPush bool) *ports.MailboxUpdate {
+	var update = &ports.MailboxUpdate{Mailbox: mailbox, FlagsChanged: true, ViaIdle: viaPush}
+	if err := b.loadMailboxes(ctx); err == nil {
+		if id, err := b.mailboxFor(ctx, mailbox); err == nil {
+			b.mu.RLock()
+			update.NumMessages = uint32(b.mailboxes[id].TotalEmails)
+			b.mu.RUnlock()
+		}
+	}
+	return update
+}
+
+// FetchAttachmentMetadata returns the attachments of an email. Part numbers
+// are JMAP part IDs.
+func (b *Backend) FetchAttachmentMetadata(ctx context.Context, uid uint32) ([]ports.AttachmentInfo, bool, error) {
+	var email, err = b.attachmentsOf(ctx, uid)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var attachments = attachmentInfo(email.Attachments)
+	return attachments, len(attachments) > 0, nil
+}
+
+// FetchAttachmentPart returns an attachment base64-encoded, like IMAP does
+// for binary parts (callers decode it with the stored encoding)
+func (b *Backend) FetchAttachmentPart(ctx context.Context, uid uint32, partNumber string) ([]byte, error) {
+	var email, err = b.attachmentsOf(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, part := range email.Attachments {
+		if part.PartID == partNumber {
+			var data, err = b.client.Download(ctx, part.BlobID, part.Type, part.Name)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(base64.StdEncoding.EncodeToString(data)), nil
+		}
+	}
+	return nil, fmt.Errorf("part %s not found", partNumber)
+}
+
+// attachmentsOf fetches the attachment parts of an email of the selected folder
+func (b *Backend) attachmentsOf(ctx context.Context, uid uint32) (*Email, error) {
+	var id, err = b.emailID(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails, err2 = b.client.GetEmails(ctx, []string{id}, []string{"id", "attachments"})
+	if err2 != nil {
+		return nil, err2
+	}
+	if len(emails) == 0 {
+		return nil, fmt.Errorf("email %d not found", uid)
+	}
+	return &emails[0], nil
+}
+
+// MarkAsRead sets the $seen keyword
+func (b *Backend) MarkAsRead(ctx context.Context, uid uint32) error {
+	return b.update(ctx, uid, map[string]interface{}{"keywords/$seen": true})
+}
+
+// MarkAsUnread clears the $seen keyword
+func (b *Backend) MarkAsUnread(ctx context.Context, uid uint32) error {
+	return b.update(ctx, uid, map[string]interface{}{"keywords/$seen": nil})
+}
+
+// Archive moves an email to the archive mailbox
+func (b *Backend) Archive(ctx context.Context, uid uint32) error {
+	var archive = b.mailboxByRole("archive")
+	if archive == "" {
+		return fmt.Errorf("no archive mailbox")
+	}
+	return b.move(ctx, uid, archive)
+}
+
+// MoveToFolder moves an email to a folder
+func (b *Backend) MoveToFolder(ctx context.Context, uid uint32, folder string) error {
+	var target, err = b.mailboxFor(ctx, folder)
+	if err != nil {
+		return err
+	}
+	return b.move(ctx, uid, target)
+}
+
+// CopyToInbox adds an email of folder to the inbox mailbox, unseen
+func (b *Backend) CopyToInbox(ctx context.Context, folder string, uid uint32) error {
+	var mailbox, err = b.mailboxFor(ctx, folder)
+	if err != nil {
+		return err
+	}
+	var inbox = b.mailboxByRole("inbox")
+	if inbox == "" {
+		return fmt.Errorf("no inbox mailbox")
+	}
+	var ids, err2 = b.state.EmailIDs(mailbox, []uint32{uid})
+	if err2 != nil {
+		return err2
+	}
+	var id, ok = ids[uid]
+	if !ok {
+		return fmt.Errorf("unknown UID %d", uid)
+	}
+	return b.client.UpdateEmail(ctx, id, map[string]interface{}{
+		"mailboxIds/" + inbox: true,
+		"keywords/$seen":      nil,
+	})
+}
+
+// Delete moves an email to the trash
+func (b *Backend) Delete(ctx context.Context, uid uint32) error {
+	var trash = b.mailboxByRole("trash")
+	if trash == "" {
+		return fmt.Errorf("no trash mailbox")
+	}
+	return b.move(ctx, uid, trash)
+}
+
+// Undelete moves an email from the trash back to the inbox
+func (b *Backend) Undelete(ctx context.Context, uid uint32) error {
+	var id, err = b.emailID(uid)
+	if err != nil {
+		return err
+	}
+	var patch = map[string]interface{}{"mailboxIds/" + b.mailboxByRole("inbox"): true}
+	if trash := b.mailboxByRole("trash"); trash != "" {
+		patch["mailboxIds/"+trash] = nil
+	}
+	return b.client.UpdateEmail(ctx, id, patch)
+}
+
+// AddKeyword sets a keyword
+func (b *Backend) AddKeyword(ctx context.Context, uid uint32, keyword string) error {
+	return b.update(ctx, uid, map[string]interface{}{"keywords/" + keyword: true})
+}
+
+// RemoveKeyword clears a keyword
+func (b *Backend) RemoveKeyword(ctx context.Context, uid uint32, keyword string) error {
+	return b.update(ctx, uid, map[string]interface{}{"keywords/" + keyword: nil})
+}
+
+// GetTrashFolder returns the folder of the trash mailbox
+func (b *Backend) GetTrashFolder() string {
+	if name := b.folderName(b.mailboxByRole("trash")); name != "" {
+		return name
+	}
+	return "Trash"
+}
+
+// move takes an email out of the selected folder and into another mailbox
+func (b *Backend) move(ctx context.Context, uid uint32, target string) error {
+	var current, err = b.selectedMailbox()
+	if err != nil {
+		return err
+	}
+	if current == target {
+		return nil
+	}
+	return b.update(ctx, uid, map[string]interface{}{
+		"mailboxIds/" + current: nil,
+		"mailboxIds/" + target:  true,
+	})
+}
+
+// update patches an email of the selected folder
+func (b *Backend) update(ctx context.Context, uid uint32, patch map[string]interface{}) error {
+	var id, err = b.emailID(uid)
+	if err != nil {
+		return err
+	}
+	return b.client.UpdateEmail(ctx, id, patch)
+}
+
+// emailID returns the JMAP email ID of a UID in the selected folder
+func (b *Backend) emailID(uid uint32) (string, error) {
+	var mailbox, err = b.selectedMailbox()
+	if err != nil {
+		return "", err
+	}
+
+	var ids, err2 = b.state.EmailIDs(mailbox, []uint32{uid})
+	if err2 != nil {
+		return "", err2
+	}
+	var id, ok = ids[uid]
+	if !ok {
+		return "", fmt.Errorf("unknown UID %d", uid)
+	}
+	return id, nil
+}
+
+// addedSince returns the emails that entered the mailbox since the state
+// (oldest first) and the new state. Emails that already have a UID there
+// (flag changes) are left out.
+func (b *Backend) addedSince(ctx context.Context, mailbox, since string) ([]string, string, error) {
+	var changes, err = b.client.EmailChanges(ctx, since)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var candidates = append(changes.Created, changes.Updated...)
+	var known, err2 = b.state.LookupUIDs(mailbox, candidates)
+	if err2 != nil {
+		return nil, "", err2
+	}
+	var unknown []string
+	for _, id := range candidates {
+		if _, ok := known[id]; !ok && !slices.Contains(unknown, id) {
+			unknown = append(unknown, id)
+		}
+	}
+
+	var added []Email
+	for start := 0; start < len(unknown); start += 500 {
+		var emails, err = b.client.GetEmails(ctx, unknown[start:min(start+500, len(unknown))], []string{"id", "mailboxIds", "receivedAt"})
+		if err != nil {
+			return nil, "", err
+		}
+		for _, e := range emails {
+			if e.MailboxIDs[mailbox] {
+				added = append(added, e)
+			}
+		}
+	}
+	sort.SliceStable(added, func(i, j int) bool { return added[i].ReceivedAt.Before(added[j].ReceivedAt) })
+
+	var ids = make([]string, len(added))
+	for i, e := range added {
+		ids[i] = e.ID
+	}
+	return ids, changes.NewState, nil
+}
+
+// fetchNew assigns UIDs to emails (oldest first) and fetches them
+func (b *Backend) fetchNew(ctx context.Context, mailbox string, ids []string) ([]ports.IMAPEmail, error) {
+	var uids, err = b.state.AssignUIDs(mailbox, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var byUID = make(map[uint32]string, len(uids))
+	for id, uid := range uids {
+		byUID[uid] = id
+	}
+	return b.fetchEmails(ctx, byUID)
+}
+
+// fetchEmails fetches emails 100 at a time and converts them, sorted by UID
+func (b *Backend) fetchEmails(ctx context.Context, ids map[uint32]string) ([]ports.IMAPEmail, error) {
+	var uidOf = make(map[string]uint32, len(ids))
+	var list = make([]string, 0, len(ids))
+	for uid, id := range ids {
+		uidOf[id] = uid
+		list = append(list, id)
+	}
+
+	var result = make([]ports.IMAPEmail, 0, len(list))
+	for start := 0; start < len(list); start += 100 {
+		var emails, err = b.client.GetEmails(ctx, list[start:min(start+100, len(list))], nil)
+		if err != nil {
+			return nil, err
+		}
+		for i := range emails {
+			result = append(result, toEmail(&emails[i], uidOf[emails[i].ID]))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UID < result[j].UID })
+	return result, nil
+}
+
+// toEmail converts an email fetched with the sync properties
+func toEmail(e *Email, uid uint32) ports.IMAPEmail {
+	var email = ports.IMAPEmail{
+		UID:            uid,
+		Subject:        e.Subject,
+		Date:           e.ReceivedAt,
+		Seen:           e.Keywords["$seen"],
+		Flagged:        e.Keywords["$flagged"],
+		Size:           e.Size,
+		HasAttachments: len(e.Attachments) > 0,
+		Attachments:    attachmentInfo(e.Attachments),
+		ThreadID:       e.ThreadID,
+	}
+	if e.SentAt != nil {
+		email.Date = *e.SentAt
+	}
+	if len(e.MessageID) > 0 {
+		email.MessageID = e.MessageID[0]
+	}
+	if len(e.InReplyTo) > 0 {
+		email.InReplyTo = "<" + e.InReplyTo[0] + ">"
+	}
+	if len(e.References) > 0 {
+		email.References = "<" + strings.Join(e.References, "> <") + ">"
+	}
+	if len(e.From) > 0 {
+		email.FromName = e.From[0].Name
+		email.FromEmail = e.From[0].Email
+	}
+	if email.FromName == "" {
+		email.FromName = email.FromEmail
+	}
+
+	var to = make([]string, 0, len(e.To))
+	for _, addr := range e.To {
+		to = append(to, addr.Email)
+	}
+	email.To = strings.Join(to, ", ")
+
+	var texts []string
+	for _, part := range e.TextBody {
+		if value, ok := e.BodyValues[part.PartID]; ok && strings.HasPrefix(part.Type, "text/plain") {
+			texts = append(texts, value.Value)
+		}
+	}
+	email.BodyText = strings.Join(texts, "\n")
+	if email.BodyText == "" {
+		email.BodyText = e.Preview
+	}
+	return email
+}
+
+// attachmentInfo converts attachment parts
+func attachmentInfo(parts []EmailBodyPart) []ports.AttachmentInfo {
+	var result []ports.AttachmentInfo
+	for _, p := range parts {
+		result = append(result, ports.AttachmentInfo{
+			PartNumber:  p.PartID,
+			Filename:    p.Name,
+			ContentType: p.Type,
+			ContentID:   strings.Trim(p.Cid, "<>"),
+			Encoding:    "base64",
+			Size:        p.Size,
+			IsInline:    p.Disposition == "inline",
+			Charset:     p.Charset,
+		})
+	}
+	return result
+}
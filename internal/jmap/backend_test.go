@@ -0,0 +1,625 @@
+package jmap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// fakeJMAP is a minimal in-process JMAP server: session, Mailbox/get,
+// Email/get, query, changes, set and import, Identity/get,
+// EmailSubmission/set and the upload and download endpoints
+type fakeJMAP struct {
+	mu          sync.Mutex
+	url         string
+	state       int
+	minState    int // Email/changes below this fails with cannotCalculateChanges
+	mailboxes   []Mailbox
+	emails      map[string]*Email
+	created     map[string]int // email ID -> state it was created in
+	updated     map[string]int // email ID -> state of its last update
+	destroyed   map[string]int
+	blobs       map[string][]byte
+	submissions []map[string]interface{}
+}
+
+func newFakeJMAP() *fakeJMAP {
+	return &fakeJMAP{
+		state: 10,
+		mailboxes: []Mailbox{
+			{ID: "mb-inbox", Name: "Inbox", Role: "inbox"},
+			{ID: "mb-archive", Name: "Archive", Role: "archive", SortOrder: 3},
+			{ID: "mb-drafts", Name: "Drafts", Role: "drafts", SortOrder: 1},
+			{ID: "mb-sent", Name: "Sent", Role: "sent", SortOrder: 2},
+			{ID: "mb-trash", Name: "Trash", Role: "trash", SortOrder: 4},
+			{ID: "mb-work", Name: "Work", SortOrder: 5},
+			{ID: "mb-clients", Name: "Clients", ParentID: "mb-work"},
+		},
+		emails:    map[string]*Email{},
+		created:   map[string]int{},
+		updated:   map[string]int{},
+		destroyed: map[string]int{},
+		blobs:     map[string][]byte{},
+	}
+}
+
+func (f *fakeJMAP) stateString() string {
+	return "s" + strconv.Itoa(f.state)
+}
+
+// addEmail delivers an email to the inbox, received after the existing ones
+func (f *fakeJMAP) addEmail(id, subject string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.state++
+	f.blobs["blob-"+id] = []byte("Subject: " + subject + "\r\n\r\nbody of " + id)
+	f.emails[id] = &Email{
+		ID:         id,
+		BlobID:     "blob-" + id,
+		ThreadID:   "thread-" + id,
+		MailboxIDs: map[string]bool{"mb-inbox": true},
+		Keywords:   map[string]bool{},
+		ReceivedAt: time.Date(2026, 1, 1, 0, 0, f.state, 0, time.UTC),
+		MessageID:  []string{id + "@example.com"},
+		From:       []EmailAddress{{Name: "Alice", Email: "alice@example.com"}},
+		Subject:    subject,
+		TextBody:   []EmailBodyPart{{PartID: "1", Type: "text/plain"}},
+		BodyValues: map[string]EmailBodyValue{"1": {Value: "body of " + id}},
+		Attachments: []EmailBodyPart{{
+			PartID: "2", BlobID: "att-" + id, Type: "application/pdf", Name: "doc.pdf", Size: 3, Disposition: "attachment",
+		}},
+	}
+	f.blobs["att-"+id] = []byte("pdf")
+	f.created[id] = f.state
+}
+
+// patch applies an Email/set patch to an email
+func (f *fakeJMAP) patch(id string, patch map[string]interface{}) bool {
+	var e, ok = f.emails[id]
+	if !ok {
+		return false
+	}
+	f.state++
+	for path, value := range patch {
+		var kind, key, _ = strings.Cut(path, "/")
+		var target = e.Keywords
+		if kind == "mailboxIds" {
+			target = e.MailboxIDs
+		}
+		if value == nil {
+			delete(target, key)
+		} else {
+			target[key] = true
+		}
+	}
+	f.updated[id] = f.state
+	return true
+}
+
+func (f *fakeJMAP) destroy(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state++
+	delete(f.emails, id)
+	f.destroyed[id] = f.state
+}
+
+func (f *fakeJMAP) setFlag(id, keyword string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.patch(id, map[string]interface{}{"keywords/" + keyword: true})
+}
+
+func (f *fakeJMAP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/.well-known/jmap":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"capabilities":    map[string]interface{}{CapabilityCore: map[string]interface{}{}, CapabilityMail: map[string]interface{}{}, CapabilitySubmission: map[string]interface{}{}},
+			"primaryAccounts": map[string]string{CapabilityMail: "acc1"},
+			"username":        "me@example.com",
+			"apiUrl":          f.url + "/api",
+			"downloadUrl":     f.url + "/download/{accountId}/{blobId}/{name}?type={type}",
+			"uploadUrl":       f.url + "/upload/{accountId}",
+			"state":           "session1",
+		})
+	case r.URL.Path == "/api":
+		var req struct {
+			MethodCalls [][]json.RawMessage `json:"methodCalls"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var responses [][]interface{}
+		var createdIDs = map[string]string{}
+		for _, call := range req.MethodCalls {
+			var name, callID string
+			var args map[string]interface{}
+			json.Unmarshal(call[0], &name)
+			json.Unmarshal(call[1], &args)
+			json.Unmarshal(call[2], &callID)
+			var respName, resp = f.call(name, args, createdIDs)
+			responses = append(responses, []interface{}{respName, resp, callID})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"methodResponses": responses})
+	case strings.HasPrefix(r.URL.Path, "/upload/"):
+		var data, _ = io.ReadAll(r.Body)
+		var id = fmt.Sprintf("upload-%d", len(f.blobs))
+		f.blobs[id] = data
+		json.NewEncoder(w).Encode(map[string]string{"blobId": id})
+	case strings.HasPrefix(r.URL.Path, "/download/"):
+		var parts = strings.Split(r.URL.Path, "/")
+		var data, ok = f.blobs[parts[3]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// call runs one method and returns the response name and arguments
+func (f *fakeJMAP) call(name string, args map[string]interface{}, createdIDs map[string]string) (string, interface{}) {
+	switch name {
+	case "Mailbox/get":
+		var list = slices.Clone(f.mailboxes)
+		for i := range list {
+			for _, e := range f.emails {
+				if e.MailboxIDs[list[i].ID] {
+					list[i].TotalEmails++
+					if !e.Keywords["$seen"] {
+						list[i].UnreadEmails++
+					}
+				}
+			}
+		}
+		return name, map[string]interface{}{"state": "m1", "list": list}
+
+	case "Email/get":
+		var list = []Email{}
+		var notFound = []string{}
+		for _, id := range args["ids"].([]interface{}) {
+			if e, ok := f.emails[id.(string)]; ok {
+				list = append(list, *e)
+			} else {
+				notFound = append(notFound, id.(string))
+			}
+		}
+		return name, map[string]interface{}{"state": f.stateString(), "list": list, "notFound": notFound}
+
+	case "Email/query":
+		var filter = args["filter"].(map[string]interface{})
+		var matches []*Email
+		for _, e := range f.emails {
+			if mailbox, ok := filter["inMailbox"].(string); ok && !e.MailboxIDs[mailbox] {
+				continue
+			}
+			if text, ok := filter["text"].(string); ok && !strings.Contains(e.Subject, text) {
+				continue
+			}
+			if after, ok := filter["after"].(string); ok {
+				var t, _ = time.Parse(time.RFC3339, after)
+				if !e.ReceivedAt.After(t) {
+					continue
+				}
+			}
+			matches = append(matches, e)
+		}
+		sort.Slice(matches, func(i, j int) bool { return matches[i].ReceivedAt.After(matches[j].ReceivedAt) })
+		var position, limit = int(args["position"].(float64)), int(args["limit"].(float64))
+		var ids = []string{}
+		for i := position; i < len(matches) && len(ids) < limit; i++ {
+			ids = append(ids, matches[i].ID)
+		}
+		return name, map[string]interface{}{"ids": ids, "position": position, "total": len(matches)}
+
+	case "Email/changes":
+		var since, _ = strconv.Atoi(strings.TrimPrefix(args["sinceState"].(string), "s"))
+		if since < f.minState {
+			return "error", map[string]string{"type": "cannotCalculateChanges"}
+		}
+		var changes = Changes{OldState: args["sinceState"].(string), NewState: f.stateString(), Created: []string{}, Updated: []string{}, Destroyed: []string{}}
+		for id, state := range f.created {
+			if _, gone := f.destroyed[id]; state > since && !gone {
+				changes.Created = append(changes.Created, id)
+			}
+		}
+		for id, state := range f.updated {
+			if _, gone := f.destroyed[id]; state > since && f.created[id] <= since && !gone {
+				changes.Updated = append(changes.Updated, id)
+			}
+		}
+		for id, state := range f.destroyed {
+			if state > since && f.created[id] <= since {
+				changes.Destroyed = append(changes.Destroyed, id)
+			}
+		}
+		return name, changes
+
+	case "Email/set":
+		var notUpdated = map[string]SetError{}
+		for id, patch := range args["update"].(map[string]interface{}) {
+			if !f.patch(id, patch.(map[string]interface{})) {
+				notUpdated[id] = SetError{Type: "notFound"}
+			}
+		}
+		return name, map[string]interface{}{"newState": f.stateString(), "notUpdated": notUpdated}
+
+	case "Email/import":
+		var created = map[string]interface{}{}
+		for key, raw := range args["emails"].(map[string]interface{}) {
+			var spec = raw.(map[string]interface{})
+			f.state++
+			var id = fmt.Sprintf("imported-%d", f.state)
+			var e = &Email{ID: id, BlobID: spec["blobId"].(string), MailboxIDs: map[string]bool{}, Keywords: map[string]bool{}, ReceivedAt: time.Now()}
+			for mailbox := range spec["mailboxIds"].(map[string]interface{}) {
+				e.MailboxIDs[mailbox] = true
+			}
+			for keyword := range spec["keywords"].(map[string]interface{}) {
+				e.Keywords[keyword] = true
+			}
+			f.emails[id] = e
+			f.created[id] = f.state
+			createdIDs["#"+key] = id
+			created[key] = map[string]string{"id": id}
+		}
+		return name, map[string]interface{}{"created": created}
+
+	case "Identity/get":
+		return name, map[string]interface{}{"list": []Identity{{ID: "id1", Email: "me@example.com"}}}
+
+	case "EmailSubmission/set":
+		var created = map[string]interface{}{}
+		for key, raw := range args["create"].(map[string]interface{}) {
+			var spec = raw.(map[string]interface{})
+			f.submissions = append(f.submissions, spec)
+			created[key] = map[string]string{"id": "sub-" + key}
+			if update, ok := args["onSuccessUpdateEmail"].(map[string]interface{})["#"+key]; ok {
+				f.patch(createdIDs[spec["emailId"].(string)], update.(map[string]interface{}))
+			}
+		}
+		return name, map[string]interface{}{"created": created}
+	}
+	return "error", map[string]string{"type": "unknownMethod"}
+}
+
+// memState is an in-memory SyncState
+type memState struct {
+	uids    map[string]map[string]uint32
+	states  map[string]string
+	modSeqs []string
+}
+
+func newMemState() *memState {
+	return &memState{uids: map[string]map[string]uint32{}, states: map[string]string{}}
+}
+
+func (s *memState) AssignUIDs(mailboxID string, emailIDs []string) (map[string]uint32, error) {
+	if s.uids[mailboxID] == nil {
+		s.uids[mailboxID] = map[string]uint32{}
+	}
+	var mailbox = s.uids[mailboxID]
+	var result = map[string]uint32{}
+	for _, id := range emailIDs {
+		if _, ok := mailbox[id]; !ok {
+			mailbox[id] = uint32(len(mailbox) + 1)
+		}
+		result[id] = mailbox[id]
+	}
+	return result, nil
+}
+
+func (s *memState) LookupUIDs(mailboxID string, emailIDs []string) (map[string]uint32, error) {
+	var result = map[string]uint32{}
+	for _, id := range emailIDs {
+		if uid, ok := s.uids[mailboxID][id]; ok {
+			result[id] = uid
+		}
+	}
+	return result, nil
+}
+
+func (s *memState) EmailIDs(mailboxID string, uids []uint32) (map[uint32]string, error) {
+	var result = map[uint32]string{}
+	for id, uid := range s.uids[mailboxID] {
+		if slices.Contains(uids, uid) {
+			result[uid] = id
+		}
+	}
+	return result, nil
+}
+
+func (s *memState) EmailIDsAfter(mailboxID string, after uint32) (map[uint32]string, error) {
+	var result = map[uint32]string{}
+	for id, uid := range s.uids[mailboxID] {
+		if uid > after {
+			result[uid] = id
+		}
+	}
+	return result, nil
+}
+
+func (s *memState) MailboxState(mailboxID string) (string, error) {
+	return s.states[mailboxID], nil
+}
+
+func (s *memState) SetMailboxState(mailboxID, state string) error {
+	s.states[mailboxID] = state
+	return nil
+}
+
+func (s *memState) ModSeq(state string) (uint64, error) {
+	if i := slices.Index(s.modSeqs, state); i >= 0 {
+		return uint64(i + 1), nil
+	}
+	s.modSeqs = append(s.modSeqs, state)
+	return uint64(len(s.modSeqs)), nil
+}
+
+func (s *memState) StateAt(modSeq uint64) (string, error) {
+	if modSeq == 0 || modSeq > uint64(len(s.modSeqs)) {
+		return "", nil
+	}
+	return s.modSeqs[modSeq-1], nil
+}
+
+// newTestBackend connects a Backend to a fake with three inbox emails and
+// selects INBOX
+func newTestBackend(t *testing.T) (*Backend, *fakeJMAP, *memState) {
+	t.Helper()
+
+	var fake = newFakeJMAP()
+	var server = httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	fake.url = server.URL
+	fake.addEmail("e1", "first")
+	fake.addEmail("e2", "second")
+	fake.addEmail("e3", "third")
+
+	var state = newMemState()
+	var backend = NewBackend(NewClientWithHTTP(server.Client(), server.URL, nil), state)
+	var ctx = context.Background()
+	if err := backend.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if _, err := backend.SelectMailbox(ctx, "INBOX"); err != nil {
+		t.Fatalf("SelectMailbox: %v", err)
+	}
+	return backend, fake, state
+}
+
+func subjects(emails []ports.IMAPEmail) []string {
+	var result []string
+	for _, e := range emails {
+		result = append(result, e.Subject)
+	}
+	return result
+}
+
+func TestBackendListMailboxes(t *testing.T) {
+	var backend, _, _ = newTestBackend(t)
+
+	var mailboxes, err = backend.ListMailboxes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range mailboxes {
+		names = append(names, m.Name)
+	}
+	var want = []string{"INBOX", "Work/Clients", "Drafts", "Sent", "Archive", "Trash", "Work"}
+	if !slices.Equal(names, want) {
+		t.Errorf("mailboxes = %v, want %v", names, want)
+	}
+	if mailboxes[0].Messages != 3 || mailboxes[0].Unseen != 3 {
+		t.Errorf("INBOX counts = %d/%d, want 3/3", mailboxes[0].Messages, mailboxes[0].Unseen)
+	}
+}
+
+func TestBackendInitialSync(t *testing.T) {
+	var backend, _, state = newTestBackend(t)
+	var ctx = context.Background()
+
+	var emails, err = backend.FetchNewEmailsBatch(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := subjects(emails); !slices.Equal(got, []string{"first", "second", "third"}) {
+		t.Fatalf("subjects = %v", got)
+	}
+	var first = emails[0]
+	if first.UID != 1 || first.MessageID != "e1@example.com" || first.FromEmail != "alice@example.com" || first.BodyText != "body of e1" {
+		t.Errorf("first email = %+v", first)
+	}
+	if len(first.Attachments) != 1 || first.Attachments[0].Filename != "doc.pdf" {
+		t.Errorf("attachments = %+v", first.Attachments)
+	}
+	if state.states["mb-inbox"] == "" {
+		t.Error("mailbox state not stored")
+	}
+
+	// Nothing changed: no new emails
+	emails, err = backend.FetchNewEmailsBatch(ctx, 3, 10)
+	if err != nil || len(emails) != 0 {
+		t.Fatalf("second sync = %v, %v", subjects(emails), err)
+	}
+}
+
+func TestBackendIncrementalSync(t *testing.T) {
+	var backend, fake, _ = newTestBackend(t)
+	var ctx = context.Background()
+
+	if _, err := backend.FetchNewEmailsBatch(ctx, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+	var status, err = backend.SelectMailbox(ctx, "INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.addEmail("e4", "fourth")
+	fake.setFlag("e1", "$seen")
+	fake.destroy("e2")
+	fake.mu.Lock()
+	fake.patch("e3", map[string]interface{}{"mailboxIds/mb-inbox": nil, "mailboxIds/mb-archive": true})
+	fake.mu.Unlock()
+
+	var newer, err2 = backend.SelectMailbox(ctx, "INBOX")
+	if err2 != nil {
+		t.Fatal(err2)
+	}
+	if newer.HighestModSeq <= status.HighestModSeq {
+		t.Fatalf("HighestModSeq %d not above %d", newer.HighestModSeq, status.HighestModSeq)
+	}
+
+	var emails, err3 = backend.FetchNewEmailsBatch(ctx, 3, 10)
+	if err3 != nil {
+		t.Fatal(err3)
+	}
+	if got := subjects(emails); !slices.Equal(got, []string{"fourth"}) || emails[0].UID != 4 {
+		t.Fatalf("new emails = %v", got)
+	}
+
+	var updates, err4 = backend.FetchFlagChanges(ctx, status.HighestModSeq)
+	if err4 != nil {
+		t.Fatal(err4)
+	}
+	var want = []ports.FlagUpdate{
+		{UID: 1, Seen: true},
+		{UID: 2, Deleted: true},
+		{UID: 3, Deleted: true},
+		{UID: 4},
+	}
+	if !slices.Equal(updates, want) {
+		t.Errorf("flag updates = %+v, want %+v", updates, want)
+	}
+
+	var uids, err5 = backend.GetAllUIDs(ctx)
+	if err5 != nil || !slices.Equal(uids, []uint32{1, 4}) {
+		t.Errorf("GetAllUIDs = %v, %v", uids, err5)
+	}
+}
+
+func TestBackendExpiredState(t *testing.T) {
+	var backend, fake, _ = newTestBackend(t)
+	var ctx = context.Background()
+
+	if _, err := backend.FetchNewEmailsBatch(ctx, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+	var status, _ = backend.SelectMailbox(ctx, "INBOX")
+
+	fake.addEmail("e4", "fourth")
+	fake.setFlag("e2", "$flagged")
+	fake.mu.Lock()
+	fake.minState = fake.state
+	fake.mu.Unlock()
+
+	// The stored state is too old: the newest emails are queried instead
+	var emails, err = backend.FetchNewEmailsBatch(ctx, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := subjects(emails); !slices.Equal(got, []string{"fourth"}) {
+		t.Fatalf("new emails = %v", got)
+	}
+
+	// ...and every synced email is refreshed
+	var updates, err2 = backend.FetchFlagChanges(ctx, status.HighestModSeq)
+	if err2 != nil {
+		t.Fatal(err2)
+	}
+	if len(updates) != 4 || !updates[1].Flagged || updates[0].Flagged {
+		t.Errorf("flag updates = %+v", updates)
+	}
+}
+
+func TestBackendActions(t *testing.T) {
+	var backend, fake, _ = newTestBackend(t)
+	var ctx = context.Background()
+
+	if _, err := backend.FetchNewEmailsBatch(ctx, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backend.MarkAsRead(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Archive(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.MoveToFolder(ctx, 3, "Work/Clients"); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.mu.Lock()
+	if !fake.emails["e1"].Keywords["$seen"] {
+		t.Error("e1 not marked as read")
+	}
+	if m := fake.emails["e2"].MailboxIDs; m["mb-inbox"] || !m["mb-archive"] {
+		t.Errorf("e2 mailboxes = %v", m)
+	}
+	if m := fake.emails["e3"].MailboxIDs; m["mb-inbox"] || !m["mb-clients"] {
+		t.Errorf("e3 mailboxes = %v", m)
+	}
+	fake.mu.Unlock()
+
+	if got := backend.GetTrashFolder(); got != "Trash" {
+		t.Errorf("GetTrashFolder = %q", got)
+	}
+
+	var raw, err = backend.FetchEmailRaw(ctx, 1)
+	if err != nil || !strings.Contains(string(raw), "Subject: first") {
+		t.Errorf("FetchEmailRaw = %q, %v", raw, err)
+	}
+	var part, err2 = backend.FetchAttachmentPart(ctx, 1, "2")
+	if err2 != nil || string(part) != "cGRm" {
+		t.Errorf("FetchAttachmentPart = %q, %v", part, err2)
+	}
+}
+
+func TestClientSubmit(t *testing.T) {
+	var backend, fake, _ = newTestBackend(t)
+	var ctx = context.Background()
+
+	var id, err = backend.client.Submit(ctx, &Submission{
+		Raw:        []byte("Subject: hi\r\n\r\nhello"),
+		From:       "me@example.com",
+		Recipients: []string{"bob@example.com", "carol@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.submissions) != 1 {
+		t.Fatalf("submissions = %d", len(fake.submissions))
+	}
+	var envelope = fake.submissions[0]["envelope"].(map[string]interface{})
+	if len(envelope["rcptTo"].([]interface{})) != 2 || fake.submissions[0]["identityId"] != "id1" {
+		t.Errorf("submission = %v", fake.submissions[0])
+	}
+	var sent = fake.emails[id]
+	if sent == nil || !sent.MailboxIDs["mb-sent"] || sent.MailboxIDs["mb-drafts"] || sent.Keywords["$draft"] {
+		t.Errorf("sent email = %+v", sent)
+	}
+	if string(fake.blobs[sent.BlobID]) != "Subject: hi\r\n\r\nhello" {
+		t.Errorf("blob = %q", fake.blobs[sent.BlobID])
+	}
+}
@@ -0,0 +1,396 @@
+package jmap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Capability URNs used by miau (RFC 8620, RFC 8621)
+const (
+	CapabilityCore       = "urn:ietf:params:jmap:core"
+	CapabilityMail       = "urn:ietf:params:jmap:mail"
+	CapabilitySubmission = "urn:ietf:params:jmap:submission"
+)
+
+// requestTimeout bounds every API call except event-source streams
+const requestTimeout = 60 * time.Second
+
+// Session is the JMAP session resource (RFC 8620 section 2)
+type Session struct {
+	Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	PrimaryAccounts map[string]string          `json:"primaryAccounts"`
+	Username        string                     `json:"username"`
+	APIURL          string                     `json:"apiUrl"`
+	DownloadURL     string                     `json:"downloadUrl"`
+	UploadURL       string                     `json:"uploadUrl"`
+	EventSourceURL  string                     `json:"eventSourceUrl"`
+	State           string                     `json:"state"`
+}
+
+// MethodError is an "error" method response (RFC 8620 section 3.6.2)
+type MethodError struct {
+	Method      string
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+func (e *MethodError) Error() string {
+	var msg = fmt.Sprintf("jmap: %s: %s", e.Method, e.Type)
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// IsCannotCalculateChanges reports whether a /changes call failed because
+// the server no longer has the changes since the given state
+func IsCannotCalculateChanges(err error) bool {
+	var methodErr, ok = err.(*MethodError)
+	return ok && methodErr.Type == "cannotCalculateChanges"
+}
+
+// HTTPError is a non-2xx response from the server
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("jmap: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// Client talks to a JMAP server. Authenticate sets the Authorization header
+// of every request (bearer token or basic auth).
+type Client struct {
+	httpClient   *http.Client
+	sessionURL   string
+	authenticate func(*http.Request)
+
+	mu        sync.Mutex
+	session   *Session
+	accountID string
+}
+
+// NewClient creates a client for the session resource at sessionURL. A
+// bare host (https://jmap.example.com) is resolved through
+// /.well-known/jmap. With a token the client uses bearer auth, otherwise
+// basic auth with username and password.
+func NewClient(sessionURL, username, password, token string) *Client {
+	var authenticate = func(r *http.Request) { r.SetBasicAuth(username, password) }
+	if token != "" {
+		authenticate = func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	return NewClientWithHTTP(&http.Client{}, sessionURL, authenticate)
+}
+
+// NewClientWithHTTP creates a client on an existing http.Client (tests use
+// an httptest server)
+func NewClientWithHTTP(httpClient *http.Client, sessionURL string, authenticate func(*http.Request)) *Client {
+	if u, err := url.Parse(sessionURL); err == nil && strings.Trim(u.Path, "/") == "" {
+		u.Path = "/.well-known/jmap"
+		sessionURL = u.String()
+	}
+	if authenticate == nil {
+		authenticate = func(*http.Request) {}
+	}
+	return &Client{httpClient: httpClient, sessionURL: sessionURL, authenticate: authenticate}
+}
+
+// Session fetches the session resource and picks the primary mail account
+func (c *Client) Session(ctx context.Context) (*Session, error) {
+	var session Session
+	if err := c.getJSON(ctx, c.sessionURL, &session); err != nil {
+		return nil, fmt.Errorf("failed to fetch JMAP session: %w", err)
+	}
+	if _, ok := session.Capabilities[CapabilityMail]; !ok {
+		return nil, fmt.Errorf("server does not support JMAP mail")
+	}
+	var accountID = session.PrimaryAccounts[CapabilityMail]
+	if accountID == "" || session.APIURL == "" {
+		return nil, fmt.Errorf("JMAP session has no mail account")
+	}
+
+	c.mu.Lock()
+	c.session = &session
+	c.accountID = accountID
+	c.mu.Unlock()
+	return &session, nil
+}
+
+// current returns the session and account, fetching the session once
+func (c *Client) current(ctx context.Context) (*Session, string, error) {
+	c.mu.Lock()
+	var session, accountID = c.session, c.accountID
+	c.mu.Unlock()
+	if session != nil {
+		return session, accountID, nil
+	}
+
+	var fetched, err = c.Session(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return fetched, fetched.PrimaryAccounts[CapabilityMail], nil
+}
+
+// CanSubmit reports whether the server supports sending
+func (c *Client) CanSubmit(ctx context.Context) bool {
+	var session, _, err = c.current(ctx)
+	if err != nil {
+		return false
+	}
+	var _, ok = session.Capabilities[CapabilitySubmission]
+	return ok
+}
+
+// Ref is a result reference to a previous call of the same request
+// (RFC 8620 section 3.7), used as an argument named "#name"
+type Ref struct {
+	ResultOf string `json:"resultOf"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+}
+
+// Request is a batch of method calls sent in one API request. Each call
+// decodes its response into the out value given to Add.
+type Request struct {
+	using []string
+	calls []invocation
+	outs  []interface{}
+}
+
+type invocation struct {
+	name   string
+	args   interface{}
+	callID string
+}
+
+func (i invocation) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{i.name, i.args, i.callID})
+}
+
+// NewRequest creates a request using the core and mail capabilities plus
+// any extra ones
+func NewRequest(extra ...string) *Request {
+	return &Request{using: append([]string{CapabilityCore, CapabilityMail}, extra...)}
+}
+
+// Add appends a method call and returns its call ID (for Ref). args gets
+// the accountId of the session.
+func (r *Request) Add(method string, args map[string]interface{}, out interface{}) string {
+	var callID = "c" + strconv.Itoa(len(r.calls))
+	r.calls = append(r.calls, invocation{name: method, args: args, callID: callID})
+	r.outs = append(r.outs, out)
+	return callID
+}
+
+// Do sends the request. The first method error is returned; responses of
+// the calls before it are decoded.
+func (c *Client) Do(ctx context.Context, r *Request) error {
+	var session, accountID, err = c.current(ctx)
+	if err != nil {
+		return err
+	}
+	for _, call := range r.calls {
+		call.args.(map[string]interface{})["accountId"] = accountID
+	}
+
+	var payload, err2 = json.Marshal(map[string]interface{}{
+		"using":       r.using,
+		"methodCalls": r.calls,
+	})
+	if err2 != nil {
+		return err2
+	}
+
+	var response struct {
+		MethodResponses [][]json.RawMessage `json:"methodResponses"`
+	}
+	if err := c.postJSON(ctx, session.APIURL, payload, &response); err != nil {
+		return err
+	}
+
+	var byID = make(map[string]int, len(r.calls))
+	for i, call := range r.calls {
+		byID[call.callID] = i
+	}
+	for _, resp := range response.MethodResponses {
+		if len(resp) != 3 {
+			return fmt.Errorf("jmap: malformed method response")
+		}
+		var name, callID string
+		json.Unmarshal(resp[0], &name)
+		json.Unmarshal(resp[2], &callID)
+		var i, ok = byID[callID]
+		if !ok {
+			continue
+		}
+
+		if name == "error" {
+			var methodErr = &MethodError{Method: r.calls[i].name}
+			json.Unmarshal(resp[1], methodErr)
+			return methodErr
+		}
+		if r.outs[i] != nil && name == r.calls[i].name {
+			if err := json.Unmarshal(resp[1], r.outs[i]); err != nil {
+				return fmt.Errorf("jmap: failed to decode %s response: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Call sends a single method call
+func (c *Client) Call(ctx context.Context, method string, args map[string]interface{}, out interface{}) error {
+	var r = NewRequest()
+	if strings.HasPrefix(method, "EmailSubmission/") || strings.HasPrefix(method, "Identity/") {
+		r = NewRequest(CapabilitySubmission)
+	}
+	r.Add(method, args, out)
+	return c.Do(ctx, r)
+}
+
+// Upload stores data as a blob and returns its ID
+func (c *Client) Upload(ctx context.Context, contentType string, data []byte) (string, error) {
+	var session, accountID, err = c.current(ctx)
+	if err != nil {
+		return "", err
+	}
+	var uploadURL = strings.ReplaceAll(session.UploadURL, "{accountId}", url.PathEscape(accountID))
+
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err2 = http.NewRequestWithContext(ctx2, http.MethodPost, uploadURL, bytes.NewReader(data))
+	if err2 != nil {
+		return "", err2
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	var result struct {
+		BlobID string `json:"blobId"`
+	}
+	if err := c.doJSON(req, &result); err != nil {
+		return "", fmt.Errorf("failed to upload blob: %w", err)
+	}
+	return result.BlobID, nil
+}
+
+// Download fetches a blob
+func (c *Client) Download(ctx context.Context, blobID, contentType, name string) ([]byte, error) {
+	var session, accountID, err = c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = "blob"
+	}
+	var replacer = strings.NewReplacer(
+		"{accountId}", url.PathEscape(accountID),
+		"{blobId}", url.PathEscape(blobID),
+		"{type}", url.QueryEscape(contentType),
+		"{name}", url.PathEscape(name),
+	)
+
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err2 = http.NewRequestWithContext(ctx2, http.MethodGet, replacer.Replace(session.DownloadURL), nil)
+	if err2 != nil {
+		return nil, err2
+	}
+	c.authenticate(req)
+
+	var resp, err3 = c.httpClient.Do(req)
+	if err3 != nil {
+		return nil, err3
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body, _ = io.ReadAll(resp.Body)
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// EventSource opens the push stream (RFC 8620 section 7.3) for the given
+// types. The caller reads server-sent events from the body and closes it.
+// Returns nil without error when the server has no event source.
+func (c *Client) EventSource(ctx context.Context, types []string, ping time.Duration) (io.ReadCloser, error) {
+	var session, _, err = c.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if session.EventSourceURL == "" {
+		return nil, nil
+	}
+	var replacer = strings.NewReplacer(
+		"{types}", strings.Join(types, ","),
+		"{closeafter}", "state",
+		"{ping}", strconv.Itoa(int(ping.Seconds())),
+	)
+
+	var req, err2 = http.NewRequestWithContext(ctx, http.MethodGet, replacer.Replace(session.EventSourceURL), nil)
+	if err2 != nil {
+		return nil, err2
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	c.authenticate(req)
+
+	var resp, err3 = c.httpClient.Do(req)
+	if err3 != nil {
+		return nil, err3
+	}
+	if resp.StatusCode != http.StatusOK {
+		var body, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp.Body, nil
+}
+
+// getJSON GETs a URL and decodes the JSON response
+func (c *Client) getJSON(ctx context.Context, u string, out interface{}) error {
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err = http.NewRequestWithContext(ctx2, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(req, out)
+}
+
+// postJSON POSTs a JSON payload and decodes the JSON response
+func (c *Client) postJSON(ctx context.Context, u string, payload []byte, out interface{}) error {
+	var ctx2, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	var req, err = http.NewRequestWithContext(ctx2, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.doJSON(req, out)
+}
+
+// doJSON authenticates and sends a request, decoding the JSON response
+func (c *Client) doJSON(req *http.Request, out interface{}) error {
+	c.authenticate(req)
+	var resp, err = c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var body, _ = io.ReadAll(resp.Body)
+		return &HTTPError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,392 @@
+package jmap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Mailbox is a JMAP mailbox (RFC 8621 section 2)
+type Mailbox struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	ParentID     string `json:"parentId,omitempty"`
+	Role         string `json:"role,omitempty"` // inbox, sent, drafts, trash, junk, archive...
+	SortOrder    int    `json:"sortOrder"`
+	TotalEmails  int    `json:"totalEmails"`
+	UnreadEmails int    `json:"unreadEmails"`
+}
+
+// EmailAddress is an address of an email header
+type EmailAddress struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// EmailBodyPart is a MIME part of an email (RFC 8621 section 4.1.4)
+type EmailBodyPart struct {
+	PartID      string          `json:"partId,omitempty"`
+	BlobID      string          `json:"blobId,omitempty"`
+	Size        int64           `json:"size"`
+	Name        string          `json:"name,omitempty"`
+	Type        string          `json:"type"`
+	Charset     string          `json:"charset,omitempty"`
+	Disposition string          `json:"disposition,omitempty"`
+	Cid         string          `json:"cid,omitempty"`
+	SubParts    []EmailBodyPart `json:"subParts,omitempty"`
+}
+
+// EmailBodyValue is the decoded text of a body part
+type EmailBodyValue struct {
+	Value       string `json:"value"`
+	IsTruncated bool   `json:"isTruncated"`
+}
+
+// Email is a JMAP email; only the requested properties are set
+type Email struct {
+	ID            string                    `json:"id"`
+	BlobID        string                    `json:"blobId,omitempty"`
+	ThreadID      string                    `json:"threadId,omitempty"`
+	MailboxIDs    map[string]bool           `json:"mailboxIds,omitempty"`
+	Keywords      map[string]bool           `json:"keywords,omitempty"`
+	Size          int64                     `json:"size,omitempty"`
+	ReceivedAt    time.Time                 `json:"receivedAt"`
+	MessageID     []string                  `json:"messageId,omitempty"`
+	InReplyTo     []string                  `json:"inReplyTo,omitempty"`
+	References    []string                  `json:"references,omitempty"`
+	From          []EmailAddress            `json:"from,omitempty"`
+	To            []EmailAddress            `json:"to,omitempty"`
+	Subject       string                    `json:"subject,omitempty"`
+	SentAt        *time.Time                `json:"sentAt,omitempty"`
+	HasAttachment bool                      `json:"hasAttachment,omitempty"`
+	Preview       string                    `json:"preview,omitempty"`
+	TextBody      []EmailBodyPart           `json:"textBody,omitempty"`
+	Attachments   []EmailBodyPart           `json:"attachments,omitempty"`
+	BodyValues    map[string]EmailBodyValue `json:"bodyValues,omitempty"`
+}
+
+// Identity is an address the user can send from
+type Identity struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// getResponse is the response of a /get call
+type getResponse[T any] struct {
+	State    string   `json:"state"`
+	List     []T      `json:"list"`
+	NotFound []string `json:"notFound"`
+}
+
+// QueryResult is the response of Email/query
+type QueryResult struct {
+	QueryState string   `json:"queryState"`
+	IDs        []string `json:"ids"`
+	Position   int      `json:"position"`
+	Total      int      `json:"total"`
+}
+
+// Changes is the response of Email/changes
+type Changes struct {
+	OldState       string   `json:"oldState"`
+	NewState       string   `json:"newState"`
+	HasMoreChanges bool     `json:"hasMoreChanges"`
+	Created        []string `json:"created"`
+	Updated        []string `json:"updated"`
+	Destroyed      []string `json:"destroyed"`
+}
+
+// SetError is why one object of a /set call failed
+type SetError struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// setResponse is the response of a /set call
+type setResponse struct {
+	NewState     string                    `json:"newState"`
+	Created      map[string]map[string]any `json:"created"`
+	NotCreated   map[string]SetError       `json:"notCreated"`
+	Updated      map[string]map[string]any `json:"updated"`
+	NotUpdated   map[string]SetError       `json:"notUpdated"`
+	NotDestroyed map[string]SetError       `json:"notDestroyed"`
+}
+
+// firstError returns the first failure of a /set call (nil if none)
+func (r *setResponse) firstError(method string) error {
+	for _, failures := range []map[string]SetError{r.NotCreated, r.NotUpdated, r.NotDestroyed} {
+		var ids = make([]string, 0, len(failures))
+		for id := range failures {
+			ids = append(ids, id)
+		}
+		if len(ids) > 0 {
+			sort.Strings(ids)
+			var e = failures[ids[0]]
+			return fmt.Errorf("jmap: %s %s: %s %s", method, ids[0], e.Type, e.Description)
+		}
+	}
+	return nil
+}
+
+// GetMailboxes returns every mailbox of the account
+func (c *Client) GetMailboxes(ctx context.Context) ([]Mailbox, error) {
+	var resp getResponse[Mailbox]
+	if err := c.Call(ctx, "Mailbox/get", map[string]interface{}{"ids": nil}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.List, nil
+}
+
+// EmailState returns the current Email state string (changes since it are
+// available through Email/changes)
+func (c *Client) EmailState(ctx context.Context) (string, error) {
+	var resp getResponse[Email]
+	if err := c.Call(ctx, "Email/get", map[string]interface{}{"ids": []string{}}, &resp); err != nil {
+		return "", err
+	}
+	return resp.State, nil
+}
+
+// QueryEmails returns email IDs matching filter, newest first (limit 0 =
+// every email, paging through the results)
+func (c *Client) QueryEmails(ctx context.Context, filter map[string]interface{}, limit int) ([]string, error) {
+	var ids []string
+	for {
+		var pageSize = 500
+		if limit > 0 && limit-len(ids) < pageSize {
+			pageSize = limit - len(ids)
+		}
+
+		var result QueryResult
+		var err = c.Call(ctx, "Email/query", map[string]interface{}{
+			"filter":   filter,
+			"sort":     []map[string]interface{}{{"property": "receivedAt", "isAscending": false}},
+			"position": len(ids),
+			"limit":    pageSize,
+		}, &result)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, result.IDs...)
+
+		if len(result.IDs) < pageSize || (limit > 0 && len(ids) >= limit) {
+			return ids, nil
+		}
+	}
+}
+
+// EmailChanges returns the emails created, updated and destroyed since
+// state, following hasMoreChanges, and the new state
+func (c *Client) EmailChanges(ctx context.Context, sinceState string) (*Changes, error) {
+	var all = &Changes{OldState: sinceState, NewState: sinceState}
+	for {
+		var page Changes
+		var err = c.Call(ctx, "Email/changes", map[string]interface{}{
+			"sinceState": all.NewState,
+			"maxChanges": 500,
+		}, &page)
+		if err != nil {
+			return nil, err
+		}
+		all.Created = append(all.Created, page.Created...)
+		all.Updated = append(all.Updated, page.Updated...)
+		all.Destroyed = append(all.Destroyed, page.Destroyed...)
+		all.NewState = page.NewState
+
+		if !page.HasMoreChanges || page.NewState == "" {
+			return all, nil
+		}
+	}
+}
+
+// emailProperties are fetched for every synced email
+var emailProperties = []string{
+	"id", "blobId", "threadId", "mailboxIds", "keywords", "size", "receivedAt",
+	"messageId", "inReplyTo", "references", "from", "to", "subject", "sentAt",
+	"hasAttachment", "preview", "textBody", "attachments", "bodyValues",
+}
+
+// bodyProperties are the properties of body parts
+var bodyProperties = []string{"partId", "blobId", "size", "name", "type", "charset", "disposition", "cid"}
+
+// maxBodyValueBytes caps the text body fetched with each email
+const maxBodyValueBytes = 256 * 1024
+
+// GetEmails fetches emails with the given properties (nil = the ones miau
+// syncs, with the text body). Emails that no longer exist are left out.
+func (c *Client) GetEmails(ctx context.Context, ids []string, properties []string) ([]Email, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var args = map[string]interface{}{"ids": ids}
+	if properties == nil {
+		args["properties"] = emailProperties
+		args["bodyProperties"] = bodyProperties
+		args["fetchTextBodyValues"] = true
+		args["maxBodyValueBytes"] = maxBodyValueBytes
+	} else {
+		args["properties"] = properties
+	}
+
+	var resp getResponse[Email]
+	if err := c.Call(ctx, "Email/get", args, &resp); err != nil {
+		return nil, err
+	}
+	return resp.List, nil
+}
+
+// UpdateEmail applies a patch (RFC 8620 section 5.3) to an email, e.g.
+// {"keywords/$seen": true}
+func (c *Client) UpdateEmail(ctx context.Context, id string, patch map[string]interface{}) error {
+	var resp setResponse
+	var err = c.Call(ctx, "Email/set", map[string]interface{}{
+		"update": map[string]interface{}{id: patch},
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	return resp.firstError("Email/set")
+}
+
+// GetIdentities returns the identities the user can send from
+func (c *Client) GetIdentities(ctx context.Context) ([]Identity, error) {
+	var resp getResponse[Identity]
+	if err := c.Call(ctx, "Identity/get", map[string]interface{}{"ids": nil}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.List, nil
+}
+
+// Submission is an outgoing email for Submit
+type Submission struct {
+	Raw        []byte   // RFC 5322 message
+	From       string   // envelope sender, also picks the identity
+	Recipients []string // envelope recipients (To, Cc and Bcc)
+}
+
+// Submit uploads the message, stores it in Drafts and sends it with
+// EmailSubmission/set, moving it to Sent on success. Import and submission
+// go in one API request, so a failed submission leaves the draft behind.
+func (c *Client) Submit(ctx context.Context, s *Submission) (string, error) {
+	if !c.CanSubmit(ctx) {
+		return "", fmt.Errorf("JMAP server does not support sending")
+	}
+
+	var identities, err = c.GetIdentities(ctx)
+	if err != nil {
+		return "", err
+	}
+	var identityID string
+	for _, identity := range identities {
+		if strings.EqualFold(identity.Email, s.From) || (identityID == "" && strings.HasPrefix(identity.Email, "*@")) {
+			identityID = identity.ID
+		}
+	}
+	if identityID == "" {
+		return "", fmt.Errorf("no JMAP identity for %s", s.From)
+	}
+
+	var mailboxes, err2 = c.GetMailboxes(ctx)
+	if err2 != nil {
+		return "", err2
+	}
+	var draftsID, sentID string
+	for _, m := range mailboxes {
+		switch m.Role {
+		case "drafts":
+			draftsID = m.ID
+		case "sent":
+			sentID = m.ID
+		}
+	}
+	if draftsID == "" {
+		draftsID = sentID
+	}
+	if draftsID == "" {
+		return "", fmt.Errorf("no drafts or sent mailbox")
+	}
+
+	var blobID, err3 = c.Upload(ctx, "message/rfc822", s.Raw)
+	if err3 != nil {
+		return "", err3
+	}
+
+	var rcptTo = make([]map[string]string, 0, len(s.Recipients))
+	for _, rcpt := range s.Recipients {
+		rcptTo = append(rcptTo, map[string]string{"email": rcpt})
+	}
+	var onSuccess = map[string]interface{}{"keywords/$draft": nil}
+	if sentID != "" && sentID != draftsID {
+		onSuccess["mailboxIds/"+draftsID] = nil
+		onSuccess["mailboxIds/"+sentID] = true
+	}
+
+	var imported struct {
+		Created    map[string]Email    `json:"created"`
+		NotCreated map[string]SetError `json:"notCreated"`
+	}
+	var submitted setResponse
+	var r = NewRequest(CapabilitySubmission)
+	r.Add("Email/import", map[string]interface{}{
+		"emails": map[string]interface{}{
+			"draft": map[string]interface{}{
+				"blobId":     blobID,
+				"mailboxIds": map[string]bool{draftsID: true},
+				"keywords":   map[string]bool{"$draft": true, "$seen": true},
+			},
+		},
+	}, &imported)
+	r.Add("EmailSubmission/set", map[string]interface{}{
+		"create": map[string]interface{}{
+			"send": map[string]interface{}{
+				"identityId": identityID,
+				"emailId":    "#draft",
+				"envelope": map[string]interface{}{
+					"mailFrom": map[string]string{"email": s.From},
+					"rcptTo":   rcptTo,
+				},
+			},
+		},
+		"onSuccessUpdateEmail": map[string]interface{}{"#send": onSuccess},
+	}, &submitted)
+	if err := c.Do(ctx, r); err != nil {
+		return "", err
+	}
+
+	if e, ok := imported.NotCreated["draft"]; ok {
+		return "", fmt.Errorf("jmap: failed to store message: %s %s", e.Type, e.Description)
+	}
+	if err := submitted.firstError("EmailSubmission/set"); err != nil {
+		return "", err
+	}
+	return imported.Created["draft"].ID, nil
+}
+
+// mailboxPaths returns the full path of each mailbox ("Parent/Child"),
+// with the inbox named INBOX like on IMAP servers
+func mailboxPaths(mailboxes []Mailbox) map[string]string {
+	var byID = make(map[string]Mailbox, len(mailboxes))
+	for _, m := range mailboxes {
+		byID[m.ID] = m
+	}
+
+	var paths = make(map[string]string, len(mailboxes))
+	for _, m := range mailboxes {
+		if m.Role == "inbox" {
+			paths[m.ID] = "INBOX"
+			continue
+		}
+		var names = []string{m.Name}
+		var seen = map[string]bool{m.ID: true}
+		for parent := m.ParentID; parent != "" && !seen[parent]; parent = byID[parent].ParentID {
+			seen[parent] = true
+			names = append([]string{byID[parent].Name}, names...)
+		}
+		paths[m.ID] = strings.Join(names, "/")
+	}
+	return paths
+}
@@ -0,0 +1,130 @@
+package pgp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/mail"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/opik/miau/internal/email/message"
+)
+
+// Autocrypt is a parsed Autocrypt header (Autocrypt Level 1, section 2.1).
+type Autocrypt struct {
+	Addr          string
+	PreferEncrypt bool
+	KeyData       []byte
+}
+
+// ParseAutocrypt parses the value of an Autocrypt header. Unknown attributes
+// are ignored unless they are critical (not prefixed with an underscore).
+func ParseAutocrypt(value string) (*Autocrypt, error) {
+	var ac Autocrypt
+	for _, attr := range strings.Split(value, ";") {
+		var name, val, ok = strings.Cut(strings.TrimSpace(attr), "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "addr":
+			ac.Addr = strings.ToLower(strings.TrimSpace(val))
+		case "prefer-encrypt":
+			ac.PreferEncrypt = strings.TrimSpace(val) == "mutual"
+		case "keydata":
+			var data, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(val), ""))
+			if err != nil {
+				return nil, errors.New("pgp: invalid autocrypt keydata")
+			}
+			ac.KeyData = data
+		default:
+			if !strings.HasPrefix(strings.TrimSpace(name), "_") {
+				return nil, errors.New("pgp: unknown critical autocrypt attribute " + name)
+			}
+		}
+	}
+	if ac.Addr == "" || len(ac.KeyData) == 0 {
+		return nil, errors.New("pgp: incomplete autocrypt header")
+	}
+	return &ac, nil
+}
+
+// AutocryptHeader formats the Autocrypt header value advertising the public
+// key of e for addr, folded so no line exceeds 78 characters.
+func AutocryptHeader(addr string, e *openpgp.Entity) (string, error) {
+	var buf bytes.Buffer
+	if err := e.Serialize(&buf); err != nil {
+		return "", err
+	}
+	var data = base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var sb strings.Builder
+	sb.WriteString("addr=" + addr + "; prefer-encrypt=mutual; keydata=")
+	for len(data) > 0 {
+		var n = min(len(data), 72)
+		sb.WriteString("\r\n " + data[:n])
+		data = data[n:]
+	}
+	return sb.String(), nil
+}
+
+// AddAutocrypt prepends an Autocrypt header for addr to a raw message.
+func AddAutocrypt(raw []byte, addr string, e *openpgp.Entity) ([]byte, error) {
+	var value, err = AutocryptHeader(addr, e)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("Autocrypt: "+value+"\r\n"), message.Canonicalize(raw)...), nil
+}
+
+// Discover imports the key from the Autocrypt header of an incoming message.
+// The header is only trusted when it names the single From address and the
+// key carries a user ID for it. Keys already in the keyring are updated;
+// secret keys are never touched. It reports whether the keyring changed.
+func (k *Keyring) Discover(raw []byte) (bool, error) {
+	var msg, err = mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return false, nil
+	}
+	var values = msg.Header["Autocrypt"]
+	if len(values) != 1 {
+		return false, nil
+	}
+	from, err := mail.ParseAddressList(msg.Header.Get("From"))
+	if err != nil || len(from) != 1 {
+		return false, nil
+	}
+
+	ac, err := ParseAutocrypt(values[0])
+	if err != nil {
+		return false, err
+	}
+	if !strings.EqualFold(ac.Addr, from[0].Address) {
+		return false, nil
+	}
+
+	list, err := openpgp.ReadKeyRing(bytes.NewReader(ac.KeyData))
+	if err != nil {
+		return false, err
+	}
+	if len(list) != 1 || !hasEmail(list[0], ac.Addr) {
+		return false, nil
+	}
+	var e = list[0]
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if i := k.index(e.PrimaryKey.Fingerprint); i >= 0 {
+		var current bytes.Buffer
+		var incoming bytes.Buffer
+		k.entities[i].Serialize(&current)
+		e.Serialize(&incoming)
+		if k.entities[i].PrivateKey != nil || bytes.Equal(current.Bytes(), incoming.Bytes()) {
+			return false, nil
+		}
+	}
+	k.merge(openpgp.EntityList{e})
+	return true, k.save()
+}
@@ -0,0 +1,536 @@
+// Package pgp implements OpenPGP for miau: a local keyring of ASCII-armored
+// keys, PGP/MIME (RFC 3156) signing, encryption, decryption and verification,
+// and key discovery from Autocrypt headers.
+package pgp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+const (
+	publicFile = "pubring.asc"
+	secretFile = "secring.asc"
+)
+
+var (
+	ErrKeyNotFound = errors.New("pgp: key not found")
+	ErrNoSecretKey = errors.New("pgp: no secret key")
+	ErrPassphrase  = errors.New("pgp: wrong or missing passphrase")
+)
+
+// Key describes a key in the keyring.
+type Key struct {
+	Fingerprint string // uppercase hex
+	KeyID       string // last 16 hex digits of the fingerprint
+	UserIDs     []string
+	Emails      []string // lowercased
+	Secret      bool
+	CanEncrypt  bool
+	Revoked     bool
+	Created     time.Time
+	Expires     time.Time // zero when the key never expires
+}
+
+// Keyring is the local OpenPGP keyring. Public keys live in pubring.asc and
+// secret keys in secring.asc, both ASCII-armored, so they can be inspected
+// and edited with gpg. Secret keys stay encrypted on disk and in memory;
+// operations that need them work on unlocked copies.
+type Keyring struct {
+	mu       sync.RWMutex
+	dir      string
+	entities openpgp.EntityList
+}
+
+// OpenKeyring loads the keyring stored in dir. Missing files are treated as
+// an empty keyring; the directory is created on the first save.
+func OpenKeyring(dir string) (*Keyring, error) {
+	var k = &Keyring{dir: dir}
+	// Secret keys are loaded first so their public copies are skipped.
+	for _, name := range []string{secretFile, publicFile} {
+		var data, err = os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list, err := readArmoredKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("pgp: %s: %w", name, err)
+		}
+		for _, e := range list {
+			if k.index(e.PrimaryKey.Fingerprint) < 0 {
+				k.entities = append(k.entities, e)
+			}
+		}
+	}
+	return k, nil
+}
+
+// Keys lists every key, secret keys first, then by primary user ID.
+func (k *Keyring) Keys() []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var keys = make([]Key, 0, len(k.entities))
+	for _, e := range k.entities {
+		keys = append(keys, describe(e))
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].Secret != keys[j].Secret {
+			return keys[i].Secret
+		}
+		return firstOf(keys[i].UserIDs) < firstOf(keys[j].UserIDs)
+	})
+	return keys
+}
+
+// Import merges ASCII-armored keys into the keyring and saves it. A key that
+// is already present is replaced by the imported copy, except that a public
+// key never replaces a secret one. It returns the keys added or updated.
+func (k *Keyring) Import(armored []byte) ([]Key, error) {
+	var list, err = readArmoredKeys(armored)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var changed = k.merge(list)
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	if err := k.save(); err != nil {
+		return nil, err
+	}
+	var keys = make([]Key, 0, len(changed))
+	for _, e := range changed {
+		keys = append(keys, describe(e))
+	}
+	return keys, nil
+}
+
+// Export returns the ASCII-armored keys matching query (a fingerprint, key ID
+// or email). With secret set it exports the secret keys, still protected by
+// their passphrase.
+func (k *Keyring) Export(query string, secret bool) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var found = k.find(query)
+	if len(found) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	var buf bytes.Buffer
+	var blockType = openpgp.PublicKeyType
+	if secret {
+		blockType = openpgp.PrivateKeyType
+	}
+	w, err := armor.Encode(&buf, blockType, nil)
+	if err != nil {
+		return nil, err
+	}
+	var n int
+	for _, e := range found {
+		if secret {
+			if e.PrivateKey == nil {
+				continue
+			}
+			err = e.SerializePrivateWithoutSigning(w, nil)
+		} else {
+			err = e.Serialize(w)
+		}
+		if err != nil {
+			return nil, err
+		}
+		n++
+	}
+	if n == 0 {
+		return nil, ErrNoSecretKey
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// Delete removes the key with the given fingerprint and saves the keyring.
+func (k *Keyring) Delete(fingerprint string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var fp = normalizeHex(fingerprint)
+	for i, e := range k.entities {
+		if fingerprintOf(e) == fp {
+			k.entities = append(k.entities[:i], k.entities[i+1:]...)
+			return k.save()
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// Recipients picks an encryption key for each address, preferring the newest
+// usable key. Addresses without one are returned in missing.
+func (k *Keyring) Recipients(addrs []string) (to openpgp.EntityList, missing []string) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var now = time.Now()
+	for _, addr := range addrs {
+		var best *openpgp.Entity
+		for _, e := range k.entities {
+			if !hasEmail(e, addr) {
+				continue
+			}
+			if _, ok := e.EncryptionKey(now); !ok {
+				continue
+			}
+			if best == nil || e.PrimaryKey.CreationTime.After(best.PrimaryKey.CreationTime) {
+				best = e
+			}
+		}
+		if best == nil {
+			missing = append(missing, addr)
+			continue
+		}
+		to = append(to, best)
+	}
+	return to, missing
+}
+
+// Signer returns an unlocked copy of the secret key matching query (a
+// fingerprint, key ID or email), ready to sign and decrypt.
+func (k *Keyring) Signer(query string, passphrase []byte) (*openpgp.Entity, error) {
+	k.mu.RLock()
+	var found = k.find(query)
+	k.mu.RUnlock()
+
+	var now = time.Now()
+	for _, e := range found {
+		if e.PrivateKey == nil {
+			continue
+		}
+		if _, ok := e.SigningKey(now); !ok {
+			continue
+		}
+		e, err := clone(e)
+		if err != nil {
+			return nil, err
+		}
+		if err := unlock(e, passphrase); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	if len(found) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return nil, ErrNoSecretKey
+}
+
+// Own returns a locked copy of the secret key matching query, enough to
+// advertise its public part without a passphrase.
+func (k *Keyring) Own(query string) (*openpgp.Entity, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, e := range k.find(query) {
+		if e.PrivateKey != nil {
+			return clone(e)
+		}
+	}
+	return nil, ErrNoSecretKey
+}
+
+// snapshot returns a copy of the keyring whose secret keys can be unlocked
+// without touching the stored entities.
+func (k *Keyring) snapshot() (openpgp.EntityList, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var list = make(openpgp.EntityList, 0, len(k.entities))
+	for _, e := range k.entities {
+		var c, err = clone(e)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// merge adds or replaces entities and returns the ones that changed.
+// Callers must hold the write lock.
+func (k *Keyring) merge(list openpgp.EntityList) []*openpgp.Entity {
+	var changed []*openpgp.Entity
+	for _, e := range list {
+		var i = k.index(e.PrimaryKey.Fingerprint)
+		switch {
+		case i < 0:
+			k.entities = append(k.entities, e)
+		case e.PrivateKey == nil && k.entities[i].PrivateKey != nil:
+			continue
+		default:
+			k.entities[i] = e
+		}
+		changed = append(changed, e)
+	}
+	return changed
+}
+
+func (k *Keyring) index(fingerprint []byte) int {
+	for i, e := range k.entities {
+		if bytes.Equal(e.PrimaryKey.Fingerprint, fingerprint) {
+			return i
+		}
+	}
+	return -1
+}
+
+// find matches a full fingerprint, a key ID suffix of at least 8 hex digits
+// or an email address.
+func (k *Keyring) find(query string) []*openpgp.Entity {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil
+	}
+	var found []*openpgp.Entity
+	if strings.Contains(query, "@") {
+		for _, e := range k.entities {
+			if hasEmail(e, query) {
+				found = append(found, e)
+			}
+		}
+		return found
+	}
+	var hex = normalizeHex(query)
+	if len(hex) < 8 {
+		return nil
+	}
+	for _, e := range k.entities {
+		if strings.HasSuffix(fingerprintOf(e), hex) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// save writes both files atomically. Callers must hold the write lock.
+func (k *Keyring) save() error {
+	if err := os.MkdirAll(k.dir, 0700); err != nil {
+		return err
+	}
+
+	var public, secret bytes.Buffer
+	pw, err := armor.Encode(&public, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return err
+	}
+	sw, err := armor.Encode(&secret, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		return err
+	}
+	var secrets int
+	for _, e := range k.entities {
+		if err := e.Serialize(pw); err != nil {
+			return err
+		}
+		if e.PrivateKey != nil {
+			if err := e.SerializePrivateWithoutSigning(sw, nil); err != nil {
+				return err
+			}
+			secrets++
+		}
+	}
+	if err := pw.Close(); err != nil {
+		return err
+	}
+	if err := sw.Close(); err != nil {
+		return err
+	}
+
+	if len(k.entities) == 0 {
+		public.Reset()
+	}
+	if secrets == 0 {
+		secret.Reset()
+	}
+	if err := writeFile(filepath.Join(k.dir, publicFile), public.Bytes(), 0644); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(k.dir, secretFile), secret.Bytes(), 0600)
+}
+
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	if len(data) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var tmp = path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readArmoredKeys reads every armored key block in data, so files holding
+// several concatenated exports are accepted.
+func readArmoredKeys(data []byte) (openpgp.EntityList, error) {
+	var list openpgp.EntityList
+	var marker = []byte("-----BEGIN PGP ")
+	for {
+		var start = bytes.Index(data, marker)
+		if start < 0 {
+			break
+		}
+		data = data[start:]
+		var next = bytes.Index(data[len(marker):], marker)
+		var block = data
+		if next >= 0 {
+			block = data[:len(marker)+next]
+		}
+		el, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(block))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, el...)
+		if next < 0 {
+			break
+		}
+		data = data[len(marker)+next:]
+	}
+	if list == nil && len(bytes.TrimSpace(data)) > 0 {
+		return nil, errors.New("pgp: no ASCII-armored key found")
+	}
+	return list, nil
+}
+
+// clone deep-copies an entity by serializing and parsing it again.
+func clone(e *openpgp.Entity) (*openpgp.Entity, error) {
+	var buf bytes.Buffer
+	var err error
+	if e.PrivateKey != nil {
+		err = e.SerializePrivateWithoutSigning(&buf, nil)
+	} else {
+		err = e.Serialize(&buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openpgp.ReadEntity(packet.NewReader(&buf))
+}
+
+func unlock(e *openpgp.Entity, passphrase []byte) error {
+	if e.PrivateKey == nil || !isLocked(e) {
+		return nil
+	}
+	if len(passphrase) == 0 {
+		return ErrPassphrase
+	}
+	if err := e.DecryptPrivateKeys(passphrase); err != nil {
+		return ErrPassphrase
+	}
+	return nil
+}
+
+func isLocked(e *openpgp.Entity) bool {
+	if e.PrivateKey != nil && e.PrivateKey.Encrypted {
+		return true
+	}
+	for _, sub := range e.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(e *openpgp.Entity) Key {
+	var now = time.Now()
+	var fp = fingerprintOf(e)
+	var key = Key{
+		Fingerprint: fp,
+		KeyID:       fp[max(len(fp)-16, 0):],
+		Secret:      e.PrivateKey != nil,
+		Revoked:     e.Revoked(now),
+		Created:     e.PrimaryKey.CreationTime,
+	}
+	_, key.CanEncrypt = e.EncryptionKey(now)
+
+	var names = make([]string, 0, len(e.Identities))
+	for name := range e.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if primary := e.PrimaryIdentity(); primary != nil {
+		key.UserIDs = append(key.UserIDs, primary.Name)
+	}
+	for _, name := range names {
+		var ident = e.Identities[name]
+		if len(key.UserIDs) == 0 || name != key.UserIDs[0] {
+			key.UserIDs = append(key.UserIDs, name)
+		}
+		if ident.UserId != nil && ident.UserId.Email != "" {
+			key.Emails = appendUnique(key.Emails, strings.ToLower(ident.UserId.Email))
+		}
+	}
+
+	if sig, _ := e.PrimarySelfSignature(); sig != nil && sig.KeyLifetimeSecs != nil && *sig.KeyLifetimeSecs > 0 {
+		key.Expires = key.Created.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+	}
+	return key
+}
+
+func hasEmail(e *openpgp.Entity, addr string) bool {
+	for _, ident := range e.Identities {
+		if ident.UserId != nil && strings.EqualFold(ident.UserId.Email, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func fingerprintOf(e *openpgp.Entity) string {
+	return fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+}
+
+func normalizeHex(s string) string {
+	s = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+	return strings.ReplaceAll(s, " ", "")
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func firstOf(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return list[0]
+}
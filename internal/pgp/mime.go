@@ -0,0 +1,357 @@
+package pgp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/opik/miau/internal/email/message"
+)
+
+// Status is the outcome of checking a signature.
+type Status string
+
+const (
+	StatusNone       Status = ""
+	StatusValid      Status = "valid"
+	StatusInvalid    Status = "invalid"
+	StatusUnknownKey Status = "unknown_key"
+	StatusMismatch   Status = "mismatch" // valid, but the key does not belong to the From address
+)
+
+// Result is what Open found in a message.
+type Result struct {
+	// Raw is the message with the PGP/MIME layers removed: the original
+	// outer headers followed by the decrypted or signed entity. It is the
+	// original message when nothing could be opened.
+	Raw       []byte
+	Encrypted bool
+	Signature Status
+	Signer    string // primary user ID of the signing key
+	KeyID     string
+	Error     string
+}
+
+// Unlocker returns the passphrase for a secret key, or nil when none is known.
+type Unlocker func(k Key) []byte
+
+// Sign wraps a complete RFC 5322 message in a multipart/signed entity
+// (RFC 3156 section 5). The signer must be unlocked.
+func Sign(raw []byte, signer *openpgp.Entity) ([]byte, error) {
+	var outer, inner = message.SplitEntity(message.Canonicalize(raw))
+
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, signer, bytes.NewReader(inner), nil); err != nil {
+		return nil, err
+	}
+
+	var boundary = newBoundary()
+	var body bytes.Buffer
+	body.WriteString("This is an OpenPGP/MIME signed message (RFC 4880 and 3156)\r\n")
+	body.WriteString("--" + boundary + "\r\n")
+	body.Write(inner)
+	body.WriteString("\r\n--" + boundary + "\r\n")
+	body.WriteString("Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n")
+	body.WriteString("Content-Description: OpenPGP digital signature\r\n")
+	body.WriteString("Content-Disposition: attachment; filename=\"signature.asc\"\r\n\r\n")
+	body.Write(message.Canonicalize(sig.Bytes()))
+	body.WriteString("\r\n--" + boundary + "--\r\n")
+
+	var contentType = mime.FormatMediaType("multipart/signed", map[string]string{
+		"boundary": boundary,
+		"micalg":   "pgp-sha256",
+		"protocol": "application/pgp-signature",
+	})
+	return message.Assemble(outer, contentType, body.Bytes()), nil
+}
+
+// Encrypt wraps a complete RFC 5322 message in a multipart/encrypted entity
+// (RFC 3156 section 4). When signer is set the content is signed and
+// encrypted in one pass, and the signer can also decrypt the result.
+func Encrypt(raw []byte, to openpgp.EntityList, signer *openpgp.Entity) ([]byte, error) {
+	if len(to) == 0 {
+		return nil, errors.New("pgp: no recipients")
+	}
+	var outer, inner = message.SplitEntity(message.Canonicalize(raw))
+
+	var recipients = to
+	if signer != nil {
+		recipients = append(openpgp.EntityList{signer}, to...)
+	}
+
+	var armored bytes.Buffer
+	aw, err := armor.Encode(&armored, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	pw, err := openpgp.Encrypt(aw, recipients, signer, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pw.Write(inner); err != nil {
+		return nil, err
+	}
+	if err := pw.Close(); err != nil {
+		return nil, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+
+	var boundary = newBoundary()
+	var body bytes.Buffer
+	body.WriteString("This is an OpenPGP/MIME encrypted message (RFC 4880 and 3156)\r\n")
+	body.WriteString("--" + boundary + "\r\n")
+	body.WriteString("Content-Type: application/pgp-encrypted\r\n")
+	body.WriteString("Content-Description: PGP/MIME version identification\r\n\r\n")
+	body.WriteString("Version: 1\r\n\r\n")
+	body.WriteString("--" + boundary + "\r\n")
+	body.WriteString("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n")
+	body.WriteString("Content-Description: OpenPGP encrypted message\r\n")
+	body.WriteString("Content-Disposition: inline; filename=\"encrypted.asc\"\r\n\r\n")
+	body.Write(message.Canonicalize(armored.Bytes()))
+	body.WriteString("\r\n--" + boundary + "--\r\n")
+
+	var contentType = mime.FormatMediaType("multipart/encrypted", map[string]string{
+		"boundary": boundary,
+		"protocol": "application/pgp-encrypted",
+	})
+	return message.Assemble(outer, contentType, body.Bytes()), nil
+}
+
+// Open decrypts and verifies a PGP/MIME message. Messages that are neither
+// signed nor encrypted come back unchanged with an empty status. Failures to
+// decrypt or verify are reported in Result.Error rather than as errors, so
+// the caller can still show the message.
+func (k *Keyring) Open(raw []byte, unlocker Unlocker) (*Result, error) {
+	raw = message.Canonicalize(raw)
+	var res = &Result{Raw: raw}
+
+	var outer, _ = message.SplitEntity(raw)
+	var mediaType, params, err = mime.ParseMediaType(message.HeaderValue(raw, "Content-Type"))
+	if err != nil {
+		return res, nil
+	}
+
+	keyring, err := k.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case mediaType == "multipart/encrypted" && strings.EqualFold(params["protocol"], "application/pgp-encrypted"):
+		res.Encrypted = true
+		var inner, err = decrypt(raw, params["boundary"], keyring, unlocker, res)
+		if err != nil {
+			res.Error = err.Error()
+			return res, nil
+		}
+		res.Raw = message.AssembleEntity(outer, inner)
+
+		// Signed-then-encrypted messages carry a multipart/signed entity.
+		if res.Signature == StatusNone {
+			mediaType, params, err = mime.ParseMediaType(message.HeaderValue(res.Raw, "Content-Type"))
+			if err == nil && mediaType == "multipart/signed" {
+				verify(res.Raw, params["boundary"], keyring, res)
+			}
+		}
+	case mediaType == "multipart/signed" && strings.EqualFold(params["protocol"], "application/pgp-signature"):
+		verify(raw, params["boundary"], keyring, res)
+	default:
+		return res, nil
+	}
+
+	if res.Signature == StatusValid {
+		checkSender(raw, keyring, res)
+	}
+	return res, nil
+}
+
+func decrypt(raw []byte, boundary string, keyring openpgp.EntityList, unlocker Unlocker, res *Result) ([]byte, error) {
+	var _, body = message.SplitMessage(raw)
+	var mr = multipart.NewReader(bytes.NewReader(body), boundary)
+	var payload []byte
+	for {
+		var part, err = mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var mediaType, _, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if mediaType == "application/octet-stream" {
+			if payload, err = io.ReadAll(part); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if payload == nil {
+		return nil, errors.New("pgp: encrypted part not found")
+	}
+
+	block, err := armor.Decode(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	var prompt = func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		var unlocked bool
+		for _, key := range keys {
+			if key.Entity == nil || unlocker == nil {
+				continue
+			}
+			var pass = unlocker(describe(key.Entity))
+			if len(pass) > 0 && key.Entity.DecryptPrivateKeys(pass) == nil {
+				unlocked = true
+			}
+		}
+		if !unlocked {
+			return nil, ErrPassphrase
+		}
+		return nil, nil
+	}
+
+	md, err := openpgp.ReadMessage(block.Body, keyring, prompt, nil)
+	if err != nil {
+		if errors.Is(err, pgperrors.ErrKeyIncorrect) {
+			return nil, ErrNoSecretKey
+		}
+		return nil, err
+	}
+	inner, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if md.IsSigned {
+		res.KeyID = fmt.Sprintf("%016X", md.SignedByKeyId)
+		switch {
+		case md.SignedBy == nil:
+			res.Signature = StatusUnknownKey
+		case md.SignatureError != nil:
+			res.Signature = StatusInvalid
+			res.Error = md.SignatureError.Error()
+		default:
+			res.Signature = StatusValid
+		}
+		if md.SignedBy != nil {
+			res.Signer = primaryName(md.SignedBy.Entity)
+		}
+	}
+	return message.Canonicalize(inner), nil
+}
+
+func verify(raw []byte, boundary string, keyring openpgp.EntityList, res *Result) {
+	var outer, body = message.SplitMessage(raw)
+	var signed, rest, ok = message.FirstPart(body, boundary)
+	if !ok {
+		res.Signature = StatusInvalid
+		res.Error = "malformed multipart/signed message"
+		return
+	}
+
+	var sig []byte
+	var mr = multipart.NewReader(bytes.NewReader(rest), boundary)
+	for {
+		var part, err = mr.NextPart()
+		if err != nil {
+			break
+		}
+		var mediaType, _, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if mediaType == "application/pgp-signature" {
+			sig, _ = io.ReadAll(part)
+			break
+		}
+	}
+	if sig == nil {
+		res.Signature = StatusInvalid
+		res.Error = "signature part not found"
+		return
+	}
+	res.KeyID = issuerOf(sig)
+
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(signed), bytes.NewReader(sig), nil)
+	switch {
+	case errors.Is(err, pgperrors.ErrUnknownIssuer):
+		res.Signature = StatusUnknownKey
+	case err != nil:
+		res.Signature = StatusInvalid
+		res.Error = err.Error()
+	default:
+		res.Signature = StatusValid
+	}
+	if signer != nil {
+		res.Signer = primaryName(signer)
+	}
+
+	res.Raw = message.AssembleEntity(outer, signed)
+}
+
+// checkSender downgrades a valid signature whose key has no user ID for the
+// From address.
+func checkSender(raw []byte, keyring openpgp.EntityList, res *Result) {
+	from, err := mail.ParseAddress(message.HeaderValue(raw, "From"))
+	if err != nil {
+		return
+	}
+	var id = normalizeHex(res.KeyID)
+	for _, e := range keyring {
+		for _, key := range append([]*packet.PublicKey{e.PrimaryKey}, subkeysOf(e)...) {
+			if key.KeyIdString() == id {
+				if !hasEmail(e, from.Address) {
+					res.Signature = StatusMismatch
+				}
+				return
+			}
+		}
+	}
+}
+
+func subkeysOf(e *openpgp.Entity) []*packet.PublicKey {
+	var keys = make([]*packet.PublicKey, 0, len(e.Subkeys))
+	for _, sub := range e.Subkeys {
+		keys = append(keys, sub.PublicKey)
+	}
+	return keys
+}
+
+func issuerOf(armored []byte) string {
+	block, err := armor.Decode(bytes.NewReader(armored))
+	if err != nil {
+		return ""
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return ""
+	}
+	if sig, ok := p.(*packet.Signature); ok && sig.IssuerKeyId != nil {
+		return fmt.Sprintf("%016X", *sig.IssuerKeyId)
+	}
+	return ""
+}
+
+func primaryName(e *openpgp.Entity) string {
+	if ident := e.PrimaryIdentity(); ident != nil {
+		return ident.Name
+	}
+	return ""
+}
+
+func newBoundary() string {
+	var b = make([]byte, 16)
+	rand.Read(b)
+	return "miau-pgp-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,336 @@
+package pgp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+const plainMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: Bob <bob@example.com>\r\n" +
+	"Subject: Quarterly report\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Numbers are in.  \r\n" +
+	"-- \r\n" +
+	"Alice\r\n"
+
+func newEntity(t *testing.T, name, email string) *openpgp.Entity {
+	t.Helper()
+	var e, err = openpgp.NewEntity(name, "", email, &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	return e
+}
+
+func armored(t *testing.T, e *openpgp.Entity, secret bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var blockType = openpgp.PublicKeyType
+	if secret {
+		blockType = openpgp.PrivateKeyType
+	}
+	w, err := armor.Encode(&buf, blockType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret {
+		err = e.SerializePrivateWithoutSigning(w, nil)
+	} else {
+		err = e.Serialize(w)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+// keyrings returns Alice's and Bob's keyrings: each holds its own secret
+// key and the other's public key.
+func keyrings(t *testing.T) (alice, bob *Keyring, aliceKey, bobKey *openpgp.Entity) {
+	t.Helper()
+	aliceKey = newEntity(t, "Alice", "alice@example.com")
+	bobKey = newEntity(t, "Bob", "bob@example.com")
+
+	var err error
+	if alice, err = OpenKeyring(filepath.Join(t.TempDir(), "alice")); err != nil {
+		t.Fatal(err)
+	}
+	if bob, err = OpenKeyring(filepath.Join(t.TempDir(), "bob")); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		k    *Keyring
+		data []byte
+	}{
+		{alice, armored(t, aliceKey, true)},
+		{alice, armored(t, bobKey, false)},
+		{bob, armored(t, bobKey, true)},
+		{bob, armored(t, aliceKey, false)},
+	} {
+		if _, err := step.k.Import(step.data); err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+	}
+	return alice, bob, aliceKey, bobKey
+}
+
+func TestKeyringPersistence(t *testing.T) {
+	var dir = t.TempDir()
+	var k, err = OpenKeyring(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alice = newEntity(t, "Alice", "alice@example.com")
+	if err := alice.EncryptPrivateKeys([]byte("secret"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A public copy first, then the secret key replaces it.
+	if _, err := k.Import(armored(t, alice, false)); err != nil {
+		t.Fatal(err)
+	}
+	added, err := k.Import(armored(t, alice, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || !added[0].Secret {
+		t.Fatalf("Import secret = %+v", added)
+	}
+	// A public copy never replaces a secret key.
+	if added, _ := k.Import(armored(t, alice, false)); len(added) != 0 {
+		t.Fatalf("public import replaced secret key: %+v", added)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, secretFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("secring mode = %v", info.Mode().Perm())
+	}
+
+	reopened, err := OpenKeyring(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys = reopened.Keys()
+	if len(keys) != 1 {
+		t.Fatalf("Keys = %+v", keys)
+	}
+	var key = keys[0]
+	if !key.Secret || !key.CanEncrypt || key.Emails[0] != "alice@example.com" || len(key.KeyID) != 16 {
+		t.Errorf("key = %+v", key)
+	}
+
+	if _, err := reopened.Signer("alice@example.com", []byte("wrong")); err != ErrPassphrase {
+		t.Errorf("Signer with wrong passphrase: %v", err)
+	}
+	if _, err := reopened.Signer(key.KeyID, []byte("secret")); err != nil {
+		t.Errorf("Signer: %v", err)
+	}
+	// Unlocking works on a copy; the stored key stays encrypted.
+	secret, err := reopened.Export(key.Fingerprint, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !list[0].PrivateKey.Encrypted {
+		t.Error("exported secret key is not encrypted")
+	}
+
+	if err := reopened.Delete(key.Fingerprint); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Export("alice@example.com", false); err != ErrKeyNotFound {
+		t.Errorf("Export after Delete: %v", err)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	var alice, bob, aliceKey, _ = keyrings(t)
+	signer, err := alice.Signer("alice@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := Sign([]byte(plainMessage), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(signed, []byte("multipart/signed")) || !bytes.Contains(signed, []byte("Subject: Quarterly report\r\n")) {
+		t.Fatalf("unexpected signed message:\n%s", signed)
+	}
+
+	res, err := bob.Open(signed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Signature != StatusValid || res.Encrypted || res.Signer != "Alice <alice@example.com>" {
+		t.Errorf("Open = %+v", res)
+	}
+	if !bytes.Contains(res.Raw, []byte("Numbers are in.  \r\n")) || bytes.Contains(res.Raw, []byte("pgp-signature")) {
+		t.Errorf("opened message:\n%s", res.Raw)
+	}
+
+	// Tampering with the signed part breaks the signature.
+	var tampered = bytes.Replace(signed, []byte("Numbers are in."), []byte("Numbers are out"), 1)
+	if res, _ := bob.Open(tampered, nil); res.Signature != StatusInvalid {
+		t.Errorf("tampered signature = %q", res.Signature)
+	}
+
+	// Stored with bare LF line endings it still verifies.
+	var lf = bytes.ReplaceAll(signed, []byte("\r\n"), []byte("\n"))
+	if res, _ := bob.Open(lf, nil); res.Signature != StatusValid {
+		t.Errorf("LF signature = %q (%s)", res.Signature, res.Error)
+	}
+
+	// A key for another address is reported as a mismatch.
+	var forged = bytes.Replace(signed, []byte("From: Alice <alice@example.com>"), []byte("From: Eve <eve@example.com>"), 1)
+	if res, _ := bob.Open(forged, nil); res.Signature != StatusMismatch {
+		t.Errorf("forged From = %q", res.Signature)
+	}
+
+	// Without Alice's key the signature cannot be checked.
+	var stranger, _ = OpenKeyring(t.TempDir())
+	res, _ = stranger.Open(signed, nil)
+	var signingKey, _ = aliceKey.SigningKey(aliceKey.PrimaryKey.CreationTime)
+	if res.Signature != StatusUnknownKey || res.KeyID != signingKey.PublicKey.KeyIdString() {
+		t.Errorf("unknown key = %+v", res)
+	}
+}
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	var alice, bob, _, _ = keyrings(t)
+	to, missing := alice.Recipients([]string{"bob@example.com", "carol@example.com"})
+	if len(to) != 1 || len(missing) != 1 || missing[0] != "carol@example.com" {
+		t.Fatalf("Recipients = %d keys, missing %v", len(to), missing)
+	}
+	signer, err := alice.Signer("alice@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := Encrypt([]byte(plainMessage), to, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted, []byte("Numbers are in")) {
+		t.Fatal("plaintext leaked into the encrypted message")
+	}
+
+	res, err := bob.Open(encrypted, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Encrypted || res.Signature != StatusValid || res.Error != "" {
+		t.Errorf("Open = %+v", res)
+	}
+	if !bytes.Contains(res.Raw, []byte("Subject: Quarterly report\r\n")) ||
+		!bytes.Contains(res.Raw, []byte("Content-Transfer-Encoding: quoted-printable\r\n")) ||
+		!bytes.Contains(res.Raw, []byte("Numbers are in.")) {
+		t.Errorf("decrypted message:\n%s", res.Raw)
+	}
+
+	// The sender can read their own copy.
+	if res, _ := alice.Open(encrypted, nil); res.Error != "" || !bytes.Contains(res.Raw, []byte("Numbers are in.")) {
+		t.Errorf("sender copy = %+v", res)
+	}
+
+	// Someone without a secret key only learns it is encrypted.
+	var stranger, _ = OpenKeyring(t.TempDir())
+	res, _ = stranger.Open(encrypted, nil)
+	if !res.Encrypted || res.Error == "" {
+		t.Errorf("stranger = %+v", res)
+	}
+
+	// Signed, then encrypted without a one-pass signature.
+	signed, err := Sign([]byte(plainMessage), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := Encrypt(signed, to, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := bob.Open(nested, nil); !res.Encrypted || res.Signature != StatusValid || !bytes.Contains(res.Raw, []byte("Numbers are in.")) {
+		t.Errorf("nested = %+v", res)
+	}
+}
+
+func TestDecryptLockedKey(t *testing.T) {
+	var alice, _, _, _ = keyrings(t)
+	var k, _ = OpenKeyring(t.TempDir())
+	var locked = newEntity(t, "Bob", "bob@work.example")
+	locked.EncryptPrivateKeys([]byte("hunter2"), nil)
+	k.Import(armored(t, locked, true))
+	alice.Import(armored(t, locked, false))
+
+	to, _ := alice.Recipients([]string{"bob@work.example"})
+	encrypted, err := Encrypt([]byte(plainMessage), to, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := k.Open(encrypted, nil); res.Error == "" {
+		t.Error("decrypted without a passphrase")
+	}
+	res, err := k.Open(encrypted, func(Key) []byte { return []byte("hunter2") })
+	if err != nil || res.Error != "" || !bytes.Contains(res.Raw, []byte("Numbers are in.")) {
+		t.Errorf("Open with passphrase = %+v, %v", res, err)
+	}
+}
+
+func TestAutocrypt(t *testing.T) {
+	var alice = newEntity(t, "Alice", "alice@example.com")
+	var msg, err = AddAutocrypt([]byte(plainMessage), "alice@example.com", alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(msg), "\r\n") {
+		if len(line) > 78 {
+			t.Fatalf("header line too long: %d", len(line))
+		}
+	}
+
+	var k, _ = OpenKeyring(t.TempDir())
+	changed, err := k.Discover(msg)
+	if err != nil || !changed {
+		t.Fatalf("Discover = %v, %v", changed, err)
+	}
+	if to, _ := k.Recipients([]string{"alice@example.com"}); len(to) != 1 {
+		t.Fatal("discovered key is not usable for encryption")
+	}
+	if changed, _ := k.Discover(msg); changed {
+		t.Error("same key imported twice")
+	}
+
+	// The header must name the sender.
+	var spoofed = bytes.Replace(msg, []byte("From: Alice <alice@example.com>"), []byte("From: Mallory <mallory@example.com>"), 1)
+	var other, _ = OpenKeyring(t.TempDir())
+	if changed, _ := other.Discover(spoofed); changed {
+		t.Error("imported a key for another sender")
+	}
+
+	ac, err := ParseAutocrypt("addr=Alice@Example.com; prefer-encrypt=mutual; _extra=1; keydata=" +
+		"bWlhdQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ac.Addr != "alice@example.com" || !ac.PreferEncrypt || string(ac.KeyData) != "miau" {
+		t.Errorf("ParseAutocrypt = %+v", ac)
+	}
+	if _, err := ParseAutocrypt("addr=a@b.c; critical=1; keydata=bWlhdQ=="); err == nil {
+		t.Error("accepted unknown critical attribute")
+	}
+}
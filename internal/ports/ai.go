@@ -0,0 +1,38 @@
+package ports
+
+import "context"
+
+// AIRequest is a single-turn prompt sent to an AI provider
+type AIRequest struct {
+	System      string  // optional system prompt
+	Prompt      string  // user prompt
+	Model       string  // overrides the provider default when set
+	MaxTokens   int     // overrides the provider default when > 0
+	Temperature float64 // 0 uses the provider default
+}
+
+// AIResponse is the result of a completion
+type AIResponse struct {
+	Text         string
+	Model        string
+	StopReason   string
+	InputTokens  int
+	OutputTokens int
+}
+
+// AIStreamFunc receives text deltas while a response is streamed.
+// Returning an error aborts the stream.
+type AIStreamFunc func(delta string) error
+
+// AIProvider is a backend capable of generating text (Anthropic, OpenAI, Ollama, CLI)
+type AIProvider interface {
+	// Name returns the provider identifier (e.g. "anthropic", "ollama", "claude")
+	Name() string
+
+	// Complete sends the request and waits for the full response
+	Complete(ctx context.Context, req *AIRequest) (*AIResponse, error)
+
+	// Stream sends the request and calls onDelta for each text chunk.
+	// The returned response contains the full concatenated text.
+	Stream(ctx context.Context, req *AIRequest, onDelta AIStreamFunc) (*AIResponse, error)
+}
@@ -8,6 +8,8 @@ type App interface {
 	// Lifecycle
 	Start() error
 	Stop() error
+	// StartBackgroundSync keeps the non-current accounts synced (unified inbox)
+	StartBackgroundSync()
 
 	// Services access
 	Email() EmailService
@@ -29,6 +31,13 @@ type App interface {
 	Plugins() PluginService
 	Snooze() SnoozeService
 	Schedule() ScheduleService
+	Rules() RuleService
+	PGP() PGPService
+	SMIME() SMIMEService
+	Risk() RiskService
+	Outbox() OutboxService
+	FollowUps() FollowUpService
+	Invites() InviteService
 
 	// Events
 	Events() EventBus
@@ -37,6 +46,8 @@ type App interface {
 	GetCurrentAccount() *AccountInfo
 	GetAllAccounts() []AccountInfo
 	SetCurrentAccount(email string) error
+	// SyncAccounts syncs a folder on every configured account concurrently
+	SyncAccounts(ctx context.Context, folder string) []AccountSyncResult
 
 	// Config
 	GetConfig() AppConfig
@@ -86,6 +97,13 @@ type PluginWithState struct {
 	State *PluginState `json:"state,omitempty"`
 }
 
+// AccountSyncResult is the outcome of syncing one account in SyncAccounts
+type AccountSyncResult struct {
+	Account AccountInfo
+	Result  *SyncResult
+	Err     error
+}
+
 // AppConfig contains application configuration
 type AppConfig struct {
 	AccountEmail   string
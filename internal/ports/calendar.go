@@ -61,6 +61,20 @@ type CalendarService interface {
 
 	// CreateFollowUpEvent creates a follow-up event for an email
 	CreateFollowUpEvent(ctx context.Context, emailID int64, followUpTime time.Time, title string) (*CalendarEventInfo, error)
+
+	// === Remote Sync ===
+
+	// SyncCalDAV syncs the account's calendar with its CalDAV server, both ways
+	SyncCalDAV(ctx context.Context, accountID int64) (*CalendarSyncResult, error)
+
+	// SyncGoogleCalendar syncs the account's primary Google calendar, both ways
+	SyncGoogleCalendar(ctx context.Context, accountID int64) (*CalendarSyncResult, error)
+
+	// GetSyncConflicts returns the events changed both locally and on the server
+	GetSyncConflicts(ctx context.Context, accountID int64) ([]CalendarConflict, error)
+
+	// ResolveSyncConflict keeps the local or the server version of an event in conflict
+	ResolveSyncConflict(ctx context.Context, eventID int64, resolution ConflictResolution) (*CalendarEventInfo, error)
 }
 
 // CalendarEventType represents the type of calendar event
@@ -80,6 +94,8 @@ const (
 	CalendarEventSourceManual       CalendarEventSource = "manual"
 	CalendarEventSourceTaskSync     CalendarEventSource = "task_sync"
 	CalendarEventSourceAISuggestion CalendarEventSource = "ai_suggestion"
+	CalendarEventSourceEmailInvite  CalendarEventSource = "email_invite"
+	CalendarEventSourceCalDAV       CalendarEventSource = "caldav"
 )
 
 // CalendarSyncStatus represents sync status with external calendar
@@ -131,6 +147,8 @@ type CalendarEventInfo struct {
 	GoogleCalendarID string
 	LastSyncedAt     *time.Time
 	SyncStatus       CalendarSyncStatus
+	Location         string
+	RSVPStatus       InviteResponse // user's answer to an email invite ("" for other events)
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
 }
@@ -142,6 +160,28 @@ type CalendarEventCounts struct {
 	Total     int
 }
 
+// CalendarSyncResult summarizes a sync with a remote calendar
+type CalendarSyncResult struct {
+	Pulled    int // events created or updated from the server
+	Pushed    int // local changes written to the server
+	Deleted   int // events deleted on either side
+	Conflicts int // events changed on both sides, waiting for the user
+}
+
+// CalendarConflict is an event changed both locally and on the server
+type CalendarConflict struct {
+	Local  CalendarEventInfo
+	Remote *CalendarEventInfo // nil when the event was deleted on the server
+}
+
+// ConflictResolution is the version of an event in conflict the user keeps
+type ConflictResolution string
+
+const (
+	ConflictKeepMine   ConflictResolution = "keep_mine"
+	ConflictKeepTheirs ConflictResolution = "keep_theirs"
+)
+
 // CalendarSyncCallback is used by TaskService to sync with CalendarService
 // This avoids circular dependency between the two services
 type CalendarSyncCallback interface {
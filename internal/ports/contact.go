@@ -7,7 +7,8 @@ import (
 
 // ContactService defines the interface for contact operations
 type ContactService interface {
-	// SyncContacts performs a full or incremental sync of contacts from Gmail
+	// SyncContacts performs a full or incremental sync of contacts from
+	// the account's sources (Google, CardDAV)
 	SyncContacts(ctx context.Context, accountID int64, fullSync bool) error
 
 	// GetContact returns a contact by ID
@@ -28,30 +29,72 @@ type ContactService interface {
 	// GetContactPhoto returns the photo data for a contact
 	GetContactPhoto(ctx context.Context, contactID int64) ([]byte, error)
 
-	// GetSyncStatus returns the sync status for an account
+	// GetSyncStatus returns the sync status for an account (of its CardDAV
+	// address book when it has one)
 	GetSyncStatus(ctx context.Context, accountID int64) (*ContactSyncStatus, error)
 
 	// GetTopContacts returns contacts ordered by interaction frequency
 	GetTopContacts(ctx context.Context, accountID int64, limit int) ([]ContactInfo, error)
+
+	// SetContactTimeZone sets the IANA time zone of a contact, used to
+	// schedule sends at the recipient's local time ("" clears it)
+	SetContactTimeZone(ctx context.Context, contactID int64, timeZone string) error
+
+	// ImportContacts adds the contacts of a vCard or CSV file ("" format
+	// detects it). A contact sharing an email with an existing one is
+	// merged into it, keeping its interaction history.
+	ImportContacts(ctx context.Context, accountID int64, data []byte, format ContactFormat) (*ContactImportResult, error)
+
+	// ExportContacts writes all contacts of an account in a format
+	ExportContacts(ctx context.Context, accountID int64, format ContactFormat) ([]byte, error)
 }
 
+// ContactFormat is a file format for importing and exporting contacts
+type ContactFormat string
+
+const (
+	ContactFormatVCard      ContactFormat = "vcard"   // .vcf, one or more vCards
+	ContactFormatGoogleCSV  ContactFormat = "google"  // Google Contacts CSV
+	ContactFormatOutlookCSV ContactFormat = "outlook" // Outlook CSV
+)
+
+// ContactImportResult summarizes an import
+type ContactImportResult struct {
+	Created int // new contacts
+	Merged  int // entries merged into a contact with the same email
+	Skipped int // entries with neither a name nor an email
+}
+
+// Contact sync sources (ContactSyncStatus.Source)
+const (
+	ContactSourceGoogle  = "google"
+	ContactSourceCardDAV = "carddav"
+)
+
 // ContactInfo represents contact information
 type ContactInfo struct {
 	ID                int64
 	AccountID         int64
-	ResourceName      string
+	ResourceName      string // people/c123 (Google) or the vCard href (CardDAV)
 	DisplayName       string
 	GivenName         string
 	FamilyName        string
 	PhotoURL          string
 	PhotoPath         string
 	IsStarred         bool
+	TimeZone          string // IANA, e.g. America/New_York ("" = unknown)
 	InteractionCount  int
 	LastInteractionAt *time.Time
 	SyncedAt          *time.Time
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
 
+	// CardDAV: the vCard last synced (kept so what miau doesn't edit
+	// survives a push) and its ETag; PendingSync marks local changes
+	ETag        string
+	VCard       string
+	PendingSync bool
+
 	// Related data
 	Emails []ContactEmailInfo
 	Phones []ContactPhoneInfo
@@ -78,6 +121,7 @@ type ContactPhoneInfo struct {
 // ContactSyncStatus represents the sync status for contacts
 type ContactSyncStatus struct {
 	AccountID           int64
+	Source              string // ContactSourceGoogle ("" too) or ContactSourceCardDAV
 	LastSyncToken       string
 	LastFullSync        *time.Time
 	LastIncrementalSync *time.Time
@@ -88,7 +132,9 @@ type ContactSyncStatus struct {
 
 // ContactStoragePort defines the interface for contact storage operations
 type ContactStoragePort interface {
-	// SaveContact saves or updates a contact
+	// SaveContact saves or updates a contact. PendingSync marks it as
+	// changed locally (it is never cleared here); VCard is only stored for
+	// a new contact.
 	SaveContact(ctx context.Context, contact *ContactInfo) (int64, error)
 
 	// GetContact returns a contact by ID
@@ -121,17 +167,36 @@ type ContactStoragePort interface {
 	// RecordInteraction records an email interaction with a contact
 	RecordInteraction(ctx context.Context, contactID int64, emailID int64, interactionType string, interactionDate time.Time) error
 
-	// GetSyncStatus returns the sync status for an account
-	GetSyncStatus(ctx context.Context, accountID int64) (*ContactSyncStatus, error)
+	// GetSyncStatus returns the sync status of a source of an account
+	GetSyncStatus(ctx context.Context, accountID int64, source string) (*ContactSyncStatus, error)
 
-	// UpdateSyncStatus updates the sync status
+	// UpdateSyncStatus updates the sync status of a source
 	UpdateSyncStatus(ctx context.Context, status *ContactSyncStatus) error
 
+	// SaveContactVCard records the CardDAV version of a contact (its href
+	// becomes the resource name) and clears its local changes
+	SaveContactVCard(ctx context.Context, contactID int64, href, etag, vcard string) error
+
+	// GetCardDAVContacts returns the contacts that came from or went to
+	// the CardDAV address book
+	GetCardDAVContacts(ctx context.Context, accountID int64) ([]ContactInfo, error)
+
+	// GetContactsPendingSync returns the contacts changed locally since
+	// their last sync, with their emails and phones
+	GetContactsPendingSync(ctx context.Context, accountID int64) ([]ContactInfo, error)
+
+	// DeleteContact deletes a contact
+	DeleteContact(ctx context.Context, id int64) error
+
 	// GetTopContacts returns contacts ordered by interaction frequency
 	GetTopContacts(ctx context.Context, accountID int64, limit int) ([]ContactInfo, error)
 
 	// DeleteContactsByAccount deletes all contacts for an account
 	DeleteContactsByAccount(ctx context.Context, accountID int64) error
+
+	// SetContactTimeZone sets the IANA time zone of a contact ("" clears
+	// it), a local change to sync
+	SetContactTimeZone(ctx context.Context, contactID int64, timeZone string) error
 }
 
 // GmailContactsPort defines the interface for Gmail People API operations
@@ -5,6 +5,10 @@ import (
 	"time"
 )
 
+// UnifiedInbox is the folder name of the unified view: the INBOX of every
+// configured account, newest first. It only exists locally, never on a server.
+const UnifiedInbox = "All Inboxes"
+
 // EmailService defines operations for email management.
 // This is the main interface that UI layers use to interact with emails.
 type EmailService interface {
@@ -12,7 +16,7 @@ type EmailService interface {
 	GetFolders(ctx context.Context) ([]Folder, error)
 	SelectFolder(ctx context.Context, name string) (*Folder, error)
 
-	// Email listing
+	// Email listing (folder may be UnifiedInbox)
 	GetEmails(ctx context.Context, folder string, limit int) ([]EmailMetadata, error)
 	GetEmail(ctx context.Context, id int64) (*EmailContent, error)
 	GetEmailByUID(ctx context.Context, folder string, uid uint32) (*EmailContent, error)
@@ -27,16 +31,32 @@ type EmailService interface {
 	// Sync
 	Sync(ctx context.Context, folder string) (*SyncResult, error)
 	GetLatestUID(ctx context.Context, folder string) (uint32, error)
+
+	// Gmail labels: a message is listed once under each of its labels.
+	// Adding and removing go through the Gmail API (OAuth2 accounts only).
+	GetLabels(ctx context.Context) ([]Label, error)
+	GetEmailsByLabel(ctx context.Context, label string, limit int) ([]EmailMetadata, error)
+	AddLabel(ctx context.Context, id int64, label string) error
+	RemoveLabel(ctx context.Context, id int64, label string) error
+	// SyncLabels pulls labels from Gmail, returns how many messages were updated
+	SyncLabels(ctx context.Context) (int, error)
 }
 
 // SendService defines operations for sending emails.
 type SendService interface {
-	// Send sends an email immediately
+	// Send sends an email, after the undo-send window if one is set
 	Send(ctx context.Context, req *SendRequest) (*SendResult, error)
 
-	// SendDraft sends a draft
+	// SendDraft sends a draft, after the undo-send window if one is set
 	SendDraft(ctx context.Context, draftID int64) (*SendResult, error)
 
+	// CancelPendingSend stops an email that hasn't gone out yet (undo send)
+	// and turns it back into a draft
+	CancelPendingSend(ctx context.Context, id int64) error
+
+	// SetSendDelay sets the undo-send window (0 sends right away)
+	SetSendDelay(delay time.Duration)
+
 	// GetSignature returns the configured email signature
 	GetSignature(ctx context.Context) (string, error)
 
@@ -70,7 +90,7 @@ type DraftService interface {
 
 // SearchService defines operations for searching emails.
 type SearchService interface {
-	// Search performs a full-text search on emails
+	// Search performs a full-text search on the emails of every account
 	Search(ctx context.Context, query string, limit int) (*SearchResult, error)
 
 	// SearchInFolder searches within a specific folder
@@ -144,6 +164,13 @@ type SyncConfig struct {
 
 	// Folders to skip during sync
 	SkipFolders []string // e.g., "[Gmail]/All Mail", "[Gmail]/Spam"
+
+	// Flag sync settings
+	FlagSyncEnabled  bool // Pull read/star/deleted changes made by other clients (default: true)
+	FlagRefreshLimit int  // Emails to refresh when the server lacks CONDSTORE (default: 200)
+
+	// Push sync settings
+	IdleEnabled bool // Keep a dedicated IDLE connection on INBOX (default: true)
 }
 
 // DefaultSyncConfig returns the default sync configuration
@@ -155,6 +182,9 @@ func DefaultSyncConfig() SyncConfig {
 		PurgeEnabled:         true,
 		PurgeMaxFolderSize:   10000,
 		SkipFolders:          []string{"[Gmail]/All Mail", "[Gmail]/Spam"},
+		FlagSyncEnabled:      true,
+		FlagRefreshLimit:     200,
+		IdleEnabled:          true,
 	}
 }
 
@@ -193,6 +223,21 @@ type SyncService interface {
 	// This is used to verify emails before displaying them (more efficient than full purge)
 	PurgeSpecificUIDs(ctx context.Context, folder string, uids []uint32) ([]uint32, error)
 
+	// CheckUIDValidity compares the UIDVALIDITY reported when the folder was
+	// selected with the stored one. If it changed, local emails are reconciled
+	// by Message-ID and the server emails missing locally are fetched.
+	CheckUIDValidity(ctx context.Context, folder string, uidValidity uint32) error
+
+	// StartIdle starts push sync: a dedicated IDLE connection on INBOX that
+	// runs an incremental sync whenever the server reports EXISTS/EXPUNGE
+	StartIdle(ctx context.Context) error
+
+	// StopIdle stops push sync and closes the IDLE connection
+	StopIdle()
+
+	// IsIdling returns true if push sync is running
+	IsIdling() bool
+
 	// GetConnectionStatus returns the current connection status
 	GetConnectionStatus() ConnectionStatus
 
@@ -270,6 +315,15 @@ type AIService interface {
 	// ClassifyEmail classifies an email (spam, important, etc.)
 	ClassifyEmail(ctx context.Context, emailID int64) (string, error)
 
+	// Ask sends a free-form prompt; provider "" uses the configured provider
+	Ask(ctx context.Context, provider, prompt string) (string, error)
+
+	// AskStream is like Ask but calls onDelta for each chunk as it arrives
+	AskStream(ctx context.Context, provider, prompt string, onDelta AIStreamFunc) (string, error)
+
+	// ProviderName returns the configured provider name
+	ProviderName() string
+
 	// ExecuteQuickCommand executes a quick command (/dr, /sum, /action, etc.)
 	// emailID can be 0 if no email is selected
 	ExecuteQuickCommand(ctx context.Context, cmd *QuickCommand, emailID int64) (string, error)
@@ -359,6 +413,24 @@ type ScheduleService interface {
 	// GetScheduledDraftsCount returns the count of scheduled drafts
 	GetScheduledDraftsCount(ctx context.Context) (int, error)
 
-	// ProcessDueSchedules sends emails that are due (background job)
+	// ScheduleDraftAt schedules a draft to be sent once at sendAt and returns
+	// the actual send time (sendAt may be read in the recipient's time zone)
+	ScheduleDraftAt(ctx context.Context, draftID int64, sendAt time.Time, opts ScheduleOptions) (time.Time, error)
+
+	// GetRecurrencePresets returns ready-made recurrence rules
+	GetRecurrencePresets() []RecurrencePresetInfo
+
+	// ScheduleRecurring turns a draft into the template of a recurring send:
+	// every occurrence of rule (RRULE) from startsAt on sends a copy of it
+	ScheduleRecurring(ctx context.Context, draftID int64, rule string, startsAt time.Time, opts ScheduleOptions) (*RecurringSchedule, error)
+
+	// GetRecurringSchedules returns the recurring sends of the account
+	GetRecurringSchedules(ctx context.Context) ([]RecurringSchedule, error)
+
+	// CancelRecurring stops a recurring send; its template goes back to drafts
+	CancelRecurring(ctx context.Context, id int64) error
+
+	// ProcessDueSchedules creates the due occurrences of recurring sends and
+	// sends the scheduled emails that are due (background job)
 	ProcessDueSchedules(ctx context.Context) (int, error)
 }
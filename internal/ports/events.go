@@ -22,6 +22,7 @@ const (
 	EventTypeSyncStarted   EventType = "sync_started"
 	EventTypeSyncCompleted EventType = "sync_completed"
 	EventTypeSyncError     EventType = "sync_error"
+	EventTypeUIDValidity   EventType = "uid_validity_changed"
 
 	// Email events
 	EventTypeNewEmail     EventType = "new_email"
@@ -66,6 +67,15 @@ const (
 	// Snooze events
 	EventTypeEmailSnoozed   EventType = "email_snoozed"
 	EventTypeEmailUnsnoozed EventType = "email_unsnoozed"
+
+	// Rule events
+	EventTypeRuleApplied EventType = "rule_applied"
+
+	// Outbox events
+	EventTypeOutboxChanged EventType = "outbox_changed"
+
+	// Follow-up events
+	EventTypeFollowUpDue EventType = "follow_up_due"
 )
 
 // BaseEvent provides common event fields
@@ -108,8 +118,9 @@ type SyncStartedEvent struct {
 // SyncCompletedEvent is emitted when sync completes
 type SyncCompletedEvent struct {
 	BaseEvent
-	Folder string
-	Result *SyncResult
+	AccountID int64
+	Folder    string
+	Result    *SyncResult
 }
 
 // SyncErrorEvent is emitted when sync fails
@@ -119,6 +130,18 @@ type SyncErrorEvent struct {
 	Error  error
 }
 
+// UIDValidityChangedEvent is emitted when a folder's UIDVALIDITY changed on the
+// server and local emails were reconciled by Message-ID
+type UIDValidityChangedEvent struct {
+	BaseEvent
+	Folder         string
+	OldUIDValidity uint32
+	NewUIDValidity uint32
+	Rematched      int // emails re-matched to their new UID
+	Orphaned       int // emails no longer on the server (marked deleted)
+	Fetched        int // server emails that were missing locally and got fetched
+}
+
 // NewEmailEvent is emitted when a new email arrives
 type NewEmailEvent struct {
 	BaseEvent
@@ -210,6 +233,33 @@ func NewAccountSwitchedEvent(previousEmail, newEmail string, newAccountID int64)
 	}
 }
 
+// RuleAppliedEvent is emitted when a filter rule runs on an email
+type RuleAppliedEvent struct {
+	BaseEvent
+	RuleID   int64
+	RuleName string
+	EmailID  int64
+	Subject  string
+	Actions  []string
+	Error    error
+}
+
+// OutboxChangedEvent is emitted when an email enters, leaves or changes
+// status in the outbox
+type OutboxChangedEvent struct {
+	BaseEvent
+	DraftID   int64
+	Status    DraftStatus // sent when the email left the outbox after being sent
+	MessageID string      // of the sent email
+}
+
+// FollowUpDueEvent is emitted when nobody replied to a followed-up email in
+// time and the user was reminded
+type FollowUpDueEvent struct {
+	BaseEvent
+	FollowUp FollowUp
+}
+
 // EventHandler is a function that handles events
 type EventHandler func(Event)
 
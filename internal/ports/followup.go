@@ -0,0 +1,62 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// FollowUpService reminds the user about sent emails nobody replied to.
+// A follow-up is set when sending (SendRequest.FollowUpAfter) or on a draft
+// before it goes out; once the email is sent, the follow-up waits until its
+// reminder time and then checks the thread for inbound replies. Without one,
+// the conversation resurfaces in the inbox (like a snooze), a task is
+// created and, when enabled, a nudge draft is generated with AI.
+type FollowUpService interface {
+	// FollowUpDraft sets a follow-up on a draft: after the draft is sent,
+	// remind if nobody replies within after
+	FollowUpDraft(ctx context.Context, draftID int64, after time.Duration) error
+
+	// List returns the follow-ups of the current account that are waiting
+	// for the email to be sent or for the reminder time
+	List(ctx context.Context) ([]FollowUp, error)
+
+	// Cancel drops a follow-up that hasn't fired yet
+	Cancel(ctx context.Context, id int64) error
+
+	// Process checks the due follow-ups once and returns how many reminded
+	Process(ctx context.Context) (int, error)
+
+	// Start runs Process in the background until Stop
+	Start(ctx context.Context)
+	Stop()
+}
+
+// FollowUpStatus is the state of a follow-up
+type FollowUpStatus string
+
+const (
+	FollowUpWaiting   FollowUpStatus = "waiting"   // the email hasn't been sent yet
+	FollowUpPending   FollowUpStatus = "pending"   // sent, waiting for the reminder time
+	FollowUpReplied   FollowUpStatus = "replied"   // someone replied in time
+	FollowUpReminded  FollowUpStatus = "reminded"  // nobody replied: the user was reminded
+	FollowUpCancelled FollowUpStatus = "cancelled" // cancelled by the user
+)
+
+// FollowUp is a "remind me if nobody replies" on a sent email
+type FollowUp struct {
+	ID        int64          `json:"id"`
+	AccountID int64          `json:"accountId"`
+	DraftID   int64          `json:"draftId,omitempty"` // draft the email was sent from
+	To        string         `json:"to"`
+	Subject   string         `json:"subject"`
+	After     time.Duration  `json:"after"`
+	MessageID string         `json:"messageId,omitempty"`
+	SentAt    *time.Time     `json:"sentAt,omitempty"`
+	RemindAt  *time.Time     `json:"remindAt,omitempty"` // nil until the email is sent
+	Status    FollowUpStatus `json:"status"`
+
+	// Set once the follow-up reminded
+	EmailID      int64 `json:"emailId,omitempty"`      // email resurfaced in the inbox
+	TaskID       int64 `json:"taskId,omitempty"`       // task created
+	NudgeDraftID int64 `json:"nudgeDraftId,omitempty"` // AI nudge draft
+}
@@ -0,0 +1,75 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// InviteService handles meeting invitations received by email: iCalendar
+// objects (RFC 5545) in text/calendar parts, following iTIP (RFC 5546).
+// Invites are imported into the calendar as meeting events linked to the
+// email, and answered with an iTIP REPLY sent to the organizer.
+type InviteService interface {
+	// Import reads the text/calendar part of an email and upserts its events
+	// into calendar_events (event_type meeting, linked by email_id). Updates
+	// with an older SEQUENCE than the saved event are not applied.
+	Import(ctx context.Context, emailID int64, data []byte) (*Invite, error)
+
+	// GetInvite returns the invite imported from an email (nil if none)
+	GetInvite(ctx context.Context, emailID int64) (*Invite, error)
+
+	// Respond sends the user's answer (an iTIP REPLY through SendService)
+	// to the organizer and records it on the event
+	Respond(ctx context.Context, emailID int64, response InviteResponse) (*Invite, error)
+}
+
+// InviteResponse is the participation status of an attendee (PARTSTAT)
+type InviteResponse string
+
+const (
+	InviteNeedsAction InviteResponse = "NEEDS-ACTION"
+	InviteAccepted    InviteResponse = "ACCEPTED"
+	InviteTentative   InviteResponse = "TENTATIVE"
+	InviteDeclined    InviteResponse = "DECLINED"
+)
+
+// Valid reports whether the response can be sent to an organizer
+func (r InviteResponse) Valid() bool {
+	return r == InviteAccepted || r == InviteTentative || r == InviteDeclined
+}
+
+// InviteAttendee is an attendee or the organizer of an invite
+type InviteAttendee struct {
+	Email    string         `json:"email"`
+	Name     string         `json:"name,omitempty"`
+	Response InviteResponse `json:"response,omitempty"`
+	Optional bool           `json:"optional,omitempty"`
+}
+
+// Invite is a meeting invitation (or its update, cancellation or reply)
+// received by email
+type Invite struct {
+	EmailID     int64            `json:"emailId"`
+	EventID     int64            `json:"eventId,omitempty"` // calendar_events entry; 0 if not imported
+	Method      string           `json:"method"`            // REQUEST, CANCEL, REPLY, PUBLISH...
+	Kind        string           `json:"kind"`              // VEVENT or VTODO
+	UID         string           `json:"uid"`
+	Sequence    int              `json:"sequence"`
+	Title       string           `json:"title"`
+	Description string           `json:"description,omitempty"`
+	Location    string           `json:"location,omitempty"`
+	Start       time.Time        `json:"start"`
+	End         *time.Time       `json:"end,omitempty"`
+	AllDay      bool             `json:"allDay"`
+	TimeZone    string           `json:"timeZone,omitempty"` // TZID of the start
+	RRule       string           `json:"rrule,omitempty"`
+	Organizer   InviteAttendee   `json:"organizer"`
+	Attendees   []InviteAttendee `json:"attendees"`
+	Cancelled   bool             `json:"cancelled"`
+	Outdated    bool             `json:"outdated"` // a newer update of the event was received
+
+	// Response is the user's participation status; CanRespond is set when
+	// the invite asks the user for one
+	Response   InviteResponse `json:"response"`
+	CanRespond bool           `json:"canRespond"`
+}
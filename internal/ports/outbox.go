@@ -0,0 +1,51 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxService holds outgoing email that couldn't be sent because SMTP or
+// the Gmail API was unreachable, and sends it in the background. Outbox
+// entries are drafts with status queued, sending or failed; each account's
+// email goes out in the order it was queued.
+type OutboxService interface {
+	// Queue puts a request in the outbox, to be sent as soon as possible
+	Queue(ctx context.Context, req *SendRequest) (*OutboxItem, error)
+
+	// QueueDraft puts an existing draft in the outbox
+	QueueDraft(ctx context.Context, draftID int64, req *SendRequest) (*OutboxItem, error)
+
+	// List returns every email in the outbox, failed ones included
+	List(ctx context.Context) ([]OutboxItem, error)
+
+	// Retry resets the attempts of an email and sends it on the next round
+	Retry(ctx context.Context, id int64) error
+
+	// Cancel takes an email out of the outbox; it stays as a regular draft
+	Cancel(ctx context.Context, id int64) error
+
+	// Update edits the recipients, subject and body of an email that isn't
+	// being sent, and queues it again
+	Update(ctx context.Context, id int64, req *SendRequest) error
+
+	// Process sends the due emails once and returns how many were sent
+	Process(ctx context.Context) (int, error)
+
+	// Start runs Process in the background until Stop
+	Start(ctx context.Context)
+	Stop()
+}
+
+// OutboxItem is an email waiting in the outbox
+type OutboxItem struct {
+	ID            int64       `json:"id"` // draft ID
+	AccountID     int64       `json:"accountId"`
+	To            string      `json:"to"`
+	Subject       string      `json:"subject"`
+	Status        DraftStatus `json:"status"` // queued, sending or failed (gave up)
+	Attempts      int         `json:"attempts"`
+	NextAttemptAt *time.Time  `json:"nextAttemptAt,omitempty"` // nil = as soon as possible
+	LastError     string      `json:"lastError,omitempty"`
+	QueuedAt      time.Time   `json:"queuedAt"`
+}
@@ -0,0 +1,84 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// PGPService manages the local OpenPGP keyring and applies PGP/MIME to
+// outgoing and incoming mail
+type PGPService interface {
+	// ListKeys returns every key in the keyring, secret keys first
+	ListKeys(ctx context.Context) ([]PGPKey, error)
+
+	// ImportKeys adds ASCII-armored public or secret keys to the keyring and
+	// returns the keys added or updated
+	ImportKeys(ctx context.Context, armored []byte) ([]PGPKey, error)
+
+	// ExportKey returns the ASCII-armored keys matching a fingerprint, key ID
+	// or email. Secret keys stay protected by their passphrase.
+	ExportKey(ctx context.Context, query string, secret bool) ([]byte, error)
+
+	// DeleteKey removes a key by fingerprint
+	DeleteKey(ctx context.Context, fingerprint string) error
+
+	// Protector returns the function that signs and/or encrypts a built
+	// message sent from the given address. sign and encrypt are OR-ed with
+	// the account defaults. It returns nil when there is nothing to do and
+	// fails when a recipient has no key or the signing key can't be unlocked.
+	Protector(ctx context.Context, from string, recipients []string, sign, encrypt bool) (func(raw []byte) ([]byte, error), error)
+
+	// Open decrypts and verifies a raw message and imports the sender key
+	// from its Autocrypt header. Messages without PGP/MIME come back
+	// unchanged with a nil Security.
+	Open(ctx context.Context, raw []byte) (*OpenedMessage, error)
+}
+
+// PGPKey describes a key in the keyring
+type PGPKey struct {
+	Fingerprint string    `json:"fingerprint"`
+	KeyID       string    `json:"keyId"`
+	UserIDs     []string  `json:"userIds"`
+	Emails      []string  `json:"emails"`
+	Secret      bool      `json:"secret"`
+	CanEncrypt  bool      `json:"canEncrypt"`
+	Revoked     bool      `json:"revoked"`
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires,omitempty"` // zero when the key never expires
+}
+
+// SignatureStatus is the result of checking a message signature
+type SignatureStatus string
+
+const (
+	SignatureNone       SignatureStatus = ""            // not signed
+	SignatureValid      SignatureStatus = "valid"       // good signature from a known key
+	SignatureInvalid    SignatureStatus = "invalid"     // bad, expired or revoked signature
+	SignatureUnknownKey SignatureStatus = "unknown_key" // signer key not in the keyring
+	SignatureMismatch   SignatureStatus = "mismatch"    // good signature, but the key is not the sender's
+)
+
+// SecurityProtocol is the standard a message is protected with
+type SecurityProtocol string
+
+const (
+	ProtocolPGP   SecurityProtocol = "pgp"   // PGP/MIME, RFC 3156
+	ProtocolSMIME SecurityProtocol = "smime" // S/MIME, RFC 8551
+)
+
+// SecurityInfo describes the cryptographic protection of a message
+type SecurityInfo struct {
+	Protocol  SecurityProtocol `json:"protocol"`
+	Encrypted bool             `json:"encrypted"`
+	Signature SignatureStatus  `json:"signature"`
+	Signer    string           `json:"signer,omitempty"` // user ID of the key, or subject of the certificate
+	KeyID     string           `json:"keyId,omitempty"`  // PGP key ID, or SHA-256 fingerprint of the certificate
+	Chain     ChainStatus      `json:"chain,omitempty"`  // S/MIME only
+	Error     string           `json:"error,omitempty"`  // why decryption or verification failed
+}
+
+// OpenedMessage is a message with its PGP/MIME layers removed
+type OpenedMessage struct {
+	Raw      []byte
+	Security *SecurityInfo // nil when the message is neither signed nor encrypted
+}
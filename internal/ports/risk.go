@@ -0,0 +1,59 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// RiskService rates how likely an email is to be spoofed or phishing, from
+// its authentication results (SPF, DKIM, DMARC) and heuristics such as
+// look-alike domains and deceptive links
+type RiskService interface {
+	// Assess analyses a stored email and saves the result. raw is the whole
+	// message when available, which enables DKIM verification; without it
+	// the stored headers and HTML body are used.
+	Assess(ctx context.Context, emailID int64, raw []byte) (*RiskInfo, error)
+
+	// GetRisk returns the saved result, nil when the email wasn't analysed
+	GetRisk(ctx context.Context, emailID int64) (*RiskInfo, error)
+}
+
+// RiskLevel groups risk scores for display
+type RiskLevel string
+
+const (
+	RiskNone   RiskLevel = ""
+	RiskLow    RiskLevel = "low"
+	RiskMedium RiskLevel = "medium" // shown as a warning
+	RiskHigh   RiskLevel = "high"
+)
+
+// Warns reports whether the level deserves a warning badge
+func (l RiskLevel) Warns() bool {
+	return l == RiskMedium || l == RiskHigh
+}
+
+// RiskInfo is the risk analysis of an email
+type RiskInfo struct {
+	Score        int           `json:"score"` // 0-100
+	Level        RiskLevel     `json:"level"`
+	SPF          string        `json:"spf,omitempty"` // pass, fail, softfail, ...; "" when unknown
+	DKIM         string        `json:"dkim,omitempty"`
+	DMARC        string        `json:"dmarc,omitempty"`
+	DKIMVerified bool          `json:"dkimVerified"` // DKIM was verified locally against DNS
+	Findings     []RiskFinding `json:"findings"`
+	AnalyzedAt   time.Time     `json:"analyzedAt"`
+}
+
+// RiskFinding is one suspicious signal, e.g. {lookalike_domain, example.com}
+type RiskFinding struct {
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SenderKnowledge is what the mailbox knows about the sender of an email
+type SenderKnowledge struct {
+	Known        bool     // a contact, or mail was exchanged before
+	NameEmails   []string // addresses of the contacts with the sender's display name
+	KnownDomains []string // domains of contacts, replied senders and own accounts
+}
@@ -0,0 +1,137 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// RuleService manages mail filter rules. Rules run on new INBOX emails at
+// sync time and can also be run on demand over existing emails.
+type RuleService interface {
+	// GetRules returns all rules of the current account, in evaluation order
+	GetRules(ctx context.Context) ([]Rule, error)
+
+	// GetRule returns a rule by ID (nil if it doesn't exist)
+	GetRule(ctx context.Context, id int64) (*Rule, error)
+
+	// SaveRule creates (ID == 0) or updates a rule after validating it
+	SaveRule(ctx context.Context, rule *Rule) (*Rule, error)
+
+	// DeleteRule removes a rule
+	DeleteRule(ctx context.Context, id int64) error
+
+	// SetRuleEnabled enables or disables a rule
+	SetRuleEnabled(ctx context.Context, id int64, enabled bool) error
+
+	// ImportRules parses rules in the sieve-like text format and saves them.
+	// With replace, existing rules are deleted first.
+	ImportRules(ctx context.Context, text string, replace bool) ([]Rule, error)
+
+	// ExportRules returns all rules in the sieve-like text format
+	ExportRules(ctx context.Context) (string, error)
+
+	// RunRules applies the enabled rules to the newest emails of a folder
+	RunRules(ctx context.Context, folder string, limit int) (*RuleRunResult, error)
+
+	// ApplyToEmails applies the enabled rules to specific stored emails,
+	// e.g. the ones a sync just downloaded
+	ApplyToEmails(ctx context.Context, emailIDs []int64) (*RuleRunResult, error)
+}
+
+// RuleField is the part of an email a condition looks at
+type RuleField string
+
+const (
+	RuleFieldFrom          RuleField = "from"           // sender name and address
+	RuleFieldTo            RuleField = "to"             // To and Cc
+	RuleFieldSubject       RuleField = "subject"        // subject line
+	RuleFieldBody          RuleField = "body"           // plain text body
+	RuleFieldHeader        RuleField = "header"         // any header, named in RuleCondition.Header
+	RuleFieldHasAttachment RuleField = "has_attachment" // no operator or value
+	RuleFieldSize          RuleField = "size"           // message size, with RuleOpOver/RuleOpUnder
+)
+
+// RuleOperator compares a field with the condition value
+type RuleOperator string
+
+const (
+	RuleOpContains RuleOperator = "contains" // case-insensitive substring
+	RuleOpIs       RuleOperator = "is"       // case-insensitive equality
+	RuleOpMatches  RuleOperator = "matches"  // case-insensitive glob (* and ?)
+	RuleOpRegex    RuleOperator = "regex"    // Go regular expression
+	RuleOpOver     RuleOperator = "over"     // size greater than value (e.g. "1M")
+	RuleOpUnder    RuleOperator = "under"    // size less than value
+)
+
+// RuleCondition is a single test on an email
+type RuleCondition struct {
+	Field    RuleField
+	Header   string // header name when Field is RuleFieldHeader
+	Operator RuleOperator
+	Value    string
+	Negate   bool
+}
+
+// RuleActionType is what a rule does to a matching email
+type RuleActionType string
+
+const (
+	RuleActionMove       RuleActionType = "move"        // Value: target folder
+	RuleActionArchive    RuleActionType = "archive"     // remove from INBOX
+	RuleActionStar       RuleActionType = "star"        // mark as starred
+	RuleActionMarkRead   RuleActionType = "mark_read"   // mark as read
+	RuleActionSnooze     RuleActionType = "snooze"      // Value: snooze preset or duration ("4h")
+	RuleActionLabel      RuleActionType = "label"       // Value: label, stored as an IMAP keyword
+	RuleActionCreateTask RuleActionType = "create_task" // Value: task title; {subject} and {from} are replaced
+	RuleActionForward    RuleActionType = "forward"     // Value: address to forward to
+	RuleActionClassify   RuleActionType = "classify"    // AI category is added as a label
+)
+
+// RuleAction is a single action of a rule
+type RuleAction struct {
+	Type  RuleActionType
+	Value string
+}
+
+// Rule is a mail filter: when its conditions match, its actions run
+type Rule struct {
+	ID         int64
+	AccountID  int64
+	Name       string
+	Enabled    bool
+	Position   int  // evaluation order (ascending)
+	MatchAny   bool // true: any condition matches; false: all must match
+	Stop       bool // don't evaluate later rules after this one matches
+	Conditions []RuleCondition
+	Actions    []RuleAction
+	HitCount   int
+	LastHitAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// RuleEmail is the email data rules are evaluated against
+type RuleEmail struct {
+	ID             int64
+	UID            uint32
+	Folder         string
+	Subject        string
+	FromName       string
+	FromEmail      string
+	To             string
+	Cc             string
+	BodyText       string
+	Headers        map[string][]string // canonical MIME keys; nil when not fetched
+	HasAttachments bool
+	Size           int64
+	IsRead         bool
+	IsStarred      bool
+}
+
+// RuleRunResult summarizes a rules run
+type RuleRunResult struct {
+	Checked int // emails evaluated
+	Matched int // emails matched by at least one rule
+	Actions int // actions executed
+	Errors  []error
+}
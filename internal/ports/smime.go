@@ -0,0 +1,55 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// SMIMEService manages S/MIME identities and correspondents' certificates
+// and applies S/MIME to outgoing and incoming mail
+type SMIMEService interface {
+	// ListCertificates returns the account identities followed by the
+	// certificates of correspondents
+	ListCertificates(ctx context.Context) ([]SMIMECertificate, error)
+
+	// ImportCertificates adds PEM or DER certificates of correspondents and
+	// returns the ones that were new
+	ImportCertificates(ctx context.Context, data []byte) ([]SMIMECertificate, error)
+
+	// DeleteCertificate removes a correspondent certificate by SHA-256
+	// fingerprint
+	DeleteCertificate(ctx context.Context, fingerprint string) error
+
+	// Protector returns the function that signs and/or encrypts a built
+	// message sent from the given address. sign and encrypt are OR-ed with
+	// the account defaults. It returns nil when the account has no S/MIME
+	// identity and fails when a recipient has no certificate.
+	Protector(ctx context.Context, from string, recipients []string, sign, encrypt bool) (func(raw []byte) ([]byte, error), error)
+
+	// Open decrypts and verifies a raw message and remembers the certificate
+	// of valid signers. Messages without S/MIME come back unchanged with a
+	// nil Security.
+	Open(ctx context.Context, raw []byte) (*OpenedMessage, error)
+}
+
+// SMIMECertificate describes an X.509 certificate
+type SMIMECertificate struct {
+	Fingerprint string    `json:"fingerprint"` // SHA-256, uppercase hex
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	Emails      []string  `json:"emails"`
+	NotBefore   time.Time `json:"notBefore"`
+	NotAfter    time.Time `json:"notAfter"`
+	Own         bool      `json:"own"` // identity of a configured account
+}
+
+// ChainStatus is the result of validating a signer certificate chain
+type ChainStatus string
+
+const (
+	ChainNone      ChainStatus = ""          // not checked (PGP, or no valid signature)
+	ChainTrusted   ChainStatus = "trusted"   // issued by a trusted CA
+	ChainUntrusted ChainStatus = "untrusted" // unknown CA or self-signed
+	ChainExpired   ChainStatus = "expired"   // a certificate of the chain has expired
+	ChainInvalid   ChainStatus = "invalid"   // wrong key usage, broken chain, ...
+)
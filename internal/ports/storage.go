@@ -17,16 +17,24 @@ type StoragePort interface {
 	GetFolders(ctx context.Context, accountID int64) ([]Folder, error)
 	GetFolderByName(ctx context.Context, accountID int64, name string) (*Folder, error)
 	UpdateFolderStats(ctx context.Context, folderID int64, total, unread int) error
+	UpdateFolderUIDValidity(ctx context.Context, folderID int64, uidValidity uint32) error
+	// ReconcileFolderUIDs re-matches local emails to the new server UIDs by Message-ID
+	// (serverIDs is UID -> Message-ID). Used when UIDVALIDITY changes.
+	ReconcileFolderUIDs(ctx context.Context, accountID, folderID int64, serverIDs map[uint32]string) (*UIDReconcileResult, error)
+	UpdateFolderModSeq(ctx context.Context, folderID int64, modSeq uint64) error
 
 	// Email operations
 	// UpsertEmail inserts or updates an email, returns (id, messageID, error)
 	UpsertEmail(ctx context.Context, accountID, folderID int64, email *EmailContent) (int64, string, error)
 	GetEmails(ctx context.Context, folderID int64, limit int) ([]EmailMetadata, error)
+	// GetUnifiedEmails returns the emails of the folder with this name across all accounts
+	GetUnifiedEmails(ctx context.Context, folderName string, limit int) ([]EmailMetadata, error)
 	GetEmail(ctx context.Context, id int64) (*EmailContent, error)
 	GetEmailByUID(ctx context.Context, folderID int64, uid uint32) (*EmailContent, error)
 	GetEmailByUIDGlobal(ctx context.Context, accountID int64, uid uint32) (*EmailContent, error)
 	GetLatestUID(ctx context.Context, folderID int64) (uint32, error)
 	GetAllUIDs(ctx context.Context, folderID int64) ([]uint32, error)
+	GetRecentUIDs(ctx context.Context, accountID, folderID int64, limit int) ([]uint32, error)
 
 	// Email content updates
 	UpdateEmailBody(ctx context.Context, id int64, bodyText, bodyHTML string) error
@@ -37,6 +45,9 @@ type StoragePort interface {
 	MarkAsArchived(ctx context.Context, id int64, archived bool) error
 	MarkAsDeleted(ctx context.Context, id int64, deleted bool) error
 	MarkAsReplied(ctx context.Context, id int64, replied bool) error
+	// ApplyFlagUpdates syncs server flags into local emails, returns how many changed.
+	// \Deleted marks an email deleted; its absence never undeletes one.
+	ApplyFlagUpdates(ctx context.Context, accountID, folderID int64, updates []FlagUpdate) (int, error)
 
 	// Bulk operations
 	MarkDeletedByUIDs(ctx context.Context, folderID int64, uids []uint32) error
@@ -50,6 +61,27 @@ type StoragePort interface {
 
 	// Threading
 	DetectAndUpdateThreadID(ctx context.Context, emailID int64, messageID, inReplyTo, references, subject string) error
+	// UpdateThreadID sets the thread ID given by the provider (Gmail API sync)
+	UpdateThreadID(ctx context.Context, emailID int64, threadID string) error
+
+	// Labels (linked to messages by Message-ID, so a message kept in several
+	// IMAP folders carries its labels once)
+	UpsertLabel(ctx context.Context, accountID int64, label *Label) error
+	GetLabels(ctx context.Context, accountID int64) ([]Label, error)
+	DeleteLabel(ctx context.Context, accountID int64, name string) error
+	SetMessageLabels(ctx context.Context, accountID int64, messageID string, labels []string) error
+	AddMessageLabel(ctx context.Context, accountID int64, messageID, label string) error
+	RemoveMessageLabel(ctx context.Context, accountID int64, messageID, label string) error
+	GetLabelsForMessages(ctx context.Context, accountID int64, messageIDs []string) (map[string][]string, error)
+	GetEmailsByLabel(ctx context.Context, accountID int64, label string, limit int) ([]EmailMetadata, error)
+
+	// Risk analysis (SPF/DKIM/DMARC and phishing heuristics), one per email
+	SaveEmailRisk(ctx context.Context, emailID, accountID int64, risk *RiskInfo) error
+	GetEmailRisk(ctx context.Context, emailID int64) (*RiskInfo, error)
+	GetRiskForEmails(ctx context.Context, emailIDs []int64) (map[int64]RiskInfo, error)
+	// GetSenderKnowledge tells whether the sender of an email is known, as of
+	// that email, and which contacts and domains could be impersonated
+	GetSenderKnowledge(ctx context.Context, accountID, emailID int64, fromName, fromEmail string) (*SenderKnowledge, error)
 
 	// Draft operations
 	CreateDraft(ctx context.Context, accountID int64, draft *Draft) (*Draft, error)
@@ -138,6 +170,19 @@ type IMAPPort interface {
 	FetchEmailRaw(ctx context.Context, uid uint32) ([]byte, error)
 	FetchEmailBody(ctx context.Context, uid uint32) (string, error)
 	GetAllUIDs(ctx context.Context) ([]uint32, error)
+	// FetchMessageIDs returns UID -> Message-ID for the selected mailbox (envelope only)
+	FetchMessageIDs(ctx context.Context) (map[uint32]string, error)
+
+	// Flag sync
+	// SupportsCondStore returns true if the server supports CONDSTORE (HIGHESTMODSEQ)
+	SupportsCondStore() bool
+	// FetchFlagChanges returns flags of emails changed since modSeq (CHANGEDSINCE)
+	FetchFlagChanges(ctx context.Context, sinceModSeq uint64) ([]FlagUpdate, error)
+	// FlagChangesIncludeExpunges returns true if the last FetchFlagChanges also
+	// reported the expunged emails (as Deleted)
+	FlagChangesIncludeExpunges() bool
+	// FetchFlags returns current flags for the given UIDs (fallback without CONDSTORE)
+	FetchFlags(ctx context.Context, uids []uint32) ([]FlagUpdate, error)
 
 	// Search
 	SearchText(ctx context.Context, query string, limit int) ([]uint32, error)
@@ -148,6 +193,11 @@ type IMAPPort interface {
 	FetchNewEmailsBatch(ctx context.Context, sinceUID uint32, limit int) ([]IMAPEmail, error)
 	FetchEmailsSinceDateBatch(ctx context.Context, sinceDays int, limit int) ([]IMAPEmail, error)
 
+	// Push notifications
+	// Idle selects the mailbox and blocks until the server reports a change
+	// (IDLE when supported, NOOP polling otherwise). Needs a dedicated connection.
+	Idle(ctx context.Context, mailbox string) (*MailboxUpdate, error)
+
 	// Attachments
 	FetchAttachmentMetadata(ctx context.Context, uid uint32) ([]AttachmentInfo, bool, error)
 	FetchAttachmentPart(ctx context.Context, uid uint32, partNumber string) ([]byte, error)
@@ -159,6 +209,12 @@ type IMAPPort interface {
 	MoveToFolder(ctx context.Context, uid uint32, folder string) error
 	Delete(ctx context.Context, uid uint32) error
 	Undelete(ctx context.Context, uid uint32) error
+	// AddKeyword/RemoveKeyword set IMAP keywords (used as labels by rules)
+	AddKeyword(ctx context.Context, uid uint32, keyword string) error
+	RemoveKeyword(ctx context.Context, uid uint32, keyword string) error
+	// CopyToInbox puts an email of folder in INBOX too, unread, without
+	// changing the selected mailbox (used to resurface sent emails)
+	CopyToInbox(ctx context.Context, folder string, uid uint32) error
 
 	// Utility
 	GetTrashFolder() string
@@ -173,11 +229,31 @@ type MailboxInfo struct {
 
 // MailboxStatus contains detailed mailbox status
 type MailboxStatus struct {
-	Name        string
-	NumMessages uint32
-	NumUnseen   uint32
-	UIDNext     uint32
-	UIDValidity uint32
+	Name          string
+	NumMessages   uint32
+	NumUnseen     uint32
+	UIDNext       uint32
+	UIDValidity   uint32
+	HighestModSeq uint64 // 0 if the server doesn't support CONDSTORE
+}
+
+// FlagUpdate is the current state of an email's flags on the server
+type FlagUpdate struct {
+	UID      uint32
+	Seen     bool
+	Flagged  bool
+	Answered bool
+	Deleted  bool
+	ModSeq   uint64
+}
+
+// MailboxUpdate describes a change reported by the server while idling
+type MailboxUpdate struct {
+	Mailbox      string
+	NumMessages  uint32 // new message count (EXISTS), 0 if not reported
+	Expunged     int    // number of EXPUNGE responses received
+	FlagsChanged bool   // unsolicited FETCH (flags changed by another client)
+	ViaIdle      bool   // true if detected via IDLE, false if via NOOP polling
 }
 
 // IMAPEmail represents an email fetched from IMAP
@@ -195,9 +271,16 @@ type IMAPEmail struct {
 	BodyText   string
 	InReplyTo  string
 	References string
+	// Authentication and sender headers (Authentication-Results, DKIM-Signature,
+	// ...), for the risk analysis; empty when the backend doesn't fetch them
+	RawHeaders string
 	// Attachment metadata (populated by batch fetch methods)
 	HasAttachments bool
 	Attachments    []AttachmentInfo
+	// Provider data (Gmail API backend): native thread ID and label names,
+	// Labels is nil when the backend has no labels
+	ThreadID string
+	Labels   []string
 }
 
 // SMTPPort defines the interface for SMTP operations.
@@ -216,4 +299,18 @@ type GmailAPIPort interface {
 
 	// Archive archives an email
 	Archive(ctx context.Context, messageID string) error
+
+	// ListLabels returns the system and user labels of the mailbox
+	ListLabels(ctx context.Context) ([]Label, error)
+
+	// CreateLabel creates a user label
+	CreateLabel(ctx context.Context, name string) (*Label, error)
+
+	// ModifyLabels adds and removes labels (by Gmail label ID) on the message
+	// with this RFC822 Message-ID
+	ModifyLabels(ctx context.Context, messageID string, add, remove []string) error
+
+	// ListMessageLabels returns the label IDs of the newest messages, keyed
+	// by RFC822 Message-ID
+	ListMessageLabels(ctx context.Context, maxMessages int) (map[string][]string, error)
 }
@@ -56,6 +56,8 @@ type TaskSource string
 const (
 	TaskSourceManual       TaskSource = "manual"
 	TaskSourceAISuggestion TaskSource = "ai_suggestion"
+	TaskSourceRule         TaskSource = "rule"
+	TaskSourceFollowUp     TaskSource = "follow_up"
 )
 
 // TaskInput represents input for creating/updating a task
@@ -28,6 +28,10 @@ type EmailMetadata struct {
 	References     string
 	ThreadID       string
 	ThreadCount    int // Number of emails in thread (for grouped view)
+	AccountID      int64
+	AccountEmail   string    // set on views that span accounts (unified inbox, search)
+	Labels         []string  // Gmail labels of the message (by name)
+	RiskLevel      RiskLevel // phishing/spoofing risk, "" when not analysed
 }
 
 // EmailContent contains full email content
@@ -42,6 +46,9 @@ type EmailContent struct {
 	RawHeaders     string
 	HasAttachments bool
 	Attachments    []Attachment
+	Security       *SecurityInfo // PGP/MIME or S/MIME status; nil for plain messages
+	Risk           *RiskInfo     // phishing/spoofing analysis; nil when not analysed
+	Invite         *Invite       // meeting invitation (text/calendar); nil for other emails
 }
 
 // Attachment represents an email attachment
@@ -66,6 +73,19 @@ type Folder struct {
 	TotalMessages  int
 	UnreadMessages int
 	LastSync       *time.Time
+	UIDValidity    uint32 // Last UIDVALIDITY seen on the server (0 = unknown)
+	HighestModSeq  uint64 // Last HIGHESTMODSEQ synced (CONDSTORE, 0 = unknown)
+}
+
+// Label is a Gmail label. Unlike a folder, a message can carry several
+// labels and is listed once under each of them.
+type Label struct {
+	ID             int64
+	Name           string
+	RemoteID       string // Gmail label ID ("" until synced)
+	System         bool   // INBOX, STARRED, IMPORTANT...
+	TotalMessages  int
+	UnreadMessages int
 }
 
 // Draft represents a draft email
@@ -97,6 +117,8 @@ type DraftStatus string
 const (
 	DraftStatusDraft     DraftStatus = "draft"
 	DraftStatusScheduled DraftStatus = "scheduled"
+	DraftStatusRecurring DraftStatus = "recurring" // template of a recurring send
+	DraftStatusQueued    DraftStatus = "queued"    // in the outbox, waiting to be (re)sent
 	DraftStatusSending   DraftStatus = "sending"
 	DraftStatusSent      DraftStatus = "sent"
 	DraftStatusCancelled DraftStatus = "cancelled"
@@ -107,11 +129,19 @@ const (
 type SyncResult struct {
 	NewEmails     int
 	DeletedEmails int
+	FlagUpdates   int // emails whose read/star/deleted flags changed on the server
 	LatestUID     uint32
 	Errors        []error
 	NewEmailIDs   []int64 // IDs of newly synced emails (for thread sync)
 }
 
+// UIDReconcileResult summarizes a UID reconciliation after UIDVALIDITY changed
+type UIDReconcileResult struct {
+	Rematched   int      // local emails re-matched by Message-ID and given their new UID
+	Orphaned    int      // local emails with no match on the server (marked deleted)
+	MissingUIDs []uint32 // server UIDs with no local email (need to be fetched)
+}
+
 // SendRequest contains all data needed to send an email
 type SendRequest struct {
 	To             []string
@@ -123,16 +153,44 @@ type SendRequest struct {
 	InReplyTo      string
 	ReferenceIDs   string
 	ReplyToEmailID *int64
+	AccountID      int64  // sending account; 0 = the account of ReplyToEmailID, else the current one
 	Classification string // for Gmail API classification
 	Attachments    []Attachment
+	Sign           bool // sign (S/MIME or PGP/MIME), on top of the account default
+	Encrypt        bool // encrypt to every recipient, on top of the account default
+
+	// Calendar is an iCalendar object sent along the bodies as an iMIP
+	// text/calendar part, with CalendarMethod (REQUEST, REPLY...) as its method
+	Calendar       []byte
+	CalendarMethod string
+
+	// FollowUpAfter sets a follow-up: remind if nobody replies within this
+	// time after the email is sent (0 = no follow-up)
+	FollowUpAfter time.Duration
+
+	// Protect transforms the built RFC 5322 message before it is sent
+	// (S/MIME or PGP/MIME). Set by SendService; adapters apply it after building.
+	Protect func(raw []byte) ([]byte, error)
 }
 
 // SendResult contains the result of sending an email
 type SendResult struct {
 	Success   bool
 	MessageID string
+	ThreadID  string // Gmail API: thread of the sent message
 	Error     error
 	SentAt    time.Time
+
+	// Queued is set when the server couldn't be reached: the email was put
+	// in the outbox (as draft OutboxID) and will be sent in the background
+	Queued   bool
+	OutboxID int64
+
+	// Pending is set while the email waits out the undo-send window in the
+	// outbox (as draft OutboxID): it goes out at SendAt unless cancelled
+	// with SendService.CancelPendingSend
+	Pending bool
+	SendAt  time.Time
 }
 
 // BatchOperation represents a batch operation on emails
@@ -445,3 +503,31 @@ type SchedulePresetInfo struct {
 	Description string    // e.g., "9:00 AM"
 	Time        time.Time // Calculated target time
 }
+
+// ScheduleOptions tunes how a scheduled send time is read
+type ScheduleOptions struct {
+	// RecipientTimeZone reads the send time as wall clock time in the time
+	// zone stored on the first recipient's contact (local time if unknown)
+	RecipientTimeZone bool
+}
+
+// RecurrencePresetInfo is a ready-made recurrence rule
+type RecurrencePresetInfo struct {
+	Label string // e.g., "Every Monday at 9:00"
+	Rule  string // RRULE, e.g., "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0"
+}
+
+// RecurringSchedule is a recurring scheduled send: every occurrence of the
+// rule clones the template draft and schedules the copy
+type RecurringSchedule struct {
+	ID          int64
+	DraftID     int64 // template draft (status recurring)
+	ToAddresses string
+	Subject     string
+	Rule        string     // RRULE
+	TimeZone    string     // IANA zone the rule runs in ("" = local time)
+	StartsAt    time.Time  // first possible occurrence
+	NextRunAt   *time.Time // nil once the rule has ended
+	LastRunAt   *time.Time
+	Occurrences int
+}
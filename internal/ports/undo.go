@@ -30,6 +30,8 @@ const (
 	OperationTypeDelete      OperationType = "delete"
 	OperationTypeMove        OperationType = "move"
 	OperationTypeBatch       OperationType = "batch"
+	OperationTypeRule        OperationType = "rule"
+	OperationTypeSend        OperationType = "send"
 )
 
 // UndoService manages undo/redo operations
@@ -0,0 +1,440 @@
+// Package rrule implements the subset of RFC 5545 recurrence rules used by
+// recurring scheduled sends: DAILY, WEEKLY, MONTHLY and YEARLY rules with
+// INTERVAL, COUNT, UNTIL, BYMONTH, BYMONTHDAY, BYDAY, BYHOUR, BYMINUTE and
+// BYSETPOS.
+//
+// Occurrences are computed in the location of the rule's start time, so a
+// rule started at 9:00 in America/New_York keeps firing at 9:00 New York
+// time across daylight saving changes.
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a rule
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds the search for the next occurrence, so rules that can
+// never match (BYMONTH=2;BYMONTHDAY=30) end instead of looping forever
+const maxPeriods = 3000
+
+// WeekdayNum is a BYDAY entry: a weekday, optionally the Nth one of the
+// month or year (N < 0 counts from the end, 0 means every one)
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByMonth    []int
+	ByMonthDay []int
+	ByDay      []WeekdayNum
+	ByHour     []int
+	ByMinute   []int
+	BySetPos   []int
+}
+
+var dayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9".
+// The "RRULE:" prefix is optional. WKST is accepted but weeks always start
+// on Monday.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("rrule: empty rule")
+	}
+
+	var r = &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		var key, value, ok = strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("rrule: invalid part %q", part)
+		}
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch f := Frequency(value); f {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = f
+			default:
+				return nil, fmt.Errorf("rrule: unsupported frequency %s", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = parseInt(value, 1, 1<<16)
+		case "COUNT":
+			r.Count, err = parseInt(value, 1, 1<<16)
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYMONTH":
+			r.ByMonth, err = parseInts(value, 1, 12, false)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(value, -31, 31, true)
+		case "BYDAY":
+			r.ByDay, err = parseWeekdays(value)
+		case "BYHOUR":
+			r.ByHour, err = parseInts(value, 0, 23, false)
+		case "BYMINUTE":
+			r.ByMinute, err = parseInts(value, 0, 59, false)
+		case "BYSETPOS":
+			r.BySetPos, err = parseInts(value, -366, 366, true)
+		case "WKST":
+			if indexOf(dayNames, value) < 0 {
+				err = fmt.Errorf("invalid weekday %q", value)
+			}
+		default:
+			return nil, fmt.Errorf("rrule: unsupported part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rrule: %s: %w", key, err)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("rrule: FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("rrule: COUNT and UNTIL can't be used together")
+	}
+	if r.Freq == Daily || r.Freq == Weekly {
+		for _, wd := range r.ByDay {
+			if wd.N != 0 {
+				return nil, fmt.Errorf("rrule: numbered BYDAY needs FREQ=MONTHLY or YEARLY")
+			}
+		}
+	}
+	return r, nil
+}
+
+// String returns the rule in RRULE form, without the "RRULE:" prefix
+func (r *Rule) String() string {
+	var parts = []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		var days = make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = dayNames[wd.Weekday]
+			if wd.N != 0 {
+				days[i] = strconv.Itoa(wd.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByHour) > 0 {
+		parts = append(parts, "BYHOUR="+joinInts(r.ByHour))
+	}
+	if len(r.ByMinute) > 0 {
+		parts = append(parts, "BYMINUTE="+joinInts(r.ByMinute))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence of the rule started at start that is
+// strictly after after, or the zero time when the rule has ended. Only
+// occurrences at or after start count, so start itself is an occurrence
+// only if it matches the rule.
+func (r *Rule) Next(start, after time.Time) time.Time {
+	// Without COUNT there is no need to walk the periods before after
+	var first = 0
+	if r.Count == 0 && after.After(start) {
+		first = max(r.periodsBetween(start, after)-1, 0)
+	}
+
+	var count = 0
+	for i := first; i < first+maxPeriods; i++ {
+		for _, t := range r.period(start, i) {
+			if t.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return time.Time{}
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}
+			}
+			if t.After(after) {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// periodsBetween returns the index of the period containing t
+func (r *Rule) periodsBetween(start, t time.Time) int {
+	var from = civil(start)
+	var to = civil(t.In(start.Location()))
+	var n int
+	switch r.Freq {
+	case Daily:
+		n = int(to.Sub(from).Hours() / 24)
+	case Weekly:
+		n = int(to.Sub(from).Hours()/24+float64((int(from.Weekday())+6)%7)) / 7
+	case Monthly:
+		n = (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	case Yearly:
+		n = to.Year() - from.Year()
+	}
+	return n / r.Interval
+}
+
+// period returns the sorted occurrences of the i-th period (day, week,
+// month or year) of the rule, BYSETPOS applied
+func (r *Rule) period(start time.Time, i int) []time.Time {
+	var day = civil(start)
+	var step = i * r.Interval
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		var d = day.AddDate(0, 0, step)
+		days = r.matchDays(d, d.AddDate(0, 0, 1), start)
+	case Weekly:
+		var monday = day.AddDate(0, 0, -((int(day.Weekday())+6)%7)+7*step)
+		days = r.matchDays(monday, monday.AddDate(0, 0, 7), start)
+	case Monthly:
+		var first = time.Date(day.Year(), day.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		days = r.matchDays(first, first.AddDate(0, 1, 0), start)
+	case Yearly:
+		var year = day.Year() + step
+		if len(r.ByMonth) > 0 && len(r.ByDay) > 0 {
+			// Numbered BYDAY counts within each month when BYMONTH is set
+			for m := time.January; m <= time.December; m++ {
+				var first = time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+				days = append(days, r.matchDays(first, first.AddDate(0, 1, 0), start)...)
+			}
+		} else {
+			var first = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+			days = r.matchDays(first, first.AddDate(1, 0, 0), start)
+		}
+	}
+
+	var hours = r.ByHour
+	if len(hours) == 0 {
+		hours = []int{start.Hour()}
+	}
+	var minutes = r.ByMinute
+	if len(minutes) == 0 {
+		minutes = []int{start.Minute()}
+	}
+
+	var set []time.Time
+	for _, d := range days {
+		for _, h := range hours {
+			for _, m := range minutes {
+				set = append(set, time.Date(d.Year(), d.Month(), d.Day(), h, m, start.Second(), 0, start.Location()))
+			}
+		}
+	}
+	sort.Slice(set, func(a, b int) bool { return set[a].Before(set[b]) })
+
+	if len(r.BySetPos) == 0 {
+		return set
+	}
+	var picked []time.Time
+	for j, t := range set {
+		for _, pos := range r.BySetPos {
+			if pos == j+1 || pos == j-len(set) {
+				picked = append(picked, t)
+				break
+			}
+		}
+	}
+	return picked
+}
+
+// matchDays returns the days in [from, to) matching the rule. Without BYDAY
+// or BYMONTHDAY, monthly and yearly rules repeat the day of the month of
+// start and weekly rules its weekday.
+func (r *Rule) matchDays(from, to, start time.Time) []time.Time {
+	var byMonthDay = r.ByMonthDay
+	var byDay = r.ByDay
+	var byMonth = r.ByMonth
+	if len(byMonthDay) == 0 && len(byDay) == 0 {
+		switch r.Freq {
+		case Weekly:
+			byDay = []WeekdayNum{{Weekday: start.Weekday()}}
+		case Monthly:
+			byMonthDay = []int{start.Day()}
+		case Yearly:
+			byMonthDay = []int{start.Day()}
+			if len(byMonth) == 0 {
+				byMonth = []int{int(start.Month())}
+			}
+		}
+	}
+
+	var days []time.Time
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		if len(byMonth) > 0 && indexOf(byMonth, int(d.Month())) < 0 {
+			continue
+		}
+		if len(byMonthDay) > 0 && !matchMonthDay(d, byMonthDay) {
+			continue
+		}
+		if len(byDay) > 0 && !matchWeekday(d, from, to, byDay) {
+			continue
+		}
+		days = append(days, d)
+	}
+	return days
+}
+
+// matchMonthDay reports whether d is one of the days of the month in list
+// (negative days count from the end of the month)
+func matchMonthDay(d time.Time, list []int) bool {
+	var last = time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, md := range list {
+		if md == d.Day() || (md < 0 && last+md+1 == d.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekday reports whether d is one of the weekdays in list, numbered
+// ones counted within [from, to)
+func matchWeekday(d, from, to time.Time, list []WeekdayNum) bool {
+	for _, wd := range list {
+		if wd.Weekday != d.Weekday() {
+			continue
+		}
+		if wd.N == 0 {
+			return true
+		}
+		var fromStart = int(d.Sub(from).Hours()/24)/7 + 1
+		var fromEnd = int(to.Sub(d).Hours()/24-1)/7 + 1
+		if wd.N == fromStart || wd.N == -fromEnd {
+			return true
+		}
+	}
+	return false
+}
+
+// civil returns the calendar day of t as midnight UTC, so day arithmetic
+// is not affected by daylight saving changes
+func civil(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// parseUntil parses UNTIL as a UTC date-time or a date (end of that day,
+// UTC); floating date-times are read as UTC
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	var t, err = time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// parseWeekdays parses a BYDAY list such as "MO,WE" or "1MO,-1FR"
+func parseWeekdays(value string) ([]WeekdayNum, error) {
+	var list []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		var day = indexOf(dayNames, item[len(item)-2:])
+		if day < 0 {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		var wd = WeekdayNum{Weekday: time.Weekday(day)}
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var n, err = strconv.Atoi(strings.TrimPrefix(prefix, "+"))
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid weekday %q", item)
+			}
+			wd.N = n
+		}
+		list = append(list, wd)
+	}
+	return list, nil
+}
+
+// parseInts parses a comma separated list of integers in [min, max]
+func parseInts(value string, min, max int, nonZero bool) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		var n, err = parseInt(item, min, max)
+		if err != nil {
+			return nil, err
+		}
+		if nonZero && n == 0 {
+			return nil, fmt.Errorf("invalid value 0")
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// parseInt parses an integer in [min, max]
+func parseInt(value string, min, max int) (int, error) {
+	var n, err = strconv.Atoi(strings.TrimPrefix(value, "+"))
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	return n, nil
+}
+
+// joinInts formats a list of integers for String
+func joinInts(list []int) string {
+	var items = make([]string, len(list))
+	for i, n := range list {
+		items[i] = strconv.Itoa(n)
+	}
+	return strings.Join(items, ",")
+}
+
+// indexOf returns the index of v in list, or -1
+func indexOf[T comparable](list []T, v T) int {
+	for i, item := range list {
+		if item == v {
+			return i
+		}
+	}
+	return -1
+}
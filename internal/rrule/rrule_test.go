@@ -0,0 +1,159 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// occurrences returns the first n occurrences of rule started at start
+func occurrences(t *testing.T, rule string, start time.Time, n int) []string {
+	t.Helper()
+	var r, err = Parse(rule)
+	require.NoError(t, err)
+
+	var list []string
+	var after = start.Add(-time.Second)
+	for len(list) < n {
+		var next = r.Next(start, after)
+		if next.IsZero() {
+			break
+		}
+		list = append(list, next.Format("Mon 2006-01-02 15:04 MST"))
+		after = next
+	}
+	return list
+}
+
+func TestParse(t *testing.T) {
+	var r, err = Parse("RRULE:FREQ=MONTHLY;INTERVAL=2;BYDAY=1MO,-1FR;BYHOUR=9;BYMINUTE=0;BYSETPOS=1")
+	require.NoError(t, err)
+	assert.Equal(t, Monthly, r.Freq)
+	assert.Equal(t, 2, r.Interval)
+	assert.Equal(t, []WeekdayNum{{time.Monday, 1}, {time.Friday, -1}}, r.ByDay)
+	assert.Equal(t, "FREQ=MONTHLY;INTERVAL=2;BYDAY=1MO,-1FR;BYHOUR=9;BYMINUTE=0;BYSETPOS=1", r.String())
+
+	r, err = Parse("freq=weekly;until=20261231")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC), r.Until)
+	assert.Equal(t, "FREQ=WEEKLY;UNTIL=20261231T235959Z", r.String())
+
+	for _, bad := range []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=DAILY;COUNT=3;UNTIL=20260101",
+		"FREQ=DAILY;BYHOUR=24",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=DAILY;BYSECOND=0",
+		"FREQ=DAILY;INTERVAL",
+	} {
+		_, err = Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNext_EveryMondayAtNine(t *testing.T) {
+	// Wednesday
+	var start = time.Date(2026, 10, 14, 16, 30, 0, 0, time.UTC)
+	assert.Equal(t, []string{
+		"Mon 2026-10-19 09:00 UTC",
+		"Mon 2026-10-26 09:00 UTC",
+		"Mon 2026-11-02 09:00 UTC",
+	}, occurrences(t, "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0", start, 3))
+}
+
+func TestNext_FirstBusinessDayOfMonth(t *testing.T) {
+	var start = time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{
+		"Thu 2026-01-01 09:00 UTC",
+		"Mon 2026-02-02 09:00 UTC",
+		"Mon 2026-03-02 09:00 UTC",
+		"Wed 2026-04-01 09:00 UTC",
+		"Fri 2026-05-01 09:00 UTC",
+		"Mon 2026-06-01 09:00 UTC",
+	}, occurrences(t, "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=1", start, 6))
+}
+
+func TestNext_Monthly(t *testing.T) {
+	var start = time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	// Months without a 31st are skipped
+	assert.Equal(t, []string{
+		"Sat 2026-01-31 10:00 UTC",
+		"Tue 2026-03-31 10:00 UTC",
+		"Sun 2026-05-31 10:00 UTC",
+	}, occurrences(t, "FREQ=MONTHLY", start, 3))
+
+	// Last day of the month
+	assert.Equal(t, []string{
+		"Sat 2026-01-31 10:00 UTC",
+		"Sat 2026-02-28 10:00 UTC",
+		"Tue 2026-03-31 10:00 UTC",
+	}, occurrences(t, "FREQ=MONTHLY;BYMONTHDAY=-1", start, 3))
+
+	// Last Friday of every other month
+	assert.Equal(t, []string{
+		"Fri 2026-03-27 10:00 UTC",
+		"Fri 2026-05-29 10:00 UTC",
+	}, occurrences(t, "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR", start, 2))
+}
+
+func TestNext_CountAndUntil(t *testing.T) {
+	var start = time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{
+		"Mon 2026-03-02 08:00 UTC",
+		"Tue 2026-03-03 08:00 UTC",
+		"Wed 2026-03-04 08:00 UTC",
+	}, occurrences(t, "FREQ=DAILY;COUNT=3", start, 10))
+
+	assert.Equal(t, []string{
+		"Mon 2026-03-02 08:00 UTC",
+		"Mon 2026-03-16 08:00 UTC",
+	}, occurrences(t, "FREQ=WEEKLY;INTERVAL=2;UNTIL=20260329", start, 10))
+
+	// COUNT keeps counting from start even when asking for a later occurrence
+	var r, _ = Parse("FREQ=DAILY;COUNT=3")
+	assert.True(t, r.Next(start, start.AddDate(0, 0, 5)).IsZero())
+}
+
+func TestNext_KeepsLocalTimeAcrossDST(t *testing.T) {
+	var ny, err = time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	// New York switches to EST on 2026-11-01
+	var start = time.Date(2026, 10, 26, 9, 0, 0, 0, ny)
+	assert.Equal(t, []string{
+		"Mon 2026-10-26 09:00 EDT",
+		"Mon 2026-11-02 09:00 EST",
+	}, occurrences(t, "FREQ=WEEKLY;BYDAY=MO", start, 2))
+}
+
+func TestNext_Yearly(t *testing.T) {
+	var start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{
+		"Thu 2026-11-26 12:00 UTC",
+		"Thu 2027-11-25 12:00 UTC",
+	}, occurrences(t, "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", start, 2))
+
+	assert.Equal(t, []string{
+		"Thu 2026-01-01 12:00 UTC",
+		"Fri 2027-01-01 12:00 UTC",
+	}, occurrences(t, "FREQ=YEARLY", start, 2))
+
+	// A rule that never matches ends instead of looping forever
+	var r, _ = Parse("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30")
+	assert.True(t, r.Next(start, start).IsZero())
+}
+
+func TestNext_StartLongAgo(t *testing.T) {
+	var start = time.Date(2001, 1, 1, 9, 0, 0, 0, time.UTC)
+	var r, _ = Parse("FREQ=DAILY;INTERVAL=3")
+	var next = r.Next(start, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC), next)
+	assert.Zero(t, int(next.Sub(start).Hours()/24)%3)
+}
@@ -0,0 +1,382 @@
+// Package rules evaluates mail filter rules and converts them to and from
+// a sieve-like text format. It has no storage or network dependencies:
+// services.RuleService loads rules, builds ports.RuleEmail values and runs
+// the actions.
+package rules
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// DefaultTaskTitle is used when a create_task action has no title
+const DefaultTaskTitle = "{subject}"
+
+// Match reports whether the email matches the rule's conditions.
+// Disabled rules never match.
+func Match(rule *ports.Rule, email *ports.RuleEmail) bool {
+	if rule == nil || !rule.Enabled || len(rule.Conditions) == 0 {
+		return false
+	}
+	for i := range rule.Conditions {
+		var ok = MatchCondition(&rule.Conditions[i], email)
+		if rule.MatchAny && ok {
+			return true
+		}
+		if !rule.MatchAny && !ok {
+			return false
+		}
+	}
+	return !rule.MatchAny
+}
+
+// MatchCondition evaluates a single condition
+func MatchCondition(cond *ports.RuleCondition, email *ports.RuleEmail) bool {
+	var result bool
+	switch cond.Field {
+	case ports.RuleFieldHasAttachment:
+		result = email.HasAttachments
+	case ports.RuleFieldSize:
+		var size, err = ParseSize(cond.Value)
+		if err != nil {
+			return false
+		}
+		switch cond.Operator {
+		case ports.RuleOpOver:
+			result = email.Size > size
+		case ports.RuleOpUnder:
+			result = email.Size < size
+		}
+	default:
+		for _, candidate := range fieldValues(cond, email) {
+			if matchText(cond.Operator, candidate, cond.Value) {
+				result = true
+				break
+			}
+		}
+	}
+	if cond.Negate {
+		return !result
+	}
+	return result
+}
+
+// fieldValues returns the strings a text condition is tested against.
+// Address fields yield each address and display name, plus the raw value.
+func fieldValues(cond *ports.RuleCondition, email *ports.RuleEmail) []string {
+	switch cond.Field {
+	case ports.RuleFieldFrom:
+		var values = []string{email.FromEmail, email.FromName}
+		if email.FromName != "" {
+			values = append(values, email.FromName+" <"+email.FromEmail+">")
+		}
+		return values
+	case ports.RuleFieldTo:
+		var values []string
+		for _, raw := range []string{email.To, email.Cc} {
+			if raw == "" {
+				continue
+			}
+			values = append(values, raw)
+			values = append(values, addressValues(raw)...)
+		}
+		return values
+	case ports.RuleFieldSubject:
+		return []string{email.Subject}
+	case ports.RuleFieldBody:
+		return []string{email.BodyText}
+	case ports.RuleFieldHeader:
+		if email.Headers == nil {
+			return nil
+		}
+		return mail.Header(email.Headers)[canonicalHeader(cond.Header)]
+	}
+	return nil
+}
+
+// addressValues splits an address list into addresses and names
+func addressValues(raw string) []string {
+	var values []string
+	if list, err := mail.ParseAddressList(raw); err == nil {
+		for _, addr := range list {
+			values = append(values, addr.Address)
+			if addr.Name != "" {
+				values = append(values, addr.Name)
+			}
+		}
+		return values
+	}
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+func canonicalHeader(name string) string {
+	// net/mail keys use textproto's canonical form ("List-Id")
+	var parts = strings.Split(strings.ToLower(strings.TrimSpace(name)), "-")
+	for i, p := range parts {
+		if p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+func matchText(op ports.RuleOperator, value, pattern string) bool {
+	switch op {
+	case ports.RuleOpContains:
+		return strings.Contains(strings.ToLower(value), strings.ToLower(pattern))
+	case ports.RuleOpIs:
+		return strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(pattern))
+	case ports.RuleOpMatches:
+		var re, err = compile(globToRegex(pattern))
+		return err == nil && re.MatchString(value)
+	case ports.RuleOpRegex:
+		var re, err = compile(pattern)
+		return err == nil && re.MatchString(value)
+	}
+	return false
+}
+
+// regexCache avoids recompiling the same patterns for every email
+var regexCache sync.Map
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	var re, err = regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+// globToRegex converts a sieve-style glob (* and ?) into an anchored,
+// case-insensitive regular expression
+func globToRegex(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("(?is)^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// ParseSize parses sizes like "500", "100K", "5M" or "1G" into bytes
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "B")
+	var mult int64 = 1
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	var n, err = strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// NeedsHeaders reports whether any enabled rule tests arbitrary headers
+// (which requires fetching the raw message)
+func NeedsHeaders(rules []ports.Rule) bool {
+	return usesField(rules, ports.RuleFieldHeader)
+}
+
+// NeedsBody reports whether any enabled rule tests the body
+func NeedsBody(rules []ports.Rule) bool {
+	return usesField(rules, ports.RuleFieldBody)
+}
+
+func usesField(rules []ports.Rule, field ports.RuleField) bool {
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		for _, c := range r.Conditions {
+			if c.Field == field {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ExpandTemplate replaces {subject}, {from} and {from_name} in a task title
+func ExpandTemplate(tmpl string, email *ports.RuleEmail) string {
+	if strings.TrimSpace(tmpl) == "" {
+		tmpl = DefaultTaskTitle
+	}
+	var fromName = email.FromName
+	if fromName == "" {
+		fromName = email.FromEmail
+	}
+	return strings.NewReplacer(
+		"{subject}", email.Subject,
+		"{from}", email.FromEmail,
+		"{from_name}", fromName,
+	).Replace(tmpl)
+}
+
+// Keyword turns a label into a valid IMAP keyword (an atom: no spaces,
+// controls or list/quoted specials). Labels are stored as keywords both by
+// the label action and in generated Sieve scripts.
+func Keyword(label string) string {
+	var sb strings.Builder
+	for _, r := range strings.TrimSpace(label) {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`(){%*"\]`, r) {
+			sb.WriteRune('_')
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// IsSnoozePreset reports whether value is one of the snooze presets
+func IsSnoozePreset(value string) bool {
+	switch ports.SnoozePreset(value) {
+	case ports.SnoozeLaterToday, ports.SnoozeTomorrow, ports.SnoozeThisWeekend,
+		ports.SnoozeNextWeek, ports.SnoozeNextMonth:
+		return true
+	}
+	return false
+}
+
+// Validate checks a rule before it is saved
+func Validate(rule *ports.Rule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if len(rule.Conditions) == 0 {
+		return fmt.Errorf("rule %q: at least one condition is required", rule.Name)
+	}
+	if len(rule.Actions) == 0 {
+		return fmt.Errorf("rule %q: at least one action is required", rule.Name)
+	}
+	for i := range rule.Conditions {
+		if err := validateCondition(&rule.Conditions[i]); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+
+	var terminal = 0
+	for _, a := range rule.Actions {
+		if err := validateAction(a); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		if a.Type == ports.RuleActionMove || a.Type == ports.RuleActionArchive {
+			terminal++
+		}
+	}
+	if terminal > 1 {
+		return fmt.Errorf("rule %q: only one move or archive action is allowed", rule.Name)
+	}
+	return nil
+}
+
+func validateCondition(c *ports.RuleCondition) error {
+	switch c.Field {
+	case ports.RuleFieldHasAttachment:
+		return nil
+	case ports.RuleFieldSize:
+		if c.Operator != ports.RuleOpOver && c.Operator != ports.RuleOpUnder {
+			return fmt.Errorf("size needs the over or under operator")
+		}
+		var _, err = ParseSize(c.Value)
+		return err
+	case ports.RuleFieldHeader:
+		if strings.TrimSpace(c.Header) == "" {
+			return fmt.Errorf("header condition needs a header name")
+		}
+	case ports.RuleFieldFrom, ports.RuleFieldTo, ports.RuleFieldSubject, ports.RuleFieldBody:
+	default:
+		return fmt.Errorf("unknown field %q", c.Field)
+	}
+
+	switch c.Operator {
+	case ports.RuleOpContains, ports.RuleOpIs, ports.RuleOpMatches:
+	case ports.RuleOpRegex:
+		if _, err := regexp.Compile(c.Value); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", c.Value, err)
+		}
+	default:
+		return fmt.Errorf("operator %q is not valid for %s", c.Operator, c.Field)
+	}
+	if c.Value == "" {
+		return fmt.Errorf("%s condition needs a value", c.Field)
+	}
+	return nil
+}
+
+func validateAction(a ports.RuleAction) error {
+	switch a.Type {
+	case ports.RuleActionArchive, ports.RuleActionStar, ports.RuleActionMarkRead,
+		ports.RuleActionCreateTask, ports.RuleActionClassify:
+		return nil
+	case ports.RuleActionMove:
+		if strings.TrimSpace(a.Value) == "" {
+			return fmt.Errorf("move needs a folder")
+		}
+	case ports.RuleActionLabel:
+		if strings.TrimSpace(a.Value) == "" {
+			return fmt.Errorf("label needs a name")
+		}
+	case ports.RuleActionSnooze:
+		if _, err := SnoozeUntil(a.Value, time.Now()); err != nil && !IsSnoozePreset(a.Value) {
+			return err
+		}
+	case ports.RuleActionForward:
+		if _, err := mail.ParseAddress(a.Value); err != nil {
+			return fmt.Errorf("invalid forward address %q", a.Value)
+		}
+	default:
+		return fmt.Errorf("unknown action %q", a.Type)
+	}
+	return nil
+}
+
+// SnoozeUntil resolves a duration snooze value ("4h", "2d") relative to now.
+// Presets are resolved by the snooze service instead.
+func SnoozeUntil(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days > 0 {
+			return now.AddDate(0, 0, days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid snooze %q: use a preset or a duration like 4h or 2d", value)
+}
@@ -0,0 +1,219 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+var testEmail = ports.RuleEmail{
+	Subject:        "Invoice #123 for March",
+	FromName:       "Vendor Billing",
+	FromEmail:      "billing@vendor.com",
+	To:             "Me <me@example.com>, team@example.com",
+	BodyText:       "Please pay by Friday",
+	Headers:        map[string][]string{"List-Id": {"<news.lists.example.com>"}},
+	HasAttachments: true,
+	Size:           2 << 20,
+}
+
+func TestMatchCondition(t *testing.T) {
+	var tests = []struct {
+		name string
+		cond ports.RuleCondition
+		want bool
+	}{
+		{"from contains", ports.RuleCondition{Field: ports.RuleFieldFrom, Operator: ports.RuleOpContains, Value: "BILLING@"}, true},
+		{"from is address", ports.RuleCondition{Field: ports.RuleFieldFrom, Operator: ports.RuleOpIs, Value: "billing@vendor.com"}, true},
+		{"from is name", ports.RuleCondition{Field: ports.RuleFieldFrom, Operator: ports.RuleOpIs, Value: "vendor billing"}, true},
+		{"from is partial", ports.RuleCondition{Field: ports.RuleFieldFrom, Operator: ports.RuleOpIs, Value: "billing"}, false},
+		{"to is second address", ports.RuleCondition{Field: ports.RuleFieldTo, Operator: ports.RuleOpIs, Value: "team@example.com"}, true},
+		{"subject glob", ports.RuleCondition{Field: ports.RuleFieldSubject, Operator: ports.RuleOpMatches, Value: "invoice #*"}, true},
+		{"subject glob anchored", ports.RuleCondition{Field: ports.RuleFieldSubject, Operator: ports.RuleOpMatches, Value: "march*"}, false},
+		{"subject regex", ports.RuleCondition{Field: ports.RuleFieldSubject, Operator: ports.RuleOpRegex, Value: `#\d+`}, true},
+		{"body contains", ports.RuleCondition{Field: ports.RuleFieldBody, Operator: ports.RuleOpContains, Value: "friday"}, true},
+		{"header", ports.RuleCondition{Field: ports.RuleFieldHeader, Header: "list-id", Operator: ports.RuleOpContains, Value: "news.lists"}, true},
+		{"missing header", ports.RuleCondition{Field: ports.RuleFieldHeader, Header: "X-Spam", Operator: ports.RuleOpContains, Value: "yes"}, false},
+		{"negated missing header", ports.RuleCondition{Field: ports.RuleFieldHeader, Header: "X-Spam", Operator: ports.RuleOpContains, Value: "yes", Negate: true}, true},
+		{"attachment", ports.RuleCondition{Field: ports.RuleFieldHasAttachment}, true},
+		{"size over", ports.RuleCondition{Field: ports.RuleFieldSize, Operator: ports.RuleOpOver, Value: "1M"}, true},
+		{"size under", ports.RuleCondition{Field: ports.RuleFieldSize, Operator: ports.RuleOpUnder, Value: "1M"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchCondition(&tt.cond, &testEmail); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	var hit = ports.RuleCondition{Field: ports.RuleFieldFrom, Operator: ports.RuleOpContains, Value: "vendor"}
+	var miss = ports.RuleCondition{Field: ports.RuleFieldSubject, Operator: ports.RuleOpContains, Value: "receipt"}
+
+	var rule = ports.Rule{Enabled: true, Conditions: []ports.RuleCondition{hit, miss}}
+	if Match(&rule, &testEmail) {
+		t.Error("allof with a failing condition should not match")
+	}
+	rule.MatchAny = true
+	if !Match(&rule, &testEmail) {
+		t.Error("anyof with a passing condition should match")
+	}
+	rule.Enabled = false
+	if Match(&rule, &testEmail) {
+		t.Error("disabled rule should not match")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	var tests = map[string]int64{"500": 500, "100K": 100 << 10, "5m": 5 << 20, "1GB": 1 << 30}
+	for in, want := range tests {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := ParseSize("lots"); err == nil {
+		t.Error("ParseSize(lots) should fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var cond = []ports.RuleCondition{{Field: ports.RuleFieldFrom, Operator: ports.RuleOpContains, Value: "x"}}
+	var tests = []struct {
+		name string
+		rule ports.Rule
+	}{
+		{"no name", ports.Rule{Conditions: cond, Actions: []ports.RuleAction{{Type: ports.RuleActionStar}}}},
+		{"no actions", ports.Rule{Name: "r", Conditions: cond}},
+		{"bad regex", ports.Rule{Name: "r", Actions: []ports.RuleAction{{Type: ports.RuleActionStar}},
+			Conditions: []ports.RuleCondition{{Field: ports.RuleFieldSubject, Operator: ports.RuleOpRegex, Value: "("}}}},
+		{"size with contains", ports.Rule{Name: "r", Actions: []ports.RuleAction{{Type: ports.RuleActionStar}},
+			Conditions: []ports.RuleCondition{{Field: ports.RuleFieldSize, Operator: ports.RuleOpContains, Value: "1M"}}}},
+		{"move and archive", ports.Rule{Name: "r", Conditions: cond,
+			Actions: []ports.RuleAction{{Type: ports.RuleActionMove, Value: "F"}, {Type: ports.RuleActionArchive}}}},
+		{"bad snooze", ports.Rule{Name: "r", Conditions: cond, Actions: []ports.RuleAction{{Type: ports.RuleActionSnooze, Value: "someday"}}}},
+		{"bad forward", ports.Rule{Name: "r", Conditions: cond, Actions: []ports.RuleAction{{Type: ports.RuleActionForward, Value: "nobody"}}}},
+	}
+	for _, tt := range tests {
+		if err := Validate(&tt.rule); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	var ok = ports.Rule{Name: "r", Conditions: cond, Actions: []ports.RuleAction{
+		{Type: ports.RuleActionSnooze, Value: "tomorrow"},
+		{Type: ports.RuleActionSnooze, Value: "2d"},
+		{Type: ports.RuleActionForward, Value: "Boss <boss@example.com>"},
+	}}
+	if err := Validate(&ok); err != nil {
+		t.Errorf("valid rule: %v", err)
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	if got := ExpandTemplate("Pay {subject} ({from_name})", &testEmail); got != "Pay Invoice #123 for March (Vendor Billing)" {
+		t.Errorf("got %q", got)
+	}
+	if got := ExpandTemplate("", &testEmail); got != testEmail.Subject {
+		t.Errorf("empty template: got %q", got)
+	}
+}
+
+func TestKeyword(t *testing.T) {
+	var tests = map[string]string{"  Work Stuff ": "Work_Stuff", `a(b"c]`: "a_b_c_", "$Newsletter": "$Newsletter"}
+	for in, want := range tests {
+		if got := Keyword(in); got != want {
+			t.Errorf("Keyword(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+const sampleRules = `
+# Invoices
+rule "Billing" {
+    if allof (from :contains "billing@vendor.com", not subject :contains "receipt") {
+        fileinto "Finance";
+        markread;
+        task "Pay {subject}";
+        stop;
+    }
+}
+
+rule "Big \"lists\"" disabled {
+    if anyof (size :over 5M, header "List-Id" :matches "*.lists.example.com>", attachment) {
+        label big;
+        snooze "next_week";
+        forward "archive@example.com";
+        classify;
+    }
+}
+`
+
+func TestParse(t *testing.T) {
+	var list, err = Parse(sampleRules)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("got %d rules, want 2", len(list))
+	}
+
+	var billing = list[0]
+	if billing.Name != "Billing" || !billing.Enabled || billing.MatchAny || !billing.Stop || billing.Position != 0 {
+		t.Errorf("unexpected rule: %+v", billing)
+	}
+	if len(billing.Conditions) != 2 || !billing.Conditions[1].Negate || billing.Conditions[1].Field != ports.RuleFieldSubject {
+		t.Errorf("unexpected conditions: %+v", billing.Conditions)
+	}
+	if len(billing.Actions) != 3 || billing.Actions[0] != (ports.RuleAction{Type: ports.RuleActionMove, Value: "Finance"}) {
+		t.Errorf("unexpected actions: %+v", billing.Actions)
+	}
+
+	var big = list[1]
+	if big.Name != `Big "lists"` || big.Enabled || !big.MatchAny || big.Position != 1 {
+		t.Errorf("unexpected rule: %+v", big)
+	}
+	if big.Conditions[1].Header != "List-Id" || big.Conditions[2].Field != ports.RuleFieldHasAttachment {
+		t.Errorf("unexpected conditions: %+v", big.Conditions)
+	}
+	if !Match(&ports.Rule{Enabled: true, MatchAny: true, Conditions: big.Conditions}, &testEmail) {
+		t.Error("parsed conditions should match the test email")
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	var list, err = Parse(sampleRules)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var text = Format(list)
+	var again, err2 = Parse(text)
+	if err2 != nil {
+		t.Fatalf("Parse(Format()): %v\n%s", err2, text)
+	}
+	if Format(again) != text {
+		t.Errorf("round trip changed the text:\n%s\n---\n%s", text, Format(again))
+	}
+	if !strings.Contains(text, `move "Finance";`) {
+		t.Errorf("Format should use canonical keywords:\n%s", text)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var tests = map[string]string{
+		`rule "x" { if from "a" { star; } }`:                 "line 1: expected an operator",
+		`rule "x" { if from :contains "a" { explode; } }`:    `unknown action "explode"`,
+		`rule "x" { if from :contains "a" { star } }`:        `expected ";"`,
+		"rule \"x\" {\n if from :contains \"a {\n star; } }": "line 2: unterminated string",
+		`rule x { if attachment { star; } }`:                 "expected quoted rule name",
+		`rule "x" { if size :over huge { star; } }`:          "invalid size",
+	}
+	for in, want := range tests {
+		var _, err = Parse(in)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error = %v, want %q", in, err, want)
+		}
+	}
+}
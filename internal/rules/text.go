@@ -0,0 +1,455 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// The text format is a small sieve-like language:
+//
+//	# Invoices go to Finance
+//	rule "Billing" {
+//	    if allof (from :contains "billing@vendor.com", not subject :contains "receipt") {
+//	        move "Finance";
+//	        markread;
+//	        task "Pay {subject}";
+//	        stop;
+//	    }
+//	}
+//
+//	rule "Large mail" disabled {
+//	    if anyof (size :over 5M, header "List-Id" :matches "*.lists.example.com") {
+//	        label "big";
+//	    }
+//	}
+//
+// Tests: from, to, subject, body (with :contains, :is, :matches or :regex),
+// header "Name" :op "value", attachment, size :over/:under N[K|M|G], and
+// "not <test>". Actions: move (fileinto), archive, star (flag), markread,
+// snooze, label, task, forward (redirect), classify and stop.
+
+// actionKeywords maps text keywords to action types
+var actionKeywords = map[string]ports.RuleActionType{
+	"move":     ports.RuleActionMove,
+	"fileinto": ports.RuleActionMove,
+	"archive":  ports.RuleActionArchive,
+	"star":     ports.RuleActionStar,
+	"flag":     ports.RuleActionStar,
+	"markread": ports.RuleActionMarkRead,
+	"snooze":   ports.RuleActionSnooze,
+	"label":    ports.RuleActionLabel,
+	"task":     ports.RuleActionCreateTask,
+	"forward":  ports.RuleActionForward,
+	"redirect": ports.RuleActionForward,
+	"classify": ports.RuleActionClassify,
+}
+
+// actionNames is the canonical keyword used by Format
+var actionNames = map[ports.RuleActionType]string{
+	ports.RuleActionMove:       "move",
+	ports.RuleActionArchive:    "archive",
+	ports.RuleActionStar:       "star",
+	ports.RuleActionMarkRead:   "markread",
+	ports.RuleActionSnooze:     "snooze",
+	ports.RuleActionLabel:      "label",
+	ports.RuleActionCreateTask: "task",
+	ports.RuleActionForward:    "forward",
+	ports.RuleActionClassify:   "classify",
+}
+
+// actionTakesValue lists actions followed by a string argument
+var actionTakesValue = map[ports.RuleActionType]bool{
+	ports.RuleActionMove:       true,
+	ports.RuleActionSnooze:     true,
+	ports.RuleActionLabel:      true,
+	ports.RuleActionCreateTask: true,
+	ports.RuleActionForward:    true,
+}
+
+// Parse reads rules in the text format. Rules are returned enabled (unless
+// marked disabled), with Position set to their order in the text, and
+// validated.
+func Parse(text string) ([]ports.Rule, error) {
+	var tokens, err = tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	var p = &parser{tokens: tokens}
+	var result []ports.Rule
+	for !p.done() {
+		var rule, err = p.parseRule()
+		if err != nil {
+			return nil, err
+		}
+		rule.Position = len(result)
+		if err := Validate(rule); err != nil {
+			return nil, err
+		}
+		result = append(result, *rule)
+	}
+	return result, nil
+}
+
+// Format writes rules in the text format accepted by Parse
+func Format(list []ports.Rule) string {
+	var sb strings.Builder
+	for i, r := range list {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("rule " + quote(r.Name))
+		if !r.Enabled {
+			sb.WriteString(" disabled")
+		}
+		sb.WriteString(" {\n    if ")
+
+		var tests = make([]string, len(r.Conditions))
+		for j, c := range r.Conditions {
+			tests[j] = formatCondition(c)
+		}
+		switch {
+		case len(tests) == 1:
+			sb.WriteString(tests[0])
+		case r.MatchAny:
+			sb.WriteString("anyof (" + strings.Join(tests, ", ") + ")")
+		default:
+			sb.WriteString("allof (" + strings.Join(tests, ", ") + ")")
+		}
+		sb.WriteString(" {\n")
+
+		for _, a := range r.Actions {
+			sb.WriteString("        " + actionNames[a.Type])
+			if actionTakesValue[a.Type] && a.Value != "" {
+				sb.WriteString(" " + quote(a.Value))
+			}
+			sb.WriteString(";\n")
+		}
+		if r.Stop {
+			sb.WriteString("        stop;\n")
+		}
+		sb.WriteString("    }\n}\n")
+	}
+	return sb.String()
+}
+
+func formatCondition(c ports.RuleCondition) string {
+	var s string
+	switch c.Field {
+	case ports.RuleFieldHasAttachment:
+		s = "attachment"
+	case ports.RuleFieldSize:
+		s = "size :" + string(c.Operator) + " " + c.Value
+	case ports.RuleFieldHeader:
+		s = "header " + quote(c.Header) + " :" + string(c.Operator) + " " + quote(c.Value)
+	default:
+		s = string(c.Field) + " :" + string(c.Operator) + " " + quote(c.Value)
+	}
+	if c.Negate {
+		return "not " + s
+	}
+	return s
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// ============================================================================
+// LEXER
+// ============================================================================
+
+type tokenKind int
+
+const (
+	tokWord   tokenKind = iota // bare word: rule, if, from, 5M
+	tokString                  // "quoted"
+	tokTag                     // :contains
+	tokPunct                   // { } ( ) , ;
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	var runes = []rune(text)
+	var line = 1
+	for i := 0; i < len(runes); {
+		var r = runes[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case strings.ContainsRune("{}(),;", r):
+			tokens = append(tokens, token{tokPunct, string(r), line})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			var start = line
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("line %d: unterminated string", start)
+				}
+				if runes[i] == '"' {
+					i++
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				if runes[i] == '\n' {
+					line++
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{tokString, sb.String(), start})
+		default:
+			var start = i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`{}(),;"#`, runes[i]) {
+				i++
+			}
+			var word = string(runes[start:i])
+			if strings.HasPrefix(word, ":") {
+				tokens = append(tokens, token{tokTag, strings.ToLower(word[1:]), line})
+			} else {
+				tokens = append(tokens, token{tokWord, word, line})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// ============================================================================
+// PARSER
+// ============================================================================
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		var line = 1
+		if len(p.tokens) > 0 {
+			line = p.tokens[len(p.tokens)-1].line
+		}
+		return token{kind: -1, text: "end of input", line: line}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	var t = p.peek()
+	if !p.done() {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) isWord(word string) bool {
+	var t = p.peek()
+	return t.kind == tokWord && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) isPunct(punct string) bool {
+	var t = p.peek()
+	return t.kind == tokPunct && t.text == punct
+}
+
+func (p *parser) expectWord(word string) error {
+	if !p.isWord(word) {
+		var t = p.peek()
+		return p.errorf(t, "expected %q, got %q", word, t.text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) expectPunct(punct string) error {
+	if !p.isPunct(punct) {
+		var t = p.peek()
+		return p.errorf(t, "expected %q, got %q", punct, t.text)
+	}
+	p.next()
+	return nil
+}
+
+// value accepts a quoted string or a bare word (e.g. 5M, tomorrow)
+func (p *parser) value(what string) (string, error) {
+	var t = p.next()
+	if t.kind != tokString && t.kind != tokWord {
+		return "", p.errorf(t, "expected %s, got %q", what, t.text)
+	}
+	return t.text, nil
+}
+
+func (p *parser) parseRule() (*ports.Rule, error) {
+	if err := p.expectWord("rule"); err != nil {
+		return nil, err
+	}
+	var name = p.next()
+	if name.kind != tokString {
+		return nil, p.errorf(name, "expected quoted rule name, got %q", name.text)
+	}
+	var rule = &ports.Rule{Name: name.text, Enabled: true}
+
+	for p.isWord("disabled") || p.isWord("enabled") {
+		rule.Enabled = strings.EqualFold(p.next().text, "enabled")
+	}
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	if err := p.expectWord("if"); err != nil {
+		return nil, err
+	}
+	if err := p.parseTestList(rule); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	if err := p.parseActions(rule); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("}"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("}"); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (p *parser) parseTestList(rule *ports.Rule) error {
+	if !p.isWord("allof") && !p.isWord("anyof") {
+		var cond, err = p.parseTest()
+		if err != nil {
+			return err
+		}
+		rule.Conditions = append(rule.Conditions, cond)
+		return nil
+	}
+
+	rule.MatchAny = strings.EqualFold(p.next().text, "anyof")
+	if err := p.expectPunct("("); err != nil {
+		return err
+	}
+	for {
+		var cond, err = p.parseTest()
+		if err != nil {
+			return err
+		}
+		rule.Conditions = append(rule.Conditions, cond)
+		if p.isPunct(",") {
+			p.next()
+			continue
+		}
+		return p.expectPunct(")")
+	}
+}
+
+func (p *parser) parseTest() (ports.RuleCondition, error) {
+	var cond ports.RuleCondition
+	var t = p.next()
+	if t.kind != tokWord {
+		return cond, p.errorf(t, "expected a test, got %q", t.text)
+	}
+
+	var keyword = strings.ToLower(t.text)
+	if keyword == "not" {
+		var inner, err = p.parseTest()
+		inner.Negate = !inner.Negate
+		return inner, err
+	}
+
+	switch keyword {
+	case "attachment":
+		cond.Field = ports.RuleFieldHasAttachment
+		return cond, nil
+	case "header":
+		var name = p.next()
+		if name.kind != tokString {
+			return cond, p.errorf(name, "expected quoted header name, got %q", name.text)
+		}
+		cond.Field = ports.RuleFieldHeader
+		cond.Header = name.text
+	case "from", "to", "subject", "body", "size":
+		cond.Field = ports.RuleField(keyword)
+	default:
+		return cond, p.errorf(t, "unknown test %q", t.text)
+	}
+
+	var op = p.next()
+	if op.kind != tokTag {
+		return cond, p.errorf(op, "expected an operator like :contains, got %q", op.text)
+	}
+	cond.Operator = ports.RuleOperator(op.text)
+	var value, err = p.value("a value")
+	if err != nil {
+		return cond, err
+	}
+	cond.Value = value
+	if err := validateCondition(&cond); err != nil {
+		return cond, p.errorf(t, "%v", err)
+	}
+	return cond, nil
+}
+
+func (p *parser) parseActions(rule *ports.Rule) error {
+	for !p.isPunct("}") {
+		var t = p.next()
+		if t.kind != tokWord {
+			return p.errorf(t, "expected an action, got %q", t.text)
+		}
+
+		var keyword = strings.ToLower(t.text)
+		if keyword == "stop" {
+			rule.Stop = true
+		} else {
+			var actionType, ok = actionKeywords[keyword]
+			if !ok {
+				return p.errorf(t, "unknown action %q", t.text)
+			}
+			var action = ports.RuleAction{Type: actionType}
+			if actionTakesValue[actionType] && !p.isPunct(";") {
+				var value, err = p.value("an argument")
+				if err != nil {
+					return err
+				}
+				action.Value = value
+			}
+			if err := validateAction(action); err != nil {
+				return p.errorf(t, "%v", err)
+			}
+			rule.Actions = append(rule.Actions, action)
+		}
+
+		if err := p.expectPunct(";"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// clientBuffer is how many events a slow SSE client may lag behind before
+// events are dropped for it
+const clientBuffer = 64
+
+// keepAliveInterval keeps idle SSE connections open through proxies
+const keepAliveInterval = 25 * time.Second
+
+// streamEvent is an event as sent over SSE
+type streamEvent struct {
+	ID   uint64                 `json:"id"`
+	Type string                 `json:"type"`
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// eventHub fans out EventBus events to SSE clients.
+// It subscribes to the bus once; clients come and go without touching the bus.
+type eventHub struct {
+	mu      sync.Mutex
+	clients map[chan streamEvent]struct{}
+	nextID  uint64
+	closed  bool
+	done    chan struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		clients: make(map[chan streamEvent]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// publish is the EventBus handler
+func (h *eventHub) publish(event ports.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	h.nextID++
+	var ev = streamEvent{
+		ID:   h.nextID,
+		Type: string(event.Type()),
+		Time: event.Timestamp(),
+		Data: eventData(event),
+	}
+	for ch := range h.clients {
+		select {
+		case ch <- ev:
+		default:
+			// Slow client: drop rather than block the bus
+		}
+	}
+}
+
+func (h *eventHub) subscribe() chan streamEvent {
+	var ch = make(chan streamEvent, clientBuffer)
+	h.mu.Lock()
+	h.clients[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan streamEvent) {
+	h.mu.Lock()
+	delete(h.clients, ch)
+	h.mu.Unlock()
+}
+
+// close ends every open stream
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		close(h.done)
+	}
+}
+
+// handleEvents streams events as server-sent events.
+// ?types=new_email,sync_completed limits the stream to those event types.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var flusher, ok = w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	var filter map[string]bool
+	if types := r.URL.Query().Get("types"); types != "" {
+		filter = make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			filter[strings.TrimSpace(t)] = true
+		}
+	}
+
+	var ch = s.hub.subscribe()
+	defer s.hub.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	var keepAlive = time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.hub.done:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case ev := <-ch:
+			if filter != nil && !filter[ev.Type] {
+				continue
+			}
+			var data, err = json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// eventData flattens an event struct into JSON-friendly data: field names
+// become lowerCamelCase, errors become strings and emails use EmailDTO.
+func eventData(event ports.Event) map[string]interface{} {
+	var data = make(map[string]interface{})
+
+	var v = reflect.ValueOf(event)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return data
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return data
+	}
+
+	var t = v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		var field = t.Field(i)
+		if !field.IsExported() || field.Type == reflect.TypeOf(ports.BaseEvent{}) {
+			continue
+		}
+		data[lowerFirst(field.Name)] = eventValue(v.Field(i).Interface())
+	}
+	return data
+}
+
+func eventValue(value interface{}) interface{} {
+	switch val := value.(type) {
+	case nil:
+		return nil
+	case error:
+		return val.Error()
+	case ports.EmailMetadata:
+		return NewEmailDTO(&val)
+	case *ports.SyncResult:
+		if val == nil {
+			return nil
+		}
+		return NewSyncResultDTO("", val)
+	default:
+		return val
+	}
+}
+
+func lowerFirst(s string) string {
+	// Keep acronyms readable: "UIDValidity" -> "uidValidity", "EmailID" -> "emailID"
+	var runes = []rune(s)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		// Lowercase the leading run of capitals, except the first letter of the next word
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
@@ -0,0 +1,519 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// defaultLimit is used when ?limit= is missing
+const defaultLimit = 50
+
+// maxLimit caps ?limit=
+const maxLimit = 1000
+
+// routes returns the API route table
+func (s *Server) routes() []route {
+	return []route{
+		{method: "GET", path: "/health", public: true, handler: s.handleHealth},
+		{method: "GET", path: "/openapi.json", public: true, handler: handleOpenAPI},
+		{method: "GET", path: "/events", handler: s.handleEvents},
+
+		{method: "GET", path: "/account", handler: s.handleAccount},
+		{method: "GET", path: "/folders", handler: s.handleFolders},
+		{method: "POST", path: "/sync", handler: s.handleSync},
+
+		{method: "GET", path: "/emails", handler: s.handleListEmails},
+		{method: "GET", path: "/emails/{id}", handler: s.handleGetEmail},
+		{method: "PATCH", path: "/emails/{id}", handler: s.handleUpdateEmail},
+		{method: "DELETE", path: "/emails/{id}", handler: s.handleDeleteEmail},
+		{method: "POST", path: "/emails/{id}/archive", handler: s.handleArchiveEmail},
+		{method: "POST", path: "/emails/{id}/move", handler: s.handleMoveEmail},
+		{method: "POST", path: "/emails/{id}/snooze", handler: s.handleSnoozeEmail},
+		{method: "DELETE", path: "/emails/{id}/snooze", handler: s.handleUnsnoozeEmail},
+		{method: "GET", path: "/snoozed", handler: s.handleListSnoozed},
+
+		{method: "GET", path: "/search", handler: s.handleSearch},
+		{method: "POST", path: "/send", handler: s.handleSend},
+		{method: "DELETE", path: "/send/{id}", handler: s.handleCancelSend},
+
+		{method: "GET", path: "/tasks", handler: s.handleListTasks},
+		{method: "POST", path: "/tasks", handler: s.handleCreateTask},
+		{method: "PATCH", path: "/tasks/{id}", handler: s.handleUpdateTask},
+		{method: "POST", path: "/tasks/{id}/toggle", handler: s.handleToggleTask},
+		{method: "DELETE", path: "/tasks/{id}", handler: s.handleDeleteTask},
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	var connected = false
+	if sync := s.app.Sync(); sync != nil {
+		connected = sync.IsConnected()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    "ok",
+		"connected": connected,
+	})
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	var account = s.app.GetCurrentAccount()
+	if account == nil {
+		writeError(w, http.StatusNotFound, "no account")
+		return
+	}
+	writeJSON(w, http.StatusOK, AccountDTO{ID: account.ID, Email: account.Email, Name: account.Name})
+}
+
+func (s *Server) handleFolders(w http.ResponseWriter, r *http.Request) {
+	var folders, err = s.app.Email().GetFolders(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var result = make([]FolderDTO, 0, len(folders))
+	for _, f := range folders {
+		result = append(result, FolderDTO{
+			ID:             f.ID,
+			Name:           f.Name,
+			TotalMessages:  f.TotalMessages,
+			UnreadMessages: f.UnreadMessages,
+			LastSync:       f.LastSync,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	var folder = r.URL.Query().Get("folder")
+	if folder == "" {
+		folder = "INBOX"
+	}
+	var result, err = s.app.Sync().SyncFolder(r.Context(), folder)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, NewSyncResultDTO(folder, result))
+}
+
+func (s *Server) handleListEmails(w http.ResponseWriter, r *http.Request) {
+	var folder = r.URL.Query().Get("folder")
+	if folder == "" {
+		folder = "INBOX"
+	}
+	var limit, ok = parseLimit(w, r)
+	if !ok {
+		return
+	}
+
+	var emails, err = s.app.Email().GetEmails(r.Context(), folder, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, NewEmailDTOs(emails))
+}
+
+func (s *Server) handleGetEmail(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	var email, err = s.app.Email().GetEmail(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if email == nil {
+		writeError(w, http.StatusNotFound, "email not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, NewEmailDetailDTO(email))
+}
+
+func (s *Server) handleUpdateEmail(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	var req EmailUpdateDTO
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var emailService = s.app.Email()
+	if req.IsRead != nil {
+		if err := emailService.MarkAsRead(r.Context(), id, *req.IsRead); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if req.IsStarred != nil {
+		if err := emailService.MarkAsStarred(r.Context(), id, *req.IsStarred); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteEmail(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.app.Email().Delete(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleArchiveEmail(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.app.Email().Archive(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMoveEmail(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	var req MoveRequestDTO
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Folder == "" {
+		writeError(w, http.StatusBadRequest, "folder is required")
+		return
+	}
+	if err := s.app.Email().MoveToFolder(r.Context(), id, req.Folder); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSnoozeEmail(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	var req SnoozeRequestDTO
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var err error
+	switch {
+	case req.Until != nil:
+		err = s.app.Snooze().SnoozeEmail(r.Context(), id, *req.Until)
+	case req.Preset != "":
+		err = s.app.Snooze().SnoozeEmailPreset(r.Context(), id, ports.SnoozePreset(req.Preset))
+	default:
+		writeError(w, http.StatusBadRequest, "until or preset is required")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUnsnoozeEmail(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.app.Snooze().UnsnoozeEmail(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListSnoozed(w http.ResponseWriter, r *http.Request) {
+	var snoozed, err = s.app.Snooze().GetSnoozedEmails(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var result = make([]SnoozedEmailDTO, 0, len(snoozed))
+	for _, sn := range snoozed {
+		var dto = SnoozedEmailDTO{
+			EmailID:     sn.EmailID,
+			SnoozedAt:   sn.SnoozedAt,
+			SnoozeUntil: sn.SnoozeUntil,
+			Preset:      string(sn.Preset),
+		}
+		if sn.Email != nil {
+			var email = NewEmailDTO(sn.Email)
+			dto.Email = &email
+		}
+		result = append(result, dto)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var query = strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	var limit, ok = parseLimit(w, r)
+	if !ok {
+		return
+	}
+
+	var result *ports.SearchResult
+	var err error
+	if folder := r.URL.Query().Get("folder"); folder != "" {
+		result, err = s.app.Search().SearchInFolder(r.Context(), folder, query, limit)
+	} else {
+		result, err = s.app.Search().Search(r.Context(), query, limit)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var dto = SearchResultDTO{Query: query, Emails: []EmailDTO{}}
+	if result != nil {
+		dto.TotalCount = result.TotalCount
+		dto.Emails = NewEmailDTOs(result.Emails)
+	}
+	writeJSON(w, http.StatusOK, dto)
+}
+
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	var req SendRequestDTO
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.To) == 0 {
+		writeError(w, http.StatusBadRequest, "to is required")
+		return
+	}
+	if req.BodyText == "" && req.BodyHTML == "" {
+		writeError(w, http.StatusBadRequest, "bodyText or bodyHtml is required")
+		return
+	}
+
+	var sendReq = &ports.SendRequest{
+		To:             req.To,
+		Cc:             req.Cc,
+		Bcc:            req.Bcc,
+		Subject:        req.Subject,
+		BodyText:       req.BodyText,
+		BodyHTML:       req.BodyHTML,
+		Classification: req.Classification,
+	}
+	if req.ReplyToEmailID > 0 {
+		sendReq.ReplyToEmailID = &req.ReplyToEmailID
+	}
+
+	var result, err = s.app.Send().Send(r.Context(), sendReq)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, SendResultDTO{Success: false, Error: err.Error()})
+		return
+	}
+
+	var dto = SendResultDTO{Success: result.Success, MessageID: result.MessageID, Queued: result.Queued, Pending: result.Pending, OutboxID: result.OutboxID}
+	if result.Pending {
+		dto.SendAt = &result.SendAt
+	}
+	if result.Error != nil {
+		dto.Error = result.Error.Error()
+	}
+	writeJSON(w, http.StatusOK, dto)
+}
+
+// handleCancelSend undoes a pending send: the email becomes a draft again
+func (s *Server) handleCancelSend(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.app.Send().CancelPendingSend(r.Context(), id); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	var account = s.app.GetCurrentAccount()
+	if account == nil {
+		writeError(w, http.StatusNotFound, "no account")
+		return
+	}
+
+	var tasks []ports.TaskInfo
+	var err error
+	switch r.URL.Query().Get("status") {
+	case "pending":
+		tasks, err = s.app.Tasks().GetPendingTasks(r.Context(), account.ID)
+	case "completed":
+		var limit, ok = parseLimit(w, r)
+		if !ok {
+			return
+		}
+		tasks, err = s.app.Tasks().GetCompletedTasks(r.Context(), account.ID, limit)
+	case "", "all":
+		tasks, err = s.app.Tasks().GetTasks(r.Context(), account.ID)
+	default:
+		writeError(w, http.StatusBadRequest, "status must be pending, completed or all")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var result = make([]TaskDTO, 0, len(tasks))
+	for i := range tasks {
+		result = append(result, NewTaskDTO(&tasks[i]))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
+	var account = s.app.GetCurrentAccount()
+	if account == nil {
+		writeError(w, http.StatusNotFound, "no account")
+		return
+	}
+	var req TaskInputDTO
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		writeError(w, http.StatusBadRequest, "title is required")
+		return
+	}
+
+	var task, err = s.app.Tasks().CreateTask(r.Context(), &ports.TaskInput{
+		AccountID:   account.ID,
+		Title:       req.Title,
+		Description: req.Description,
+		IsCompleted: req.IsCompleted,
+		Priority:    ports.TaskPriority(req.Priority),
+		DueDate:     req.DueDate,
+		EmailID:     req.EmailID,
+		Source:      ports.TaskSourceManual,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, NewTaskDTO(task))
+}
+
+func (s *Server) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	var existing, err = s.app.Tasks().GetTask(r.Context(), id)
+	if err != nil || existing == nil {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+
+	// Start from the stored task so omitted fields keep their values
+	var req = TaskInputDTO{
+		Title:       existing.Title,
+		Description: existing.Description,
+		IsCompleted: existing.IsCompleted,
+		Priority:    int(existing.Priority),
+		DueDate:     existing.DueDate,
+		EmailID:     existing.EmailID,
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var task, updateErr = s.app.Tasks().UpdateTask(r.Context(), &ports.TaskInput{
+		ID:          id,
+		AccountID:   existing.AccountID,
+		Title:       req.Title,
+		Description: req.Description,
+		IsCompleted: req.IsCompleted,
+		Priority:    ports.TaskPriority(req.Priority),
+		DueDate:     req.DueDate,
+		EmailID:     req.EmailID,
+		Source:      existing.Source,
+	})
+	if updateErr != nil {
+		writeError(w, http.StatusInternalServerError, updateErr.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, NewTaskDTO(task))
+}
+
+func (s *Server) handleToggleTask(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	var completed, err = s.app.Tasks().ToggleTaskCompleted(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"isCompleted": completed})
+}
+
+func (s *Server) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
+	var id, ok = pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.app.Tasks().DeleteTask(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pathID parses the {id} path value, writing a 400 on failure
+func pathID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	var id, err = strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+// parseLimit parses ?limit=, writing a 400 on failure
+func parseLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	var raw = r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultLimit, true
+	}
+	var limit, err = strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return 0, false
+	}
+	return min(limit, maxLimit), true
+}
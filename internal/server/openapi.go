@@ -0,0 +1,16 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes every route in routes(); server_test.go keeps them in sync
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,239 @@
+// Package server exposes ports.App over a local HTTP/JSON API.
+// It is meant for scripts, automations and dashboards: it only listens on
+// loopback addresses and every request (except health and the OpenAPI
+// description) must carry the API token.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// DefaultAddr is the default listen address
+const DefaultAddr = "127.0.0.1:8421"
+
+// apiPrefix is the prefix of every API route
+const apiPrefix = "/api/v1"
+
+// Options configures the server
+type Options struct {
+	Addr  string // host:port, must be a loopback address (default: 127.0.0.1:8421)
+	Token string // required bearer token
+}
+
+// Server serves the REST API and the event stream
+type Server struct {
+	app   ports.App
+	opts  Options
+	hub   *eventHub
+	mux   *http.ServeMux
+	http  *http.Server
+	unsub func()
+}
+
+// route is a single API endpoint; the same table feeds the mux and the OpenAPI test
+type route struct {
+	method  string
+	path    string // relative to apiPrefix, Go 1.22 pattern syntax
+	public  bool   // no token required
+	handler http.HandlerFunc
+}
+
+// New creates a server for app. It fails if the address is not loopback.
+func New(app ports.App, opts Options) (*Server, error) {
+	if app == nil {
+		return nil, fmt.Errorf("app is required")
+	}
+	if opts.Addr == "" {
+		opts.Addr = DefaultAddr
+	}
+	if opts.Token == "" {
+		return nil, fmt.Errorf("an API token is required")
+	}
+	if err := checkLoopback(opts.Addr); err != nil {
+		return nil, err
+	}
+
+	var s = &Server{
+		app:  app,
+		opts: opts,
+		hub:  newEventHub(),
+		mux:  http.NewServeMux(),
+	}
+	for _, r := range s.routes() {
+		var h = r.handler
+		if !r.public {
+			h = s.requireToken(h)
+		}
+		s.mux.HandleFunc(r.method+" "+apiPrefix+r.path, h)
+	}
+	return s, nil
+}
+
+// Handler returns the HTTP handler (host check applied)
+func (s *Server) Handler() http.Handler {
+	return s.checkHost(s.mux)
+}
+
+// ListenAndServe serves until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if events := s.app.Events(); events != nil {
+		s.unsub = events.SubscribeAll(s.hub.publish)
+	}
+	defer func() {
+		if s.unsub != nil {
+			s.unsub()
+		}
+		s.hub.close()
+	}()
+
+	var listener, err = net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.http = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	var errCh = make(chan error, 1)
+	go func() {
+		errCh <- s.http.Serve(listener)
+	}()
+
+	log.Printf("[server] listening on http://%s%s", listener.Addr(), apiPrefix)
+
+	select {
+	case <-ctx.Done():
+		// Closing the hub ends open event streams so Shutdown doesn't wait on them
+		s.hub.close()
+		var shutdownCtx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return s.http.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
+
+// requireToken checks the bearer token in constant time.
+// The events endpoint also accepts ?access_token= because browsers'
+// EventSource cannot set headers.
+func (s *Server) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" && r.URL.Path == apiPrefix+"/events" {
+			token = r.URL.Query().Get("access_token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="miau"`)
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// checkHost rejects requests whose Host is not loopback (DNS rebinding protection)
+func (s *Server) checkHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var host = r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !isLoopbackHost(host) {
+			writeError(w, http.StatusForbidden, "host not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkLoopback validates that addr only listens on loopback
+func checkLoopback(addr string) error {
+	var host, _, err = net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	if !isLoopbackHost(host) {
+		return fmt.Errorf("refusing to listen on %q: only loopback addresses are allowed", addr)
+	}
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	var ip = net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// LoadOrCreateToken reads the token stored at path, creating a random one
+// (0600) if the file doesn't exist
+func LoadOrCreateToken(path string) (string, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		var token = strings.TrimSpace(string(data))
+		if token != "" {
+			return token, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	var buf = make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var token = hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// writeJSON writes v with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if v != nil {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+// writeError writes an ErrorDTO
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, ErrorDTO{Error: msg})
+}
+
+// readJSON decodes the request body into v (unknown fields are rejected)
+func readJSON(r *http.Request, v interface{}) error {
+	var dec = json.NewDecoder(http.MaxBytesReader(nil, r.Body, 25<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+const testToken = "secret-token"
+
+// fakeApp implements only what the tests use; other methods panic via the nil embedded interface
+type fakeApp struct {
+	ports.App
+	email *fakeEmailService
+}
+
+func (a *fakeApp) Email() ports.EmailService { return a.email }
+func (a *fakeApp) Events() ports.EventBus    { return nil }
+func (a *fakeApp) Sync() ports.SyncService   { return nil }
+func (a *fakeApp) GetCurrentAccount() *ports.AccountInfo {
+	return &ports.AccountInfo{ID: 1, Email: "me@example.com", Name: "Me"}
+}
+
+type fakeEmailService struct {
+	ports.EmailService
+	emails     []ports.EmailMetadata
+	gotFolder  string
+	gotLimit   int
+	readCalled map[int64]bool
+}
+
+func (s *fakeEmailService) GetEmails(ctx context.Context, folder string, limit int) ([]ports.EmailMetadata, error) {
+	s.gotFolder, s.gotLimit = folder, limit
+	return s.emails, nil
+}
+
+func (s *fakeEmailService) MarkAsRead(ctx context.Context, id int64, read bool) error {
+	if s.readCalled == nil {
+		s.readCalled = make(map[int64]bool)
+	}
+	s.readCalled[id] = read
+	return nil
+}
+
+func newTestServer(t *testing.T) (*Server, *fakeEmailService) {
+	t.Helper()
+	var email = &fakeEmailService{
+		emails: []ports.EmailMetadata{{ID: 7, UID: 70, Subject: "Hello", FromEmail: "a@example.com"}},
+	}
+	var s, err = New(&fakeApp{email: email}, Options{Token: testToken})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s, email
+}
+
+func doRequest(s *Server, method, target, token, body string) *httptest.ResponseRecorder {
+	var req = httptest.NewRequest(method, "http://127.0.0.1:8421"+target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	var rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewRejectsNonLoopback(t *testing.T) {
+	var app = &fakeApp{email: &fakeEmailService{}}
+	for _, addr := range []string{"0.0.0.0:8421", "192.168.1.10:8421", ":8421"} {
+		if _, err := New(app, Options{Addr: addr, Token: testToken}); err == nil {
+			t.Errorf("New(%q) should fail", addr)
+		}
+	}
+	for _, addr := range []string{"127.0.0.1:0", "[::1]:8421", "localhost:8421"} {
+		if _, err := New(app, Options{Addr: addr, Token: testToken}); err != nil {
+			t.Errorf("New(%q): %v", addr, err)
+		}
+	}
+	if _, err := New(app, Options{}); err == nil {
+		t.Error("New without token should fail")
+	}
+}
+
+func TestAuth(t *testing.T) {
+	var s, _ = newTestServer(t)
+
+	if rec := doRequest(s, "GET", "/api/v1/health", "", ""); rec.Code != http.StatusOK {
+		t.Errorf("health without token: got %d, want 200", rec.Code)
+	}
+	if rec := doRequest(s, "GET", "/api/v1/emails", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("emails without token: got %d, want 401", rec.Code)
+	}
+	if rec := doRequest(s, "GET", "/api/v1/emails", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("emails with wrong token: got %d, want 401", rec.Code)
+	}
+	if rec := doRequest(s, "GET", "/api/v1/emails?access_token="+testToken, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("access_token outside /events: got %d, want 401", rec.Code)
+	}
+	if rec := doRequest(s, "GET", "/api/v1/emails", testToken, ""); rec.Code != http.StatusOK {
+		t.Errorf("emails with token: got %d, want 200", rec.Code)
+	}
+}
+
+func TestHostCheck(t *testing.T) {
+	var s, _ = newTestServer(t)
+	var req = httptest.NewRequest("GET", "http://evil.example.com/api/v1/health", nil)
+	var rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("foreign Host: got %d, want 403", rec.Code)
+	}
+}
+
+func TestListEmails(t *testing.T) {
+	var s, email = newTestServer(t)
+
+	var rec = doRequest(s, "GET", "/api/v1/emails?folder=Archive&limit=5000", testToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if email.gotFolder != "Archive" || email.gotLimit != maxLimit {
+		t.Errorf("GetEmails(%q, %d), want (Archive, %d)", email.gotFolder, email.gotLimit, maxLimit)
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(got) != 1 || got[0]["subject"] != "Hello" || got[0]["fromEmail"] != "a@example.com" {
+		t.Errorf("unexpected body: %s", rec.Body)
+	}
+
+	if rec := doRequest(s, "GET", "/api/v1/emails?limit=abc", testToken, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid limit: got %d, want 400", rec.Code)
+	}
+}
+
+func TestUpdateEmail(t *testing.T) {
+	var s, email = newTestServer(t)
+
+	var rec = doRequest(s, "PATCH", "/api/v1/emails/7", testToken, `{"isRead":true}`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if read, ok := email.readCalled[7]; !ok || !read {
+		t.Errorf("MarkAsRead not called: %v", email.readCalled)
+	}
+
+	if rec := doRequest(s, "PATCH", "/api/v1/emails/7", testToken, `{"unknown":1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown field: got %d, want 400", rec.Code)
+	}
+	if rec := doRequest(s, "PATCH", "/api/v1/emails/abc", testToken, `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: got %d, want 400", rec.Code)
+	}
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var s, _ = newTestServer(t)
+
+	var spec struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+
+	for _, r := range s.routes() {
+		var ops, ok = spec.Paths[r.path]
+		if !ok {
+			t.Errorf("openapi.json is missing path %s", r.path)
+			continue
+		}
+		if _, ok := ops[strings.ToLower(r.method)]; !ok {
+			t.Errorf("openapi.json is missing %s %s", r.method, r.path)
+		}
+	}
+
+	var rec = doRequest(s, "GET", "/api/v1/openapi.json", "", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("GET openapi.json: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	var s, _ = newTestServer(t)
+	var ts = httptest.NewServer(s.Handler())
+	defer ts.Close()
+	defer s.hub.close()
+
+	var req, _ = http.NewRequest("GET", ts.URL+"/api/v1/events?types=new_email&access_token="+testToken, nil)
+	var resp, err = ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %d, want 200", resp.StatusCode)
+	}
+
+	var reader = bufio.NewReader(resp.Body)
+	// Wait for the handshake comment so the client is subscribed
+	if line, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, ": connected") {
+		t.Fatalf("unexpected first line %q: %v", line, err)
+	}
+
+	// Filtered out, then delivered
+	s.hub.publish(ports.EmailReadEvent{BaseEvent: ports.NewBaseEvent(ports.EventTypeEmailRead), EmailID: 1, Read: true})
+	s.hub.publish(ports.NewEmailEvent{
+		BaseEvent: ports.NewBaseEvent(ports.EventTypeNewEmail),
+		Email:     ports.EmailMetadata{ID: 9, Subject: "Ping"},
+	})
+
+	var done = make(chan struct{})
+	var event, data string
+	go func() {
+		defer close(done)
+		for {
+			var line, err = reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	if event != "new_email" {
+		t.Errorf("event = %q, want new_email", event)
+	}
+	var ev streamEvent
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		t.Fatalf("invalid data %q: %v", data, err)
+	}
+	var email, _ = ev.Data["email"].(map[string]interface{})
+	if ev.Type != "new_email" || email["subject"] != "Ping" {
+		t.Errorf("unexpected event: %s", data)
+	}
+}
+
+func TestLowerFirst(t *testing.T) {
+	var tests = map[string]string{
+		"EmailID":     "emailID",
+		"UIDValidity": "uidValidity",
+		"Folder":      "folder",
+		"ID":          "id",
+	}
+	for in, want := range tests {
+		if got := lowerFirst(in); got != want {
+			t.Errorf("lowerFirst(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLoadOrCreateToken(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "sub", "server.token")
+
+	var token, err = LoadOrCreateToken(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if len(token) != 64 {
+		t.Errorf("token length = %d, want 64", len(token))
+	}
+	var info, _ = os.Stat(path)
+	if info == nil || info.Mode().Perm() != 0600 {
+		t.Errorf("token file mode = %v, want 0600", info)
+	}
+
+	var again, _ = LoadOrCreateToken(path)
+	if again != token {
+		t.Errorf("token changed on reload: %q != %q", again, token)
+	}
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// EmailDTO is an email in listings
+type EmailDTO struct {
+	ID             int64     `json:"id"`
+	UID            uint32    `json:"uid"`
+	MessageID      string    `json:"messageId,omitempty"`
+	Subject        string    `json:"subject"`
+	FromName       string    `json:"fromName"`
+	FromEmail      string    `json:"fromEmail"`
+	ToAddress      string    `json:"toAddress,omitempty"`
+	Date           time.Time `json:"date"`
+	IsRead         bool      `json:"isRead"`
+	IsStarred      bool      `json:"isStarred"`
+	IsReplied      bool      `json:"isReplied"`
+	HasAttachments bool      `json:"hasAttachments"`
+	Snippet        string    `json:"snippet"`
+	ThreadID       string    `json:"threadId,omitempty"`
+	ThreadCount    int       `json:"threadCount,omitempty"`
+}
+
+// EmailDetailDTO is a full email
+type EmailDetailDTO struct {
+	EmailDTO
+	Folder      string          `json:"folder"`
+	ToAddresses string          `json:"toAddresses"`
+	CcAddresses string          `json:"ccAddresses"`
+	BodyText    string          `json:"bodyText"`
+	BodyHTML    string          `json:"bodyHtml"`
+	Attachments []AttachmentDTO `json:"attachments"`
+}
+
+// AttachmentDTO is attachment metadata (content is not included)
+type AttachmentDTO struct {
+	ID          int64  `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	ContentID   string `json:"contentId,omitempty"`
+	Size        int64  `json:"size"`
+	IsInline    bool   `json:"isInline"`
+}
+
+// FolderDTO is a mail folder
+type FolderDTO struct {
+	ID             int64      `json:"id"`
+	Name           string     `json:"name"`
+	TotalMessages  int        `json:"totalMessages"`
+	UnreadMessages int        `json:"unreadMessages"`
+	LastSync       *time.Time `json:"lastSync,omitempty"`
+}
+
+// AccountDTO is the current account
+type AccountDTO struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+// SearchResultDTO is a search response
+type SearchResultDTO struct {
+	Query      string     `json:"query"`
+	TotalCount int        `json:"totalCount"`
+	Emails     []EmailDTO `json:"emails"`
+}
+
+// SyncResultDTO is the result of a folder sync
+type SyncResultDTO struct {
+	Folder        string   `json:"folder,omitempty"`
+	NewEmails     int      `json:"newEmails"`
+	DeletedEmails int      `json:"deletedEmails"`
+	FlagUpdates   int      `json:"flagUpdates"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+// SendRequestDTO is the body of POST /send
+type SendRequestDTO struct {
+	To             []string `json:"to"`
+	Cc             []string `json:"cc,omitempty"`
+	Bcc            []string `json:"bcc,omitempty"`
+	Subject        string   `json:"subject"`
+	BodyText       string   `json:"bodyText,omitempty"`
+	BodyHTML       string   `json:"bodyHtml,omitempty"`
+	ReplyToEmailID int64    `json:"replyToEmailId,omitempty"`
+	Classification string   `json:"classification,omitempty"`
+}
+
+// SendResultDTO is the result of a send
+type SendResultDTO struct {
+	Success   bool       `json:"success"`
+	MessageID string     `json:"messageId,omitempty"`
+	Queued    bool       `json:"queued,omitempty"`
+	Pending   bool       `json:"pending,omitempty"`
+	SendAt    *time.Time `json:"sendAt,omitempty"`
+	OutboxID  int64      `json:"outboxId,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// EmailUpdateDTO is the body of PATCH /emails/{id}
+type EmailUpdateDTO struct {
+	IsRead    *bool `json:"isRead,omitempty"`
+	IsStarred *bool `json:"isStarred,omitempty"`
+}
+
+// MoveRequestDTO is the body of POST /emails/{id}/move
+type MoveRequestDTO struct {
+	Folder string `json:"folder"`
+}
+
+// SnoozeRequestDTO is the body of POST /emails/{id}/snooze.
+// Either Until or Preset must be set.
+type SnoozeRequestDTO struct {
+	Until  *time.Time `json:"until,omitempty"`
+	Preset string     `json:"preset,omitempty"`
+}
+
+// SnoozedEmailDTO is a snoozed email
+type SnoozedEmailDTO struct {
+	EmailID     int64     `json:"emailId"`
+	SnoozedAt   time.Time `json:"snoozedAt"`
+	SnoozeUntil time.Time `json:"snoozeUntil"`
+	Preset      string    `json:"preset"`
+	Email       *EmailDTO `json:"email,omitempty"`
+}
+
+// TaskDTO is a task
+type TaskDTO struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	IsCompleted bool       `json:"isCompleted"`
+	Priority    int        `json:"priority"`
+	DueDate     *time.Time `json:"dueDate,omitempty"`
+	EmailID     *int64     `json:"emailId,omitempty"`
+	Source      string     `json:"source"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// TaskInputDTO is the body of POST /tasks and PATCH /tasks/{id}
+type TaskInputDTO struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	IsCompleted bool       `json:"isCompleted,omitempty"`
+	Priority    int        `json:"priority,omitempty"`
+	DueDate     *time.Time `json:"dueDate,omitempty"`
+	EmailID     *int64     `json:"emailId,omitempty"`
+}
+
+// ErrorDTO is returned for every non-2xx response
+type ErrorDTO struct {
+	Error string `json:"error"`
+}
+
+// NewEmailDTO converts an email for the API (also used by the CLI JSON output)
+func NewEmailDTO(e *ports.EmailMetadata) EmailDTO {
+	return EmailDTO{
+		ID:             e.ID,
+		UID:            e.UID,
+		MessageID:      e.MessageID,
+		Subject:        e.Subject,
+		FromName:       e.FromName,
+		FromEmail:      e.FromEmail,
+		ToAddress:      e.ToAddress,
+		Date:           e.Date,
+		IsRead:         e.IsRead,
+		IsStarred:      e.IsStarred,
+		IsReplied:      e.IsReplied,
+		HasAttachments: e.HasAttachments,
+		Snippet:        e.Snippet,
+		ThreadID:       e.ThreadID,
+		ThreadCount:    e.ThreadCount,
+	}
+}
+
+// NewEmailDTOs converts a list of emails
+func NewEmailDTOs(emails []ports.EmailMetadata) []EmailDTO {
+	var result = make([]EmailDTO, 0, len(emails))
+	for i := range emails {
+		result = append(result, NewEmailDTO(&emails[i]))
+	}
+	return result
+}
+
+// NewEmailDetailDTO converts a full email
+func NewEmailDetailDTO(e *ports.EmailContent) EmailDetailDTO {
+	var attachments = make([]AttachmentDTO, 0, len(e.Attachments))
+	for _, a := range e.Attachments {
+		attachments = append(attachments, AttachmentDTO{
+			ID:          a.ID,
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			ContentID:   a.ContentID,
+			Size:        a.Size,
+			IsInline:    a.IsInline,
+		})
+	}
+	return EmailDetailDTO{
+		EmailDTO:    NewEmailDTO(&e.EmailMetadata),
+		Folder:      e.FolderName,
+		ToAddresses: e.ToAddresses,
+		CcAddresses: e.CcAddresses,
+		BodyText:    e.BodyText,
+		BodyHTML:    e.BodyHTML,
+		Attachments: attachments,
+	}
+}
+
+// NewTaskDTO converts a task
+func NewTaskDTO(t *ports.TaskInfo) TaskDTO {
+	return TaskDTO{
+		ID:          t.ID,
+		Title:       t.Title,
+		Description: t.Description,
+		IsCompleted: t.IsCompleted,
+		Priority:    int(t.Priority),
+		DueDate:     t.DueDate,
+		EmailID:     t.EmailID,
+		Source:      string(t.Source),
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
+
+// NewSyncResultDTO converts a sync result; errors become strings
+func NewSyncResultDTO(folder string, r *ports.SyncResult) SyncResultDTO {
+	var dto = SyncResultDTO{Folder: folder}
+	if r == nil {
+		return dto
+	}
+	dto.NewEmails = r.NewEmails
+	dto.DeletedEmails = r.DeletedEmails
+	dto.FlagUpdates = r.FlagUpdates
+	for _, err := range r.Errors {
+		if err != nil {
+			dto.Errors = append(dto.Errors, err.Error())
+		}
+	}
+	return dto
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// accountSet tracks the accounts of a multi-account runtime and the IMAP
+// connection of each, so a service can act on emails of any account
+// (e.g. from the unified inbox) over the right connection.
+type accountSet struct {
+	mu       sync.RWMutex
+	accounts []*ports.AccountInfo
+	imap     map[int64]ports.IMAPPort
+	gmail    map[int64]ports.GmailAPIPort
+}
+
+// add registers an account; adding it again replaces its connection
+func (s *accountSet) add(account *ports.AccountInfo, imap ports.IMAPPort) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.imap == nil {
+		s.imap = make(map[int64]ports.IMAPPort)
+	}
+	if _, ok := s.imap[account.ID]; !ok {
+		s.accounts = append(s.accounts, account)
+	}
+	s.imap[account.ID] = imap
+}
+
+// imapFor returns the connection of an account, or fallback for unknown
+// accounts (single-account setups never register any)
+func (s *accountSet) imapFor(accountID int64, fallback ports.IMAPPort) ports.IMAPPort {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if imap, ok := s.imap[accountID]; ok && imap != nil {
+		return imap
+	}
+	return fallback
+}
+
+// list returns the registered accounts in registration order
+func (s *accountSet) list() []*ports.AccountInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*ports.AccountInfo(nil), s.accounts...)
+}
+
+// setGmail registers the Gmail API client of an account (nil clears it)
+func (s *accountSet) setGmail(accountID int64, gmail ports.GmailAPIPort) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.gmail == nil {
+		s.gmail = make(map[int64]ports.GmailAPIPort)
+	}
+	if gmail == nil {
+		delete(s.gmail, accountID)
+		return
+	}
+	s.gmail[accountID] = gmail
+}
+
+// gmailFor returns the Gmail API client of an account, or nil when the
+// account has none (not a Gmail account, or no OAuth2 token)
+func (s *accountSet) gmailFor(accountID int64) ports.GmailAPIPort {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.gmail[accountID]
+}
@@ -1,13 +1,12 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"os/exec"
 	"strings"
 	"sync"
 
+	"github.com/opik/miau/internal/ai"
 	"github.com/opik/miau/internal/ports"
 	"github.com/opik/miau/internal/storage"
 )
@@ -16,20 +15,94 @@ import (
 // CRITICAL: This is the SINGLE SOURCE OF TRUTH for AI logic
 // TUI and Desktop MUST use this service, NEVER call AI CLIs directly
 type AIService struct {
-	mu      sync.RWMutex
-	storage ports.StoragePort
-	events  ports.EventBus
-	account *ports.AccountInfo
+	mu       sync.RWMutex
+	storage  ports.StoragePort
+	events   ports.EventBus
+	account  *ports.AccountInfo
+	provider ports.AIProvider
+
+	// newProvider builds a provider by name (desktop provider selector)
+	newProvider func(name string) (ports.AIProvider, error)
 }
 
-// NewAIService creates a new AIService
+// NewAIService creates a new AIService.
+// It uses the claude CLI until SetProvider is called.
 func NewAIService(storage ports.StoragePort, events ports.EventBus) *AIService {
+	var provider, _ = ai.New(ai.Options{Provider: ai.ProviderClaudeCLI})
 	return &AIService{
-		storage: storage,
-		events:  events,
+		storage:  storage,
+		events:   events,
+		provider: provider,
 	}
 }
 
+// SetProvider sets the default AI provider
+func (s *AIService) SetProvider(provider ports.AIProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.provider = provider
+}
+
+// SetProviderFactory sets the function used to build providers requested by name
+func (s *AIService) SetProviderFactory(factory func(name string) (ports.AIProvider, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.newProvider = factory
+}
+
+// ProviderName returns the name of the default provider
+func (s *AIService) ProviderName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.provider == nil {
+		return ""
+	}
+	return s.provider.Name()
+}
+
+// Ask sends a free-form prompt. An empty provider uses the default one.
+func (s *AIService) Ask(ctx context.Context, provider, prompt string) (string, error) {
+	return s.AskStream(ctx, provider, prompt, nil)
+}
+
+// AskStream is like Ask but calls onDelta for each chunk as it arrives
+func (s *AIService) AskStream(ctx context.Context, provider, prompt string, onDelta ports.AIStreamFunc) (string, error) {
+	var p, err = s.providerFor(provider)
+	if err != nil {
+		return "", err
+	}
+
+	var resp *ports.AIResponse
+	if onDelta != nil {
+		resp, err = p.Stream(ctx, &ports.AIRequest{Prompt: prompt}, onDelta)
+	} else {
+		resp, err = p.Complete(ctx, &ports.AIRequest{Prompt: prompt})
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+// providerFor returns the default provider or builds the named one
+func (s *AIService) providerFor(name string) (ports.AIProvider, error) {
+	s.mu.RLock()
+	var provider = s.provider
+	var factory = s.newProvider
+	s.mu.RUnlock()
+
+	if name == "" || (provider != nil && provider.Name() == name) {
+		if provider == nil {
+			return nil, fmt.Errorf("no AI provider configured")
+		}
+		return provider, nil
+	}
+	if factory == nil {
+		return nil, fmt.Errorf("AI provider not available: %s", name)
+	}
+	return factory(name)
+}
+
 // SetAccount sets the current account
 func (s *AIService) SetAccount(account *ports.AccountInfo) {
 	s.mu.Lock()
@@ -54,8 +127,8 @@ func (s *AIService) Summarize(ctx context.Context, emailID int64) (string, error
 	// Build prompt for summarization (brief style by default)
 	var prompt = s.buildSummarizePromptWithStyle(email, ports.SummaryStyleBrief)
 
-	// Call AI CLI
-	var response, aiErr = s.callAI(ctx, "claude", prompt)
+	// Call AI provider
+	var response, aiErr = s.callAI(ctx, prompt)
 	if aiErr != nil {
 		return "", aiErr
 	}
@@ -92,8 +165,8 @@ func (s *AIService) SummarizeWithStyle(ctx context.Context, emailID int64, style
 	// Build prompt for summarization with specific style
 	var prompt = s.buildSummarizePromptWithStyle(email, style)
 
-	// Call AI CLI
-	var response, aiErr = s.callAI(ctx, "claude", prompt)
+	// Call AI provider
+	var response, aiErr = s.callAI(ctx, prompt)
 	if aiErr != nil {
 		return nil, fmt.Errorf("AI failed to generate summary: %w", aiErr)
 	}
@@ -187,8 +260,8 @@ func (s *AIService) SummarizeThread(ctx context.Context, emailID int64) (string,
 	// Build thread prompt
 	var prompt = s.buildThreadSummarizePrompt(emails)
 
-	// Call AI CLI
-	var response, aiErr = s.callAI(ctx, "claude", prompt)
+	// Call AI provider
+	var response, aiErr = s.callAI(ctx, prompt)
 	if aiErr != nil {
 		return "", aiErr
 	}
@@ -257,8 +330,8 @@ func (s *AIService) SummarizeThreadDetailed(ctx context.Context, emailID int64)
 	// Build detailed thread prompt
 	var prompt = s.buildDetailedThreadSummarizePrompt(emails)
 
-	// Call AI CLI
-	var response, aiErr = s.callAI(ctx, "claude", prompt)
+	// Call AI provider
+	var response, aiErr = s.callAI(ctx, prompt)
 	if aiErr != nil {
 		return nil, fmt.Errorf("AI failed to summarize thread: %w", aiErr)
 	}
@@ -317,8 +390,8 @@ func (s *AIService) GenerateReply(ctx context.Context, emailID int64, userPrompt
 	// Build prompt for reply generation
 	var prompt = s.buildReplyPrompt(email, userPrompt)
 
-	// Call AI CLI
-	var reply, aiErr = s.callAI(ctx, "claude", prompt)
+	// Call AI provider
+	var reply, aiErr = s.callAI(ctx, prompt)
 	if aiErr != nil {
 		return nil, fmt.Errorf("AI failed to generate reply: %w", aiErr)
 	}
@@ -350,8 +423,8 @@ func (s *AIService) ExtractActions(ctx context.Context, emailID int64) ([]string
 	// Build prompt for action extraction
 	var prompt = s.buildExtractActionsPrompt(email)
 
-	// Call AI CLI
-	var response, aiErr = s.callAI(ctx, "claude", prompt)
+	// Call AI provider
+	var response, aiErr = s.callAI(ctx, prompt)
 	if aiErr != nil {
 		return nil, fmt.Errorf("AI failed to extract actions: %w", aiErr)
 	}
@@ -373,8 +446,8 @@ func (s *AIService) ClassifyEmail(ctx context.Context, emailID int64) (string, e
 	// Build prompt for classification
 	var prompt = s.buildClassifyPrompt(email)
 
-	// Call AI CLI
-	return s.callAI(ctx, "claude", prompt)
+	// Call AI provider
+	return s.callAI(ctx, prompt)
 }
 
 // buildSummarizePrompt builds the prompt for email summarization
@@ -705,44 +778,21 @@ func (s *AIService) parseActionItems(response string) []string {
 	return actions
 }
 
-// callAI calls the AI CLI with the given prompt
-func (s *AIService) callAI(ctx context.Context, provider, prompt string) (string, error) {
-	var cmd *exec.Cmd
-
-	switch provider {
-	case "claude":
-		cmd = exec.CommandContext(ctx, "claude", "-p", "--permission-mode", "bypassPermissions", prompt)
-	case "gemini":
-		cmd = exec.CommandContext(ctx, "gemini", prompt)
-	case "ollama":
-		cmd = exec.CommandContext(ctx, "ollama", "run", "llama3", prompt)
-	case "openai":
-		cmd = exec.CommandContext(ctx, "openai", "api", "chat.completions", "create",
-			"-m", "gpt-4o-mini",
-			"-g", "user", prompt)
-	default:
-		// Default to claude
-		cmd = exec.CommandContext(ctx, "claude", "-p", "--permission-mode", "bypassPermissions", prompt)
+// callAI sends the prompt to the default provider
+func (s *AIService) callAI(ctx context.Context, prompt string) (string, error) {
+	var provider, err = s.providerFor("")
+	if err != nil {
+		return "", err
 	}
 
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	var err = cmd.Run()
-	if err != nil {
+	var resp, aiErr = provider.Complete(ctx, &ports.AIRequest{Prompt: prompt})
+	if aiErr != nil {
 		// Check if it's a context cancellation
 		if ctx.Err() != nil {
 			return "", ctx.Err()
 		}
-		return "", fmt.Errorf("AI command failed: %w - stderr: %s", err, stderr.String())
-	}
-
-	var response = strings.TrimSpace(stdout.String())
-	if response == "" {
-		return "", fmt.Errorf("AI returned empty response")
+		return "", fmt.Errorf("AI request failed (%s): %w", provider.Name(), aiErr)
 	}
 
-	return response, nil
+	return resp.Text, nil
 }
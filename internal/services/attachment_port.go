@@ -19,6 +19,9 @@ type AttachmentServicePort struct {
 	imap    ports.IMAPPort
 	account *ports.AccountInfo
 	folder  *ports.Folder
+
+	// Other accounts of a multi-account runtime (unified inbox)
+	accounts accountSet
 }
 
 // NewAttachmentServicePort creates a new AttachmentServicePort
@@ -50,6 +53,20 @@ func (s *AttachmentServicePort) SetIMAPAdapter(imap ports.IMAPPort) {
 	s.imap = imap
 }
 
+// AddAccount registers an account of the multi-account runtime, so
+// attachments of its emails are fetched over its own connection
+func (s *AttachmentServicePort) AddAccount(account *ports.AccountInfo, imap ports.IMAPPort) {
+	s.accounts.add(account, imap)
+}
+
+// imapFor returns the IMAP connection of the account an email belongs to
+func (s *AttachmentServicePort) imapFor(accountID int64) ports.IMAPPort {
+	s.mu.RLock()
+	var imap = s.imap
+	s.mu.RUnlock()
+	return s.accounts.imapFor(accountID, imap)
+}
+
 // GetAttachments returns all attachments for an email
 // First tries to get from database, then falls back to IMAP if not found
 func (s *AttachmentServicePort) GetAttachments(ctx context.Context, emailID int64) ([]ports.Attachment, error) {
@@ -66,19 +83,20 @@ func (s *AttachmentServicePort) GetAttachments(ctx context.Context, emailID int6
 	}
 
 	// Check if IMAP is connected
-	if !s.imap.IsConnected() {
+	var imap = s.imapFor(email.AccountID)
+	if !imap.IsConnected() {
 		return nil, fmt.Errorf("not connected to IMAP server")
 	}
 
 	// Select mailbox
 	if email.FolderName != "" {
-		if _, selErr := s.imap.SelectMailbox(ctx, email.FolderName); selErr != nil {
+		if _, selErr := imap.SelectMailbox(ctx, email.FolderName); selErr != nil {
 			return nil, fmt.Errorf("failed to select mailbox: %w", selErr)
 		}
 	}
 
 	// Fetch attachment metadata via BODYSTRUCTURE
-	var imapAtts, hasAtts, fetchErr = s.imap.FetchAttachmentMetadata(ctx, email.UID)
+	var imapAtts, hasAtts, fetchErr = imap.FetchAttachmentMetadata(ctx, email.UID)
 	if fetchErr != nil {
 		return nil, fmt.Errorf("failed to fetch attachment metadata: %w", fetchErr)
 	}
@@ -149,13 +167,14 @@ func (s *AttachmentServicePort) Download(ctx context.Context, id int64) ([]byte,
 
 	// We need to connect and fetch from IMAP
 	// For now, return error if not connected
-	if !s.imap.IsConnected() {
+	var imap = s.imapFor(email.AccountID)
+	if !imap.IsConnected() {
 		return nil, fmt.Errorf("not connected to IMAP server")
 	}
 
 	// Select mailbox
 	if email.FolderName != "" {
-		if _, err := s.imap.SelectMailbox(ctx, email.FolderName); err != nil {
+		if _, err := imap.SelectMailbox(ctx, email.FolderName); err != nil {
 			return nil, fmt.Errorf("failed to select mailbox: %w", err)
 		}
 	}
@@ -191,20 +210,21 @@ func (s *AttachmentServicePort) DownloadByPart(ctx context.Context, emailID int6
 	}
 
 	// We need to connect and fetch from IMAP
-	if !s.imap.IsConnected() {
+	var imap = s.imapFor(email.AccountID)
+	if !imap.IsConnected() {
 		return nil, fmt.Errorf("not connected to IMAP server")
 	}
 
 	// Select mailbox
 	if email.FolderName != "" {
-		if _, mailboxErr := s.imap.SelectMailbox(ctx, email.FolderName); mailboxErr != nil {
+		if _, mailboxErr := imap.SelectMailbox(ctx, email.FolderName); mailboxErr != nil {
 			return nil, fmt.Errorf("failed to select mailbox: %w", mailboxErr)
 		}
 	}
 
 	// Get attachment metadata to find the encoding
 	var encoding = "base64" // default encoding for attachments
-	var attachments, _, metaErr = s.imap.FetchAttachmentMetadata(ctx, email.UID)
+	var attachments, _, metaErr = imap.FetchAttachmentMetadata(ctx, email.UID)
 	if metaErr == nil {
 		for _, att := range attachments {
 			if att.PartNumber == partNumber {
@@ -215,7 +235,7 @@ func (s *AttachmentServicePort) DownloadByPart(ctx context.Context, emailID int6
 	}
 
 	// Fetch the attachment part (raw data)
-	var rawData, fetchErr = s.imap.FetchAttachmentPart(ctx, email.UID, partNumber)
+	var rawData, fetchErr = imap.FetchAttachmentPart(ctx, email.UID, partNumber)
 	if fetchErr != nil {
 		return nil, fmt.Errorf("failed to fetch attachment: %w", fetchErr)
 	}
@@ -0,0 +1,563 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/opik/miau/internal/caldav"
+	"github.com/opik/miau/internal/gmail"
+	"github.com/opik/miau/internal/ical"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+)
+
+// CalDAV sync: every calendarSyncInterval (and right after local changes,
+// see Wake) each account with a CalDAV calendar is synced both ways.
+// Deletions go first, then the server changes are pulled when its ctag
+// moved, then local changes are pushed with If-Match on the ETag last
+// seen. An event changed on both sides becomes a conflict and keeps the
+// server version until the user picks one (ResolveSyncConflict).
+const (
+	calendarSyncInterval = 15 * time.Minute // CalDAV and Google Calendar
+	caldavGetBatch       = 50               // hrefs per calendar-multiget
+)
+
+// SetCalDAV sets the CalDAV client of an account (nil turns its sync off)
+func (s *CalendarService) SetCalDAV(accountID int64, client *caldav.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if client == nil {
+		delete(s.caldav, accountID)
+		return
+	}
+	s.caldav[accountID] = client
+}
+
+// IsCalDAVConnected reports whether the account syncs with a CalDAV server
+func (s *CalendarService) IsCalDAVConnected(accountID int64) bool {
+	return s.caldavClient(accountID) != nil
+}
+
+// caldavClient returns the CalDAV client of an account (nil if none)
+func (s *CalendarService) caldavClient(accountID int64) *caldav.Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.caldav[accountID]
+}
+
+// caldavSyncable reports whether an event is written to the CalDAV server:
+// those already there, and new custom events and meetings created in miau
+// (task deadlines, follow-ups and email invites stay local)
+func (s *CalendarService) caldavSyncable(e *storage.CalendarEvent) bool {
+	if e.CalDAVHref.Valid {
+		return true
+	}
+	if s.caldavClient(e.AccountID) == nil || e.GoogleEventID.String != "" {
+		return false
+	}
+	var source = e.Source == storage.CalendarEventSourceManual || e.Source == storage.CalendarEventSourceCalDAV
+	var kind = e.EventType == storage.CalendarEventTypeCustom || e.EventType == storage.CalendarEventTypeMeeting
+	return source && kind
+}
+
+// SyncCalDAV syncs the account's calendar with its CalDAV server, both ways
+func (s *CalendarService) SyncCalDAV(ctx context.Context, accountID int64) (*ports.CalendarSyncResult, error) {
+	var client = s.caldavClient(accountID)
+	if client == nil {
+		return nil, fmt.Errorf("CalDAV not configured for account %d", accountID)
+	}
+	s.syncing.Lock()
+	defer s.syncing.Unlock()
+
+	var state, err = storage.GetCalendarSyncState(accountID, storage.CalendarSyncCalDAV)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync state: %w", err)
+	}
+	if state == nil {
+		state = &storage.CalendarSyncState{AccountID: accountID, Source: storage.CalendarSyncCalDAV}
+	}
+	var collection, errDiscover = client.Discover(ctx)
+	if errDiscover != nil {
+		return nil, errDiscover
+	}
+	if collection != state.CollectionURL {
+		state.CollectionURL = collection
+		state.CTag = ""
+	}
+
+	var result = &ports.CalendarSyncResult{}
+	if err := s.deleteCalDAV(ctx, client, accountID, result); err != nil {
+		return nil, err
+	}
+
+	var ctag, errCTag = client.CTag(ctx)
+	if errCTag != nil {
+		return nil, errCTag
+	}
+	if ctag == "" || ctag != state.CTag {
+		if err := s.pullCalDAV(ctx, client, accountID, result); err != nil {
+			return nil, err
+		}
+	}
+
+	var events, errPush = storage.GetCalDAVEventsToPush(accountID)
+	if errPush != nil {
+		return nil, fmt.Errorf("failed to get local changes: %w", errPush)
+	}
+	for i := range events {
+		var e = &events[i]
+		if !s.caldavSyncable(e) {
+			continue
+		}
+		var conflict, err = s.pushCalDAV(ctx, client, e)
+		switch {
+		case err != nil:
+			log.Printf("[CalendarService] Failed to push event %d to CalDAV: %v", e.ID, err)
+		case conflict:
+			result.Conflicts++
+		default:
+			result.Pushed++
+		}
+	}
+
+	// Our own writes moved the ctag: the next sync lists the calendar once
+	// and finds the ETags it already has
+	state.CTag = ctag
+	state.LastSyncAt = sql.NullTime{Time: s.now(), Valid: true}
+	if err := storage.SaveCalendarSyncState(state); err != nil {
+		return nil, fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return result, nil
+}
+
+// deleteCalDAV deletes on the server the events deleted locally. An event
+// changed on the server since is left there, and comes back with the pull.
+func (s *CalendarService) deleteCalDAV(ctx context.Context, client *caldav.Client, accountID int64, result *ports.CalendarSyncResult) error {
+	var deletions, err = storage.GetCalendarDeletions(accountID, storage.CalendarSyncCalDAV)
+	if err != nil {
+		return fmt.Errorf("failed to get deletions: %w", err)
+	}
+	for _, d := range deletions {
+		var err = client.Delete(ctx, d.RemoteID, d.ETag)
+		switch {
+		case err == nil:
+			result.Deleted++
+		case caldav.IsNotFound(err), caldav.IsPreconditionFailed(err):
+		default:
+			return fmt.Errorf("failed to delete %s: %w", d.RemoteID, err)
+		}
+		storage.RemoveCalendarDeletion(d.ID)
+	}
+	return nil
+}
+
+// pullCalDAV applies the server changes: new and changed objects, and
+// objects gone from the server
+func (s *CalendarService) pullCalDAV(ctx context.Context, client *caldav.Client, accountID int64, result *ports.CalendarSyncResult) error {
+	var objects, err = client.List(ctx)
+	if err != nil {
+		return err
+	}
+	var events, errLocal = storage.GetCalDAVEvents(accountID)
+	if errLocal != nil {
+		return fmt.Errorf("failed to get events: %w", errLocal)
+	}
+	var local = make(map[string]*storage.CalendarEvent, len(events))
+	for i := range events {
+		local[events[i].CalDAVHref.String] = &events[i]
+	}
+
+	var remote = make(map[string]bool, len(objects))
+	var changed []string
+	for _, o := range objects {
+		remote[o.Href] = true
+		var e = local[o.Href]
+		if e == nil || (e.CalDAVETag.String != o.ETag && e.ConflictETag.String != o.ETag) {
+			changed = append(changed, o.Href)
+		}
+	}
+
+	for start := 0; start < len(changed); start += caldavGetBatch {
+		var end = min(start+caldavGetBatch, len(changed))
+		var fetched, err = client.Get(ctx, changed[start:end])
+		if err != nil {
+			return err
+		}
+		for _, o := range fetched {
+			s.pullObject(accountID, local[o.Href], o, result)
+		}
+	}
+
+	for href, e := range local {
+		if !remote[href] {
+			s.deletedRemotely(e, result)
+		}
+	}
+	return nil
+}
+
+// deletedRemotely applies the deletion of an event on the server: it is
+// deleted locally too, unless it has local changes (then it becomes a
+// conflict)
+func (s *CalendarService) deletedRemotely(e *storage.CalendarEvent, result *ports.CalendarSyncResult) {
+	if e.SyncStatus == storage.CalendarSyncStatusConflict && !e.ConflictData.Valid {
+		return // deletion already known
+	}
+	if e.SyncStatus == storage.CalendarSyncStatusPendingSync || e.SyncStatus == storage.CalendarSyncStatusConflict {
+		if err := s.markConflict(e, "", nil); err == nil {
+			result.Conflicts++
+		}
+		return
+	}
+	if err := storage.DeleteCalendarEvent(e.ID); err == nil {
+		result.Deleted++
+	}
+}
+
+// pullObject applies a new or changed object of the server to its local
+// event (nil for a new one)
+func (s *CalendarService) pullObject(accountID int64, e *storage.CalendarEvent, o caldav.Object, result *ports.CalendarSyncResult) {
+	var cal, err = ical.Parse(o.Data)
+	if err != nil {
+		log.Printf("[CalendarService] Skipping CalDAV object %s: %v", o.Href, err)
+		return
+	}
+	var main = cal.Main()
+	if main == nil || main.Kind != ical.KindEvent {
+		return
+	}
+
+	if e != nil && (e.SyncStatus == storage.CalendarSyncStatusPendingSync || e.SyncStatus == storage.CalendarSyncStatusConflict) {
+		if err := s.markConflict(e, o.ETag, o.Data); err == nil {
+			result.Conflicts++
+		}
+		return
+	}
+
+	if e == nil {
+		var eventType = storage.CalendarEventTypeCustom
+		if len(main.Attendees) > 0 {
+			eventType = storage.CalendarEventTypeMeeting
+		}
+		e = &storage.CalendarEvent{
+			AccountID: accountID,
+			EventType: eventType,
+			Color:     toNullString(ports.GetDefaultColor(ports.CalendarEventType(eventType))),
+			Source:    storage.CalendarEventSourceCalDAV,
+		}
+		s.applyRemote(e, main, o)
+		err = storage.CreateCalendarEvent(e)
+	} else {
+		s.applyRemote(e, main, o)
+		err = storage.UpdateSyncedCalendarEvent(e)
+	}
+	if err != nil {
+		log.Printf("[CalendarService] Failed to save CalDAV event %s: %v", o.Href, err)
+		return
+	}
+	result.Pulled++
+}
+
+// applyRemote sets an event to the server version
+func (s *CalendarService) applyRemote(e *storage.CalendarEvent, main *ical.Event, o caldav.Object) {
+	e.Title = eventTitle(main)
+	e.Description = toNullString(main.Description)
+	e.Location = toNullString(main.Location)
+	e.StartTime = storage.SQLiteTime{Time: main.Start.UTC()}
+	e.EndTime = sql.NullTime{Time: main.End.UTC(), Valid: !main.End.IsZero()}
+	e.AllDay = main.AllDay
+	e.IsCompleted = main.Cancelled()
+	e.ICalUID = toNullString(main.UID)
+	e.ICalRecurrenceID = main.RecurrenceID
+	e.ICalSequence = main.Sequence
+	e.ICalData = sql.NullString{String: string(o.Data), Valid: true}
+	e.CalDAVHref = toNullString(o.Href)
+	e.CalDAVETag = toNullString(o.ETag)
+	e.ConflictETag = sql.NullString{}
+	e.ConflictData = sql.NullString{}
+	e.SyncStatus = storage.CalendarSyncStatusSynced
+	e.LastSyncedAt = sql.NullTime{Time: s.now(), Valid: true}
+}
+
+// markConflict keeps the server version of an event changed on both sides
+// (data nil: deleted on the server): the CalDAV object, or the Google event
+// as JSON
+func (s *CalendarService) markConflict(e *storage.CalendarEvent, etag string, data []byte) error {
+	e.SyncStatus = storage.CalendarSyncStatusConflict
+	e.ConflictETag = toNullString(etag)
+	e.ConflictData = sql.NullString{String: string(data), Valid: data != nil}
+	return storage.UpdateSyncedCalendarEvent(e)
+}
+
+// pushCalDAV writes a local change to the server. The object read from the
+// server is updated in place, so what miau doesn't edit (attendees,
+// alarms, recurrence...) is kept. conflict is set when the object changed
+// on the server since it was read.
+func (s *CalendarService) pushCalDAV(ctx context.Context, client *caldav.Client, e *storage.CalendarEvent) (conflict bool, err error) {
+	var cal *ical.Calendar
+	var ev *ical.Event
+	if e.ICalData.Valid {
+		if parsed, err := ical.Parse([]byte(e.ICalData.String)); err == nil {
+			cal, ev = parsed, parsed.Find(e.ICalUID.String, e.ICalRecurrenceID)
+		}
+	}
+	if !e.ICalUID.Valid {
+		e.ICalUID = toNullString(newUID())
+	}
+	if cal == nil || ev == nil {
+		cal, ev = ical.New(), &ical.Event{Kind: ical.KindEvent, UID: e.ICalUID.String}
+	}
+
+	ev.Summary = e.Title
+	ev.Description = e.Description.String
+	ev.Location = e.Location.String
+	ev.Start = e.StartTime.Time.Local()
+	ev.End = time.Time{}
+	if e.EndTime.Valid {
+		ev.End = e.EndTime.Time.Local()
+	}
+	ev.AllDay = e.AllDay
+	cal.Update(ev, s.now())
+	var data = cal.Encode()
+
+	if !e.CalDAVHref.Valid {
+		var href, err = client.NewHref(ctx, e.ICalUID.String)
+		if err != nil {
+			return false, err
+		}
+		e.CalDAVHref = toNullString(href)
+		e.CalDAVETag = sql.NullString{}
+	}
+
+	var etag, errPut = client.Put(ctx, e.CalDAVHref.String, data, e.CalDAVETag.String)
+	if caldav.IsPreconditionFailed(errPut) {
+		var remote, err = client.Get(ctx, []string{e.CalDAVHref.String})
+		if err != nil {
+			return false, err
+		}
+		if len(remote) == 0 {
+			return true, s.markConflict(e, "", nil)
+		}
+		return true, s.markConflict(e, remote[0].ETag, remote[0].Data)
+	}
+	if errPut != nil {
+		return false, errPut
+	}
+
+	e.ICalData = sql.NullString{String: string(data), Valid: true}
+	e.CalDAVETag = toNullString(etag)
+	e.ConflictETag = sql.NullString{}
+	e.ConflictData = sql.NullString{}
+	e.SyncStatus = storage.CalendarSyncStatusSynced
+	e.LastSyncedAt = sql.NullTime{Time: s.now(), Valid: true}
+	return false, storage.UpdateSyncedCalendarEvent(e)
+}
+
+// GetSyncConflicts returns the events changed both locally and on the server
+func (s *CalendarService) GetSyncConflicts(ctx context.Context, accountID int64) ([]ports.CalendarConflict, error) {
+	var events, err = storage.GetCalendarConflicts(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conflicts: %w", err)
+	}
+
+	var conflicts = make([]ports.CalendarConflict, 0, len(events))
+	for i := range events {
+		var e = &events[i]
+		var conflict = ports.CalendarConflict{Local: *storageEventToInfo(e)}
+		if e.ConflictData.Valid {
+			var remote = *e
+			if err := s.applyConflict(&remote); err != nil {
+				log.Printf("[CalendarService] %v", err)
+			}
+			conflict.Remote = storageEventToInfo(&remote)
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts, nil
+}
+
+// applyConflict sets an event in conflict to the server version kept by
+// markConflict
+func (s *CalendarService) applyConflict(e *storage.CalendarEvent) error {
+	if !e.CalDAVHref.Valid && e.GoogleEventID.String != "" {
+		var ge gmail.CalendarEventInfo
+		if err := json.Unmarshal([]byte(e.ConflictData.String), &ge); err != nil {
+			return fmt.Errorf("invalid server version of event %d: %w", e.ID, err)
+		}
+		s.applyGoogle(e, &ge)
+		return nil
+	}
+
+	var cal, err = ical.Parse([]byte(e.ConflictData.String))
+	if err != nil || cal.Main() == nil {
+		return fmt.Errorf("invalid server version of event %d: %v", e.ID, err)
+	}
+	s.applyRemote(e, cal.Main(), caldav.Object{Href: e.CalDAVHref.String, ETag: e.ConflictETag.String, Data: []byte(e.ConflictData.String)})
+	return nil
+}
+
+// ResolveSyncConflict keeps the local or the server version of an event in
+// conflict. The local version is pushed right away; keeping the server's
+// deletion deletes the event (and returns nil).
+func (s *CalendarService) ResolveSyncConflict(ctx context.Context, eventID int64, resolution ports.ConflictResolution) (*ports.CalendarEventInfo, error) {
+	var e, err = storage.GetCalendarEvent(eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+	if e == nil || e.SyncStatus != storage.CalendarSyncStatusConflict {
+		return nil, fmt.Errorf("event %d is not in conflict", eventID)
+	}
+
+	switch resolution {
+	case ports.ConflictKeepMine:
+		// Overwrite the version on the server, or create it again
+		var google = !e.CalDAVHref.Valid && e.GoogleEventID.String != ""
+		switch {
+		case e.ConflictData.Valid && !google:
+			e.CalDAVETag = e.ConflictETag
+		case !e.ConflictData.Valid && google:
+			e.GoogleEventID = sql.NullString{}
+		case !e.ConflictData.Valid:
+			e.CalDAVHref = sql.NullString{}
+			e.CalDAVETag = sql.NullString{}
+		}
+		e.ConflictETag = sql.NullString{}
+		e.ConflictData = sql.NullString{}
+		e.SyncStatus = storage.CalendarSyncStatusPendingSync
+		if err := storage.UpdateSyncedCalendarEvent(e); err != nil {
+			return nil, fmt.Errorf("failed to update event: %w", err)
+		}
+		s.pushNow(ctx, e, google)
+
+	case ports.ConflictKeepTheirs:
+		if !e.ConflictData.Valid {
+			if err := storage.DeleteCalendarEvent(e.ID); err != nil {
+				return nil, fmt.Errorf("failed to delete event: %w", err)
+			}
+			return nil, nil
+		}
+		if err := s.applyConflict(e); err != nil {
+			return nil, err
+		}
+		if err := storage.UpdateSyncedCalendarEvent(e); err != nil {
+			return nil, fmt.Errorf("failed to update event: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid resolution %q", resolution)
+	}
+	return s.GetEvent(ctx, eventID)
+}
+
+// pushNow writes the local version of an event kept by ResolveSyncConflict
+// (left pending for the next sync if it fails)
+func (s *CalendarService) pushNow(ctx context.Context, e *storage.CalendarEvent, google bool) {
+	s.syncing.Lock()
+	defer s.syncing.Unlock()
+
+	var err error
+	if google {
+		if client := s.googleClient(e.AccountID); client != nil {
+			_, err = s.pushGoogle(ctx, client, e)
+		}
+	} else if client := s.caldavClient(e.AccountID); client != nil {
+		_, err = s.pushCalDAV(ctx, client, e)
+	}
+	if err != nil {
+		log.Printf("[CalendarService] Failed to push event %d: %v", e.ID, err)
+	}
+}
+
+// newUID returns a UID for an event or contact created in miau
+func newUID() string {
+	var b = make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b) + "@miau"
+}
+
+// Start runs SyncCalDAV and SyncGoogleCalendar for the accounts with a
+// remote calendar every calendarSyncInterval and on Wake, until Stop
+func (s *CalendarService) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return // already running
+	}
+
+	var workerCtx, cancel = context.WithCancel(ctx)
+	var done = make(chan struct{})
+	s.cancel = cancel
+	s.done = done
+
+	go s.run(workerCtx, done)
+}
+
+// Stop stops the worker and waits for it to exit
+func (s *CalendarService) Stop() {
+	s.mu.Lock()
+	var cancel = s.cancel
+	var done = s.done
+	s.cancel = nil
+	s.done = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Wake makes the worker sync now
+func (s *CalendarService) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the worker loop of Start
+func (s *CalendarService) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		s.mu.RLock()
+		var caldavAccounts = make([]int64, 0, len(s.caldav))
+		for accountID := range s.caldav {
+			caldavAccounts = append(caldavAccounts, accountID)
+		}
+		var googleAccounts = make([]int64, 0, len(s.google))
+		for accountID := range s.google {
+			googleAccounts = append(googleAccounts, accountID)
+		}
+		s.mu.RUnlock()
+
+		for _, accountID := range caldavAccounts {
+			if _, err := s.SyncCalDAV(ctx, accountID); err != nil && ctx.Err() == nil {
+				log.Printf("[CalendarService] CalDAV sync of account %d failed: %v", accountID, err)
+			}
+		}
+		for _, accountID := range googleAccounts {
+			if _, err := s.SyncGoogleCalendar(ctx, accountID); err != nil && ctx.Err() == nil {
+				log.Printf("[CalendarService] Google Calendar sync of account %d failed: %v", accountID, err)
+			}
+		}
+
+		var timer = time.NewTimer(calendarSyncInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+	}
+}
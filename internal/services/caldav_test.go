@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/caldav"
+	"github.com/opik/miau/internal/caldav/caldavtest"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func caldavEvent(uid, summary string) []byte {
+	return []byte(strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"SUMMARY:" + summary,
+		"DTSTART:20261027T090000Z",
+		"DTEND:20261027T100000Z",
+		"ATTENDEE;PARTSTAT=ACCEPTED:mailto:bob@example.com",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER:-PT15M",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n"))
+}
+
+// editEvent renames an event as the desktop app does
+func editEvent(t *testing.T, s *CalendarService, id int64, title string) {
+	var ctx = context.Background()
+	var event, err = s.GetEvent(ctx, id)
+	require.NoError(t, err)
+	_, err = s.UpdateEvent(ctx, &ports.CalendarEventInput{
+		ID:        event.ID,
+		AccountID: event.AccountID,
+		Title:     title,
+		EventType: event.EventType,
+		StartTime: event.StartTime,
+		EndTime:   event.EndTime,
+		Color:     event.Color,
+	})
+	require.NoError(t, err)
+}
+
+func TestCalendarService_SyncCalDAV(t *testing.T) {
+	require.NoError(t, storage.Init(filepath.Join(t.TempDir(), "test.db")))
+	defer storage.Close()
+	var account, _ = storage.GetOrCreateAccount("me@example.org", "Me")
+	var ctx = context.Background()
+
+	var server = caldavtest.NewServer()
+	defer server.Close()
+	var remoteHref, _ = server.Add("standup.ics", caldavEvent("standup@example.org", "Standup"))
+
+	var client, err = caldav.NewClient(server.URL, caldavtest.Username, caldavtest.Password)
+	require.NoError(t, err)
+	var s = NewCalendarService(nil)
+	s.SetCalDAV(account.ID, client)
+
+	// The server event comes down; a local one goes up
+	var result, errSync = s.SyncCalDAV(ctx, account.ID)
+	require.NoError(t, errSync)
+	assert.Equal(t, 1, result.Pulled)
+
+	var events, _ = s.GetEvents(ctx, account.ID)
+	require.Len(t, events, 1)
+	var standup = events[0]
+	assert.Equal(t, "Standup", standup.Title)
+	assert.Equal(t, ports.CalendarEventTypeMeeting, standup.EventType)
+	assert.Equal(t, ports.CalendarEventSourceCalDAV, standup.Source)
+	assert.Equal(t, ports.CalendarSyncStatusSynced, standup.SyncStatus)
+
+	var end = time.Date(2026, 10, 28, 13, 0, 0, 0, time.UTC)
+	var lunch, errCreate = s.CreateEvent(ctx, &ports.CalendarEventInput{
+		AccountID: account.ID,
+		Title:     "Lunch",
+		StartTime: time.Date(2026, 10, 28, 12, 0, 0, 0, time.UTC),
+		EndTime:   &end,
+	})
+	require.NoError(t, errCreate)
+	assert.Equal(t, ports.CalendarSyncStatusPendingSync, lunch.SyncStatus)
+
+	result, errSync = s.SyncCalDAV(ctx, account.ID)
+	require.NoError(t, errSync)
+	assert.Equal(t, 1, result.Pushed)
+	assert.Len(t, server.Hrefs(), 2)
+
+	// Nothing changed on either side
+	var puts = server.Puts()
+	result, errSync = s.SyncCalDAV(ctx, account.ID)
+	require.NoError(t, errSync)
+	assert.Equal(t, ports.CalendarSyncResult{}, *result)
+	assert.Equal(t, puts, server.Puts())
+
+	// Edited on both sides: the user keeps theirs
+	editEvent(t, s, standup.ID, "Standup (mine)")
+	server.Add("standup.ics", caldavEvent("standup@example.org", "Standup (server)"))
+	result, errSync = s.SyncCalDAV(ctx, account.ID)
+	require.NoError(t, errSync)
+	assert.Equal(t, 1, result.Conflicts)
+	assert.Equal(t, 0, result.Pushed)
+
+	var conflicts, _ = s.GetSyncConflicts(ctx, account.ID)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "Standup (mine)", conflicts[0].Local.Title)
+	require.NotNil(t, conflicts[0].Remote)
+	assert.Equal(t, "Standup (server)", conflicts[0].Remote.Title)
+
+	var resolved, errResolve = s.ResolveSyncConflict(ctx, standup.ID, ports.ConflictKeepMine)
+	require.NoError(t, errResolve)
+	assert.Equal(t, ports.CalendarSyncStatusSynced, resolved.SyncStatus)
+	var data, _ = server.Object(remoteHref)
+	assert.Contains(t, string(data), "SUMMARY:Standup (mine)")
+	assert.Contains(t, string(data), "ATTENDEE", "what miau doesn't edit is kept")
+	assert.Contains(t, string(data), "BEGIN:VALARM")
+
+	// Edited on both sides: the user keeps the server's
+	editEvent(t, s, standup.ID, "Standup (mine again)")
+	server.Add("standup.ics", caldavEvent("standup@example.org", "Standup (server again)"))
+	_, errSync = s.SyncCalDAV(ctx, account.ID)
+	require.NoError(t, errSync)
+	resolved, errResolve = s.ResolveSyncConflict(ctx, standup.ID, ports.ConflictKeepTheirs)
+	require.NoError(t, errResolve)
+	assert.Equal(t, "Standup (server again)", resolved.Title)
+	assert.Equal(t, ports.CalendarSyncStatusSynced, resolved.SyncStatus)
+
+	// Deleted locally, then on the server
+	require.NoError(t, s.DeleteEvent(ctx, lunch.ID))
+	result, errSync = s.SyncCalDAV(ctx, account.ID)
+	require.NoError(t, errSync)
+	assert.Equal(t, 1, result.Deleted)
+	assert.Equal(t, []string{remoteHref}, server.Hrefs())
+
+	server.Remove(remoteHref)
+	result, errSync = s.SyncCalDAV(ctx, account.ID)
+	require.NoError(t, errSync)
+	assert.Equal(t, 1, result.Deleted)
+	events, _ = s.GetEvents(ctx, account.ID)
+	assert.Empty(t, events)
+}
+
+func TestCalendarService_CalDAVSyncable(t *testing.T) {
+	var s = NewCalendarService(nil)
+	var event = &storage.CalendarEvent{
+		AccountID: 1,
+		EventType: storage.CalendarEventTypeCustom,
+		Source:    storage.CalendarEventSourceManual,
+	}
+	assert.False(t, s.caldavSyncable(event), "no CalDAV calendar")
+
+	var client, _ = caldav.NewClient("https://dav.example.org/", "me", "secret")
+	s.SetCalDAV(1, client)
+	assert.True(t, s.caldavSyncable(event))
+
+	event.EventType = storage.CalendarEventTypeTaskDeadline
+	assert.False(t, s.caldavSyncable(event))
+	event.EventType = storage.CalendarEventTypeMeeting
+	event.Source = storage.CalendarEventSourceEmailInvite
+	assert.False(t, s.caldavSyncable(event))
+}
@@ -3,8 +3,10 @@ package services
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/opik/miau/internal/caldav"
 	"github.com/opik/miau/internal/gmail"
 	"github.com/opik/miau/internal/ports"
 	"github.com/opik/miau/internal/storage"
@@ -12,14 +14,27 @@ import (
 
 // CalendarService implements ports.CalendarService
 type CalendarService struct {
+	mu             sync.RWMutex
 	taskService    ports.TaskService
 	googleCalendar *gmail.CalendarClient
+	google         map[int64]*gmail.CalendarClient // by account, two-way sync
+	caldav         map[int64]*caldav.Client        // by account
+	now            func() time.Time
+
+	syncing sync.Mutex // one remote calendar sync at a time
+	cancel  context.CancelFunc
+	done    chan struct{}
+	wake    chan struct{}
 }
 
 // NewCalendarService creates a new CalendarService
 func NewCalendarService(taskService ports.TaskService) *CalendarService {
 	return &CalendarService{
 		taskService: taskService,
+		google:      map[int64]*gmail.CalendarClient{},
+		caldav:      map[int64]*caldav.Client{},
+		now:         time.Now,
+		wake:        make(chan struct{}, 1),
 	}
 }
 
@@ -69,11 +84,19 @@ func (s *CalendarService) CreateEvent(ctx context.Context, input *ports.Calendar
 	if !event.Color.Valid || event.Color.String == "" {
 		event.Color = toNullString(ports.GetDefaultColor(input.EventType))
 	}
+	// Written to the CalDAV server or Google Calendar by the next sync
+	var push = event.SyncStatus == storage.CalendarSyncStatusLocal && s.syncable(event)
+	if push {
+		event.SyncStatus = storage.CalendarSyncStatusPendingSync
+	}
 
 	err := storage.CreateCalendarEvent(event)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create event: %w", err)
 	}
+	if push {
+		s.Wake()
+	}
 
 	return storageEventToInfo(event), nil
 }
@@ -178,10 +201,31 @@ func (s *CalendarService) UpdateEvent(ctx context.Context, input *ports.Calendar
 		SyncStatus:       storage.CalendarSyncStatus(input.SyncStatus),
 	}
 
-	err := storage.UpdateCalendarEvent(event)
+	// Events on a remote calendar keep their sync state and are written
+	// back by the next sync
+	existing, err := storage.GetCalendarEvent(input.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+	var push = existing != nil && s.syncable(existing)
+	if push {
+		event.Source = existing.Source
+		event.GoogleEventID = existing.GoogleEventID
+		event.GoogleCalendarID = existing.GoogleCalendarID
+		event.LastSyncedAt = existing.LastSyncedAt
+		event.SyncStatus = storage.CalendarSyncStatusPendingSync
+		if existing.SyncStatus == storage.CalendarSyncStatusConflict {
+			event.SyncStatus = storage.CalendarSyncStatusConflict
+		}
+	}
+
+	err = storage.UpdateCalendarEvent(event)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update event: %w", err)
 	}
+	if push {
+		s.Wake()
+	}
 
 	return s.GetEvent(ctx, input.ID)
 }
@@ -197,13 +241,50 @@ func (s *CalendarService) ToggleEventCompleted(ctx context.Context, id int64) (b
 
 // DeleteEvent removes an event
 func (s *CalendarService) DeleteEvent(ctx context.Context, id int64) error {
-	err := storage.DeleteCalendarEvent(id)
+	// Deleted on the CalDAV server or Google Calendar by the next sync
+	event, err := storage.GetCalendarEvent(id)
+	if err != nil {
+		return fmt.Errorf("failed to get event: %w", err)
+	}
+	if deletion := s.remoteDeletion(event); deletion != nil {
+		err = storage.AddCalendarDeletion(deletion)
+		if err != nil {
+			return fmt.Errorf("failed to record deletion: %w", err)
+		}
+		defer s.Wake()
+	}
+
+	err = storage.DeleteCalendarEvent(id)
 	if err != nil {
 		return fmt.Errorf("failed to delete event: %w", err)
 	}
 	return nil
 }
 
+// remoteDeletion returns the deletion to send to the remote calendar of an
+// event (nil if it is only local)
+func (s *CalendarService) remoteDeletion(e *storage.CalendarEvent) *storage.CalendarDeletion {
+	switch {
+	case e == nil:
+		return nil
+	case e.CalDAVHref.Valid:
+		return &storage.CalendarDeletion{
+			AccountID: e.AccountID,
+			Source:    storage.CalendarSyncCalDAV,
+			RemoteID:  e.CalDAVHref.String,
+			ETag:      e.CalDAVETag.String,
+		}
+	case e.GoogleEventID.String != "" && s.googleSyncable(e):
+		return &storage.CalendarDeletion{
+			AccountID:  e.AccountID,
+			Source:     storage.CalendarSyncGoogle,
+			Collection: googleCalendarOf(e),
+			RemoteID:   e.GoogleEventID.String,
+		}
+	}
+	return nil
+}
+
 // CountEvents returns event counts
 func (s *CalendarService) CountEvents(ctx context.Context, accountID int64) (*ports.CalendarEventCounts, error) {
 	upcoming, completed, total, err := storage.CountCalendarEvents(accountID)
@@ -305,10 +386,14 @@ func (s *CalendarService) UpdateEventFromTask(ctx context.Context, taskID int64)
 
 // DeleteEventByTask removes the event when task is deleted
 func (s *CalendarService) DeleteEventByTask(ctx context.Context, taskID int64) error {
-	err := storage.DeleteCalendarEventByTask(taskID)
+	// Through DeleteEvent, so the deadline leaves Google Calendar too
+	event, err := storage.GetCalendarEventByTask(taskID)
 	if err != nil {
 		return fmt.Errorf("failed to delete event by task: %w", err)
 	}
+	if event != nil {
+		return s.DeleteEvent(ctx, event.ID)
+	}
 	return nil
 }
 
@@ -386,6 +471,8 @@ func storageEventToInfo(e *storage.CalendarEvent) *ports.CalendarEventInfo {
 		GoogleCalendarID: nullStringToString(e.GoogleCalendarID),
 		LastSyncedAt:     nullTimeToPtr(e.LastSyncedAt),
 		SyncStatus:       ports.CalendarSyncStatus(e.SyncStatus),
+		Location:         nullStringToString(e.Location),
+		RSVPStatus:       ports.InviteResponse(nullStringToString(e.RSVPStatus)),
 		CreatedAt:        e.CreatedAt.Time,
 		UpdatedAt:        e.UpdatedAt.Time,
 	}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/opik/miau/internal/carddav"
 	"github.com/opik/miau/internal/ports"
 )
 
@@ -19,6 +20,11 @@ type ContactService struct {
 	gmail    ports.GmailContactsPort
 	events   ports.EventBus
 	photoDir string
+
+	// CardDAV address books by account; apart from mu, which is held for
+	// a whole SyncContacts
+	carddavMu sync.RWMutex
+	carddav   map[int64]*carddav.Client
 }
 
 // NewContactService creates a new ContactService
@@ -33,24 +39,36 @@ func NewContactService(storage ports.ContactStoragePort, gmail ports.GmailContac
 		gmail:    gmail,
 		events:   events,
 		photoDir: photoDir,
+		carddav:  make(map[int64]*carddav.Client),
 	}
 }
 
-// SyncContacts performs a full or incremental sync of contacts from Gmail
+// SyncContacts performs a full or incremental sync of contacts from the
+// account's CardDAV address book, if it has one, and from Gmail
 func (s *ContactService) SyncContacts(ctx context.Context, accountID int64, fullSync bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	log.Printf("[ContactService.SyncContacts] started for account %d (full=%v)", accountID, fullSync)
 
+	var client = s.cardDAVClient(accountID)
+	if client != nil {
+		if err := s.syncCardDAV(ctx, client, accountID, fullSync); err != nil {
+			return err
+		}
+	}
+
 	// Check if gmail port is available
 	if s.gmail == nil {
+		if client != nil {
+			return nil
+		}
 		log.Printf("[ContactService.SyncContacts] gmail port is nil")
 		return fmt.Errorf("gmail contacts API not available")
 	}
 
 	// Get current sync status
-	var syncStatus, err = s.storage.GetSyncStatus(ctx, accountID)
+	var syncStatus, err = s.storage.GetSyncStatus(ctx, accountID, ports.ContactSourceGoogle)
 	if err != nil {
 		log.Printf("[ContactService.SyncContacts] failed to get sync status: %v", err)
 		return fmt.Errorf("failed to get sync status: %w", err)
@@ -60,6 +78,7 @@ func (s *ContactService) SyncContacts(ctx context.Context, accountID int64, full
 	if syncStatus == nil {
 		syncStatus = &ports.ContactSyncStatus{
 			AccountID: accountID,
+			Source:    ports.ContactSourceGoogle,
 			Status:    "never_synced",
 		}
 	}
@@ -326,12 +345,26 @@ func (s *ContactService) GetContactPhoto(ctx context.Context, contactID int64) (
 	return os.ReadFile(contact.PhotoPath)
 }
 
-// GetSyncStatus returns the sync status for an account
+// GetSyncStatus returns the sync status for an account (of its CardDAV
+// address book when it has one)
 func (s *ContactService) GetSyncStatus(ctx context.Context, accountID int64) (*ports.ContactSyncStatus, error) {
-	return s.storage.GetSyncStatus(ctx, accountID)
+	if s.cardDAVClient(accountID) != nil {
+		return s.storage.GetSyncStatus(ctx, accountID, ports.ContactSourceCardDAV)
+	}
+	return s.storage.GetSyncStatus(ctx, accountID, ports.ContactSourceGoogle)
 }
 
 // GetTopContacts returns contacts ordered by interaction frequency
 func (s *ContactService) GetTopContacts(ctx context.Context, accountID int64, limit int) ([]ports.ContactInfo, error) {
 	return s.storage.GetTopContacts(ctx, accountID, limit)
 }
+
+// SetContactTimeZone sets the IANA time zone of a contact ("" clears it)
+func (s *ContactService) SetContactTimeZone(ctx context.Context, contactID int64, timeZone string) error {
+	if timeZone != "" {
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			return fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+		}
+	}
+	return s.storage.SetContactTimeZone(ctx, contactID, timeZone)
+}
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opik/miau/internal/carddav"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/vcard"
+)
+
+// CardDAV contact sync: an account with a CardDAV address book syncs it
+// both ways on SyncContacts, before (or, for IMAP accounts, instead of)
+// Google. The changes since the last sync token are pulled (everything
+// again when the server forgot the token), then the contacts changed in
+// miau are pushed. A contact changed on both sides takes the server's
+// version; our own writes come back with the ETag we stored and are
+// skipped.
+
+// cardDAVBatch is how many vCards one addressbook-multiget fetches
+const cardDAVBatch = 100
+
+// SetCardDAV sets the CardDAV address book of an account (nil turns it
+// off)
+func (s *ContactService) SetCardDAV(accountID int64, client *carddav.Client) {
+	s.carddavMu.Lock()
+	defer s.carddavMu.Unlock()
+	if client == nil {
+		delete(s.carddav, accountID)
+		return
+	}
+	s.carddav[accountID] = client
+}
+
+// cardDAVClient returns the CardDAV client of an account (nil if none)
+func (s *ContactService) cardDAVClient(accountID int64) *carddav.Client {
+	s.carddavMu.RLock()
+	defer s.carddavMu.RUnlock()
+	return s.carddav[accountID]
+}
+
+// syncCardDAV syncs the account's address book, both ways, keeping its
+// own sync status
+func (s *ContactService) syncCardDAV(ctx context.Context, client *carddav.Client, accountID int64, fullSync bool) error {
+	var status, err = s.storage.GetSyncStatus(ctx, accountID, ports.ContactSourceCardDAV)
+	if err != nil {
+		return fmt.Errorf("failed to get sync status: %w", err)
+	}
+	if status == nil {
+		status = &ports.ContactSyncStatus{AccountID: accountID, Source: ports.ContactSourceCardDAV}
+	}
+	status.Status = "syncing"
+	if err := s.storage.UpdateSyncStatus(ctx, status); err != nil {
+		return fmt.Errorf("failed to update sync status: %w", err)
+	}
+	s.events.Publish(ports.ContactSyncStartedEvent{
+		BaseEvent: ports.NewBaseEvent(ports.EventTypeContactSyncStarted),
+		AccountID: accountID,
+		FullSync:  fullSync,
+	})
+
+	var pulled, full, errSync = s.pullCardDAV(ctx, client, status, fullSync)
+	var pushed int
+	if errSync == nil {
+		pushed, errSync = s.pushCardDAV(ctx, client, accountID)
+	}
+	if errSync != nil {
+		status.Status = "error"
+		status.ErrorMessage = errSync.Error()
+		s.storage.UpdateSyncStatus(ctx, status)
+		s.events.Publish(ports.ContactSyncFailedEvent{
+			BaseEvent: ports.NewBaseEvent(ports.EventTypeContactSyncFailed),
+			AccountID: accountID,
+			Error:     errSync.Error(),
+		})
+		return fmt.Errorf("failed to sync CardDAV contacts: %w", errSync)
+	}
+
+	var now = time.Now()
+	var contacts, _ = s.storage.GetCardDAVContacts(ctx, accountID)
+	status.Status = "synced"
+	status.ErrorMessage = ""
+	status.TotalContacts = len(contacts)
+	if full {
+		status.LastFullSync = &now
+	} else {
+		status.LastIncrementalSync = &now
+	}
+	if err := s.storage.UpdateSyncStatus(ctx, status); err != nil {
+		log.Printf("Warning: failed to update sync status: %v", err)
+	}
+
+	s.events.Publish(ports.ContactSyncCompletedEvent{
+		BaseEvent:   ports.NewBaseEvent(ports.EventTypeContactSyncCompleted),
+		AccountID:   accountID,
+		TotalSynced: pulled + pushed,
+		FullSync:    full,
+	})
+	log.Printf("CardDAV contact sync completed: %d pulled, %d pushed", pulled, pushed)
+	return nil
+}
+
+// pullCardDAV applies the changes of the address book since the sync
+// token. A full sync (first one, asked for, or the token was forgotten)
+// also deletes the contacts the server no longer lists.
+func (s *ContactService) pullCardDAV(ctx context.Context, client *carddav.Client, status *ports.ContactSyncStatus, fullSync bool) (pulled int, full bool, err error) {
+	var token = status.LastSyncToken
+	if fullSync {
+		token = ""
+	}
+	var changes, errSync = client.Sync(ctx, token)
+	if errors.Is(errSync, carddav.ErrSyncTokenInvalid) {
+		changes, errSync = client.Sync(ctx, "")
+	}
+	if errSync != nil {
+		return 0, false, errSync
+	}
+
+	var contacts, errLocal = s.storage.GetCardDAVContacts(ctx, status.AccountID)
+	if errLocal != nil {
+		return 0, false, errLocal
+	}
+	var local = make(map[string]*ports.ContactInfo, len(contacts))
+	for i := range contacts {
+		local[contacts[i].ResourceName] = &contacts[i]
+	}
+
+	var listed = make(map[string]bool, len(changes.Updated))
+	var fetch []string
+	for _, o := range changes.Updated {
+		listed[o.Href] = true
+		if c := local[o.Href]; c == nil || c.ETag != o.ETag {
+			fetch = append(fetch, o.Href)
+		}
+	}
+	for start := 0; start < len(fetch); start += cardDAVBatch {
+		var objects, err = client.Get(ctx, fetch[start:min(start+cardDAVBatch, len(fetch))])
+		if err != nil {
+			return pulled, changes.Full, err
+		}
+		for _, o := range objects {
+			if err := s.saveVCard(ctx, client, status.AccountID, local[o.Href], o); err != nil {
+				log.Printf("Warning: failed to save contact %s: %v", o.Href, err)
+				continue
+			}
+			pulled++
+		}
+	}
+
+	var removed = changes.Removed
+	if changes.Full {
+		for href := range local {
+			if !listed[href] {
+				removed = append(removed, href)
+			}
+		}
+	}
+	for _, href := range removed {
+		if c := local[href]; c != nil {
+			if err := s.storage.DeleteContact(ctx, c.ID); err != nil {
+				log.Printf("Warning: failed to delete contact %d: %v", c.ID, err)
+				continue
+			}
+			pulled++
+		}
+	}
+
+	status.LastSyncToken = changes.SyncToken
+	return pulled, changes.Full, nil
+}
+
+// saveVCard saves the server version of a contact (existing is nil for a
+// new one): names, emails, phones, time zone and photo
+func (s *ContactService) saveVCard(ctx context.Context, client *carddav.Client, accountID int64, existing *ports.ContactInfo, o carddav.Object) error {
+	var card, err = vcard.ParseOne(o.Data)
+	if err != nil {
+		return err
+	}
+
+	var now = time.Now()
+	var contact = &ports.ContactInfo{
+		AccountID:    accountID,
+		ResourceName: o.Href,
+		DisplayName:  card.Name,
+		GivenName:    card.GivenName,
+		FamilyName:   card.FamilyName,
+		PhotoURL:     card.Photo.URL,
+		TimeZone:     card.TimeZone,
+		SyncedAt:     &now,
+	}
+	if existing != nil {
+		contact.ID = existing.ID
+		contact.IsStarred = existing.IsStarred
+		contact.PhotoPath = existing.PhotoPath
+	}
+	var contactID, errSave = s.storage.SaveContact(ctx, contact)
+	if errSave != nil {
+		return fmt.Errorf("failed to save contact: %w", errSave)
+	}
+
+	// Without a preferred email or phone, the first one is
+	var emails []ports.ContactEmailInfo
+	var primaryEmail = false
+	var seen = make(map[string]bool)
+	for _, e := range card.Emails {
+		if seen[strings.ToLower(e.Address)] {
+			continue
+		}
+		seen[strings.ToLower(e.Address)] = true
+		emails = append(emails, ports.ContactEmailInfo{ContactID: contactID, Email: e.Address, Type: e.Type, IsPrimary: e.Preferred})
+		primaryEmail = primaryEmail || e.Preferred
+	}
+	if len(emails) > 0 && !primaryEmail {
+		emails[0].IsPrimary = true
+	}
+	if err := s.storage.SaveContactEmails(ctx, contactID, emails); err != nil {
+		return fmt.Errorf("failed to save contact emails: %w", err)
+	}
+
+	var phones []ports.ContactPhoneInfo
+	var primaryPhone = false
+	for _, p := range card.Phones {
+		phones = append(phones, ports.ContactPhoneInfo{ContactID: contactID, PhoneNumber: p.Number, Type: p.Type, IsPrimary: p.Preferred})
+		primaryPhone = primaryPhone || p.Preferred
+	}
+	if len(phones) > 0 && !primaryPhone {
+		phones[0].IsPrimary = true
+	}
+	if err := s.storage.SaveContactPhones(ctx, contactID, phones); err != nil {
+		return fmt.Errorf("failed to save contact phones: %w", err)
+	}
+
+	if photoPath := s.saveCardPhoto(ctx, client, contactID, card.Photo); photoPath != "" && photoPath != contact.PhotoPath {
+		contact.ID = contactID
+		contact.PhotoPath = photoPath
+		if _, err := s.storage.SaveContact(ctx, contact); err != nil {
+			return fmt.Errorf("failed to save contact photo: %w", err)
+		}
+	}
+
+	return s.storage.SaveContactVCard(ctx, contactID, o.Href, o.ETag, string(o.Data))
+}
+
+// saveCardPhoto writes the photo of a card (inline, or downloaded from its
+// link) to the photo directory and returns its path ("" if none)
+func (s *ContactService) saveCardPhoto(ctx context.Context, client *carddav.Client, contactID int64, photo vcard.Photo) string {
+	var data = photo.Data
+	if len(data) == 0 && photo.URL != "" {
+		var err error
+		if data, err = client.Download(ctx, photo.URL); err != nil {
+			log.Printf("Warning: failed to download photo for contact %d: %v", contactID, err)
+			return ""
+		}
+	}
+	return s.writePhoto(contactID, data)
+}
+
+// writePhoto writes the photo of a contact to the photo directory and
+// returns its path ("" if none)
+func (s *ContactService) writePhoto(contactID int64, data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	var extension = ".jpg"
+	if http.DetectContentType(data) == "image/png" {
+		extension = ".png"
+	}
+	var photoPath = filepath.Join(s.photoDir, fmt.Sprintf("contact_%d%s", contactID, extension))
+	if err := os.WriteFile(photoPath, data, 0600); err != nil {
+		log.Printf("Warning: failed to save photo for contact %d: %v", contactID, err)
+		return ""
+	}
+	return photoPath
+}
+
+// pushCardDAV writes the contacts changed in miau to the address book.
+// Google contacts stay in Google.
+func (s *ContactService) pushCardDAV(ctx context.Context, client *carddav.Client, accountID int64) (int, error) {
+	var contacts, err = s.storage.GetContactsPendingSync(ctx, accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get local changes: %w", err)
+	}
+
+	var pushed = 0
+	for i := range contacts {
+		var c = &contacts[i]
+		if isGoogleContact(c) {
+			continue
+		}
+		if err := s.pushContact(ctx, client, c); err != nil {
+			log.Printf("Warning: failed to push contact %d: %v", c.ID, err)
+			continue
+		}
+		pushed++
+	}
+	return pushed, nil
+}
+
+// pushContact writes a contact to the address book as a vCard
+func (s *ContactService) pushContact(ctx context.Context, client *carddav.Client, c *ports.ContactInfo) error {
+	var card = contactCard(c)
+	var data = card.Encode()
+
+	var href = c.ResourceName
+	if c.ETag == "" {
+		var err error
+		if href, err = client.NewHref(ctx, card.UID); err != nil {
+			return err
+		}
+	}
+	var etag, err = client.Put(ctx, href, data, c.ETag)
+	if carddav.IsPreconditionFailed(err) && c.ETag != "" {
+		// Changed (or deleted) on the server since the last sync: the
+		// server's version wins
+		log.Printf("[ContactService] Contact %d changed on the server too, keeping the server's version", c.ID)
+		var objects, errGet = client.Get(ctx, []string{href})
+		if errGet != nil {
+			return errGet
+		}
+		if len(objects) == 0 {
+			return s.storage.DeleteContact(ctx, c.ID)
+		}
+		return s.saveVCard(ctx, client, c.AccountID, c, objects[0])
+	}
+	if err != nil {
+		return err
+	}
+	return s.storage.SaveContactVCard(ctx, c.ID, href, etag, string(data))
+}
+
+// contactCard returns a contact as a vCard: the vCard it was synced or
+// imported with, if any, with the fields miau edits updated
+func contactCard(c *ports.ContactInfo) *vcard.Card {
+	var card *vcard.Card
+	if c.VCard != "" {
+		card, _ = vcard.ParseOne([]byte(c.VCard))
+	}
+	if card == nil {
+		card = vcard.New(newUID())
+	}
+	if card.UID == "" {
+		card.UID = newUID()
+	}
+
+	card.Name = c.DisplayName
+	card.GivenName = c.GivenName
+	card.FamilyName = c.FamilyName
+	card.TimeZone = c.TimeZone
+	card.Emails = nil
+	for _, e := range c.Emails {
+		var email = vcard.Email{Address: e.Email, Type: e.Type, Preferred: e.IsPrimary}
+		if e.IsPrimary {
+			card.Emails = append([]vcard.Email{email}, card.Emails...)
+		} else {
+			card.Emails = append(card.Emails, email)
+		}
+	}
+	card.Phones = nil
+	for _, p := range c.Phones {
+		card.Phones = append(card.Phones, vcard.Phone{Number: p.PhoneNumber, Type: p.Type, Preferred: p.IsPrimary})
+	}
+	if len(card.Photo.Data) == 0 && card.Photo.URL == "" && c.PhotoPath != "" {
+		if data, err := os.ReadFile(c.PhotoPath); err == nil {
+			card.Photo = vcard.Photo{Data: data, MediaType: http.DetectContentType(data)}
+		}
+	}
+	return card
+}
+
+// isGoogleContact reports whether a contact comes from Google People
+func isGoogleContact(c *ports.ContactInfo) bool {
+	return strings.HasPrefix(c.ResourceName, "people/") || strings.HasPrefix(c.ResourceName, "otherContacts/")
+}
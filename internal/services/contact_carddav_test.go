@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opik/miau/internal/carddav"
+	"github.com/opik/miau/internal/carddav/carddavtest"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func carddavContact(uid, name string) []byte {
+	return []byte(strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"UID:" + uid,
+		"FN:" + name,
+		"N:" + name + ";;;;",
+		"ORG:Example",
+		"EMAIL;TYPE=INTERNET,WORK:" + strings.ToLower(name) + "@example.com",
+		"TEL;TYPE=CELL:+1 555 0100",
+		"PHOTO;ENCODING=b;TYPE=PNG:iVBORw0KGgoAAAANSUhEUg==",
+		"END:VCARD",
+		"",
+	}, "\r\n"))
+}
+
+func TestContactService_SyncCardDAV(t *testing.T) {
+	require.NoError(t, storage.Init(filepath.Join(t.TempDir(), "test.db")))
+	defer storage.Close()
+	var account, _ = storage.GetOrCreateAccount("me@example.org", "Me")
+	var ctx = context.Background()
+
+	var server = carddavtest.NewServer()
+	defer server.Close()
+	var janeHref, _ = server.Add("jane.vcf", carddavContact("jane@example.org", "Jane"))
+	var bobHref, _ = server.Add("bob.vcf", carddavContact("bob@example.org", "Bob"))
+
+	var client, err = carddav.NewClient(server.URL, carddavtest.Username, carddavtest.Password)
+	require.NoError(t, err)
+	var contactStorage = storage.NewContactStorageAdapter()
+	var s = NewContactService(contactStorage, nil, NewEventBus(), t.TempDir())
+
+	// Without Gmail nor CardDAV there is nothing to sync from
+	assert.Error(t, s.SyncContacts(ctx, account.ID, false))
+	s.SetCardDAV(account.ID, client)
+
+	// The address book comes down
+	require.NoError(t, s.SyncContacts(ctx, account.ID, false))
+	var jane, errGet = s.GetContactByEmail(ctx, account.ID, "jane@example.com")
+	require.NoError(t, errGet)
+	assert.Equal(t, "Jane", jane.DisplayName)
+	assert.Equal(t, janeHref, jane.ResourceName)
+	var phones, _ = contactStorage.GetContactPhones(ctx, jane.ID)
+	require.Len(t, phones, 1)
+	assert.Equal(t, "mobile", phones[0].Type)
+	assert.True(t, phones[0].IsPrimary)
+	require.NotEmpty(t, jane.PhotoPath)
+	assert.Equal(t, ".png", filepath.Ext(jane.PhotoPath))
+	var photo, _ = os.ReadFile(jane.PhotoPath)
+	assert.Equal(t, []byte("\x89PNG\r\n\x1a\n"), photo[:8])
+
+	var status, _ = s.GetSyncStatus(ctx, account.ID)
+	require.NotNil(t, status)
+	assert.Equal(t, ports.ContactSourceCardDAV, status.Source)
+	assert.Equal(t, "synced", status.Status)
+	assert.Equal(t, 2, status.TotalContacts)
+	assert.NotEmpty(t, status.LastSyncToken)
+
+	// Changed in miau: the vCard goes up, keeping what miau doesn't edit
+	require.NoError(t, s.SetContactTimeZone(ctx, jane.ID, "Europe/Lisbon"))
+	require.NoError(t, s.SyncContacts(ctx, account.ID, false))
+	var data, _ = server.Object(janeHref)
+	assert.Contains(t, string(data), "TZ;VALUE=text:Europe/Lisbon")
+	assert.Contains(t, string(data), "ORG:Example")
+	assert.Contains(t, string(data), "PHOTO;ENCODING=b;TYPE=PNG:")
+
+	// Our own write is not pulled back, nor pushed again
+	var puts = server.Puts()
+	require.NoError(t, s.SyncContacts(ctx, account.ID, false))
+	assert.Equal(t, puts, server.Puts())
+	jane, _ = s.GetContact(ctx, jane.ID)
+	assert.False(t, jane.PendingSync)
+
+	// Created in miau
+	var localID, _ = contactStorage.SaveContact(ctx, &ports.ContactInfo{AccountID: account.ID, ResourceName: "local/ana", DisplayName: "Ana"})
+	contactStorage.SaveContactEmails(ctx, localID, []ports.ContactEmailInfo{{Email: "ana@example.com", IsPrimary: true}})
+	require.NoError(t, s.SetContactTimeZone(ctx, localID, "America/Sao_Paulo"))
+	require.NoError(t, s.SyncContacts(ctx, account.ID, false))
+	var ana, _ = s.GetContact(ctx, localID)
+	require.Contains(t, server.Hrefs(), ana.ResourceName)
+	data, _ = server.Object(ana.ResourceName)
+	assert.Contains(t, string(data), "FN:Ana")
+	assert.Contains(t, string(data), "EMAIL;TYPE=pref:ana@example.com")
+
+	// Changed on the server, and changed on both sides: the server wins
+	server.Add("jane.vcf", carddavContact("jane@example.org", "Janet"))
+	require.NoError(t, s.SetContactTimeZone(ctx, jane.ID, "Asia/Tokyo"))
+	require.NoError(t, s.SyncContacts(ctx, account.ID, false))
+	jane, _ = s.GetContact(ctx, jane.ID)
+	assert.Equal(t, "Janet", jane.DisplayName)
+	assert.False(t, jane.PendingSync)
+	data, _ = server.Object(janeHref)
+	assert.NotContains(t, string(data), "Asia/Tokyo")
+
+	// Deleted on the server
+	server.Remove(bobHref)
+	require.NoError(t, s.SyncContacts(ctx, account.ID, false))
+	var bob, _ = contactStorage.GetContactByResourceName(ctx, account.ID, bobHref)
+	assert.Nil(t, bob)
+
+	// Deleted while the server forgot the sync token: the full sync finds
+	// it gone
+	server.ExpireTokens()
+	server.Remove(janeHref)
+	require.NoError(t, s.SyncContacts(ctx, account.ID, false))
+	var contacts, _ = s.ListContacts(ctx, account.ID, 10)
+	require.Len(t, contacts, 1)
+	assert.Equal(t, "Ana", contacts[0].DisplayName)
+	status, _ = s.GetSyncStatus(ctx, account.ID)
+	assert.NotNil(t, status.LastFullSync)
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// Google and Outlook CSV layouts. Both name their columns in a header
+// row, so one reader takes either: Google numbers its emails and phones
+// ("E-mail 1 - Value", labeled by "E-mail 1 - Label", or "- Type" in older
+// exports), joins several values of a label with " ::: " and marks the
+// primary label with "* "; Outlook has fixed columns ("E-mail 2 Address",
+// "Mobile Phone"...).
+
+// outlookPhoneColumns maps the Outlook phone columns to phone types
+var outlookPhoneColumns = []struct{ column, kind string }{
+	{"Primary Phone", ""},
+	{"Mobile Phone", "mobile"},
+	{"Home Phone", "home"},
+	{"Home Phone 2", "home"},
+	{"Business Phone", "work"},
+	{"Business Phone 2", "work"},
+	{"Other Phone", "other"},
+	{"Home Fax", "fax"},
+	{"Business Fax", "fax"},
+	{"Pager", "pager"},
+}
+
+// outlookEmailColumns are the Outlook email columns, in order
+var outlookEmailColumns = []string{"E-mail Address", "E-mail 2 Address", "E-mail 3 Address"}
+
+// readContactsCSV reads a Google or Outlook CSV file
+func readContactsCSV(data []byte) ([]importedContact, error) {
+	var reader = csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	var rows, err = reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty CSV")
+	}
+
+	var columns = make(map[string]int)
+	for i, name := range rows[0] {
+		if _, ok := columns[strings.TrimSpace(name)]; !ok {
+			columns[strings.TrimSpace(name)] = i
+		}
+	}
+	var known = false
+	for _, name := range []string{"Name", "First Name", "Given Name", "Last Name", "Family Name", "E-mail 1 - Value", outlookEmailColumns[0]} {
+		_, ok := columns[name]
+		known = known || ok
+	}
+	if !known {
+		return nil, fmt.Errorf("no name or email columns in the CSV header")
+	}
+
+	var entries = make([]importedContact, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		var field = func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		var contact = ports.ContactInfo{
+			GivenName:  firstNonEmpty(field("First Name"), field("Given Name")),
+			FamilyName: firstNonEmpty(field("Last Name"), field("Family Name")),
+		}
+		var middle = firstNonEmpty(field("Middle Name"), field("Additional Name"))
+		contact.DisplayName = firstNonEmpty(field("Name"), field("File As"),
+			joinNames(contact.GivenName, middle, contact.FamilyName), field("Nickname"))
+
+		// Google
+		for n := 1; ; n++ {
+			var prefix = fmt.Sprintf("E-mail %d - ", n)
+			if _, ok := columns[prefix+"Value"]; !ok {
+				break
+			}
+			var kind, primary = googleLabel(firstNonEmpty(field(prefix+"Label"), field(prefix+"Type")))
+			for _, value := range strings.Split(field(prefix+"Value"), ":::") {
+				if value = strings.TrimSpace(value); value != "" {
+					contact.Emails = append(contact.Emails, ports.ContactEmailInfo{Email: value, Type: kind, IsPrimary: primary})
+					primary = false
+				}
+			}
+		}
+		for n := 1; ; n++ {
+			var prefix = fmt.Sprintf("Phone %d - ", n)
+			if _, ok := columns[prefix+"Value"]; !ok {
+				break
+			}
+			var kind, primary = googleLabel(firstNonEmpty(field(prefix+"Label"), field(prefix+"Type")))
+			for _, value := range strings.Split(field(prefix+"Value"), ":::") {
+				if value = strings.TrimSpace(value); value != "" {
+					contact.Phones = append(contact.Phones, ports.ContactPhoneInfo{PhoneNumber: value, Type: kind, IsPrimary: primary})
+					primary = false
+				}
+			}
+		}
+
+		// Outlook
+		for _, column := range outlookEmailColumns {
+			if value := field(column); value != "" {
+				contact.Emails = append(contact.Emails, ports.ContactEmailInfo{Email: value})
+			}
+		}
+		for _, c := range outlookPhoneColumns {
+			if value := field(c.column); value != "" {
+				contact.Phones = append(contact.Phones, ports.ContactPhoneInfo{PhoneNumber: value, Type: c.kind, IsPrimary: c.kind == ""})
+			}
+		}
+
+		entries = append(entries, importedContact{contact: contact})
+	}
+	return entries, nil
+}
+
+// googleLabel reads a Google label ("* Work" is the primary work one)
+func googleLabel(label string) (kind string, primary bool) {
+	if strings.HasPrefix(label, "*") {
+		primary = true
+		label = strings.TrimPrefix(label, "*")
+	}
+	return strings.ToLower(strings.TrimSpace(label)), primary
+}
+
+// writeGoogleCSV writes contacts in the Google layout, with as many email
+// and phone columns as the contact with the most needs
+func writeGoogleCSV(contacts []ports.ContactInfo) ([]byte, error) {
+	var emails, phones = 1, 1
+	for i := range contacts {
+		emails = max(emails, len(contacts[i].Emails))
+		phones = max(phones, len(contacts[i].Phones))
+	}
+
+	var header = []string{"First Name", "Last Name", "File As"}
+	for n := 1; n <= emails; n++ {
+		header = append(header, fmt.Sprintf("E-mail %d - Label", n), fmt.Sprintf("E-mail %d - Value", n))
+	}
+	for n := 1; n <= phones; n++ {
+		header = append(header, fmt.Sprintf("Phone %d - Label", n), fmt.Sprintf("Phone %d - Value", n))
+	}
+
+	var rows = [][]string{header}
+	for i := range contacts {
+		var c = &contacts[i]
+		var row = make([]string, len(header))
+		row[0], row[1] = csvNames(c)
+		row[2] = c.DisplayName
+		for n, e := range c.Emails {
+			row[3+2*n], row[4+2*n] = formatGoogleLabel(e.Type, e.IsPrimary), e.Email
+		}
+		for n, p := range c.Phones {
+			row[3+2*emails+2*n], row[4+2*emails+2*n] = formatGoogleLabel(p.Type, p.IsPrimary), p.PhoneNumber
+		}
+		rows = append(rows, row)
+	}
+	return writeCSV(rows)
+}
+
+// writeOutlookCSV writes contacts in the Outlook layout: three emails, the
+// primary first, and one phone of each type
+func writeOutlookCSV(contacts []ports.ContactInfo) ([]byte, error) {
+	var header = append([]string{"First Name", "Last Name"}, outlookEmailColumns...)
+	var phoneColumns = map[string]int{}
+	for _, column := range []string{"Mobile Phone", "Home Phone", "Business Phone", "Other Phone"} {
+		phoneColumns[column] = len(header)
+		header = append(header, column)
+	}
+
+	var rows = [][]string{header}
+	for i := range contacts {
+		var c = &contacts[i]
+		var row = make([]string, len(header))
+		row[0], row[1] = csvNames(c)
+		var emails = mergeEmails(nil, c.Emails)
+		for n, e := range emails {
+			if e.IsPrimary {
+				emails[0], emails[n] = emails[n], emails[0]
+			}
+		}
+		for n := 0; n < len(emails) && n < len(outlookEmailColumns); n++ {
+			row[2+n] = emails[n].Email
+		}
+		for _, p := range c.Phones {
+			var column = "Other Phone"
+			switch p.Type {
+			case "mobile", "cell":
+				column = "Mobile Phone"
+			case "home":
+				column = "Home Phone"
+			case "work":
+				column = "Business Phone"
+			}
+			if row[phoneColumns[column]] != "" {
+				column = "Other Phone"
+			}
+			if row[phoneColumns[column]] == "" {
+				row[phoneColumns[column]] = p.PhoneNumber
+			}
+		}
+		rows = append(rows, row)
+	}
+	return writeCSV(rows)
+}
+
+// csvNames returns the first and last name columns of a contact: its
+// display name as the first name when it has no structured name
+func csvNames(c *ports.ContactInfo) (first, last string) {
+	if c.GivenName == "" && c.FamilyName == "" {
+		return c.DisplayName, ""
+	}
+	return c.GivenName, c.FamilyName
+}
+
+// formatGoogleLabel writes a type as a Google label
+func formatGoogleLabel(kind string, primary bool) string {
+	var label = kind
+	if label != "" {
+		label = strings.ToUpper(label[:1]) + label[1:]
+	}
+	if primary {
+		label = strings.TrimSpace("* " + label)
+	}
+	return label
+}
+
+func writeCSV(rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer = csv.NewWriter(&buf)
+	writer.UseCRLF = true
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/vcard"
+)
+
+// importResourcePrefix starts the resource name of imported contacts,
+// followed by their vCard UID (a new one for CSV)
+const importResourcePrefix = "import/"
+
+// importedContact is an entry of an imported file: names, time zone,
+// emails and phones (and, from a vCard, the card itself) in contact, and
+// an inline photo
+type importedContact struct {
+	contact ports.ContactInfo
+	photo   []byte
+}
+
+// ImportContacts adds the contacts of a vCard or CSV file ("" format
+// detects it). Duplicates are found by email address, among the account's
+// contacts and the earlier entries of the file, and merged: the file's
+// names and time zone win, its emails and phones are added, and the
+// contact keeps its interaction count, last interaction, star and photo.
+// Imported contacts go to the CardDAV address book on the next sync.
+func (s *ContactService) ImportContacts(ctx context.Context, accountID int64, data []byte, format ports.ContactFormat) (*ports.ContactImportResult, error) {
+	if format == "" {
+		format = detectContactFormat(data)
+	}
+	var entries []importedContact
+	var err error
+	switch format {
+	case ports.ContactFormatVCard:
+		entries, err = readVCards(data)
+	case ports.ContactFormatGoogleCSV, ports.ContactFormatOutlookCSV:
+		entries, err = readContactsCSV(data)
+	default:
+		return nil, fmt.Errorf("unknown contact format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result = &ports.ContactImportResult{}
+	for i := range entries {
+		var entry = &entries[i]
+		if entry.contact.DisplayName == "" && len(entry.contact.Emails) == 0 {
+			result.Skipped++
+			continue
+		}
+
+		var existing, err = s.findImportDuplicate(ctx, accountID, entry)
+		if err != nil {
+			return result, err
+		}
+		if existing != nil {
+			if err := s.mergeImported(ctx, existing, entry); err != nil {
+				return result, fmt.Errorf("failed to merge contact %d: %w", existing.ID, err)
+			}
+			result.Merged++
+			continue
+		}
+		if err := s.createImported(ctx, accountID, entry); err != nil {
+			return result, fmt.Errorf("failed to import contact %q: %w", entry.contact.DisplayName, err)
+		}
+		result.Created++
+	}
+
+	log.Printf("[ContactService] Imported contacts for account %d: %d created, %d merged, %d skipped",
+		accountID, result.Created, result.Merged, result.Skipped)
+	return result, nil
+}
+
+// ExportContacts writes all contacts of an account in a format. vCards
+// keep what miau doesn't edit of the synced or imported ones, and carry
+// the photos inline.
+func (s *ContactService) ExportContacts(ctx context.Context, accountID int64, format ports.ContactFormat) ([]byte, error) {
+	switch format {
+	case ports.ContactFormatVCard, ports.ContactFormatGoogleCSV, ports.ContactFormatOutlookCSV:
+	default:
+		return nil, fmt.Errorf("unknown contact format %q", format)
+	}
+
+	// A negative limit is no limit for SQLite
+	var contacts, err = s.storage.ListContacts(ctx, accountID, -1)
+	if err != nil {
+		return nil, err
+	}
+	for i := range contacts {
+		contacts[i].Emails, _ = s.storage.GetContactEmails(ctx, contacts[i].ID)
+		contacts[i].Phones, _ = s.storage.GetContactPhones(ctx, contacts[i].ID)
+	}
+
+	switch format {
+	case ports.ContactFormatGoogleCSV:
+		return writeGoogleCSV(contacts)
+	case ports.ContactFormatOutlookCSV:
+		return writeOutlookCSV(contacts)
+	}
+	var buf bytes.Buffer
+	for i := range contacts {
+		buf.Write(contactCard(&contacts[i]).Encode())
+	}
+	return buf.Bytes(), nil
+}
+
+// detectContactFormat tells vCards from CSV, and the Outlook CSV layout
+// from Google's by its email column
+func detectContactFormat(data []byte) ports.ContactFormat {
+	var text = strings.TrimPrefix(string(data), "\ufeff")
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(text)), "BEGIN:VCARD") {
+		return ports.ContactFormatVCard
+	}
+	var header, _, _ = strings.Cut(text, "\n")
+	if strings.Contains(header, "E-mail Address") {
+		return ports.ContactFormatOutlookCSV
+	}
+	return ports.ContactFormatGoogleCSV
+}
+
+// readVCards reads the cards of a .vcf file
+func readVCards(data []byte) ([]importedContact, error) {
+	var cards, err = vcard.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries = make([]importedContact, 0, len(cards))
+	for _, card := range cards {
+		if card.UID == "" {
+			card.UID = newUID()
+		}
+		var entry = importedContact{
+			contact: ports.ContactInfo{
+				DisplayName:  card.Name,
+				GivenName:    card.GivenName,
+				FamilyName:   card.FamilyName,
+				TimeZone:     card.TimeZone,
+				PhotoURL:     card.Photo.URL,
+				VCard:        string(card.Encode()),
+				ResourceName: importResourcePrefix + card.UID,
+			},
+			photo: card.Photo.Data,
+		}
+		if entry.contact.DisplayName == "" {
+			entry.contact.DisplayName = joinNames(card.GivenName, card.FamilyName)
+		}
+		for _, e := range card.Emails {
+			if address := strings.TrimSpace(e.Address); address != "" {
+				entry.contact.Emails = append(entry.contact.Emails, ports.ContactEmailInfo{Email: address, Type: e.Type, IsPrimary: e.Preferred})
+			}
+		}
+		for _, p := range card.Phones {
+			if number := strings.TrimSpace(p.Number); number != "" {
+				entry.contact.Phones = append(entry.contact.Phones, ports.ContactPhoneInfo{PhoneNumber: number, Type: p.Type, IsPrimary: p.Preferred})
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// findImportDuplicate returns the contact an entry duplicates (nil if
+// none): the one the same vCard was imported as, or one sharing an email
+func (s *ContactService) findImportDuplicate(ctx context.Context, accountID int64, entry *importedContact) (*ports.ContactInfo, error) {
+	if entry.contact.ResourceName != "" {
+		var contact, err = s.storage.GetContactByResourceName(ctx, accountID, entry.contact.ResourceName)
+		if err != nil || contact != nil {
+			return contact, err
+		}
+	}
+	for _, e := range entry.contact.Emails {
+		if contact, err := s.storage.GetContactByEmail(ctx, accountID, e.Email); err == nil {
+			return contact, nil
+		}
+	}
+	return nil, nil
+}
+
+// createImported saves an entry as a new contact
+func (s *ContactService) createImported(ctx context.Context, accountID int64, entry *importedContact) error {
+	var contact = entry.contact
+	contact.AccountID = accountID
+	contact.PendingSync = true
+	if contact.ResourceName == "" {
+		contact.ResourceName = importResourcePrefix + newUID()
+	}
+	var contactID, err = s.storage.SaveContact(ctx, &contact)
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.SaveContactEmails(ctx, contactID, mergeEmails(nil, contact.Emails)); err != nil {
+		return err
+	}
+	if err := s.storage.SaveContactPhones(ctx, contactID, mergePhones(nil, contact.Phones)); err != nil {
+		return err
+	}
+	if photoPath := s.writePhoto(contactID, entry.photo); photoPath != "" {
+		contact.ID = contactID
+		contact.PhotoPath = photoPath
+		if _, err := s.storage.SaveContact(ctx, &contact); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeImported merges an entry into the contact it duplicates
+func (s *ContactService) mergeImported(ctx context.Context, existing *ports.ContactInfo, entry *importedContact) error {
+	var contact = *existing
+	var imported = &entry.contact
+	if imported.DisplayName != "" {
+		contact.DisplayName = imported.DisplayName
+	}
+	if imported.GivenName != "" {
+		contact.GivenName = imported.GivenName
+	}
+	if imported.FamilyName != "" {
+		contact.FamilyName = imported.FamilyName
+	}
+	if imported.TimeZone != "" {
+		contact.TimeZone = imported.TimeZone
+	}
+	if contact.PhotoURL == "" {
+		contact.PhotoURL = imported.PhotoURL
+	}
+	if contact.PhotoPath == "" {
+		contact.PhotoPath = s.writePhoto(contact.ID, entry.photo)
+	}
+	// Google contacts stay in Google
+	contact.PendingSync = !isGoogleContact(existing)
+	if _, err := s.storage.SaveContact(ctx, &contact); err != nil {
+		return err
+	}
+
+	var emails, err = s.storage.GetContactEmails(ctx, contact.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.SaveContactEmails(ctx, contact.ID, mergeEmails(emails, imported.Emails)); err != nil {
+		return err
+	}
+	phones, err := s.storage.GetContactPhones(ctx, contact.ID)
+	if err != nil {
+		return err
+	}
+	return s.storage.SaveContactPhones(ctx, contact.ID, mergePhones(phones, imported.Phones))
+}
+
+// mergeEmails adds to emails those of added it lacks (regardless of case),
+// keeping one primary: the first one when none is
+func mergeEmails(emails, added []ports.ContactEmailInfo) []ports.ContactEmailInfo {
+	var result = append([]ports.ContactEmailInfo(nil), emails...)
+	var seen = make(map[string]bool)
+	var primary = false
+	for _, e := range result {
+		seen[strings.ToLower(e.Email)] = true
+		primary = primary || e.IsPrimary
+	}
+	for _, e := range added {
+		if seen[strings.ToLower(e.Email)] {
+			continue
+		}
+		seen[strings.ToLower(e.Email)] = true
+		e.IsPrimary = e.IsPrimary && !primary
+		primary = primary || e.IsPrimary
+		result = append(result, e)
+	}
+	if len(result) > 0 && !primary {
+		result[0].IsPrimary = true
+	}
+	return result
+}
+
+// mergePhones adds to phones those of added it lacks (comparing digits),
+// keeping one primary: the first one when none is
+func mergePhones(phones, added []ports.ContactPhoneInfo) []ports.ContactPhoneInfo {
+	var result = append([]ports.ContactPhoneInfo(nil), phones...)
+	var seen = make(map[string]bool)
+	var primary = false
+	for _, p := range result {
+		seen[phoneDigits(p.PhoneNumber)] = true
+		primary = primary || p.IsPrimary
+	}
+	for _, p := range added {
+		if seen[phoneDigits(p.PhoneNumber)] {
+			continue
+		}
+		seen[phoneDigits(p.PhoneNumber)] = true
+		p.IsPrimary = p.IsPrimary && !primary
+		primary = primary || p.IsPrimary
+		result = append(result, p)
+	}
+	if len(result) > 0 && !primary {
+		result[0].IsPrimary = true
+	}
+	return result
+}
+
+// phoneDigits returns the digits of a phone number
+func phoneDigits(number string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+}
+
+// joinNames joins the non-empty parts of a name
+func joinNames(parts ...string) string {
+	var names []string
+	for _, part := range parts {
+		if part != "" {
+			names = append(names, part)
+		}
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/carddav"
+	"github.com/opik/miau/internal/carddav/carddavtest"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+	"github.com/opik/miau/internal/vcard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const importVCards = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"UID:jane@example.org\r\n" +
+	"FN:Jane Doe\r\n" +
+	"N:Doe;Jane;;;\r\n" +
+	"ORG:Example\r\n" +
+	"EMAIL;TYPE=INTERNET,WORK:jane@example.com\r\n" +
+	"TEL;TYPE=CELL:+1 555 0100\r\n" +
+	"TZ:Europe/Lisbon\r\n" +
+	"PHOTO;ENCODING=b;TYPE=PNG:iVBORw0KGgoAAAANSUhEUg==\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:4.0\r\n" +
+	"FN:Bob\r\n" +
+	"EMAIL:BOB@example.com\r\n" +
+	"END:VCARD\r\n"
+
+const importGoogleCSV = "First Name,Last Name,E-mail 1 - Label,E-mail 1 - Value,E-mail 2 - Label,E-mail 2 - Value,Phone 1 - Label,Phone 1 - Value\r\n" +
+	"Robert,Smith,* Work,bob@example.com,Home,bob@home.example ::: robert@home.example,Mobile,+1 555 0199\r\n" +
+	"Ana,,* Other,ana@example.com,,,,\r\n" +
+	"Ana,Silva,,ANA@example.com,,,Home,+55 11 5555-0100\r\n" +
+	",,,,,,,\r\n"
+
+const importOutlookCSV = "\xef\xbb\xbfFirst Name,Middle Name,Last Name,E-mail Address,E-mail 2 Address,Mobile Phone,Business Phone\r\n" +
+	"Carla,M.,Costa,carla@example.com,,+1 555 0111,+1 555 0112\r\n"
+
+func TestContactService_ImportContacts(t *testing.T) {
+	require.NoError(t, storage.Init(filepath.Join(t.TempDir(), "test.db")))
+	defer storage.Close()
+	var account, _ = storage.GetOrCreateAccount("me@example.org", "Me")
+	var ctx = context.Background()
+
+	var contactStorage = storage.NewContactStorageAdapter()
+	var s = NewContactService(contactStorage, nil, NewEventBus(), t.TempDir())
+
+	// A contact miau already knows, with a history
+	var bobID, _ = contactStorage.SaveContact(ctx, &ports.ContactInfo{AccountID: account.ID, ResourceName: "people/c1", DisplayName: "Bob"})
+	contactStorage.SaveContactEmails(ctx, bobID, []ports.ContactEmailInfo{{Email: "bob@example.com", IsPrimary: true}})
+	var lastInteraction = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, contactStorage.RecordInteraction(ctx, bobID, 0, "received", lastInteraction))
+
+	var result, err = s.ImportContacts(ctx, account.ID, []byte(importVCards), "")
+	require.NoError(t, err)
+	assert.Equal(t, &ports.ContactImportResult{Created: 1, Merged: 1}, result)
+
+	var jane, _ = s.GetContactByEmail(ctx, account.ID, "jane@example.com")
+	require.NotNil(t, jane)
+	assert.Equal(t, "Jane Doe", jane.DisplayName)
+	assert.Equal(t, "Europe/Lisbon", jane.TimeZone)
+	assert.True(t, jane.PendingSync)
+	assert.Contains(t, jane.VCard, "ORG:Example")
+	var photo, _ = os.ReadFile(jane.PhotoPath)
+	assert.Equal(t, []byte("\x89PNG"), photo[:4])
+
+	// Merged by email regardless of case: the history stays
+	var bob, _ = s.GetContact(ctx, bobID)
+	assert.Equal(t, 1, bob.InteractionCount)
+	require.NotNil(t, bob.LastInteractionAt)
+	assert.True(t, bob.LastInteractionAt.Equal(lastInteraction))
+	assert.False(t, bob.PendingSync, "Google contacts stay in Google")
+
+	// The same vCards again are duplicates
+	result, err = s.ImportContacts(ctx, account.ID, []byte(importVCards), ports.ContactFormatVCard)
+	require.NoError(t, err)
+	assert.Equal(t, &ports.ContactImportResult{Merged: 2}, result)
+
+	// Google CSV: duplicates within the file too
+	result, err = s.ImportContacts(ctx, account.ID, []byte(importGoogleCSV), "")
+	require.NoError(t, err)
+	assert.Equal(t, &ports.ContactImportResult{Created: 1, Merged: 2, Skipped: 1}, result)
+
+	bob, _ = s.GetContact(ctx, bobID)
+	assert.Equal(t, "Robert Smith", bob.DisplayName)
+	assert.Equal(t, 1, bob.InteractionCount)
+	var emails, _ = contactStorage.GetContactEmails(ctx, bobID)
+	require.Len(t, emails, 3)
+	assert.Equal(t, "bob@example.com", emails[0].Email)
+	assert.True(t, emails[0].IsPrimary)
+	assert.False(t, emails[1].IsPrimary || emails[2].IsPrimary)
+
+	var ana, _ = s.GetContactByEmail(ctx, account.ID, "ana@example.com")
+	require.NotNil(t, ana)
+	assert.Equal(t, "Ana Silva", ana.DisplayName)
+	emails, _ = contactStorage.GetContactEmails(ctx, ana.ID)
+	assert.Len(t, emails, 1)
+	var phones, _ = contactStorage.GetContactPhones(ctx, ana.ID)
+	require.Len(t, phones, 1)
+	assert.Equal(t, "home", phones[0].Type)
+
+	// Outlook CSV
+	result, err = s.ImportContacts(ctx, account.ID, []byte(importOutlookCSV), "")
+	require.NoError(t, err)
+	assert.Equal(t, &ports.ContactImportResult{Created: 1}, result)
+	var carla, _ = s.GetContactByEmail(ctx, account.ID, "carla@example.com")
+	require.NotNil(t, carla)
+	assert.Equal(t, "Carla M. Costa", carla.DisplayName)
+	phones, _ = contactStorage.GetContactPhones(ctx, carla.ID)
+	require.Len(t, phones, 2)
+	assert.Equal(t, "mobile", phones[0].Type)
+	assert.True(t, phones[0].IsPrimary)
+
+	_, err = s.ImportContacts(ctx, account.ID, []byte("foo,bar\r\n1,2\r\n"), "")
+	assert.Error(t, err)
+}
+
+func TestContactService_ExportContacts(t *testing.T) {
+	require.NoError(t, storage.Init(filepath.Join(t.TempDir(), "test.db")))
+	defer storage.Close()
+	var account, _ = storage.GetOrCreateAccount("me@example.org", "Me")
+	var ctx = context.Background()
+
+	var contactStorage = storage.NewContactStorageAdapter()
+	var s = NewContactService(contactStorage, nil, NewEventBus(), t.TempDir())
+	_, err := s.ImportContacts(ctx, account.ID, []byte(importVCards), "")
+	require.NoError(t, err)
+	var supportID, _ = contactStorage.SaveContact(ctx, &ports.ContactInfo{AccountID: account.ID, ResourceName: "people/c2", DisplayName: "Support"})
+	contactStorage.SaveContactEmails(ctx, supportID, []ports.ContactEmailInfo{
+		{Email: "help@example.com", Type: "work"},
+		{Email: "support@example.com", Type: "work", IsPrimary: true},
+	})
+	contactStorage.SaveContactPhones(ctx, supportID, []ports.ContactPhoneInfo{
+		{PhoneNumber: "+1 555 0150", Type: "work"},
+		{PhoneNumber: "+1 555 0151", Type: "work"},
+	})
+
+	// vCard: what miau doesn't edit survives
+	var data, errExport = s.ExportContacts(ctx, account.ID, ports.ContactFormatVCard)
+	require.NoError(t, errExport)
+	var cards, errParse = vcard.Parse(data)
+	require.NoError(t, errParse)
+	require.Len(t, cards, 3)
+	assert.Equal(t, "jane@example.org", cards[1].UID)
+	assert.Contains(t, string(data), "ORG:Example")
+	assert.Equal(t, "Support", cards[2].Name)
+	assert.Equal(t, "support@example.com", cards[2].Emails[0].Address)
+
+	data, errExport = s.ExportContacts(ctx, account.ID, ports.ContactFormatGoogleCSV)
+	require.NoError(t, errExport)
+	var lines = strings.Split(strings.TrimSpace(string(data)), "\r\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "First Name,Last Name,File As,E-mail 1 - Label,E-mail 1 - Value,E-mail 2 - Label,E-mail 2 - Value,Phone 1 - Label,Phone 1 - Value,Phone 2 - Label,Phone 2 - Value", lines[0])
+	assert.Equal(t, "Support,,Support,* Work,support@example.com,Work,help@example.com,Work,+1 555 0150,Work,+1 555 0151", lines[3])
+
+	data, errExport = s.ExportContacts(ctx, account.ID, ports.ContactFormatOutlookCSV)
+	require.NoError(t, errExport)
+	lines = strings.Split(strings.TrimSpace(string(data)), "\r\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "First Name,Last Name,E-mail Address,E-mail 2 Address,E-mail 3 Address,Mobile Phone,Home Phone,Business Phone,Other Phone", lines[0])
+	assert.Equal(t, "Jane,Doe,jane@example.com,,,+1 555 0100,,,", lines[2])
+	assert.Equal(t, "Support,,support@example.com,help@example.com,,,,+1 555 0150,+1 555 0151", lines[3])
+
+	// Exported files come back as duplicates
+	for _, format := range []ports.ContactFormat{ports.ContactFormatGoogleCSV, ports.ContactFormatOutlookCSV} {
+		data, _ = s.ExportContacts(ctx, account.ID, format)
+		var result, err = s.ImportContacts(ctx, account.ID, data, "")
+		require.NoError(t, err)
+		assert.Equal(t, &ports.ContactImportResult{Merged: 3}, result, format)
+	}
+
+	_, err = s.ExportContacts(ctx, account.ID, "json")
+	assert.Error(t, err)
+}
+
+func TestContactService_ImportPushesToCardDAV(t *testing.T) {
+	require.NoError(t, storage.Init(filepath.Join(t.TempDir(), "test.db")))
+	defer storage.Close()
+	var account, _ = storage.GetOrCreateAccount("me@example.org", "Me")
+	var ctx = context.Background()
+
+	var server = carddavtest.NewServer()
+	defer server.Close()
+	var client, err = carddav.NewClient(server.URL, carddavtest.Username, carddavtest.Password)
+	require.NoError(t, err)
+	var s = NewContactService(storage.NewContactStorageAdapter(), nil, NewEventBus(), t.TempDir())
+	s.SetCardDAV(account.ID, client)
+
+	_, err = s.ImportContacts(ctx, account.ID, []byte(importVCards), "")
+	require.NoError(t, err)
+	require.NoError(t, s.SyncContacts(ctx, account.ID, false))
+
+	var hrefs = server.Hrefs()
+	require.Len(t, hrefs, 2)
+	assert.Contains(t, hrefs, carddavtest.AddressbookPath+"jane@example.org.vcf")
+	var data, _ = server.Object(carddavtest.AddressbookPath + "jane@example.org.vcf")
+	assert.Contains(t, string(data), "ORG:Example")
+	var jane, _ = s.GetContactByEmail(ctx, account.ID, "jane@example.com")
+	assert.False(t, jane.PendingSync)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 
 	emailparser "github.com/opik/miau/internal/email"
@@ -19,6 +20,13 @@ type EmailService struct {
 	undo    ports.UndoService
 	account *ports.AccountInfo
 	folder  *ports.Folder
+	pgp     ports.PGPService
+	smime   ports.SMIMEService
+	risk    ports.RiskService
+	invites ports.InviteService
+
+	// Other accounts of a multi-account runtime (unified inbox)
+	accounts accountSet
 }
 
 // NewEmailService creates a new EmailService
@@ -38,6 +46,46 @@ func (s *EmailService) SetAccount(account *ports.AccountInfo) {
 	s.account = account
 }
 
+// AddAccount registers an account of the multi-account runtime, so its
+// emails show up in the unified inbox and are acted on over its own connection
+func (s *EmailService) AddAccount(account *ports.AccountInfo, imap ports.IMAPPort) {
+	s.accounts.add(account, imap)
+}
+
+// SetPGP sets the PGP service used to decrypt and verify emails
+func (s *EmailService) SetPGP(pgp ports.PGPService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pgp = pgp
+}
+
+// SetSMIME sets the S/MIME service used to decrypt and verify emails
+func (s *EmailService) SetSMIME(smime ports.SMIMEService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.smime = smime
+}
+
+// SetRisk sets the risk service that rates opened emails
+func (s *EmailService) SetRisk(risk ports.RiskService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.risk = risk
+}
+
+// SetInvites sets the invite service that reads the meeting invitations
+// (text/calendar parts) of the emails opened
+func (s *EmailService) SetInvites(invites ports.InviteService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invites = invites
+}
+
+// imapFor returns the IMAP connection of the account an email belongs to
+func (s *EmailService) imapFor(accountID int64) ports.IMAPPort {
+	return s.accounts.imapFor(accountID, s.imap)
+}
+
 // GetFolders returns all folders for the current account
 func (s *EmailService) GetFolders(ctx context.Context) ([]ports.Folder, error) {
 	s.mu.RLock()
@@ -61,13 +109,22 @@ func (s *EmailService) SelectFolder(ctx context.Context, name string) (*ports.Fo
 		return nil, fmt.Errorf("no account set")
 	}
 
+	// The unified inbox is local only, there's nothing to select
+	if name == ports.UnifiedInbox {
+		var folder = &ports.Folder{Name: ports.UnifiedInbox}
+		s.mu.Lock()
+		s.folder = folder
+		s.mu.Unlock()
+		return folder, nil
+	}
+
 	var folder, err = s.storage.GetFolderByName(ctx, account.ID, name)
 	if err != nil {
 		return nil, err
 	}
 
 	// Select on IMAP
-	var _, err2 = s.imap.SelectMailbox(ctx, name)
+	var _, err2 = s.imapFor(account.ID).SelectMailbox(ctx, name)
 	if err2 != nil {
 		return nil, fmt.Errorf("failed to select mailbox: %w", err2)
 	}
@@ -79,7 +136,8 @@ func (s *EmailService) SelectFolder(ctx context.Context, name string) (*ports.Fo
 	return folder, nil
 }
 
-// GetEmails returns emails from the current folder
+// GetEmails returns emails from the current folder.
+// ports.UnifiedInbox lists the INBOX of every account.
 func (s *EmailService) GetEmails(ctx context.Context, folder string, limit int) ([]ports.EmailMetadata, error) {
 	s.mu.RLock()
 	var account = s.account
@@ -89,12 +147,26 @@ func (s *EmailService) GetEmails(ctx context.Context, folder string, limit int)
 		return nil, fmt.Errorf("no account set")
 	}
 
-	var f, err = s.storage.GetFolderByName(ctx, account.ID, folder)
-	if err != nil {
-		return nil, err
+	var emails []ports.EmailMetadata
+	if folder == ports.UnifiedInbox {
+		var unified, err = s.storage.GetUnifiedEmails(ctx, "INBOX", limit)
+		if err != nil {
+			return nil, err
+		}
+		emails = unified
+	} else {
+		var f, err = s.storage.GetFolderByName(ctx, account.ID, folder)
+		if err != nil {
+			return nil, err
+		}
+		if emails, err = s.storage.GetEmails(ctx, f.ID, limit); err != nil {
+			return nil, err
+		}
 	}
 
-	return s.storage.GetEmails(ctx, f.ID, limit)
+	s.attachLabels(ctx, emails)
+	s.attachRisk(ctx, emails)
+	return emails, nil
 }
 
 // GetEmail returns a single email by ID with full content
@@ -111,9 +183,12 @@ func (s *EmailService) GetEmail(ctx context.Context, id int64) (*ports.EmailCont
 		email.HasAttachments = true
 	}
 
+	// The email may belong to another account (unified inbox)
+	var imap = s.imapFor(email.AccountID)
+
 	// Select the correct folder in IMAP before fetching
 	if email.FolderName != "" {
-		if _, err := s.imap.SelectMailbox(ctx, email.FolderName); err != nil {
+		if _, err := imap.SelectMailbox(ctx, email.FolderName); err != nil {
 			// Log but continue - the mailbox might already be selected
 			log.Printf("[GetEmail] Failed to select mailbox %s: %v", email.FolderName, err)
 		}
@@ -121,7 +196,7 @@ func (s *EmailService) GetEmail(ctx context.Context, id int64) (*ports.EmailCont
 
 	// If no attachments from DB but has_attachments flag is set, fetch from IMAP
 	if len(email.Attachments) == 0 && email.HasAttachments {
-		var imapAtts, hasAtts, attErr = s.imap.FetchAttachmentMetadata(ctx, email.UID)
+		var imapAtts, hasAtts, attErr = imap.FetchAttachmentMetadata(ctx, email.UID)
 		if attErr == nil && hasAtts {
 			for _, att := range imapAtts {
 				var contentID = att.ContentID
@@ -142,14 +217,65 @@ func (s *EmailService) GetEmail(ctx context.Context, id int64) (*ports.EmailCont
 		}
 	}
 
+	s.mu.RLock()
+	var pgp = s.pgp
+	var smime = s.smime
+	var risk = s.risk
+	var invites = s.invites
+	s.mu.RUnlock()
+
+	// Meeting invites are read from the saved event; when the email wasn't
+	// imported yet, the raw message is fetched for its text/calendar part
+	var importInvite = false
+	if invites != nil && hasParts(email.Attachments, isCalendarPart) {
+		if saved, err := invites.GetInvite(ctx, id); err != nil {
+			log.Printf("[GetEmail] Failed to load invite: %v", err)
+		} else {
+			email.Invite = saved
+		}
+		importInvite = email.Invite == nil
+	}
+
+	// PGP/MIME and S/MIME emails are opened on every read, so signatures are
+	// checked against the current keys and decrypted bodies never hit the
+	// database
+	var protected = (pgp != nil && hasParts(email.Attachments, isPGPPart)) ||
+		(smime != nil && hasParts(email.Attachments, isSMIMEPart))
+	if protected {
+		email.Attachments = withoutParts(email.Attachments, isProtectedPart)
+		email.HasAttachments = len(email.Attachments) > 0
+	}
+
 	// If body is empty, fetch from IMAP and cache it
-	if email.BodyText == "" && email.BodyHTML == "" {
-		var rawData, fetchErr = s.imap.FetchEmailRaw(ctx, email.UID)
+	if (email.BodyText == "" && email.BodyHTML == "") || protected || importInvite {
+		var rawData, fetchErr = imap.FetchEmailRaw(ctx, email.UID)
 		if fetchErr != nil {
 			log.Printf("[GetEmail] Failed to fetch email body: %v", fetchErr)
+			email.Risk = s.emailRisk(ctx, risk, id, nil)
 			return email, nil // Return without body
 		}
 
+		// Rate the message as received: the DKIM signature covers the
+		// encrypted body, not the opened one
+		email.Risk = s.emailRisk(ctx, risk, id, rawData)
+
+		if smime != nil {
+			if opened, err := smime.Open(ctx, rawData); err != nil {
+				log.Printf("[GetEmail] Failed to open S/MIME email: %v", err)
+			} else {
+				rawData = opened.Raw
+				email.Security = opened.Security
+			}
+		}
+		if pgp != nil && email.Security == nil {
+			if opened, err := pgp.Open(ctx, rawData); err != nil {
+				log.Printf("[GetEmail] Failed to open PGP/MIME email: %v", err)
+			} else {
+				rawData = opened.Raw
+				email.Security = opened.Security
+			}
+		}
+
 		// Parse email content
 		var parsed, _ = emailparser.Parse(rawData)
 		if parsed != nil {
@@ -157,16 +283,101 @@ func (s *EmailService) GetEmail(ctx context.Context, id int64) (*ports.EmailCont
 			email.BodyHTML = parsed.HTMLBody
 
 			// Cache the body in database for future requests
-			if err := s.storage.UpdateEmailBody(ctx, id, parsed.TextBody, parsed.HTMLBody); err != nil {
-				log.Printf("[GetEmail] Failed to cache email body: %v", err)
-				// Continue anyway, we have the body in memory
+			if email.Security == nil {
+				if err := s.storage.UpdateEmailBody(ctx, id, parsed.TextBody, parsed.HTMLBody); err != nil {
+					log.Printf("[GetEmail] Failed to cache email body: %v", err)
+					// Continue anyway, we have the body in memory
+				}
+			}
+
+			if invites != nil && email.Invite == nil && len(parsed.Calendar) > 0 {
+				if invite, err := invites.Import(ctx, id, parsed.Calendar); err != nil {
+					log.Printf("[GetEmail] Failed to import invite: %v", err)
+				} else {
+					email.Invite = invite
+				}
 			}
 		}
+	} else {
+		email.Risk = s.emailRisk(ctx, risk, id, nil)
 	}
 
 	return email, nil
 }
 
+// emailRisk returns the risk analysis of an email (best effort). A whole
+// message is always analysed, since only then DKIM can be verified;
+// otherwise the saved result is used, analysing the email if there's none.
+func (s *EmailService) emailRisk(ctx context.Context, risk ports.RiskService, id int64, raw []byte) *ports.RiskInfo {
+	if risk == nil {
+		return nil
+	}
+	if raw == nil {
+		if saved, err := risk.GetRisk(ctx, id); err == nil && saved != nil {
+			return saved
+		}
+	}
+	var result, err = risk.Assess(ctx, id, raw)
+	if err != nil {
+		log.Printf("[GetEmail] Risk analysis failed: %v", err)
+		return nil
+	}
+	return result
+}
+
+// hasParts reports whether any attachment matches
+func hasParts(attachments []ports.Attachment, match func(ports.Attachment) bool) bool {
+	for _, att := range attachments {
+		if match(att) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutParts drops the matching attachments
+func withoutParts(attachments []ports.Attachment, match func(ports.Attachment) bool) []ports.Attachment {
+	var result []ports.Attachment
+	for _, att := range attachments {
+		if !match(att) {
+			result = append(result, att)
+		}
+	}
+	return result
+}
+
+// isProtectedPart matches the cryptographic parts of PGP/MIME and S/MIME
+func isProtectedPart(att ports.Attachment) bool {
+	return isPGPPart(att) || isSMIMEPart(att)
+}
+
+// isPGPPart matches the signature or the encrypted payload of RFC 3156
+func isPGPPart(att ports.Attachment) bool {
+	var contentType = strings.ToLower(att.ContentType)
+	return strings.HasPrefix(contentType, "application/pgp-signature") ||
+		strings.HasPrefix(contentType, "application/pgp-encrypted") ||
+		(strings.HasPrefix(contentType, "application/octet-stream") && strings.EqualFold(att.Filename, "encrypted.asc"))
+}
+
+// isSMIMEPart matches the signature or the enveloped/opaque-signed payload
+// of RFC 8551, including the legacy x- types and octet-stream .p7m/.p7s
+func isSMIMEPart(att ports.Attachment) bool {
+	var contentType = strings.ToLower(att.ContentType)
+	var filename = strings.ToLower(att.Filename)
+	return strings.HasPrefix(contentType, "application/pkcs7-") ||
+		strings.HasPrefix(contentType, "application/x-pkcs7-") ||
+		(strings.HasPrefix(contentType, "application/octet-stream") &&
+			(strings.HasSuffix(filename, ".p7m") || strings.HasSuffix(filename, ".p7s")))
+}
+
+// isCalendarPart matches an iCalendar object (a meeting invite)
+func isCalendarPart(att ports.Attachment) bool {
+	var contentType = strings.ToLower(att.ContentType)
+	return strings.HasPrefix(contentType, "text/calendar") ||
+		strings.HasPrefix(contentType, "application/ics") ||
+		strings.HasSuffix(strings.ToLower(att.Filename), ".ics")
+}
+
 // GetEmailByUID returns an email by UID
 func (s *EmailService) GetEmailByUID(ctx context.Context, folder string, uid uint32) (*ports.EmailContent, error) {
 	s.mu.RLock()
@@ -201,7 +412,7 @@ func (s *EmailService) MarkAsRead(ctx context.Context, id int64, read bool) erro
 		email.Subject,
 		email.UID,
 		s.storage,
-		s.imap,
+		s.imapFor(email.AccountID),
 	)
 
 	// Execute operation
@@ -267,7 +478,7 @@ func (s *EmailService) Archive(ctx context.Context, id int64) error {
 		email.UID,
 		false, // wasArchived (we're archiving now, so it wasn't before)
 		s.storage,
-		s.imap,
+		s.imapFor(email.AccountID),
 	)
 
 	// Execute operation
@@ -297,7 +508,7 @@ func (s *EmailService) Delete(ctx context.Context, id int64) error {
 		email.UID,
 		false, // wasDeleted (we're deleting now, so it wasn't before)
 		s.storage,
-		s.imap,
+		s.imapFor(email.AccountID),
 	)
 
 	// Execute operation
@@ -328,7 +539,7 @@ func (s *EmailService) MoveToFolder(ctx context.Context, id int64, folder string
 		folder,           // to folder
 		email.UID,
 		s.storage,
-		s.imap,
+		s.imapFor(email.AccountID),
 	)
 
 	// Execute operation
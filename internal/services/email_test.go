@@ -18,7 +18,7 @@ func TestEmailService_GetFolders_Success(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 	svc.SetAccount(testutil.TestAccount())
 
 	var folders = testutil.TestFolders()
@@ -42,7 +42,7 @@ func TestEmailService_GetFolders_NoAccount(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 	// Don't set account
 
 	// Act
@@ -62,7 +62,7 @@ func TestEmailService_SelectFolder_Success(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 	svc.SetAccount(testutil.TestAccount())
 
 	var folder = testutil.TestFolder()
@@ -89,7 +89,7 @@ func TestEmailService_SelectFolder_FolderNotFound(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 	svc.SetAccount(testutil.TestAccount())
 
 	mockStorage.On("GetFolderByName", mock.Anything, int64(1), "NonExistent").Return(nil, errors.New("folder not found"))
@@ -110,7 +110,7 @@ func TestEmailService_SelectFolder_IMAPError(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 	svc.SetAccount(testutil.TestAccount())
 
 	var folder = testutil.TestFolder()
@@ -133,7 +133,7 @@ func TestEmailService_GetEmails_Success(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 	svc.SetAccount(testutil.TestAccount())
 
 	var folder = testutil.TestFolder()
@@ -141,6 +141,11 @@ func TestEmailService_GetEmails_Success(t *testing.T) {
 
 	mockStorage.On("GetFolderByName", mock.Anything, int64(1), "INBOX").Return(folder, nil)
 	mockStorage.On("GetEmails", mock.Anything, folder.ID, 50).Return(emails, nil)
+	mockStorage.On("GetLabelsForMessages", mock.Anything, emails[0].AccountID,
+		[]string{"<msg001@example.com>", "<msg002@example.com>", "<msg003@example.com>"}).
+		Return(map[string][]string{"msg001@example.com": {"Work"}}, nil)
+	mockStorage.On("GetRiskForEmails", mock.Anything, []int64{emails[0].ID, emails[1].ID, emails[2].ID}).
+		Return(map[int64]ports.RiskInfo{emails[1].ID: {Score: 80, Level: ports.RiskHigh}}, nil)
 
 	// Act
 	var result, err = svc.GetEmails(context.Background(), "INBOX", 50)
@@ -149,6 +154,9 @@ func TestEmailService_GetEmails_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Len(t, result, 3)
+	assert.Equal(t, []string{"Work"}, result[0].Labels)
+	assert.Empty(t, result[1].Labels)
+	assert.Equal(t, ports.RiskHigh, result[1].RiskLevel)
 
 	mockStorage.AssertExpectations(t)
 }
@@ -159,7 +167,7 @@ func TestEmailService_GetEmails_NoAccount(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 	// Don't set account
 
 	// Act
@@ -177,7 +185,7 @@ func TestEmailService_GetEmail_FromStorage(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	var email = testutil.TestEmailContent()
 	email.BodyText = "Already has body"
@@ -206,7 +214,7 @@ func TestEmailService_GetEmail_FetchFromIMAP(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	var email = testutil.TestEmailContent()
 	email.BodyText = "" // Empty body - should trigger IMAP fetch
@@ -220,6 +228,7 @@ func TestEmailService_GetEmail_FetchFromIMAP(t *testing.T) {
 	mockStorage.On("GetAttachmentsByEmail", mock.Anything, int64(1)).Return([]ports.Attachment{}, nil)
 	mockIMAP.On("SelectMailbox", mock.Anything, "INBOX").Return(testutil.TestMailboxStatus(), nil)
 	mockIMAP.On("FetchEmailRaw", mock.Anything, email.UID).Return(rawEmail, nil)
+	mockStorage.On("UpdateEmailBody", mock.Anything, int64(1), mock.Anything, mock.Anything).Return(nil)
 
 	// Act
 	var result, err = svc.GetEmail(context.Background(), 1)
@@ -238,7 +247,7 @@ func TestEmailService_GetEmail_NotFound(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	mockStorage.On("GetEmail", mock.Anything, int64(999)).Return(nil, errors.New("email not found"))
 
@@ -256,7 +265,7 @@ func TestEmailService_MarkAsRead_Success(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	var email = testutil.TestEmailContent()
 
@@ -282,7 +291,7 @@ func TestEmailService_MarkAsRead_Unread(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	var email = testutil.TestEmailContent()
 
@@ -306,8 +315,9 @@ func TestEmailService_MarkAsStarred_Success(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
+	mockStorage.On("GetEmail", mock.Anything, int64(1)).Return(testutil.TestEmailContent(), nil)
 	mockStorage.On("MarkAsStarred", mock.Anything, int64(1), true).Return(nil)
 
 	// Act
@@ -325,7 +335,7 @@ func TestEmailService_Archive_Success(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	var email = testutil.TestEmailContent()
 
@@ -349,7 +359,7 @@ func TestEmailService_Archive_IMAPError(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	var email = testutil.TestEmailContent()
 
@@ -372,7 +382,7 @@ func TestEmailService_Delete_Success(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	var email = testutil.TestEmailContent()
 
@@ -396,7 +406,7 @@ func TestEmailService_Delete_IMAPError(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	var email = testutil.TestEmailContent()
 
@@ -419,7 +429,7 @@ func TestEmailService_MoveToFolder_Success(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 
 	var email = testutil.TestEmailContent()
 
@@ -441,7 +451,7 @@ func TestEmailService_GetLatestUID_Success(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 	svc.SetAccount(testutil.TestAccount())
 
 	var folder = testutil.TestFolder()
@@ -463,7 +473,7 @@ func TestEmailService_GetLatestUID_NoAccount(t *testing.T) {
 	var mockStorage = new(mocks.StoragePort)
 	var mockEvents = new(mocks.EventBus)
 
-	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents)
+	var svc = NewEmailService(mockIMAP, mockStorage, mockEvents, nil)
 	// Don't set account
 
 	// Act
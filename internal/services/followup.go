@@ -0,0 +1,564 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+)
+
+// Follow-up worker: due follow-ups are checked every followUpPollInterval,
+// or right when the next reminder is due. Replies are looked for in the
+// local database, so they show up once sync has fetched them.
+const (
+	followUpPollInterval = 5 * time.Minute
+	followUpNudgeTimeout = 2 * time.Minute
+)
+
+// followUpNudgePrompt is the instruction given to AIService.GenerateReply
+// for the nudge draft
+const followUpNudgePrompt = "Ninguém respondeu esta conversa desde o último email que eu enviei. " +
+	"Escreva um follow-up curto e cordial pedindo um retorno, sem repetir todo o conteúdo."
+
+// FollowUpService implements ports.FollowUpService
+type FollowUpService struct {
+	mu      sync.RWMutex
+	threads ports.ThreadService
+	tasks   ports.TaskService
+	ai      ports.AIService
+	events  ports.EventBus
+	account *ports.AccountInfo
+	nudge   bool
+	now     func() time.Time
+
+	// accounts are the ones whose follow-ups the worker processes; without
+	// any, only the current account is
+	accounts []followUpAccount
+
+	processing sync.Mutex // one Process at a time
+	cancel     context.CancelFunc
+	done       chan struct{}
+	wake       chan struct{}
+}
+
+// NewFollowUpService creates a new FollowUpService. Replies are looked up
+// with threads; reminders create tasks in tasks (may be nil).
+func NewFollowUpService(threads ports.ThreadService, tasks ports.TaskService, events ports.EventBus) *FollowUpService {
+	return &FollowUpService{
+		threads: threads,
+		tasks:   tasks,
+		events:  events,
+		now:     time.Now,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// SetAccount sets the current account
+func (s *FollowUpService) SetAccount(account *ports.AccountInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account = account
+}
+
+// followUpAccount is an account processed by the worker, with the thread
+// service that sees its emails and the connection that resurfaces them
+type followUpAccount struct {
+	info    *ports.AccountInfo
+	threads ports.ThreadService
+	imap    ports.IMAPPort
+}
+
+// AddAccount registers an account for the worker; threads must be set to
+// that account. Adding it again replaces its services.
+func (s *FollowUpService) AddAccount(account *ports.AccountInfo, threads ports.ThreadService, imap ports.IMAPPort) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entry = followUpAccount{info: account, threads: threads, imap: imap}
+	for i, a := range s.accounts {
+		if a.info.ID == account.ID {
+			s.accounts[i] = entry
+			return
+		}
+	}
+	s.accounts = append(s.accounts, entry)
+}
+
+// processedAccounts returns the accounts the worker processes
+func (s *FollowUpService) processedAccounts() []followUpAccount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.accounts) > 0 {
+		return append([]followUpAccount(nil), s.accounts...)
+	}
+	if s.account == nil {
+		return nil
+	}
+	return []followUpAccount{{info: s.account, threads: s.threads}}
+}
+
+// SetNudge sets whether reminders pre-generate a nudge draft with ai
+// (nil or false disables it)
+func (s *FollowUpService) SetNudge(ai ports.AIService, enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ai = ai
+	s.nudge = enabled
+}
+
+// FollowUpDraft sets the follow-up of a draft, replacing the previous one;
+// after <= 0 removes it
+func (s *FollowUpService) FollowUpDraft(ctx context.Context, draftID int64, after time.Duration) error {
+	var draft, err = storage.GetDraftByID(draftID)
+	if err != nil {
+		return fmt.Errorf("draft not found: %w", err)
+	}
+	switch draft.Status {
+	case storage.DraftStatusSent, storage.DraftStatusSending, storage.DraftStatusCancelled:
+		return fmt.Errorf("draft %d is %s", draftID, draft.Status)
+	}
+
+	if err := storage.CancelDraftFollowUps(draftID); err != nil {
+		return err
+	}
+	if after <= 0 {
+		return nil
+	}
+
+	_, err = storage.CreateFollowUp(&storage.FollowUp{
+		AccountID:      draft.AccountID,
+		DraftID:        sql.NullInt64{Int64: draftID, Valid: true},
+		WaitSeconds:    int64(after / time.Second),
+		ToAddresses:    draft.ToAddresses,
+		Subject:        draft.Subject,
+		ReplyToEmailID: draft.ReplyToEmailID,
+		Status:         storage.FollowUpWaiting,
+	})
+	return err
+}
+
+// track starts the follow-up asked for in req, whose email was just sent
+// from accountID
+func (s *FollowUpService) track(accountID int64, req *ports.SendRequest, result *ports.SendResult) error {
+	var f = &storage.FollowUp{
+		AccountID:   accountID,
+		WaitSeconds: int64(req.FollowUpAfter / time.Second),
+		ToAddresses: strings.Join(req.To, ", "),
+		Subject:     req.Subject,
+		MessageID:   toNullString(result.MessageID),
+		ThreadID:    toNullString(result.ThreadID),
+		SentAt:      storage.SQLiteTime{Time: s.sentAt(result)},
+		Status:      storage.FollowUpPending,
+	}
+	if req.ReplyToEmailID != nil {
+		f.ReplyToEmailID = sql.NullInt64{Int64: *req.ReplyToEmailID, Valid: true}
+	}
+	if _, err := storage.CreateFollowUp(f); err != nil {
+		return err
+	}
+	s.Wake()
+	return nil
+}
+
+// draftSent starts the follow-ups of a draft that was just sent
+func (s *FollowUpService) draftSent(draftID int64, result *ports.SendResult) error {
+	var n, err = storage.StartDraftFollowUps(draftID, result.MessageID, result.ThreadID, s.sentAt(result))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		s.Wake()
+	}
+	return nil
+}
+
+// sentAt returns when the email of result was sent
+func (s *FollowUpService) sentAt(result *ports.SendResult) time.Time {
+	if !result.SentAt.IsZero() {
+		return result.SentAt
+	}
+	return s.now()
+}
+
+// List returns the open follow-ups of the current account
+func (s *FollowUpService) List(ctx context.Context) ([]ports.FollowUp, error) {
+	s.mu.RLock()
+	var account = s.account
+	s.mu.RUnlock()
+
+	if account == nil {
+		return nil, fmt.Errorf("no account set")
+	}
+
+	var list, err = storage.GetFollowUps(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	var result = make([]ports.FollowUp, 0, len(list))
+	for _, f := range list {
+		result = append(result, followUpToPort(f))
+	}
+	return result, nil
+}
+
+// Cancel drops a follow-up that hasn't fired yet
+func (s *FollowUpService) Cancel(ctx context.Context, id int64) error {
+	var cancelled, err = storage.CancelFollowUp(id)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return fmt.Errorf("follow-up %d is not open", id)
+	}
+	return nil
+}
+
+// Process checks the due follow-ups of every account: the ones somebody
+// replied to are closed, the others remind the user
+func (s *FollowUpService) Process(ctx context.Context) (int, error) {
+	s.processing.Lock()
+	defer s.processing.Unlock()
+
+	var reminded = 0
+	var firstErr error
+	for _, account := range s.processedAccounts() {
+		var n, err = s.processAccount(ctx, account)
+		reminded += n
+		if ctx.Err() != nil {
+			return reminded, ctx.Err()
+		}
+		if err != nil {
+			log.Printf("[FollowUpService] Failed to process follow-ups of %s: %v", account.info.Email, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if reminded > 0 {
+		log.Printf("[FollowUpService] %d email(s) without reply", reminded)
+	}
+	return reminded, firstErr
+}
+
+// processAccount checks the due follow-ups of one account
+func (s *FollowUpService) processAccount(ctx context.Context, account followUpAccount) (int, error) {
+	var due, err = storage.GetDueFollowUps(account.info.ID, s.now())
+	if err != nil {
+		return 0, err
+	}
+
+	var reminded = 0
+	for _, f := range due {
+		if ctx.Err() != nil {
+			return reminded, ctx.Err()
+		}
+
+		var thread = s.lookup(ctx, account, f)
+		if thread.replied {
+			storage.ClaimFollowUp(f.ID, storage.FollowUpReplied)
+			continue
+		}
+		if claimed, err := storage.ClaimFollowUp(f.ID, storage.FollowUpReminded); err != nil || !claimed {
+			continue
+		}
+		s.remind(ctx, account, f, thread)
+		reminded++
+	}
+	return reminded, nil
+}
+
+// followUpThread is what the conversation of a follow-up looks like
+type followUpThread struct {
+	replied   bool
+	emailID   int64  // email to resurface: newest inbound, else the sent copy
+	lastMsgID string // Message-ID of the newest message, for the nudge
+}
+
+// lookup looks for inbound replies in the threads the sent email may be in:
+// the Gmail thread, the thread of its synced copy, the thread replies get
+// when the copy isn't synced (its own Message-ID) and the thread of the
+// email it replied to
+func (s *FollowUpService) lookup(ctx context.Context, account followUpAccount, f storage.FollowUp) followUpThread {
+	var result followUpThread
+	var threadIDs []string
+	var add = func(id string) {
+		if id == "" {
+			return
+		}
+		for _, seen := range threadIDs {
+			if seen == id {
+				return
+			}
+		}
+		threadIDs = append(threadIDs, id)
+	}
+
+	add(f.ThreadID.String)
+	var sentCopy *storage.Email
+	if f.MessageID.Valid {
+		sentCopy, _ = storage.GetEmailByMessageID(account.info.ID, f.MessageID.String)
+		if sentCopy != nil {
+			add(sentCopy.ThreadID.String)
+		}
+		add(storage.GenerateThreadID(f.MessageID.String, "", "", ""))
+	}
+	if f.ReplyToEmailID.Valid {
+		if original, err := storage.GetEmailByID(f.ReplyToEmailID.Int64); err == nil {
+			add(original.ThreadID.String)
+		}
+	}
+
+	var sentAt = f.SentAt.Time
+	var newest, newestInbound time.Time
+	for _, id := range threadIDs {
+		var thread, err = account.threads.GetThreadByID(ctx, id)
+		if err != nil {
+			continue // thread not synced
+		}
+		for _, msg := range thread.Messages {
+			if msg.Date.After(newest) && msg.MessageID != "" {
+				newest = msg.Date
+				result.lastMsgID = msg.MessageID
+			}
+			if strings.EqualFold(msg.FromEmail, account.info.Email) {
+				continue
+			}
+			if msg.Date.After(sentAt) {
+				result.replied = true
+				return result
+			}
+			if msg.Date.After(newestInbound) {
+				newestInbound = msg.Date
+				result.emailID = msg.ID
+			}
+		}
+	}
+
+	if result.emailID == 0 && sentCopy != nil {
+		result.emailID = sentCopy.ID
+	}
+	if result.emailID == 0 && f.ReplyToEmailID.Valid {
+		result.emailID = f.ReplyToEmailID.Int64
+	}
+	return result
+}
+
+// remind resurfaces the conversation in the inbox, creates a task and, when
+// enabled, a nudge draft
+func (s *FollowUpService) remind(ctx context.Context, account followUpAccount, f storage.FollowUp, thread followUpThread) {
+	s.mu.RLock()
+	var tasks = s.tasks
+	var ai = s.ai
+	var nudge = s.nudge
+	s.mu.RUnlock()
+
+	if thread.emailID != 0 {
+		s.resurfaceInInbox(ctx, account, thread.emailID)
+	}
+
+	var taskID int64
+	if tasks != nil {
+		var input = &ports.TaskInput{
+			AccountID:   account.info.ID,
+			Title:       "Follow-up: " + f.Subject,
+			Description: fmt.Sprintf("Sem resposta de %s desde %s", f.ToAddresses, f.SentAt.Local().Format("02/01/2006 15:04")),
+			Source:      ports.TaskSourceFollowUp,
+		}
+		var due = s.now()
+		input.DueDate = &due
+		if thread.emailID != 0 {
+			var emailID = thread.emailID
+			input.EmailID = &emailID
+		}
+		if task, err := tasks.CreateTask(ctx, input); err != nil {
+			log.Printf("[FollowUpService] Failed to create task for follow-up %d: %v", f.ID, err)
+		} else {
+			taskID = task.ID
+		}
+	}
+
+	var nudgeID int64
+	if nudge && ai != nil && thread.emailID != 0 {
+		var err error
+		if nudgeID, err = s.createNudge(ctx, ai, account.info, f, thread); err != nil {
+			log.Printf("[FollowUpService] Failed to generate nudge for follow-up %d: %v", f.ID, err)
+		}
+	}
+
+	if err := storage.SetFollowUpReminder(f.ID, thread.emailID, taskID, nudgeID); err != nil {
+		log.Printf("[FollowUpService] Failed to record reminder of follow-up %d: %v", f.ID, err)
+	}
+
+	if s.events != nil {
+		var port = followUpToPort(f)
+		port.Status = ports.FollowUpReminded
+		port.EmailID, port.TaskID, port.NudgeDraftID = thread.emailID, taskID, nudgeID
+		s.events.Publish(ports.FollowUpDueEvent{
+			BaseEvent: ports.NewBaseEvent(ports.EventTypeFollowUpDue),
+			FollowUp:  port,
+		})
+	}
+}
+
+// resurfaceInInbox brings the email back like a due snooze. An email outside
+// the INBOX (the sent copy, an archived reply) is also copied to the INBOX
+// on the server, so the next sync shows it there.
+func (s *FollowUpService) resurfaceInInbox(ctx context.Context, account followUpAccount, emailID int64) {
+	if err := resurface(emailID); err != nil {
+		log.Printf("[FollowUpService] Failed to resurface email %d: %v", emailID, err)
+	}
+
+	var email, err = storage.GetEmailByIDWithFolder(emailID)
+	if err != nil || strings.EqualFold(email.FolderName, "INBOX") {
+		return
+	}
+	if account.imap == nil {
+		log.Printf("[FollowUpService] No connection to copy email %d to the INBOX", emailID)
+		return
+	}
+	if err := account.imap.CopyToInbox(ctx, email.FolderName, email.UID); err != nil {
+		log.Printf("[FollowUpService] Failed to copy email %d to the INBOX: %v", emailID, err)
+	}
+}
+
+// createNudge generates the nudge draft, a reply to the newest message of
+// the conversation sent to the original recipients
+func (s *FollowUpService) createNudge(ctx context.Context, ai ports.AIService, account *ports.AccountInfo, f storage.FollowUp, thread followUpThread) (int64, error) {
+	var aiCtx, cancel = context.WithTimeout(ctx, followUpNudgeTimeout)
+	defer cancel()
+
+	var reply, err = ai.GenerateReply(aiCtx, thread.emailID, followUpNudgePrompt)
+	if err != nil {
+		return 0, err
+	}
+
+	var inReplyTo = thread.lastMsgID
+	if inReplyTo == "" {
+		inReplyTo = f.MessageID.String
+	}
+	return storage.CreateDraft(&storage.Draft{
+		AccountID:        account.ID,
+		ToAddresses:      f.ToAddresses,
+		Subject:          reply.Subject,
+		BodyText:         toNullString(reply.BodyText),
+		InReplyTo:        toNullString(inReplyTo),
+		ReferenceIDs:     toNullString(inReplyTo),
+		ReplyToEmailID:   sql.NullInt64{Int64: thread.emailID, Valid: true},
+		Status:           storage.DraftStatusDraft,
+		GenerationSource: "ai",
+		AIPrompt:         toNullString(followUpNudgePrompt),
+	})
+}
+
+// Start runs Process every followUpPollInterval and when a reminder is due,
+// until Stop
+func (s *FollowUpService) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return // already running
+	}
+
+	var workerCtx, cancel = context.WithCancel(ctx)
+	var done = make(chan struct{})
+	s.cancel = cancel
+	s.done = done
+
+	go s.run(workerCtx, done)
+}
+
+// Stop stops the worker and waits for it to exit
+func (s *FollowUpService) Stop() {
+	s.mu.Lock()
+	var cancel = s.cancel
+	var done = s.done
+	s.cancel = nil
+	s.done = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Wake makes the worker check the follow-ups now
+func (s *FollowUpService) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the worker loop of Start
+func (s *FollowUpService) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		if _, err := s.Process(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[FollowUpService] Failed to process follow-ups: %v", err)
+		}
+
+		var timer = time.NewTimer(s.nextRound())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// nextRound returns how long the worker waits before processing again:
+// until the next reminder of any account, at most followUpPollInterval
+func (s *FollowUpService) nextRound() time.Duration {
+	var wait = followUpPollInterval
+	for _, account := range s.processedAccounts() {
+		var next, err = storage.NextFollowUp(account.info.ID)
+		if err != nil || next.IsZero() {
+			continue
+		}
+		if until := next.Sub(s.now()); until < wait {
+			wait = max(until, 0)
+		}
+	}
+	return wait
+}
+
+// followUpToPort converts a storage follow-up to a ports.FollowUp
+func followUpToPort(f storage.FollowUp) ports.FollowUp {
+	var result = ports.FollowUp{
+		ID:           f.ID,
+		AccountID:    f.AccountID,
+		DraftID:      f.DraftID.Int64,
+		To:           f.ToAddresses,
+		Subject:      f.Subject,
+		After:        time.Duration(f.WaitSeconds) * time.Second,
+		MessageID:    f.MessageID.String,
+		Status:       ports.FollowUpStatus(f.Status),
+		EmailID:      f.EmailID.Int64,
+		TaskID:       f.TaskID.Int64,
+		NudgeDraftID: f.NudgeDraftID.Int64,
+	}
+	if !f.SentAt.IsZero() {
+		var t = f.SentAt.Time
+		result.SentAt = &t
+	}
+	if !f.RemindAt.IsZero() {
+		var t = f.RemindAt.Time
+		result.RemindAt = &t
+	}
+	return result
+}
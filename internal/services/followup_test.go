@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+	"github.com/opik/miau/internal/testutil/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// noThreads is a ThreadService where no thread has been synced
+type noThreads struct {
+	ports.ThreadService
+}
+
+func (noThreads) GetThreadByID(ctx context.Context, threadID string) (*ports.Thread, error) {
+	return nil, errors.New("thread not found")
+}
+
+func TestFollowUpService_Process_ResurfacesSentCopyOfEveryAccount(t *testing.T) {
+	// Arrange: the follow-up belongs to an account that isn't the current one
+	require.NoError(t, storage.Init(filepath.Join(t.TempDir(), "test.db")))
+	t.Cleanup(func() { storage.Close() })
+
+	var now = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	var current, _ = storage.GetOrCreateAccount("me@example.org", "Me")
+	var other, _ = storage.GetOrCreateAccount("other@example.org", "Other")
+	var sent, _ = storage.GetOrCreateFolder(other.ID, "Sent")
+	var emailID, _, err = storage.UpsertEmail(&storage.Email{
+		AccountID: other.ID,
+		FolderID:  sent.ID,
+		UID:       7,
+		MessageID: sql.NullString{String: "<proposal@example.org>", Valid: true},
+		Subject:   "Proposal",
+		FromEmail: "other@example.org",
+		Date:      storage.SQLiteTime{Time: now.Add(-4 * 24 * time.Hour)},
+		IsRead:    true,
+	})
+	require.NoError(t, err)
+	var followUpID, _ = storage.CreateFollowUp(&storage.FollowUp{
+		AccountID:   other.ID,
+		WaitSeconds: int64((72 * time.Hour).Seconds()),
+		ToAddresses: "client@example.com",
+		Subject:     "Proposal",
+		MessageID:   sql.NullString{String: "<proposal@example.org>", Valid: true},
+		SentAt:      storage.SQLiteTime{Time: now.Add(-4 * 24 * time.Hour)},
+		Status:      storage.FollowUpPending,
+	})
+
+	var currentIMAP = new(mocks.IMAPPort)
+	var otherIMAP = new(mocks.IMAPPort)
+	otherIMAP.On("CopyToInbox", mock.Anything, "Sent", uint32(7)).Return(nil)
+
+	var svc = NewFollowUpService(noThreads{}, nil, nil)
+	svc.now = func() time.Time { return now }
+	svc.SetAccount(&ports.AccountInfo{ID: current.ID, Email: current.Email})
+	svc.AddAccount(&ports.AccountInfo{ID: current.ID, Email: current.Email}, noThreads{}, currentIMAP)
+	svc.AddAccount(&ports.AccountInfo{ID: other.ID, Email: other.Email}, noThreads{}, otherIMAP)
+
+	// Act
+	var reminded, processErr = svc.Process(context.Background())
+
+	// Assert: unread like a due snooze, and copied to the INBOX on the server
+	require.NoError(t, processErr)
+	assert.Equal(t, 1, reminded)
+	otherIMAP.AssertExpectations(t)
+	currentIMAP.AssertNotCalled(t, "CopyToInbox", mock.Anything, mock.Anything, mock.Anything)
+
+	var email, _ = storage.GetEmailByID(emailID)
+	assert.False(t, email.IsRead)
+	var followUp, _ = storage.GetFollowUp(followUpID)
+	assert.Equal(t, storage.FollowUpReminded, followUp.Status)
+	assert.Equal(t, emailID, followUp.EmailID.Int64)
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/opik/miau/internal/gmail"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+)
+
+// Google Calendar sync: like the CalDAV sync, every calendarSyncInterval
+// (and on Wake) the primary calendar of each Gmail account is synced both
+// ways. Deletions go first, then the changes since the last sync token are
+// pulled (everything again when Google expires the token), then local
+// changes are pushed. An event changed on Google while it had local changes
+// becomes a conflict (ResolveSyncConflict).
+const (
+	googleSyncCalendar = "primary"
+	googleSyncPast     = 30 * 24 * time.Hour // how far back a full sync lists
+)
+
+// SetGoogleCalendar sets the Google Calendar client of an account for the
+// two-way sync (nil turns it off)
+func (s *CalendarService) SetGoogleCalendar(accountID int64, client *gmail.CalendarClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if client == nil {
+		delete(s.google, accountID)
+		return
+	}
+	s.google[accountID] = client
+}
+
+// googleClient returns the Google Calendar client of an account (nil if none)
+func (s *CalendarService) googleClient(accountID int64) *gmail.CalendarClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.google[accountID]
+}
+
+// googleSyncable reports whether an event is written to Google Calendar:
+// those that came from it, and the events created in miau that don't go to
+// a CalDAV server, follow-ups and task deadlines included (email invites
+// are already in the organizer's calendar)
+func (s *CalendarService) googleSyncable(e *storage.CalendarEvent) bool {
+	if e.CalDAVHref.Valid || s.googleClient(e.AccountID) == nil {
+		return false
+	}
+	if e.GoogleEventID.String != "" {
+		return true
+	}
+	if s.caldavSyncable(e) {
+		return false
+	}
+	return e.Source == storage.CalendarEventSourceManual || e.Source == storage.CalendarEventSourceTaskSync
+}
+
+// syncable reports whether an event is written to a remote calendar
+func (s *CalendarService) syncable(e *storage.CalendarEvent) bool {
+	return s.caldavSyncable(e) || s.googleSyncable(e)
+}
+
+// googleCalendarOf returns the Google calendar of an event
+func googleCalendarOf(e *storage.CalendarEvent) string {
+	if e.GoogleCalendarID.String != "" {
+		return e.GoogleCalendarID.String
+	}
+	return googleSyncCalendar
+}
+
+// SyncGoogleCalendar syncs the account's primary Google calendar, both ways
+func (s *CalendarService) SyncGoogleCalendar(ctx context.Context, accountID int64) (*ports.CalendarSyncResult, error) {
+	var client = s.googleClient(accountID)
+	if client == nil {
+		return nil, fmt.Errorf("Google Calendar not configured for account %d", accountID)
+	}
+	s.syncing.Lock()
+	defer s.syncing.Unlock()
+
+	var state, err = storage.GetCalendarSyncState(accountID, storage.CalendarSyncGoogle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync state: %w", err)
+	}
+	if state == nil {
+		state = &storage.CalendarSyncState{AccountID: accountID, Source: storage.CalendarSyncGoogle, CollectionURL: googleSyncCalendar}
+	}
+
+	var result = &ports.CalendarSyncResult{}
+	if err := s.deleteGoogle(ctx, client, accountID, result); err != nil {
+		return nil, err
+	}
+	if err := s.pullGoogle(ctx, client, state, result); err != nil {
+		return nil, err
+	}
+
+	var events, errPush = storage.GetGoogleEventsToPush(accountID)
+	if errPush != nil {
+		return nil, fmt.Errorf("failed to get local changes: %w", errPush)
+	}
+	for i := range events {
+		var e = &events[i]
+		if !s.googleSyncable(e) {
+			continue
+		}
+		var conflict, err = s.pushGoogle(ctx, client, e)
+		switch {
+		case err != nil:
+			log.Printf("[CalendarService] Failed to push event %d to Google Calendar: %v", e.ID, err)
+		case conflict:
+			result.Conflicts++
+		default:
+			result.Pushed++
+		}
+	}
+
+	// Our own writes come back with the next changes, and are skipped by
+	// their update time (see pullGoogleEvent)
+	state.LastSyncAt = sql.NullTime{Time: s.now(), Valid: true}
+	if err := storage.SaveCalendarSyncState(state); err != nil {
+		return nil, fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return result, nil
+}
+
+// deleteGoogle deletes on Google the events deleted locally
+func (s *CalendarService) deleteGoogle(ctx context.Context, client *gmail.CalendarClient, accountID int64, result *ports.CalendarSyncResult) error {
+	var deletions, err = storage.GetCalendarDeletions(accountID, storage.CalendarSyncGoogle)
+	if err != nil {
+		return fmt.Errorf("failed to get deletions: %w", err)
+	}
+	for _, d := range deletions {
+		var err = client.DeleteEvent(ctx, d.Collection, d.RemoteID)
+		switch {
+		case err == nil:
+			result.Deleted++
+		case gmail.IsEventGone(err):
+		default:
+			return err
+		}
+		storage.RemoveCalendarDeletion(d.ID)
+	}
+	return nil
+}
+
+// pullGoogle applies the changes of the calendar since the sync token. A
+// full sync (first one, or the token expired) also deletes the local
+// events Google no longer lists.
+func (s *CalendarService) pullGoogle(ctx context.Context, client *gmail.CalendarClient, state *storage.CalendarSyncState, result *ports.CalendarSyncResult) error {
+	var timeMin = s.now().Add(-googleSyncPast)
+	var full = state.SyncToken == ""
+	var changes, err = client.ListChanges(ctx, state.CollectionURL, state.SyncToken, timeMin)
+	if errors.Is(err, gmail.ErrSyncTokenExpired) {
+		full = true
+		changes, err = client.ListChanges(ctx, state.CollectionURL, "", timeMin)
+	}
+	if err != nil {
+		return err
+	}
+
+	var events, errLocal = storage.GetGoogleSyncedEvents(state.AccountID, state.CollectionURL)
+	if errLocal != nil {
+		return fmt.Errorf("failed to get events: %w", errLocal)
+	}
+	var local = make(map[string]*storage.CalendarEvent, len(events))
+	for i := range events {
+		local[events[i].GoogleEventID.String] = &events[i]
+	}
+
+	var listed = make(map[string]bool, len(changes.Events))
+	for i := range changes.Events {
+		var ge = &changes.Events[i]
+		listed[ge.ID] = true
+		s.pullGoogleEvent(state, local[ge.ID], ge, result)
+	}
+
+	if full {
+		for id, e := range local {
+			var end = e.StartTime.Time
+			if e.EndTime.Valid {
+				end = e.EndTime.Time
+			}
+			if !listed[id] && end.After(timeMin) {
+				s.deletedRemotely(e, result)
+			}
+		}
+	}
+
+	state.SyncToken = changes.NextSyncToken
+	return nil
+}
+
+// pullGoogleEvent applies a changed Google event to its local event (nil
+// for a new one)
+func (s *CalendarService) pullGoogleEvent(state *storage.CalendarSyncState, e *storage.CalendarEvent, ge *gmail.CalendarEventInfo, result *ports.CalendarSyncResult) {
+	if ge.Status == "cancelled" {
+		if e != nil {
+			s.deletedRemotely(e, result)
+		}
+		return
+	}
+	if e != nil && e.LastSyncedAt.Valid && !ge.Updated.After(e.LastSyncedAt.Time) {
+		return // our own write, or already pulled
+	}
+
+	if e != nil && (e.SyncStatus == storage.CalendarSyncStatusPendingSync || e.SyncStatus == storage.CalendarSyncStatusConflict) {
+		if e.ConflictETag.String == ge.ETag {
+			return // conflict already known
+		}
+		var data, _ = json.Marshal(ge)
+		if err := s.markConflict(e, ge.ETag, data); err == nil {
+			result.Conflicts++
+		}
+		return
+	}
+
+	var err error
+	if e == nil {
+		var eventType = storage.CalendarEventTypeMeeting
+		if ge.AllDay {
+			eventType = storage.CalendarEventTypeCustom
+		}
+		e = &storage.CalendarEvent{
+			AccountID:        state.AccountID,
+			EventType:        eventType,
+			Color:            toNullString(getColorFromGoogleColorID(ge.ColorID)),
+			Source:           storage.CalendarEventSourceManual,
+			GoogleEventID:    toNullString(ge.ID),
+			GoogleCalendarID: toNullString(state.CollectionURL),
+		}
+		s.applyGoogle(e, ge)
+		err = storage.CreateCalendarEvent(e)
+	} else {
+		s.applyGoogle(e, ge)
+		err = storage.UpdateSyncedCalendarEvent(e)
+	}
+	if err != nil {
+		log.Printf("[CalendarService] Failed to save Google event %s: %v", ge.ID, err)
+		return
+	}
+	result.Pulled++
+}
+
+// applyGoogle sets an event to the Google version. LastSyncedAt is the
+// update time of that version, so the sync can tell newer changes apart.
+func (s *CalendarService) applyGoogle(e *storage.CalendarEvent, ge *gmail.CalendarEventInfo) {
+	e.Title = ge.Summary
+	e.Description = toNullString(ge.Description)
+	e.Location = toNullString(ge.Location)
+	e.StartTime = storage.SQLiteTime{Time: ge.StartTime.UTC()}
+	e.EndTime = sql.NullTime{Time: ge.EndTime.UTC(), Valid: !ge.EndTime.IsZero()}
+	e.AllDay = ge.AllDay
+	e.GoogleEventID = toNullString(ge.ID)
+	e.ConflictETag = sql.NullString{}
+	e.ConflictData = sql.NullString{}
+	e.SyncStatus = storage.CalendarSyncStatusSynced
+	e.LastSyncedAt = sql.NullTime{Time: ge.Updated, Valid: true}
+	if ge.Updated.IsZero() {
+		e.LastSyncedAt.Time = s.now()
+	}
+}
+
+// pushGoogle writes a local change to Google Calendar: new events are
+// inserted in their calendar, the others patched (what miau doesn't edit
+// is kept). conflict is set when the event was deleted on Google.
+func (s *CalendarService) pushGoogle(ctx context.Context, client *gmail.CalendarClient, e *storage.CalendarEvent) (conflict bool, err error) {
+	var event = &gmail.CalendarEventInfo{
+		Summary:     e.Title,
+		Description: e.Description.String,
+		Location:    e.Location.String,
+		StartTime:   e.StartTime.Time,
+		AllDay:      e.AllDay,
+	}
+	if e.EndTime.Valid {
+		event.EndTime = e.EndTime.Time
+	}
+
+	var saved *gmail.CalendarEventInfo
+	if e.GoogleEventID.String == "" {
+		saved, err = client.InsertEvent(ctx, googleCalendarOf(e), event)
+	} else {
+		saved, err = client.PatchEvent(ctx, googleCalendarOf(e), e.GoogleEventID.String, event)
+		if gmail.IsEventGone(err) {
+			return true, s.markConflict(e, "", nil)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	e.GoogleEventID = toNullString(saved.ID)
+	e.GoogleCalendarID = toNullString(googleCalendarOf(e))
+	e.ConflictETag = sql.NullString{}
+	e.ConflictData = sql.NullString{}
+	e.SyncStatus = storage.CalendarSyncStatusSynced
+	e.LastSyncedAt = sql.NullTime{Time: saved.Updated, Valid: true}
+	if saved.Updated.IsZero() {
+		e.LastSyncedAt.Time = s.now()
+	}
+	return false, storage.UpdateSyncedCalendarEvent(e)
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/caldav"
+	"github.com/opik/miau/internal/gmail"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/calendar/v3"
+)
+
+// fakeGoogleCalendar is a minimal in-process Calendar API for the events
+// of one calendar: list (with sync tokens), insert, patch and delete
+type fakeGoogleCalendar struct {
+	mu      sync.Mutex
+	seq     int
+	minSeq  int // sync tokens below this return 410 (expired)
+	events  map[string]*calendar.Event
+	changed map[string]int // seq of the last change of each event
+	writes  int
+}
+
+func newFakeGoogleCalendar() *fakeGoogleCalendar {
+	return &fakeGoogleCalendar{events: map[string]*calendar.Event{}, changed: map[string]int{}}
+}
+
+// touch records a change to an event
+func (f *fakeGoogleCalendar) touch(e *calendar.Event) {
+	f.seq++
+	f.changed[e.Id] = f.seq
+	e.Updated = time.Date(2026, 10, 16, 12, 0, f.seq, 0, time.UTC).Format(time.RFC3339Nano)
+	e.Etag = fmt.Sprintf(`"%d"`, f.seq)
+}
+
+// add creates or edits an event as another client would
+func (f *fakeGoogleCalendar) add(id, summary string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var e = f.events[id]
+	if e == nil {
+		e = &calendar.Event{
+			Id:     id,
+			Status: "confirmed",
+			Start:  &calendar.EventDateTime{DateTime: "2026-10-27T09:00:00Z"},
+			End:    &calendar.EventDateTime{DateTime: "2026-10-27T10:00:00Z"},
+		}
+		f.events[id] = e
+	}
+	e.Summary = summary
+	f.touch(e)
+}
+
+// purge drops an event without a trace and expires the sync tokens, as
+// when Google answers 410
+func (f *fakeGoogleCalendar) purge(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.events, id)
+	delete(f.changed, id)
+	f.seq++
+	f.minSeq = f.seq
+}
+
+func (f *fakeGoogleCalendar) event(id string) *calendar.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.events[id]
+}
+
+func (f *fakeGoogleCalendar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var id = strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/calendar/v3/calendars/primary/events"), "/")
+	var e = f.events[id]
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		var since = -1
+		if token := r.URL.Query().Get("syncToken"); token != "" {
+			since, _ = strconv.Atoi(token)
+			if since < f.minSeq {
+				writeGoogleError(w, http.StatusGone, "Sync token is no longer valid")
+				return
+			}
+		}
+		var items = []*calendar.Event{}
+		for id, e := range f.events {
+			if f.changed[id] > since && (since >= 0 || e.Status != "cancelled") {
+				items = append(items, e)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+		json.NewEncoder(w).Encode(&calendar.Events{Items: items, NextSyncToken: strconv.Itoa(f.seq)})
+
+	case r.Method == http.MethodPost && id == "":
+		var created calendar.Event
+		json.NewDecoder(r.Body).Decode(&created)
+		created.Id = fmt.Sprintf("new%d", f.seq+1)
+		created.Status = "confirmed"
+		f.events[created.Id] = &created
+		f.touch(&created)
+		f.writes++
+		json.NewEncoder(w).Encode(&created)
+
+	case e == nil:
+		writeGoogleError(w, http.StatusNotFound, "Not Found")
+
+	case e.Status == "cancelled":
+		writeGoogleError(w, http.StatusGone, "Resource has been deleted")
+
+	case r.Method == http.MethodPatch:
+		var patch calendar.Event
+		json.NewDecoder(r.Body).Decode(&patch)
+		e.Summary, e.Description, e.Location = patch.Summary, patch.Description, patch.Location
+		e.Start, e.End = patch.Start, patch.End
+		f.touch(e)
+		f.writes++
+		json.NewEncoder(w).Encode(e)
+
+	case r.Method == http.MethodDelete:
+		e.Status = "cancelled"
+		f.touch(e)
+		f.writes++
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeGoogleError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":%q}}`, code, message)
+}
+
+// redirectTransport sends every request to the fake server
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newFakeGoogleClient(t *testing.T, fake *fakeGoogleCalendar) *gmail.CalendarClient {
+	var server = httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	var target, _ = url.Parse(server.URL)
+	var client, err = gmail.NewCalendarClient(context.Background(), &http.Client{Transport: redirectTransport{target: target}})
+	require.NoError(t, err)
+	return client
+}
+
+func TestCalendarService_SyncGoogleCalendar(t *testing.T) {
+	require.NoError(t, storage.Init(filepath.Join(t.TempDir(), "test.db")))
+	defer storage.Close()
+	var account, _ = storage.GetOrCreateAccount("me@example.org", "Me")
+	var ctx = context.Background()
+
+	var fake = newFakeGoogleCalendar()
+	fake.add("standup", "Standup")
+	var s = NewCalendarService(nil)
+	s.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+	s.SetGoogleCalendar(account.ID, newFakeGoogleClient(t, fake))
+
+	// The Google event comes down; a local follow-up goes up
+	var result, err = s.SyncGoogleCalendar(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Pulled)
+
+	var events, _ = s.GetEvents(ctx, account.ID)
+	require.Len(t, events, 1)
+	var standup = events[0]
+	assert.Equal(t, "Standup", standup.Title)
+	assert.Equal(t, "standup", standup.GoogleEventID)
+	assert.Equal(t, ports.CalendarSyncStatusSynced, standup.SyncStatus)
+
+	var followUp, errCreate = s.CreateEvent(ctx, &ports.CalendarEventInput{
+		AccountID: account.ID,
+		Title:     "Follow up: proposal",
+		EventType: ports.CalendarEventTypeEmailFollowup,
+		StartTime: time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, errCreate)
+	assert.Equal(t, ports.CalendarSyncStatusPendingSync, followUp.SyncStatus)
+
+	result, err = s.SyncGoogleCalendar(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Pushed)
+	followUp, _ = s.GetEvent(ctx, followUp.ID)
+	require.NotEmpty(t, followUp.GoogleEventID)
+	var remote = fake.event(followUp.GoogleEventID)
+	require.NotNil(t, remote)
+	assert.Equal(t, "Follow up: proposal", remote.Summary)
+	assert.Equal(t, "2026-10-20T09:00:00Z", remote.Start.DateTime)
+
+	// Our own write comes back with the changes and is skipped
+	var writes = fake.writes
+	result, err = s.SyncGoogleCalendar(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ports.CalendarSyncResult{}, *result)
+	assert.Equal(t, writes, fake.writes)
+
+	// Edited on both sides: the user keeps theirs
+	editEvent(t, s, standup.ID, "Standup (mine)")
+	fake.add("standup", "Standup (server)")
+	result, err = s.SyncGoogleCalendar(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Conflicts)
+	assert.Equal(t, 0, result.Pushed)
+
+	var conflicts, _ = s.GetSyncConflicts(ctx, account.ID)
+	require.Len(t, conflicts, 1)
+	require.NotNil(t, conflicts[0].Remote)
+	assert.Equal(t, "Standup (server)", conflicts[0].Remote.Title)
+
+	var resolved, errResolve = s.ResolveSyncConflict(ctx, standup.ID, ports.ConflictKeepMine)
+	require.NoError(t, errResolve)
+	assert.Equal(t, ports.CalendarSyncStatusSynced, resolved.SyncStatus)
+	assert.Equal(t, "Standup (mine)", fake.event("standup").Summary)
+
+	// Deleted locally
+	require.NoError(t, s.DeleteEvent(ctx, followUp.ID))
+	result, err = s.SyncGoogleCalendar(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Deleted)
+	assert.Equal(t, "cancelled", fake.event(followUp.GoogleEventID).Status)
+
+	// Deleted on Google while the sync token expired: the full sync
+	// finds it gone
+	fake.purge("standup")
+	result, err = s.SyncGoogleCalendar(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Deleted)
+	events, _ = s.GetEvents(ctx, account.ID)
+	assert.Empty(t, events)
+
+	var state, _ = storage.GetCalendarSyncState(account.ID, storage.CalendarSyncGoogle)
+	require.NotNil(t, state)
+	assert.Equal(t, strconv.Itoa(fake.seq), state.SyncToken)
+}
+
+func TestCalendarService_GoogleSyncable(t *testing.T) {
+	var s = NewCalendarService(nil)
+	var deadline = &storage.CalendarEvent{
+		AccountID: 1,
+		EventType: storage.CalendarEventTypeTaskDeadline,
+		Source:    storage.CalendarEventSourceTaskSync,
+	}
+	assert.False(t, s.googleSyncable(deadline), "no Google client")
+
+	s.SetGoogleCalendar(1, &gmail.CalendarClient{})
+	assert.True(t, s.googleSyncable(deadline))
+
+	var invite = &storage.CalendarEvent{
+		AccountID: 1,
+		EventType: storage.CalendarEventTypeMeeting,
+		Source:    storage.CalendarEventSourceEmailInvite,
+	}
+	assert.False(t, s.googleSyncable(invite))
+
+	// Custom events go to the CalDAV server when there is one
+	var custom = &storage.CalendarEvent{
+		AccountID: 1,
+		EventType: storage.CalendarEventTypeCustom,
+		Source:    storage.CalendarEventSourceManual,
+	}
+	assert.True(t, s.googleSyncable(custom))
+	var client, _ = caldav.NewClient("https://dav.example.org/", "me", "secret")
+	s.SetCalDAV(1, client)
+	assert.False(t, s.googleSyncable(custom))
+	assert.True(t, s.googleSyncable(deadline))
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opik/miau/internal/ical"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+)
+
+// InviteService implements ports.InviteService
+type InviteService struct {
+	mu      sync.RWMutex
+	send    ports.SendService
+	account *ports.AccountInfo
+	now     func() time.Time
+}
+
+// NewInviteService creates a new InviteService. Replies to invites are
+// sent with send.
+func NewInviteService(send ports.SendService) *InviteService {
+	return &InviteService{
+		send: send,
+		now:  time.Now,
+	}
+}
+
+// SetAccount sets the current account
+func (s *InviteService) SetAccount(account *ports.AccountInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account = account
+}
+
+// Import reads the iCalendar part of an email and upserts its events.
+// Requests, publications and cancellations are saved; replies from other
+// attendees and VTODOs are only returned.
+func (s *InviteService) Import(ctx context.Context, emailID int64, data []byte) (*ports.Invite, error) {
+	var cal, err = ical.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var main = cal.Main()
+	if main == nil {
+		return nil, fmt.Errorf("invite has no event")
+	}
+
+	var email, errEmail = storage.GetEmailByID(emailID)
+	if errEmail != nil {
+		return nil, fmt.Errorf("email not found: %w", errEmail)
+	}
+	var account = s.accountFor(email.AccountID)
+	var invite = s.toInvite(emailID, cal, main, account)
+
+	switch cal.Method {
+	case ical.MethodRequest, ical.MethodPublish, ical.MethodCancel, "":
+	default:
+		invite.CanRespond = false
+		return invite, nil
+	}
+
+	for i := range cal.Events {
+		var e = &cal.Events[i]
+		if e.Kind != ical.KindEvent {
+			continue
+		}
+		var event, outdated, err = s.save(email.AccountID, emailID, e, account, data)
+		if err != nil {
+			return nil, err
+		}
+		if e != main {
+			continue
+		}
+		if outdated {
+			invite.Outdated = true
+			invite.CanRespond = false
+			continue
+		}
+		invite.EventID = event.ID
+		if event.RSVPStatus.Valid {
+			invite.Response = ports.InviteResponse(event.RSVPStatus.String)
+		}
+	}
+	return invite, nil
+}
+
+// save upserts an event of an invite. Updates older than the saved event
+// (lower SEQUENCE) are skipped and reported as outdated.
+func (s *InviteService) save(accountID, emailID int64, e *ical.Event, account *ports.AccountInfo, data []byte) (*storage.CalendarEvent, bool, error) {
+	var existing, err = storage.GetCalendarEventByICalUID(accountID, e.UID, e.RecurrenceID)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil && e.Sequence < existing.ICalSequence {
+		return existing, true, nil
+	}
+
+	// The answer given to this version of the event is kept; a new version
+	// takes the status the organizer has for us
+	var rsvp sql.NullString
+	if existing != nil && existing.ICalSequence == e.Sequence && existing.RSVPStatus.Valid {
+		rsvp = existing.RSVPStatus
+	} else if account != nil {
+		if me := e.Attendee(account.Email); me != nil {
+			rsvp = sql.NullString{String: string(me.PartStat), Valid: true}
+		}
+	}
+
+	var event = existing
+	if event == nil {
+		event = &storage.CalendarEvent{
+			AccountID:        accountID,
+			EventType:        storage.CalendarEventTypeMeeting,
+			Color:            sql.NullString{String: ports.GetDefaultColor(ports.CalendarEventTypeMeeting), Valid: true},
+			Source:           storage.CalendarEventSourceEmailInvite,
+			SyncStatus:       storage.CalendarSyncStatusLocal,
+			ICalUID:          sql.NullString{String: e.UID, Valid: true},
+			ICalRecurrenceID: e.RecurrenceID,
+		}
+	}
+	event.Title = eventTitle(e)
+	event.Description = sql.NullString{String: e.Description, Valid: e.Description != ""}
+	event.Location = sql.NullString{String: e.Location, Valid: e.Location != ""}
+	event.StartTime = storage.SQLiteTime{Time: e.Start.UTC()}
+	event.EndTime = sql.NullTime{Time: e.End.UTC(), Valid: !e.End.IsZero()}
+	event.AllDay = e.AllDay
+	event.EmailID = sql.NullInt64{Int64: emailID, Valid: true}
+	event.IsCompleted = e.Cancelled()
+	event.ICalSequence = e.Sequence
+	event.ICalData = sql.NullString{String: string(data), Valid: true}
+	event.RSVPStatus = rsvp
+
+	if existing == nil {
+		err = storage.CreateCalendarEvent(event)
+	} else {
+		err = storage.UpdateInviteEvent(event)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save event %s: %w", e.UID, err)
+	}
+	return event, false, nil
+}
+
+// GetInvite returns the invite imported from an email (nil if none)
+func (s *InviteService) GetInvite(ctx context.Context, emailID int64) (*ports.Invite, error) {
+	var event, cal, e, err = s.load(emailID)
+	if err != nil || event == nil {
+		return nil, err
+	}
+
+	var invite = s.toInvite(emailID, cal, e, s.accountFor(event.AccountID))
+	invite.EventID = event.ID
+	if event.RSVPStatus.Valid {
+		invite.Response = ports.InviteResponse(event.RSVPStatus.String)
+	}
+	return invite, nil
+}
+
+// load returns the event imported from an email with its parsed calendar
+// (nil if none)
+func (s *InviteService) load(emailID int64) (*storage.CalendarEvent, *ical.Calendar, *ical.Event, error) {
+	var event, err = storage.GetInviteEventByEmail(emailID)
+	if err != nil || event == nil {
+		return nil, nil, nil, err
+	}
+
+	var cal, errParse = ical.Parse([]byte(event.ICalData.String))
+	if errParse != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse saved invite: %w", errParse)
+	}
+	var e = cal.Find(event.ICalUID.String, event.ICalRecurrenceID)
+	if e == nil {
+		return nil, nil, nil, fmt.Errorf("event %s not found in saved invite", event.ICalUID.String)
+	}
+	return event, cal, e, nil
+}
+
+// Respond sends an iTIP REPLY with the user's answer to the organizer and
+// records it on the event
+func (s *InviteService) Respond(ctx context.Context, emailID int64, response ports.InviteResponse) (*ports.Invite, error) {
+	if !response.Valid() {
+		return nil, fmt.Errorf("invalid response %q", response)
+	}
+
+	var event, cal, e, err = s.load(emailID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, fmt.Errorf("email %d has no invite", emailID)
+	}
+	if e.Organizer.Email == "" {
+		return nil, fmt.Errorf("invite has no organizer")
+	}
+	if e.Cancelled() {
+		return nil, fmt.Errorf("event was cancelled")
+	}
+
+	var account = s.accountFor(event.AccountID)
+	if account == nil {
+		return nil, fmt.Errorf("no account set")
+	}
+
+	// Invites sent to an alias or a list don't name us: reply as the account
+	var from = ical.Attendee{Email: account.Email, Name: account.Name}
+	if me := e.Attendee(account.Email); me != nil {
+		from = *me
+	}
+	from.PartStat = ical.PartStat(response)
+
+	var name = from.Name
+	if name == "" {
+		name = from.Email
+	}
+	var verb = strings.ToLower(string(response))
+	var req = &ports.SendRequest{
+		To:             []string{e.Organizer.Email},
+		Subject:        responseSubject(response) + ": " + eventTitle(e),
+		BodyText:       fmt.Sprintf("%s has %s this invitation.\n", name, verb),
+		AccountID:      event.AccountID,
+		ReplyToEmailID: &emailID,
+		Calendar:       cal.Reply(e, from, s.now()),
+		CalendarMethod: string(ical.MethodReply),
+	}
+	if _, err := s.send.Send(ctx, req); err != nil {
+		return nil, fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	if err := storage.SetCalendarEventRSVP(event.ID, string(response)); err != nil {
+		return nil, err
+	}
+	return s.GetInvite(ctx, emailID)
+}
+
+// accountFor returns the account an invite was received in (nil if unknown)
+func (s *InviteService) accountFor(accountID int64) *ports.AccountInfo {
+	s.mu.RLock()
+	var account = s.account
+	s.mu.RUnlock()
+
+	if account != nil && account.ID == accountID {
+		return account
+	}
+	var stored, err = storage.GetAccountByID(accountID)
+	if err != nil {
+		return account
+	}
+	return &ports.AccountInfo{ID: stored.ID, Email: stored.Email, Name: stored.Name, CreatedAt: stored.CreatedAt.Time}
+}
+
+// toInvite converts an event of a calendar, as seen by account
+func (s *InviteService) toInvite(emailID int64, cal *ical.Calendar, e *ical.Event, account *ports.AccountInfo) *ports.Invite {
+	var invite = &ports.Invite{
+		EmailID:     emailID,
+		Method:      string(cal.Method),
+		Kind:        string(e.Kind),
+		UID:         e.UID,
+		Sequence:    e.Sequence,
+		Title:       eventTitle(e),
+		Description: e.Description,
+		Location:    e.Location,
+		Start:       e.Start,
+		AllDay:      e.AllDay,
+		TimeZone:    e.TimeZone,
+		RRule:       e.RRule,
+		Organizer:   toInviteAttendee(e.Organizer),
+		Attendees:   []ports.InviteAttendee{},
+		Cancelled:   e.Cancelled(),
+		Response:    ports.InviteNeedsAction,
+	}
+	if e.Kind == ical.KindTodo {
+		invite.Start = e.Due
+	}
+	if !e.End.IsZero() {
+		var end = e.End
+		invite.End = &end
+	}
+	for _, a := range e.Attendees {
+		invite.Attendees = append(invite.Attendees, toInviteAttendee(a))
+	}
+
+	var organizer = account != nil && strings.EqualFold(e.Organizer.Email, account.Email)
+	if account != nil {
+		if me := e.Attendee(account.Email); me != nil {
+			invite.Response = ports.InviteResponse(me.PartStat)
+		}
+	}
+	invite.CanRespond = cal.Method == ical.MethodRequest && e.Kind == ical.KindEvent &&
+		e.Organizer.Email != "" && !organizer && !invite.Cancelled
+	return invite
+}
+
+func toInviteAttendee(a ical.Attendee) ports.InviteAttendee {
+	return ports.InviteAttendee{
+		Email:    a.Email,
+		Name:     a.Name,
+		Response: ports.InviteResponse(a.PartStat),
+		Optional: a.Role == "OPT-PARTICIPANT",
+	}
+}
+
+// eventTitle returns the summary of an event, or a placeholder
+func eventTitle(e *ical.Event) string {
+	if e.Summary == "" {
+		return "(no title)"
+	}
+	return e.Summary
+}
+
+// responseSubject returns the subject prefix of a reply, as calendar
+// clients write it
+func responseSubject(response ports.InviteResponse) string {
+	switch response {
+	case ports.InviteAccepted:
+		return "Accepted"
+	case ports.InviteTentative:
+		return "Tentative"
+	default:
+		return "Declined"
+	}
+}
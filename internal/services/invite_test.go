@@ -0,0 +1,70 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/opik/miau/internal/ical"
+	"github.com/opik/miau/internal/ports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testInvite(method string) *ical.Calendar {
+	var data = strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"METHOD:" + method,
+		"BEGIN:VEVENT",
+		"UID:sync@example.com",
+		"SEQUENCE:1",
+		"SUMMARY:Weekly sync",
+		"DTSTART:20261027T090000Z",
+		"DTEND:20261027T100000Z",
+		"ORGANIZER;CN=Jane:mailto:jane@example.com",
+		"ATTENDEE;PARTSTAT=TENTATIVE;RSVP=TRUE:mailto:me@example.org",
+		"ATTENDEE;ROLE=OPT-PARTICIPANT;CN=Bob:mailto:bob@example.com",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	var cal, err = ical.Parse([]byte(data))
+	if err != nil {
+		panic(err)
+	}
+	return cal
+}
+
+func TestInviteService_ToInvite(t *testing.T) {
+	var s = NewInviteService(nil)
+	var me = &ports.AccountInfo{ID: 1, Email: "ME@example.org"}
+
+	var cal = testInvite("REQUEST")
+	var invite = s.toInvite(42, cal, cal.Main(), me)
+	assert.Equal(t, int64(42), invite.EmailID)
+	assert.Equal(t, "Weekly sync", invite.Title)
+	assert.Equal(t, ports.InviteTentative, invite.Response)
+	assert.True(t, invite.CanRespond)
+	require.NotNil(t, invite.End)
+	assert.Equal(t, "Jane", invite.Organizer.Name)
+	require.Len(t, invite.Attendees, 2)
+	assert.Equal(t, ports.InviteNeedsAction, invite.Attendees[1].Response)
+	assert.True(t, invite.Attendees[1].Optional)
+
+	// Only requests to someone else's meeting can be answered
+	var organizer = &ports.AccountInfo{ID: 2, Email: "jane@example.com"}
+	assert.False(t, s.toInvite(42, cal, cal.Main(), organizer).CanRespond)
+
+	cal = testInvite("CANCEL")
+	invite = s.toInvite(42, cal, cal.Main(), me)
+	assert.True(t, invite.Cancelled)
+	assert.False(t, invite.CanRespond)
+
+	cal = testInvite("PUBLISH")
+	assert.False(t, s.toInvite(42, cal, cal.Main(), me).CanRespond)
+}
+
+func TestResponseSubject(t *testing.T) {
+	assert.Equal(t, "Accepted", responseSubject(ports.InviteAccepted))
+	assert.Equal(t, "Tentative", responseSubject(ports.InviteTentative))
+	assert.Equal(t, "Declined", responseSubject(ports.InviteDeclined))
+	assert.False(t, ports.InviteNeedsAction.Valid())
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// labelSyncMessages is how many of the newest messages SyncLabels refreshes
+const labelSyncMessages = 1000
+
+// hiddenSystemLabels are Gmail system labels that are really flags or
+// folders already shown elsewhere, so they aren't listed as labels
+var hiddenSystemLabels = map[string]bool{
+	"UNREAD": true,
+	"DRAFT":  true,
+	"SPAM":   true,
+	"TRASH":  true,
+	"CHAT":   true,
+}
+
+// visibleLabel reports whether a Gmail label is shown as a label
+func visibleLabel(label ports.Label) bool {
+	return !label.System || !hiddenLabel(label.RemoteID)
+}
+
+// hiddenLabel reports whether a system label ID (also its name) is hidden
+func hiddenLabel(id string) bool {
+	return hiddenSystemLabels[id] || strings.HasPrefix(id, "CATEGORY_")
+}
+
+// visibleLabelNames drops the hidden system labels from label names
+func visibleLabelNames(names []string) []string {
+	var result = []string{}
+	for _, name := range names {
+		if !hiddenLabel(name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// SetGmailAPI sets the Gmail API client labels of an account go through
+// (nil for accounts without one: labels are then read-only)
+func (s *EmailService) SetGmailAPI(accountID int64, gmail ports.GmailAPIPort) {
+	s.accounts.setGmail(accountID, gmail)
+}
+
+// currentAccount returns the current account or an error
+func (s *EmailService) currentAccount() (*ports.AccountInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.account == nil {
+		return nil, fmt.Errorf("no account set")
+	}
+	return s.account, nil
+}
+
+// GetLabels returns the labels of the current account with message counts
+func (s *EmailService) GetLabels(ctx context.Context) ([]ports.Label, error) {
+	var account, err = s.currentAccount()
+	if err != nil {
+		return nil, err
+	}
+	return s.storage.GetLabels(ctx, account.ID)
+}
+
+// GetEmailsByLabel returns the messages of the current account carrying a
+// label, each listed once even when it is stored in several folders
+func (s *EmailService) GetEmailsByLabel(ctx context.Context, label string, limit int) ([]ports.EmailMetadata, error) {
+	var account, err = s.currentAccount()
+	if err != nil {
+		return nil, err
+	}
+
+	var emails, err2 = s.storage.GetEmailsByLabel(ctx, account.ID, label, limit)
+	if err2 != nil {
+		return nil, err2
+	}
+	s.attachLabels(ctx, emails)
+	s.attachRisk(ctx, emails)
+	return emails, nil
+}
+
+// AddLabel adds a label to an email in Gmail and locally, creating the
+// label when it doesn't exist yet
+func (s *EmailService) AddLabel(ctx context.Context, id int64, label string) error {
+	var email, gmail, err = s.labelTarget(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var existing, err2 = s.findLabel(ctx, email.AccountID, label)
+	if err2 != nil {
+		return err2
+	}
+	if existing == nil || existing.RemoteID == "" {
+		var created, err3 = gmail.CreateLabel(ctx, label)
+		if err3 != nil {
+			return fmt.Errorf("failed to create label %s: %w", label, err3)
+		}
+		if err := s.storage.UpsertLabel(ctx, email.AccountID, created); err != nil {
+			return err
+		}
+		existing = created
+	}
+
+	if err := gmail.ModifyLabels(ctx, email.MessageID, []string{existing.RemoteID}, nil); err != nil {
+		return fmt.Errorf("failed to add label %s: %w", label, err)
+	}
+	return s.storage.AddMessageLabel(ctx, email.AccountID, email.MessageID, existing.Name)
+}
+
+// RemoveLabel removes a label from an email in Gmail and locally
+func (s *EmailService) RemoveLabel(ctx context.Context, id int64, label string) error {
+	var email, gmail, err = s.labelTarget(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var existing, err2 = s.findLabel(ctx, email.AccountID, label)
+	if err2 != nil {
+		return err2
+	}
+	if existing == nil || existing.RemoteID == "" {
+		return fmt.Errorf("unknown label: %s", label)
+	}
+
+	if err := gmail.ModifyLabels(ctx, email.MessageID, nil, []string{existing.RemoteID}); err != nil {
+		return fmt.Errorf("failed to remove label %s: %w", label, err)
+	}
+	return s.storage.RemoveMessageLabel(ctx, email.AccountID, email.MessageID, existing.Name)
+}
+
+// SyncLabels pulls the labels of the current account from Gmail and the
+// labels of its newest messages. Returns how many messages were updated.
+func (s *EmailService) SyncLabels(ctx context.Context) (int, error) {
+	var account, err = s.currentAccount()
+	if err != nil {
+		return 0, err
+	}
+
+	var gmail = s.accounts.gmailFor(account.ID)
+	if gmail == nil {
+		return 0, fmt.Errorf("labels need the Gmail API (OAuth2 account)")
+	}
+
+	var remote, err2 = gmail.ListLabels(ctx)
+	if err2 != nil {
+		return 0, fmt.Errorf("failed to list labels: %w", err2)
+	}
+
+	// Labels: upsert the visible ones, drop the ones deleted in Gmail
+	var names = make(map[string]string) // Gmail label ID -> name
+	for i := range remote {
+		if !visibleLabel(remote[i]) {
+			continue
+		}
+		if err := s.storage.UpsertLabel(ctx, account.ID, &remote[i]); err != nil {
+			return 0, err
+		}
+		names[remote[i].RemoteID] = remote[i].Name
+	}
+
+	var local, err3 = s.storage.GetLabels(ctx, account.ID)
+	if err3 != nil {
+		return 0, err3
+	}
+	for _, l := range local {
+		if _, ok := names[l.RemoteID]; !ok {
+			if err := s.storage.DeleteLabel(ctx, account.ID, l.Name); err != nil {
+				log.Printf("[SyncLabels] Failed to delete label %s: %v", l.Name, err)
+			}
+		}
+	}
+
+	// Message labels, keyed by Message-ID
+	var messageLabels, err4 = gmail.ListMessageLabels(ctx, labelSyncMessages)
+	if err4 != nil {
+		return 0, fmt.Errorf("failed to fetch message labels: %w", err4)
+	}
+
+	var updated = 0
+	for messageID, ids := range messageLabels {
+		var labels []string
+		for _, id := range ids {
+			if name, ok := names[id]; ok {
+				labels = append(labels, name)
+			}
+		}
+		if err := s.storage.SetMessageLabels(ctx, account.ID, messageID, labels); err != nil {
+			log.Printf("[SyncLabels] Failed to save labels of %s: %v", messageID, err)
+			continue
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+// labelTarget returns an email and the Gmail API client of its account
+func (s *EmailService) labelTarget(ctx context.Context, id int64) (*ports.EmailContent, ports.GmailAPIPort, error) {
+	var email, err = s.storage.GetEmail(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if email.MessageID == "" {
+		return nil, nil, fmt.Errorf("email has no Message-ID")
+	}
+
+	var gmail = s.accounts.gmailFor(email.AccountID)
+	if gmail == nil {
+		return nil, nil, fmt.Errorf("labels need the Gmail API (OAuth2 account)")
+	}
+	return email, gmail, nil
+}
+
+// findLabel returns the local label with this name (case-insensitive), or nil
+func (s *EmailService) findLabel(ctx context.Context, accountID int64, name string) (*ports.Label, error) {
+	var labels, err = s.storage.GetLabels(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range labels {
+		if strings.EqualFold(labels[i].Name, name) {
+			return &labels[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// attachLabels fills in the labels of each email (best effort)
+func (s *EmailService) attachLabels(ctx context.Context, emails []ports.EmailMetadata) {
+	var byAccount = make(map[int64][]string)
+	for _, e := range emails {
+		if e.MessageID != "" {
+			byAccount[e.AccountID] = append(byAccount[e.AccountID], e.MessageID)
+		}
+	}
+
+	for accountID, messageIDs := range byAccount {
+		var labels, err = s.storage.GetLabelsForMessages(ctx, accountID, messageIDs)
+		if err != nil {
+			log.Printf("[attachLabels] Failed to load labels: %v", err)
+			continue
+		}
+		for i := range emails {
+			if emails[i].AccountID == accountID {
+				emails[i].Labels = labels[strings.Trim(emails[i].MessageID, "<>")]
+			}
+		}
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
 )
 
 // MarkReadOperation represents a mark as read/unread operation
@@ -391,6 +394,309 @@ func (o *BatchOperation) Data() (string, error) {
 	return string(bytes), err
 }
 
+// LabelOperation adds an IMAP keyword (label) to an email
+type LabelOperation struct {
+	emailUID uint32
+	label    string
+	subject  string
+	imap     ports.IMAPPort
+}
+
+func NewLabelOperation(emailUID uint32, label string, subject string, imap ports.IMAPPort) *LabelOperation {
+	return &LabelOperation{
+		emailUID: emailUID,
+		label:    label,
+		subject:  subject,
+		imap:     imap,
+	}
+}
+
+func (o *LabelOperation) Execute(ctx context.Context) error {
+	return o.imap.AddKeyword(ctx, o.emailUID, o.label)
+}
+
+func (o *LabelOperation) Undo(ctx context.Context) error {
+	return o.imap.RemoveKeyword(ctx, o.emailUID, o.label)
+}
+
+func (o *LabelOperation) Description() string {
+	return fmt.Sprintf("Adicionar label %s: '%s'", o.label, truncate(o.subject, 50))
+}
+
+func (o *LabelOperation) Type() ports.OperationType {
+	return ports.OperationTypeRule
+}
+
+func (o *LabelOperation) Data() (string, error) {
+	data := map[string]interface{}{
+		"email_uid": o.emailUID,
+		"label":     o.label,
+		"subject":   o.subject,
+	}
+	bytes, err := json.Marshal(data)
+	return string(bytes), err
+}
+
+// SnoozeOperation snoozes an email until a preset or a fixed time
+type SnoozeOperation struct {
+	emailID int64
+	preset  ports.SnoozePreset
+	until   time.Time // used when preset is empty
+	subject string
+	snooze  ports.SnoozeService
+}
+
+func NewSnoozeOperation(
+	emailID int64,
+	preset ports.SnoozePreset,
+	until time.Time,
+	subject string,
+	snooze ports.SnoozeService,
+) *SnoozeOperation {
+	return &SnoozeOperation{
+		emailID: emailID,
+		preset:  preset,
+		until:   until,
+		subject: subject,
+		snooze:  snooze,
+	}
+}
+
+func (o *SnoozeOperation) Execute(ctx context.Context) error {
+	if o.preset != "" {
+		return o.snooze.SnoozeEmailPreset(ctx, o.emailID, o.preset)
+	}
+	return o.snooze.SnoozeEmail(ctx, o.emailID, o.until)
+}
+
+func (o *SnoozeOperation) Undo(ctx context.Context) error {
+	return o.snooze.UnsnoozeEmail(ctx, o.emailID)
+}
+
+func (o *SnoozeOperation) Description() string {
+	return fmt.Sprintf("Adiar email: '%s'", truncate(o.subject, 50))
+}
+
+func (o *SnoozeOperation) Type() ports.OperationType {
+	return ports.OperationTypeRule
+}
+
+func (o *SnoozeOperation) Data() (string, error) {
+	data := map[string]interface{}{
+		"email_id": o.emailID,
+		"preset":   o.preset,
+		"until":    o.until,
+		"subject":  o.subject,
+	}
+	bytes, err := json.Marshal(data)
+	return string(bytes), err
+}
+
+// CreateTaskOperation creates a task linked to an email; undo deletes it
+type CreateTaskOperation struct {
+	input  ports.TaskInput
+	taskID int64
+	tasks  ports.TaskService
+}
+
+func NewCreateTaskOperation(input ports.TaskInput, tasks ports.TaskService) *CreateTaskOperation {
+	return &CreateTaskOperation{
+		input: input,
+		tasks: tasks,
+	}
+}
+
+func (o *CreateTaskOperation) Execute(ctx context.Context) error {
+	var task, err = o.tasks.CreateTask(ctx, &o.input)
+	if err != nil {
+		return err
+	}
+	o.taskID = task.ID
+	return nil
+}
+
+func (o *CreateTaskOperation) Undo(ctx context.Context) error {
+	if o.taskID == 0 {
+		return nil
+	}
+	if err := o.tasks.DeleteTask(ctx, o.taskID); err != nil {
+		return err
+	}
+	o.taskID = 0
+	return nil
+}
+
+func (o *CreateTaskOperation) Description() string {
+	return fmt.Sprintf("Criar tarefa: '%s'", truncate(o.input.Title, 50))
+}
+
+func (o *CreateTaskOperation) Type() ports.OperationType {
+	return ports.OperationTypeRule
+}
+
+func (o *CreateTaskOperation) Data() (string, error) {
+	// task_id changes on redo; Data must stay stable to match the stored record
+	data := map[string]interface{}{
+		"title":    o.input.Title,
+		"email_id": o.input.EmailID,
+	}
+	bytes, err := json.Marshal(data)
+	return string(bytes), err
+}
+
+// RuleOperation is everything one filter rule did to one email (composite).
+// Forwards can't be undone; they are only listed in the description.
+type RuleOperation struct {
+	ruleID       int64
+	ruleName     string
+	emailID      int64
+	subject      string
+	operations   []ports.Operation
+	irreversible []string
+}
+
+func NewRuleOperation(ruleID int64, ruleName string, emailID int64, subject string) *RuleOperation {
+	return &RuleOperation{
+		ruleID:   ruleID,
+		ruleName: ruleName,
+		emailID:  emailID,
+		subject:  subject,
+	}
+}
+
+// Add appends an operation that has already been executed
+func (o *RuleOperation) Add(op ports.Operation) {
+	o.operations = append(o.operations, op)
+}
+
+// AddIrreversible notes an action that undo can't revert
+func (o *RuleOperation) AddIrreversible(description string) {
+	o.irreversible = append(o.irreversible, description)
+}
+
+// Empty returns true if the rule did nothing
+func (o *RuleOperation) Empty() bool {
+	return len(o.operations) == 0 && len(o.irreversible) == 0
+}
+
+// Rebind points IMAP-backed steps to another connection. Rules run on the
+// sync connection, but undo must use the main one.
+func (o *RuleOperation) Rebind(imap ports.IMAPPort) {
+	for _, op := range o.operations {
+		switch v := op.(type) {
+		case *MarkReadOperation:
+			v.imap = imap
+		case *ArchiveOperation:
+			v.imap = imap
+		case *MoveOperation:
+			v.imap = imap
+		case *LabelOperation:
+			v.imap = imap
+		}
+	}
+}
+
+func (o *RuleOperation) Execute(ctx context.Context) error {
+	for _, op := range o.operations {
+		if err := op.Execute(ctx); err != nil {
+			return fmt.Errorf("rule operation failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (o *RuleOperation) Undo(ctx context.Context) error {
+	// Undo in reverse order
+	for i := len(o.operations) - 1; i >= 0; i-- {
+		if err := o.operations[i].Undo(ctx); err != nil {
+			return fmt.Errorf("rule undo failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (o *RuleOperation) Description() string {
+	var desc = fmt.Sprintf("Regra '%s': '%s'", o.ruleName, truncate(o.subject, 40))
+	if len(o.irreversible) > 0 {
+		desc += fmt.Sprintf(" (não desfaz: %s)", strings.Join(o.irreversible, ", "))
+	}
+	return desc
+}
+
+func (o *RuleOperation) Type() ports.OperationType {
+	return ports.OperationTypeRule
+}
+
+func (o *RuleOperation) Data() (string, error) {
+	var steps = make([]string, len(o.operations))
+	for i, op := range o.operations {
+		steps[i] = op.Description()
+	}
+	data := map[string]interface{}{
+		"rule_id":      o.ruleID,
+		"rule_name":    o.ruleName,
+		"email_id":     o.emailID,
+		"subject":      o.subject,
+		"steps":        steps,
+		"irreversible": o.irreversible,
+	}
+	bytes, err := json.Marshal(data)
+	return string(bytes), err
+}
+
+// SendOperation represents an email held in the outbox for the undo-send
+// window. Undo cancels the send (the email becomes a draft again); Redo holds
+// it again for a new window.
+type SendOperation struct {
+	draftID int64
+	subject string
+	send    *SendService
+}
+
+func NewSendOperation(draftID int64, subject string, send *SendService) *SendOperation {
+	return &SendOperation{
+		draftID: draftID,
+		subject: subject,
+		send:    send,
+	}
+}
+
+func (o *SendOperation) Execute(ctx context.Context) error {
+	var _, err = o.send.hold(ctx, o.draftID, nil, false)
+	return err
+}
+
+func (o *SendOperation) Undo(ctx context.Context) error {
+	return o.send.CancelPendingSend(ctx, o.draftID)
+}
+
+// Expired reports whether the send can't be undone anymore: the email went
+// out, is going out, or was already taken out of the outbox
+func (o *SendOperation) Expired(ctx context.Context) bool {
+	var entry, err = storage.GetOutboxEntry(o.draftID)
+	if err != nil {
+		return false
+	}
+	return entry == nil || entry.Status == storage.DraftStatusSending
+}
+
+func (o *SendOperation) Description() string {
+	return fmt.Sprintf("Enviar email: '%s'", truncate(o.subject, 50))
+}
+
+func (o *SendOperation) Type() ports.OperationType {
+	return ports.OperationTypeSend
+}
+
+func (o *SendOperation) Data() (string, error) {
+	data := map[string]interface{}{
+		"draft_id": o.draftID,
+		"subject":  o.subject,
+	}
+	bytes, err := json.Marshal(data)
+	return string(bytes), err
+}
+
 // truncate truncates a string to a maximum length
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
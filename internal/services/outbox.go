@@ -0,0 +1,555 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+)
+
+// Outbox retry policy: failed sends wait 30s, 1m, 2m... up to 30 minutes,
+// and are given up (draft status failed) after outboxMaxAttempts. Failures
+// because the server is unreachable don't count as attempts.
+//
+// The outbox also holds emails during the undo-send window (see
+// SendService.SetSendDelay): they are queued with a first attempt time.
+const (
+	outboxMaxAttempts  = 8
+	outboxBaseDelay    = 30 * time.Second
+	outboxMaxDelay     = 30 * time.Minute
+	outboxPollInterval = time.Minute
+	outboxStuckAfter   = 10 * time.Minute // "sending" for longer means the app died mid-send
+)
+
+// OutboxService implements ports.OutboxService
+type OutboxService struct {
+	mu     sync.Mutex
+	send   *SendService
+	events ports.EventBus
+	online func(ctx context.Context, accountID int64) bool
+	now    func() time.Time
+
+	processing sync.Mutex // one Process at a time
+	cancel     context.CancelFunc
+	done       chan struct{}
+	wake       chan struct{}
+}
+
+// outboxExtras is what the outbox keeps besides the draft (outbox.request)
+type outboxExtras struct {
+	Attachments    []ports.Attachment `json:"attachments,omitempty"`
+	Sign           bool               `json:"sign,omitempty"`
+	Encrypt        bool               `json:"encrypt,omitempty"`
+	Calendar       []byte             `json:"calendar,omitempty"`
+	CalendarMethod string             `json:"calendarMethod,omitempty"`
+}
+
+// NewOutboxService creates a new OutboxService that sends through send
+func NewOutboxService(send *SendService, events ports.EventBus) *OutboxService {
+	return &OutboxService{
+		send:   send,
+		events: events,
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// SetConnectivityCheck sets how the worker tells whether an account's send
+// server is reachable. Without it every due email is tried.
+func (s *OutboxService) SetConnectivityCheck(online func(ctx context.Context, accountID int64) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.online = online
+}
+
+// Queue puts a request in the outbox as a new draft of the sending account
+func (s *OutboxService) Queue(ctx context.Context, req *ports.SendRequest) (*ports.OutboxItem, error) {
+	return s.queueRequest(ctx, req, time.Time{})
+}
+
+// queueRequest is Queue for an email that must not go out before sendAt
+// (zero for as soon as possible)
+func (s *OutboxService) queueRequest(ctx context.Context, req *ports.SendRequest, sendAt time.Time) (*ports.OutboxItem, error) {
+	var account = s.send.accountFor(ctx, req)
+	if account == nil {
+		return nil, fmt.Errorf("no account set")
+	}
+
+	var draft = &storage.Draft{
+		AccountID:        account.ID,
+		ToAddresses:      strings.Join(req.To, ", "),
+		CcAddresses:      toNullString(strings.Join(req.Cc, ", ")),
+		BccAddresses:     toNullString(strings.Join(req.Bcc, ", ")),
+		Subject:          req.Subject,
+		BodyHTML:         toNullString(req.BodyHTML),
+		BodyText:         toNullString(req.BodyText),
+		Classification:   toNullString(req.Classification),
+		InReplyTo:        toNullString(req.InReplyTo),
+		ReferenceIDs:     toNullString(req.ReferenceIDs),
+		Status:           storage.DraftStatusQueued,
+		GenerationSource: "manual",
+	}
+	if req.ReplyToEmailID != nil {
+		draft.ReplyToEmailID = sql.NullInt64{Int64: *req.ReplyToEmailID, Valid: true}
+	}
+
+	var id, err = storage.CreateDraft(draft)
+	if err != nil {
+		return nil, err
+	}
+	s.send.followUpDraft(ctx, id, req)
+	return s.queue(id, account.ID, req, sendAt)
+}
+
+// QueueDraft puts an existing draft in the outbox; req carries what drafts
+// don't store (attachments, signing, encryption) and may be nil
+func (s *OutboxService) QueueDraft(ctx context.Context, draftID int64, req *ports.SendRequest) (*ports.OutboxItem, error) {
+	return s.queueDraft(draftID, req, time.Time{})
+}
+
+// queueDraft is QueueDraft for an email that must not go out before sendAt
+// (zero for as soon as possible)
+func (s *OutboxService) queueDraft(draftID int64, req *ports.SendRequest, sendAt time.Time) (*ports.OutboxItem, error) {
+	var draft, err = storage.GetDraftByID(draftID)
+	if err != nil {
+		return nil, fmt.Errorf("draft not found: %w", err)
+	}
+	if req == nil {
+		req = &ports.SendRequest{}
+	}
+	return s.queue(draftID, draft.AccountID, req, sendAt)
+}
+
+// queue stores the outbox entry of a draft
+func (s *OutboxService) queue(draftID, accountID int64, req *ports.SendRequest, sendAt time.Time) (*ports.OutboxItem, error) {
+	var extras, err = json.Marshal(outboxExtras{
+		Attachments:    req.Attachments,
+		Sign:           req.Sign,
+		Encrypt:        req.Encrypt,
+		Calendar:       req.Calendar,
+		CalendarMethod: req.CalendarMethod,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.QueueDraft(draftID, accountID, string(extras), sendAt); err != nil {
+		return nil, err
+	}
+	s.publish(draftID, ports.DraftStatusQueued)
+	if !sendAt.IsZero() {
+		s.Wake() // plan the worker's next round for sendAt
+	}
+	return s.item(draftID)
+}
+
+// List returns every email in the outbox, in sending order per account
+func (s *OutboxService) List(ctx context.Context) ([]ports.OutboxItem, error) {
+	var entries, err = storage.GetOutbox()
+	if err != nil {
+		return nil, err
+	}
+
+	var items = make([]ports.OutboxItem, 0, len(entries))
+	for _, e := range entries {
+		switch e.Status {
+		case storage.DraftStatusQueued, storage.DraftStatusSending, storage.DraftStatusFailed:
+			items = append(items, outboxItem(e))
+		}
+	}
+	return items, nil
+}
+
+// Retry resets the attempts of an email, failed ones included
+func (s *OutboxService) Retry(ctx context.Context, id int64) error {
+	if _, err := s.waiting(id); err != nil {
+		return err
+	}
+	if err := storage.ResetOutboxEntry(id); err != nil {
+		return err
+	}
+	s.publish(id, ports.DraftStatusQueued)
+	s.Wake()
+	return nil
+}
+
+// Cancel takes an email out of the outbox and turns it back into a draft
+func (s *OutboxService) Cancel(ctx context.Context, id int64) error {
+	if _, err := s.waiting(id); err != nil {
+		return err
+	}
+	// The worker may claim the email between the check and here: only the
+	// atomic cancel tells whether it was stopped in time
+	var cancelled, err = storage.CancelOutboxEntry(id)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return fmt.Errorf("email %d is being sent", id)
+	}
+	s.publish(id, ports.DraftStatusDraft)
+	return nil
+}
+
+// Update edits an email that isn't being sent and queues it again
+func (s *OutboxService) Update(ctx context.Context, id int64, req *ports.SendRequest) error {
+	var updated, err = storage.UpdateQueuedDraft(&storage.Draft{
+		ID:           id,
+		ToAddresses:  strings.Join(req.To, ", "),
+		CcAddresses:  toNullString(strings.Join(req.Cc, ", ")),
+		BccAddresses: toNullString(strings.Join(req.Bcc, ", ")),
+		Subject:      req.Subject,
+		BodyHTML:     toNullString(req.BodyHTML),
+		BodyText:     toNullString(req.BodyText),
+	})
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return fmt.Errorf("email %d can't be edited: not in the outbox or being sent", id)
+	}
+	return s.Retry(ctx, id)
+}
+
+// waiting returns the outbox entry of an email that isn't being sent
+func (s *OutboxService) waiting(id int64) (*storage.OutboxEntry, error) {
+	var entry, err = storage.GetOutboxEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("email %d is not in the outbox", id)
+	}
+	if entry.Status == storage.DraftStatusSending {
+		return nil, fmt.Errorf("email %d is being sent", id)
+	}
+	return entry, nil
+}
+
+// item returns the outbox item of a draft
+func (s *OutboxService) item(id int64) (*ports.OutboxItem, error) {
+	var entry, err = storage.GetOutboxEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("email %d is not in the outbox", id)
+	}
+	var item = outboxItem(*entry)
+	return &item, nil
+}
+
+// Process sends the due emails once. Each account's emails go out in order:
+// when one can't be sent yet, the later ones of that account wait too.
+func (s *OutboxService) Process(ctx context.Context) (int, error) {
+	s.processing.Lock()
+	defer s.processing.Unlock()
+
+	s.mu.Lock()
+	var online = s.online
+	s.mu.Unlock()
+
+	if n, err := storage.RequeueStuckOutbox(s.now().Add(-outboxStuckAfter)); err == nil && n > 0 {
+		log.Printf("[OutboxService] Requeued %d email(s) stuck in sending", n)
+	}
+
+	var entries, err = storage.GetOutbox()
+	if err != nil {
+		return 0, err
+	}
+
+	var now = s.now()
+	var blocked = make(map[int64]bool) // accounts whose next email can't go yet
+	var checked = make(map[int64]bool) // accounts whose connectivity was checked
+	var sent = 0
+	for _, e := range entries {
+		if e.Status != storage.DraftStatusQueued || blocked[e.AccountID] {
+			continue
+		}
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		if !e.NextAttemptAt.IsZero() && e.NextAttemptAt.After(now) {
+			blocked[e.AccountID] = true
+			continue
+		}
+
+		// Only send from the account the email was queued on (single-account
+		// runtimes can only send from the current account)
+		if account := s.send.accountFor(ctx, &ports.SendRequest{AccountID: e.AccountID}); account == nil || account.ID != e.AccountID {
+			blocked[e.AccountID] = true
+			continue
+		}
+		if !checked[e.AccountID] {
+			checked[e.AccountID] = true
+			if online != nil && !online(ctx, e.AccountID) {
+				blocked[e.AccountID] = true
+				continue
+			}
+		}
+
+		if claimed, err := storage.ClaimOutboxEntry(e.DraftID); err != nil || !claimed {
+			continue
+		}
+		s.publish(e.DraftID, ports.DraftStatusSending)
+
+		var result, err = s.deliver(ctx, e)
+		if err != nil {
+			blocked[e.AccountID] = true
+			s.fail(e, err)
+			continue
+		}
+
+		storage.MarkDraftSent(e.DraftID)
+		storage.RemoveFromOutbox(e.DraftID)
+		s.send.followUpSent(ctx, e.DraftID, nil, result)
+		s.publishSent(e.DraftID, result)
+		sent++
+	}
+
+	if sent > 0 {
+		log.Printf("[OutboxService] Sent %d queued email(s)", sent)
+	}
+	return sent, nil
+}
+
+// deliver sends an outbox entry, bypassing the outbox
+func (s *OutboxService) deliver(ctx context.Context, e storage.OutboxEntry) (*ports.SendResult, error) {
+	var draft, err = storage.GetDraftByID(e.DraftID)
+	if err != nil {
+		return nil, err
+	}
+
+	var extras outboxExtras
+	if err := json.Unmarshal([]byte(e.Request), &extras); err != nil {
+		return nil, fmt.Errorf("invalid outbox request: %w", err)
+	}
+
+	var req = &ports.SendRequest{
+		To:             parseAddresses(draft.ToAddresses),
+		Cc:             parseAddresses(nullStringValue(draft.CcAddresses)),
+		Bcc:            parseAddresses(nullStringValue(draft.BccAddresses)),
+		Subject:        draft.Subject,
+		BodyText:       nullStringValue(draft.BodyText),
+		BodyHTML:       nullStringValue(draft.BodyHTML),
+		InReplyTo:      nullStringValue(draft.InReplyTo),
+		ReferenceIDs:   nullStringValue(draft.ReferenceIDs),
+		AccountID:      e.AccountID,
+		Classification: nullStringValue(draft.Classification),
+		Attachments:    extras.Attachments,
+		Sign:           extras.Sign,
+		Encrypt:        extras.Encrypt,
+		Calendar:       extras.Calendar,
+		CalendarMethod: extras.CalendarMethod,
+	}
+	if draft.ReplyToEmailID.Valid {
+		var id = draft.ReplyToEmailID.Int64
+		req.ReplyToEmailID = &id
+	}
+
+	return s.send.deliver(ctx, req)
+}
+
+// fail records a failed send: the email waits for the next attempt, or is
+// given up once it reaches outboxMaxAttempts
+func (s *OutboxService) fail(e storage.OutboxEntry, err error) {
+	var attempts = e.Attempts
+	var delay = outboxBaseDelay
+	if !IsConnectivityError(err) {
+		attempts++
+		delay = outboxBackoff(attempts)
+	}
+	storage.RecordOutboxFailure(e.DraftID, attempts, s.now().Add(delay), err.Error())
+
+	if attempts >= outboxMaxAttempts {
+		log.Printf("[OutboxService] Giving up on draft %d after %d attempts: %v", e.DraftID, attempts, err)
+		storage.MarkDraftFailed(e.DraftID, err.Error())
+		s.publish(e.DraftID, ports.DraftStatusFailed)
+		return
+	}
+
+	log.Printf("[OutboxService] Draft %d not sent (attempt %d), retrying in %s: %v", e.DraftID, attempts, delay, err)
+	storage.MarkDraftQueued(e.DraftID)
+	s.publish(e.DraftID, ports.DraftStatusQueued)
+}
+
+// publish announces a change in the outbox
+func (s *OutboxService) publish(draftID int64, status ports.DraftStatus) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(ports.OutboxChangedEvent{
+		BaseEvent: ports.NewBaseEvent(ports.EventTypeOutboxChanged),
+		DraftID:   draftID,
+		Status:    status,
+	})
+}
+
+// publishSent announces that an email left the outbox after being sent
+func (s *OutboxService) publishSent(draftID int64, result *ports.SendResult) {
+	if s.events == nil {
+		return
+	}
+	var event = ports.OutboxChangedEvent{
+		BaseEvent: ports.NewBaseEvent(ports.EventTypeOutboxChanged),
+		DraftID:   draftID,
+		Status:    ports.DraftStatusSent,
+	}
+	if result != nil {
+		event.MessageID = result.MessageID
+	}
+	s.events.Publish(event)
+}
+
+// Start runs Process every outboxPollInterval, when a held email is due and
+// on Wake, until Stop
+func (s *OutboxService) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return // already running
+	}
+
+	var workerCtx, cancel = context.WithCancel(ctx)
+	var done = make(chan struct{})
+	s.cancel = cancel
+	s.done = done
+
+	go s.run(workerCtx, done)
+}
+
+// Stop stops the worker and waits for it to exit
+func (s *OutboxService) Stop() {
+	s.mu.Lock()
+	var cancel = s.cancel
+	var done = s.done
+	s.cancel = nil
+	s.done = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Wake makes the worker process the outbox now
+func (s *OutboxService) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the worker loop of Start
+func (s *OutboxService) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		if _, err := s.Process(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[OutboxService] Failed to process outbox: %v", err)
+		}
+
+		var timer = time.NewTimer(s.nextRound())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// nextRound returns how long the worker waits before processing again: until
+// the next email is due, at most outboxPollInterval
+func (s *OutboxService) nextRound() time.Duration {
+	var wait = outboxPollInterval
+
+	var entries, err = storage.GetOutbox()
+	if err != nil {
+		return wait
+	}
+	var now = s.now()
+	for _, e := range entries {
+		if e.Status != storage.DraftStatusQueued || !e.NextAttemptAt.After(now) {
+			continue
+		}
+		if until := e.NextAttemptAt.Sub(now); until < wait {
+			wait = until
+		}
+	}
+	return wait
+}
+
+// outboxBackoff returns how long to wait after the given number of failed
+// attempts: outboxBaseDelay doubling each time, capped at outboxMaxDelay
+func outboxBackoff(attempts int) time.Duration {
+	var delay = outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxDelay {
+		delay = outboxMaxDelay
+	}
+	return delay
+}
+
+// IsConnectivityError reports whether a send failed because the server
+// couldn't be reached (no network, DNS failure, refused or dropped
+// connection, timeout), as opposed to the server rejecting the email
+func IsConnectivityError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &opErr), errors.As(err, &dnsErr):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// outboxItem converts a storage outbox entry to a ports.OutboxItem
+func outboxItem(e storage.OutboxEntry) ports.OutboxItem {
+	var item = ports.OutboxItem{
+		ID:        e.DraftID,
+		AccountID: e.AccountID,
+		To:        e.ToAddresses,
+		Subject:   e.Subject,
+		Status:    ports.DraftStatus(e.Status),
+		Attempts:  e.Attempts,
+		LastError: nullStringValue(e.LastError),
+		QueuedAt:  e.CreatedAt.Time,
+	}
+	if !e.NextAttemptAt.IsZero() {
+		var t = e.NextAttemptAt.Time
+		item.NextAttemptAt = &t
+	}
+	return item
+}
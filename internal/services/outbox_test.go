@@ -0,0 +1,32 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, outboxBackoff(1))
+	assert.Equal(t, time.Minute, outboxBackoff(2))
+	assert.Equal(t, 4*time.Minute, outboxBackoff(4))
+	assert.Equal(t, 16*time.Minute, outboxBackoff(6))
+	assert.Equal(t, 30*time.Minute, outboxBackoff(7))
+	assert.Equal(t, 30*time.Minute, outboxBackoff(100))
+}
+
+func TestIsConnectivityError(t *testing.T) {
+	var dial = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	assert.True(t, IsConnectivityError(fmt.Errorf("failed to connect: %w", dial)))
+	assert.True(t, IsConnectivityError(&net.DNSError{Err: "no such host", Name: "smtp.example.com"}))
+	assert.True(t, IsConnectivityError(fmt.Errorf("failed to send: %w", io.EOF)))
+	assert.False(t, IsConnectivityError(errors.New("550 mailbox unavailable")))
+	assert.False(t, IsConnectivityError(fmt.Errorf("failed to send: %v", dial)))
+	assert.False(t, IsConnectivityError(nil))
+}
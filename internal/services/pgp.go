@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/opik/miau/internal/pgp"
+	"github.com/opik/miau/internal/ports"
+)
+
+// PGPAccount is the OpenPGP setup of one account
+type PGPAccount struct {
+	Key        string // fingerprint, key ID or email of the secret key; empty = the account email
+	Passphrase string
+	Sign       bool // sign every outgoing email
+	Encrypt    bool // encrypt every outgoing email
+}
+
+// PGPService implements ports.PGPService. Keys live in the keyring under
+// dir; the keyring is opened on first use.
+type PGPService struct {
+	mu       sync.RWMutex
+	dir      string
+	keyring  *pgp.Keyring
+	accounts map[string]PGPAccount // by lowercased email
+}
+
+// NewPGPService creates a new PGPService for the keyring stored in dir
+func NewPGPService(dir string) *PGPService {
+	return &PGPService{
+		dir:      dir,
+		accounts: make(map[string]PGPAccount),
+	}
+}
+
+// SetAccount registers the OpenPGP setup of an account
+func (s *PGPService) SetAccount(email string, settings PGPAccount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[strings.ToLower(email)] = settings
+}
+
+// open returns the keyring, loading it the first time
+func (s *PGPService) open() (*pgp.Keyring, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keyring != nil {
+		return s.keyring, nil
+	}
+	var keyring, err = pgp.OpenKeyring(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PGP keyring: %w", err)
+	}
+	s.keyring = keyring
+	return keyring, nil
+}
+
+// ListKeys returns every key in the keyring
+func (s *PGPService) ListKeys(ctx context.Context) ([]ports.PGPKey, error) {
+	var keyring, err = s.open()
+	if err != nil {
+		return nil, err
+	}
+	return toPGPKeys(keyring.Keys()), nil
+}
+
+// ImportKeys adds ASCII-armored keys to the keyring
+func (s *PGPService) ImportKeys(ctx context.Context, armored []byte) ([]ports.PGPKey, error) {
+	var keyring, err = s.open()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := keyring.Import(armored)
+	if err != nil {
+		return nil, err
+	}
+	return toPGPKeys(keys), nil
+}
+
+// ExportKey returns the ASCII-armored keys matching query
+func (s *PGPService) ExportKey(ctx context.Context, query string, secret bool) ([]byte, error) {
+	var keyring, err = s.open()
+	if err != nil {
+		return nil, err
+	}
+	return keyring.Export(query, secret)
+}
+
+// DeleteKey removes a key by fingerprint
+func (s *PGPService) DeleteKey(ctx context.Context, fingerprint string) error {
+	var keyring, err = s.open()
+	if err != nil {
+		return err
+	}
+	return keyring.Delete(fingerprint)
+}
+
+// Protector returns the PGP/MIME transform for an email sent from the
+// given address. Accounts with a secret key also advertise it in an
+// Autocrypt header, even when the email is neither signed nor encrypted.
+func (s *PGPService) Protector(ctx context.Context, from string, recipients []string, sign, encrypt bool) (func(raw []byte) ([]byte, error), error) {
+	s.mu.RLock()
+	var settings, configured = s.accounts[strings.ToLower(from)]
+	s.mu.RUnlock()
+
+	sign = sign || settings.Sign
+	encrypt = encrypt || settings.Encrypt
+	if !sign && !encrypt && !configured {
+		return nil, nil
+	}
+
+	var keyring, err = s.open()
+	if err != nil {
+		return nil, err
+	}
+	var query = settings.Key
+	if query == "" {
+		query = from
+	}
+
+	var own, _ = keyring.Own(query)
+	if own == nil && !sign && !encrypt {
+		return nil, nil
+	}
+
+	var signer = own
+	if sign || encrypt {
+		var unlocked, err = keyring.Signer(query, []byte(settings.Passphrase))
+		switch {
+		case err == nil:
+			signer = unlocked
+		case sign:
+			return nil, fmt.Errorf("PGP signing key for %s: %w", from, err)
+		default:
+			// Encrypting without our own key: the sent copy won't be readable
+			signer = nil
+		}
+	}
+
+	var to openpgp.EntityList
+	if encrypt {
+		var list, missing = keyring.Recipients(recipients)
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("no PGP key for %s", strings.Join(missing, ", "))
+		}
+		to = list
+		if signer != nil && !sign {
+			to = append(to, signer)
+		}
+	}
+
+	return func(raw []byte) ([]byte, error) {
+		var err error
+		if own != nil {
+			if raw, err = pgp.AddAutocrypt(raw, from, own); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case encrypt && sign:
+			return pgp.Encrypt(raw, to, signer)
+		case encrypt:
+			return pgp.Encrypt(raw, to, nil)
+		case sign:
+			return pgp.Sign(raw, signer)
+		}
+		return raw, nil
+	}, nil
+}
+
+// Open decrypts and verifies a raw email and imports the sender key from its
+// Autocrypt header
+func (s *PGPService) Open(ctx context.Context, raw []byte) (*ports.OpenedMessage, error) {
+	var keyring, err = s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := keyring.Discover(raw); err != nil {
+		log.Printf("[PGPService.Open] ignoring Autocrypt header: %v", err)
+	}
+
+	res, err := keyring.Open(raw, s.passphraseFor)
+	if err != nil {
+		return nil, err
+	}
+	var opened = &ports.OpenedMessage{Raw: res.Raw}
+	if res.Encrypted || res.Signature != pgp.StatusNone {
+		opened.Security = &ports.SecurityInfo{
+			Protocol:  ports.ProtocolPGP,
+			Encrypted: res.Encrypted,
+			Signature: ports.SignatureStatus(res.Signature),
+			Signer:    res.Signer,
+			KeyID:     res.KeyID,
+			Error:     res.Error,
+		}
+	}
+	return opened, nil
+}
+
+// passphraseFor finds the configured passphrase of a secret key
+func (s *PGPService) passphraseFor(key pgp.Key) []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for email, settings := range s.accounts {
+		if settings.Passphrase == "" {
+			continue
+		}
+		var query = strings.ToLower(settings.Key)
+		if query == "" {
+			query = email
+		}
+		if matchesPGPKey(key, query) {
+			return []byte(settings.Passphrase)
+		}
+	}
+	return nil
+}
+
+func matchesPGPKey(key pgp.Key, query string) bool {
+	if strings.Contains(query, "@") {
+		for _, email := range key.Emails {
+			if email == query {
+				return true
+			}
+		}
+		return false
+	}
+	query = strings.ToUpper(strings.ReplaceAll(strings.TrimPrefix(query, "0x"), " ", ""))
+	return len(query) >= 8 && strings.HasSuffix(key.Fingerprint, query)
+}
+
+func toPGPKeys(keys []pgp.Key) []ports.PGPKey {
+	var result = make([]ports.PGPKey, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, ports.PGPKey{
+			Fingerprint: k.Fingerprint,
+			KeyID:       k.KeyID,
+			UserIDs:     k.UserIDs,
+			Emails:      k.Emails,
+			Secret:      k.Secret,
+			CanEncrypt:  k.CanEncrypt,
+			Revoked:     k.Revoked,
+			Created:     k.Created,
+			Expires:     k.Expires,
+		})
+	}
+	return result
+}
@@ -271,7 +271,7 @@ Assunto: %s
 ---
 %s`, email.FromName, email.FromEmail, email.Subject, body)
 
-	return s.callAI(ctx, "claude", prompt)
+	return s.callAI(ctx, prompt)
 }
 
 // executeExtractActions extracts action items
@@ -342,7 +342,7 @@ Retorne apenas a tradução.
 ---
 %s`, langName, body)
 
-	var translation, aiErr = s.callAI(ctx, "claude", prompt)
+	var translation, aiErr = s.callAI(ctx, prompt)
 	if aiErr != nil {
 		return "", aiErr
 	}
@@ -378,7 +378,7 @@ NÃO use markdown ou formatação.
 ---
 %s`, tone, body)
 
-	var rewritten, aiErr = s.callAI(ctx, "claude", prompt)
+	var rewritten, aiErr = s.callAI(ctx, prompt)
 	if aiErr != nil {
 		return "", aiErr
 	}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	emailparser "github.com/opik/miau/internal/email"
+	"github.com/opik/miau/internal/email/authresults"
+	"github.com/opik/miau/internal/ports"
+)
+
+// RiskService implements ports.RiskService
+type RiskService struct {
+	storage  ports.StoragePort
+	analyzer *authresults.Analyzer
+}
+
+// NewRiskService creates a new RiskService. resolver looks up DKIM keys
+// (net.DefaultResolver in production); nil disables local DKIM verification.
+func NewRiskService(storage ports.StoragePort, resolver authresults.Resolver) *RiskService {
+	return &RiskService{
+		storage:  storage,
+		analyzer: &authresults.Analyzer{Resolver: resolver},
+	}
+}
+
+// Assess analyses a stored email and saves the result. Emails sent by the
+// account itself aren't rated.
+func (s *RiskService) Assess(ctx context.Context, emailID int64, raw []byte) (*ports.RiskInfo, error) {
+	var email, err = s.storage.GetEmail(ctx, emailID)
+	if err != nil {
+		return nil, err
+	}
+	if email == nil {
+		return nil, fmt.Errorf("email %d not found", emailID)
+	}
+
+	if account, err := s.storage.GetAccount(ctx, email.AccountID); err == nil && account != nil &&
+		strings.EqualFold(account.Email, email.FromEmail) {
+		return &ports.RiskInfo{}, nil
+	}
+
+	var msg = authresults.Message{
+		Raw:       raw,
+		FromName:  email.FromName,
+		FromEmail: email.FromEmail,
+		HTML:      email.BodyHTML,
+	}
+	if len(raw) > 0 {
+		if html := emailparser.ExtractHTML(raw); html != "" {
+			msg.HTML = html
+		}
+	} else if email.RawHeaders != "" {
+		msg.Raw = []byte(email.RawHeaders + "\r\n\r\n")
+	}
+
+	var knowledge, err2 = s.storage.GetSenderKnowledge(ctx, email.AccountID, emailID, email.FromName, email.FromEmail)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	var report = s.analyzer.Analyze(ctx, msg, authresults.Sender{
+		Known:        knowledge.Known,
+		NameEmails:   knowledge.NameEmails,
+		KnownDomains: knowledge.KnownDomains,
+	})
+	var risk = convertReport(report)
+	if err := s.storage.SaveEmailRisk(ctx, emailID, email.AccountID, risk); err != nil {
+		return nil, err
+	}
+	return risk, nil
+}
+
+// GetRisk returns the saved analysis of an email, nil when not analysed
+func (s *RiskService) GetRisk(ctx context.Context, emailID int64) (*ports.RiskInfo, error) {
+	return s.storage.GetEmailRisk(ctx, emailID)
+}
+
+// convertReport converts an authresults report to a ports.RiskInfo
+func convertReport(report *authresults.Report) *ports.RiskInfo {
+	var risk = &ports.RiskInfo{
+		Score:        report.Score,
+		Level:        ports.RiskLevel(report.Level),
+		SPF:          string(report.SPF),
+		DKIM:         string(report.DKIM),
+		DMARC:        string(report.DMARC),
+		DKIMVerified: report.DKIMVerified,
+		Findings:     []ports.RiskFinding{},
+		AnalyzedAt:   time.Now(),
+	}
+	for _, f := range report.Findings {
+		risk.Findings = append(risk.Findings, ports.RiskFinding{Reason: string(f.Reason), Detail: f.Detail})
+	}
+	return risk
+}
+
+// attachRisk fills in the risk level of each email (best effort)
+func (s *EmailService) attachRisk(ctx context.Context, emails []ports.EmailMetadata) {
+	if len(emails) == 0 {
+		return
+	}
+	var ids = make([]int64, len(emails))
+	for i, e := range emails {
+		ids[i] = e.ID
+	}
+
+	var risks, err = s.storage.GetRiskForEmails(ctx, ids)
+	if err != nil {
+		log.Printf("[attachRisk] Failed to load risk: %v", err)
+		return
+	}
+	for i := range emails {
+		emails[i].RiskLevel = risks[emails[i].ID].Level
+	}
+}
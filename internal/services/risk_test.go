@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/testutil"
+	"github.com/opik/miau/internal/testutil/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRiskService_Assess_StoredHeaders(t *testing.T) {
+	// Arrange
+	var mockStorage = new(mocks.StoragePort)
+	var svc = NewRiskService(mockStorage, nil)
+
+	var email = testutil.TestEmailContent()
+	email.FromName = "Alice Smith"
+	email.FromEmail = "alice@paypa1.com"
+	email.RawHeaders = "Authentication-Results: mx.example.com; spf=fail smtp.mailfrom=paypa1.com; dmarc=fail header.from=paypa1.com\r\n" +
+		"From: Alice Smith <alice@paypa1.com>"
+	email.BodyHTML = `<a href="https://paypa1.com/login">https://paypal.com/login</a>`
+
+	mockStorage.On("GetEmail", mock.Anything, email.ID).Return(email, nil)
+	mockStorage.On("GetAccount", mock.Anything, email.AccountID).Return(testutil.TestAccount(), nil)
+	mockStorage.On("GetSenderKnowledge", mock.Anything, email.AccountID, email.ID, "Alice Smith", "alice@paypa1.com").
+		Return(&ports.SenderKnowledge{NameEmails: []string{"alice@company.com"}, KnownDomains: []string{"paypal.com"}}, nil)
+	mockStorage.On("SaveEmailRisk", mock.Anything, email.ID, email.AccountID, mock.AnythingOfType("*ports.RiskInfo")).Return(nil)
+
+	// Act
+	var risk, err = svc.Assess(context.Background(), email.ID, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, ports.RiskHigh, risk.Level)
+	assert.Equal(t, "fail", risk.SPF)
+	assert.Equal(t, "fail", risk.DMARC)
+	assert.False(t, risk.DKIMVerified)
+
+	var reasons = make(map[string]bool)
+	for _, f := range risk.Findings {
+		reasons[f.Reason] = true
+	}
+	assert.True(t, reasons["dmarc_fail"])
+	assert.True(t, reasons["contact_spoof"])
+	assert.True(t, reasons["lookalike_domain"])
+	assert.True(t, reasons["link_mismatch"])
+	assert.True(t, reasons["first_time_sender"])
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestRiskService_Assess_OwnEmail(t *testing.T) {
+	// Arrange
+	var mockStorage = new(mocks.StoragePort)
+	var svc = NewRiskService(mockStorage, nil)
+
+	var email = testutil.TestEmailContent()
+	email.FromEmail = "Test@Example.com"
+
+	mockStorage.On("GetEmail", mock.Anything, email.ID).Return(email, nil)
+	mockStorage.On("GetAccount", mock.Anything, email.AccountID).Return(testutil.TestAccount(), nil)
+
+	// Act
+	var risk, err = svc.Assess(context.Background(), email.ID, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, ports.RiskNone, risk.Level)
+	assert.Equal(t, 0, risk.Score)
+
+	mockStorage.AssertNotCalled(t, "SaveEmailRisk", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
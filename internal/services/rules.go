@@ -0,0 +1,715 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opik/miau/internal/ai"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/rules"
+	"github.com/opik/miau/internal/storage"
+)
+
+// classifyTimeout bounds the AI call of a classify action (it runs inline during sync)
+const classifyTimeout = 30 * time.Second
+
+// RuleService implements ports.RuleService. Matching lives in the rules
+// package; this service stores rules, gathers the email data they need and
+// runs the actions as undoable operations.
+type RuleService struct {
+	mu      sync.RWMutex
+	imap    ports.IMAPPort
+	storage ports.StoragePort
+	events  ports.EventBus
+	undo    ports.UndoService
+	snooze  ports.SnoozeService
+	tasks   ports.TaskService
+	send    ports.SendService
+	ai      ports.AIService
+	account *ports.AccountInfo
+}
+
+// NewRuleService creates a new RuleService
+func NewRuleService(imap ports.IMAPPort, storage ports.StoragePort, events ports.EventBus, undo ports.UndoService) *RuleService {
+	return &RuleService{
+		imap:    imap,
+		storage: storage,
+		events:  events,
+		undo:    undo,
+	}
+}
+
+// SetAccount sets the current account
+func (s *RuleService) SetAccount(account *ports.AccountInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account = account
+}
+
+// SetIMAPAdapter updates the IMAP adapter (used when switching accounts)
+func (s *RuleService) SetIMAPAdapter(imap ports.IMAPPort) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.imap = imap
+}
+
+// SetActionServices sets the services used by snooze, create_task, forward
+// and classify actions. Actions whose service is nil fail with an error.
+func (s *RuleService) SetActionServices(snooze ports.SnoozeService, tasks ports.TaskService, send ports.SendService, ai ports.AIService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snooze = snooze
+	s.tasks = tasks
+	s.send = send
+	s.ai = ai
+}
+
+// storedCondition is the JSON shape of a condition in mail_rules.conditions
+type storedCondition struct {
+	Field    string `json:"field"`
+	Header   string `json:"header,omitempty"`
+	Operator string `json:"op,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Negate   bool   `json:"negate,omitempty"`
+}
+
+// storedAction is the JSON shape of an action in mail_rules.actions
+type storedAction struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+func ruleToStorage(rule *ports.Rule) (*storage.MailRule, error) {
+	var conditions = make([]storedCondition, len(rule.Conditions))
+	for i, c := range rule.Conditions {
+		conditions[i] = storedCondition{
+			Field:    string(c.Field),
+			Header:   c.Header,
+			Operator: string(c.Operator),
+			Value:    c.Value,
+			Negate:   c.Negate,
+		}
+	}
+	var actions = make([]storedAction, len(rule.Actions))
+	for i, a := range rule.Actions {
+		actions[i] = storedAction{Type: string(a.Type), Value: a.Value}
+	}
+
+	var condJSON, err = json.Marshal(conditions)
+	if err != nil {
+		return nil, err
+	}
+	var actJSON, err2 = json.Marshal(actions)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	return &storage.MailRule{
+		ID:         rule.ID,
+		AccountID:  rule.AccountID,
+		Name:       rule.Name,
+		Enabled:    rule.Enabled,
+		Position:   rule.Position,
+		MatchAny:   rule.MatchAny,
+		Stop:       rule.Stop,
+		Conditions: string(condJSON),
+		Actions:    string(actJSON),
+	}, nil
+}
+
+func ruleFromStorage(r *storage.MailRule) (ports.Rule, error) {
+	var rule = ports.Rule{
+		ID:        r.ID,
+		AccountID: r.AccountID,
+		Name:      r.Name,
+		Enabled:   r.Enabled,
+		Position:  r.Position,
+		MatchAny:  r.MatchAny,
+		Stop:      r.Stop,
+		HitCount:  r.HitCount,
+		CreatedAt: r.CreatedAt.Time,
+		UpdatedAt: r.UpdatedAt.Time,
+	}
+	if r.LastHitAt.Valid {
+		var t = r.LastHitAt.Time
+		rule.LastHitAt = &t
+	}
+
+	var conditions []storedCondition
+	if err := json.Unmarshal([]byte(r.Conditions), &conditions); err != nil {
+		return rule, fmt.Errorf("rule %d: invalid conditions: %w", r.ID, err)
+	}
+	for _, c := range conditions {
+		rule.Conditions = append(rule.Conditions, ports.RuleCondition{
+			Field:    ports.RuleField(c.Field),
+			Header:   c.Header,
+			Operator: ports.RuleOperator(c.Operator),
+			Value:    c.Value,
+			Negate:   c.Negate,
+		})
+	}
+
+	var actions []storedAction
+	if err := json.Unmarshal([]byte(r.Actions), &actions); err != nil {
+		return rule, fmt.Errorf("rule %d: invalid actions: %w", r.ID, err)
+	}
+	for _, a := range actions {
+		rule.Actions = append(rule.Actions, ports.RuleAction{Type: ports.RuleActionType(a.Type), Value: a.Value})
+	}
+	return rule, nil
+}
+
+func rulesFromStorage(stored []storage.MailRule) ([]ports.Rule, error) {
+	var result = make([]ports.Rule, 0, len(stored))
+	for i := range stored {
+		var rule, err = ruleFromStorage(&stored[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+func (s *RuleService) currentAccount() (*ports.AccountInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.account == nil {
+		return nil, fmt.Errorf("no account set")
+	}
+	return s.account, nil
+}
+
+// GetRules returns all rules of the current account, in evaluation order
+func (s *RuleService) GetRules(ctx context.Context) ([]ports.Rule, error) {
+	var account, err = s.currentAccount()
+	if err != nil {
+		return nil, err
+	}
+	var stored, err2 = storage.GetRules(account.ID)
+	if err2 != nil {
+		return nil, fmt.Errorf("failed to get rules: %w", err2)
+	}
+	return rulesFromStorage(stored)
+}
+
+// GetRule returns a rule by ID (nil if it doesn't exist)
+func (s *RuleService) GetRule(ctx context.Context, id int64) (*ports.Rule, error) {
+	var stored, err = storage.GetRule(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule: %w", err)
+	}
+	if stored == nil {
+		return nil, nil
+	}
+	var rule, err2 = ruleFromStorage(stored)
+	if err2 != nil {
+		return nil, err2
+	}
+	return &rule, nil
+}
+
+// SaveRule creates (ID == 0) or updates a rule after validating it
+func (s *RuleService) SaveRule(ctx context.Context, rule *ports.Rule) (*ports.Rule, error) {
+	var account, err = s.currentAccount()
+	if err != nil {
+		return nil, err
+	}
+	if err := rules.Validate(rule); err != nil {
+		return nil, err
+	}
+
+	rule.AccountID = account.ID
+	var stored, err2 = ruleToStorage(rule)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	if rule.ID == 0 {
+		if err := storage.CreateRule(stored); err != nil {
+			return nil, fmt.Errorf("failed to create rule: %w", err)
+		}
+	} else {
+		var existing, err3 = storage.GetRule(rule.ID)
+		if err3 != nil {
+			return nil, fmt.Errorf("failed to get rule: %w", err3)
+		}
+		if existing == nil || existing.AccountID != account.ID {
+			return nil, fmt.Errorf("rule %d not found", rule.ID)
+		}
+		if err := storage.UpdateRule(stored); err != nil {
+			return nil, fmt.Errorf("failed to update rule: %w", err)
+		}
+	}
+
+	return s.GetRule(ctx, stored.ID)
+}
+
+// DeleteRule removes a rule
+func (s *RuleService) DeleteRule(ctx context.Context, id int64) error {
+	if err := storage.DeleteRule(id); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+	return nil
+}
+
+// SetRuleEnabled enables or disables a rule
+func (s *RuleService) SetRuleEnabled(ctx context.Context, id int64, enabled bool) error {
+	if err := storage.SetRuleEnabled(id, enabled); err != nil {
+		return fmt.Errorf("failed to update rule: %w", err)
+	}
+	return nil
+}
+
+// ImportRules parses rules in the sieve-like text format and saves them.
+// The text is parsed before anything is deleted and a replace happens in a
+// single transaction, so a bad file or a failed save never wipes the
+// existing rules.
+func (s *RuleService) ImportRules(ctx context.Context, text string, replace bool) ([]ports.Rule, error) {
+	var account, err = s.currentAccount()
+	if err != nil {
+		return nil, err
+	}
+	var parsed, err2 = rules.Parse(text)
+	if err2 != nil {
+		return nil, err2
+	}
+
+	if replace {
+		return s.replaceRules(ctx, account, parsed)
+	}
+
+	var saved = make([]ports.Rule, 0, len(parsed))
+	for i := range parsed {
+		var rule, err = s.SaveRule(ctx, &parsed[i])
+		if err != nil {
+			return saved, err
+		}
+		saved = append(saved, *rule)
+	}
+	return saved, nil
+}
+
+// replaceRules validates all rules, then swaps them for the account's
+// current ones atomically
+func (s *RuleService) replaceRules(ctx context.Context, account *ports.AccountInfo, parsed []ports.Rule) ([]ports.Rule, error) {
+	var stored = make([]storage.MailRule, 0, len(parsed))
+	for i := range parsed {
+		var rule = &parsed[i]
+		if err := rules.Validate(rule); err != nil {
+			return nil, err
+		}
+		rule.AccountID = account.ID
+		var r, err = ruleToStorage(rule)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, *r)
+	}
+
+	if err := storage.ReplaceRules(account.ID, stored); err != nil {
+		return nil, fmt.Errorf("failed to replace rules: %w", err)
+	}
+	return s.GetRules(ctx)
+}
+
+// ExportRules returns all rules in the sieve-like text format
+func (s *RuleService) ExportRules(ctx context.Context) (string, error) {
+	var list, err = s.GetRules(ctx)
+	if err != nil {
+		return "", err
+	}
+	return rules.Format(list), nil
+}
+
+// RunRules applies the enabled rules to the newest emails of a folder
+func (s *RuleService) RunRules(ctx context.Context, folder string, limit int) (*ports.RuleRunResult, error) {
+	var account, err = s.currentAccount()
+	if err != nil {
+		return nil, err
+	}
+
+	var f, err2 = s.storage.GetFolderByName(ctx, account.ID, folder)
+	if err2 != nil {
+		return nil, fmt.Errorf("folder not found: %w", err2)
+	}
+	var list, err3 = s.storage.GetEmails(ctx, f.ID, limit)
+	if err3 != nil {
+		return nil, err3
+	}
+	var ids = make([]int64, 0, len(list))
+	for _, meta := range list {
+		ids = append(ids, meta.ID)
+	}
+	return s.ApplyToEmails(ctx, ids)
+}
+
+// ApplyToEmails applies the enabled rules to stored emails, selecting each
+// email's folder on the main connection before running its rules
+func (s *RuleService) ApplyToEmails(ctx context.Context, emailIDs []int64) (*ports.RuleRunResult, error) {
+	if _, err := s.currentAccount(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	var imap = s.imap
+	s.mu.RUnlock()
+
+	// Group by folder so each mailbox is selected once
+	var folders []string
+	var byFolder = make(map[string][]ports.EmailContent)
+	for _, id := range emailIDs {
+		var email, err = s.storage.GetEmail(ctx, id)
+		if err != nil || email == nil {
+			continue
+		}
+		if _, ok := byFolder[email.FolderName]; !ok {
+			folders = append(folders, email.FolderName)
+		}
+		byFolder[email.FolderName] = append(byFolder[email.FolderName], *email)
+	}
+
+	var result = &ports.RuleRunResult{}
+	for _, folder := range folders {
+		if _, err := imap.SelectMailbox(ctx, folder); err != nil {
+			return result, fmt.Errorf("failed to select mailbox: %w", err)
+		}
+		var partial = s.ApplyRules(ctx, imap, byFolder[folder])
+		if partial == nil {
+			continue
+		}
+		result.Checked += partial.Checked
+		result.Matched += partial.Matched
+		result.Actions += partial.Actions
+		result.Errors = append(result.Errors, partial.Errors...)
+	}
+	return result, nil
+}
+
+// ApplyRules runs the enabled rules over emails of the folder currently
+// selected on imap. Used by SyncService right after new emails are stored.
+// Returns nil when the account has no enabled rules.
+func (s *RuleService) ApplyRules(ctx context.Context, imap ports.IMAPPort, emails []ports.EmailContent) *ports.RuleRunResult {
+	var account, err = s.currentAccount()
+	if err != nil || len(emails) == 0 {
+		return nil
+	}
+	var stored, err2 = storage.GetEnabledRules(account.ID)
+	if err2 != nil || len(stored) == 0 {
+		return nil
+	}
+	var list, err3 = rulesFromStorage(stored)
+	if err3 != nil {
+		log.Printf("[RuleService] %v", err3)
+		return &ports.RuleRunResult{Errors: []error{err3}}
+	}
+
+	var needHeaders = rules.NeedsHeaders(list)
+	var needBody = rules.NeedsBody(list)
+	var result = &ports.RuleRunResult{}
+
+	for i := range emails {
+		var email = s.ruleEmail(ctx, imap, &emails[i], needHeaders, needBody)
+		result.Checked++
+
+		var matched = false
+		for j := range list {
+			var rule = &list[j]
+			if !rules.Match(rule, email) {
+				continue
+			}
+			matched = true
+
+			var done, terminal, err = s.applyRule(ctx, imap, rule, email)
+			result.Actions += len(done)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("rule %q on %q: %w", rule.Name, email.Subject, err))
+			}
+			storage.RecordRuleHit(rule.ID)
+			s.events.Publish(ports.RuleAppliedEvent{
+				BaseEvent: ports.NewBaseEvent(ports.EventTypeRuleApplied),
+				RuleID:    rule.ID,
+				RuleName:  rule.Name,
+				EmailID:   email.ID,
+				Subject:   email.Subject,
+				Actions:   done,
+				Error:     err,
+			})
+
+			// A moved/archived email is no longer in this folder
+			if rule.Stop || terminal {
+				break
+			}
+		}
+		if matched {
+			result.Matched++
+		}
+	}
+	return result
+}
+
+// ruleEmail builds the data rules are evaluated against, fetching the raw
+// headers and body only when some rule needs them
+func (s *RuleService) ruleEmail(ctx context.Context, imap ports.IMAPPort, e *ports.EmailContent, needHeaders, needBody bool) *ports.RuleEmail {
+	var email = &ports.RuleEmail{
+		ID:             e.ID,
+		UID:            e.UID,
+		Folder:         e.FolderName,
+		Subject:        e.Subject,
+		FromName:       e.FromName,
+		FromEmail:      e.FromEmail,
+		To:             e.ToAddress,
+		Cc:             e.CcAddresses,
+		BodyText:       e.BodyText,
+		HasAttachments: e.HasAttachments,
+		Size:           e.Size,
+		IsRead:         e.IsRead,
+		IsStarred:      e.IsStarred,
+	}
+	if e.ToAddresses != "" {
+		email.To = e.ToAddresses
+	}
+
+	if needHeaders {
+		var raw []byte
+		if e.RawHeaders != "" {
+			raw = []byte(e.RawHeaders + "\r\n\r\n")
+		} else if fetched, err := imap.FetchEmailRaw(ctx, e.UID); err == nil {
+			raw = fetched
+		}
+		if msg, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+			email.Headers = msg.Header
+			if email.Cc == "" {
+				email.Cc = msg.Header.Get("Cc")
+			}
+		}
+	}
+
+	if needBody && email.BodyText == "" {
+		if body, err := imap.FetchEmailBody(ctx, e.UID); err == nil {
+			email.BodyText = body
+		}
+	}
+	return email
+}
+
+// applyRule runs the actions of a matching rule and records them as one
+// undoable operation. Move/archive run last since they change the UID.
+// Returns the descriptions of the actions done and whether the email left
+// the folder.
+func (s *RuleService) applyRule(ctx context.Context, imap ports.IMAPPort, rule *ports.Rule, email *ports.RuleEmail) ([]string, bool, error) {
+	var op = NewRuleOperation(rule.ID, rule.Name, email.ID, email.Subject)
+	var done []string
+	var err error
+	var terminal *ports.RuleAction
+
+	for i := range rule.Actions {
+		var action = rule.Actions[i]
+		if action.Type == ports.RuleActionMove || action.Type == ports.RuleActionArchive {
+			terminal = &rule.Actions[i]
+			continue
+		}
+		if err = s.runAction(ctx, imap, op, action, email); err != nil {
+			break
+		}
+		done = append(done, describeAction(action))
+	}
+
+	var moved = false
+	if err == nil && terminal != nil {
+		if err = s.runAction(ctx, imap, op, *terminal, email); err == nil {
+			done = append(done, describeAction(*terminal))
+			moved = true
+		}
+	}
+
+	// Record what was done even if a later action failed
+	if s.undo != nil && !op.Empty() {
+		s.mu.RLock()
+		op.Rebind(s.imap)
+		s.mu.RUnlock()
+		s.undo.RecordOperation(ctx, op)
+	}
+	return done, moved, err
+}
+
+// runAction executes a single action, adding reversible steps to op
+func (s *RuleService) runAction(ctx context.Context, imap ports.IMAPPort, op *RuleOperation, action ports.RuleAction, email *ports.RuleEmail) error {
+	s.mu.RLock()
+	var account = s.account
+	var snooze, tasks, send, aiService = s.snooze, s.tasks, s.send, s.ai
+	s.mu.RUnlock()
+
+	switch action.Type {
+	case ports.RuleActionMarkRead:
+		if email.IsRead {
+			return nil
+		}
+		email.IsRead = true
+		return s.execute(ctx, op, NewMarkReadOperation(email.ID, true, false, email.Subject, email.UID, s.storage, imap))
+
+	case ports.RuleActionStar:
+		if email.IsStarred {
+			return nil
+		}
+		email.IsStarred = true
+		return s.execute(ctx, op, NewMarkStarredOperation(email.ID, true, false, email.Subject, s.storage))
+
+	case ports.RuleActionLabel:
+		return s.execute(ctx, op, NewLabelOperation(email.UID, rules.Keyword(action.Value), email.Subject, imap))
+
+	case ports.RuleActionSnooze:
+		if snooze == nil {
+			return fmt.Errorf("snooze is not available")
+		}
+		if rules.IsSnoozePreset(action.Value) {
+			return s.execute(ctx, op, NewSnoozeOperation(email.ID, ports.SnoozePreset(action.Value), time.Time{}, email.Subject, snooze))
+		}
+		var until, err = rules.SnoozeUntil(action.Value, time.Now())
+		if err != nil {
+			return err
+		}
+		return s.execute(ctx, op, NewSnoozeOperation(email.ID, "", until, email.Subject, snooze))
+
+	case ports.RuleActionCreateTask:
+		if tasks == nil {
+			return fmt.Errorf("tasks are not available")
+		}
+		var emailID = email.ID
+		return s.execute(ctx, op, NewCreateTaskOperation(ports.TaskInput{
+			AccountID: account.ID,
+			Title:     rules.ExpandTemplate(action.Value, email),
+			EmailID:   &emailID,
+			Source:    ports.TaskSourceRule,
+		}, tasks))
+
+	case ports.RuleActionForward:
+		if send == nil {
+			return fmt.Errorf("sending is not available")
+		}
+		if err := s.forward(ctx, imap, send, account.ID, action.Value, email); err != nil {
+			return err
+		}
+		op.AddIrreversible("encaminhar para " + action.Value)
+		return nil
+
+	case ports.RuleActionClassify:
+		if aiService == nil {
+			return fmt.Errorf("AI is not available")
+		}
+		// Rules run unattended on incoming mail: a crafted email could
+		// prompt-inject a CLI agent into running tools on this machine
+		if name := aiService.ProviderName(); ai.IsCLI(name) {
+			return fmt.Errorf("classify needs an API provider (anthropic, openai or ollama), not the %s CLI", name)
+		}
+		var aiCtx, cancel = context.WithTimeout(ctx, classifyTimeout)
+		defer cancel()
+		var category, err = aiService.ClassifyEmail(aiCtx, email.ID)
+		if err != nil {
+			return fmt.Errorf("classify: %w", err)
+		}
+		category = strings.ToLower(strings.TrimSpace(strings.SplitN(strings.TrimSpace(category), "\n", 2)[0]))
+		if category == "" {
+			return nil
+		}
+		return s.execute(ctx, op, NewLabelOperation(email.UID, rules.Keyword(category), email.Subject, imap))
+
+	case ports.RuleActionMove:
+		if err := s.execute(ctx, op, NewMoveOperation(email.ID, email.Subject, email.Folder, action.Value, email.UID, s.storage, imap)); err != nil {
+			return err
+		}
+		// The local copy left this folder; the target folder picks it up on its next sync
+		return s.execute(ctx, op, &movedOutOperation{emailID: email.ID, storage: s.storage})
+
+	case ports.RuleActionArchive:
+		return s.execute(ctx, op, NewArchiveOperation(email.ID, email.Subject, email.UID, false, s.storage, imap))
+	}
+	return fmt.Errorf("unknown action %q", action.Type)
+}
+
+// execute runs a step and adds it to the rule operation
+func (s *RuleService) execute(ctx context.Context, op *RuleOperation, step ports.Operation) error {
+	if err := step.Execute(ctx); err != nil {
+		return err
+	}
+	op.Add(step)
+	return nil
+}
+
+// forward sends the email body to addr with the usual forwarded-message
+// header, from the account the rule belongs to
+func (s *RuleService) forward(ctx context.Context, imap ports.IMAPPort, send ports.SendService, accountID int64, addr string, email *ports.RuleEmail) error {
+	var body = email.BodyText
+	if body == "" {
+		if fetched, err := imap.FetchEmailBody(ctx, email.UID); err == nil {
+			body = fetched
+		}
+	}
+
+	var from = email.FromEmail
+	if email.FromName != "" {
+		from = fmt.Sprintf("%s <%s>", email.FromName, email.FromEmail)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---------- Forwarded message ---------\n")
+	sb.WriteString("From: " + from + "\n")
+	sb.WriteString("Subject: " + email.Subject + "\n")
+	if email.To != "" {
+		sb.WriteString("To: " + email.To + "\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(body)
+
+	var _, err = send.Send(ctx, &ports.SendRequest{
+		AccountID: accountID,
+		To:        []string{addr},
+		Subject:   "Fwd: " + email.Subject,
+		BodyText:  sb.String(),
+	})
+	return err
+}
+
+// describeAction is the short text shown in RuleAppliedEvent
+func describeAction(a ports.RuleAction) string {
+	if a.Value == "" {
+		return string(a.Type)
+	}
+	return string(a.Type) + " " + a.Value
+}
+
+// movedOutOperation hides the local copy of an email moved by a rule
+type movedOutOperation struct {
+	emailID int64
+	storage ports.StoragePort
+}
+
+func (o *movedOutOperation) Execute(ctx context.Context) error {
+	return o.storage.MarkAsDeleted(ctx, o.emailID, true)
+}
+
+func (o *movedOutOperation) Undo(ctx context.Context) error {
+	return o.storage.MarkAsDeleted(ctx, o.emailID, false)
+}
+
+func (o *movedOutOperation) Description() string {
+	return "Remover da pasta local"
+}
+
+func (o *movedOutOperation) Type() ports.OperationType {
+	return ports.OperationTypeRule
+}
+
+func (o *movedOutOperation) Data() (string, error) {
+	var data, err = json.Marshal(map[string]interface{}{"email_id": o.emailID})
+	return string(data), err
+}
+
+// Ensure RuleService implements ports.RuleService
+var _ ports.RuleService = (*RuleService)(nil)
@@ -4,13 +4,28 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
+	"net/mail"
 	"sync"
 	"time"
 
 	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/rrule"
 	"github.com/opik/miau/internal/storage"
 )
 
+// schedulePollInterval is how often the worker looks for scheduled emails
+// that are due; recurring occurrences wake it up exactly on time
+const schedulePollInterval = 30 * time.Second
+
+// recurrencePresets are the ready-made rules offered by GetRecurrencePresets
+var recurrencePresets = []ports.RecurrencePresetInfo{
+	{Label: "Every Monday at 9:00", Rule: "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0"},
+	{Label: "Every weekday at 9:00", Rule: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;BYHOUR=9;BYMINUTE=0"},
+	{Label: "First business day of the month at 9:00", Rule: "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=1;BYHOUR=9;BYMINUTE=0"},
+	{Label: "Last business day of the month at 17:00", Rule: "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;BYHOUR=17;BYMINUTE=0"},
+}
+
 // ScheduleService implements ports.ScheduleService
 type ScheduleService struct {
 	mu          sync.RWMutex
@@ -18,6 +33,12 @@ type ScheduleService struct {
 	sendService ports.SendService
 	events      ports.EventBus
 	account     *ports.AccountInfo
+	now         func() time.Time
+
+	processing sync.Mutex // one ProcessDueSchedules at a time
+	cancel     context.CancelFunc
+	done       chan struct{}
+	wake       chan struct{}
 }
 
 // NewScheduleService creates a new ScheduleService
@@ -26,6 +47,8 @@ func NewScheduleService(storagePort ports.StoragePort, sendService ports.SendSer
 		storage:     storagePort,
 		sendService: sendService,
 		events:      events,
+		now:         time.Now,
+		wake:        make(chan struct{}, 1),
 	}
 }
 
@@ -95,8 +118,155 @@ func (s *ScheduleService) GetScheduledDraftsCount(ctx context.Context) (int, err
 	return storage.CountScheduledDrafts(account.ID)
 }
 
-// ProcessDueSchedules sends emails that are due
+// ScheduleDraftAt schedules a draft to be sent once at sendAt. With
+// opts.RecipientTimeZone the wall clock time of sendAt is read in the
+// recipient's time zone: 9:00 means 9:00 where the recipient is.
+func (s *ScheduleService) ScheduleDraftAt(ctx context.Context, draftID int64, sendAt time.Time, opts ports.ScheduleOptions) (time.Time, error) {
+	var draft, err = s.accountDraft(draftID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if opts.RecipientTimeZone {
+		if loc, _ := recipientLocation(draft); loc != nil {
+			sendAt = inLocation(sendAt, loc)
+		}
+	}
+	if !sendAt.After(s.now()) {
+		return time.Time{}, fmt.Errorf("send time %s is in the past", sendAt.Format(time.RFC3339))
+	}
+
+	if err := storage.ScheduleDraft(draftID, sendAt.UTC()); err != nil {
+		return time.Time{}, err
+	}
+	s.publishScheduled()
+	return sendAt, nil
+}
+
+// GetRecurrencePresets returns ready-made recurrence rules
+func (s *ScheduleService) GetRecurrencePresets() []ports.RecurrencePresetInfo {
+	return recurrencePresets
+}
+
+// ScheduleRecurring turns a draft into the template of a recurring send.
+// The rule runs in the local time zone or, with opts.RecipientTimeZone, in
+// the recipient's; a zero startsAt means now.
+func (s *ScheduleService) ScheduleRecurring(ctx context.Context, draftID int64, rule string, startsAt time.Time, opts ports.ScheduleOptions) (*ports.RecurringSchedule, error) {
+	var r, err = rrule.Parse(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	var draft, errDraft = s.accountDraft(draftID)
+	if errDraft != nil {
+		return nil, errDraft
+	}
+	if draft.Status != storage.DraftStatusDraft && draft.Status != storage.DraftStatusScheduled {
+		return nil, fmt.Errorf("draft #%d can't be made recurring (status %s)", draftID, draft.Status)
+	}
+
+	var loc = time.Local
+	var zone string
+	if opts.RecipientTimeZone {
+		if recipient, name := recipientLocation(draft); recipient != nil {
+			loc, zone = recipient, name
+		}
+	}
+
+	var now = s.now()
+	if startsAt.IsZero() {
+		startsAt = now
+	}
+	startsAt = inLocation(startsAt, loc)
+
+	var after = startsAt.Add(-time.Second)
+	if now.After(after) {
+		after = now
+	}
+	var next = r.Next(startsAt, after)
+	if next.IsZero() {
+		return nil, fmt.Errorf("rule %s has no occurrences after %s", r.String(), after.Format(time.RFC3339))
+	}
+
+	var item = &storage.RecurringSchedule{
+		AccountID: draft.AccountID,
+		DraftID:   draftID,
+		Rule:      r.String(),
+		TimeZone:  zone,
+		StartsAt:  storage.SQLiteTime{Time: startsAt},
+		NextRunAt: storage.SQLiteTime{Time: next},
+	}
+	var id, errCreate = storage.CreateRecurringSchedule(item)
+	if errCreate != nil {
+		return nil, errCreate
+	}
+	s.publishScheduled()
+	s.Wake()
+
+	var created, errGet = storage.GetRecurringSchedule(id)
+	if errGet != nil || created == nil {
+		return nil, fmt.Errorf("recurring send #%d not found after creating it: %v", id, errGet)
+	}
+	var result = recurringToPort(*created)
+	return &result, nil
+}
+
+// GetRecurringSchedules returns the recurring sends of the account
+func (s *ScheduleService) GetRecurringSchedules(ctx context.Context) ([]ports.RecurringSchedule, error) {
+	s.mu.RLock()
+	var account = s.account
+	s.mu.RUnlock()
+
+	if account == nil {
+		return nil, fmt.Errorf("no account set")
+	}
+
+	var list, err = storage.GetRecurringSchedules(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	var result = make([]ports.RecurringSchedule, len(list))
+	for i, r := range list {
+		result[i] = recurringToPort(r)
+	}
+	return result, nil
+}
+
+// CancelRecurring stops a recurring send. Occurrences already created stay
+// scheduled; the template goes back to the drafts.
+func (s *ScheduleService) CancelRecurring(ctx context.Context, id int64) error {
+	s.mu.RLock()
+	var account = s.account
+	s.mu.RUnlock()
+
+	var item, err = storage.GetRecurringSchedule(id)
+	if err != nil {
+		return err
+	}
+	if item == nil || account == nil || item.AccountID != account.ID {
+		return fmt.Errorf("recurring send #%d not found", id)
+	}
+
+	if err := storage.DeleteRecurringSchedule(id); err != nil {
+		return err
+	}
+	s.events.Publish(ports.BaseEvent{
+		EventType: ports.EventTypeDraftCancelled,
+		Time:      time.Now(),
+	})
+	return nil
+}
+
+// ProcessDueSchedules creates the due occurrences of recurring sends, then
+// sends the scheduled emails that are due
 func (s *ScheduleService) ProcessDueSchedules(ctx context.Context) (int, error) {
+	s.processing.Lock()
+	defer s.processing.Unlock()
+
+	if err := s.processRecurring(); err != nil {
+		return 0, err
+	}
+
 	var readyDrafts, err = storage.GetScheduledDraftsReady()
 	if err != nil {
 		return 0, err
@@ -104,8 +274,10 @@ func (s *ScheduleService) ProcessDueSchedules(ctx context.Context) (int, error)
 
 	var sent = 0
 	for _, draft := range readyDrafts {
-		// Mark as sending
-		storage.MarkDraftSending(draft.ID)
+		// Mark as sending (skipped if someone else got to it first)
+		if claimed, errClaim := storage.ClaimScheduledDraft(draft.ID); errClaim != nil || !claimed {
+			continue
+		}
 
 		// Get full draft for sending
 		var draftData, getErr = storage.GetDraftByID(draft.ID)
@@ -127,6 +299,186 @@ func (s *ScheduleService) ProcessDueSchedules(ctx context.Context) (int, error)
 	return sent, nil
 }
 
+// processRecurring clones the template of every recurring send that is due
+// into a scheduled draft. Occurrences missed while miau was closed are
+// skipped: only the latest one is sent.
+func (s *ScheduleService) processRecurring() error {
+	var now = s.now()
+	var due, err = storage.GetDueRecurringSchedules(now)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range due {
+		var r, errRule = rrule.Parse(item.Rule)
+		if errRule != nil {
+			log.Printf("[ScheduleService] Recurring send #%d has an invalid rule: %v", item.ID, errRule)
+			continue
+		}
+		var loc = zoneLocation(item.TimeZone)
+		var start = item.StartsAt.In(loc)
+
+		var occurrence = item.NextRunAt.In(loc)
+		var next = r.Next(start, occurrence)
+		for !next.IsZero() && !next.After(now) {
+			occurrence = next
+			next = r.Next(start, next)
+		}
+
+		var draftID, errAdd = storage.AddRecurringOccurrence(item.ID, item.NextRunAt.Time, occurrence, next)
+		if errAdd != nil {
+			log.Printf("[ScheduleService] Failed to create occurrence of recurring send #%d: %v", item.ID, errAdd)
+			continue
+		}
+		if draftID != 0 {
+			s.publishScheduled()
+		}
+	}
+	return nil
+}
+
+// Start runs ProcessDueSchedules in the background until ctx is done or
+// Stop is called
+func (s *ScheduleService) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return // already running
+	}
+
+	var workerCtx, cancel = context.WithCancel(ctx)
+	var done = make(chan struct{})
+	s.cancel = cancel
+	s.done = done
+
+	go s.run(workerCtx, done)
+}
+
+// Stop stops the worker and waits for it to exit
+func (s *ScheduleService) Stop() {
+	s.mu.Lock()
+	var cancel = s.cancel
+	var done = s.done
+	s.cancel = nil
+	s.done = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Wake makes the worker look for due schedules now
+func (s *ScheduleService) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the worker loop of Start
+func (s *ScheduleService) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		if _, err := s.ProcessDueSchedules(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[ScheduleService] Failed to process schedules: %v", err)
+		}
+
+		var timer = time.NewTimer(s.nextRound())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// nextRound returns how long the worker waits before processing again:
+// until the next recurring occurrence, at most schedulePollInterval
+func (s *ScheduleService) nextRound() time.Duration {
+	var wait = schedulePollInterval
+
+	var next, err = storage.NextRecurringRun()
+	if err != nil || next.IsZero() {
+		return wait
+	}
+	if until := next.Sub(s.now()); until < wait {
+		wait = max(until, 0)
+	}
+	return wait
+}
+
+// accountDraft returns a draft of the current account
+func (s *ScheduleService) accountDraft(draftID int64) (*storage.Draft, error) {
+	s.mu.RLock()
+	var account = s.account
+	s.mu.RUnlock()
+
+	if account == nil {
+		return nil, fmt.Errorf("no account set")
+	}
+	var draft, err = storage.GetDraftByID(draftID)
+	if err != nil || draft.AccountID != account.ID {
+		return nil, fmt.Errorf("draft #%d not found", draftID)
+	}
+	return draft, nil
+}
+
+// publishScheduled tells listeners the scheduled drafts changed
+func (s *ScheduleService) publishScheduled() {
+	s.events.Publish(ports.BaseEvent{
+		EventType: ports.EventTypeDraftScheduled,
+		Time:      time.Now(),
+	})
+}
+
+// recipientLocation returns the time zone stored on the contact of the
+// first recipient of the draft, and its name (nil when unknown)
+func recipientLocation(draft *storage.Draft) (*time.Location, string) {
+	var to = parseAddresses(draft.ToAddresses)
+	if len(to) == 0 {
+		return nil, ""
+	}
+	var address = to[0]
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		address = parsed.Address
+	}
+
+	var name, err = storage.GetContactTimeZone(draft.AccountID, address)
+	if err != nil || name == "" {
+		return nil, ""
+	}
+	var loc, errLoad = time.LoadLocation(name)
+	if errLoad != nil {
+		return nil, ""
+	}
+	return loc, name
+}
+
+// zoneLocation loads a stored time zone name ("" or unknown = local time)
+func zoneLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	var loc, err = time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// inLocation returns the wall clock time of t in loc, to the minute
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+}
+
 // calculateScheduleTime calculates the target time for a schedule preset
 func calculateScheduleTime(preset ports.SchedulePreset) time.Time {
 	var now = time.Now()
@@ -199,6 +551,29 @@ func draftToPort(d storage.Draft) ports.Draft {
 	return result
 }
 
+// recurringToPort converts a storage recurring send to ports
+func recurringToPort(r storage.RecurringSchedule) ports.RecurringSchedule {
+	var result = ports.RecurringSchedule{
+		ID:          r.ID,
+		DraftID:     r.DraftID,
+		ToAddresses: r.ToAddresses,
+		Subject:     r.Subject,
+		Rule:        r.Rule,
+		TimeZone:    r.TimeZone,
+		StartsAt:    r.StartsAt.In(zoneLocation(r.TimeZone)),
+		Occurrences: r.Occurrences,
+	}
+	if !r.NextRunAt.IsZero() {
+		var t = r.NextRunAt.In(zoneLocation(r.TimeZone))
+		result.NextRunAt = &t
+	}
+	if !r.LastRunAt.IsZero() {
+		var t = r.LastRunAt.In(zoneLocation(r.TimeZone))
+		result.LastRunAt = &t
+	}
+	return result
+}
+
 // nullStringValue returns the string value or empty string
 func nullStringValue(ns sql.NullString) string {
 	if ns.Valid {
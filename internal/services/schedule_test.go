@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/rrule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecurrencePresets(t *testing.T) {
+	var start = time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC) // Friday
+	var want = []time.Time{
+		time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, 11, 2, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 30, 17, 0, 0, 0, time.UTC),
+	}
+
+	for i, preset := range NewScheduleService(nil, nil, nil).GetRecurrencePresets() {
+		var r, err = rrule.Parse(preset.Rule)
+		if assert.NoError(t, err, preset.Label) {
+			assert.Equal(t, want[i], r.Next(start, start), preset.Label)
+		}
+	}
+}
+
+func TestInLocation(t *testing.T) {
+	var tokyo = time.FixedZone("JST", 9*60*60)
+	var local = time.Date(2026, 10, 19, 9, 0, 42, 0, time.UTC)
+
+	// Same wall clock time, in the recipient's zone
+	var sendAt = inLocation(local, tokyo)
+	assert.Equal(t, "2026-10-19 09:00 JST", sendAt.Format("2006-01-02 15:04 MST"))
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), sendAt.UTC())
+}
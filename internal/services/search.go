@@ -17,6 +17,9 @@ type SearchService struct {
 	imap    ports.IMAPPort
 	events  ports.EventBus
 	account *ports.AccountInfo
+
+	// Other accounts of a multi-account runtime, searched together
+	accounts accountSet
 }
 
 // NewSearchService creates a new SearchService
@@ -41,8 +44,16 @@ func (s *SearchService) SetAccount(account *ports.AccountInfo) {
 	s.account = account
 }
 
+// AddAccount registers an account of the multi-account runtime, searched
+// over its own connection
+func (s *SearchService) AddAccount(account *ports.AccountInfo, imap ports.IMAPPort) {
+	s.accounts.add(account, imap)
+}
+
 // Search performs a hybrid search: local DB + IMAP server-side
-// This combines fast local results with full-text server search
+// This combines fast local results with full-text server search.
+// With several accounts registered, all of them are searched concurrently
+// and each result carries the account it belongs to.
 func (s *SearchService) Search(ctx context.Context, query string, limit int) (*ports.SearchResult, error) {
 	s.mu.RLock()
 	var account = s.account
@@ -53,6 +64,69 @@ func (s *SearchService) Search(ctx context.Context, query string, limit int) (*p
 		return nil, fmt.Errorf("no account set")
 	}
 
+	var accounts = s.accounts.list()
+	var emails []ports.EmailMetadata
+
+	if len(accounts) <= 1 {
+		var found, err = s.searchAccount(ctx, account, s.accounts.imapFor(account.ID, imapClient), query, limit)
+		if err != nil {
+			return nil, err
+		}
+		emails = found
+	} else {
+		var results = make([][]ports.EmailMetadata, len(accounts))
+		var errs = make([]error, len(accounts))
+		var wg sync.WaitGroup
+		for i, acc := range accounts {
+			wg.Add(1)
+			go func(i int, acc *ports.AccountInfo) {
+				defer wg.Done()
+				results[i], errs[i] = s.searchAccount(ctx, acc, s.accounts.imapFor(acc.ID, nil), query, limit)
+				for j := range results[i] {
+					results[i][j].AccountID = acc.ID
+					results[i][j].AccountEmail = acc.Email
+				}
+			}(i, acc)
+		}
+		wg.Wait()
+
+		// One failing account doesn't hide the results of the others
+		var failed = 0
+		for i, found := range results {
+			if errs[i] != nil {
+				log.Printf("[search] %s: %v", accounts[i].Email, errs[i])
+				failed++
+				continue
+			}
+			emails = append(emails, found...)
+		}
+		if failed == len(accounts) {
+			return nil, errs[0]
+		}
+	}
+
+	// Group by thread: show only the most recent email per thread
+	var threadedEmails = groupByThread(emails)
+
+	// Sort by date (most recent first)
+	sort.Slice(threadedEmails, func(i, j int) bool {
+		return threadedEmails[i].Date.After(threadedEmails[j].Date)
+	})
+
+	// Limit results
+	if len(threadedEmails) > limit {
+		threadedEmails = threadedEmails[:limit]
+	}
+
+	return &ports.SearchResult{
+		Emails:     threadedEmails,
+		TotalCount: len(threadedEmails),
+		Query:      query,
+	}, nil
+}
+
+// searchAccount runs the hybrid search on one account
+func (s *SearchService) searchAccount(ctx context.Context, account *ports.AccountInfo, imapClient ports.IMAPPort, query string, limit int) ([]ports.EmailMetadata, error) {
 	// 1. Local search (fast, but limited to indexed/downloaded content)
 	var localEmails, err = s.storage.SearchEmails(ctx, account.ID, query, limit)
 	if err != nil {
@@ -197,24 +271,7 @@ func (s *SearchService) Search(ctx context.Context, query string, limit int) (*p
 		}
 	}
 
-	// Group by thread: show only the most recent email per thread
-	var threadedEmails = groupByThread(localEmails)
-
-	// Sort by date (most recent first)
-	sort.Slice(threadedEmails, func(i, j int) bool {
-		return threadedEmails[i].Date.After(threadedEmails[j].Date)
-	})
-
-	// Limit results
-	if len(threadedEmails) > limit {
-		threadedEmails = threadedEmails[:limit]
-	}
-
-	return &ports.SearchResult{
-		Emails:     threadedEmails,
-		TotalCount: len(threadedEmails),
-		Query:      query,
-	}, nil
+	return localEmails, nil
 }
 
 // groupByThread groups emails by thread_id, keeping only the most recent per thread
@@ -232,9 +289,10 @@ func groupByThread(emails []ports.EmailMetadata) []ports.EmailMetadata {
 	var threads = make(map[string]*threadInfo)
 
 	for _, e := range emails {
-		// Use thread_id if available, otherwise use email ID as unique key
-		var key = e.ThreadID
-		if key == "" {
+		// Use thread_id if available, otherwise use email ID as unique key.
+		// Threads never span accounts.
+		var key = fmt.Sprintf("%d/%s", e.AccountID, e.ThreadID)
+		if e.ThreadID == "" {
 			key = fmt.Sprintf("_id_%d", e.ID)
 		}
 
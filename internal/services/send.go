@@ -21,6 +21,24 @@ type SendService struct {
 	sendMethod      ports.SendMethod
 	signatureCache  string
 	signatureCached bool
+	pgp             ports.PGPService
+	smime           ports.SMIMEService
+	outbox          *OutboxService
+	undo            ports.UndoService
+	delay           time.Duration // undo-send window
+	followUps       *FollowUpService
+
+	// Other accounts of a multi-account runtime, used to reply from the
+	// account the original email was received on
+	identities map[int64]*sendIdentity
+}
+
+// sendIdentity is how one account sends email
+type sendIdentity struct {
+	account  *ports.AccountInfo
+	smtp     ports.SMTPPort
+	gmailAPI ports.GmailAPIPort
+	method   ports.SendMethod
 }
 
 // NewSendService creates a new SendService
@@ -41,6 +59,51 @@ func (s *SendService) SetAccount(account *ports.AccountInfo) {
 	s.account = account
 }
 
+// SetSMTP sets the SMTP adapter (used when switching accounts)
+func (s *SendService) SetSMTP(smtp ports.SMTPPort) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.smtp = smtp
+}
+
+// AddIdentity registers an account of the multi-account runtime that
+// emails can be sent from (see SendRequest.AccountID)
+func (s *SendService) AddIdentity(account *ports.AccountInfo, smtp ports.SMTPPort, gmailAPI ports.GmailAPIPort, method ports.SendMethod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.identities == nil {
+		s.identities = make(map[int64]*sendIdentity)
+	}
+	s.identities[account.ID] = &sendIdentity{account: account, smtp: smtp, gmailAPI: gmailAPI, method: method}
+}
+
+// identityFor returns the identity a request must be sent from, or nil for
+// the current account. Replies go out from the account of the original email.
+func (s *SendService) identityFor(ctx context.Context, req *ports.SendRequest) *sendIdentity {
+	s.mu.RLock()
+	var current = s.account
+	var hasIdentities = len(s.identities) > 0
+	s.mu.RUnlock()
+
+	if !hasIdentities {
+		return nil
+	}
+
+	var accountID = req.AccountID
+	if accountID == 0 && req.ReplyToEmailID != nil {
+		if original, err := s.storage.GetEmail(ctx, *req.ReplyToEmailID); err == nil {
+			accountID = original.AccountID
+		}
+	}
+	if accountID == 0 || (current != nil && accountID == current.ID) {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.identities[accountID]
+}
+
 // SetSendMethod sets the send method (SMTP or Gmail API)
 func (s *SendService) SetSendMethod(method ports.SendMethod) {
 	s.mu.Lock()
@@ -58,13 +121,254 @@ func (s *SendService) SetGmailAPI(gmailAPI ports.GmailAPIPort) {
 	s.signatureCached = false
 }
 
-// Send sends an email immediately
+// SetPGP sets the PGP service used to sign and encrypt outgoing email
+func (s *SendService) SetPGP(pgp ports.PGPService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pgp = pgp
+}
+
+// SetSMIME sets the S/MIME service used to sign and encrypt outgoing email
+func (s *SendService) SetSMIME(smime ports.SMIMEService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.smime = smime
+}
+
+// SetOutbox sets the outbox that takes emails the server couldn't be
+// reached for
+func (s *SendService) SetOutbox(outbox *OutboxService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outbox = outbox
+}
+
+// SetSendDelay sets the undo-send window: emails wait that long in the
+// outbox before going out and can be cancelled meanwhile (0 sends right away)
+func (s *SendService) SetSendDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+// SetUndo sets the undo service that held emails are recorded in, so that
+// Undo cancels the send
+func (s *SendService) SetUndo(undo ports.UndoService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.undo = undo
+}
+
+// SetFollowUps sets the follow-up service that tracks emails sent with a
+// follow-up (SendRequest.FollowUpAfter, or set on their draft)
+func (s *SendService) SetFollowUps(followUps *FollowUpService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.followUps = followUps
+}
+
+// followUpDraft sets the follow-up asked for in req on the draft it was
+// queued as
+func (s *SendService) followUpDraft(ctx context.Context, draftID int64, req *ports.SendRequest) {
+	s.mu.RLock()
+	var followUps = s.followUps
+	s.mu.RUnlock()
+
+	if followUps == nil || req.FollowUpAfter <= 0 {
+		return
+	}
+	if err := followUps.FollowUpDraft(ctx, draftID, req.FollowUpAfter); err != nil {
+		log.Printf("[SendService] Failed to set follow-up on draft %d: %v", draftID, err)
+	}
+}
+
+// followUpSent starts the follow-ups of an email that was just sent: the
+// ones set on its draft (draftID != 0), else the one asked for in req
+func (s *SendService) followUpSent(ctx context.Context, draftID int64, req *ports.SendRequest, result *ports.SendResult) {
+	s.mu.RLock()
+	var followUps = s.followUps
+	s.mu.RUnlock()
+
+	if followUps == nil || result == nil {
+		return
+	}
+
+	var err error
+	switch {
+	case draftID != 0:
+		err = followUps.draftSent(draftID, result)
+	case req != nil && req.FollowUpAfter > 0:
+		if account := s.accountFor(ctx, req); account != nil {
+			err = followUps.track(account.ID, req, result)
+		}
+	}
+	if err != nil {
+		log.Printf("[SendService] Failed to start follow-up: %v", err)
+	}
+}
+
+// holding reports whether sends are held for an undo-send window
+func (s *SendService) holding() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.delay > 0 && s.outbox != nil
+}
+
+// hold puts a request in the outbox until the undo-send window is over, as
+// draft draftID (0 creates one), and records it in the undo history
+func (s *SendService) hold(ctx context.Context, draftID int64, req *ports.SendRequest, record bool) (*ports.SendResult, error) {
+	s.mu.RLock()
+	var outbox = s.outbox
+	var undo = s.undo
+	var delay = s.delay
+	s.mu.RUnlock()
+
+	if outbox == nil {
+		return nil, fmt.Errorf("no outbox set")
+	}
+
+	var sendAt = time.Now().Add(delay).Truncate(time.Second)
+	var item *ports.OutboxItem
+	var err error
+	if draftID == 0 {
+		item, err = outbox.queueRequest(ctx, req, sendAt)
+	} else {
+		item, err = outbox.queueDraft(draftID, req, sendAt)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to hold email for undo send: %w", err)
+	}
+
+	if record && undo != nil {
+		if err := undo.RecordOperation(ctx, NewSendOperation(item.ID, item.Subject, s)); err != nil {
+			log.Printf("[SendService] Failed to record send for undo: %v", err)
+		}
+	}
+	return &ports.SendResult{Pending: true, OutboxID: item.ID, SendAt: sendAt}, nil
+}
+
+// CancelPendingSend stops an email that hasn't gone out yet, held for the
+// undo-send window or waiting in the outbox, and turns it back into a draft
+func (s *SendService) CancelPendingSend(ctx context.Context, id int64) error {
+	s.mu.RLock()
+	var outbox = s.outbox
+	s.mu.RUnlock()
+
+	if outbox == nil {
+		return fmt.Errorf("no outbox set")
+	}
+	return outbox.Cancel(ctx, id)
+}
+
+// accountFor returns the account a request is sent from
+func (s *SendService) accountFor(ctx context.Context, req *ports.SendRequest) *ports.AccountInfo {
+	if id := s.identityFor(ctx, req); id != nil {
+		return id.account
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.account
+}
+
+// protect returns a copy of req whose Protect signs and/or encrypts the
+// message, following the request flags and the account settings. Accounts
+// with an S/MIME identity use S/MIME; the others use PGP/MIME.
+func (s *SendService) protect(ctx context.Context, account *ports.AccountInfo, req *ports.SendRequest) (*ports.SendRequest, error) {
+	s.mu.RLock()
+	var pgp = s.pgp
+	var smime = s.smime
+	s.mu.RUnlock()
+
+	var recipients []string
+	recipients = append(recipients, req.To...)
+	recipients = append(recipients, req.Cc...)
+	recipients = append(recipients, req.Bcc...)
+
+	var protect func(raw []byte) ([]byte, error)
+	var err error
+	if smime != nil {
+		if protect, err = smime.Protector(ctx, account.Email, recipients, req.Sign, req.Encrypt); err != nil {
+			return nil, err
+		}
+	}
+	if protect == nil {
+		if pgp == nil {
+			if req.Sign || req.Encrypt {
+				return nil, fmt.Errorf("PGP not configured")
+			}
+			return req, nil
+		}
+		if protect, err = pgp.Protector(ctx, account.Email, recipients, req.Sign, req.Encrypt); err != nil {
+			return nil, err
+		}
+	}
+	if protect == nil {
+		return req, nil
+	}
+	var protected = *req
+	protected.Protect = protect
+	return &protected, nil
+}
+
+// Send sends an email. With an undo-send window set, the email is held in
+// the outbox first and the result has Pending set. When the server can't be
+// reached, the email goes to the outbox instead and the result has Queued set.
 func (s *SendService) Send(ctx context.Context, req *ports.SendRequest) (*ports.SendResult, error) {
+	if s.holding() {
+		return s.hold(ctx, 0, req, true)
+	}
+
+	var result, err = s.deliver(ctx, req)
+	if err != nil && IsConnectivityError(err) {
+		if queued := s.queue(ctx, 0, req, err); queued != nil {
+			return queued, nil
+		}
+	}
+	if err == nil {
+		s.followUpSent(ctx, 0, req, result)
+	}
+	return result, err
+}
+
+// queue puts a request that failed with err in the outbox, as draft draftID
+// (0 creates one). Returns nil when there's no outbox or queueing failed.
+func (s *SendService) queue(ctx context.Context, draftID int64, req *ports.SendRequest, err error) *ports.SendResult {
+	s.mu.RLock()
+	var outbox = s.outbox
+	s.mu.RUnlock()
+
+	if outbox == nil {
+		return nil
+	}
+
+	var item *ports.OutboxItem
+	var queueErr error
+	if draftID == 0 {
+		item, queueErr = outbox.Queue(ctx, req)
+	} else {
+		item, queueErr = outbox.QueueDraft(ctx, draftID, req)
+	}
+	if queueErr != nil {
+		log.Printf("[SendService] Failed to queue email in the outbox: %v", queueErr)
+		return nil
+	}
+	log.Printf("[SendService] Server unreachable, email queued in the outbox: %v", err)
+	return &ports.SendResult{Queued: true, OutboxID: item.ID}
+}
+
+// deliver sends an email right away, without falling back to the outbox
+func (s *SendService) deliver(ctx context.Context, req *ports.SendRequest) (*ports.SendResult, error) {
 	s.mu.RLock()
 	var account = s.account
 	var method = s.sendMethod
+	var smtp = s.smtp
+	var gmailAPI = s.gmailAPI
 	s.mu.RUnlock()
 
+	if id := s.identityFor(ctx, req); id != nil {
+		account, method, smtp, gmailAPI = id.account, id.method, id.smtp, id.gmailAPI
+	}
+
 	if account == nil {
 		return nil, fmt.Errorf("no account set")
 	}
@@ -77,25 +381,33 @@ func (s *SendService) Send(ctx context.Context, req *ports.SendRequest) (*ports.
 	var result *ports.SendResult
 	var err error
 
+	if req, err = s.protect(ctx, account, req); err != nil {
+		s.events.Publish(ports.BaseEvent{
+			EventType: ports.EventTypeSendError,
+			Time:      time.Now(),
+		})
+		return nil, err
+	}
+
 	switch method {
 	case ports.SendMethodGmailAPI:
-		if s.gmailAPI == nil {
+		if gmailAPI == nil {
 			s.events.Publish(ports.BaseEvent{
 				EventType: ports.EventTypeSendError,
 				Time:      time.Now(),
 			})
 			return nil, fmt.Errorf("Gmail API not configured - check OAuth2 setup")
 		}
-		result, err = s.gmailAPI.Send(ctx, req)
+		result, err = gmailAPI.Send(ctx, req)
 	default:
-		if s.smtp == nil {
+		if smtp == nil {
 			s.events.Publish(ports.BaseEvent{
 				EventType: ports.EventTypeSendError,
 				Time:      time.Now(),
 			})
 			return nil, fmt.Errorf("SMTP not configured - check account settings")
 		}
-		result, err = s.smtp.Send(ctx, req)
+		result, err = smtp.Send(ctx, req)
 	}
 
 	if err != nil {
@@ -121,13 +433,17 @@ func (s *SendService) Send(ctx context.Context, req *ports.SendRequest) (*ports.
 	return result, nil
 }
 
-// SendDraft sends a draft
+// SendDraft sends a draft, held for the undo-send window like Send
 func (s *SendService) SendDraft(ctx context.Context, draftID int64) (*ports.SendResult, error) {
 	var draft, err = s.storage.GetDraft(ctx, draftID)
 	if err != nil {
 		return nil, fmt.Errorf("draft not found: %w", err)
 	}
 
+	if s.holding() {
+		return s.hold(ctx, draftID, nil, true)
+	}
+
 	// Update draft status to sending
 	s.storage.UpdateDraftStatus(ctx, draftID, ports.DraftStatusSending)
 
@@ -145,14 +461,20 @@ func (s *SendService) SendDraft(ctx context.Context, draftID int64) (*ports.Send
 		Classification: draft.Classification,
 	}
 
-	var result, sendErr = s.Send(ctx, req)
+	var result, sendErr = s.deliver(ctx, req)
 	if sendErr != nil {
+		if IsConnectivityError(sendErr) {
+			if queued := s.queue(ctx, draftID, req, sendErr); queued != nil {
+				return queued, nil
+			}
+		}
 		s.storage.UpdateDraftStatus(ctx, draftID, ports.DraftStatusFailed)
 		return nil, sendErr
 	}
 
 	// Update draft status to sent
 	s.storage.UpdateDraftStatus(ctx, draftID, ports.DraftStatusSent)
+	s.followUpSent(ctx, draftID, req, result)
 
 	return result, nil
 }
@@ -1,11 +1,15 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"testing"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/opik/miau/internal/ports"
 	"github.com/opik/miau/internal/testutil"
 	"github.com/opik/miau/internal/testutil/mocks"
@@ -410,6 +414,144 @@ func TestSendService_Send_TracksEmailForBounceDetection(t *testing.T) {
 	mockStorage.AssertCalled(t, "TrackSentEmail", mock.Anything, int64(1), "<unique-msg-id@test.com>", "recipient@example.com", "Test Subject")
 }
 
+func TestSendService_Send_ReplyUsesAccountOfOriginal(t *testing.T) {
+	// Arrange
+	var mockSMTP = new(mocks.SMTPPort)
+	var otherSMTP = new(mocks.SMTPPort)
+	var mockGmail = new(mocks.GmailAPIPort)
+	var mockStorage = new(mocks.StoragePort)
+	var mockEvents = new(mocks.EventBus)
+
+	var svc = NewSendService(mockSMTP, mockGmail, mockStorage, mockEvents)
+	svc.SetAccount(testutil.TestAccount())
+	svc.SetSendMethod(ports.SendMethodSMTP)
+	svc.AddIdentity(testutil.TestAccount(), mockSMTP, nil, ports.SendMethodSMTP)
+	svc.AddIdentity(testutil.TestAccount2(), otherSMTP, nil, ports.SendMethodSMTP)
+
+	// The original email was received on the second account
+	var req = testutil.TestSendRequestReply()
+	var original = testutil.TestEmailContent()
+	original.AccountID = 2
+	var result = testutil.TestSendResult()
+
+	mockEvents.On("Publish", mock.Anything).Return()
+	mockStorage.On("GetEmail", mock.Anything, int64(1)).Return(original, nil)
+	otherSMTP.On("Send", mock.Anything, req).Return(result, nil)
+	mockStorage.On("TrackSentEmail", mock.Anything, int64(2), result.MessageID, req.To[0], req.Subject).Return(nil)
+
+	// Act
+	var _, err = svc.Send(context.Background(), req)
+
+	// Assert
+	assert.NoError(t, err)
+	otherSMTP.AssertExpectations(t)
+	mockSMTP.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestSendService_Send_ExplicitAccount(t *testing.T) {
+	// Arrange
+	var mockSMTP = new(mocks.SMTPPort)
+	var mockGmail = new(mocks.GmailAPIPort)
+	var otherGmail = new(mocks.GmailAPIPort)
+	var mockStorage = new(mocks.StoragePort)
+	var mockEvents = new(mocks.EventBus)
+
+	var svc = NewSendService(mockSMTP, mockGmail, mockStorage, mockEvents)
+	svc.SetAccount(testutil.TestAccount())
+	svc.SetSendMethod(ports.SendMethodSMTP)
+	svc.AddIdentity(testutil.TestAccount2(), nil, otherGmail, ports.SendMethodGmailAPI)
+
+	var req = testutil.TestSendRequest()
+	req.AccountID = 2
+	var result = testutil.TestSendResult()
+
+	mockEvents.On("Publish", mock.Anything).Return()
+	otherGmail.On("Send", mock.Anything, req).Return(result, nil)
+	mockStorage.On("TrackSentEmail", mock.Anything, int64(2), result.MessageID, req.To[0], req.Subject).Return(nil)
+
+	// Act
+	var _, err = svc.Send(context.Background(), req)
+
+	// Assert
+	assert.NoError(t, err)
+	otherGmail.AssertExpectations(t)
+	mockSMTP.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+}
+
+func TestSendService_Send_PGPMissingRecipientKey(t *testing.T) {
+	// Arrange
+	var mockSMTP = new(mocks.SMTPPort)
+	var mockGmail = new(mocks.GmailAPIPort)
+	var mockStorage = new(mocks.StoragePort)
+	var mockEvents = new(mocks.EventBus)
+
+	var svc = NewSendService(mockSMTP, mockGmail, mockStorage, mockEvents)
+	svc.SetAccount(testutil.TestAccount())
+	svc.SetPGP(NewPGPService(t.TempDir()))
+
+	var req = testutil.TestSendRequest()
+	req.Encrypt = true
+
+	mockEvents.On("Publish", mock.Anything).Return()
+
+	// Act
+	var result, err = svc.Send(context.Background(), req)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "recipient@example.com")
+	assert.Nil(t, result)
+	mockSMTP.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+}
+
+func TestSendService_Send_PGPSignedByAccountDefault(t *testing.T) {
+	// Arrange
+	var mockSMTP = new(mocks.SMTPPort)
+	var mockGmail = new(mocks.GmailAPIPort)
+	var mockStorage = new(mocks.StoragePort)
+	var mockEvents = new(mocks.EventBus)
+
+	var entity, err = openpgp.NewEntity("Test User", "", "test@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	assert.NoError(t, err)
+	var armored bytes.Buffer
+	var w, _ = armor.Encode(&armored, openpgp.PrivateKeyType, nil)
+	assert.NoError(t, entity.SerializePrivateWithoutSigning(w, nil))
+	w.Close()
+
+	var pgp = NewPGPService(t.TempDir())
+	_, err = pgp.ImportKeys(context.Background(), armored.Bytes())
+	assert.NoError(t, err)
+	pgp.SetAccount("test@example.com", PGPAccount{Sign: true})
+
+	var svc = NewSendService(mockSMTP, mockGmail, mockStorage, mockEvents)
+	svc.SetAccount(testutil.TestAccount())
+	svc.SetPGP(pgp)
+
+	var req = testutil.TestSendRequest()
+	var result = testutil.TestSendResult()
+	var sent *ports.SendRequest
+
+	mockEvents.On("Publish", mock.Anything).Return()
+	mockSMTP.On("Send", mock.Anything, mock.MatchedBy(func(r *ports.SendRequest) bool {
+		sent = r
+		return r.Protect != nil
+	})).Return(result, nil)
+	mockStorage.On("TrackSentEmail", mock.Anything, int64(1), result.MessageID, req.To[0], req.Subject).Return(nil)
+
+	// Act
+	_, err = svc.Send(context.Background(), req)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Nil(t, req.Protect, "the caller's request must not be modified")
+	var raw, protectErr = sent.Protect([]byte("From: test@example.com\r\nSubject: Hi\r\nContent-Type: text/plain\r\n\r\nHello\r\n"))
+	assert.NoError(t, protectErr)
+	assert.Contains(t, string(raw), "Autocrypt: addr=test@example.com")
+	assert.Contains(t, string(raw), "multipart/signed")
+	mockSMTP.AssertExpectations(t)
+}
+
 func TestParseAddresses(t *testing.T) {
 	tests := []struct {
 		name     string
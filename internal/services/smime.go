@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/smime"
+)
+
+// SMIMEAccount is the S/MIME setup of one account
+type SMIMEAccount struct {
+	Identity string // PKCS#12 file with the key, certificate and chain
+	Password string
+	CA       string // PEM file with extra trusted CAs
+	Sign     bool   // sign every outgoing email
+	Encrypt  bool   // encrypt every outgoing email
+}
+
+// SMIMEService implements ports.SMIMEService. Correspondents' certificates
+// live in a store under dir; identities, trusted roots and the store are
+// loaded on first use.
+type SMIMEService struct {
+	mu       sync.Mutex
+	dir      string
+	accounts map[string]SMIMEAccount // by lowercased email
+	state    *smimeState
+}
+
+// smimeState is what the service loads once
+type smimeState struct {
+	store      *smime.Store
+	roots      *x509.CertPool
+	identities map[string]*smime.Identity // by lowercased email
+	failures   map[string]error           // identities that failed to load
+}
+
+// NewSMIMEService creates a new SMIMEService for the certificate store in dir
+func NewSMIMEService(dir string) *SMIMEService {
+	return &SMIMEService{
+		dir:      dir,
+		accounts: make(map[string]SMIMEAccount),
+	}
+}
+
+// SetAccount registers the S/MIME setup of an account
+func (s *SMIMEService) SetAccount(email string, settings SMIMEAccount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[strings.ToLower(email)] = settings
+	s.state = nil
+}
+
+// load returns the loaded state, loading it the first time. Accounts whose
+// identity can't be loaded are logged and skipped, so one broken PKCS#12
+// file doesn't disable S/MIME for the others.
+func (s *SMIMEService) load() (*smimeState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != nil {
+		return s.state, nil
+	}
+
+	var store, err = smime.OpenStore(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open S/MIME certificate store: %w", err)
+	}
+	var roots, poolErr = x509.SystemCertPool()
+	if poolErr != nil {
+		roots = x509.NewCertPool()
+	}
+
+	var state = &smimeState{
+		store:      store,
+		roots:      roots,
+		identities: make(map[string]*smime.Identity),
+		failures:   make(map[string]error),
+	}
+	for email, settings := range s.accounts {
+		var id, err = smime.LoadIdentity(settings.Identity, settings.Password)
+		if err != nil {
+			log.Printf("[SMIMEService] Failed to load identity of %s: %v", email, err)
+			state.failures[email] = err
+			continue
+		}
+		state.identities[email] = id
+
+		if settings.CA != "" {
+			var data, err = os.ReadFile(settings.CA)
+			if err == nil {
+				var certs []*x509.Certificate
+				if certs, err = smime.ParseCertificates(data); err == nil {
+					for _, cert := range certs {
+						roots.AddCert(cert)
+					}
+				}
+			}
+			if err != nil {
+				log.Printf("[SMIMEService] Failed to load CAs of %s: %v", email, err)
+			}
+		}
+	}
+	s.state = state
+	return state, nil
+}
+
+// ListCertificates returns the account identities, then the store
+func (s *SMIMEService) ListCertificates(ctx context.Context) ([]ports.SMIMECertificate, error) {
+	var state, err = s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var own = make([]smime.Certificate, 0, len(state.identities))
+	for _, id := range state.identities {
+		own = append(own, smime.Describe(id.Certificate))
+	}
+	sort.Slice(own, func(i, j int) bool { return own[i].Subject < own[j].Subject })
+
+	var result = toSMIMECertificates(own, true)
+	return append(result, toSMIMECertificates(state.store.Certificates(), false)...), nil
+}
+
+// ImportCertificates adds correspondents' certificates to the store
+func (s *SMIMEService) ImportCertificates(ctx context.Context, data []byte) ([]ports.SMIMECertificate, error) {
+	var state, err = s.load()
+	if err != nil {
+		return nil, err
+	}
+	added, err := state.store.Import(data)
+	if err != nil {
+		return nil, err
+	}
+	return toSMIMECertificates(added, false), nil
+}
+
+// DeleteCertificate removes a certificate from the store
+func (s *SMIMEService) DeleteCertificate(ctx context.Context, fingerprint string) error {
+	var state, err = s.load()
+	if err != nil {
+		return err
+	}
+	return state.store.Delete(fingerprint)
+}
+
+// Protector returns the S/MIME transform for an email sent from the given
+// address, or nil when the account has no S/MIME setup or nothing to do.
+// Encrypted copies are also encrypted to the sender, so the Sent folder
+// stays readable.
+func (s *SMIMEService) Protector(ctx context.Context, from string, recipients []string, sign, encrypt bool) (func(raw []byte) ([]byte, error), error) {
+	s.mu.Lock()
+	var settings, configured = s.accounts[strings.ToLower(from)]
+	s.mu.Unlock()
+	if !configured {
+		return nil, nil
+	}
+
+	sign = sign || settings.Sign
+	encrypt = encrypt || settings.Encrypt
+	if !sign && !encrypt {
+		return nil, nil
+	}
+
+	var state, err = s.load()
+	if err != nil {
+		return nil, err
+	}
+	var id = state.identities[strings.ToLower(from)]
+	if id == nil {
+		return nil, fmt.Errorf("S/MIME identity for %s: %w", from, state.failures[strings.ToLower(from)])
+	}
+
+	var to []*x509.Certificate
+	if encrypt {
+		var list, missing = state.store.Recipients(recipients)
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("no S/MIME certificate for %s", strings.Join(missing, ", "))
+		}
+		to = list
+		if id.Certificate.PublicKeyAlgorithm == x509.RSA {
+			to = append(to, id.Certificate)
+		}
+	}
+
+	return func(raw []byte) ([]byte, error) {
+		var err error
+		if sign {
+			if raw, err = smime.Sign(raw, id); err != nil {
+				return nil, err
+			}
+		}
+		if encrypt {
+			return smime.Encrypt(raw, to)
+		}
+		return raw, nil
+	}, nil
+}
+
+// Open decrypts and verifies a raw email with the account identities and
+// stores the certificate of a valid signer, so replies can be encrypted
+func (s *SMIMEService) Open(ctx context.Context, raw []byte) (*ports.OpenedMessage, error) {
+	var state, err = s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids = make([]*smime.Identity, 0, len(state.identities))
+	for _, id := range state.identities {
+		ids = append(ids, id)
+	}
+	res, err := smime.Open(raw, ids, state.roots)
+	if err != nil {
+		return nil, err
+	}
+
+	var opened = &ports.OpenedMessage{Raw: res.Raw}
+	if res.Encrypted || res.Signature != smime.StatusNone {
+		opened.Security = &ports.SecurityInfo{
+			Protocol:  ports.ProtocolSMIME,
+			Encrypted: res.Encrypted,
+			Signature: ports.SignatureStatus(res.Signature),
+			Signer:    res.Signer,
+			Chain:     ports.ChainStatus(res.Chain),
+			Error:     res.Error,
+		}
+	}
+	if res.Certificate != nil {
+		opened.Security.KeyID = smime.Fingerprint(res.Certificate)
+		if res.Signature == smime.StatusValid {
+			if _, err := state.store.Add(res.Certificate); err != nil {
+				log.Printf("[SMIMEService.Open] Failed to store signer certificate: %v", err)
+			}
+		}
+	}
+	return opened, nil
+}
+
+func toSMIMECertificates(certs []smime.Certificate, own bool) []ports.SMIMECertificate {
+	var result = make([]ports.SMIMECertificate, 0, len(certs))
+	for _, c := range certs {
+		result = append(result, ports.SMIMECertificate{
+			Fingerprint: c.Fingerprint,
+			Subject:     c.Subject,
+			Issuer:      c.Issuer,
+			Emails:      c.Emails,
+			NotBefore:   c.NotBefore,
+			NotAfter:    c.NotAfter,
+			Own:         own,
+		})
+	}
+	return result
+}
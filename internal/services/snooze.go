@@ -145,13 +145,7 @@ func (s *SnoozeService) ProcessDueSnoozes(ctx context.Context) (int, error) {
 
 	var processed = 0
 	for _, snooze := range dueSnoozes {
-		// Mark email as unread
-		if err := storage.MarkEmailUnread(snooze.EmailID); err != nil {
-			continue
-		}
-
-		// Bump email date to appear at top
-		if err := storage.BumpEmailDate(snooze.EmailID); err != nil {
+		if err := resurface(snooze.EmailID); err != nil {
 			continue
 		}
 
@@ -172,6 +166,15 @@ func (s *SnoozeService) ProcessDueSnoozes(ctx context.Context) (int, error) {
 	return processed, nil
 }
 
+// resurface brings an email back as unread at the top of the list, like a
+// snooze that's due
+func resurface(emailID int64) error {
+	if err := storage.MarkEmailUnread(emailID); err != nil {
+		return err
+	}
+	return storage.BumpEmailDate(emailID)
+}
+
 // GetSnoozePresets returns all available presets with calculated times
 func (s *SnoozeService) GetSnoozePresets() []ports.SnoozePresetInfo {
 	return []ports.SnoozePresetInfo{
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/opik/miau/internal/ports"
 	"github.com/opik/miau/internal/storage"
@@ -20,16 +21,35 @@ var EssentialFolders = []string{
 
 // SyncService implements ports.SyncService
 type SyncService struct {
-	mu       sync.RWMutex
-	imap     ports.IMAPPort
-	storage  ports.StoragePort
-	events   ports.EventBus
-	status   ports.ConnectionStatus
-	account  *ports.AccountInfo
-	folders  map[string]*ports.Folder
-	config   ports.SyncConfig
+	mu      sync.RWMutex
+	imap    ports.IMAPPort
+	storage ports.StoragePort
+	events  ports.EventBus
+	status  ports.ConnectionStatus
+	account *ports.AccountInfo
+	folders map[string]*ports.Folder
+	config  ports.SyncConfig
+
+	// Push sync (IDLE) runs on its own connection, since a connection in
+	// IDLE can't issue other commands
+	idle        ports.IMAPPort
+	idleCancel  context.CancelFunc
+	idleDone    chan struct{}
+	idleBackoff time.Duration // first reconnect delay, doubled up to idleMaxBackoff
+
+	// Filter rules run on new INBOX emails (nil disables them)
+	rules *RuleService
+
+	// Rates new emails for phishing/spoofing (nil disables it)
+	risk ports.RiskService
 }
 
+// idleFolder is the folder watched by push sync
+const idleFolder = "INBOX"
+
+// idleMaxBackoff caps the reconnect delay of the IDLE loop
+const idleMaxBackoff = 5 * time.Minute
+
 // NewSyncService creates a new SyncService
 func NewSyncService(imap ports.IMAPPort, storage ports.StoragePort, events ports.EventBus) *SyncService {
 	return &SyncService{
@@ -39,6 +59,8 @@ func NewSyncService(imap ports.IMAPPort, storage ports.StoragePort, events ports
 		status:  ports.ConnectionStatusDisconnected,
 		folders: make(map[string]*ports.Folder),
 		config:  ports.DefaultSyncConfig(),
+
+		idleBackoff: time.Second,
 	}
 }
 
@@ -63,6 +85,20 @@ func (s *SyncService) SetAccount(account *ports.AccountInfo) {
 	s.account = account
 }
 
+// SetRules sets the rule service applied to new INBOX emails
+func (s *SyncService) SetRules(rules *RuleService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = rules
+}
+
+// SetRisk sets the risk service that rates stored emails
+func (s *SyncService) SetRisk(risk ports.RiskService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.risk = risk
+}
+
 // SetIMAPAdapter updates the IMAP adapter (used when switching accounts)
 func (s *SyncService) SetIMAPAdapter(imap ports.IMAPPort) {
 	s.mu.Lock()
@@ -72,6 +108,26 @@ func (s *SyncService) SetIMAPAdapter(imap ports.IMAPPort) {
 	s.folders = make(map[string]*ports.Folder)
 }
 
+// SetIdleAdapter sets the dedicated IMAP connection used for push sync.
+// If push sync is running it is restarted on the new adapter.
+func (s *SyncService) SetIdleAdapter(imap ports.IMAPPort) {
+	s.mu.RLock()
+	var wasIdling = s.idleCancel != nil
+	s.mu.RUnlock()
+
+	if wasIdling {
+		s.StopIdle()
+	}
+
+	s.mu.Lock()
+	s.idle = imap
+	s.mu.Unlock()
+
+	if wasIdling && imap != nil {
+		s.StartIdle(context.Background())
+	}
+}
+
 // Connect establishes connection to the email server
 func (s *SyncService) Connect(ctx context.Context) error {
 	s.mu.Lock()
@@ -104,6 +160,8 @@ func (s *SyncService) Connect(ctx context.Context) error {
 
 // Disconnect closes the connection
 func (s *SyncService) Disconnect(ctx context.Context) error {
+	s.StopIdle()
+
 	var err = s.imap.Close()
 
 	s.mu.Lock()
@@ -136,6 +194,15 @@ func (s *SyncService) GetConnectionStatus() ports.ConnectionStatus {
 // This is for incremental sync - uses FetchNewEmailsBatch (1 request for N emails)
 // Does NOT run purge - call PurgeDeletedEmails separately
 func (s *SyncService) SyncFolder(ctx context.Context, folderName string) (*ports.SyncResult, error) {
+	s.mu.RLock()
+	var imap = s.imap
+	s.mu.RUnlock()
+
+	return s.syncFolderWith(ctx, imap, folderName)
+}
+
+// syncFolderWith runs SyncFolder on the given IMAP connection
+func (s *SyncService) syncFolderWith(ctx context.Context, imap ports.IMAPPort, folderName string) (*ports.SyncResult, error) {
 	s.mu.RLock()
 	var account = s.account
 	var config = s.config
@@ -160,7 +227,7 @@ func (s *SyncService) SyncFolder(ctx context.Context, folderName string) (*ports
 	var syncID, _ = storage.LogSyncStart(account.ID, folder.ID)
 
 	// Select mailbox on IMAP
-	var status, err2 = s.imap.SelectMailbox(ctx, folderName)
+	var status, err2 = imap.SelectMailbox(ctx, folderName)
 	if err2 != nil {
 		storage.LogSyncComplete(syncID, 0, 0, err2)
 		s.events.Publish(ports.SyncErrorEvent{
@@ -174,6 +241,17 @@ func (s *SyncService) SyncFolder(ctx context.Context, folderName string) (*ports
 	// Update folder stats
 	s.storage.UpdateFolderStats(ctx, folder.ID, int(status.NumMessages), int(status.NumUnseen))
 
+	// UIDVALIDITY changed: local UIDs are meaningless now, reconcile before fetching
+	if err := s.checkUIDValidity(ctx, imap, account, folder, status.UIDValidity); err != nil {
+		storage.LogSyncComplete(syncID, 0, 0, err)
+		s.events.Publish(ports.SyncErrorEvent{
+			BaseEvent: ports.NewBaseEvent(ports.EventTypeSyncError),
+			Folder:    folderName,
+			Error:     err,
+		})
+		return nil, err
+	}
+
 	// Get latest UID from storage
 	var latestUID, _ = s.storage.GetLatestUID(ctx, folder.ID)
 
@@ -187,10 +265,15 @@ func (s *SyncService) SyncFolder(ctx context.Context, folderName string) (*ports
 	// Use appropriate sync method
 	if isInitialSync {
 		// Initial sync: use date-based fetch (last N days)
-		result, err = s.initialSyncFolder(ctx, account, folder, config, syncID)
+		result, err = s.initialSyncFolder(ctx, imap, account, folder, config, syncID)
 		if err != nil {
 			return nil, err
 		}
+
+		// Initial sync already has current flags: start the flag delta from here
+		if status.HighestModSeq > 0 {
+			s.storage.UpdateFolderModSeq(ctx, folder.ID, status.HighestModSeq)
+		}
 	} else {
 		// Incremental sync: use batch fetch (1 request for all new emails)
 		var batchSize = config.IncrementalBatchSize
@@ -199,7 +282,7 @@ func (s *SyncService) SyncFolder(ctx context.Context, folderName string) (*ports
 		}
 
 		// OPTIMIZED: Single request for envelope + bodystructure
-		var newEmails, err3 = s.imap.FetchNewEmailsBatch(ctx, latestUID, batchSize)
+		var newEmails, err3 = imap.FetchNewEmailsBatch(ctx, latestUID, batchSize)
 		if err3 != nil {
 			storage.LogSyncComplete(syncID, 0, 0, err3)
 			s.events.Publish(ports.SyncErrorEvent{
@@ -210,11 +293,24 @@ func (s *SyncService) SyncFolder(ctx context.Context, folderName string) (*ports
 			return nil, fmt.Errorf("failed to fetch new emails: %w", err3)
 		}
 
+		// A backend may hand back the newest stored email again (IMAP n:*
+		// always matches the last message): re-storing it would rerun rules
+		newEmails = emailsAfter(newEmails, latestUID)
+
 		// Store new emails (attachments already included from batch fetch!)
-		s.storeEmailsBatch(ctx, account, folder, newEmails, result)
+		var stored = s.storeEmailsBatch(ctx, account, folder, newEmails, result)
+
+		// Filter rules only see new mail: not the initial sync or a UID reconcile
+		s.applyRules(ctx, imap, folderName, stored)
+
+		// Pull flag changes and expunges made by other clients
+		if config.FlagSyncEnabled {
+			s.syncFlagChanges(ctx, imap, account, folder, status, config, len(newEmails), result)
+		}
 	}
 
-	// NOTE: Purge is now SEPARATE - not called during sync
+	// NOTE: The full purge is SEPARATE - not called during sync
+	// (syncFlagChanges only runs it when the message count shows expunges)
 	// Call PurgeDeletedEmails periodically instead
 
 	// Conta novos emails desde o último sync
@@ -222,10 +318,11 @@ func (s *SyncService) SyncFolder(ctx context.Context, folderName string) (*ports
 	result.NewEmails = newCount
 
 	// Registra conclusão do sync
-	storage.LogSyncComplete(syncID, newCount, 0, nil)
+	storage.LogSyncComplete(syncID, newCount, result.DeletedEmails, nil)
 
 	s.events.Publish(ports.SyncCompletedEvent{
 		BaseEvent: ports.NewBaseEvent(ports.EventTypeSyncCompleted),
+		AccountID: account.ID,
 		Folder:    folderName,
 		Result:    result,
 	})
@@ -234,7 +331,7 @@ func (s *SyncService) SyncFolder(ctx context.Context, folderName string) (*ports
 }
 
 // initialSyncFolder performs optimized first-time sync using date-based search
-func (s *SyncService) initialSyncFolder(ctx context.Context, account *ports.AccountInfo, folder *ports.Folder, config ports.SyncConfig, syncID int64) (*ports.SyncResult, error) {
+func (s *SyncService) initialSyncFolder(ctx context.Context, imap ports.IMAPPort, account *ports.AccountInfo, folder *ports.Folder, config ports.SyncConfig, syncID int64) (*ports.SyncResult, error) {
 	var days = config.InitialSyncDays
 	if days == 0 {
 		days = 30
@@ -247,7 +344,7 @@ func (s *SyncService) initialSyncFolder(ctx context.Context, account *ports.Acco
 	var result = &ports.SyncResult{}
 
 	// OPTIMIZED: Fetch by date (last N days) with batch operation
-	var emails, err = s.imap.FetchEmailsSinceDateBatch(ctx, days, maxEmails)
+	var emails, err = imap.FetchEmailsSinceDateBatch(ctx, days, maxEmails)
 	if err != nil {
 		storage.LogSyncComplete(syncID, 0, 0, err)
 		s.events.Publish(ports.SyncErrorEvent{
@@ -264,8 +361,206 @@ func (s *SyncService) initialSyncFolder(ctx context.Context, account *ports.Acco
 	return result, nil
 }
 
-// storeEmailsBatch stores emails from batch fetch (includes attachment metadata)
-func (s *SyncService) storeEmailsBatch(ctx context.Context, account *ports.AccountInfo, folder *ports.Folder, emails []ports.IMAPEmail, result *ports.SyncResult) {
+// syncFlagChanges pulls read/star/deleted flags changed by other clients.
+// With CONDSTORE only emails changed since the stored HIGHESTMODSEQ are
+// fetched (CHANGEDSINCE); otherwise the newest FlagRefreshLimit emails are
+// refreshed. Expunges come with the delta when the backend can report them
+// (QRESYNC VANISHED, Gmail history, JMAP changes); otherwise the message
+// count tells when one happened and only then the full UID purge
+// (purgeDeleted) runs.
+func (s *SyncService) syncFlagChanges(ctx context.Context, imap ports.IMAPPort, account *ports.AccountInfo, folder *ports.Folder, status *ports.MailboxStatus, config ports.SyncConfig, fetched int, result *ports.SyncResult) {
+	var updates []ports.FlagUpdate
+	var expungesSynced bool
+	var err error
+
+	if status.HighestModSeq > 0 && imap.SupportsCondStore() {
+		if folder.HighestModSeq > 0 && status.HighestModSeq > folder.HighestModSeq {
+			updates, err = imap.FetchFlagChanges(ctx, folder.HighestModSeq)
+			expungesSynced = err == nil && imap.FlagChangesIncludeExpunges()
+		}
+	} else {
+		var limit = config.FlagRefreshLimit
+		if limit == 0 {
+			limit = 200
+		}
+		var uids []uint32
+		uids, err = s.storage.GetRecentUIDs(ctx, account.ID, folder.ID, limit)
+		if err == nil {
+			updates, err = imap.FetchFlags(ctx, uids)
+		}
+	}
+	if err != nil {
+		// Keep the old MODSEQ so the next sync retries the same delta
+		log.Printf("[SyncService] flag sync failed for %s: %v", folder.Name, err)
+		result.Errors = append(result.Errors, err)
+		return
+	}
+
+	var changed, applyErr = s.storage.ApplyFlagUpdates(ctx, account.ID, folder.ID, updates)
+	if applyErr != nil {
+		log.Printf("[SyncService] failed to apply flag updates for %s: %v", folder.Name, applyErr)
+		result.Errors = append(result.Errors, applyErr)
+		return
+	}
+	result.FlagUpdates = changed
+
+	// Fewer messages than last time plus what just arrived: something was expunged
+	if !expungesSynced && folder.TotalMessages > 0 && int(status.NumMessages) < folder.TotalMessages+fetched {
+		var purged, purgeErr = s.purgeDeleted(ctx, imap, folder.ID)
+		if purgeErr != nil {
+			result.Errors = append(result.Errors, purgeErr)
+		}
+		result.DeletedEmails += purged
+	}
+
+	if status.HighestModSeq > folder.HighestModSeq {
+		if err := s.storage.UpdateFolderModSeq(ctx, folder.ID, status.HighestModSeq); err == nil {
+			folder.HighestModSeq = status.HighestModSeq
+		}
+	}
+}
+
+// checkUIDValidity compares the server UIDVALIDITY with the stored one. On the
+// first sync it is just recorded; if it changed, local emails are reconciled
+// by Message-ID before anything else trusts the stored UIDs.
+// The new value is only stored after a successful reconciliation.
+func (s *SyncService) checkUIDValidity(ctx context.Context, imap ports.IMAPPort, account *ports.AccountInfo, folder *ports.Folder, uidValidity uint32) error {
+	if uidValidity == 0 || uidValidity == folder.UIDValidity {
+		return nil
+	}
+
+	if folder.UIDValidity != 0 {
+		if err := s.reconcileUIDValidity(ctx, imap, account, folder, uidValidity); err != nil {
+			return fmt.Errorf("failed to reconcile UIDVALIDITY change: %w", err)
+		}
+	}
+
+	if err := s.storage.UpdateFolderUIDValidity(ctx, folder.ID, uidValidity); err != nil {
+		return fmt.Errorf("failed to store UIDVALIDITY: %w", err)
+	}
+	folder.UIDValidity = uidValidity
+	return nil
+}
+
+// CheckUIDValidity runs the UIDVALIDITY check for a folder the caller just
+// selected on the shared IMAP connection (the TUI syncs on its own)
+func (s *SyncService) CheckUIDValidity(ctx context.Context, folderName string, uidValidity uint32) error {
+	s.mu.RLock()
+	var account = s.account
+	var imap = s.imap
+	s.mu.RUnlock()
+
+	if account == nil {
+		return fmt.Errorf("no account set")
+	}
+
+	var folder, err = s.storage.GetFolderByName(ctx, account.ID, folderName)
+	if err != nil {
+		return fmt.Errorf("folder not found: %w", err)
+	}
+	return s.checkUIDValidity(ctx, imap, account, folder, uidValidity)
+}
+
+// reconcileUIDValidity re-matches local emails to the renumbered server UIDs
+// by Message-ID, marks true orphans as deleted and fetches server emails that
+// have no local copy. Expects the folder to be selected on imap.
+func (s *SyncService) reconcileUIDValidity(ctx context.Context, imap ports.IMAPPort, account *ports.AccountInfo, folder *ports.Folder, uidValidity uint32) error {
+	log.Printf("[SyncService] UIDVALIDITY changed for %s: %d -> %d, reconciling by Message-ID",
+		folder.Name, folder.UIDValidity, uidValidity)
+
+	var serverIDs, err = imap.FetchMessageIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	var result, err2 = s.storage.ReconcileFolderUIDs(ctx, account.ID, folder.ID, serverIDs)
+	if err2 != nil {
+		return err2
+	}
+
+	// Fetch server emails missing locally (most recent first, capped like an initial sync)
+	s.mu.RLock()
+	var maxEmails = s.config.InitialMaxPerFolder
+	s.mu.RUnlock()
+	if maxEmails == 0 {
+		maxEmails = 500
+	}
+
+	var missing = result.MissingUIDs
+	if len(missing) > maxEmails {
+		missing = missing[len(missing)-maxEmails:]
+	}
+
+	var fetched = 0
+	if len(missing) > 0 {
+		var emails, fetchErr = imap.FetchEmailsBatch(ctx, missing)
+		if fetchErr != nil {
+			log.Printf("[SyncService] failed to fetch %d missing emails after reconcile: %v", len(missing), fetchErr)
+		} else {
+			s.storeEmailsBatch(ctx, account, folder, emails, &ports.SyncResult{})
+			fetched = len(emails)
+		}
+	}
+
+	log.Printf("[SyncService] reconcile %s: rematched=%d orphaned=%d fetched=%d",
+		folder.Name, result.Rematched, result.Orphaned, fetched)
+
+	s.events.Publish(ports.UIDValidityChangedEvent{
+		BaseEvent:      ports.NewBaseEvent(ports.EventTypeUIDValidity),
+		Folder:         folder.Name,
+		OldUIDValidity: folder.UIDValidity,
+		NewUIDValidity: uidValidity,
+		Rematched:      result.Rematched,
+		Orphaned:       result.Orphaned,
+		Fetched:        fetched,
+	})
+
+	return nil
+}
+
+// emailsAfter keeps only the emails with a UID above latestUID
+func emailsAfter(emails []ports.IMAPEmail, latestUID uint32) []ports.IMAPEmail {
+	var after = make([]ports.IMAPEmail, 0, len(emails))
+	for _, email := range emails {
+		if email.UID > latestUID {
+			after = append(after, email)
+		}
+	}
+	return after
+}
+
+// applyRules runs the filter rules over emails just stored in INBOX
+func (s *SyncService) applyRules(ctx context.Context, imap ports.IMAPPort, folderName string, emails []ports.EmailContent) {
+	s.mu.RLock()
+	var rules = s.rules
+	s.mu.RUnlock()
+
+	if rules == nil || folderName != idleFolder || len(emails) == 0 {
+		return
+	}
+
+	var result = rules.ApplyRules(ctx, imap, emails)
+	if result == nil {
+		return
+	}
+	for _, err := range result.Errors {
+		log.Printf("[SyncService] rule failed: %v", err)
+	}
+	if result.Matched > 0 {
+		log.Printf("[SyncService] rules matched %d of %d new emails (%d actions)", result.Matched, result.Checked, result.Actions)
+	}
+}
+
+// storeEmailsBatch stores emails from batch fetch (includes attachment metadata).
+// Returns the stored emails with their IDs set. Callers only pass emails with
+// a UID above the folder's latest stored one, so every email is a new row and
+// gets a NewEmailEvent.
+func (s *SyncService) storeEmailsBatch(ctx context.Context, account *ports.AccountInfo, folder *ports.Folder, emails []ports.IMAPEmail, result *ports.SyncResult) []ports.EmailContent {
+	s.mu.RLock()
+	var risk = s.risk
+	s.mu.RUnlock()
+
+	var stored = make([]ports.EmailContent, 0, len(emails))
 	for _, email := range emails {
 		var content = &ports.EmailContent{
 			EmailMetadata: ports.EmailMetadata{
@@ -283,6 +578,7 @@ func (s *SyncService) storeEmailsBatch(ctx context.Context, account *ports.Accou
 				References: email.References,
 			},
 			BodyText:       email.BodyText,
+			RawHeaders:     email.RawHeaders,
 			HasAttachments: email.HasAttachments,
 		}
 
@@ -291,6 +587,32 @@ func (s *SyncService) storeEmailsBatch(ctx context.Context, account *ports.Accou
 			result.Errors = append(result.Errors, upsertErr)
 			continue
 		}
+		content.ID = emailID
+		content.FolderID = folder.ID
+		content.FolderName = folder.Name
+
+		// Backends with native threads and labels (Gmail API) hand them over
+		if email.ThreadID != "" {
+			if err := s.storage.UpdateThreadID(ctx, emailID, email.ThreadID); err == nil {
+				content.ThreadID = email.ThreadID
+			}
+		}
+		if email.Labels != nil && messageID != "" {
+			var labels = visibleLabelNames(email.Labels)
+			if err := s.storage.SetMessageLabels(ctx, account.ID, messageID, labels); err == nil {
+				content.Labels = labels
+			}
+		}
+
+		// Rate the email from its authentication headers (DKIM is verified
+		// when it is opened, with the whole message)
+		if risk != nil {
+			if rating, err := risk.Assess(ctx, emailID, nil); err != nil {
+				log.Printf("[SyncService] risk analysis of email %d failed: %v", emailID, err)
+			} else {
+				content.RiskLevel = rating.Level
+			}
+		}
 
 		// Collect ID for thread sync (only emails with message_id can have thread_id)
 		if messageID != "" {
@@ -329,7 +651,10 @@ func (s *SyncService) storeEmailsBatch(ctx context.Context, account *ports.Accou
 			BaseEvent: ports.NewBaseEvent(ports.EventTypeNewEmail),
 			Email:     content.EmailMetadata,
 		})
+
+		stored = append(stored, *content)
 	}
+	return stored
 }
 
 // InitialSync performs optimized first-time sync for a folder
@@ -356,12 +681,18 @@ func (s *SyncService) InitialSync(ctx context.Context, folderName string) (*port
 
 	var syncID, _ = storage.LogSyncStart(account.ID, folder.ID)
 
-	if _, err := s.imap.SelectMailbox(ctx, folderName); err != nil {
+	var status, selectErr = s.imap.SelectMailbox(ctx, folderName)
+	if selectErr != nil {
+		storage.LogSyncComplete(syncID, 0, 0, selectErr)
+		return nil, fmt.Errorf("failed to select mailbox: %w", selectErr)
+	}
+
+	if err := s.checkUIDValidity(ctx, s.imap, account, folder, status.UIDValidity); err != nil {
 		storage.LogSyncComplete(syncID, 0, 0, err)
-		return nil, fmt.Errorf("failed to select mailbox: %w", err)
+		return nil, err
 	}
 
-	var result, syncErr = s.initialSyncFolder(ctx, account, folder, config, syncID)
+	var result, syncErr = s.initialSyncFolder(ctx, s.imap, account, folder, config, syncID)
 	if syncErr != nil {
 		return nil, syncErr
 	}
@@ -372,6 +703,7 @@ func (s *SyncService) InitialSync(ctx context.Context, folderName string) (*port
 
 	s.events.Publish(ports.SyncCompletedEvent{
 		BaseEvent: ports.NewBaseEvent(ports.EventTypeSyncCompleted),
+		AccountID: account.ID,
 		Folder:    folderName,
 		Result:    result,
 	})
@@ -432,19 +764,26 @@ func (s *SyncService) PurgeDeletedEmails(ctx context.Context, folderName string)
 	}
 
 	// Select mailbox
-	if _, err := s.imap.SelectMailbox(ctx, folderName); err != nil {
-		log.Printf("[PurgeDeletedEmails] error selecting mailbox: %v", err)
+	var status, selectErr = s.imap.SelectMailbox(ctx, folderName)
+	if selectErr != nil {
+		log.Printf("[PurgeDeletedEmails] error selecting mailbox: %v", selectErr)
+		return 0, selectErr
+	}
+
+	// Never compare UIDs across UIDVALIDITY epochs (would mark everything deleted)
+	if err := s.checkUIDValidity(ctx, s.imap, account, folder, status.UIDValidity); err != nil {
+		log.Printf("[PurgeDeletedEmails] error reconciling UIDVALIDITY: %v", err)
 		return 0, err
 	}
 
-	var purged, purgeErr = s.purgeDeleted(ctx, folder.ID)
+	var purged, purgeErr = s.purgeDeleted(ctx, s.imap, folder.ID)
 	log.Printf("[PurgeDeletedEmails] completed: purged=%d, err=%v", purged, purgeErr)
 	return purged, purgeErr
 }
 
 // purgeDeleted marks emails as deleted that were removed from server
 // NOTE: This is expensive for large mailboxes, so we skip if too many local emails
-func (s *SyncService) purgeDeleted(ctx context.Context, folderID int64) (int, error) {
+func (s *SyncService) purgeDeleted(ctx context.Context, imap ports.IMAPPort, folderID int64) (int, error) {
 	// Get local email count first
 	var localUIDs, err2 = s.storage.GetAllUIDs(ctx, folderID)
 	if err2 != nil {
@@ -458,11 +797,16 @@ func (s *SyncService) purgeDeleted(ctx context.Context, folderID int64) (int, er
 	}
 
 	// Get all UIDs from server
-	var serverUIDs, err = s.imap.GetAllUIDs(ctx)
+	var serverUIDs, err = imap.GetAllUIDs(ctx)
 	if err != nil {
 		return 0, err
 	}
 
+	// Empty list from the server is more likely an error than an empty folder
+	if len(serverUIDs) == 0 {
+		return 0, nil
+	}
+
 	// Create a set for fast lookup
 	var serverUIDSet = make(map[uint32]bool)
 	for _, uid := range serverUIDs {
@@ -507,7 +851,13 @@ func (s *SyncService) PurgeSpecificUIDs(ctx context.Context, folderName string,
 	}
 
 	// Select mailbox
-	if _, err := s.imap.SelectMailbox(ctx, folderName); err != nil {
+	var status, selectErr = s.imap.SelectMailbox(ctx, folderName)
+	if selectErr != nil {
+		return nil, selectErr
+	}
+
+	// Requested UIDs may belong to an old UIDVALIDITY; reconcile first
+	if err := s.checkUIDValidity(ctx, s.imap, account, folder, status.UIDValidity); err != nil {
 		return nil, err
 	}
 
@@ -542,6 +892,220 @@ func (s *SyncService) PurgeSpecificUIDs(ctx context.Context, folderName string,
 	return deletedUIDs, nil
 }
 
+// StartIdle starts push sync on INBOX using the dedicated IDLE adapter.
+// Returns immediately; the watcher runs until StopIdle or ctx is cancelled.
+func (s *SyncService) StartIdle(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.account == nil {
+		return fmt.Errorf("no account set")
+	}
+	if s.idle == nil {
+		return fmt.Errorf("no idle adapter set")
+	}
+	if !s.config.IdleEnabled {
+		return nil
+	}
+	if s.idleCancel != nil {
+		return nil // already running
+	}
+
+	var idleCtx, cancel = context.WithCancel(ctx)
+	var done = make(chan struct{})
+	s.idleCancel = cancel
+	s.idleDone = done
+
+	go s.runIdle(idleCtx, s.idle, s.idleBackoff, done)
+	return nil
+}
+
+// StopIdle stops push sync and waits for the watcher to exit
+func (s *SyncService) StopIdle() {
+	s.mu.Lock()
+	var cancel = s.idleCancel
+	var done = s.idleDone
+	s.idleCancel = nil
+	s.idleDone = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// IsIdling returns true if push sync is running
+func (s *SyncService) IsIdling() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.idleCancel != nil
+}
+
+// runIdle keeps an IDLE connection on INBOX and syncs whenever the server
+// reports a change. Reconnects with exponential backoff on errors and runs a
+// catch-up sync after every (re)connect so nothing is missed while offline.
+func (s *SyncService) runIdle(ctx context.Context, imap ports.IMAPPort, minBackoff time.Duration, done chan struct{}) {
+	defer close(done)
+	defer imap.Close()
+
+	var backoff = minBackoff
+	var connected = false
+
+	for ctx.Err() == nil {
+		if !connected {
+			if err := imap.Connect(ctx); err != nil {
+				log.Printf("[SyncService.idle] connect failed: %v (retry in %s)", err, backoff)
+				if !sleepCtx(ctx, backoff) {
+					return
+				}
+				backoff = min(backoff*2, idleMaxBackoff)
+				continue
+			}
+			connected = true
+
+			// Catch-up: pega o que chegou enquanto estava desconectado
+			if _, err := s.syncFolderWith(ctx, imap, idleFolder); err != nil {
+				log.Printf("[SyncService.idle] catch-up sync failed: %v", err)
+			}
+		}
+
+		var update, err = imap.Idle(ctx, idleFolder)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[SyncService.idle] idle failed: %v (reconnecting in %s)", err, backoff)
+			imap.Close()
+			connected = false
+			if !sleepCtx(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, idleMaxBackoff)
+			continue
+		}
+		backoff = minBackoff
+
+		// With flag sync on, the flag delta (or its purge fallback) also
+		// catches expunges; the full UID purge runs only without it
+		var flagsSynced bool
+		if update.NumMessages > 0 {
+			// The full sync pulls flag changes too
+			if _, err := s.syncFolderWith(ctx, imap, idleFolder); err != nil {
+				log.Printf("[SyncService.idle] sync failed: %v", err)
+			}
+			flagsSynced = s.flagSyncEnabled()
+		} else if update.FlagsChanged || update.Expunged > 0 {
+			flagsSynced = s.syncIdleFlags(ctx, imap)
+		}
+		if update.Expunged > 0 && !flagsSynced {
+			s.purgeIdleFolder(ctx, imap)
+		}
+	}
+}
+
+// flagSyncEnabled reports whether syncs pull flag changes
+func (s *SyncService) flagSyncEnabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.FlagSyncEnabled
+}
+
+// syncIdleFlags pulls flag changes and expunges reported during IDLE without
+// looking for new emails, so nothing is stored and no NewEmailEvent fires.
+// Returns false if flag sync is off.
+func (s *SyncService) syncIdleFlags(ctx context.Context, imap ports.IMAPPort) bool {
+	s.mu.RLock()
+	var account = s.account
+	var config = s.config
+	s.mu.RUnlock()
+
+	if account == nil || !config.FlagSyncEnabled {
+		return false
+	}
+
+	var folder, err = s.storage.GetFolderByName(ctx, account.ID, idleFolder)
+	if err != nil {
+		return true
+	}
+
+	var status, selectErr = imap.SelectMailbox(ctx, idleFolder)
+	if selectErr != nil {
+		log.Printf("[SyncService.idle] select failed: %v", selectErr)
+		return true
+	}
+	s.storage.UpdateFolderStats(ctx, folder.ID, int(status.NumMessages), int(status.NumUnseen))
+	if err := s.checkUIDValidity(ctx, imap, account, folder, status.UIDValidity); err != nil {
+		log.Printf("[SyncService.idle] reconcile failed: %v", err)
+		return true
+	}
+
+	var result = &ports.SyncResult{}
+	s.syncFlagChanges(ctx, imap, account, folder, status, config, 0, result)
+	if result.FlagUpdates > 0 || result.DeletedEmails > 0 {
+		s.events.Publish(ports.SyncCompletedEvent{
+			BaseEvent: ports.NewBaseEvent(ports.EventTypeSyncCompleted),
+			AccountID: account.ID,
+			Folder:    idleFolder,
+			Result:    result,
+		})
+	}
+	return true
+}
+
+// purgeIdleFolder marks emails expunged on the server as deleted locally
+func (s *SyncService) purgeIdleFolder(ctx context.Context, imap ports.IMAPPort) {
+	s.mu.RLock()
+	var account = s.account
+	s.mu.RUnlock()
+
+	if account == nil {
+		return
+	}
+
+	var folder, err = s.storage.GetFolderByName(ctx, account.ID, idleFolder)
+	if err != nil {
+		return
+	}
+
+	var status, selectErr = imap.SelectMailbox(ctx, idleFolder)
+	if selectErr != nil {
+		return
+	}
+	if err := s.checkUIDValidity(ctx, imap, account, folder, status.UIDValidity); err != nil {
+		log.Printf("[SyncService.idle] reconcile failed: %v", err)
+		return
+	}
+
+	var purged, purgeErr = s.purgeDeleted(ctx, imap, folder.ID)
+	if purgeErr != nil {
+		log.Printf("[SyncService.idle] purge failed: %v", purgeErr)
+		return
+	}
+	if purged > 0 {
+		s.events.Publish(ports.SyncCompletedEvent{
+			BaseEvent: ports.NewBaseEvent(ports.EventTypeSyncCompleted),
+			AccountID: account.ID,
+			Folder:    idleFolder,
+			Result:    &ports.SyncResult{DeletedEmails: purged},
+		})
+	}
+}
+
+// sleepCtx waits for d or until ctx is cancelled. Returns false if cancelled.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	var timer = time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
 // SyncAll syncs all folders
 func (s *SyncService) SyncAll(ctx context.Context) ([]ports.SyncResult, error) {
 	s.mu.RLock()
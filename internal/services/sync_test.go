@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+	"github.com/opik/miau/internal/testutil"
+	"github.com/opik/miau/internal/testutil/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestIdleService returns a SyncService watching INBOX through an IDLE
+// mock. Flag sync is off so a sync only fetches new emails.
+func newTestIdleService(t *testing.T) (*SyncService, *mocks.IMAPPort, *mocks.StoragePort) {
+	// The sync log lives in the database, not behind the storage port
+	require.NoError(t, storage.Init(filepath.Join(t.TempDir(), "test.db")))
+	t.Cleanup(func() { storage.Close() })
+
+	var mockIdle = new(mocks.IMAPPort)
+	var mockStorage = new(mocks.StoragePort)
+	var mockEvents = new(mocks.EventBus)
+	mockEvents.On("Publish", mock.Anything).Return()
+
+	var svc = NewSyncService(new(mocks.IMAPPort), mockStorage, mockEvents)
+	svc.SetAccount(testutil.TestAccount())
+	var config = ports.DefaultSyncConfig()
+	config.FlagSyncEnabled = false
+	svc.SetSyncConfig(config)
+	svc.idleBackoff = 10 * time.Millisecond
+	svc.SetIdleAdapter(mockIdle)
+
+	var folder = testutil.TestFolder()
+	mockStorage.On("GetFolderByName", mock.Anything, int64(1), "INBOX").Return(folder, nil)
+	mockStorage.On("UpdateFolderStats", mock.Anything, folder.ID, mock.Anything, mock.Anything).Return(nil)
+	mockStorage.On("GetLatestUID", mock.Anything, folder.ID).Return(uint32(1050), nil)
+	mockIdle.On("SelectMailbox", mock.Anything, "INBOX").Return(&ports.MailboxStatus{Name: "INBOX", NumMessages: 100}, nil)
+	mockIdle.On("Close").Return(nil)
+	return svc, mockIdle, mockStorage
+}
+
+// blockIdle makes further Idle calls wait until the watcher is stopped
+func blockIdle(mockIdle *mocks.IMAPPort) {
+	mockIdle.On("Idle", mock.Anything, "INBOX").Return(nil, context.Canceled).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	})
+}
+
+// waitFor waits for n signals on ch
+func waitFor(t *testing.T, ch chan struct{}, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %d of %d signals", i, n)
+		}
+	}
+}
+
+func TestSyncService_Idle_SyncsOnExists(t *testing.T) {
+	// Arrange
+	var svc, mockIdle, _ = newTestIdleService(t)
+	var fetched = make(chan struct{}, 10)
+
+	mockIdle.On("Connect", mock.Anything).Return(nil)
+	mockIdle.On("FetchNewEmailsBatch", mock.Anything, uint32(1050), mock.Anything).
+		Return([]ports.IMAPEmail{}, nil).Run(func(mock.Arguments) { fetched <- struct{}{} })
+	mockIdle.On("Idle", mock.Anything, "INBOX").Return(&ports.MailboxUpdate{Mailbox: "INBOX", NumMessages: 101, ViaIdle: true}, nil).Once()
+	blockIdle(mockIdle)
+
+	// Act
+	require.NoError(t, svc.StartIdle(context.Background()))
+	defer svc.StopIdle()
+
+	// Assert: the catch-up sync after connecting, then the one for EXISTS
+	waitFor(t, fetched, 2)
+	mockIdle.AssertNumberOfCalls(t, "Connect", 1)
+}
+
+func TestSyncService_Idle_PurgesOnExpunge(t *testing.T) {
+	// Arrange
+	var svc, mockIdle, mockStorage = newTestIdleService(t)
+	var purged = make(chan struct{}, 1)
+
+	mockIdle.On("Connect", mock.Anything).Return(nil)
+	mockIdle.On("FetchNewEmailsBatch", mock.Anything, uint32(1050), mock.Anything).Return([]ports.IMAPEmail{}, nil)
+	mockIdle.On("Idle", mock.Anything, "INBOX").Return(&ports.MailboxUpdate{Mailbox: "INBOX", Expunged: 1, ViaIdle: true}, nil).Once()
+	blockIdle(mockIdle)
+	mockStorage.On("GetAllUIDs", mock.Anything, int64(1)).Return([]uint32{1001, 1002, 1003}, nil)
+	mockIdle.On("GetAllUIDs", mock.Anything).Return([]uint32{1001, 1003}, nil)
+	mockStorage.On("MarkDeletedByUIDs", mock.Anything, int64(1), []uint32{1002}).
+		Return(nil).Run(func(mock.Arguments) { purged <- struct{}{} })
+
+	// Act
+	require.NoError(t, svc.StartIdle(context.Background()))
+	defer svc.StopIdle()
+
+	// Assert
+	waitFor(t, purged, 1)
+}
+
+func TestSyncService_Idle_ReconnectsWithBackoff(t *testing.T) {
+	// Arrange
+	var svc, mockIdle, _ = newTestIdleService(t)
+	var mu sync.Mutex
+	var attempts []time.Time
+	var connected = make(chan struct{}, 10)
+	var track = func(mock.Arguments) {
+		mu.Lock()
+		attempts = append(attempts, time.Now())
+		mu.Unlock()
+	}
+
+	// Two failed connects, then a dropped IDLE connection
+	mockIdle.On("Connect", mock.Anything).Return(errors.New("connection refused")).Run(track).Twice()
+	mockIdle.On("Connect", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		track(args)
+		connected <- struct{}{}
+	})
+	mockIdle.On("FetchNewEmailsBatch", mock.Anything, uint32(1050), mock.Anything).Return([]ports.IMAPEmail{}, nil)
+	mockIdle.On("Idle", mock.Anything, "INBOX").Return(nil, errors.New("connection reset")).Once()
+	blockIdle(mockIdle)
+
+	// Act
+	require.NoError(t, svc.StartIdle(context.Background()))
+	defer svc.StopIdle()
+
+	// Assert: each retry waits twice as long as the previous one
+	waitFor(t, connected, 2)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, attempts, 4)
+	assert.GreaterOrEqual(t, attempts[1].Sub(attempts[0]), 10*time.Millisecond)
+	assert.GreaterOrEqual(t, attempts[2].Sub(attempts[1]), 20*time.Millisecond)
+	assert.GreaterOrEqual(t, attempts[3].Sub(attempts[2]), 40*time.Millisecond)
+	// The dropped connection was closed before reconnecting
+	mockIdle.AssertCalled(t, "Close")
+}
+
+func TestSyncService_StopIdle_ReturnsPromptly(t *testing.T) {
+	var cases = []struct {
+		name  string
+		setup func(*mocks.IMAPPort, chan struct{})
+	}{
+		{
+			name: "while idling",
+			setup: func(mockIdle *mocks.IMAPPort, started chan struct{}) {
+				mockIdle.On("Connect", mock.Anything).Return(nil)
+				mockIdle.On("FetchNewEmailsBatch", mock.Anything, uint32(1050), mock.Anything).Return([]ports.IMAPEmail{}, nil)
+				mockIdle.On("Idle", mock.Anything, "INBOX").Return(nil, context.Canceled).Run(func(args mock.Arguments) {
+					started <- struct{}{}
+					<-args.Get(0).(context.Context).Done()
+				})
+			},
+		},
+		{
+			name: "while waiting to reconnect",
+			setup: func(mockIdle *mocks.IMAPPort, started chan struct{}) {
+				mockIdle.On("Connect", mock.Anything).Return(errors.New("connection refused")).
+					Run(func(mock.Arguments) { started <- struct{}{} })
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var svc, mockIdle, _ = newTestIdleService(t)
+			svc.idleBackoff = time.Hour
+			var started = make(chan struct{}, 1)
+			tc.setup(mockIdle, started)
+			require.NoError(t, svc.StartIdle(context.Background()))
+			waitFor(t, started, 1)
+			assert.True(t, svc.IsIdling())
+
+			// Act
+			var stopped = make(chan struct{})
+			go func() {
+				svc.StopIdle()
+				close(stopped)
+			}()
+
+			// Assert
+			select {
+			case <-stopped:
+			case <-time.After(time.Second):
+				t.Fatal("StopIdle did not return")
+			}
+			assert.False(t, svc.IsIdling())
+			mockIdle.AssertCalled(t, "Close")
+		})
+	}
+}
+
+func TestSyncService_CheckUIDValidity_ReconcilesOnChange(t *testing.T) {
+	// Arrange
+	var mockIMAP = new(mocks.IMAPPort)
+	var mockStorage = new(mocks.StoragePort)
+	var mockEvents = new(mocks.EventBus)
+	mockEvents.On("Publish", mock.Anything).Return()
+
+	var svc = NewSyncService(mockIMAP, mockStorage, mockEvents)
+	svc.SetAccount(testutil.TestAccount())
+
+	var folder = testutil.TestFolder()
+	folder.UIDValidity = 100
+	var serverIDs = map[uint32]string{1: "<a@example.com>"}
+	mockStorage.On("GetFolderByName", mock.Anything, int64(1), "INBOX").Return(folder, nil)
+	mockIMAP.On("FetchMessageIDs", mock.Anything).Return(serverIDs, nil)
+	mockStorage.On("ReconcileFolderUIDs", mock.Anything, int64(1), folder.ID, serverIDs).
+		Return(&ports.UIDReconcileResult{Rematched: 1}, nil)
+	mockStorage.On("UpdateFolderUIDValidity", mock.Anything, folder.ID, uint32(200)).Return(nil)
+
+	// Act: unchanged, then changed
+	require.NoError(t, svc.CheckUIDValidity(context.Background(), "INBOX", 100))
+	mockIMAP.AssertNotCalled(t, "FetchMessageIDs", mock.Anything)
+	var err = svc.CheckUIDValidity(context.Background(), "INBOX", 200)
+
+	// Assert
+	assert.NoError(t, err)
+	mockStorage.AssertExpectations(t)
+	mockIMAP.AssertExpectations(t)
+}
+
+func TestSyncService_SyncFolder_SkipsStoredLastUID(t *testing.T) {
+	// Arrange: n:* matches the newest message even when it is already stored
+	var svc, mockIMAP, mockStorage = newTestIdleService(t)
+	mockIMAP.On("FetchNewEmailsBatch", mock.Anything, uint32(1050), mock.Anything).
+		Return([]ports.IMAPEmail{{UID: 1050, Subject: "Already here"}}, nil)
+
+	// Act
+	var result, err = svc.syncFolderWith(context.Background(), mockIMAP, "INBOX")
+
+	// Assert: nothing is stored again, so no rules run and no event fires
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1050), result.LatestUID)
+	assert.Empty(t, result.NewEmailIDs)
+	mockStorage.AssertNotCalled(t, "UpsertEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSyncService_Idle_FlagChangeSyncsFlagsOnly(t *testing.T) {
+	// Arrange
+	var svc, mockIdle, mockStorage = newTestIdleService(t)
+	var config = svc.GetSyncConfig()
+	config.FlagSyncEnabled = true
+	svc.SetSyncConfig(config)
+	var applied = make(chan struct{}, 10)
+
+	mockIdle.On("Connect", mock.Anything).Return(nil)
+	mockIdle.On("FetchNewEmailsBatch", mock.Anything, uint32(1050), mock.Anything).Return([]ports.IMAPEmail{}, nil)
+	mockIdle.On("Idle", mock.Anything, "INBOX").Return(&ports.MailboxUpdate{Mailbox: "INBOX", FlagsChanged: true, ViaIdle: true}, nil).Once()
+	blockIdle(mockIdle)
+	mockStorage.On("GetRecentUIDs", mock.Anything, int64(1), int64(1), mock.Anything).Return([]uint32{1050}, nil)
+	mockIdle.On("FetchFlags", mock.Anything, []uint32{1050}).Return([]ports.FlagUpdate{{UID: 1050, Seen: true}}, nil)
+	mockStorage.On("ApplyFlagUpdates", mock.Anything, int64(1), int64(1), mock.Anything).
+		Return(1, nil).Run(func(mock.Arguments) { applied <- struct{}{} })
+
+	// Act
+	require.NoError(t, svc.StartIdle(context.Background()))
+	defer svc.StopIdle()
+
+	// Assert: flags pulled after the catch-up sync and again for the change,
+	// but only the catch-up looked for new emails
+	waitFor(t, applied, 2)
+	svc.StopIdle()
+	mockIdle.AssertNumberOfCalls(t, "FetchNewEmailsBatch", 1)
+	for _, call := range svc.events.(*mocks.EventBus).Calls {
+		assert.NotEqual(t, ports.EventTypeNewEmail, call.Arguments.Get(0).(ports.Event).Type())
+	}
+}
+
+func TestSyncService_SyncFlagChanges_Expunges(t *testing.T) {
+	var cases = []struct {
+		name            string
+		includeExpunges bool
+		wantPurgeByUIDs bool
+	}{
+		{name: "delta reports expunges (VANISHED)", includeExpunges: true},
+		{name: "delta without expunges falls back to the UID purge", wantPurgeByUIDs: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange: one message less on the server than last time
+			var mockIMAP = new(mocks.IMAPPort)
+			var mockStorage = new(mocks.StoragePort)
+			var svc = NewSyncService(mockIMAP, mockStorage, new(mocks.EventBus))
+			var account = testutil.TestAccount()
+			var folder = testutil.TestFolder()
+			folder.HighestModSeq = 10
+			var status = &ports.MailboxStatus{Name: "INBOX", NumMessages: 99, HighestModSeq: 20}
+			var updates = []ports.FlagUpdate{{UID: 1002, Deleted: true}}
+
+			mockIMAP.On("SupportsCondStore").Return(true)
+			mockIMAP.On("FetchFlagChanges", mock.Anything, uint64(10)).Return(updates, nil)
+			mockIMAP.On("FlagChangesIncludeExpunges").Return(tc.includeExpunges)
+			mockStorage.On("ApplyFlagUpdates", mock.Anything, account.ID, folder.ID, updates).Return(1, nil)
+			mockStorage.On("UpdateFolderModSeq", mock.Anything, folder.ID, uint64(20)).Return(nil)
+			mockStorage.On("GetAllUIDs", mock.Anything, folder.ID).Return([]uint32{1001, 1003}, nil)
+			mockIMAP.On("GetAllUIDs", mock.Anything).Return([]uint32{1001, 1003}, nil)
+
+			// Act
+			var result = &ports.SyncResult{}
+			svc.syncFlagChanges(context.Background(), mockIMAP, account, folder, status, ports.DefaultSyncConfig(), 0, result)
+
+			// Assert
+			assert.Empty(t, result.Errors)
+			assert.Equal(t, 1, result.FlagUpdates)
+			assert.Equal(t, uint64(20), folder.HighestModSeq)
+			if tc.wantPurgeByUIDs {
+				mockIMAP.AssertCalled(t, "GetAllUIDs", mock.Anything)
+			} else {
+				mockIMAP.AssertNotCalled(t, "GetAllUIDs", mock.Anything)
+			}
+		})
+	}
+}
@@ -15,11 +15,18 @@ type UndoServiceImpl struct {
 	storage    ports.StoragePort
 	imap       ports.IMAPPort
 	account    *ports.AccountInfo
+	send       *SendService
 	undoStack  []ports.Operation
 	redoStack  []ports.Operation
 	maxHistory int
 }
 
+// expirable is an operation that can stop being undoable (a held email
+// whose undo-send window is over)
+type expirable interface {
+	Expired(ctx context.Context) bool
+}
+
 // NewUndoService creates a new UndoService
 func NewUndoService(storage ports.StoragePort, imap ports.IMAPPort) *UndoServiceImpl {
 	return &UndoServiceImpl{
@@ -31,6 +38,23 @@ func NewUndoService(storage ports.StoragePort, imap ports.IMAPPort) *UndoService
 	}
 }
 
+// SetIMAPAdapter sets the IMAP adapter that operations restored from
+// history act through (call before SetAccount when switching accounts)
+func (s *UndoServiceImpl) SetIMAPAdapter(imap ports.IMAPPort) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.imap = imap
+}
+
+// SetSendService sets the send service that send operations restored from
+// history act through, and reloads the history so they are restored
+func (s *UndoServiceImpl) SetSendService(send *SendService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.send = send
+	s.loadHistory()
+}
+
 // SetAccount sets the current account
 func (s *UndoServiceImpl) SetAccount(account *ports.AccountInfo) {
 	s.mu.Lock()
@@ -73,6 +97,12 @@ func (s *UndoServiceImpl) Undo(ctx context.Context) error {
 	op := s.undoStack[len(s.undoStack)-1]
 	s.undoStack = s.undoStack[:len(s.undoStack)-1]
 
+	// Drop operations that can't be undone anymore
+	if exp, ok := op.(expirable); ok && exp.Expired(ctx) {
+		s.removeOperation(ctx, op, "undo")
+		return fmt.Errorf("too late to undo: %s", op.Description())
+	}
+
 	// Execute undo
 	if err := op.Undo(ctx); err != nil {
 		// Re-add to undo stack on failure
@@ -274,6 +304,8 @@ func (s *UndoServiceImpl) reconstructOperation(record ports.OperationRecord) por
 		return s.reconstructDeleteOp(data)
 	case ports.OperationTypeMove:
 		return s.reconstructMoveOp(data)
+	case ports.OperationTypeSend:
+		return s.reconstructSendOp(data)
 	default:
 		return nil
 	}
@@ -345,6 +377,17 @@ func (s *UndoServiceImpl) reconstructMoveOp(data map[string]interface{}) ports.O
 	)
 }
 
+func (s *UndoServiceImpl) reconstructSendOp(data map[string]interface{}) ports.Operation {
+	if s.send == nil {
+		return nil
+	}
+	return NewSendOperation(
+		int64(data["draft_id"].(float64)),
+		data["subject"].(string),
+		s.send,
+	)
+}
+
 // Ensure UndoServiceImpl implements ports.UndoService
 var _ ports.UndoService = (*UndoServiceImpl)(nil)
 
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSendOperation is an undoable send whose window can be closed
+type fakeSendOperation struct {
+	expired bool
+	undone  bool
+}
+
+func (o *fakeSendOperation) Execute(ctx context.Context) error { return nil }
+func (o *fakeSendOperation) Undo(ctx context.Context) error    { o.undone = true; return nil }
+func (o *fakeSendOperation) Expired(ctx context.Context) bool  { return o.expired }
+func (o *fakeSendOperation) Description() string               { return "Enviar email: 'Oi'" }
+func (o *fakeSendOperation) Type() ports.OperationType         { return ports.OperationTypeSend }
+func (o *fakeSendOperation) Data() (string, error)             { return `{"draft_id":1}`, nil }
+
+func TestUndoService_Undo_ExpiredOperation(t *testing.T) {
+	var ctx = context.Background()
+	var svc = NewUndoService(nil, nil)
+
+	var sent = &fakeSendOperation{expired: true}
+	var held = &fakeSendOperation{}
+	assert.NoError(t, svc.RecordOperation(ctx, held))
+	assert.NoError(t, svc.RecordOperation(ctx, sent))
+
+	// The email already went out: the operation is dropped, not undone
+	var err = svc.Undo(ctx)
+	assert.ErrorContains(t, err, "too late to undo")
+	assert.False(t, sent.undone)
+	assert.False(t, svc.CanRedo(ctx))
+
+	// The next undo reaches the operation below it
+	assert.NoError(t, svc.Undo(ctx))
+	assert.True(t, held.undone)
+	assert.False(t, svc.CanUndo(ctx))
+}
@@ -0,0 +1,86 @@
+package sieve
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/opik/miau/internal/auth"
+	"github.com/opik/miau/internal/config"
+)
+
+// DefaultScriptName is the script miau uploads when the account sets none
+const DefaultScriptName = "miau"
+
+// ScriptName returns the name of the script miau manages for the account
+func ScriptName(account *config.Account) string {
+	if account.Sieve != nil && account.Sieve.Script != "" {
+		return account.Sieve.Script
+	}
+	return DefaultScriptName
+}
+
+// Address returns the ManageSieve server of the account: the sieve
+// settings when present, otherwise the IMAP host on port 4190
+func Address(account *config.Account) string {
+	var host = account.IMAP.Host
+	var port = DefaultPort
+	if account.Sieve != nil {
+		if account.Sieve.Host != "" {
+			host = account.Sieve.Host
+		}
+		if account.Sieve.Port != 0 {
+			port = account.Sieve.Port
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// DialAccount connects to the account's ManageSieve server and logs in with
+// the same credentials used for IMAP. Credentials are only sent after
+// STARTTLS, except to a loopback server.
+func DialAccount(account *config.Account) (*Client, error) {
+	var addr = Address(account)
+	var host, _, _ = net.SplitHostPort(addr)
+
+	var c, err = Dial(addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.Capability("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			c.Close()
+			return nil, err
+		}
+	} else if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		c.Close()
+		return nil, fmt.Errorf("managesieve: %s does not offer STARTTLS, refusing to send credentials", addr)
+	}
+
+	if account.AuthType == config.AuthTypeOAuth2 {
+		err = authenticateOAuth2(c, account)
+	} else {
+		err = c.AuthenticatePlain(account.Email, account.Password)
+	}
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("managesieve authentication failed: %w", err)
+	}
+	return c, nil
+}
+
+// authenticateOAuth2 uses the token saved by the IMAP login; it never opens
+// a browser, so run miau once to authenticate first
+func authenticateOAuth2(c *Client, account *config.Account) error {
+	if account.OAuth2 == nil {
+		return fmt.Errorf("account %s has no oauth2 settings", account.Email)
+	}
+	var tokenPath = auth.GetTokenPath(config.GetConfigPath(), account.Email)
+	var oauthCfg = auth.GetOAuth2Config(account.OAuth2.ClientID, account.OAuth2.ClientSecret)
+	var token, err = auth.GetValidToken(oauthCfg, tokenPath)
+	if err != nil {
+		return fmt.Errorf("no valid OAuth2 token (run miau to log in first): %w", err)
+	}
+	return c.AuthenticateOAuth2(account.Email, token.AccessToken)
+}
@@ -0,0 +1,185 @@
+package sieve
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/rules"
+)
+
+// attachmentKey approximates the has_attachment test: Sieve has no
+// attachment test, but messages with attachments are multipart/mixed
+const attachmentKey = "multipart/mixed"
+
+// Generate writes the enabled rules as a Sieve script. Actions Sieve can't
+// express (archive, snooze, task, classify) are left out of the script and
+// described in skipped; rules left without actions are skipped entirely.
+func Generate(list []ports.Rule) (script string, skipped []string) {
+	var body strings.Builder
+	var require = map[string]bool{}
+
+	for i := range list {
+		var rule = &list[i]
+		if !rule.Enabled {
+			continue
+		}
+
+		var actions, unsupported = generateActions(rule, require)
+		for _, a := range unsupported {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", rule.Name, a))
+		}
+		if len(actions) == 0 {
+			skipped = append(skipped, fmt.Sprintf("%s: no server-side actions, rule left out", rule.Name))
+			continue
+		}
+
+		var tests = make([]string, 0, len(rule.Conditions))
+		for j := range rule.Conditions {
+			tests = append(tests, generateTest(&rule.Conditions[j], require))
+		}
+
+		fmt.Fprintf(&body, "\n# %s %s\n", ruleComment, singleLine(rule.Name))
+		switch {
+		case len(tests) == 1:
+			fmt.Fprintf(&body, "if %s {\n", tests[0])
+		case rule.MatchAny:
+			fmt.Fprintf(&body, "if anyof (%s) {\n", strings.Join(tests, ", "))
+		default:
+			fmt.Fprintf(&body, "if allof (%s) {\n", strings.Join(tests, ", "))
+		}
+		for _, a := range unsupported {
+			fmt.Fprintf(&body, "    # not supported by Sieve: %s\n", a)
+		}
+		for _, a := range actions {
+			fmt.Fprintf(&body, "    %s;\n", a)
+		}
+		body.WriteString("}\n")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Generated by miau. Edit the rules in miau and push again.\n")
+	if len(require) > 0 {
+		var exts = make([]string, 0, len(require))
+		for ext := range require {
+			exts = append(exts, ext)
+		}
+		sort.Strings(exts)
+		fmt.Fprintf(&sb, "require %s;\n", stringList(exts))
+	}
+	sb.WriteString(body.String())
+	return sb.String(), skipped
+}
+
+// generateTest writes a condition as a Sieve test
+func generateTest(c *ports.RuleCondition, require map[string]bool) string {
+	var test string
+	switch c.Field {
+	case ports.RuleFieldHasAttachment:
+		test = fmt.Sprintf("header :contains %s %s", quote("content-type"), quote(attachmentKey))
+	case ports.RuleFieldSize:
+		var size, _ = rules.ParseSize(c.Value)
+		test = fmt.Sprintf("size :%s %s", c.Operator, formatSize(size))
+	case ports.RuleFieldBody:
+		require["body"] = true
+		test = fmt.Sprintf("body :text %s %s", matchType(c.Operator, require), quote(c.Value))
+	default:
+		var kind = "header"
+		var headers string
+		switch c.Field {
+		case ports.RuleFieldFrom:
+			headers = quote("from")
+		case ports.RuleFieldTo:
+			headers = stringList([]string{"to", "cc"})
+		case ports.RuleFieldSubject:
+			headers = quote("subject")
+		default:
+			headers = quote(c.Header)
+		}
+		// An exact address compares against the address only, not the display name
+		if c.Operator == ports.RuleOpIs && (c.Field == ports.RuleFieldFrom || c.Field == ports.RuleFieldTo) && strings.Contains(c.Value, "@") {
+			kind = "address"
+		}
+		test = fmt.Sprintf("%s %s %s %s", kind, matchType(c.Operator, require), headers, quote(c.Value))
+	}
+	if c.Negate {
+		return "not " + test
+	}
+	return test
+}
+
+func matchType(op ports.RuleOperator, require map[string]bool) string {
+	if op == ports.RuleOpRegex {
+		require["regex"] = true
+	}
+	return ":" + string(op)
+}
+
+// generateActions returns the Sieve commands of a rule, flags first and
+// fileinto last (as the rules engine runs moves last)
+func generateActions(rule *ports.Rule, require map[string]bool) (actions, unsupported []string) {
+	var flags []string
+	var redirects []string
+	var fileinto string
+
+	for _, a := range rule.Actions {
+		switch a.Type {
+		case ports.RuleActionMarkRead:
+			flags = append(flags, `\Seen`)
+		case ports.RuleActionStar:
+			flags = append(flags, `\Flagged`)
+		case ports.RuleActionLabel:
+			flags = append(flags, rules.Keyword(a.Value))
+		case ports.RuleActionForward:
+			require["copy"] = true
+			redirects = append(redirects, "redirect :copy "+quote(a.Value))
+		case ports.RuleActionMove:
+			require["fileinto"] = true
+			fileinto = "fileinto " + quote(a.Value)
+		default:
+			var desc = string(a.Type)
+			if a.Value != "" {
+				desc += " " + singleLine(a.Value)
+			}
+			unsupported = append(unsupported, desc)
+		}
+	}
+
+	if len(flags) > 0 {
+		require["imap4flags"] = true
+		if len(flags) == 1 {
+			actions = append(actions, "addflag "+quote(flags[0]))
+		} else {
+			actions = append(actions, "addflag "+stringList(flags))
+		}
+	}
+	actions = append(actions, redirects...)
+	if fileinto != "" {
+		actions = append(actions, fileinto)
+	}
+	// A moved email is done for in miau; without stop, later Sieve rules
+	// could file another copy elsewhere
+	if len(actions) > 0 && (rule.Stop || fileinto != "") {
+		actions = append(actions, "stop")
+	}
+	return actions, unsupported
+}
+
+// quote writes a Sieve quoted string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func stringList(list []string) string {
+	var quoted = make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = quote(s)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// singleLine keeps names and values from breaking out of a comment line
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
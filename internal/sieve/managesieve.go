@@ -0,0 +1,478 @@
+package sieve
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPort is the ManageSieve port (RFC 5804)
+const DefaultPort = 4190
+
+// dialTimeout bounds connecting and every command round trip
+const dialTimeout = 30 * time.Second
+
+// maxQuoted is the longest string sent quoted rather than as a literal
+const maxQuoted = 1024
+
+// Script is a script stored on the server
+type Script struct {
+	Name   string
+	Active bool
+}
+
+// ResponseError is a NO or BYE response from the server
+type ResponseError struct {
+	Status  string // NO or BYE
+	Code    string // response code, e.g. QUOTA or NONEXISTENT
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	var msg = "managesieve: " + e.Status
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Client is a ManageSieve connection. It is not safe for concurrent use.
+type Client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	caps map[string]string // upper-case capability name -> value
+}
+
+// Dial connects to addr and reads the server greeting. With tlsConfig set,
+// the connection is upgraded with STARTTLS, which the server must offer.
+func Dial(addr string, tlsConfig *tls.Config) (*Client, error) {
+	var conn, err = net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	var c, err2 = NewClient(conn)
+	if err2 != nil {
+		conn.Close()
+		return nil, err2
+	}
+	if tlsConfig != nil {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewClient wraps an open connection and reads the greeting
+func NewClient(conn net.Conn) (*Client, error) {
+	var c = &Client{conn: conn, r: bufio.NewReader(conn)}
+	var caps, err = c.readCapabilities()
+	if err != nil {
+		return nil, fmt.Errorf("invalid greeting: %w", err)
+	}
+	c.caps = caps
+	return c, nil
+}
+
+// Capability returns a capability value and whether the server announced it
+func (c *Client) Capability(name string) (string, bool) {
+	var value, ok = c.caps[strings.ToUpper(name)]
+	return value, ok
+}
+
+// SupportsSASL reports whether the server offers a SASL mechanism
+func (c *Client) SupportsSASL(mech string) bool {
+	var mechs, _ = c.Capability("SASL")
+	for _, m := range strings.Fields(mechs) {
+		if strings.EqualFold(m, mech) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartTLS upgrades the connection and refreshes the capabilities
+func (c *Client) StartTLS(config *tls.Config) error {
+	if _, ok := c.Capability("STARTTLS"); !ok {
+		return errors.New("managesieve: server does not offer STARTTLS")
+	}
+	if _, err := c.cmd("STARTTLS"); err != nil {
+		return err
+	}
+	var conn = tls.Client(c.conn, config)
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := conn.Handshake(); err != nil {
+		return fmt.Errorf("managesieve: TLS handshake failed: %w", err)
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+
+	// The server re-sends its capabilities after the handshake
+	var caps, err = c.readCapabilities()
+	if err != nil {
+		return err
+	}
+	c.caps = caps
+	return nil
+}
+
+// AuthenticatePlain logs in with SASL PLAIN
+func (c *Client) AuthenticatePlain(username, password string) error {
+	var ir = "\x00" + username + "\x00" + password
+	return c.authenticate("PLAIN", ir)
+}
+
+// AuthenticateOAuth2 logs in with an OAuth2 access token, using
+// OAUTHBEARER (RFC 7628) or XOAUTH2, whichever the server offers
+func (c *Client) AuthenticateOAuth2(username, token string) error {
+	if c.SupportsSASL("OAUTHBEARER") {
+		return c.authenticate("OAUTHBEARER", "n,a="+username+",\x01auth=Bearer "+token+"\x01\x01")
+	}
+	if c.SupportsSASL("XOAUTH2") {
+		return c.authenticate("XOAUTH2", "user="+username+"\x01auth=Bearer "+token+"\x01\x01")
+	}
+	return errors.New("managesieve: server offers no OAuth2 mechanism")
+}
+
+func (c *Client) authenticate(mech, initial string) error {
+	if !c.SupportsSASL(mech) {
+		return fmt.Errorf("managesieve: server does not offer SASL %s", mech)
+	}
+	var line = "AUTHENTICATE " + quoteArg(mech) + " " + quoteArg(base64.StdEncoding.EncodeToString([]byte(initial)))
+	if err := c.send(line); err != nil {
+		return err
+	}
+	for {
+		var fields, status, err = c.readLine()
+		if err != nil {
+			return err
+		}
+		if status != nil {
+			return status.err()
+		}
+		// A challenge after the initial response means failure details
+		// (XOAUTH2/OAUTHBEARER); an empty answer lets the server finish
+		if len(fields) > 0 {
+			if err := c.send(`""`); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ListScripts lists the scripts on the server
+func (c *Client) ListScripts() ([]Script, error) {
+	var lines, err = c.cmd("LISTSCRIPTS")
+	if err != nil {
+		return nil, err
+	}
+	var scripts []Script
+	for _, fields := range lines {
+		if len(fields) == 0 {
+			continue
+		}
+		var s = Script{Name: fields[0].value}
+		s.Active = len(fields) > 1 && strings.EqualFold(fields[1].value, "ACTIVE")
+		scripts = append(scripts, s)
+	}
+	return scripts, nil
+}
+
+// ActiveScript returns the name of the active script ("" if none)
+func (c *Client) ActiveScript() (string, error) {
+	var scripts, err = c.ListScripts()
+	if err != nil {
+		return "", err
+	}
+	for _, s := range scripts {
+		if s.Active {
+			return s.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// GetScript downloads a script
+func (c *Client) GetScript(name string) (string, error) {
+	var lines, err = c.cmd("GETSCRIPT " + quoteArg(name))
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 || len(lines[0]) == 0 {
+		return "", fmt.Errorf("managesieve: empty GETSCRIPT response")
+	}
+	return lines[0][0].value, nil
+}
+
+// CheckScript asks the server to validate a script without storing it.
+// Servers without CHECKSCRIPT (protocol version < 1.0) accept everything.
+func (c *Client) CheckScript(content string) error {
+	if _, ok := c.Capability("VERSION"); !ok {
+		return nil
+	}
+	var _, err = c.cmd("CHECKSCRIPT " + literal(content))
+	return err
+}
+
+// PutScript uploads (or replaces) a script; the server validates it
+func (c *Client) PutScript(name, content string) error {
+	var _, err = c.cmd("PUTSCRIPT " + quoteArg(name) + " " + literal(content))
+	return err
+}
+
+// SetActive activates a script; an empty name deactivates all scripts
+func (c *Client) SetActive(name string) error {
+	var _, err = c.cmd("SETACTIVE " + quoteArg(name))
+	return err
+}
+
+// DeleteScript removes a script (the active script can't be deleted)
+func (c *Client) DeleteScript(name string) error {
+	var _, err = c.cmd("DELETESCRIPT " + quoteArg(name))
+	return err
+}
+
+// Logout ends the session and closes the connection
+func (c *Client) Logout() error {
+	var _, err = c.cmd("LOGOUT")
+	var closeErr = c.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// Close closes the connection without logging out
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// === PROTOCOL ===
+
+// field is a response word: an atom, or a quoted or literal string
+type field struct {
+	value  string
+	atom   bool
+	paren  int // nesting depth inside a response code
+	opened bool
+}
+
+// status is the final OK/NO/BYE line of a response
+type status struct {
+	word    string
+	code    string
+	message string
+}
+
+func (s *status) err() error {
+	if s.word == "OK" {
+		return nil
+	}
+	return &ResponseError{Status: s.word, Code: s.code, Message: s.message}
+}
+
+func (c *Client) send(line string) error {
+	c.conn.SetDeadline(time.Now().Add(dialTimeout))
+	var _, err = io.WriteString(c.conn, line+"\r\n")
+	return err
+}
+
+// cmd sends a command and collects the data lines until its status
+func (c *Client) cmd(line string) ([][]field, error) {
+	if err := c.send(line); err != nil {
+		return nil, err
+	}
+	var lines [][]field
+	for {
+		var fields, status, err = c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if status != nil {
+			return lines, status.err()
+		}
+		lines = append(lines, fields)
+	}
+}
+
+func (c *Client) readCapabilities() (map[string]string, error) {
+	var caps = map[string]string{}
+	for {
+		var fields, status, err = c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if status != nil {
+			if err := status.err(); err != nil {
+				return nil, err
+			}
+			return caps, nil
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		var value string
+		if len(fields) > 1 {
+			value = fields[1].value
+		}
+		caps[strings.ToUpper(fields[0].value)] = value
+	}
+}
+
+// readLine reads one response line. A line starting with the atom OK, NO or
+// BYE is a status; anything else is data.
+func (c *Client) readLine() ([]field, *status, error) {
+	c.conn.SetDeadline(time.Now().Add(dialTimeout))
+	var fields, err = readFields(c.r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(fields) == 0 || !fields[0].atom {
+		return fields, nil, nil
+	}
+
+	var word = strings.ToUpper(fields[0].value)
+	if word != "OK" && word != "NO" && word != "BYE" {
+		return fields, nil, nil
+	}
+	var s = &status{word: word}
+	var rest = fields[1:]
+	if len(rest) > 0 && rest[0].opened {
+		// Response code: the words up to the matching parenthesis
+		var code []string
+		for len(rest) > 0 && rest[0].paren > 0 {
+			code = append(code, rest[0].value)
+			rest = rest[1:]
+		}
+		s.code = strings.Join(code, " ")
+	}
+	if len(rest) > 0 {
+		s.message = rest[len(rest)-1].value
+	}
+	return fields, s, nil
+}
+
+// readFields splits a response line into atoms and strings, reading
+// {n} literals from the following bytes
+func readFields(r *bufio.Reader) ([]field, error) {
+	var fields []field
+	var depth = 0
+	for {
+		var b, err = r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case b == '\r':
+			continue
+		case b == '\n':
+			return fields, nil
+		case b == ' ':
+			continue
+		case b == '(':
+			depth++
+			var f, err = readField(r)
+			if err != nil {
+				return nil, err
+			}
+			f.paren, f.opened = depth, true
+			fields = append(fields, f)
+		case b == ')':
+			if depth > 0 {
+				depth--
+			}
+		default:
+			r.UnreadByte()
+			var f, err = readField(r)
+			if err != nil {
+				return nil, err
+			}
+			f.paren = depth
+			fields = append(fields, f)
+		}
+	}
+}
+
+func readField(r *bufio.Reader) (field, error) {
+	var b, err = r.ReadByte()
+	if err != nil {
+		return field{}, err
+	}
+	switch b {
+	case '"':
+		var sb strings.Builder
+		for {
+			b, err = r.ReadByte()
+			if err != nil {
+				return field{}, err
+			}
+			if b == '"' {
+				return field{value: sb.String()}, nil
+			}
+			if b == '\\' {
+				if b, err = r.ReadByte(); err != nil {
+					return field{}, err
+				}
+			}
+			sb.WriteByte(b)
+		}
+	case '{':
+		var spec, err = r.ReadString('}')
+		if err != nil {
+			return field{}, err
+		}
+		var n, convErr = strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(spec, "}"), "+"))
+		if convErr != nil || n < 0 {
+			return field{}, fmt.Errorf("managesieve: invalid literal {%s", spec)
+		}
+		// CRLF after the literal size
+		if _, err := r.ReadString('\n'); err != nil {
+			return field{}, err
+		}
+		var buf = make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return field{}, err
+		}
+		return field{value: string(buf)}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteByte(b)
+	for {
+		b, err = r.ReadByte()
+		if err != nil {
+			return field{}, err
+		}
+		if b == ' ' || b == '\r' || b == '\n' || b == '(' || b == ')' {
+			r.UnreadByte()
+			return field{value: sb.String(), atom: true}, nil
+		}
+		sb.WriteByte(b)
+	}
+}
+
+// quoteArg writes a command argument as a quoted string, or as a literal
+// when it can't be quoted
+func quoteArg(s string) string {
+	if len(s) > maxQuoted || strings.ContainsAny(s, "\r\n\x00") {
+		return literal(s)
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// literal writes a non-synchronizing literal ({n+}), which every
+// ManageSieve server accepts
+func literal(s string) string {
+	return "{" + strconv.Itoa(len(s)) + "+}\r\n" + s
+}
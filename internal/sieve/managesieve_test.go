@@ -0,0 +1,259 @@
+package sieve
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubServer is a minimal in-process ManageSieve server
+type stubServer struct {
+	ln       net.Listener
+	username string
+	password string
+
+	mu      sync.Mutex
+	scripts map[string]string
+	active  string
+}
+
+func newStubServer(t *testing.T) *stubServer {
+	var ln, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	var s = &stubServer{ln: ln, username: "me@example.com", password: "secret", scripts: map[string]string{}}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *stubServer) serve() {
+	for {
+		var conn, err = s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *stubServer) handle(conn net.Conn) {
+	defer conn.Close()
+	var r = bufio.NewReader(conn)
+	var w = func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	var greet = func() {
+		w(`"IMPLEMENTATION" "miau stub"`)
+		w(`"SASL" "PLAIN"`)
+		w(`"SIEVE" "fileinto imap4flags copy body regex"`)
+		w(`"VERSION" "1.0"`)
+		w(`OK "ready"`)
+	}
+	greet()
+
+	var authed = false
+	for {
+		var fields, err = readFields(r)
+		if err != nil {
+			return
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		var args = make([]string, len(fields)-1)
+		for i, f := range fields[1:] {
+			args[i] = f.value
+		}
+
+		s.mu.Lock()
+		switch cmd := strings.ToUpper(fields[0].value); {
+		case cmd == "LOGOUT":
+			w(`OK "bye"`)
+			s.mu.Unlock()
+			return
+		case cmd == "CAPABILITY":
+			greet()
+		case cmd == "AUTHENTICATE":
+			var raw, _ = base64.StdEncoding.DecodeString(args[1])
+			var parts = strings.Split(string(raw), "\x00")
+			if args[0] == "PLAIN" && len(parts) == 3 && parts[1] == s.username && parts[2] == s.password {
+				authed = true
+				w(`OK "logged in"`)
+			} else {
+				w(`NO (AUTH-TOO-WEAK) "authentication failed"`)
+			}
+		case !authed:
+			w(`NO "log in first"`)
+		case cmd == "LISTSCRIPTS":
+			var names []string
+			for name := range s.scripts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if name == s.active {
+					w(`"%s" ACTIVE`, name)
+				} else {
+					w(`"%s"`, name)
+				}
+			}
+			w("OK")
+		case cmd == "PUTSCRIPT":
+			if strings.Contains(args[1], "discard") {
+				w(`NO "line 1: discard is not allowed here"`)
+				break
+			}
+			s.scripts[args[0]] = args[1]
+			w("OK")
+		case cmd == "CHECKSCRIPT":
+			w(`OK (WARNINGS) "line 2: unused variable"`)
+		case cmd == "GETSCRIPT":
+			var content, ok = s.scripts[args[0]]
+			if !ok {
+				w(`NO (NONEXISTENT) "no such script"`)
+				break
+			}
+			w("{%d}\r\n%s", len(content), content)
+			w("OK")
+		case cmd == "SETACTIVE":
+			if _, ok := s.scripts[args[0]]; !ok && args[0] != "" {
+				w(`NO (NONEXISTENT) "no such script"`)
+				break
+			}
+			s.active = args[0]
+			w("OK")
+		case cmd == "DELETESCRIPT":
+			if args[0] == s.active {
+				w(`NO (ACTIVE) "script is active"`)
+				break
+			}
+			delete(s.scripts, args[0])
+			w("OK")
+		default:
+			w(`NO "unknown command"`)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestClientSession(t *testing.T) {
+	var srv = newStubServer(t)
+
+	var c, err = Dial(srv.ln.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	if impl, _ := c.Capability("implementation"); impl != "miau stub" {
+		t.Errorf("IMPLEMENTATION = %q", impl)
+	}
+	if !c.SupportsSASL("plain") || c.SupportsSASL("XOAUTH2") {
+		t.Error("unexpected SASL mechanisms")
+	}
+
+	// Wrong password: NO with a response code
+	var authErr = c.AuthenticatePlain("me@example.com", "wrong")
+	var respErr *ResponseError
+	if !errors.As(authErr, &respErr) || respErr.Status != "NO" || respErr.Code != "AUTH-TOO-WEAK" || respErr.Message != "authentication failed" {
+		t.Fatalf("AuthenticatePlain(wrong) = %v", authErr)
+	}
+	if err := c.AuthenticatePlain("me@example.com", "secret"); err != nil {
+		t.Fatalf("AuthenticatePlain: %v", err)
+	}
+
+	// Multi-line script with quotes and backslashes goes up as a literal
+	var script = "require \"imap4flags\";\r\nif size :over 1M {\r\n    addflag \"\\\\Flagged\";\r\n}\r\n"
+	if err := c.CheckScript(script); err != nil {
+		t.Fatalf("CheckScript: %v", err)
+	}
+	if err := c.PutScript("miau", script); err != nil {
+		t.Fatalf("PutScript: %v", err)
+	}
+	if err := c.PutScript("other", `discard;`); err == nil || !strings.Contains(err.Error(), "discard is not allowed") {
+		t.Errorf("PutScript(invalid) = %v", err)
+	}
+	if err := c.PutScript("old", "keep;"); err != nil {
+		t.Fatalf("PutScript: %v", err)
+	}
+	if err := c.SetActive("miau"); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+
+	var scripts, listErr = c.ListScripts()
+	if listErr != nil {
+		t.Fatalf("ListScripts: %v", listErr)
+	}
+	var want = []Script{{Name: "miau", Active: true}, {Name: "old"}}
+	if len(scripts) != 2 || scripts[0] != want[0] || scripts[1] != want[1] {
+		t.Errorf("ListScripts = %+v", scripts)
+	}
+	if active, _ := c.ActiveScript(); active != "miau" {
+		t.Errorf("ActiveScript = %q", active)
+	}
+
+	var got, getErr = c.GetScript("miau")
+	if getErr != nil || got != script {
+		t.Errorf("GetScript = %q, %v", got, getErr)
+	}
+	if _, err := c.GetScript("missing"); !errors.As(err, &respErr) || respErr.Code != "NONEXISTENT" {
+		t.Errorf("GetScript(missing) = %v", err)
+	}
+
+	if err := c.DeleteScript("miau"); err == nil {
+		t.Error("deleting the active script should fail")
+	}
+	if err := c.DeleteScript("old"); err != nil {
+		t.Errorf("DeleteScript: %v", err)
+	}
+	if err := c.Logout(); err != nil {
+		t.Errorf("Logout: %v", err)
+	}
+}
+
+func TestClientPushGeneratedRules(t *testing.T) {
+	var srv = newStubServer(t)
+	var c, err = Dial(srv.ln.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+	if err := c.AuthenticatePlain(srv.username, srv.password); err != nil {
+		t.Fatalf("AuthenticatePlain: %v", err)
+	}
+
+	var rules, parseErr = Parse(serverScript)
+	if parseErr != nil {
+		t.Fatalf("Parse: %v", parseErr)
+	}
+	var script, _ = Generate(rules)
+	if err := c.PutScript("miau", script); err != nil {
+		t.Fatalf("PutScript: %v", err)
+	}
+
+	var back, _ = c.GetScript("miau")
+	var again, err2 = Parse(back)
+	if err2 != nil || len(again) != len(rules) {
+		t.Errorf("Parse(GetScript) = %d rules, %v", len(again), err2)
+	}
+}
+
+func TestStartTLSNotOffered(t *testing.T) {
+	var srv = newStubServer(t)
+	var c, err = Dial(srv.ln.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+	if err := c.StartTLS(nil); err == nil {
+		t.Error("StartTLS should fail when the server does not offer it")
+	}
+}
@@ -0,0 +1,705 @@
+// Package sieve converts mail filter rules to and from Sieve scripts
+// (RFC 5228) and talks to ManageSieve servers (RFC 5804), so rules built in
+// miau can run on the server while miau is closed.
+package sieve
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// ruleComment marks the comment Generate writes above each rule with its name
+const ruleComment = "rule:"
+
+// === LEXER ===
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokTag    // :contains
+	tokString // "quoted" or text: multi-line
+	tokNumber
+	tokPunct // ; , ( ) [ ] { }
+)
+
+type token struct {
+	kind    tokenKind
+	text    string
+	num     int64
+	line    int
+	comment string // last hash comment right before the token
+}
+
+type lexer struct {
+	src     string
+	pos     int
+	line    int
+	comment string
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	var tok = token{line: l.line, comment: l.comment}
+	l.comment = ""
+	if l.pos >= len(l.src) {
+		return tok, nil
+	}
+
+	var c = l.src[l.pos]
+	switch {
+	case strings.IndexByte(";,()[]{}", c) >= 0:
+		l.pos++
+		tok.kind, tok.text = tokPunct, string(c)
+	case c == '"':
+		var s, err = l.quoted()
+		if err != nil {
+			return tok, err
+		}
+		tok.kind, tok.text = tokString, s
+	case c == ':':
+		l.pos++
+		var word = l.word()
+		if word == "" {
+			return tok, l.errorf("expected tag after \":\"")
+		}
+		tok.kind, tok.text = tokTag, strings.ToLower(word)
+	case c >= '0' && c <= '9':
+		var start = l.pos
+		for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+			l.pos++
+		}
+		var n, _ = strconv.ParseInt(l.src[start:l.pos], 10, 64)
+		if l.pos < len(l.src) {
+			switch unicode.ToUpper(rune(l.src[l.pos])) {
+			case 'K':
+				n <<= 10
+				l.pos++
+			case 'M':
+				n <<= 20
+				l.pos++
+			case 'G':
+				n <<= 30
+				l.pos++
+			}
+		}
+		tok.kind, tok.num, tok.text = tokNumber, n, l.src[start:l.pos]
+	case isWordByte(c):
+		var word = l.word()
+		if strings.EqualFold(word, "text") && l.pos < len(l.src) && l.src[l.pos] == ':' {
+			l.pos++
+			var s, err = l.multiline()
+			if err != nil {
+				return tok, err
+			}
+			tok.kind, tok.text = tokString, s
+			return tok, nil
+		}
+		tok.kind, tok.text = tokIdent, strings.ToLower(word)
+	default:
+		return tok, l.errorf("unexpected character %q", c)
+	}
+	return tok, nil
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (l *lexer) word() string {
+	var start = l.pos
+	for l.pos < len(l.src) && isWordByte(l.src[l.pos]) {
+		l.pos++
+	}
+	return l.src[start:l.pos]
+}
+
+// skipSpace skips whitespace and comments, remembering the last hash comment
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		var c = l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#':
+			var end = strings.IndexByte(l.src[l.pos:], '\n')
+			if end < 0 {
+				end = len(l.src) - l.pos
+			}
+			l.comment = strings.TrimSpace(l.src[l.pos+1 : l.pos+end])
+			l.pos += end
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			var end = strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return l.errorf("unterminated comment")
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) quoted() (string, error) {
+	var start = l.line
+	var sb strings.Builder
+	l.pos++ // opening quote
+	for l.pos < len(l.src) {
+		var c = l.src[l.pos]
+		l.pos++
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if l.pos < len(l.src) {
+				c = l.src[l.pos]
+				l.pos++
+			}
+		case '\n':
+			l.line++
+		}
+		sb.WriteByte(c)
+	}
+	return "", fmt.Errorf("line %d: unterminated string", start)
+}
+
+// multiline reads a "text:" string: lines up to a lone ".", with dot-stuffing
+func (l *lexer) multiline() (string, error) {
+	var start = l.line
+	var eol = strings.IndexByte(l.src[l.pos:], '\n')
+	if eol < 0 {
+		return "", l.errorf("unterminated text: string")
+	}
+	l.pos += eol + 1
+	l.line++
+
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		var end = strings.IndexByte(l.src[l.pos:], '\n')
+		if end < 0 {
+			end = len(l.src) - l.pos
+		}
+		var line = strings.TrimSuffix(l.src[l.pos:l.pos+end], "\r")
+		l.pos += end + 1
+		l.line++
+		if line == "." {
+			return sb.String(), nil
+		}
+		sb.WriteString(strings.TrimPrefix(line, "."))
+		sb.WriteString("\n")
+	}
+	return "", fmt.Errorf("line %d: unterminated text: string", start)
+}
+
+// === PARSER ===
+
+// argument is a positional or tagged argument of a command or test
+type argument struct {
+	kind tokenKind // tokTag, tokString (string list) or tokNumber
+	tag  string
+	strs []string
+	num  int64
+}
+
+type test struct {
+	name  string
+	line  int
+	args  []argument
+	tests []test
+}
+
+type command struct {
+	name    string
+	line    int
+	comment string
+	args    []argument
+	tests   []test
+	block   []command
+	hasBody bool
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	var tok, err = p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.tok.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) isPunct(s string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return p.errorf("expected %q", s)
+	}
+	return p.advance()
+}
+
+func (p *parser) commands(inBlock bool) ([]command, error) {
+	var list []command
+	for {
+		if p.tok.kind == tokEOF {
+			if inBlock {
+				return nil, p.errorf("unexpected end of script, expected \"}\"")
+			}
+			return list, nil
+		}
+		if inBlock && p.isPunct("}") {
+			return list, p.advance()
+		}
+		if p.tok.kind != tokIdent {
+			return nil, p.errorf("expected a command")
+		}
+
+		var cmd = command{name: p.tok.text, line: p.tok.line, comment: p.tok.comment}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var args, tests, err = p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		cmd.args, cmd.tests = args, tests
+
+		if p.isPunct("{") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if cmd.block, err = p.commands(true); err != nil {
+				return nil, err
+			}
+			cmd.hasBody = true
+		} else if err := p.expectPunct(";"); err != nil {
+			return nil, err
+		}
+		list = append(list, cmd)
+	}
+}
+
+// arguments reads arguments followed by an optional test or test list
+func (p *parser) arguments() ([]argument, []test, error) {
+	var args []argument
+	for {
+		switch {
+		case p.tok.kind == tokTag:
+			args = append(args, argument{kind: tokTag, tag: p.tok.text})
+		case p.tok.kind == tokNumber:
+			args = append(args, argument{kind: tokNumber, num: p.tok.num})
+		case p.tok.kind == tokString:
+			args = append(args, argument{kind: tokString, strs: []string{p.tok.text}})
+		case p.isPunct("["):
+			var list, err = p.stringList()
+			if err != nil {
+				return nil, nil, err
+			}
+			args = append(args, argument{kind: tokString, strs: list})
+			continue
+		case p.isPunct("("):
+			var tests, err = p.testList()
+			return args, tests, err
+		case p.tok.kind == tokIdent:
+			var t, err = p.test()
+			return args, []test{t}, err
+		default:
+			return args, nil, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+func (p *parser) stringList() ([]string, error) {
+	var list []string
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for {
+		if p.tok.kind != tokString {
+			return nil, p.errorf("expected a string in list")
+		}
+		list = append(list, p.tok.text)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.isPunct("]") {
+			return list, p.advance()
+		}
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) testList() ([]test, error) {
+	var list []test
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for {
+		if p.tok.kind != tokIdent {
+			return nil, p.errorf("expected a test")
+		}
+		var t, err = p.test()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+		if p.isPunct(")") {
+			return list, p.advance()
+		}
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) test() (test, error) {
+	var t = test{name: p.tok.text, line: p.tok.line}
+	if err := p.advance(); err != nil {
+		return t, err
+	}
+	var args, tests, err = p.arguments()
+	t.args, t.tests = args, tests
+	return t, err
+}
+
+// === CONVERSION ===
+
+// Parse converts a Sieve script into rules. Each top-level "if" becomes a
+// rule, named after a preceding "# rule: Name" comment when there is one.
+// Constructs rules can't represent (elsif/else, nested ifs, discard,
+// vacation, ...) are reported as errors rather than silently dropped.
+func Parse(script string) ([]ports.Rule, error) {
+	var p = &parser{lex: &lexer{src: script, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var cmds, err = p.commands(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []ports.Rule
+	for i, cmd := range cmds {
+		switch cmd.name {
+		case "require", "keep":
+			continue
+		case "stop":
+			// Top-level stop only ends the script
+			continue
+		case "if":
+			if i+1 < len(cmds) && (cmds[i+1].name == "elsif" || cmds[i+1].name == "else") {
+				return nil, fmt.Errorf("line %d: %s is not supported", cmds[i+1].line, cmds[i+1].name)
+			}
+			var rule, err = convertIf(&cmd)
+			if err != nil {
+				return nil, err
+			}
+			rule.Position = len(list)
+			if rule.Name == "" {
+				rule.Name = fmt.Sprintf("Sieve rule %d", len(list)+1)
+			}
+			list = append(list, rule)
+		default:
+			return nil, fmt.Errorf("line %d: %s at top level is not supported", cmd.line, cmd.name)
+		}
+	}
+	return list, nil
+}
+
+func convertIf(cmd *command) (ports.Rule, error) {
+	var rule = ports.Rule{Enabled: true}
+	if strings.HasPrefix(cmd.comment, ruleComment) {
+		rule.Name = strings.TrimSpace(strings.TrimPrefix(cmd.comment, ruleComment))
+	}
+	if len(cmd.tests) != 1 || len(cmd.args) > 0 || !cmd.hasBody {
+		return rule, fmt.Errorf("line %d: if needs exactly one test and a block", cmd.line)
+	}
+
+	var conds, matchAny, err = convertTopTest(&cmd.tests[0])
+	if err != nil {
+		return rule, err
+	}
+	rule.Conditions, rule.MatchAny = conds, matchAny
+
+	for _, action := range cmd.block {
+		var actions, stop, err = convertAction(&action)
+		if err != nil {
+			return rule, err
+		}
+		rule.Actions = append(rule.Actions, actions...)
+		rule.Stop = rule.Stop || stop
+	}
+	if len(rule.Actions) == 0 {
+		return rule, fmt.Errorf("line %d: rule has no supported actions", cmd.line)
+	}
+	return rule, nil
+}
+
+// convertTopTest flattens the test of an if into conditions joined by
+// allof (matchAny false) or anyof (matchAny true)
+func convertTopTest(t *test) ([]ports.RuleCondition, bool, error) {
+	var negate = false
+	for t.name == "not" {
+		if len(t.tests) != 1 {
+			return nil, false, fmt.Errorf("line %d: not needs one test", t.line)
+		}
+		negate = !negate
+		t = &t.tests[0]
+	}
+
+	if t.name != "allof" && t.name != "anyof" {
+		var conds, joinAny, err = convertTest(t, negate)
+		return conds, joinAny && len(conds) > 1, err
+	}
+
+	// not allof(a, b) == anyof(not a, not b) and vice versa
+	var matchAny = (t.name == "anyof") != negate
+	var list []ports.RuleCondition
+	for i := range t.tests {
+		var child = &t.tests[i]
+		var childNegate = negate
+		for child.name == "not" && len(child.tests) == 1 {
+			childNegate = !childNegate
+			child = &child.tests[0]
+		}
+		if child.name == "allof" || child.name == "anyof" {
+			return nil, false, fmt.Errorf("line %d: nested %s is not supported", child.line, child.name)
+		}
+		var conds, joinAny, err = convertTest(child, childNegate)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(conds) > 1 && joinAny != matchAny {
+			return nil, false, fmt.Errorf("line %d: string lists here can't be expressed as a single rule", child.line)
+		}
+		list = append(list, conds...)
+	}
+	return list, matchAny, nil
+}
+
+// convertTest converts a single test. Lists of headers or keys expand to
+// several conditions, which are alternatives (joinAny) unless negated.
+func convertTest(t *test, negate bool) ([]ports.RuleCondition, bool, error) {
+	switch t.name {
+	case "header", "address", "body":
+		return convertMatchTest(t, negate)
+	case "size":
+		var op ports.RuleOperator
+		var size int64 = -1
+		for _, a := range t.args {
+			switch {
+			case a.kind == tokTag && (a.tag == "over" || a.tag == "under"):
+				op = ports.RuleOperator(a.tag)
+			case a.kind == tokNumber:
+				size = a.num
+			default:
+				return nil, false, fmt.Errorf("line %d: invalid size test", t.line)
+			}
+		}
+		if op == "" || size < 0 {
+			return nil, false, fmt.Errorf("line %d: size needs :over or :under and a number", t.line)
+		}
+		return []ports.RuleCondition{{Field: ports.RuleFieldSize, Operator: op, Value: formatSize(size), Negate: negate}}, false, nil
+	}
+	return nil, false, fmt.Errorf("line %d: test %q is not supported", t.line, t.name)
+}
+
+func convertMatchTest(t *test, negate bool) ([]ports.RuleCondition, bool, error) {
+	var op = ports.RuleOpIs
+	var lists [][]string
+	for _, a := range t.args {
+		switch {
+		case a.kind == tokTag:
+			switch a.tag {
+			case "is", "contains", "matches", "regex":
+				op = ports.RuleOperator(a.tag)
+			case "all", "text":
+				// Defaults for address and body
+			case "comparator":
+				// Comparisons are always case-insensitive; the comparator name follows
+			default:
+				return nil, false, fmt.Errorf("line %d: %s :%s is not supported", t.line, t.name, a.tag)
+			}
+		case a.kind == tokString:
+			lists = append(lists, a.strs)
+		default:
+			return nil, false, fmt.Errorf("line %d: unexpected number in %s", t.line, t.name)
+		}
+	}
+	// A :comparator value is the first string list when present
+	for _, a := range t.args {
+		if a.kind == tokTag && a.tag == "comparator" && len(lists) > 0 {
+			lists = lists[1:]
+			break
+		}
+	}
+
+	var headers, keys []string
+	switch {
+	case t.name == "body" && len(lists) == 1:
+		keys = lists[0]
+	case t.name != "body" && len(lists) == 2:
+		headers, keys = lists[0], lists[1]
+	default:
+		return nil, false, fmt.Errorf("line %d: wrong number of arguments to %s", t.line, t.name)
+	}
+
+	var conds []ports.RuleCondition
+	var add = func(field ports.RuleField, header string) {
+		for _, key := range keys {
+			conds = append(conds, ports.RuleCondition{Field: field, Header: header, Operator: op, Value: key, Negate: negate})
+		}
+	}
+
+	if t.name == "body" {
+		add(ports.RuleFieldBody, "")
+		return conds, !negate, nil
+	}
+
+	// Attachment check written by Generate
+	if t.name == "header" && op == ports.RuleOpContains && len(headers) == 1 && len(keys) == 1 &&
+		strings.EqualFold(headers[0], "content-type") && strings.EqualFold(keys[0], attachmentKey) {
+		return []ports.RuleCondition{{Field: ports.RuleFieldHasAttachment, Negate: negate}}, false, nil
+	}
+
+	var lower = make([]string, len(headers))
+	for i, h := range headers {
+		lower[i] = strings.ToLower(h)
+	}
+	var joined = strings.Join(lower, ",")
+	switch {
+	case joined == "from":
+		add(ports.RuleFieldFrom, "")
+	case joined == "to,cc" || joined == "cc,to" || joined == "to" || joined == "cc":
+		add(ports.RuleFieldTo, "")
+	case joined == "subject":
+		add(ports.RuleFieldSubject, "")
+	default:
+		for _, h := range headers {
+			add(ports.RuleFieldHeader, h)
+		}
+	}
+	return conds, !negate, nil
+}
+
+// convertAction converts a command inside an if block
+func convertAction(cmd *command) ([]ports.RuleAction, bool, error) {
+	if cmd.hasBody || len(cmd.tests) > 0 {
+		return nil, false, fmt.Errorf("line %d: nested %s is not supported", cmd.line, cmd.name)
+	}
+
+	var strs [][]string
+	var tags []string
+	for _, a := range cmd.args {
+		switch a.kind {
+		case tokString:
+			strs = append(strs, a.strs)
+		case tokTag:
+			tags = append(tags, a.tag)
+		default:
+			return nil, false, fmt.Errorf("line %d: unexpected number in %s", cmd.line, cmd.name)
+		}
+	}
+	var single = func() (string, error) {
+		if len(strs) != 1 || len(strs[0]) != 1 {
+			return "", fmt.Errorf("line %d: %s needs one string", cmd.line, cmd.name)
+		}
+		return strs[0][0], nil
+	}
+
+	switch cmd.name {
+	case "stop":
+		return nil, true, nil
+	case "keep":
+		return nil, false, nil
+	case "fileinto":
+		for _, tag := range tags {
+			if tag != "create" {
+				return nil, false, fmt.Errorf("line %d: fileinto :%s is not supported", cmd.line, tag)
+			}
+		}
+		var folder, err = single()
+		if err != nil {
+			return nil, false, err
+		}
+		return []ports.RuleAction{{Type: ports.RuleActionMove, Value: folder}}, false, nil
+	case "redirect":
+		// Rules always keep the original, so :copy is implied
+		for _, tag := range tags {
+			if tag != "copy" {
+				return nil, false, fmt.Errorf("line %d: redirect :%s is not supported", cmd.line, tag)
+			}
+		}
+		var addr, err = single()
+		if err != nil {
+			return nil, false, err
+		}
+		return []ports.RuleAction{{Type: ports.RuleActionForward, Value: addr}}, false, nil
+	case "addflag", "setflag":
+		if len(strs) != 1 || len(tags) > 0 {
+			return nil, false, fmt.Errorf("line %d: only %s with a flag list is supported", cmd.line, cmd.name)
+		}
+		var actions []ports.RuleAction
+		for _, value := range strs[0] {
+			// imap4flags splits each string on spaces
+			for _, flag := range strings.Fields(value) {
+				actions = append(actions, flagAction(flag))
+			}
+		}
+		return actions, false, nil
+	}
+	return nil, false, fmt.Errorf("line %d: action %q is not supported", cmd.line, cmd.name)
+}
+
+func flagAction(flag string) ports.RuleAction {
+	switch strings.ToLower(flag) {
+	case `\seen`:
+		return ports.RuleAction{Type: ports.RuleActionMarkRead}
+	case `\flagged`:
+		return ports.RuleAction{Type: ports.RuleActionStar}
+	}
+	return ports.RuleAction{Type: ports.RuleActionLabel, Value: flag}
+}
+
+// formatSize writes bytes with the largest exact Sieve quantifier
+func formatSize(n int64) string {
+	switch {
+	case n > 0 && n%(1<<30) == 0:
+		return strconv.FormatInt(n>>30, 10) + "G"
+	case n > 0 && n%(1<<20) == 0:
+		return strconv.FormatInt(n>>20, 10) + "M"
+	case n > 0 && n%(1<<10) == 0:
+		return strconv.FormatInt(n>>10, 10) + "K"
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,210 @@
+package sieve
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+const serverScript = `require ["fileinto", "imap4flags", "copy", "body"];
+/* Written by hand on the server */
+
+# rule: Invoices
+if allof (address :is "from" "billing@vendor.com", not header :contains "subject" "receipt") {
+    addflag ["\\Seen", "finance"];
+    fileinto :create "Finance";
+    stop;
+}
+
+if anyof (header :matches ["list-id", "list-post"] "*example*", size :over 5M) {
+    redirect :copy "archive@example.com";
+}
+
+if body :text :contains ["unsubscribe", "opt out"] {
+    setflag "\\Flagged newsletter";
+}
+`
+
+func TestParse(t *testing.T) {
+	var list, err = Parse(serverScript)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("got %d rules, want 3", len(list))
+	}
+
+	var invoices = list[0]
+	if invoices.Name != "Invoices" || !invoices.Enabled || invoices.MatchAny || !invoices.Stop {
+		t.Errorf("unexpected rule: %+v", invoices)
+	}
+	var wantConds = []ports.RuleCondition{
+		{Field: ports.RuleFieldFrom, Operator: ports.RuleOpIs, Value: "billing@vendor.com"},
+		{Field: ports.RuleFieldSubject, Operator: ports.RuleOpContains, Value: "receipt", Negate: true},
+	}
+	if !reflect.DeepEqual(invoices.Conditions, wantConds) {
+		t.Errorf("conditions = %+v", invoices.Conditions)
+	}
+	var wantActions = []ports.RuleAction{
+		{Type: ports.RuleActionMarkRead},
+		{Type: ports.RuleActionLabel, Value: "finance"},
+		{Type: ports.RuleActionMove, Value: "Finance"},
+	}
+	if !reflect.DeepEqual(invoices.Actions, wantActions) {
+		t.Errorf("actions = %+v", invoices.Actions)
+	}
+
+	var lists = list[1]
+	if lists.Name != "Sieve rule 2" || !lists.MatchAny || len(lists.Conditions) != 3 {
+		t.Errorf("unexpected rule: %+v", lists)
+	}
+	if lists.Conditions[1].Header != "list-post" || lists.Conditions[2].Value != "5M" {
+		t.Errorf("conditions = %+v", lists.Conditions)
+	}
+
+	// A lone test with a key list matches any of the keys
+	var body = list[2]
+	if !body.MatchAny || len(body.Conditions) != 2 || body.Conditions[1].Value != "opt out" {
+		t.Errorf("unexpected rule: %+v", body)
+	}
+	if len(body.Actions) != 2 || body.Actions[0].Type != ports.RuleActionStar || body.Actions[1].Value != "newsletter" {
+		t.Errorf("actions = %+v", body.Actions)
+	}
+}
+
+func TestParseNegatedLists(t *testing.T) {
+	// not anyof(a, b) == allof(not a, not b)
+	var list, err = Parse(`if not anyof (header :contains "subject" "a", size :under 1K) { keep; addflag "x"; }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var rule = list[0]
+	if rule.MatchAny || !rule.Conditions[0].Negate || !rule.Conditions[1].Negate {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+
+	// A key list inside allof needs every key, which one rule can't express
+	if _, err := Parse(`if allof (header :contains "subject" ["a", "b"], size :under 1K) { addflag "x"; }`); err == nil {
+		t.Error("expected an error for a key list inside allof")
+	}
+}
+
+func TestParseUnsupported(t *testing.T) {
+	var tests = map[string]string{
+		`if size :over 1M { discard; }`:                                      `action "discard" is not supported`,
+		`if size :over 1M { fileinto "a"; } else { fileinto "b"; }`:          "else is not supported",
+		`if size :over 1M { if size :over 2M { stop; } }`:                    "nested if",
+		"if exists \"x\" {\n addflag \"x\";\n}":                              `line 1: test "exists" is not supported`,
+		`vacation "away";`:                                                   "vacation at top level",
+		`if header :contains "subject" "x" { addflag "y" }`:                  `expected ";"`,
+		"if header :contains \"subject\" \"x {\n stop;\n}":                   "line 1: unterminated string",
+		`if address :domain :is "from" "example.com" { fileinto "Example"; }`: "address :domain is not supported",
+	}
+	for in, want := range tests {
+		var _, err = Parse(in)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error = %v, want %q", in, err, want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var list = []ports.Rule{
+		{
+			Name:    "Work \"stuff\"",
+			Enabled: true,
+			Conditions: []ports.RuleCondition{
+				{Field: ports.RuleFieldTo, Operator: ports.RuleOpIs, Value: "team@example.com"},
+				{Field: ports.RuleFieldHasAttachment},
+				{Field: ports.RuleFieldBody, Operator: ports.RuleOpRegex, Value: `PO-\d+`},
+			},
+			Actions: []ports.RuleAction{
+				{Type: ports.RuleActionMove, Value: "Work"},
+				{Type: ports.RuleActionLabel, Value: "Work Stuff"},
+				{Type: ports.RuleActionSnooze, Value: "tomorrow"},
+				{Type: ports.RuleActionForward, Value: "boss@example.com"},
+			},
+		},
+		{
+			Name:       "Tasks only",
+			Enabled:    true,
+			Conditions: []ports.RuleCondition{{Field: ports.RuleFieldFrom, Operator: ports.RuleOpContains, Value: "jira"}},
+			Actions:    []ports.RuleAction{{Type: ports.RuleActionCreateTask}},
+		},
+		{
+			Name:       "Disabled",
+			Conditions: []ports.RuleCondition{{Field: ports.RuleFieldFrom, Operator: ports.RuleOpContains, Value: "x"}},
+			Actions:    []ports.RuleAction{{Type: ports.RuleActionStar}},
+		},
+	}
+
+	var script, skipped = Generate(list)
+
+	for _, want := range []string{
+		`require ["body", "copy", "fileinto", "imap4flags", "regex"];`,
+		`# rule: Work "stuff"`,
+		`if allof (address :is ["to", "cc"] "team@example.com", header :contains "content-type" "multipart/mixed", body :text :regex "PO-\\d+") {`,
+		`    # not supported by Sieve: snooze tomorrow`,
+		"    addflag \"Work_Stuff\";\n    redirect :copy \"boss@example.com\";\n    fileinto \"Work\";\n    stop;\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script is missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "Tasks only") || strings.Contains(script, "Disabled") {
+		t.Errorf("script should leave out rules without Sieve actions and disabled rules:\n%s", script)
+	}
+	var wantSkipped = []string{"Work \"stuff\": snooze tomorrow", "Tasks only: create_task", "Tasks only: no server-side actions, rule left out"}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("skipped = %q", skipped)
+	}
+}
+
+func TestGenerateParseRoundTrip(t *testing.T) {
+	var list = []ports.Rule{
+		{
+			Name:     "Lists",
+			Enabled:  true,
+			MatchAny: true,
+			Conditions: []ports.RuleCondition{
+				{Field: ports.RuleFieldHeader, Header: "List-Id", Operator: ports.RuleOpMatches, Value: "*.example.com>"},
+				{Field: ports.RuleFieldSize, Operator: ports.RuleOpOver, Value: "2M"},
+				{Field: ports.RuleFieldHasAttachment, Negate: true},
+			},
+			Actions: []ports.RuleAction{{Type: ports.RuleActionMarkRead}, {Type: ports.RuleActionStar}, {Type: ports.RuleActionLabel, Value: "lists"}},
+			Stop:    true,
+		},
+		{
+			Name:       "Finance",
+			Enabled:    true,
+			Position:   1,
+			Conditions: []ports.RuleCondition{{Field: ports.RuleFieldFrom, Operator: ports.RuleOpContains, Value: `ACME "billing"`}},
+			Actions:    []ports.RuleAction{{Type: ports.RuleActionMove, Value: "Finance/2024"}},
+			Stop:       true, // implied by the move
+		},
+	}
+
+	var script, skipped = Generate(list)
+	if len(skipped) != 0 {
+		t.Errorf("skipped = %q", skipped)
+	}
+	var parsed, err = Parse(script)
+	if err != nil {
+		t.Fatalf("Parse(Generate()): %v\n%s", err, script)
+	}
+	if !reflect.DeepEqual(parsed, list) {
+		t.Errorf("round trip changed the rules:\n got %+v\nwant %+v\n%s", parsed, list, script)
+	}
+}
+
+func TestMultilineString(t *testing.T) {
+	var list, err = Parse("if header :contains \"subject\" text:\nhello\n..dots\n.\n{ addflag \"x\"; }")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := list[0].Conditions[0].Value; got != "hello\n.dots\n" {
+		t.Errorf("value = %q", got)
+	}
+}
@@ -0,0 +1,168 @@
+// Package smime implements S/MIME (RFC 8551) for miau: PKCS#12 identities,
+// a store of correspondents' certificates, signing, encryption, decryption
+// and verification with certificate chain checks.
+package smime
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	ErrCertNotFound = errors.New("smime: certificate not found")
+	ErrNoIdentity   = errors.New("smime: no identity")
+)
+
+// oidEmailAddress is the legacy emailAddress attribute of a subject DN,
+// still used by many S/MIME certificates instead of a SAN.
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+// Identity is a certificate with its private key, used to sign outgoing
+// mail and decrypt incoming mail.
+type Identity struct {
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate // intermediate CAs sent along with signatures
+	Key         crypto.PrivateKey
+}
+
+// LoadIdentity reads a PKCS#12 (.p12/.pfx) file.
+func LoadIdentity(path, password string) (*Identity, error) {
+	var data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIdentity(data, password)
+}
+
+// ParseIdentity decodes a PKCS#12 bundle holding a private key, its
+// certificate and optionally the CA chain.
+func ParseIdentity(data []byte, password string) (*Identity, error) {
+	var key, cert, chain, err = pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("smime: %w", err)
+	}
+	if _, ok := key.(crypto.Signer); !ok {
+		return nil, errors.New("smime: unsupported private key type")
+	}
+	return &Identity{Certificate: cert, Chain: chain, Key: key}, nil
+}
+
+// Certificate describes an X.509 certificate.
+type Certificate struct {
+	Fingerprint string // SHA-256, uppercase hex
+	Subject     string
+	Issuer      string
+	Emails      []string // lowercased
+	NotBefore   time.Time
+	NotAfter    time.Time
+}
+
+// Describe summarizes a certificate.
+func Describe(cert *x509.Certificate) Certificate {
+	return Certificate{
+		Fingerprint: Fingerprint(cert),
+		Subject:     Name(cert),
+		Issuer:      cert.Issuer.String(),
+		Emails:      Emails(cert),
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+	}
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate.
+func Fingerprint(cert *x509.Certificate) string {
+	var sum = sha256.Sum256(cert.Raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Emails returns the addresses a certificate is issued for: the SAN
+// rfc822Name entries and the legacy emailAddress subject attribute.
+func Emails(cert *x509.Certificate) []string {
+	var emails []string
+	var add = func(addr string) {
+		addr = strings.ToLower(strings.TrimSpace(addr))
+		for _, e := range emails {
+			if e == addr {
+				return
+			}
+		}
+		if addr != "" {
+			emails = append(emails, addr)
+		}
+	}
+	for _, addr := range cert.EmailAddresses {
+		add(addr)
+	}
+	for _, name := range cert.Subject.Names {
+		if name.Type.Equal(oidEmailAddress) {
+			if addr, ok := name.Value.(string); ok {
+				add(addr)
+			}
+		}
+	}
+	return emails
+}
+
+// Name formats the subject of a certificate as "Common Name <email>".
+func Name(cert *x509.Certificate) string {
+	var emails = Emails(cert)
+	switch {
+	case cert.Subject.CommonName != "" && len(emails) > 0:
+		return cert.Subject.CommonName + " <" + emails[0] + ">"
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(emails) > 0:
+		return emails[0]
+	}
+	return cert.Subject.String()
+}
+
+func hasEmail(cert *x509.Certificate, addr string) bool {
+	addr = strings.ToLower(strings.TrimSpace(addr))
+	for _, e := range Emails(cert) {
+		if e == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// canEncrypt reports whether mail can be encrypted to a certificate now.
+// Encryption is limited to RSA keys, the only ones the CMS implementation
+// supports for key transport.
+func canEncrypt(cert *x509.Certificate, now time.Time) bool {
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return false
+	}
+	if cert.PublicKeyAlgorithm != x509.RSA {
+		return false
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageKeyEncipherment == 0 {
+		return false
+	}
+	return allowsEmail(cert)
+}
+
+// allowsEmail reports whether the extended key usage, when present,
+// includes email protection.
+func allowsEmail(cert *x509.Certificate) bool {
+	if len(cert.ExtKeyUsage) == 0 {
+		return true
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageEmailProtection || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
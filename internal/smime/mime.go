@@ -0,0 +1,373 @@
+package smime
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/opik/miau/internal/email/message"
+	"github.com/smallstep/pkcs7"
+)
+
+func init() {
+	// The CMS default is DES-CBC; AES-256-CBC is what current clients
+	// (Outlook, Apple Mail, Thunderbird) all read.
+	pkcs7.ContentEncryptionAlgorithm = pkcs7.EncryptionAlgorithmAES256CBC
+}
+
+// Status is the outcome of checking a signature.
+type Status string
+
+const (
+	StatusNone       Status = ""
+	StatusValid      Status = "valid"
+	StatusInvalid    Status = "invalid"
+	StatusUnknownKey Status = "unknown_key" // the signer certificate is not in the message
+	StatusMismatch   Status = "mismatch"    // valid, but the certificate is not for the From address
+)
+
+// Chain is the outcome of validating the signer certificate chain.
+type Chain string
+
+const (
+	ChainNone      Chain = ""
+	ChainTrusted   Chain = "trusted"
+	ChainUntrusted Chain = "untrusted" // issued by an unknown CA, or self-signed
+	ChainExpired   Chain = "expired"
+	ChainInvalid   Chain = "invalid" // wrong key usage, broken chain, ...
+)
+
+// Result is what Open found in a message.
+type Result struct {
+	// Raw is the message with the S/MIME layers removed: the original outer
+	// headers followed by the decrypted or signed entity. It is the original
+	// message when nothing could be opened.
+	Raw         []byte
+	Encrypted   bool
+	Signature   Status
+	Chain       Chain
+	Signer      string            // "Common Name <email>" of the signer certificate
+	Certificate *x509.Certificate // signer certificate, with a valid signature only
+	Error       string
+}
+
+// Sign wraps a complete RFC 5322 message in a multipart/signed entity with
+// a detached CMS signature (RFC 8551 section 3.5.3), so clients without
+// S/MIME can still read it.
+func Sign(raw []byte, id *Identity) ([]byte, error) {
+	var outer, inner = message.SplitEntity(message.Canonicalize(raw))
+
+	var der, err = signDetached(inner, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var boundary = newBoundary()
+	var body bytes.Buffer
+	body.WriteString("This is an S/MIME signed message\r\n")
+	body.WriteString("--" + boundary + "\r\n")
+	body.Write(inner)
+	body.WriteString("\r\n--" + boundary + "\r\n")
+	body.WriteString("Content-Type: application/pkcs7-signature; name=\"smime.p7s\"\r\n")
+	body.WriteString("Content-Transfer-Encoding: base64\r\n")
+	body.WriteString("Content-Disposition: attachment; filename=\"smime.p7s\"\r\n")
+	body.WriteString("Content-Description: S/MIME Cryptographic Signature\r\n\r\n")
+	body.Write(encodeBase64(der))
+	body.WriteString("--" + boundary + "--\r\n")
+
+	var contentType = mime.FormatMediaType("multipart/signed", map[string]string{
+		"boundary": boundary,
+		"micalg":   "sha-256",
+		"protocol": "application/pkcs7-signature",
+	})
+	return message.Assemble(outer, contentType, body.Bytes()), nil
+}
+
+// Encrypt wraps a complete RFC 5322 message in an application/pkcs7-mime
+// enveloped-data entity (RFC 8551 section 3.3). Sign first to send a
+// signed and encrypted message.
+func Encrypt(raw []byte, to []*x509.Certificate) ([]byte, error) {
+	if len(to) == 0 {
+		return nil, errors.New("smime: no recipients")
+	}
+	var outer, inner = message.SplitEntity(message.Canonicalize(raw))
+
+	var der, err = pkcs7.Encrypt(inner, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var contentType = mime.FormatMediaType("application/pkcs7-mime", map[string]string{
+		"smime-type": "enveloped-data",
+		"name":       "smime.p7m",
+	})
+	outer = append(outer,
+		[]byte("Content-Transfer-Encoding: base64\r\n"),
+		[]byte("Content-Disposition: attachment; filename=\"smime.p7m\"\r\n"),
+		[]byte("Content-Description: S/MIME Encrypted Message\r\n"))
+	return message.Assemble(outer, contentType, encodeBase64(der)), nil
+}
+
+// Open decrypts and verifies an S/MIME message with the given identities,
+// checking signer chains against roots (nil = the system pool). Messages
+// without S/MIME come back unchanged with an empty status. Failures to
+// decrypt or verify are reported in Result.Error rather than as errors, so
+// the caller can still show the message.
+func Open(raw []byte, ids []*Identity, roots *x509.CertPool) (*Result, error) {
+	raw = message.Canonicalize(raw)
+	var res = &Result{Raw: raw}
+
+	// An encrypted message may hold a signed one; signatures are not
+	// nested further.
+	for depth := 0; depth < 2; depth++ {
+		var outer, _ = message.SplitEntity(res.Raw)
+		var mediaType, params, err = mime.ParseMediaType(message.HeaderValue(res.Raw, "Content-Type"))
+		if err != nil {
+			break
+		}
+
+		switch {
+		case isPKCS7Mime(mediaType) && !strings.EqualFold(params["smime-type"], "signed-data") && !res.Encrypted:
+			res.Encrypted = true
+			var inner, err = decrypt(res.Raw, ids)
+			if err != nil {
+				res.Error = err.Error()
+				return res, nil
+			}
+			res.Raw = message.AssembleEntity(outer, inner)
+			continue
+		case isPKCS7Mime(mediaType) && strings.EqualFold(params["smime-type"], "signed-data"):
+			verifyOpaque(res.Raw, roots, res)
+		case mediaType == "multipart/signed" && isPKCS7Signature(params["protocol"]):
+			verifyDetached(res.Raw, params["boundary"], roots, res)
+		}
+		break
+	}
+
+	if res.Signature == StatusValid {
+		checkSender(raw, res)
+	}
+	return res, nil
+}
+
+func signDetached(content []byte, id *Identity) ([]byte, error) {
+	var sd, err = pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSigner(id.Certificate, id.Key, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	for _, cert := range id.Chain {
+		sd.AddCertificate(cert)
+	}
+	sd.Detach()
+	return sd.Finish()
+}
+
+func decrypt(raw []byte, ids []*Identity) ([]byte, error) {
+	var der, err = entityBody(raw)
+	if err != nil {
+		return nil, err
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrNoIdentity
+	}
+
+	var lastErr error = ErrNoIdentity
+	for _, id := range ids {
+		var inner, err = p7.Decrypt(id.Certificate, id.Key)
+		if err == nil {
+			return message.Canonicalize(inner), nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// verifyDetached checks a multipart/signed message: the first part is the
+// signed entity, exactly as transmitted.
+func verifyDetached(raw []byte, boundary string, roots *x509.CertPool, res *Result) {
+	var outer, body = message.SplitMessage(raw)
+	var signed, rest, ok = message.FirstPart(body, boundary)
+	if !ok {
+		res.Signature = StatusInvalid
+		res.Error = "malformed multipart/signed message"
+		return
+	}
+
+	var sig []byte
+	var mr = multipart.NewReader(bytes.NewReader(rest), boundary)
+	for {
+		var part, err = mr.NextPart()
+		if err != nil {
+			break
+		}
+		var mediaType, _, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if isPKCS7Signature(mediaType) {
+			sig, _ = io.ReadAll(part)
+			if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+				sig, _ = decodeBase64(sig)
+			}
+			break
+		}
+	}
+	if len(sig) == 0 {
+		res.Signature = StatusInvalid
+		res.Error = "signature part not found"
+		return
+	}
+
+	p7, err := pkcs7.Parse(sig)
+	if err != nil {
+		res.Signature = StatusInvalid
+		res.Error = err.Error()
+		return
+	}
+	p7.Content = signed
+	check(p7, roots, res)
+	res.Raw = message.AssembleEntity(outer, signed)
+}
+
+// verifyOpaque checks an application/pkcs7-mime signed-data entity, which
+// carries the signed entity inside the CMS structure.
+func verifyOpaque(raw []byte, roots *x509.CertPool, res *Result) {
+	var outer, _ = message.SplitEntity(raw)
+	var der, err = entityBody(raw)
+	if err != nil {
+		res.Signature = StatusInvalid
+		res.Error = err.Error()
+		return
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		res.Signature = StatusInvalid
+		res.Error = err.Error()
+		return
+	}
+	check(p7, roots, res)
+	res.Raw = message.AssembleEntity(outer, message.Canonicalize(p7.Content))
+}
+
+// check verifies the signature of a parsed SignedData and the chain of its
+// signer certificate.
+func check(p7 *pkcs7.PKCS7, roots *x509.CertPool, res *Result) {
+	var signer = p7.GetOnlySigner()
+	if signer == nil {
+		res.Signature = StatusUnknownKey
+		res.Error = "no certificate for the signer"
+		return
+	}
+	res.Signer = Name(signer)
+
+	if err := p7.Verify(); err != nil {
+		res.Signature = StatusInvalid
+		res.Error = err.Error()
+		return
+	}
+	res.Signature = StatusValid
+	res.Certificate = signer
+
+	// The chain is checked at signing time when the signature carries it,
+	// so old mail stays trusted after the certificate expires.
+	var at = time.Now()
+	var signingTime time.Time
+	if err := p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeSigningTime, &signingTime); err == nil {
+		at = signingTime
+	}
+	res.Chain, res.Error = verifyChain(signer, p7.Certificates, roots, at)
+}
+
+func verifyChain(cert *x509.Certificate, certs []*x509.Certificate, roots *x509.CertPool, at time.Time) (Chain, string) {
+	var intermediates = x509.NewCertPool()
+	for _, c := range certs {
+		if !c.Equal(cert) {
+			intermediates.AddCert(c)
+		}
+	}
+	var _, err = cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	})
+	if err == nil {
+		return ChainTrusted, ""
+	}
+
+	var invalid x509.CertificateInvalidError
+	var unknown x509.UnknownAuthorityError
+	switch {
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return ChainExpired, err.Error()
+	case errors.As(err, &unknown):
+		return ChainUntrusted, err.Error()
+	}
+	return ChainInvalid, err.Error()
+}
+
+// checkSender downgrades a valid signature whose certificate is not issued
+// for the From address.
+func checkSender(raw []byte, res *Result) {
+	from, err := mail.ParseAddress(message.HeaderValue(raw, "From"))
+	if err != nil || res.Certificate == nil {
+		return
+	}
+	if !hasEmail(res.Certificate, from.Address) {
+		res.Signature = StatusMismatch
+	}
+}
+
+// entityBody returns the decoded body of the top-level entity of raw.
+func entityBody(raw []byte) ([]byte, error) {
+	var _, body = message.SplitMessage(raw)
+	if strings.EqualFold(message.HeaderValue(raw, "Content-Transfer-Encoding"), "base64") {
+		return decodeBase64(body)
+	}
+	return body, nil
+}
+
+func isPKCS7Mime(mediaType string) bool {
+	return mediaType == "application/pkcs7-mime" || mediaType == "application/x-pkcs7-mime"
+}
+
+func isPKCS7Signature(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	return mediaType == "application/pkcs7-signature" || mediaType == "application/x-pkcs7-signature"
+}
+
+func decodeBase64(b []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(b)), ""))
+}
+
+// encodeBase64 encodes b in CRLF-terminated lines of 76 characters.
+func encodeBase64(b []byte) []byte {
+	var s = base64.StdEncoding.EncodeToString(b)
+	var buf bytes.Buffer
+	for len(s) > 0 {
+		var n = min(len(s), 76)
+		buf.WriteString(s[:n] + "\r\n")
+		s = s[n:]
+	}
+	return buf.Bytes()
+}
+
+func newBoundary() string {
+	var b = make([]byte, 16)
+	rand.Read(b)
+	return "miau-smime-" + hex.EncodeToString(b)
+}
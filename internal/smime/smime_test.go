@@ -0,0 +1,259 @@
+package smime
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const testMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: bob@example.com\r\n" +
+	"Subject: Quarterly report\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Numbers attached.\r\n"
+
+var serial int64
+
+// newCert issues a certificate for email, signed by parent (self-signed
+// when parent is nil).
+func newCert(t *testing.T, name, email string, parent *Identity, notAfter time.Time) *Identity {
+	t.Helper()
+	var key, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	var tmpl = &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	if email == "" {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		tmpl.EmailAddresses = []string{email}
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}
+	}
+
+	var issuer, signer = tmpl, any(key)
+	if parent != nil {
+		issuer, signer = parent.Certificate, parent.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id = &Identity{Certificate: cert, Key: key}
+	if parent != nil {
+		id.Chain = []*x509.Certificate{parent.Certificate}
+	}
+	return id
+}
+
+func pool(certs ...*x509.Certificate) *x509.CertPool {
+	var p = x509.NewCertPool()
+	for _, c := range certs {
+		p.AddCert(c)
+	}
+	return p
+}
+
+func TestParseIdentity(t *testing.T) {
+	var ca = newCert(t, "Example CA", "", nil, time.Now().Add(time.Hour))
+	var alice = newCert(t, "Alice", "alice@example.com", ca, time.Now().Add(time.Hour))
+
+	var pfx, err = pkcs12.Modern.Encode(alice.Key, alice.Certificate, alice.Chain, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := ParseIdentity(pfx, "secret")
+	if err != nil {
+		t.Fatalf("ParseIdentity: %v", err)
+	}
+	if !id.Certificate.Equal(alice.Certificate) || len(id.Chain) != 1 {
+		t.Errorf("Unexpected identity: %s, %d chain certificates", Name(id.Certificate), len(id.Chain))
+	}
+	if _, err := ParseIdentity(pfx, "wrong"); err == nil {
+		t.Error("Expected an error for a wrong password")
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	var ca = newCert(t, "Example CA", "", nil, time.Now().Add(time.Hour))
+	var alice = newCert(t, "Alice", "alice@example.com", ca, time.Now().Add(time.Hour))
+
+	var signed, err = Sign([]byte(testMessage), alice)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !strings.Contains(string(signed), "multipart/signed") || !strings.Contains(string(signed), "Subject: Quarterly report") {
+		t.Fatalf("Unexpected signed message:\n%s", signed)
+	}
+
+	res, err := Open(signed, nil, pool(ca.Certificate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Signature != StatusValid || res.Chain != ChainTrusted || res.Encrypted {
+		t.Fatalf("Expected a valid, trusted signature, got %s", describe(res))
+	}
+	if res.Signer != "Alice <alice@example.com>" {
+		t.Errorf("Signer = %q", res.Signer)
+	}
+	if !strings.Contains(string(res.Raw), "Numbers attached.") || strings.Contains(string(res.Raw), "pkcs7") {
+		t.Errorf("Unexpected opened message:\n%s", res.Raw)
+	}
+
+	// Unknown CA
+	res, _ = Open(signed, nil, pool())
+	if res.Signature != StatusValid || res.Chain != ChainUntrusted {
+		t.Errorf("Expected a valid but untrusted signature, got %s", describe(res))
+	}
+
+	// Tampered content
+	var tampered = strings.Replace(string(signed), "Numbers attached.", "Numbers changed.", 1)
+	res, _ = Open([]byte(tampered), nil, pool(ca.Certificate))
+	if res.Signature != StatusInvalid {
+		t.Errorf("Expected an invalid signature, got %s", describe(res))
+	}
+
+	// Signed by someone else's certificate
+	var mallory = newCert(t, "Mallory", "mallory@example.com", ca, time.Now().Add(time.Hour))
+	signed, _ = Sign([]byte(testMessage), mallory)
+	res, _ = Open(signed, nil, pool(ca.Certificate))
+	if res.Signature != StatusMismatch {
+		t.Errorf("Expected a sender mismatch, got %s", describe(res))
+	}
+}
+
+func TestExpiredChain(t *testing.T) {
+	var ca = newCert(t, "Example CA", "", nil, time.Now().Add(time.Hour))
+	var alice = newCert(t, "Alice", "alice@example.com", ca, time.Now().Add(-time.Hour))
+
+	var signed, err = Sign([]byte(testMessage), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res, _ = Open(signed, nil, pool(ca.Certificate))
+	// Signing with an expired certificate is an invalid signature
+	if res.Signature != StatusInvalid {
+		t.Errorf("Expected an invalid signature, got %s", describe(res))
+	}
+
+	var chain, _ = verifyChain(alice.Certificate, nil, pool(ca.Certificate), time.Now())
+	if chain != ChainExpired {
+		t.Errorf("Chain = %q, want %q", chain, ChainExpired)
+	}
+}
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	var ca = newCert(t, "Example CA", "", nil, time.Now().Add(time.Hour))
+	var alice = newCert(t, "Alice", "alice@example.com", ca, time.Now().Add(time.Hour))
+	var bob = newCert(t, "Bob", "bob@example.com", ca, time.Now().Add(time.Hour))
+	var carol = newCert(t, "Carol", "carol@example.com", ca, time.Now().Add(time.Hour))
+
+	var signed, err = Sign([]byte(testMessage), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := Encrypt(signed, []*x509.Certificate{bob.Certificate, alice.Certificate})
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if strings.Contains(string(encrypted), "Numbers attached.") || !strings.Contains(string(encrypted), "enveloped-data") {
+		t.Fatalf("Unexpected encrypted message:\n%s", encrypted)
+	}
+
+	res, err := Open(encrypted, []*Identity{carol, bob}, pool(ca.Certificate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Encrypted || res.Signature != StatusValid || res.Chain != ChainTrusted {
+		t.Fatalf("Expected a decrypted message with a valid signature, got %s", describe(res))
+	}
+	if !strings.Contains(string(res.Raw), "Numbers attached.") || !strings.Contains(string(res.Raw), "Subject: Quarterly report") {
+		t.Errorf("Unexpected opened message:\n%s", res.Raw)
+	}
+
+	res, _ = Open(encrypted, []*Identity{carol}, pool(ca.Certificate))
+	if !res.Encrypted || res.Error == "" || res.Signature != StatusNone {
+		t.Errorf("Expected a decryption error, got %s", describe(res))
+	}
+}
+
+func TestOpenPlainMessage(t *testing.T) {
+	var res, err = Open([]byte(testMessage), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Encrypted || res.Signature != StatusNone || string(res.Raw) != testMessage {
+		t.Errorf("Expected the message unchanged, got %s", describe(res))
+	}
+}
+
+func TestStore(t *testing.T) {
+	var dir = t.TempDir()
+	var ca = newCert(t, "Example CA", "", nil, time.Now().Add(time.Hour))
+	var bob = newCert(t, "Bob", "bob@example.com", ca, time.Now().Add(time.Hour))
+	var expired = newCert(t, "Bob", "bob@example.com", ca, time.Now().Add(-time.Hour))
+
+	var store, err = OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: bob.Certificate.Raw})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: expired.Certificate.Raw})...)
+	added, err := store.Import(data)
+	if err != nil || len(added) != 2 {
+		t.Fatalf("Import: %v, %d added", err, len(added))
+	}
+	if added, _ := store.Add(bob.Certificate); len(added) != 0 {
+		t.Error("Expected a known certificate to be skipped")
+	}
+
+	reopened, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.Certificates()) != 2 {
+		t.Fatalf("Expected 2 certificates after reopening, got %d", len(reopened.Certificates()))
+	}
+
+	var to, missing = reopened.Recipients([]string{"Bob@Example.com", "dave@example.com"})
+	if len(to) != 1 || !to[0].Equal(bob.Certificate) {
+		t.Errorf("Expected the valid certificate of Bob, got %d certificates", len(to))
+	}
+	if len(missing) != 1 || missing[0] != "dave@example.com" {
+		t.Errorf("missing = %v", missing)
+	}
+
+	if err := reopened.Delete(Fingerprint(bob.Certificate)); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Delete(Fingerprint(bob.Certificate)); err != ErrCertNotFound {
+		t.Errorf("Expected ErrCertNotFound, got %v", err)
+	}
+}
+
+func describe(res *Result) string {
+	return fmt.Sprintf("encrypted=%v signature=%q chain=%q error=%q", res.Encrypted, res.Signature, res.Chain, res.Error)
+}
@@ -0,0 +1,204 @@
+package smime
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const certsFile = "certificates.pem"
+
+// Store keeps the certificates of correspondents, which are needed to
+// encrypt mail to them. They live in one PEM file, so they can be inspected
+// and edited with openssl.
+type Store struct {
+	mu    sync.RWMutex
+	dir   string
+	certs []*x509.Certificate
+}
+
+// OpenStore loads the certificates stored in dir. A missing file is treated
+// as an empty store; the directory is created on the first save.
+func OpenStore(dir string) (*Store, error) {
+	var s = &Store{dir: dir}
+	var data, err = os.ReadFile(filepath.Join(dir, certsFile))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	certs, err := ParseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("smime: %s: %w", certsFile, err)
+	}
+	for _, cert := range certs {
+		if s.index(cert) < 0 {
+			s.certs = append(s.certs, cert)
+		}
+	}
+	return s, nil
+}
+
+// Certificates lists every certificate, by subject.
+func (s *Store) Certificates() []Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list = make([]Certificate, 0, len(s.certs))
+	for _, cert := range s.certs {
+		list = append(list, Describe(cert))
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Subject < list[j].Subject
+	})
+	return list
+}
+
+// Add stores certificates that are not in the store yet and returns them.
+func (s *Store) Add(certs ...*x509.Certificate) ([]Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var added []Certificate
+	for _, cert := range certs {
+		if s.index(cert) >= 0 {
+			continue
+		}
+		s.certs = append(s.certs, cert)
+		added = append(added, Describe(cert))
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+	return added, s.save()
+}
+
+// Import adds the certificates of a PEM or DER file.
+func (s *Store) Import(data []byte) ([]Certificate, error) {
+	var certs, err = ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.Add(certs...)
+}
+
+// Delete removes a certificate by SHA-256 fingerprint.
+func (s *Store) Delete(fingerprint string) error {
+	fingerprint = normalizeHex(fingerprint)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, cert := range s.certs {
+		if Fingerprint(cert) == fingerprint {
+			s.certs = append(s.certs[:i], s.certs[i+1:]...)
+			return s.save()
+		}
+	}
+	return ErrCertNotFound
+}
+
+// Recipients finds a certificate to encrypt to for each address, the one
+// valid the longest when there are several. Addresses without a usable
+// certificate are returned in missing.
+func (s *Store) Recipients(addrs []string) (to []*x509.Certificate, missing []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var now = time.Now()
+	for _, addr := range addrs {
+		var best *x509.Certificate
+		for _, cert := range s.certs {
+			if hasEmail(cert, addr) && canEncrypt(cert, now) && (best == nil || cert.NotAfter.After(best.NotAfter)) {
+				best = cert
+			}
+		}
+		if best == nil {
+			missing = append(missing, addr)
+			continue
+		}
+		to = append(to, best)
+	}
+	return to, missing
+}
+
+func (s *Store) index(cert *x509.Certificate) int {
+	for i, c := range s.certs {
+		if c.Equal(cert) {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the file atomically. Callers must hold the write lock.
+func (s *Store) save() error {
+	var path = filepath.Join(s.dir, certsFile)
+	if len(s.certs) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, cert := range s.certs {
+		fmt.Fprintf(&buf, "# %s\n", Name(cert))
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return err
+		}
+	}
+	var tmp = path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ParseCertificates reads every certificate in PEM data, or a single DER
+// certificate.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		var cert, err = x509.ParseCertificate(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		var cert, err = x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, ErrCertNotFound
+	}
+	return certs, nil
+}
+
+func normalizeHex(s string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", " ", "").Replace(s))
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/opik/miau/internal/config"
+	"github.com/opik/miau/internal/email/message"
 )
 
 // Classificações de email disponíveis (Google Workspace labels)
@@ -20,11 +21,17 @@ type Email struct {
 	Bcc            []string
 	Subject        string
 	Body           string // Corpo do email (HTML ou plain text)
+	TextBody       string // Versão texto quando IsHTML (gerada do HTML se vazia)
 	ReplyTo        string
 	InReplyTo      string // Message-ID do email original (para threading)
 	References     string // Chain de Message-IDs
 	Classification string // Classificação do email (Public, Interno, etc)
 	IsHTML         bool   // Se true, envia como text/html; senão text/plain
+	Attachments    []message.Attachment
+	Calendar       []byte // iCalendar (iMIP) enviado como alternativa do corpo
+	CalendarMethod string // método iTIP do Calendar (REQUEST, REPLY...)
+	// Protect transforma a mensagem montada antes do envio (PGP/MIME)
+	Protect func(raw []byte) ([]byte, error)
 }
 
 // Client é o cliente SMTP
@@ -54,53 +61,53 @@ func (c *Client) Send(email *Email) (*SendResult, error) {
 	var domain = c.account.Email[strings.Index(c.account.Email, "@")+1:]
 	var messageID = fmt.Sprintf("<%d.%d@%s>", time.Now().UnixNano(), time.Now().Unix(), domain)
 
-	// Headers do email (ordem importa para alguns servidores)
-	var message strings.Builder
-	message.WriteString(fmt.Sprintf("From: %s\r\n", c.formatFrom()))
-	message.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(email.To, ", ")))
-	if len(email.Cc) > 0 {
-		message.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(email.Cc, ", ")))
-	}
-	message.WriteString(fmt.Sprintf("Subject: %s\r\n", email.Subject))
-	message.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
-	message.WriteString(fmt.Sprintf("Message-ID: %s\r\n", messageID))
-
-	// Headers de threading para replies
-	if email.InReplyTo != "" {
-		message.WriteString(fmt.Sprintf("In-Reply-To: %s\r\n", email.InReplyTo))
-	}
-	if email.References != "" {
-		message.WriteString(fmt.Sprintf("References: %s\r\n", email.References))
-	}
-
-	if email.ReplyTo != "" {
-		message.WriteString(fmt.Sprintf("Reply-To: %s\r\n", email.ReplyTo))
-	}
-
 	// Headers de classificação (vários formatos para compatibilidade)
 	var classification = email.Classification
 	if classification == "" {
 		classification = "Público"
 	}
-	// Google Workspace / genérico
-	message.WriteString(fmt.Sprintf("X-Classification: %s\r\n", classification))
-	message.WriteString(fmt.Sprintf("X-Google-Classification: %s\r\n", classification))
-	// Microsoft / Exchange
-	message.WriteString(fmt.Sprintf("Sensitivity: %s\r\n", classification))
-	message.WriteString(fmt.Sprintf("X-MS-Exchange-Organization-Classification: %s\r\n", classification))
-	// Outros sistemas DLP comuns
-	message.WriteString(fmt.Sprintf("X-Message-Classification: %s\r\n", classification))
-	message.WriteString(fmt.Sprintf("X-Data-Classification: %s\r\n", classification))
-	message.WriteString("X-Priority: 3\r\n")
-
-	message.WriteString("MIME-Version: 1.0\r\n")
+
+	var msg = &message.Message{
+		From:       c.formatFrom(),
+		To:         email.To,
+		Cc:         email.Cc,
+		ReplyTo:    email.ReplyTo,
+		Subject:    email.Subject,
+		MessageID:  messageID,
+		InReplyTo:  email.InReplyTo,
+		References: email.References,
+		Headers: []message.Header{
+			// Google Workspace / genérico
+			{Key: "X-Classification", Value: classification},
+			{Key: "X-Google-Classification", Value: classification},
+			// Microsoft / Exchange
+			{Key: "Sensitivity", Value: classification},
+			{Key: "X-MS-Exchange-Organization-Classification", Value: classification},
+			// Outros sistemas DLP comuns
+			{Key: "X-Message-Classification", Value: classification},
+			{Key: "X-Data-Classification", Value: classification},
+			{Key: "X-Priority", Value: "3"},
+		},
+		Attachments:    email.Attachments,
+		Calendar:       email.Calendar,
+		CalendarMethod: email.CalendarMethod,
+	}
 	if email.IsHTML {
-		message.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+		msg.HTMLBody = email.Body
+		msg.TextBody = email.TextBody
 	} else {
-		message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		msg.TextBody = email.Body
+	}
+
+	var raw, errBuild = msg.Build()
+	if errBuild != nil {
+		return nil, fmt.Errorf("erro ao montar mensagem: %w", errBuild)
+	}
+	if email.Protect != nil {
+		if raw, errBuild = email.Protect(raw); errBuild != nil {
+			return nil, fmt.Errorf("erro ao proteger mensagem: %w", errBuild)
+		}
 	}
-	message.WriteString("\r\n")
-	message.WriteString(email.Body)
 
 	// Destinatários (To + Cc + Bcc)
 	var recipients []string
@@ -151,7 +158,7 @@ func (c *Client) Send(email *Email) (*SendResult, error) {
 		return nil, fmt.Errorf("erro ao iniciar envio: %w", err3)
 	}
 
-	if _, err := w.Write([]byte(message.String())); err != nil {
+	if _, err := w.Write(raw); err != nil {
 		return nil, fmt.Errorf("erro ao enviar mensagem: %w", err)
 	}
 
@@ -13,12 +13,16 @@ func CreateCalendarEvent(event *CalendarEvent) error {
 		INSERT INTO calendar_events (
 			account_id, title, description, event_type, start_time, end_time,
 			all_day, color, task_id, email_id, is_completed, source,
-			google_event_id, google_calendar_id, sync_status
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			google_event_id, google_calendar_id, sync_status,
+			location, ical_uid, ical_recurrence_id, ical_sequence, ical_data, rsvp_status,
+			caldav_href, caldav_etag, last_synced_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		event.AccountID, event.Title, event.Description, event.EventType,
 		event.StartTime, event.EndTime, event.AllDay, event.Color,
 		event.TaskID, event.EmailID, event.IsCompleted, event.Source,
-		event.GoogleEventID, event.GoogleCalendarID, event.SyncStatus)
+		event.GoogleEventID, event.GoogleCalendarID, event.SyncStatus,
+		event.Location, event.ICalUID, event.ICalRecurrenceID, event.ICalSequence, event.ICalData, event.RSVPStatus,
+		event.CalDAVHref, event.CalDAVETag, event.LastSyncedAt)
 	if err != nil {
 		return err
 	}
@@ -246,3 +250,76 @@ func GetCalendarEventByGoogleID(googleEventID string) (*CalendarEvent, error) {
 	}
 	return &event, nil
 }
+
+// === EMAIL INVITES ===
+
+// GetCalendarEventByICalUID returns the event with an iCalendar UID and
+// RECURRENCE-ID ("" for the series), or nil
+func GetCalendarEventByICalUID(accountID int64, uid, recurrenceID string) (*CalendarEvent, error) {
+	var event CalendarEvent
+	err := db.Get(&event, `
+		SELECT * FROM calendar_events
+		WHERE account_id = ? AND ical_uid = ? AND ical_recurrence_id = ?
+		ORDER BY id LIMIT 1`,
+		accountID, uid, recurrenceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// GetInviteEventByEmail returns the event imported from an email invite,
+// the series before its exceptions, or nil
+func GetInviteEventByEmail(emailID int64) (*CalendarEvent, error) {
+	var event CalendarEvent
+	err := db.Get(&event, `
+		SELECT * FROM calendar_events
+		WHERE email_id = ? AND ical_data IS NOT NULL
+		ORDER BY ical_recurrence_id != '', id
+		LIMIT 1`,
+		emailID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// UpdateInviteEvent updates an event imported from an email invite,
+// including its iCalendar fields
+func UpdateInviteEvent(event *CalendarEvent) error {
+	_, err := db.Exec(`
+		UPDATE calendar_events SET
+			title = ?,
+			description = ?,
+			start_time = ?,
+			end_time = ?,
+			all_day = ?,
+			email_id = ?,
+			is_completed = ?,
+			location = ?,
+			ical_sequence = ?,
+			ical_data = ?,
+			rsvp_status = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		event.Title, event.Description, event.StartTime, event.EndTime,
+		event.AllDay, event.EmailID, event.IsCompleted, event.Location,
+		event.ICalSequence, event.ICalData, event.RSVPStatus,
+		event.ID)
+	return err
+}
+
+// SetCalendarEventRSVP records the user's answer to an email invite
+func SetCalendarEventRSVP(id int64, status string) error {
+	_, err := db.Exec(`
+		UPDATE calendar_events SET rsvp_status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		status, id)
+	return err
+}
@@ -0,0 +1,190 @@
+package storage
+
+import "database/sql"
+
+// === REMOTE CALENDAR SYNC ===
+
+// Remote calendars kept in sync with calendar_events
+const (
+	CalendarSyncCalDAV = "caldav"
+	CalendarSyncGoogle = "google"
+)
+
+// CalendarSyncState is how far a remote calendar was synced
+type CalendarSyncState struct {
+	AccountID     int64        `db:"account_id"`
+	Source        string       `db:"source"`
+	CollectionURL string       `db:"collection_url"` // CalDAV collection found by discovery, or Google calendar ID
+	CTag          string       `db:"ctag"`           // changes with every change to the collection
+	SyncToken     string       `db:"sync_token"`     // Google: events changed since
+	LastSyncAt    sql.NullTime `db:"last_sync_at"`
+}
+
+// CalendarDeletion is an event deleted locally that still has to be
+// deleted on the server
+type CalendarDeletion struct {
+	ID         int64      `db:"id"`
+	AccountID  int64      `db:"account_id"`
+	Source     string     `db:"source"`
+	Collection string     `db:"collection"` // Google calendar ID
+	RemoteID   string     `db:"remote_id"`  // CalDAV href or Google event ID
+	ETag       string     `db:"etag"`
+	DeletedAt  SQLiteTime `db:"deleted_at"`
+}
+
+// GetCalendarSyncState returns the sync state of a remote calendar, or nil
+// if it was never synced
+func GetCalendarSyncState(accountID int64, source string) (*CalendarSyncState, error) {
+	var state CalendarSyncState
+	err := db.Get(&state, `
+		SELECT * FROM calendar_sync_state
+		WHERE account_id = ? AND source = ?`,
+		accountID, source)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveCalendarSyncState saves the sync state of a remote calendar
+func SaveCalendarSyncState(state *CalendarSyncState) error {
+	_, err := db.Exec(`
+		INSERT INTO calendar_sync_state (account_id, source, collection_url, ctag, sync_token, last_sync_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, source) DO UPDATE SET
+			collection_url = excluded.collection_url,
+			ctag = excluded.ctag,
+			sync_token = excluded.sync_token,
+			last_sync_at = excluded.last_sync_at`,
+		state.AccountID, state.Source, state.CollectionURL, state.CTag, state.SyncToken, state.LastSyncAt)
+	return err
+}
+
+// GetCalDAVEvents returns the events of an account stored on its CalDAV
+// server
+func GetCalDAVEvents(accountID int64) ([]CalendarEvent, error) {
+	var events []CalendarEvent
+	err := db.Select(&events, `
+		SELECT * FROM calendar_events
+		WHERE account_id = ? AND caldav_href IS NOT NULL
+		ORDER BY id ASC`,
+		accountID)
+	return events, err
+}
+
+// GetCalDAVEventsToPush returns the events changed locally that have to be
+// written to the CalDAV server: those already there and the new ones not
+// synced to Google Calendar
+func GetCalDAVEventsToPush(accountID int64) ([]CalendarEvent, error) {
+	var events []CalendarEvent
+	err := db.Select(&events, `
+		SELECT * FROM calendar_events
+		WHERE account_id = ? AND sync_status = 'pending_sync'
+		  AND (caldav_href IS NOT NULL OR COALESCE(google_event_id, '') = '')
+		ORDER BY updated_at ASC, id ASC`,
+		accountID)
+	return events, err
+}
+
+// GetGoogleEventsToPush returns the events changed locally that may have to
+// be written to Google Calendar (those not on a CalDAV server)
+func GetGoogleEventsToPush(accountID int64) ([]CalendarEvent, error) {
+	var events []CalendarEvent
+	err := db.Select(&events, `
+		SELECT * FROM calendar_events
+		WHERE account_id = ? AND sync_status = 'pending_sync' AND caldav_href IS NULL
+		ORDER BY updated_at ASC, id ASC`,
+		accountID)
+	return events, err
+}
+
+// GetGoogleSyncedEvents returns the events of an account that came from a
+// Google calendar ("primary" for those without one)
+func GetGoogleSyncedEvents(accountID int64, calendarID string) ([]CalendarEvent, error) {
+	var events []CalendarEvent
+	err := db.Select(&events, `
+		SELECT * FROM calendar_events
+		WHERE account_id = ? AND COALESCE(google_event_id, '') != ''
+		  AND COALESCE(NULLIF(google_calendar_id, ''), 'primary') = ?
+		ORDER BY id ASC`,
+		accountID, calendarID)
+	return events, err
+}
+
+// UpdateSyncedCalendarEvent updates an event with everything a sync
+// writes: its fields, the remote object and the sync status
+func UpdateSyncedCalendarEvent(event *CalendarEvent) error {
+	_, err := db.Exec(`
+		UPDATE calendar_events SET
+			title = ?,
+			description = ?,
+			event_type = ?,
+			start_time = ?,
+			end_time = ?,
+			all_day = ?,
+			is_completed = ?,
+			location = ?,
+			ical_uid = ?,
+			ical_recurrence_id = ?,
+			ical_sequence = ?,
+			ical_data = ?,
+			caldav_href = ?,
+			caldav_etag = ?,
+			google_event_id = ?,
+			google_calendar_id = ?,
+			conflict_etag = ?,
+			conflict_data = ?,
+			sync_status = ?,
+			last_synced_at = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		event.Title, event.Description, event.EventType,
+		event.StartTime, event.EndTime, event.AllDay, event.IsCompleted, event.Location,
+		event.ICalUID, event.ICalRecurrenceID, event.ICalSequence, event.ICalData,
+		event.CalDAVHref, event.CalDAVETag, event.GoogleEventID, event.GoogleCalendarID,
+		event.ConflictETag, event.ConflictData,
+		event.SyncStatus, event.LastSyncedAt,
+		event.ID)
+	return err
+}
+
+// GetCalendarConflicts returns the events in conflict with the server
+func GetCalendarConflicts(accountID int64) ([]CalendarEvent, error) {
+	var events []CalendarEvent
+	err := db.Select(&events, `
+		SELECT * FROM calendar_events
+		WHERE account_id = ? AND sync_status = 'conflict'
+		ORDER BY start_time ASC`,
+		accountID)
+	return events, err
+}
+
+// AddCalendarDeletion records an event deleted locally, to be deleted on
+// the server by the next sync
+func AddCalendarDeletion(d *CalendarDeletion) error {
+	_, err := db.Exec(`
+		INSERT INTO calendar_deleted_events (account_id, source, collection, remote_id, etag)
+		VALUES (?, ?, ?, ?, ?)`,
+		d.AccountID, d.Source, d.Collection, d.RemoteID, d.ETag)
+	return err
+}
+
+// GetCalendarDeletions returns the deletions still to be sent to a server
+func GetCalendarDeletions(accountID int64, source string) ([]CalendarDeletion, error) {
+	var deletions []CalendarDeletion
+	err := db.Select(&deletions, `
+		SELECT * FROM calendar_deleted_events
+		WHERE account_id = ? AND source = ?
+		ORDER BY id ASC`,
+		accountID, source)
+	return deletions, err
+}
+
+// RemoveCalendarDeletion forgets a deletion already sent to the server
+func RemoveCalendarDeletion(id int64) error {
+	_, err := db.Exec("DELETE FROM calendar_deleted_events WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCalendarSync tests the state kept by the CalDAV sync
+func TestCalendarSync(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, _ = GetOrCreateAccount("me@example.org", "Me")
+
+	if state, err := GetCalendarSyncState(account.ID, CalendarSyncCalDAV); state != nil || err != nil {
+		t.Fatalf("Expected no state, got %+v, %v", state, err)
+	}
+	var state = &CalendarSyncState{
+		AccountID:     account.ID,
+		Source:        CalendarSyncCalDAV,
+		CollectionURL: "https://dav.example.org/calendars/me/personal/",
+		CTag:          "ctag-1",
+		LastSyncAt:    sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	if err := SaveCalendarSyncState(state); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+	state.CTag = "ctag-2"
+	if err := SaveCalendarSyncState(state); err != nil {
+		t.Fatalf("Failed to update state: %v", err)
+	}
+	if saved, _ := GetCalendarSyncState(account.ID, CalendarSyncCalDAV); saved == nil || saved.CTag != "ctag-2" || saved.CollectionURL != state.CollectionURL {
+		t.Errorf("Unexpected state: %+v", saved)
+	}
+
+	var newEvent = func(title string, status CalendarSyncStatus, href string) *CalendarEvent {
+		var event = &CalendarEvent{
+			AccountID:  account.ID,
+			Title:      title,
+			EventType:  CalendarEventTypeCustom,
+			StartTime:  SQLiteTime{time.Date(2026, 10, 27, 9, 0, 0, 0, time.UTC)},
+			Source:     CalendarEventSourceManual,
+			SyncStatus: status,
+			CalDAVHref: sql.NullString{String: href, Valid: href != ""},
+			CalDAVETag: sql.NullString{String: `"1"`, Valid: href != ""},
+		}
+		if err := CreateCalendarEvent(event); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+		return event
+	}
+	var synced = newEvent("Synced", CalendarSyncStatusSynced, "/cal/a.ics")
+	var edited = newEvent("Edited", CalendarSyncStatusPendingSync, "/cal/b.ics")
+	var created = newEvent("Created", CalendarSyncStatusPendingSync, "")
+	newEvent("Local", CalendarSyncStatusLocal, "")
+
+	if events, _ := GetCalDAVEvents(account.ID); len(events) != 2 {
+		t.Errorf("Expected 2 events on the server, got %d", len(events))
+	}
+	var push, _ = GetCalDAVEventsToPush(account.ID)
+	if len(push) != 2 || push[0].ID != edited.ID || push[1].ID != created.ID {
+		t.Errorf("Expected the edited and created events to push, got %+v", push)
+	}
+
+	// Pushed: the new event gets its object on the server
+	created.CalDAVHref = sql.NullString{String: "/cal/c.ics", Valid: true}
+	created.CalDAVETag = sql.NullString{String: `"2"`, Valid: true}
+	created.ICalUID = sql.NullString{String: "c@miau", Valid: true}
+	created.SyncStatus = CalendarSyncStatusSynced
+	if err := UpdateSyncedCalendarEvent(created); err != nil {
+		t.Fatalf("Failed to update event: %v", err)
+	}
+	if saved, _ := GetCalendarEvent(created.ID); saved.CalDAVHref.String != "/cal/c.ics" || saved.ICalUID.String != "c@miau" || saved.SyncStatus != CalendarSyncStatusSynced {
+		t.Errorf("Unexpected event after sync: %+v", saved)
+	}
+
+	// Changed on both sides
+	edited.SyncStatus = CalendarSyncStatusConflict
+	edited.ConflictETag = sql.NullString{String: `"3"`, Valid: true}
+	if err := UpdateSyncedCalendarEvent(edited); err != nil {
+		t.Fatalf("Failed to set conflict: %v", err)
+	}
+	var conflicts, _ = GetCalendarConflicts(account.ID)
+	if len(conflicts) != 1 || conflicts[0].ID != edited.ID || conflicts[0].ConflictETag.String != `"3"` || conflicts[0].ConflictData.Valid {
+		t.Errorf("Unexpected conflicts: %+v", conflicts)
+	}
+	if push, _ := GetCalDAVEventsToPush(account.ID); len(push) != 0 {
+		t.Errorf("Expected nothing to push, got %+v", push)
+	}
+
+	// Deleted locally
+	var deletion = &CalendarDeletion{AccountID: account.ID, Source: CalendarSyncCalDAV, RemoteID: synced.CalDAVHref.String, ETag: synced.CalDAVETag.String}
+	if err := AddCalendarDeletion(deletion); err != nil {
+		t.Fatalf("Failed to add deletion: %v", err)
+	}
+	var deletions, _ = GetCalendarDeletions(account.ID, CalendarSyncCalDAV)
+	if len(deletions) != 1 || deletions[0].RemoteID != "/cal/a.ics" || deletions[0].ETag != `"1"` {
+		t.Fatalf("Unexpected deletions: %+v", deletions)
+	}
+	RemoveCalendarDeletion(deletions[0].ID)
+	if deletions, _ := GetCalendarDeletions(account.ID, CalendarSyncCalDAV); len(deletions) != 0 {
+		t.Errorf("Expected no deletions, got %+v", deletions)
+	}
+}
+
+// TestGoogleCalendarSync tests the queries of the Google Calendar sync
+func TestGoogleCalendarSync(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, _ = GetOrCreateAccount("me@example.org", "Me")
+	var newEvent = func(title string, status CalendarSyncStatus, googleID, calendarID string) *CalendarEvent {
+		var event = &CalendarEvent{
+			AccountID:        account.ID,
+			Title:            title,
+			EventType:        CalendarEventTypeTaskDeadline,
+			StartTime:        SQLiteTime{time.Date(2026, 10, 27, 0, 0, 0, 0, time.UTC)},
+			AllDay:           true,
+			Source:           CalendarEventSourceTaskSync,
+			SyncStatus:       status,
+			GoogleEventID:    sql.NullString{String: googleID, Valid: googleID != ""},
+			GoogleCalendarID: sql.NullString{String: calendarID, Valid: calendarID != ""},
+		}
+		if err := CreateCalendarEvent(event); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+		return event
+	}
+	var created = newEvent("Created", CalendarSyncStatusPendingSync, "", "")
+	newEvent("Primary", CalendarSyncStatusSynced, "g1", "primary")
+	newEvent("Imported", CalendarSyncStatusSynced, "g2", "")
+	newEvent("Holidays", CalendarSyncStatusSynced, "g3", "holidays@group.calendar.google.com")
+
+	if push, _ := GetGoogleEventsToPush(account.ID); len(push) != 1 || push[0].ID != created.ID {
+		t.Errorf("Expected the created event to push, got %+v", push)
+	}
+	if events, _ := GetGoogleSyncedEvents(account.ID, "primary"); len(events) != 2 {
+		t.Errorf("Expected 2 events in the primary calendar, got %d", len(events))
+	}
+
+	// Pushed: the event gets its Google ID
+	created.GoogleEventID = sql.NullString{String: "g4", Valid: true}
+	created.GoogleCalendarID = sql.NullString{String: "primary", Valid: true}
+	created.SyncStatus = CalendarSyncStatusSynced
+	if err := UpdateSyncedCalendarEvent(created); err != nil {
+		t.Fatalf("Failed to update event: %v", err)
+	}
+	if saved, _ := GetCalendarEventByGoogleID("g4"); saved == nil || saved.ID != created.ID || saved.SyncStatus != CalendarSyncStatusSynced {
+		t.Errorf("Unexpected event after push: %+v", saved)
+	}
+
+	var deletion = &CalendarDeletion{AccountID: account.ID, Source: CalendarSyncGoogle, Collection: "primary", RemoteID: "g1"}
+	if err := AddCalendarDeletion(deletion); err != nil {
+		t.Fatalf("Failed to add deletion: %v", err)
+	}
+	if deletions, _ := GetCalendarDeletions(account.ID, CalendarSyncGoogle); len(deletions) != 1 || deletions[0].Collection != "primary" || deletions[0].RemoteID != "g1" {
+		t.Errorf("Unexpected deletions: %+v", deletions)
+	}
+	if deletions, _ := GetCalendarDeletions(account.ID, CalendarSyncCalDAV); len(deletions) != 0 {
+		t.Errorf("Expected no CalDAV deletions, got %+v", deletions)
+	}
+}
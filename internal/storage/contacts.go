@@ -17,7 +17,9 @@ func NewContactStorageAdapter() *ContactStorageAdapter {
 	return &ContactStorageAdapter{}
 }
 
-// SaveContact saves or updates a contact
+// SaveContact saves or updates a contact. PendingSync marks it as changed
+// locally (it is never cleared here); VCard is only stored for a new
+// contact.
 func (a *ContactStorageAdapter) SaveContact(ctx context.Context, contact *ports.ContactInfo) (int64, error) {
 	var now = time.Now()
 
@@ -26,14 +28,16 @@ func (a *ContactStorageAdapter) SaveContact(ctx context.Context, contact *ports.
 		var query = `
 			INSERT INTO contacts (
 				account_id, resource_name, display_name, given_name, family_name,
-				photo_url, photo_path, is_starred, synced_at, created_at, updated_at
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				photo_url, photo_path, is_starred, time_zone, vcard_data, pending_sync,
+				synced_at, created_at, updated_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`
 		var result, err = db.ExecContext(ctx, query,
 			contact.AccountID, contact.ResourceName, contact.DisplayName,
 			nullString(contact.GivenName), nullString(contact.FamilyName),
 			nullString(contact.PhotoURL), nullString(contact.PhotoPath),
-			contact.IsStarred, nullTime(contact.SyncedAt), now, now,
+			contact.IsStarred, nullString(contact.TimeZone), nullString(contact.VCard), contact.PendingSync,
+			nullTime(contact.SyncedAt), now, now,
 		)
 		if err != nil {
 			return 0, fmt.Errorf("failed to insert contact: %w", err)
@@ -47,18 +51,20 @@ func (a *ContactStorageAdapter) SaveContact(ctx context.Context, contact *ports.
 		return id, nil
 	}
 
-	// Update existing contact
+	// Update existing contact (the time zone is kept unless one is given:
+	// synced contacts don't carry it)
 	var query = `
 		UPDATE contacts SET
 			display_name = ?, given_name = ?, family_name = ?,
 			photo_url = ?, photo_path = ?,
-			is_starred = ?, synced_at = ?, updated_at = ?
+			is_starred = ?, time_zone = COALESCE(?, time_zone), pending_sync = (pending_sync OR ?),
+			synced_at = ?, updated_at = ?
 		WHERE id = ?
 	`
 	var _, err = db.ExecContext(ctx, query,
 		contact.DisplayName, nullString(contact.GivenName), nullString(contact.FamilyName),
 		nullString(contact.PhotoURL), nullString(contact.PhotoPath),
-		contact.IsStarred, nullTime(contact.SyncedAt), now,
+		contact.IsStarred, nullString(contact.TimeZone), contact.PendingSync, nullTime(contact.SyncedAt), now,
 		contact.ID,
 	)
 	if err != nil {
@@ -103,7 +109,7 @@ func (a *ContactStorageAdapter) GetContactByEmail(ctx context.Context, accountID
 	var query = `
 		SELECT c.* FROM contacts c
 		INNER JOIN contact_emails ce ON c.id = ce.contact_id
-		WHERE c.account_id = ? AND ce.email = ?
+		WHERE c.account_id = ? AND ce.email = ? COLLATE NOCASE
 		LIMIT 1
 	`
 
@@ -294,12 +300,12 @@ func (a *ContactStorageAdapter) RecordInteraction(ctx context.Context, contactID
 	return nil
 }
 
-// GetSyncStatus returns the sync status for an account
-func (a *ContactStorageAdapter) GetSyncStatus(ctx context.Context, accountID int64) (*ports.ContactSyncStatus, error) {
-	var query = `SELECT * FROM contacts_sync_state WHERE account_id = ?`
+// GetSyncStatus returns the sync status of a source of an account
+func (a *ContactStorageAdapter) GetSyncStatus(ctx context.Context, accountID int64, source string) (*ports.ContactSyncStatus, error) {
+	var query = `SELECT * FROM contacts_sync_state WHERE account_id = ? AND source = ?`
 
 	var state ContactsSyncState
-	if err := db.GetContext(ctx, &state, query, accountID); err != nil {
+	if err := db.GetContext(ctx, &state, query, accountID, syncSource(source)); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // not found, return nil without error
 		}
@@ -308,6 +314,7 @@ func (a *ContactStorageAdapter) GetSyncStatus(ctx context.Context, accountID int
 
 	return &ports.ContactSyncStatus{
 		AccountID:           state.AccountID,
+		Source:              state.Source,
 		LastSyncToken:       state.LastSyncToken.String,
 		LastFullSync:        nullTimeToPtr(state.LastFullSync),
 		LastIncrementalSync: nullTimeToPtr(state.LastIncrementalSync),
@@ -317,7 +324,7 @@ func (a *ContactStorageAdapter) GetSyncStatus(ctx context.Context, accountID int
 	}, nil
 }
 
-// UpdateSyncStatus updates the sync status
+// UpdateSyncStatus updates the sync status of a source
 func (a *ContactStorageAdapter) UpdateSyncStatus(ctx context.Context, status *ports.ContactSyncStatus) error {
 	var now = time.Now()
 
@@ -331,7 +338,7 @@ func (a *ContactStorageAdapter) UpdateSyncStatus(ctx context.Context, status *po
 			status = ?,
 			error_message = ?,
 			updated_at = ?
-		WHERE account_id = ?
+		WHERE account_id = ? AND source = ?
 	`
 	var result, err = db.ExecContext(ctx, updateQuery,
 		nullString(status.LastSyncToken),
@@ -342,6 +349,7 @@ func (a *ContactStorageAdapter) UpdateSyncStatus(ctx context.Context, status *po
 		nullString(status.ErrorMessage),
 		now,
 		status.AccountID,
+		syncSource(status.Source),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update sync status: %w", err)
@@ -355,12 +363,13 @@ func (a *ContactStorageAdapter) UpdateSyncStatus(ctx context.Context, status *po
 	// Insert if not exists
 	var insertQuery = `
 		INSERT INTO contacts_sync_state (
-			account_id, last_sync_token, last_full_sync, last_incremental_sync,
+			account_id, source, last_sync_token, last_full_sync, last_incremental_sync,
 			total_contacts, status, error_message, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	var _, err2 = db.ExecContext(ctx, insertQuery,
 		status.AccountID,
+		syncSource(status.Source),
 		nullString(status.LastSyncToken),
 		nullTime(status.LastFullSync),
 		nullTime(status.LastIncrementalSync),
@@ -419,6 +428,91 @@ func (a *ContactStorageAdapter) DeleteContactsByAccount(ctx context.Context, acc
 	return nil
 }
 
+// SetContactTimeZone sets the IANA time zone of a contact ("" clears it),
+// a local change to sync
+func (a *ContactStorageAdapter) SetContactTimeZone(ctx context.Context, contactID int64, timeZone string) error {
+	var _, err = db.ExecContext(ctx, `
+		UPDATE contacts SET time_zone = ?, pending_sync = 1, updated_at = ? WHERE id = ?`,
+		nullString(timeZone), time.Now(), contactID)
+	if err != nil {
+		return fmt.Errorf("failed to set contact time zone: %w", err)
+	}
+	return nil
+}
+
+// SaveContactVCard records the CardDAV version of a contact (its href
+// becomes the resource name) and clears its local changes
+func (a *ContactStorageAdapter) SaveContactVCard(ctx context.Context, contactID int64, href, etag, vcard string) error {
+	var _, err = db.ExecContext(ctx, `
+		UPDATE contacts SET resource_name = ?, carddav_etag = ?, vcard_data = ?, pending_sync = 0, synced_at = ?
+		WHERE id = ?`,
+		href, etag, vcard, time.Now(), contactID)
+	if err != nil {
+		return fmt.Errorf("failed to save vcard: %w", err)
+	}
+	return nil
+}
+
+// GetCardDAVContacts returns the contacts that came from or went to the
+// CardDAV address book
+func (a *ContactStorageAdapter) GetCardDAVContacts(ctx context.Context, accountID int64) ([]ports.ContactInfo, error) {
+	var contacts []Contact
+	if err := db.SelectContext(ctx, &contacts, `
+		SELECT * FROM contacts WHERE account_id = ? AND carddav_etag IS NOT NULL`, accountID); err != nil {
+		return nil, fmt.Errorf("failed to get carddav contacts: %w", err)
+	}
+
+	var result []ports.ContactInfo
+	for i := range contacts {
+		result = append(result, *contactToPort(&contacts[i]))
+	}
+	return result, nil
+}
+
+// GetContactsPendingSync returns the contacts changed locally since their
+// last sync, with their emails and phones
+func (a *ContactStorageAdapter) GetContactsPendingSync(ctx context.Context, accountID int64) ([]ports.ContactInfo, error) {
+	var contacts []Contact
+	if err := db.SelectContext(ctx, &contacts, `
+		SELECT * FROM contacts WHERE account_id = ? AND pending_sync = 1 ORDER BY id`, accountID); err != nil {
+		return nil, fmt.Errorf("failed to get pending contacts: %w", err)
+	}
+
+	var result []ports.ContactInfo
+	for i := range contacts {
+		var contact = contactToPort(&contacts[i])
+		contact.Emails, _ = a.GetContactEmails(ctx, contact.ID)
+		contact.Phones, _ = a.GetContactPhones(ctx, contact.ID)
+		result = append(result, *contact)
+	}
+	return result, nil
+}
+
+// DeleteContact deletes a contact (cascade handles emails, phones,
+// interactions)
+func (a *ContactStorageAdapter) DeleteContact(ctx context.Context, id int64) error {
+	var _, err = db.ExecContext(ctx, "DELETE FROM contacts WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete contact: %w", err)
+	}
+	return nil
+}
+
+// GetContactTimeZone returns the time zone stored on the contact with the
+// given email address ("" when the contact or its time zone is unknown)
+func GetContactTimeZone(accountID int64, email string) (string, error) {
+	var timeZone sql.NullString
+	var err = db.Get(&timeZone, `
+		SELECT c.time_zone FROM contacts c
+		INNER JOIN contact_emails ce ON c.id = ce.contact_id
+		WHERE c.account_id = ? AND ce.email = ? COLLATE NOCASE AND c.time_zone IS NOT NULL
+		LIMIT 1`, accountID, email)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return timeZone.String, err
+}
+
 // Helper functions
 
 func contactToPort(c *Contact) *ports.ContactInfo {
@@ -432,12 +526,24 @@ func contactToPort(c *Contact) *ports.ContactInfo {
 		PhotoURL:          c.PhotoURL.String,
 		PhotoPath:         c.PhotoPath.String,
 		IsStarred:         c.IsStarred,
+		TimeZone:          c.TimeZone.String,
 		InteractionCount:  c.InteractionCount,
 		LastInteractionAt: nullTimeToPtr(c.LastInteractionAt),
 		SyncedAt:          nullTimeToPtr(c.SyncedAt),
 		CreatedAt:         c.CreatedAt.Time,
 		UpdatedAt:         c.UpdatedAt.Time,
+		ETag:              c.CardDAVETag.String,
+		VCard:             c.VCardData.String,
+		PendingSync:       c.PendingSync,
+	}
+}
+
+// syncSource returns the source of a sync state (Google when unset)
+func syncSource(source string) string {
+	if source == "" {
+		return ports.ContactSourceGoogle
 	}
+	return source
 }
 
 func nullString(s string) sql.NullString {
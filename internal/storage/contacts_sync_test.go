@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/opik/miau/internal/ports"
+)
+
+// TestContactSyncSources tests the sync state kept per source and the
+// CardDAV state of the contacts
+func TestContactSyncSources(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var ctx = context.Background()
+	var adapter = NewContactStorageAdapter()
+	var account, _ = GetOrCreateAccount("me@example.org", "Me")
+
+	for _, status := range []*ports.ContactSyncStatus{
+		{AccountID: account.ID, LastSyncToken: "google-token", Status: "synced"},
+		{AccountID: account.ID, Source: ports.ContactSourceCardDAV, LastSyncToken: "http://dav/sync/1", Status: "synced"},
+	} {
+		if err := adapter.UpdateSyncStatus(ctx, status); err != nil {
+			t.Fatalf("Failed to save sync status: %v", err)
+		}
+	}
+	if status, _ := adapter.GetSyncStatus(ctx, account.ID, ports.ContactSourceGoogle); status == nil || status.LastSyncToken != "google-token" || status.Source != ports.ContactSourceGoogle {
+		t.Errorf("Unexpected Google status: %+v", status)
+	}
+	if status, _ := adapter.GetSyncStatus(ctx, account.ID, ports.ContactSourceCardDAV); status == nil || status.LastSyncToken != "http://dav/sync/1" {
+		t.Errorf("Unexpected CardDAV status: %+v", status)
+	}
+
+	// A contact pulled from the address book
+	var id, err = adapter.SaveContact(ctx, &ports.ContactInfo{AccountID: account.ID, ResourceName: "/ab/jane.vcf", DisplayName: "Jane"})
+	if err != nil {
+		t.Fatalf("Failed to save contact: %v", err)
+	}
+	if err := adapter.SaveContactVCard(ctx, id, "/ab/jane.vcf", `"1"`, "BEGIN:VCARD\r\nEND:VCARD\r\n"); err != nil {
+		t.Fatalf("Failed to save vcard: %v", err)
+	}
+	adapter.SaveContact(ctx, &ports.ContactInfo{AccountID: account.ID, ResourceName: "people/c1", DisplayName: "Google"})
+
+	var contacts, _ = adapter.GetCardDAVContacts(ctx, account.ID)
+	if len(contacts) != 1 || contacts[0].ID != id || contacts[0].ETag != `"1"` || contacts[0].VCard == "" || contacts[0].PendingSync {
+		t.Errorf("Unexpected CardDAV contacts: %+v", contacts)
+	}
+
+	// Changed locally
+	adapter.SaveContactEmails(ctx, id, []ports.ContactEmailInfo{{Email: "jane@example.com", IsPrimary: true}})
+	if err := adapter.SetContactTimeZone(ctx, id, "Europe/Lisbon"); err != nil {
+		t.Fatalf("Failed to set time zone: %v", err)
+	}
+	var pending, _ = adapter.GetContactsPendingSync(ctx, account.ID)
+	if len(pending) != 1 || pending[0].ID != id || len(pending[0].Emails) != 1 || pending[0].TimeZone != "Europe/Lisbon" {
+		t.Errorf("Unexpected pending contacts: %+v", pending)
+	}
+
+	if err := adapter.DeleteContact(ctx, id); err != nil {
+		t.Fatalf("Failed to delete contact: %v", err)
+	}
+	if contact, _ := adapter.GetContactByResourceName(ctx, account.ID, "/ab/jane.vcf"); contact != nil {
+		t.Errorf("Expected the contact to be gone, got %+v", contact)
+	}
+}
+
+// TestMigrateContactSources tests that the sync state of a database from
+// before CardDAV becomes the Google one
+func TestMigrateContactSources(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+	var account, _ = GetOrCreateAccount("me@example.org", "Me")
+
+	db.MustExec("DROP TABLE contacts_sync_state")
+	db.MustExec(`CREATE TABLE contacts_sync_state (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account_id INTEGER NOT NULL UNIQUE,
+		last_sync_token TEXT,
+		last_full_sync DATETIME,
+		last_incremental_sync DATETIME,
+		total_contacts INTEGER DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'never_synced',
+		error_message TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	db.MustExec("INSERT INTO contacts_sync_state (account_id, last_sync_token, total_contacts, status) VALUES (?, 'old-token', 42, 'synced')", account.ID)
+
+	if err := migrateContactSources(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := migrateContactSources(); err != nil {
+		t.Fatalf("Failed to migrate again: %v", err)
+	}
+
+	var adapter = NewContactStorageAdapter()
+	var status, _ = adapter.GetSyncStatus(context.Background(), account.ID, ports.ContactSourceGoogle)
+	if status == nil || status.LastSyncToken != "old-token" || status.TotalContacts != 42 {
+		t.Errorf("Unexpected migrated status: %+v", status)
+	}
+}
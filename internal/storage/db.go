@@ -27,6 +27,8 @@ CREATE TABLE IF NOT EXISTS folders (
 	total_messages INTEGER DEFAULT 0,
 	unread_messages INTEGER DEFAULT 0,
 	last_sync DATETIME,
+	uid_validity INTEGER DEFAULT 0,
+	highest_modseq INTEGER DEFAULT 0,
 	FOREIGN KEY (account_id) REFERENCES accounts(id),
 	UNIQUE(account_id, name)
 );
@@ -436,7 +438,7 @@ CREATE TABLE IF NOT EXISTS tasks (
 	priority INTEGER DEFAULT 0, -- 0=normal, 1=high, 2=urgent
 	due_date DATETIME,
 	email_id INTEGER, -- link opcional com email
-	source TEXT NOT NULL DEFAULT 'manual', -- 'manual', 'ai_suggestion'
+	source TEXT NOT NULL DEFAULT 'manual', -- 'manual', 'ai_suggestion', 'rule', 'follow_up'
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	FOREIGN KEY (account_id) REFERENCES accounts(id),
@@ -550,6 +552,16 @@ func Init(dbPath string) error {
 		return fmt.Errorf("erro na migração threading: %w", err)
 	}
 
+	// Migração: adiciona coluna uid_validity em folders
+	if err := migrateAddUIDValidity(); err != nil {
+		return fmt.Errorf("erro na migração uid_validity: %w", err)
+	}
+
+	// Migração: adiciona coluna highest_modseq em folders
+	if err := migrateAddHighestModSeq(); err != nil {
+		return fmt.Errorf("erro na migração highest_modseq: %w", err)
+	}
+
 	// Migração: adiciona coluna forward_to para batch ops
 	if err := migrateAddForwardTo(); err != nil {
 		return fmt.Errorf("erro na migração forward_to: %w", err)
@@ -575,6 +587,66 @@ func Init(dbPath string) error {
 		return fmt.Errorf("erro na migração snoozed_emails: %w", err)
 	}
 
+	// Migração: tabela de regras de filtro
+	if err := migrateMailRules(); err != nil {
+		return fmt.Errorf("erro na migração mail_rules: %w", err)
+	}
+
+	// Migração: tabelas de labels do Gmail
+	if err := migrateLabels(); err != nil {
+		return fmt.Errorf("erro na migração labels: %w", err)
+	}
+
+	// Migração: estado do sync pela API do Gmail
+	if err := migrateGmailSync(); err != nil {
+		return fmt.Errorf("erro na migração gmail_sync: %w", err)
+	}
+
+	// Migração: estado do sync por JMAP
+	if err := migrateJMAPSync(); err != nil {
+		return fmt.Errorf("erro na migração jmap_sync: %w", err)
+	}
+
+	// Migração: análise de risco (SPF/DKIM/DMARC e phishing)
+	if err := migrateEmailRisk(); err != nil {
+		return fmt.Errorf("erro na migração email_risk: %w", err)
+	}
+
+	// Migração: outbox (envios pendentes por falta de conexão)
+	if err := migrateOutbox(); err != nil {
+		return fmt.Errorf("erro na migração outbox: %w", err)
+	}
+
+	// Migração: fuso horário dos contatos (envio no horário do destinatário)
+	if err := migrateContactTimeZone(); err != nil {
+		return fmt.Errorf("erro na migração contacts.time_zone: %w", err)
+	}
+
+	// Migração: envios recorrentes
+	if err := migrateRecurringSchedules(); err != nil {
+		return fmt.Errorf("erro na migração recurring_schedules: %w", err)
+	}
+
+	// Migração: follow-ups (lembrar se ninguém responder)
+	if err := migrateFollowUps(); err != nil {
+		return fmt.Errorf("erro na migração follow_ups: %w", err)
+	}
+
+	// Migração: convites de reunião (iCalendar) em calendar_events
+	if err := migrateCalendarInvites(); err != nil {
+		return fmt.Errorf("erro na migração calendar_events invites: %w", err)
+	}
+
+	// Migração: sincronização CalDAV do calendário
+	if err := migrateCalendarSync(); err != nil {
+		return fmt.Errorf("erro na migração calendar sync: %w", err)
+	}
+
+	// Migração: contatos de servidores CardDAV (sync por fonte)
+	if err := migrateContactSources(); err != nil {
+		return fmt.Errorf("erro na migração contacts carddav: %w", err)
+	}
+
 	return nil
 }
 
@@ -646,6 +718,24 @@ func migrateAddThreading() error {
 	return nil
 }
 
+// migrateAddUIDValidity adiciona coluna uid_validity para detectar renumeração de pastas
+func migrateAddUIDValidity() error {
+	var _, err = db.Exec("ALTER TABLE folders ADD COLUMN uid_validity INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return err
+	}
+	return nil
+}
+
+// migrateAddHighestModSeq adiciona coluna highest_modseq para delta sync de flags (CONDSTORE)
+func migrateAddHighestModSeq() error {
+	var _, err = db.Exec("ALTER TABLE folders ADD COLUMN highest_modseq INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return err
+	}
+	return nil
+}
+
 // migrateAddForwardTo adiciona coluna forward_to para operações de forward em batch
 func migrateAddForwardTo() error {
 	var _, err = db.Exec("ALTER TABLE pending_batch_ops ADD COLUMN forward_to TEXT")
@@ -787,6 +877,268 @@ func migrateSnoozedEmails() error {
 	return nil
 }
 
+// migrateMailRules cria tabela de regras de filtro (condições e ações em JSON)
+func migrateMailRules() error {
+	var _, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS mail_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			enabled BOOLEAN DEFAULT 1,
+			position INTEGER DEFAULT 0,
+			match_any BOOLEAN DEFAULT 0,
+			stop BOOLEAN DEFAULT 0,
+			conditions TEXT NOT NULL DEFAULT '[]',
+			actions TEXT NOT NULL DEFAULT '[]',
+			hit_count INTEGER DEFAULT 0,
+			last_hit_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_mail_rules_account ON mail_rules(account_id, position)")
+	return nil
+}
+
+// migrateLabels cria as tabelas de labels do Gmail. Um label pode estar em
+// várias mensagens e a mesma mensagem aparece em várias pastas IMAP, então a
+// ligação é pelo Message-ID e não pelo id da linha em emails.
+func migrateLabels() error {
+	var _, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS labels (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			remote_id TEXT,
+			system BOOLEAN DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (account_id) REFERENCES accounts(id),
+			UNIQUE(account_id, name)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS email_labels (
+			account_id INTEGER NOT NULL,
+			message_id TEXT NOT NULL,
+			label_id INTEGER NOT NULL,
+			PRIMARY KEY (account_id, message_id, label_id),
+			FOREIGN KEY (label_id) REFERENCES labels(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_email_labels_label ON email_labels(label_id)")
+	return nil
+}
+
+// migrateGmailSync cria as tabelas do backend de sync pela API do Gmail: o
+// UID dado a cada mensagem em cada label (a API não tem UIDs) e o history ID
+// até onde cada label já foi sincronizado.
+func migrateGmailSync() error {
+	var _, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS gmail_sync_uids (
+			account_id INTEGER NOT NULL,
+			label_id TEXT NOT NULL,
+			uid INTEGER NOT NULL,
+			gmail_id TEXT NOT NULL,
+			PRIMARY KEY (account_id, label_id, uid),
+			UNIQUE(account_id, label_id, gmail_id),
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS gmail_sync_state (
+			account_id INTEGER NOT NULL,
+			label_id TEXT NOT NULL,
+			history_id INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (account_id, label_id),
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	return err
+}
+
+// migrateJMAPSync cria as tabelas do backend JMAP: o UID dado a cada email
+// em cada mailbox, o estado até onde cada mailbox já foi sincronizada e o
+// número (mod-sequence) dado a cada estado.
+func migrateJMAPSync() error {
+	var _, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS jmap_sync_uids (
+			account_id INTEGER NOT NULL,
+			mailbox_id TEXT NOT NULL,
+			uid INTEGER NOT NULL,
+			email_id TEXT NOT NULL,
+			PRIMARY KEY (account_id, mailbox_id, uid),
+			UNIQUE(account_id, mailbox_id, email_id),
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS jmap_sync_state (
+			account_id INTEGER NOT NULL,
+			mailbox_id TEXT NOT NULL,
+			state TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (account_id, mailbox_id),
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS jmap_modseqs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL,
+			state TEXT NOT NULL,
+			UNIQUE(account_id, state),
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	return err
+}
+
+// migrateEmailRisk cria a tabela com a análise de risco de cada email
+func migrateEmailRisk() error {
+	var _, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS email_risk (
+			email_id INTEGER PRIMARY KEY,
+			account_id INTEGER NOT NULL,
+			score INTEGER NOT NULL DEFAULT 0,
+			level TEXT NOT NULL DEFAULT '',
+			spf TEXT NOT NULL DEFAULT '',
+			dkim TEXT NOT NULL DEFAULT '',
+			dmarc TEXT NOT NULL DEFAULT '',
+			dkim_verified BOOLEAN DEFAULT 0,
+			findings TEXT NOT NULL DEFAULT '[]',
+			analyzed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE,
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_email_risk_account ON email_risk(account_id, level)")
+	return nil
+}
+
+// migrateOutbox cria a tabela da outbox. Cada entrada é um draft com status
+// queued/sending/failed; request guarda em JSON o que o draft não tem
+// (anexos, assinatura, criptografia)
+func migrateOutbox() error {
+	var _, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS outbox (
+			draft_id INTEGER PRIMARY KEY,
+			account_id INTEGER NOT NULL,
+			request TEXT NOT NULL DEFAULT '{}',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME,
+			last_error TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (draft_id) REFERENCES drafts(id) ON DELETE CASCADE,
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_outbox_account ON outbox(account_id, created_at)")
+	return nil
+}
+
+// migrateContactTimeZone adiciona a coluna time_zone (IANA, ex:
+// America/New_York) em contacts se não existir
+func migrateContactTimeZone() error {
+	var _, err = db.Exec("ALTER TABLE contacts ADD COLUMN time_zone TEXT")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return err
+	}
+	return nil
+}
+
+// migrateRecurringSchedules cria a tabela de envios recorrentes. Cada
+// entrada aponta para um draft modelo (status recurring) que é clonado a
+// cada ocorrência da regra (RRULE), avaliada no fuso time_zone
+func migrateRecurringSchedules() error {
+	var _, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS recurring_schedules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL,
+			draft_id INTEGER NOT NULL UNIQUE,
+			rule TEXT NOT NULL,
+			time_zone TEXT NOT NULL DEFAULT '',
+			starts_at DATETIME NOT NULL,
+			next_run_at DATETIME,
+			last_run_at DATETIME,
+			occurrences INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (draft_id) REFERENCES drafts(id) ON DELETE CASCADE,
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_recurring_schedules_next ON recurring_schedules(next_run_at)")
+	return nil
+}
+
+// migrateFollowUps cria a tabela de follow-ups. Um follow-up nasce preso ao
+// draft (waiting) ou já com o email enviado (pending); ao ser enviado ganha
+// message_id, o link com sent_emails e remind_at = sent_at + wait_seconds.
+func migrateFollowUps() error {
+	var _, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS follow_ups (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL,
+			draft_id INTEGER,
+			wait_seconds INTEGER NOT NULL,
+			to_addresses TEXT NOT NULL DEFAULT '',
+			subject TEXT NOT NULL DEFAULT '',
+			reply_to_email_id INTEGER, -- email respondido (thread de fallback)
+			message_id TEXT,
+			thread_id TEXT, -- Gmail API: thread do email enviado
+			sent_email_id INTEGER,
+			sent_at DATETIME,
+			remind_at DATETIME,
+			status TEXT NOT NULL DEFAULT 'waiting', -- waiting, pending, replied, reminded, cancelled
+			email_id INTEGER, -- email que voltou para o inbox
+			task_id INTEGER,
+			nudge_draft_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (account_id) REFERENCES accounts(id),
+			FOREIGN KEY (draft_id) REFERENCES drafts(id) ON DELETE SET NULL,
+			FOREIGN KEY (sent_email_id) REFERENCES sent_emails(id) ON DELETE SET NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_follow_ups_remind ON follow_ups(status, remind_at)")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_follow_ups_draft ON follow_ups(draft_id)")
+	return nil
+}
+
 func GetDB() *sqlx.DB {
 	return db
 }
@@ -797,3 +1149,148 @@ func Close() error {
 	}
 	return nil
 }
+
+// migrateCalendarInvites adiciona em calendar_events as colunas dos convites
+// recebidos por email: local, identidade iTIP (UID, RECURRENCE-ID e
+// SEQUENCE), o VCALENDAR recebido (para responder) e a resposta do usuário
+func migrateCalendarInvites() error {
+	var columns = []string{
+		"location TEXT",
+		"ical_uid TEXT",
+		"ical_recurrence_id TEXT NOT NULL DEFAULT ''",
+		"ical_sequence INTEGER NOT NULL DEFAULT 0",
+		"ical_data TEXT",
+		"rsvp_status TEXT",
+	}
+	for _, column := range columns {
+		var _, err = db.Exec("ALTER TABLE calendar_events ADD COLUMN " + column)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return err
+		}
+	}
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_calendar_events_ical ON calendar_events(account_id, ical_uid, ical_recurrence_id)")
+	return nil
+}
+
+// migrateCalendarSync adiciona o que a sincronização de calendários remotos
+// (CalDAV) precisa: o objeto e a ETag de cada evento no servidor, a versão
+// do servidor quando há conflito, o estado (ctag) de cada calendário e os
+// eventos apagados localmente que ainda precisam ser apagados no servidor
+func migrateCalendarSync() error {
+	var columns = []string{
+		"caldav_href TEXT",
+		"caldav_etag TEXT",
+		"conflict_etag TEXT",
+		"conflict_data TEXT",
+	}
+	for _, column := range columns {
+		var _, err = db.Exec("ALTER TABLE calendar_events ADD COLUMN " + column)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return err
+		}
+	}
+
+	var _, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS calendar_sync_state (
+			account_id INTEGER NOT NULL,
+			source TEXT NOT NULL, -- 'caldav', 'google'
+			collection_url TEXT NOT NULL DEFAULT '',
+			ctag TEXT NOT NULL DEFAULT '',
+			sync_token TEXT NOT NULL DEFAULT '',
+			last_sync_at DATETIME,
+			PRIMARY KEY (account_id, source),
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS calendar_deleted_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL,
+			source TEXT NOT NULL, -- 'caldav', 'google'
+			remote_id TEXT NOT NULL, -- href no servidor ou ID do evento no Google
+			etag TEXT NOT NULL DEFAULT '',
+			deleted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	// Google: calendário do evento apagado
+	_, err = db.Exec("ALTER TABLE calendar_deleted_events ADD COLUMN collection TEXT NOT NULL DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return err
+	}
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_calendar_events_caldav ON calendar_events(account_id, caldav_href)")
+	return nil
+}
+
+// migrateContactSources prepara os contatos vindos de servidores CardDAV:
+// em contacts, a etag e o vCard do servidor e se há alterações locais a
+// enviar; em contacts_sync_state, um estado por fonte (google, carddav).
+// Trocar UNIQUE(account_id) por UNIQUE(account_id, source) exige recriar a
+// tabela no SQLite.
+func migrateContactSources() error {
+	var columns = []string{
+		"carddav_etag TEXT",
+		"vcard_data TEXT",
+		"pending_sync INTEGER NOT NULL DEFAULT 0",
+	}
+	for _, column := range columns {
+		var _, err = db.Exec("ALTER TABLE contacts ADD COLUMN " + column)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return err
+		}
+	}
+
+	var hasSource int
+	if err := db.Get(&hasSource, "SELECT COUNT(*) FROM pragma_table_info('contacts_sync_state') WHERE name = 'source'"); err != nil {
+		return err
+	}
+	if hasSource > 0 {
+		return nil
+	}
+
+	var tx, err = db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var statements = []string{
+		`CREATE TABLE contacts_sync_state_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL,
+			source TEXT NOT NULL DEFAULT 'google', -- google, carddav
+			last_sync_token TEXT,
+			last_full_sync DATETIME,
+			last_incremental_sync DATETIME,
+			total_contacts INTEGER DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'never_synced', -- never_synced, syncing, synced, error
+			error_message TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(account_id, source),
+			FOREIGN KEY (account_id) REFERENCES accounts(id)
+		)`,
+		`INSERT INTO contacts_sync_state_new (
+			id, account_id, source, last_sync_token, last_full_sync, last_incremental_sync,
+			total_contacts, status, error_message, created_at, updated_at
+		)
+		SELECT id, account_id, 'google', last_sync_token, last_full_sync, last_incremental_sync,
+			total_contacts, status, error_message, created_at, updated_at
+		FROM contacts_sync_state`,
+		`DROP TABLE contacts_sync_state`,
+		`ALTER TABLE contacts_sync_state_new RENAME TO contacts_sync_state`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// === FOLLOW-UPS ===
+
+// FollowUpStatus é o estado de um follow-up
+type FollowUpStatus string
+
+const (
+	FollowUpWaiting   FollowUpStatus = "waiting"   // Draft ainda não enviado
+	FollowUpPending   FollowUpStatus = "pending"   // Enviado, aguardando remind_at
+	FollowUpReplied   FollowUpStatus = "replied"   // Alguém respondeu a tempo
+	FollowUpReminded  FollowUpStatus = "reminded"  // Ninguém respondeu: usuário lembrado
+	FollowUpCancelled FollowUpStatus = "cancelled" // Cancelado pelo usuário
+)
+
+// FollowUp é um "me lembre se ninguém responder" de um email enviado
+type FollowUp struct {
+	ID             int64          `db:"id"`
+	AccountID      int64          `db:"account_id"`
+	DraftID        sql.NullInt64  `db:"draft_id"`
+	WaitSeconds    int64          `db:"wait_seconds"`
+	ToAddresses    string         `db:"to_addresses"`
+	Subject        string         `db:"subject"`
+	ReplyToEmailID sql.NullInt64  `db:"reply_to_email_id"`
+	MessageID      sql.NullString `db:"message_id"`
+	ThreadID       sql.NullString `db:"thread_id"`
+	SentEmailID    sql.NullInt64  `db:"sent_email_id"`
+	SentAt         SQLiteTime     `db:"sent_at"`
+	RemindAt       SQLiteTime     `db:"remind_at"`
+	Status         FollowUpStatus `db:"status"`
+	EmailID        sql.NullInt64  `db:"email_id"`
+	TaskID         sql.NullInt64  `db:"task_id"`
+	NudgeDraftID   sql.NullInt64  `db:"nudge_draft_id"`
+	CreatedAt      SQLiteTime     `db:"created_at"`
+}
+
+// CreateFollowUp cria um follow-up. Com status pending o email já foi
+// enviado: remind_at é sent_at + wait_seconds e o follow-up é ligado ao
+// registro em sent_emails pelo Message-ID.
+func CreateFollowUp(f *FollowUp) (int64, error) {
+	var remindAt SQLiteTime
+	if f.Status == FollowUpPending {
+		remindAt = SQLiteTime{f.SentAt.UTC().Add(time.Duration(f.WaitSeconds) * time.Second)}
+	}
+
+	var result, err = db.Exec(`
+		INSERT INTO follow_ups (
+			account_id, draft_id, wait_seconds, to_addresses, subject,
+			reply_to_email_id, message_id, thread_id, sent_email_id,
+			sent_at, remind_at, status
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?,
+			(SELECT MAX(id) FROM sent_emails WHERE account_id = ? AND message_id = ?),
+			?, ?, ?)`,
+		f.AccountID, f.DraftID, f.WaitSeconds, f.ToAddresses, f.Subject,
+		f.ReplyToEmailID, f.MessageID, f.ThreadID,
+		f.AccountID, f.MessageID,
+		SQLiteTime{f.SentAt.UTC()}, remindAt, f.Status)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// StartDraftFollowUps passa para pending os follow-ups de um draft que
+// acabou de ser enviado. Retorna quantos foram iniciados.
+func StartDraftFollowUps(draftID int64, messageID, threadID string, sentAt time.Time) (int64, error) {
+	var sent = SQLiteTime{sentAt.UTC()}
+	var result, err = db.Exec(`
+		UPDATE follow_ups SET
+			status = 'pending',
+			message_id = NULLIF(?, ''),
+			thread_id = NULLIF(?, ''),
+			sent_email_id = (
+				SELECT MAX(id) FROM sent_emails
+				WHERE account_id = follow_ups.account_id AND message_id = NULLIF(?, '')
+			),
+			sent_at = ?,
+			remind_at = datetime(?, '+' || wait_seconds || ' seconds')
+		WHERE draft_id = ? AND status = 'waiting'`,
+		messageID, threadID, messageID, sent, sent, draftID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CancelDraftFollowUps cancela os follow-ups de um draft ainda não enviado
+func CancelDraftFollowUps(draftID int64) error {
+	var _, err = db.Exec(`
+		UPDATE follow_ups SET status = 'cancelled'
+		WHERE draft_id = ? AND status = 'waiting'`, draftID)
+	return err
+}
+
+// GetFollowUp busca um follow-up (nil se não existir)
+func GetFollowUp(id int64) (*FollowUp, error) {
+	var f FollowUp
+	var err = db.Get(&f, "SELECT * FROM follow_ups WHERE id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetFollowUps busca os follow-ups ainda abertos de uma conta: os enviados
+// pelo lembrete mais próximo, depois os que aguardam o envio
+func GetFollowUps(accountID int64) ([]FollowUp, error) {
+	var list []FollowUp
+	var err = db.Select(&list, `
+		SELECT * FROM follow_ups
+		WHERE account_id = ? AND status IN ('waiting', 'pending')
+		ORDER BY remind_at IS NULL, remind_at, id`, accountID)
+	return list, err
+}
+
+// GetDueFollowUps busca os follow-ups de uma conta com lembrete até now
+func GetDueFollowUps(accountID int64, now time.Time) ([]FollowUp, error) {
+	var list []FollowUp
+	var err = db.Select(&list, `
+		SELECT * FROM follow_ups
+		WHERE account_id = ? AND status = 'pending' AND remind_at <= ?
+		ORDER BY remind_at`, accountID, SQLiteTime{now.UTC()})
+	return list, err
+}
+
+// NextFollowUp retorna o próximo lembrete de uma conta (zero se não houver)
+func NextFollowUp(accountID int64) (time.Time, error) {
+	var next SQLiteTime
+	var err = db.Get(&next, `
+		SELECT MIN(remind_at) FROM follow_ups
+		WHERE account_id = ? AND status = 'pending'`, accountID)
+	return next.Time, err
+}
+
+// ClaimFollowUp encerra um follow-up pendente com o status dado. Retorna
+// false se outro processo já o encerrou (ou ele foi cancelado).
+func ClaimFollowUp(id int64, status FollowUpStatus) (bool, error) {
+	var result, err = db.Exec(`
+		UPDATE follow_ups SET status = ?
+		WHERE id = ? AND status = 'pending'`, status, id)
+	if err != nil {
+		return false, err
+	}
+	var n, _ = result.RowsAffected()
+	return n > 0, nil
+}
+
+// SetFollowUpReminder registra o que o lembrete criou (0 = nada)
+func SetFollowUpReminder(id, emailID, taskID, nudgeDraftID int64) error {
+	var _, err = db.Exec(`
+		UPDATE follow_ups SET
+			email_id = NULLIF(?, 0),
+			task_id = NULLIF(?, 0),
+			nudge_draft_id = NULLIF(?, 0)
+		WHERE id = ?`, emailID, taskID, nudgeDraftID, id)
+	return err
+}
+
+// CancelFollowUp cancela um follow-up que ainda não disparou. Retorna false
+// se ele não estava mais aberto.
+func CancelFollowUp(id int64) (bool, error) {
+	var result, err = db.Exec(`
+		UPDATE follow_ups SET status = 'cancelled'
+		WHERE id = ? AND status IN ('waiting', 'pending')`, id)
+	if err != nil {
+		return false, err
+	}
+	var n, _ = result.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestFollowUps tests a follow-up going from its draft to the reminder
+func TestFollowUps(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, _ = GetOrCreateAccount("me@example.org", "Me")
+	var draftID, err = CreateDraft(&Draft{
+		AccountID:        account.ID,
+		ToAddresses:      "client@example.com",
+		Subject:          "Proposal",
+		Status:           DraftStatusDraft,
+		GenerationSource: "manual",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create draft: %v", err)
+	}
+
+	var id, errCreate = CreateFollowUp(&FollowUp{
+		AccountID:   account.ID,
+		DraftID:     sql.NullInt64{Int64: draftID, Valid: true},
+		WaitSeconds: int64((72 * time.Hour).Seconds()),
+		ToAddresses: "client@example.com",
+		Subject:     "Proposal",
+		Status:      FollowUpWaiting,
+	})
+	if errCreate != nil {
+		t.Fatalf("Failed to create follow-up: %v", errCreate)
+	}
+
+	// Waiting follow-ups have no reminder yet
+	if list, _ := GetFollowUps(account.ID); len(list) != 1 || list[0].Status != FollowUpWaiting || !list[0].RemindAt.IsZero() {
+		t.Fatalf("Unexpected follow-ups: %+v", list)
+	}
+	if next, _ := NextFollowUp(account.ID); !next.IsZero() {
+		t.Errorf("Expected no reminder before the email is sent, got %v", next)
+	}
+
+	// Sending the draft starts the follow-up and links it to sent_emails
+	var sentAt = time.Date(2026, 10, 16, 14, 30, 0, 0, time.UTC)
+	var sentID, _ = RecordSentEmail(account.ID, "<proposal@example.org>", "client@example.com", "", "", "Proposal", "", "", "", "", "smtp", sql.NullInt64{}, sql.NullInt64{Int64: draftID, Valid: true})
+	if n, err := StartDraftFollowUps(draftID, "<proposal@example.org>", "", sentAt); err != nil || n != 1 {
+		t.Fatalf("Failed to start follow-up: %d, %v", n, err)
+	}
+	var followUp, _ = GetFollowUp(id)
+	var remindAt = sentAt.Add(72 * time.Hour)
+	if followUp.Status != FollowUpPending || !followUp.RemindAt.Equal(remindAt) {
+		t.Errorf("Expected pending follow-up reminding at %v, got %+v", remindAt, followUp)
+	}
+	if followUp.SentEmailID.Int64 != sentID || followUp.ThreadID.Valid {
+		t.Errorf("Expected sent email %d and no thread, got %+v", sentID, followUp)
+	}
+
+	// Due only once the reminder time is reached
+	if due, _ := GetDueFollowUps(account.ID, remindAt.Add(-time.Minute)); len(due) != 0 {
+		t.Errorf("Expected nothing due, got %d", len(due))
+	}
+	if due, _ := GetDueFollowUps(account.ID, remindAt); len(due) != 1 {
+		t.Fatalf("Expected 1 due follow-up, got %d", len(due))
+	}
+	if next, _ := NextFollowUp(account.ID); !next.Equal(remindAt) {
+		t.Errorf("Expected next reminder %v, got %v", remindAt, next)
+	}
+
+	// A follow-up fires once
+	if claimed, _ := ClaimFollowUp(id, FollowUpReminded); !claimed {
+		t.Fatal("Expected to claim the follow-up")
+	}
+	if claimed, _ := ClaimFollowUp(id, FollowUpReplied); claimed {
+		t.Error("Expected the follow-up to be claimed only once")
+	}
+	if err := SetFollowUpReminder(id, 0, 7, 0); err != nil {
+		t.Fatalf("Failed to record reminder: %v", err)
+	}
+	followUp, _ = GetFollowUp(id)
+	if followUp.TaskID.Int64 != 7 || followUp.EmailID.Valid || followUp.NudgeDraftID.Valid {
+		t.Errorf("Unexpected reminder: %+v", followUp)
+	}
+	if list, _ := GetFollowUps(account.ID); len(list) != 0 {
+		t.Errorf("Expected no open follow-ups, got %d", len(list))
+	}
+
+	// Only open follow-ups can be cancelled
+	if cancelled, _ := CancelFollowUp(id); cancelled {
+		t.Error("Expected a reminded follow-up not to be cancelled")
+	}
+	var other, _ = CreateFollowUp(&FollowUp{
+		AccountID:   account.ID,
+		WaitSeconds: 60,
+		Subject:     "Other",
+		MessageID:   sql.NullString{String: "<other@example.org>", Valid: true},
+		SentAt:      SQLiteTime{sentAt},
+		Status:      FollowUpPending,
+	})
+	if followUp, _ := GetFollowUp(other); !followUp.RemindAt.Equal(sentAt.Add(time.Minute)) {
+		t.Errorf("Expected reminder one minute after sending, got %v", followUp.RemindAt)
+	}
+	if cancelled, _ := CancelFollowUp(other); !cancelled {
+		t.Error("Expected the pending follow-up to be cancelled")
+	}
+}
@@ -0,0 +1,48 @@
+package storage
+
+// === ESTADO DO SYNC PELA API DO GMAIL ===
+// Os UIDs ficam por label (ver remoteUIDTable); "" é o All Mail.
+
+// AssignGmailUIDs retorna o UID de cada mensagem no label, dando os próximos
+// UIDs (na ordem recebida) às que ainda não têm
+func AssignGmailUIDs(accountID int64, labelID string, gmailIDs []string) (map[string]uint32, error) {
+	return gmailUIDTable.assign(accountID, labelID, gmailIDs)
+}
+
+// LookupGmailUIDs retorna os UIDs já dados às mensagens no label (sem criar novos)
+func LookupGmailUIDs(accountID int64, labelID string, gmailIDs []string) (map[string]uint32, error) {
+	return gmailUIDTable.lookup(accountID, labelID, gmailIDs)
+}
+
+// GetGmailIDs retorna o ID do Gmail de cada UID do label
+func GetGmailIDs(accountID int64, labelID string, uids []uint32) (map[uint32]string, error) {
+	return gmailUIDTable.remoteIDs(accountID, labelID, uids)
+}
+
+// GetGmailIDsAfter retorna UID -> ID do Gmail das mensagens do label com UID
+// maior que uid (0 retorna todas)
+func GetGmailIDsAfter(accountID int64, labelID string, uid uint32) (map[uint32]string, error) {
+	return gmailUIDTable.remoteIDsAfter(accountID, labelID, uid)
+}
+
+// GetGmailHistoryID retorna o history ID até onde o label foi sincronizado (0 = nunca)
+func GetGmailHistoryID(accountID int64, labelID string) (uint64, error) {
+	var ids []uint64
+	var err = db.Select(&ids, "SELECT history_id FROM gmail_sync_state WHERE account_id = ? AND label_id = ?", accountID, labelID)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// SetGmailHistoryID guarda o history ID até onde o label foi sincronizado
+func SetGmailHistoryID(accountID int64, labelID string, historyID uint64) error {
+	var _, err = db.Exec(`
+		INSERT INTO gmail_sync_state (account_id, label_id, history_id, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(account_id, label_id) DO UPDATE SET
+			history_id = excluded.history_id,
+			updated_at = CURRENT_TIMESTAMP`,
+		accountID, labelID, historyID)
+	return err
+}
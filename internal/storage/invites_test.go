@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestInviteEvents tests events imported from email invites
+func TestInviteEvents(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, _ = GetOrCreateAccount("me@example.org", "Me")
+	var inbox, _ = GetOrCreateFolder(account.ID, "INBOX")
+	var email = Email{
+		AccountID: account.ID,
+		FolderID:  inbox.ID,
+		UID:       1,
+		Subject:   "Invitation: Weekly sync",
+		FromEmail: "jane@example.com",
+		Date:      SQLiteTime{time.Now()},
+	}
+	var emailID, _, err = UpsertEmail(&email)
+	if err != nil {
+		t.Fatalf("Failed to insert email: %v", err)
+	}
+
+	var start = time.Date(2026, 10, 27, 9, 0, 0, 0, time.UTC)
+	var newEvent = func(rid string) *CalendarEvent {
+		return &CalendarEvent{
+			AccountID:        account.ID,
+			Title:            "Weekly sync",
+			EventType:        CalendarEventTypeMeeting,
+			StartTime:        SQLiteTime{start},
+			EmailID:          sql.NullInt64{Int64: emailID, Valid: true},
+			Source:           CalendarEventSourceEmailInvite,
+			SyncStatus:       CalendarSyncStatusLocal,
+			Location:         sql.NullString{String: "Room 4", Valid: true},
+			ICalUID:          sql.NullString{String: "uid-1", Valid: true},
+			ICalRecurrenceID: rid,
+			ICalSequence:     1,
+			ICalData:         sql.NullString{String: "BEGIN:VCALENDAR", Valid: true},
+		}
+	}
+
+	// An exception created before the series: the series still comes first
+	var exception = newEvent("20261103T090000Z")
+	if err := CreateCalendarEvent(exception); err != nil {
+		t.Fatalf("Failed to create exception: %v", err)
+	}
+	var series = newEvent("")
+	if err := CreateCalendarEvent(series); err != nil {
+		t.Fatalf("Failed to create series: %v", err)
+	}
+
+	if found, _ := GetInviteEventByEmail(emailID); found == nil || found.ID != series.ID {
+		t.Fatalf("Expected the series, got %+v", found)
+	}
+	if found, _ := GetCalendarEventByICalUID(account.ID, "uid-1", "20261103T090000Z"); found == nil || found.ID != exception.ID {
+		t.Errorf("Expected the exception, got %+v", found)
+	}
+	if found, err := GetCalendarEventByICalUID(account.ID, "uid-2", ""); found != nil || err != nil {
+		t.Errorf("Expected no event, got %+v, %v", found, err)
+	}
+
+	// An update of the invite
+	series.Title = "Weekly sync (moved)"
+	series.ICalSequence = 2
+	series.IsCompleted = true
+	if err := UpdateInviteEvent(series); err != nil {
+		t.Fatalf("Failed to update event: %v", err)
+	}
+	if err := SetCalendarEventRSVP(series.ID, "ACCEPTED"); err != nil {
+		t.Fatalf("Failed to set RSVP: %v", err)
+	}
+	var saved, _ = GetCalendarEvent(series.ID)
+	if saved.Title != "Weekly sync (moved)" || saved.ICalSequence != 2 || !saved.IsCompleted || saved.RSVPStatus.String != "ACCEPTED" {
+		t.Errorf("Unexpected event after update: %+v", saved)
+	}
+	if saved.Location.String != "Room 4" || saved.EventType != CalendarEventTypeMeeting {
+		t.Errorf("Expected location and type kept, got %+v", saved)
+	}
+
+	// Events without ical_data are not invites
+	db.Exec(`UPDATE calendar_events SET ical_data = NULL WHERE email_id = ?`, emailID)
+	if found, _ := GetInviteEventByEmail(emailID); found != nil {
+		t.Errorf("Expected no invite event, got %+v", found)
+	}
+}
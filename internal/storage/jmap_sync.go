@@ -0,0 +1,82 @@
+package storage
+
+// === ESTADO DO SYNC POR JMAP ===
+// Os UIDs ficam por mailbox (ver remoteUIDTable). O JMAP marca mudanças com
+// strings de estado opacas; cada estado visto ganha um número crescente em
+// jmap_modseqs, que faz o papel do HIGHESTMODSEQ do IMAP.
+
+// AssignJMAPUIDs retorna o UID de cada email na mailbox, dando os próximos
+// UIDs (na ordem recebida) aos que ainda não têm
+func AssignJMAPUIDs(accountID int64, mailboxID string, emailIDs []string) (map[string]uint32, error) {
+	return jmapUIDTable.assign(accountID, mailboxID, emailIDs)
+}
+
+// LookupJMAPUIDs retorna os UIDs já dados aos emails na mailbox (sem criar novos)
+func LookupJMAPUIDs(accountID int64, mailboxID string, emailIDs []string) (map[string]uint32, error) {
+	return jmapUIDTable.lookup(accountID, mailboxID, emailIDs)
+}
+
+// GetJMAPEmailIDs retorna o ID JMAP de cada UID da mailbox
+func GetJMAPEmailIDs(accountID int64, mailboxID string, uids []uint32) (map[uint32]string, error) {
+	return jmapUIDTable.remoteIDs(accountID, mailboxID, uids)
+}
+
+// GetJMAPEmailIDsAfter retorna UID -> ID JMAP dos emails da mailbox com UID
+// maior que uid (0 retorna todos)
+func GetJMAPEmailIDsAfter(accountID int64, mailboxID string, uid uint32) (map[uint32]string, error) {
+	return jmapUIDTable.remoteIDsAfter(accountID, mailboxID, uid)
+}
+
+// GetJMAPMailboxState retorna o estado até onde a mailbox foi sincronizada ("" = nunca)
+func GetJMAPMailboxState(accountID int64, mailboxID string) (string, error) {
+	var states []string
+	var err = db.Select(&states, "SELECT state FROM jmap_sync_state WHERE account_id = ? AND mailbox_id = ?", accountID, mailboxID)
+	if err != nil || len(states) == 0 {
+		return "", err
+	}
+	return states[0], nil
+}
+
+// SetJMAPMailboxState guarda o estado até onde a mailbox foi sincronizada
+func SetJMAPMailboxState(accountID int64, mailboxID, state string) error {
+	var _, err = db.Exec(`
+		INSERT INTO jmap_sync_state (account_id, mailbox_id, state, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(account_id, mailbox_id) DO UPDATE SET
+			state = excluded.state,
+			updated_at = CURRENT_TIMESTAMP`,
+		accountID, mailboxID, state)
+	return err
+}
+
+// maxJMAPModSeqs é quantos estados guardar por conta; um estado esquecido só
+// faz o próximo sync de flags reler a pasta inteira
+const maxJMAPModSeqs = 1000
+
+// GetJMAPModSeq retorna o número do estado, criando um novo (maior que
+// todos os anteriores) na primeira vez que o estado aparece
+func GetJMAPModSeq(accountID int64, state string) (uint64, error) {
+	var result, err = db.Exec("INSERT OR IGNORE INTO jmap_modseqs (account_id, state) VALUES (?, ?)", accountID, state)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		if id, err := result.LastInsertId(); err == nil {
+			db.Exec("DELETE FROM jmap_modseqs WHERE account_id = ? AND id <= ?", accountID, id-maxJMAPModSeqs)
+		}
+	}
+
+	var modSeq uint64
+	err = db.Get(&modSeq, "SELECT id FROM jmap_modseqs WHERE account_id = ? AND state = ?", accountID, state)
+	return modSeq, err
+}
+
+// GetJMAPStateAt retorna o estado de um número dado por GetJMAPModSeq ("" se não existir)
+func GetJMAPStateAt(accountID int64, modSeq uint64) (string, error) {
+	var states []string
+	var err = db.Select(&states, "SELECT state FROM jmap_modseqs WHERE account_id = ? AND id = ?", accountID, modSeq)
+	if err != nil || len(states) == 0 {
+		return "", err
+	}
+	return states[0], nil
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Label é um label do Gmail (ou da conta) guardado localmente
+type Label struct {
+	ID        int64          `db:"id"`
+	AccountID int64          `db:"account_id"`
+	Name      string         `db:"name"`
+	RemoteID  sql.NullString `db:"remote_id"`
+	System    bool           `db:"system"`
+	CreatedAt SQLiteTime     `db:"created_at"`
+}
+
+// LabelSummary é um label com a contagem de mensagens locais
+type LabelSummary struct {
+	Label
+	TotalMessages  int `db:"total_messages"`
+	UnreadMessages int `db:"unread_messages"`
+}
+
+// normalizeMessageID remove os <> do Message-ID, como o IMAP e a API do Gmail
+// nem sempre concordam sobre eles
+func normalizeMessageID(messageID string) string {
+	return strings.Trim(strings.TrimSpace(messageID), "<>")
+}
+
+// UpsertLabel cria o label ou atualiza o ID remoto e o tipo dele
+func UpsertLabel(accountID int64, name, remoteID string, system bool) (*Label, error) {
+	var _, err = db.Exec(`
+		INSERT INTO labels (account_id, name, remote_id, system)
+		VALUES (?, ?, NULLIF(?, ''), ?)
+		ON CONFLICT(account_id, name) DO UPDATE SET
+			remote_id = COALESCE(excluded.remote_id, labels.remote_id),
+			system = excluded.system`,
+		accountID, name, remoteID, system)
+	if err != nil {
+		return nil, err
+	}
+	return GetLabelByName(accountID, name)
+}
+
+// GetLabelByName busca um label pelo nome
+func GetLabelByName(accountID int64, name string) (*Label, error) {
+	var label Label
+	var err = db.Get(&label, "SELECT * FROM labels WHERE account_id = ? AND name = ?", accountID, name)
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// GetLabels retorna os labels da conta com as contagens de mensagens. Cada
+// mensagem conta uma vez, mesmo que esteja em várias pastas.
+func GetLabels(accountID int64) ([]LabelSummary, error) {
+	var labels []LabelSummary
+	var err = db.Select(&labels, `
+		SELECT l.*,
+			COUNT(DISTINCT e.message_id) AS total_messages,
+			COUNT(DISTINCT CASE WHEN e.is_read = 0 THEN e.message_id END) AS unread_messages
+		FROM labels l
+		LEFT JOIN email_labels el ON el.label_id = l.id
+		LEFT JOIN emails e ON e.account_id = el.account_id AND e.message_id = el.message_id AND e.is_deleted = 0
+		WHERE l.account_id = ?
+		GROUP BY l.id
+		ORDER BY l.system DESC, l.name COLLATE NOCASE`,
+		accountID)
+	return labels, err
+}
+
+// DeleteLabel remove o label e as ligações dele com as mensagens
+func DeleteLabel(accountID int64, name string) error {
+	var _, err = db.Exec("DELETE FROM labels WHERE account_id = ? AND name = ?", accountID, name)
+	return err
+}
+
+// SetMessageLabels substitui os labels de uma mensagem, criando os que
+// ainda não existem
+func SetMessageLabels(accountID int64, messageID string, names []string) error {
+	messageID = normalizeMessageID(messageID)
+	if messageID == "" {
+		return nil
+	}
+
+	var tx, err = db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM email_labels WHERE account_id = ? AND message_id = ?", accountID, messageID); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, err := tx.Exec(`
+			INSERT INTO labels (account_id, name) VALUES (?, ?)
+			ON CONFLICT(account_id, name) DO NOTHING`,
+			accountID, name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO email_labels (account_id, message_id, label_id)
+			SELECT ?, ?, id FROM labels WHERE account_id = ? AND name = ?`,
+			accountID, messageID, accountID, name); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// AddMessageLabel coloca um label numa mensagem, criando o label se preciso
+func AddMessageLabel(accountID int64, messageID, name string) error {
+	messageID = normalizeMessageID(messageID)
+	var label, err = GetLabelByName(accountID, name)
+	if err == sql.ErrNoRows {
+		label, err = UpsertLabel(accountID, name, "", false)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT OR IGNORE INTO email_labels (account_id, message_id, label_id)
+		VALUES (?, ?, ?)`,
+		accountID, messageID, label.ID)
+	return err
+}
+
+// RemoveMessageLabel tira um label de uma mensagem
+func RemoveMessageLabel(accountID int64, messageID, name string) error {
+	var _, err = db.Exec(`
+		DELETE FROM email_labels
+		WHERE account_id = ? AND message_id = ?
+		  AND label_id = (SELECT id FROM labels WHERE account_id = ? AND name = ?)`,
+		accountID, normalizeMessageID(messageID), accountID, name)
+	return err
+}
+
+// GetLabelsForMessages retorna os nomes dos labels de cada Message-ID
+func GetLabelsForMessages(accountID int64, messageIDs []string) (map[string][]string, error) {
+	var result = make(map[string][]string)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	var placeholders = make([]string, len(messageIDs))
+	var args = []interface{}{accountID}
+	for i, id := range messageIDs {
+		placeholders[i] = "?"
+		args = append(args, normalizeMessageID(id))
+	}
+
+	var rows []struct {
+		MessageID string `db:"message_id"`
+		Name      string `db:"name"`
+	}
+	var err = db.Select(&rows, `
+		SELECT el.message_id, l.name
+		FROM email_labels el
+		JOIN labels l ON l.id = el.label_id
+		WHERE el.account_id = ? AND el.message_id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY l.system DESC, l.name COLLATE NOCASE`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.MessageID] = append(result[row.MessageID], row.Name)
+	}
+	return result, nil
+}
+
+// GetEmailsByLabel retorna as mensagens com o label, uma linha por
+// Message-ID (a mesma mensagem em INBOX e All Mail aparece uma vez só).
+// Inclui arquivados, já que no Gmail arquivar só tira o label INBOX.
+func GetEmailsByLabel(accountID int64, name string, limit, offset int) ([]EmailSummary, error) {
+	var emails []EmailSummary
+	var err = db.Select(&emails, `
+		SELECT e.id, e.account_id, e.uid, e.message_id, e.subject, e.from_name, e.from_email,
+			e.date, e.is_read, e.is_starred, e.is_replied, e.has_attachments, e.snippet, e.thread_id,
+			f.name AS folder_name
+		FROM emails e
+		JOIN folders f ON f.id = e.folder_id
+		WHERE e.id IN (
+			SELECT MIN(e2.id)
+			FROM emails e2
+			JOIN email_labels el ON el.account_id = e2.account_id AND el.message_id = e2.message_id
+			JOIN labels l ON l.id = el.label_id
+			WHERE e2.account_id = ? AND l.name = ? AND e2.is_deleted = 0
+			GROUP BY e2.message_id
+		)
+		ORDER BY e.date DESC
+		LIMIT ? OFFSET ?`,
+		accountID, name, limit, offset)
+	return emails, err
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMessageLabels tests that a message in several folders is listed once per label
+func TestMessageLabels(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, _ = GetOrCreateAccount("me@gmail.com", "Me")
+	var inbox, _ = GetOrCreateFolder(account.ID, "INBOX")
+	var allMail, _ = GetOrCreateFolder(account.ID, "[Gmail]/All Mail")
+
+	var now = time.Now()
+	var insert = func(folderID int64, uid uint32, messageID, subject string, age time.Duration) {
+		var email = Email{
+			AccountID: account.ID,
+			FolderID:  folderID,
+			UID:       uid,
+			MessageID: sql.NullString{String: messageID, Valid: true},
+			Subject:   subject,
+			FromEmail: "sender@example.com",
+			Date:      SQLiteTime{now.Add(-age)},
+		}
+		if _, _, err := UpsertEmail(&email); err != nil {
+			t.Fatalf("Failed to insert %q: %v", subject, err)
+		}
+	}
+	insert(inbox.ID, 1, "a@example.com", "invoice", time.Hour)
+	insert(allMail.ID, 10, "a@example.com", "invoice", time.Hour)
+	insert(allMail.ID, 11, "b@example.com", "receipt", time.Minute)
+
+	if err := SetMessageLabels(account.ID, "<a@example.com>", []string{"INBOX", "Finance"}); err != nil {
+		t.Fatalf("SetMessageLabels failed: %v", err)
+	}
+	if err := AddMessageLabel(account.ID, "b@example.com", "Finance"); err != nil {
+		t.Fatalf("AddMessageLabel failed: %v", err)
+	}
+
+	var emails, err = GetEmailsByLabel(account.ID, "Finance", 10, 0)
+	if err != nil {
+		t.Fatalf("GetEmailsByLabel failed: %v", err)
+	}
+	if len(emails) != 2 || emails[0].Subject != "receipt" || emails[1].Subject != "invoice" {
+		t.Fatalf("Expected [receipt invoice], got %+v", emails)
+	}
+
+	var labels, _ = GetLabels(account.ID)
+	var counts = make(map[string]int)
+	for _, l := range labels {
+		counts[l.Name] = l.TotalMessages
+	}
+	if counts["Finance"] != 2 || counts["INBOX"] != 1 {
+		t.Errorf("Unexpected label counts: %v", counts)
+	}
+
+	var byMessage, _ = GetLabelsForMessages(account.ID, []string{"a@example.com"})
+	if len(byMessage["a@example.com"]) != 2 {
+		t.Errorf("Expected 2 labels on a@example.com, got %v", byMessage["a@example.com"])
+	}
+
+	if err := RemoveMessageLabel(account.ID, "a@example.com", "Finance"); err != nil {
+		t.Fatalf("RemoveMessageLabel failed: %v", err)
+	}
+	if err := DeleteLabel(account.ID, "INBOX"); err != nil {
+		t.Fatalf("DeleteLabel failed: %v", err)
+	}
+	byMessage, _ = GetLabelsForMessages(account.ID, []string{"a@example.com"})
+	if len(byMessage["a@example.com"]) != 0 {
+		t.Errorf("Expected no labels on a@example.com, got %v", byMessage["a@example.com"])
+	}
+}
@@ -69,6 +69,8 @@ type Folder struct {
 	TotalMessages  int          `db:"total_messages"`
 	UnreadMessages int          `db:"unread_messages"`
 	LastSync       sql.NullTime `db:"last_sync"`
+	UIDValidity    uint32       `db:"uid_validity"`
+	HighestModSeq  uint64       `db:"highest_modseq"`
 }
 
 type Email struct {
@@ -106,6 +108,8 @@ type Email struct {
 // EmailSummary é uma versão resumida para listagem
 type EmailSummary struct {
 	ID             int64          `db:"id"`
+	AccountID      int64          `db:"account_id"`
+	AccountEmail   string         `db:"account_email"` // só na caixa unificada
 	UID            uint32         `db:"uid"`
 	MessageID      sql.NullString `db:"message_id"`
 	Subject        string         `db:"subject"`
@@ -120,6 +124,9 @@ type EmailSummary struct {
 	Snippet        string         `db:"snippet"`
 	ThreadID       sql.NullString `db:"thread_id"`
 	ThreadCount    int            `db:"thread_count"` // Number of emails in thread (for grouped view)
+	FolderName     string         `db:"folder_name"`  // só nas visões por label
+	Labels         []string       `db:"-"`            // labels do Gmail (GetLabelsForMessages)
+	RiskLevel      string         `db:"-"`            // nível de risco (GetRiskForEmails)
 }
 
 // DraftStatus representa o estado de um draft
@@ -128,6 +135,8 @@ type DraftStatus string
 const (
 	DraftStatusDraft     DraftStatus = "draft"     // Aguardando aprovação (AI drafts)
 	DraftStatusScheduled DraftStatus = "scheduled" // Aprovado, aguardando delay para envio
+	DraftStatusRecurring DraftStatus = "recurring" // Modelo de envio recorrente (clonado a cada ocorrência)
+	DraftStatusQueued    DraftStatus = "queued"    // Na outbox, aguardando conexão ou nova tentativa
 	DraftStatusSending   DraftStatus = "sending"   // Em processo de envio
 	DraftStatusSent      DraftStatus = "sent"      // Enviado com sucesso
 	DraftStatusCancelled DraftStatus = "cancelled" // Cancelado pelo usuário
@@ -332,11 +341,12 @@ type AttachmentSummary struct {
 
 // === CONTACTS ===
 
-// Contact representa um contato sincronizado do Google People API
+// Contact representa um contato sincronizado do Google People API ou de
+// um servidor CardDAV
 type Contact struct {
 	ID                  int64          `db:"id"`
 	AccountID           int64          `db:"account_id"`
-	ResourceName        string         `db:"resource_name"` // people/c1234567890
+	ResourceName        string         `db:"resource_name"` // people/c1234567890, ou o href do vCard (CardDAV)
 	DisplayName         string         `db:"display_name"`
 	GivenName           sql.NullString `db:"given_name"`
 	FamilyName          sql.NullString `db:"family_name"`
@@ -347,6 +357,10 @@ type Contact struct {
 	InteractionCount    int            `db:"interaction_count"`
 	LastInteractionAt   sql.NullTime   `db:"last_interaction_at"`
 	MetadataJSON        sql.NullString `db:"metadata_json"`
+	TimeZone            sql.NullString `db:"time_zone"` // IANA, ex: America/New_York
+	CardDAVETag         sql.NullString `db:"carddav_etag"`
+	VCardData           sql.NullString `db:"vcard_data"`   // vCard do servidor CardDAV
+	PendingSync         bool           `db:"pending_sync"` // alterado localmente desde o último sync
 	SyncedAt            sql.NullTime   `db:"synced_at"`
 	CreatedAt           SQLiteTime     `db:"created_at"`
 	UpdatedAt           SQLiteTime     `db:"updated_at"`
@@ -386,6 +400,7 @@ type ContactInteraction struct {
 type ContactsSyncState struct {
 	ID                   int64          `db:"id"`
 	AccountID            int64          `db:"account_id"`
+	Source               string         `db:"source"` // google, carddav
 	LastSyncToken        sql.NullString `db:"last_sync_token"`
 	LastFullSync         sql.NullTime   `db:"last_full_sync"`
 	LastIncrementalSync  sql.NullTime   `db:"last_incremental_sync"`
@@ -428,6 +443,7 @@ type TaskSource string
 const (
 	TaskSourceManual       TaskSource = "manual"
 	TaskSourceAISuggestion TaskSource = "ai_suggestion"
+	TaskSourceRule         TaskSource = "rule"
 )
 
 // Task representa uma tarefa do usuário
@@ -462,6 +478,8 @@ const (
 	CalendarEventSourceManual       CalendarEventSource = "manual"
 	CalendarEventSourceTaskSync     CalendarEventSource = "task_sync"
 	CalendarEventSourceAISuggestion CalendarEventSource = "ai_suggestion"
+	CalendarEventSourceEmailInvite  CalendarEventSource = "email_invite"
+	CalendarEventSourceCalDAV       CalendarEventSource = "caldav"
 )
 
 // CalendarSyncStatus represents sync status with Google Calendar
@@ -493,6 +511,16 @@ type CalendarEvent struct {
 	GoogleCalendarID sql.NullString     `db:"google_calendar_id"`
 	LastSyncedAt     sql.NullTime       `db:"last_synced_at"`
 	SyncStatus       CalendarSyncStatus `db:"sync_status"`
+	Location         sql.NullString     `db:"location"`
+	ICalUID          sql.NullString     `db:"ical_uid"`
+	ICalRecurrenceID string             `db:"ical_recurrence_id"` // exceção de uma série ("" = a série)
+	ICalSequence     int                `db:"ical_sequence"`
+	ICalData         sql.NullString     `db:"ical_data"`     // VCALENDAR recebido, usado para responder
+	RSVPStatus       sql.NullString     `db:"rsvp_status"`   // resposta do usuário (PARTSTAT)
+	CalDAVHref       sql.NullString     `db:"caldav_href"`   // objeto no servidor CalDAV
+	CalDAVETag       sql.NullString     `db:"caldav_etag"`   // ETag da última versão sincronizada
+	ConflictETag     sql.NullString     `db:"conflict_etag"` // versão do servidor em conflito
+	ConflictData     sql.NullString     `db:"conflict_data"` // iCalendar da versão do servidor (NULL = apagado lá)
 	CreatedAt        SQLiteTime         `db:"created_at"`
 	UpdatedAt        SQLiteTime         `db:"updated_at"`
 }
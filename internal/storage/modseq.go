@@ -0,0 +1,76 @@
+package storage
+
+// FlagUpdate é o estado das flags de um email no servidor (delta sync)
+type FlagUpdate struct {
+	UID      uint32
+	Seen     bool
+	Flagged  bool
+	Answered bool
+	Deleted  bool
+}
+
+// UpdateFolderModSeq salva o HIGHESTMODSEQ já sincronizado da pasta (CONDSTORE)
+func UpdateFolderModSeq(folderID int64, modSeq uint64) error {
+	var _, err = db.Exec("UPDATE folders SET highest_modseq = ? WHERE id = ?", modSeq, folderID)
+	return err
+}
+
+// GetRecentUIDs retorna os UIDs dos N emails mais recentes da pasta
+// (usado no refresh limitado de flags em servidores sem CONDSTORE)
+func GetRecentUIDs(accountID, folderID int64, limit int) ([]uint32, error) {
+	var uids []uint32
+	var err = db.Select(&uids, `
+		SELECT uid FROM emails
+		WHERE account_id = ? AND folder_id = ? AND is_deleted = 0 AND uid < ?
+		ORDER BY uid DESC
+		LIMIT ?`,
+		accountID, folderID, orphanUIDFloor, limit)
+	return uids, err
+}
+
+// ApplyFlagUpdates aplica as flags do servidor nos emails locais.
+// \Deleted no servidor marca o email como deletado, mas a falta dele nunca
+// desfaz uma exclusão local que o servidor ainda não aplicou.
+// Retorna quantos emails realmente mudaram.
+func ApplyFlagUpdates(accountID, folderID int64, updates []FlagUpdate) (int, error) {
+	if len(updates) == 0 {
+		return 0, nil
+	}
+
+	var tx, err = db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Só toca linhas com alguma diferença, para contar mudanças reais
+	var stmt, prepErr = tx.Preparex(`
+		UPDATE emails
+		SET is_read = ?, is_starred = ?, is_replied = ?,
+		    is_deleted = CASE WHEN ? THEN 1 ELSE is_deleted END, updated_at = CURRENT_TIMESTAMP
+		WHERE account_id = ? AND folder_id = ? AND uid = ?
+		  AND (is_read != ? OR is_starred != ? OR COALESCE(is_replied, 0) != ? OR (? AND is_deleted = 0))`)
+	if prepErr != nil {
+		return 0, prepErr
+	}
+	defer stmt.Close()
+
+	var changed = 0
+	for _, u := range updates {
+		var result, execErr = stmt.Exec(
+			u.Seen, u.Flagged, u.Answered, u.Deleted,
+			accountID, folderID, u.UID,
+			u.Seen, u.Flagged, u.Answered, u.Deleted)
+		if execErr != nil {
+			return 0, execErr
+		}
+		var affected, _ = result.RowsAffected()
+		changed += int(affected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestApplyFlagUpdates tests that server flags are applied and only real changes are counted
+func TestApplyFlagUpdates(t *testing.T) {
+	var tmpDir = t.TempDir()
+	var dbPath = filepath.Join(tmpDir, "test.db")
+
+	if err := Init(dbPath); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, err = GetOrCreateAccount("test@example.com", "Test User")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	var folder, err2 = GetOrCreateFolder(account.ID, "INBOX")
+	if err2 != nil {
+		t.Fatalf("Failed to create folder: %v", err2)
+	}
+
+	// UIDs 1..3, all unread and not starred
+	for uid := uint32(1); uid <= 3; uid++ {
+		var email = Email{
+			AccountID: account.ID,
+			FolderID:  folder.ID,
+			UID:       uid,
+			Subject:   "Test email",
+			FromEmail: "sender@example.com",
+			Date:      SQLiteTime{time.Now()},
+		}
+		if _, _, err := UpsertEmail(&email); err != nil {
+			t.Fatalf("Failed to insert email UID %d: %v", uid, err)
+		}
+	}
+
+	var updates = []FlagUpdate{
+		{UID: 1, Seen: true},                // read on the phone
+		{UID: 2, Flagged: true, Seen: true}, // starred in Gmail web
+		{UID: 3},                            // unchanged
+		{UID: 99, Seen: true},               // not synced locally, ignored
+	}
+
+	var changed, err3 = ApplyFlagUpdates(account.ID, folder.ID, updates)
+	if err3 != nil {
+		t.Fatalf("ApplyFlagUpdates failed: %v", err3)
+	}
+	if changed != 2 {
+		t.Errorf("Expected 2 changed emails, got %d", changed)
+	}
+
+	var email, _ = GetEmailByUID(account.ID, folder.ID, 2)
+	if email == nil || !email.IsRead || !email.IsStarred {
+		t.Errorf("Expected UID 2 to be read and starred, got %+v", email)
+	}
+
+	// Applying the same state again changes nothing
+	changed, _ = ApplyFlagUpdates(account.ID, folder.ID, updates)
+	if changed != 0 {
+		t.Errorf("Expected 0 changed emails on second apply, got %d", changed)
+	}
+
+	// Recent UIDs come newest first and respect the limit
+	var uids, _ = GetRecentUIDs(account.ID, folder.ID, 2)
+	if len(uids) != 2 || uids[0] != 3 || uids[1] != 2 {
+		t.Errorf("Expected recent UIDs [3 2], got %v", uids)
+	}
+
+	// \Deleted on the server deletes locally, but its absence never undoes a
+	// local delete the server hasn't applied yet
+	var deleted, _ = GetEmailByUID(account.ID, folder.ID, 3)
+	if err := DeleteEmail(deleted.ID); err != nil {
+		t.Fatalf("DeleteEmail failed: %v", err)
+	}
+	changed, _ = ApplyFlagUpdates(account.ID, folder.ID, []FlagUpdate{{UID: 1, Seen: true, Deleted: true}, {UID: 3}})
+	if changed != 1 {
+		t.Errorf("Expected 1 changed email, got %d", changed)
+	}
+	for _, uid := range []uint32{1, 3} {
+		if email, _ := GetEmailByUID(account.ID, folder.ID, uid); email == nil || !email.IsDeleted {
+			t.Errorf("Expected UID %d to be deleted, got %+v", uid, email)
+		}
+	}
+}
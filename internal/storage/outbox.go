@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// === OUTBOX (envios pendentes: janela de desfazer envio ou falta de conexão) ===
+
+// OutboxEntry é um email na outbox, com os dados do draft que o acompanha.
+// NextAttemptAt zero significa "assim que possível".
+type OutboxEntry struct {
+	DraftID       int64          `db:"draft_id"`
+	AccountID     int64          `db:"account_id"`
+	Request       string         `db:"request"`
+	Attempts      int            `db:"attempts"`
+	NextAttemptAt SQLiteTime     `db:"next_attempt_at"`
+	LastError     sql.NullString `db:"last_error"`
+	CreatedAt     SQLiteTime     `db:"created_at"`
+	Status        DraftStatus    `db:"status"`
+	ToAddresses   string         `db:"to_addresses"`
+	Subject       string         `db:"subject"`
+}
+
+const outboxSelect = `
+	SELECT o.draft_id, o.account_id, o.request, o.attempts, o.next_attempt_at,
+		o.last_error, o.created_at, d.status, d.to_addresses, d.subject
+	FROM outbox o
+	JOIN drafts d ON d.id = o.draft_id`
+
+// QueueDraft coloca um draft na outbox (ou reinicia suas tentativas, se já
+// estiver lá) e marca o draft como queued. O email só sai a partir de sendAt
+// (zero = assim que possível).
+func QueueDraft(draftID, accountID int64, request string, sendAt time.Time) error {
+	var tx, err = db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO outbox (draft_id, account_id, request, next_attempt_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(draft_id) DO UPDATE SET
+			request = excluded.request,
+			attempts = 0,
+			next_attempt_at = excluded.next_attempt_at,
+			last_error = NULL`,
+		draftID, accountID, request, SQLiteTime{sendAt.UTC()}); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE drafts SET
+			status = 'queued',
+			error_message = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, draftID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetOutbox retorna toda a outbox, na ordem de envio de cada conta
+func GetOutbox() ([]OutboxEntry, error) {
+	var entries []OutboxEntry
+	var err = db.Select(&entries, outboxSelect+`
+		ORDER BY o.account_id, o.created_at, o.draft_id`)
+	return entries, err
+}
+
+// GetOutboxEntry busca um email da outbox (nil se não estiver lá)
+func GetOutboxEntry(draftID int64) (*OutboxEntry, error) {
+	var entry OutboxEntry
+	var err = db.Get(&entry, outboxSelect+" WHERE o.draft_id = ?", draftID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RecordOutboxFailure registra uma tentativa de envio que falhou
+func RecordOutboxFailure(draftID int64, attempts int, nextAttempt time.Time, lastError string) error {
+	var _, err = db.Exec(`
+		UPDATE outbox SET
+			attempts = ?,
+			next_attempt_at = ?,
+			last_error = ?
+		WHERE draft_id = ?`,
+		attempts, SQLiteTime{nextAttempt.UTC()}, lastError, draftID)
+	return err
+}
+
+// ResetOutboxEntry zera as tentativas para o email sair na próxima rodada
+func ResetOutboxEntry(draftID int64) error {
+	var _, err = db.Exec(`
+		UPDATE outbox SET
+			attempts = 0,
+			next_attempt_at = NULL,
+			last_error = NULL
+		WHERE draft_id = ?`, draftID)
+	if err != nil {
+		return err
+	}
+	return MarkDraftQueued(draftID)
+}
+
+// RemoveFromOutbox tira um email da outbox (o draft continua existindo)
+func RemoveFromOutbox(draftID int64) error {
+	var _, err = db.Exec("DELETE FROM outbox WHERE draft_id = ?", draftID)
+	return err
+}
+
+// CancelOutboxEntry tira da outbox um email que ainda não está sendo
+// enviado e o volta para draft. Retorna false se o envio já começou (ou o
+// email não está na outbox).
+func CancelOutboxEntry(draftID int64) (bool, error) {
+	var tx, err = db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var result, errUpdate = tx.Exec(`
+		UPDATE drafts SET
+			status = 'draft',
+			scheduled_send_at = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN ('queued', 'failed')
+			AND id IN (SELECT draft_id FROM outbox)`, draftID)
+	if errUpdate != nil {
+		return false, errUpdate
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec("DELETE FROM outbox WHERE draft_id = ?", draftID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ClaimOutboxEntry marca um email da outbox como sending, se ainda estiver
+// queued. Retorna false se outro processo (ou um cancelamento) chegou antes.
+func ClaimOutboxEntry(draftID int64) (bool, error) {
+	var result, err = db.Exec(`
+		UPDATE drafts SET
+			status = 'sending',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'queued'
+			AND id IN (SELECT draft_id FROM outbox)`, draftID)
+	if err != nil {
+		return false, err
+	}
+	var n, _ = result.RowsAffected()
+	return n > 0, nil
+}
+
+// RequeueStuckOutbox volta para queued os emails que ficaram em sending desde
+// antes de since (app fechado no meio do envio)
+func RequeueStuckOutbox(since time.Time) (int64, error) {
+	var result, err = db.Exec(`
+		UPDATE drafts SET
+			status = 'queued',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE status = 'sending' AND updated_at < ?
+			AND id IN (SELECT draft_id FROM outbox)`,
+		SQLiteTime{since.UTC()})
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MarkDraftQueued marca draft como aguardando na outbox
+func MarkDraftQueued(id int64) error {
+	_, err := db.Exec(`
+		UPDATE drafts SET
+			status = 'queued',
+			error_message = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, id)
+	return err
+}
+
+// UpdateQueuedDraft edita destinatários, assunto e corpo de um email da
+// outbox. Só vale para emails que não estão sendo enviados; retorna false
+// caso contrário.
+func UpdateQueuedDraft(d *Draft) (bool, error) {
+	var result, err = db.Exec(`
+		UPDATE drafts SET
+			to_addresses = ?, cc_addresses = ?, bcc_addresses = ?,
+			subject = ?, body_html = ?, body_text = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN ('queued', 'failed')
+			AND id IN (SELECT draft_id FROM outbox)`,
+		d.ToAddresses, d.CcAddresses, d.BccAddresses,
+		d.Subject, d.BodyHTML, d.BodyText,
+		d.ID)
+	if err != nil {
+		return false, err
+	}
+	var n, _ = result.RowsAffected()
+	return n > 0, nil
+}
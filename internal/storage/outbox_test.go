@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestOutbox tests queueing drafts, recording failures and editing queued email
+func TestOutbox(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, _ = GetOrCreateAccount("me@example.org", "Me")
+	var other, _ = GetOrCreateAccount("other@example.org", "Other")
+
+	var create = func(accountID int64, subject string) int64 {
+		var id, err = CreateDraft(&Draft{
+			AccountID:        accountID,
+			ToAddresses:      "bob@example.com",
+			Subject:          subject,
+			Status:           DraftStatusDraft,
+			GenerationSource: "manual",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create draft: %v", err)
+		}
+		if err := QueueDraft(id, accountID, `{"sign":true}`, time.Time{}); err != nil {
+			t.Fatalf("Failed to queue draft: %v", err)
+		}
+		return id
+	}
+	var first = create(other.ID, "first")
+	var second = create(account.ID, "second")
+	var third = create(account.ID, "third")
+
+	var entries, err = GetOutbox()
+	if err != nil {
+		t.Fatalf("GetOutbox failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	if entries[0].DraftID != second || entries[1].DraftID != third || entries[2].DraftID != first {
+		t.Errorf("Expected entries ordered by account and queue time, got %d, %d, %d",
+			entries[0].DraftID, entries[1].DraftID, entries[2].DraftID)
+	}
+	if entries[0].Status != DraftStatusQueued || entries[0].Request != `{"sign":true}` || !entries[0].NextAttemptAt.IsZero() {
+		t.Errorf("Unexpected queued entry: %+v", entries[0])
+	}
+
+	var next = time.Now().Add(time.Minute).Truncate(time.Second)
+	if err := RecordOutboxFailure(second, 2, next, "connection refused"); err != nil {
+		t.Fatalf("RecordOutboxFailure failed: %v", err)
+	}
+	var entry, _ = GetOutboxEntry(second)
+	if entry.Attempts != 2 || entry.LastError.String != "connection refused" || !entry.NextAttemptAt.Equal(next) {
+		t.Errorf("Failure not recorded: %+v", entry)
+	}
+
+	// Emails being sent can't be claimed twice nor edited; stuck ones go back
+	// to the queue
+	if claimed, err := ClaimOutboxEntry(second); err != nil || !claimed {
+		t.Fatalf("ClaimOutboxEntry failed: %v", err)
+	}
+	if claimed, _ := ClaimOutboxEntry(second); claimed {
+		t.Error("Expected an email being sent not to be claimed again")
+	}
+	var updated, _ = UpdateQueuedDraft(&Draft{ID: second, ToAddresses: "carol@example.com", Subject: "edited"})
+	if updated {
+		t.Error("Expected an email being sent not to be editable")
+	}
+	if n, _ := RequeueStuckOutbox(time.Now().Add(-time.Hour)); n != 0 {
+		t.Errorf("Expected a recent send not to be requeued, got %d", n)
+	}
+	if n, _ := RequeueStuckOutbox(time.Now().Add(time.Minute)); n != 1 {
+		t.Errorf("Expected 1 stuck email requeued, got %d", n)
+	}
+
+	updated, err = UpdateQueuedDraft(&Draft{
+		ID:          second,
+		ToAddresses: "carol@example.com",
+		Subject:     "edited",
+		BodyText:    sql.NullString{String: "hi", Valid: true},
+	})
+	if err != nil || !updated {
+		t.Fatalf("UpdateQueuedDraft failed: %v", err)
+	}
+	if err := ResetOutboxEntry(second); err != nil {
+		t.Fatalf("ResetOutboxEntry failed: %v", err)
+	}
+	entry, _ = GetOutboxEntry(second)
+	if entry.Attempts != 0 || entry.LastError.Valid || entry.ToAddresses != "carol@example.com" || entry.Status != DraftStatusQueued {
+		t.Errorf("Entry not reset: %+v", entry)
+	}
+
+	// Removing keeps the draft
+	RemoveFromOutbox(third)
+	if entry, _ := GetOutboxEntry(third); entry != nil {
+		t.Error("Expected entry to be removed from the outbox")
+	}
+	if draft, err := GetDraftByID(third); err != nil || draft.Subject != "third" {
+		t.Errorf("Expected draft to survive removal: %v", err)
+	}
+
+	// Deleting the draft drops it from the outbox
+	DeleteDraft(first)
+	if entry, _ := GetOutboxEntry(first); entry != nil {
+		t.Error("Expected outbox entry to be deleted with its draft")
+	}
+
+	// Held emails (undo send) wait until sendAt and can be cancelled until
+	// their send starts
+	var sendAt = time.Now().Add(30 * time.Second).Truncate(time.Second)
+	if err := QueueDraft(third, account.ID, "{}", sendAt); err != nil {
+		t.Fatalf("Failed to hold draft: %v", err)
+	}
+	entry, _ = GetOutboxEntry(third)
+	if entry == nil || !entry.NextAttemptAt.Equal(sendAt) {
+		t.Errorf("Expected held email to wait until %v: %+v", sendAt, entry)
+	}
+	if cancelled, err := CancelOutboxEntry(third); err != nil || !cancelled {
+		t.Fatalf("CancelOutboxEntry failed: %v", err)
+	}
+	if draft, _ := GetDraftByID(third); draft.Status != DraftStatusDraft {
+		t.Errorf("Expected cancelled email to be a draft again, got %s", draft.Status)
+	}
+	ClaimOutboxEntry(second)
+	if cancelled, _ := CancelOutboxEntry(second); cancelled {
+		t.Error("Expected an email being sent not to be cancelled")
+	}
+}
@@ -38,7 +38,7 @@ func TestPurgeDeletedFromServer(t *testing.T) {
 			Date:      SQLiteTime{time.Now()},
 			IsDeleted: false,
 		}
-		if err := UpsertEmail(&email); err != nil {
+		if _, _, err := UpsertEmail(&email); err != nil {
 			t.Fatalf("Failed to insert email UID %d: %v", uid, err)
 		}
 	}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// === ENVIOS RECORRENTES ===
+
+// RecurringSchedule é um envio recorrente: a cada ocorrência da regra (RRULE)
+// o draft modelo é clonado e o clone é agendado. A regra é avaliada no fuso
+// TimeZone (vazio = fuso local). NextRunAt zero significa que a regra acabou.
+type RecurringSchedule struct {
+	ID          int64      `db:"id"`
+	AccountID   int64      `db:"account_id"`
+	DraftID     int64      `db:"draft_id"`
+	Rule        string     `db:"rule"`
+	TimeZone    string     `db:"time_zone"`
+	StartsAt    SQLiteTime `db:"starts_at"`
+	NextRunAt   SQLiteTime `db:"next_run_at"`
+	LastRunAt   SQLiteTime `db:"last_run_at"`
+	Occurrences int        `db:"occurrences"`
+	CreatedAt   SQLiteTime `db:"created_at"`
+	ToAddresses string     `db:"to_addresses"`
+	Subject     string     `db:"subject"`
+}
+
+const recurringSelect = `
+	SELECT r.id, r.account_id, r.draft_id, r.rule, r.time_zone, r.starts_at,
+		r.next_run_at, r.last_run_at, r.occurrences, r.created_at,
+		d.to_addresses, d.subject
+	FROM recurring_schedules r
+	JOIN drafts d ON d.id = r.draft_id`
+
+// CreateRecurringSchedule cria um envio recorrente e marca o draft modelo
+// como recurring (ele sai da lista de drafts pendentes)
+func CreateRecurringSchedule(r *RecurringSchedule) (int64, error) {
+	var tx, err = db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var result, errInsert = tx.Exec(`
+		INSERT INTO recurring_schedules (account_id, draft_id, rule, time_zone, starts_at, next_run_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		r.AccountID, r.DraftID, r.Rule, r.TimeZone,
+		SQLiteTime{r.StartsAt.UTC()}, SQLiteTime{r.NextRunAt.UTC()})
+	if errInsert != nil {
+		return 0, errInsert
+	}
+	if _, err := tx.Exec(`
+		UPDATE drafts SET
+			status = 'recurring',
+			scheduled_send_at = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, r.DraftID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetRecurringSchedules busca os envios recorrentes de uma conta, pela
+// próxima ocorrência (os já encerrados por último)
+func GetRecurringSchedules(accountID int64) ([]RecurringSchedule, error) {
+	var list []RecurringSchedule
+	var err = db.Select(&list, recurringSelect+`
+		WHERE r.account_id = ?
+		ORDER BY r.next_run_at IS NULL, r.next_run_at, r.id`, accountID)
+	return list, err
+}
+
+// GetRecurringSchedule busca um envio recorrente (nil se não existir)
+func GetRecurringSchedule(id int64) (*RecurringSchedule, error) {
+	var r RecurringSchedule
+	var err = db.Get(&r, recurringSelect+" WHERE r.id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetDueRecurringSchedules busca os envios recorrentes com ocorrência até now
+func GetDueRecurringSchedules(now time.Time) ([]RecurringSchedule, error) {
+	var list []RecurringSchedule
+	var err = db.Select(&list, recurringSelect+`
+		WHERE r.next_run_at IS NOT NULL AND r.next_run_at <= ?
+		ORDER BY r.next_run_at`, SQLiteTime{now.UTC()})
+	return list, err
+}
+
+// NextRecurringRun retorna a próxima ocorrência entre todos os envios
+// recorrentes (zero se não houver)
+func NextRecurringRun() (time.Time, error) {
+	var next SQLiteTime
+	var err = db.Get(&next, "SELECT MIN(next_run_at) FROM recurring_schedules")
+	return next.Time, err
+}
+
+// AddRecurringOccurrence clona o draft modelo num draft agendado para sendAt
+// e avança o envio recorrente para next (zero = regra encerrada). Só vale se
+// a ocorrência pendente ainda for due; retorna 0 se outro processo já a
+// criou.
+func AddRecurringOccurrence(scheduleID int64, due, sendAt, next time.Time) (int64, error) {
+	var tx, err = db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var result, errUpdate = tx.Exec(`
+		UPDATE recurring_schedules SET
+			next_run_at = ?,
+			last_run_at = ?,
+			occurrences = occurrences + 1
+		WHERE id = ? AND next_run_at = ?`,
+		SQLiteTime{next.UTC()}, SQLiteTime{sendAt.UTC()}, scheduleID, SQLiteTime{due.UTC()})
+	if errUpdate != nil {
+		return 0, errUpdate
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, nil
+	}
+
+	var clone, errClone = tx.Exec(`
+		INSERT INTO drafts (
+			account_id, to_addresses, cc_addresses, bcc_addresses,
+			subject, body_html, body_text, classification,
+			in_reply_to, reference_ids, reply_to_email_id,
+			status, scheduled_send_at, generation_source, ai_prompt
+		)
+		SELECT
+			account_id, to_addresses, cc_addresses, bcc_addresses,
+			subject, body_html, body_text, classification,
+			in_reply_to, reference_ids, reply_to_email_id,
+			'scheduled', ?, generation_source, ai_prompt
+		FROM drafts
+		WHERE id = (SELECT draft_id FROM recurring_schedules WHERE id = ?)`,
+		SQLiteTime{sendAt.UTC()}, scheduleID)
+	if errClone != nil {
+		return 0, errClone
+	}
+	var draftID, errID = clone.LastInsertId()
+	if errID != nil {
+		return 0, errID
+	}
+	return draftID, tx.Commit()
+}
+
+// DeleteRecurringSchedule encerra um envio recorrente e volta o draft modelo
+// para draft. Os clones já agendados continuam agendados.
+func DeleteRecurringSchedule(id int64) error {
+	var tx, err = db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE drafts SET
+			status = 'draft',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = (SELECT draft_id FROM recurring_schedules WHERE id = ?)`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recurring_schedules WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
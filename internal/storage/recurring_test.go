@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRecurringSchedules tests creating occurrences of a recurring send from its template draft
+func TestRecurringSchedules(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, _ = GetOrCreateAccount("me@example.org", "Me")
+	var templateID, err = CreateDraft(&Draft{
+		AccountID:        account.ID,
+		ToAddresses:      "team@example.com",
+		Subject:          "Weekly status",
+		Status:           DraftStatusDraft,
+		GenerationSource: "manual",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create draft: %v", err)
+	}
+
+	var monday = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	var id, errCreate = CreateRecurringSchedule(&RecurringSchedule{
+		AccountID: account.ID,
+		DraftID:   templateID,
+		Rule:      "FREQ=WEEKLY;BYDAY=MO;BYHOUR=12;BYMINUTE=0",
+		TimeZone:  "Europe/Lisbon",
+		StartsAt:  SQLiteTime{monday},
+		NextRunAt: SQLiteTime{monday},
+	})
+	if errCreate != nil {
+		t.Fatalf("Failed to create recurring send: %v", errCreate)
+	}
+
+	// The template leaves the pending drafts
+	var template, _ = GetDraftByID(templateID)
+	if template.Status != DraftStatusRecurring {
+		t.Errorf("Expected template status recurring, got %s", template.Status)
+	}
+	if pending, _ := CountPendingDrafts(account.ID); pending != 0 {
+		t.Errorf("Expected no pending drafts, got %d", pending)
+	}
+
+	var list, _ = GetRecurringSchedules(account.ID)
+	if len(list) != 1 || list[0].Subject != "Weekly status" || list[0].TimeZone != "Europe/Lisbon" {
+		t.Fatalf("Unexpected recurring sends: %+v", list)
+	}
+	if next, _ := NextRecurringRun(); !next.Equal(monday) {
+		t.Errorf("Expected next run %v, got %v", monday, next)
+	}
+
+	// Not due before its time
+	if due, _ := GetDueRecurringSchedules(monday.Add(-time.Minute)); len(due) != 0 {
+		t.Errorf("Expected nothing due, got %d", len(due))
+	}
+	var due, _ = GetDueRecurringSchedules(monday)
+	if len(due) != 1 {
+		t.Fatalf("Expected 1 due recurring send, got %d", len(due))
+	}
+
+	// The occurrence is a scheduled copy of the template
+	var next = monday.AddDate(0, 0, 7)
+	var cloneID, errAdd = AddRecurringOccurrence(id, due[0].NextRunAt.Time, monday, next)
+	if errAdd != nil || cloneID == 0 {
+		t.Fatalf("Failed to add occurrence: %d, %v", cloneID, errAdd)
+	}
+	var clone, _ = GetDraftByID(cloneID)
+	if clone.Status != DraftStatusScheduled || clone.Subject != "Weekly status" || clone.ToAddresses != "team@example.com" {
+		t.Errorf("Unexpected clone: %+v", clone)
+	}
+	if !clone.ScheduledSendAt.Valid || !clone.ScheduledSendAt.Time.Equal(monday) {
+		t.Errorf("Expected clone scheduled at %v, got %v", monday, clone.ScheduledSendAt)
+	}
+
+	// The same occurrence is only created once
+	if again, _ := AddRecurringOccurrence(id, due[0].NextRunAt.Time, monday, next); again != 0 {
+		t.Errorf("Expected occurrence to be created once, got draft %d", again)
+	}
+	var item, _ = GetRecurringSchedule(id)
+	if item.Occurrences != 1 || !item.NextRunAt.Equal(next) || !item.LastRunAt.Equal(monday) {
+		t.Errorf("Unexpected recurring send after occurrence: %+v", item)
+	}
+
+	// Sending claims the clone once
+	if claimed, _ := ClaimScheduledDraft(cloneID); !claimed {
+		t.Error("Expected to claim the scheduled clone")
+	}
+	if claimed, _ := ClaimScheduledDraft(cloneID); claimed {
+		t.Error("Expected the clone to be claimed only once")
+	}
+
+	// Cancelling gives the template back as a draft
+	if err := DeleteRecurringSchedule(id); err != nil {
+		t.Fatalf("Failed to delete recurring send: %v", err)
+	}
+	template, _ = GetDraftByID(templateID)
+	if template.Status != DraftStatusDraft {
+		t.Errorf("Expected template back to draft, got %s", template.Status)
+	}
+	if item, _ := GetRecurringSchedule(id); item != nil {
+		t.Errorf("Expected recurring send to be deleted, got %+v", item)
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// === UIDs LOCAIS PARA BACKENDS SEM IMAP ===
+// A API do Gmail e o JMAP identificam mensagens por IDs opacos, sem UIDs. Os
+// backends de sync dão a cada mensagem um UID crescente por pasta (label ou
+// mailbox), na ordem em que ela aparece lá, como um servidor IMAP faria.
+
+// remoteUIDTable descreve uma tabela de UIDs locais: account_id, a coluna da
+// pasta, uid e a coluna do ID remoto
+type remoteUIDTable struct {
+	name      string
+	boxCol    string
+	remoteCol string
+}
+
+var (
+	gmailUIDTable = remoteUIDTable{name: "gmail_sync_uids", boxCol: "label_id", remoteCol: "gmail_id"}
+	jmapUIDTable  = remoteUIDTable{name: "jmap_sync_uids", boxCol: "mailbox_id", remoteCol: "email_id"}
+)
+
+// remoteUID é uma linha de uma tabela de UIDs locais
+type remoteUID struct {
+	UID      uint32 `db:"uid"`
+	RemoteID string `db:"remote_id"`
+}
+
+// assign retorna o UID de cada mensagem na pasta, dando os próximos UIDs (na
+// ordem recebida) às que ainda não têm
+func (t remoteUIDTable) assign(accountID int64, box string, remoteIDs []string) (map[string]uint32, error) {
+	var result = make(map[string]uint32)
+	if len(remoteIDs) == 0 {
+		return result, nil
+	}
+
+	var tx, err = db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var next uint32
+	var query = fmt.Sprintf("SELECT COALESCE(MAX(uid), 0) + 1 FROM %s WHERE account_id = ? AND %s = ?", t.name, t.boxCol)
+	if err := tx.Get(&next, query, accountID, box); err != nil {
+		return nil, err
+	}
+
+	var lookup = fmt.Sprintf("SELECT uid FROM %s WHERE account_id = ? AND %s = ? AND %s = ?", t.name, t.boxCol, t.remoteCol)
+	var insert = fmt.Sprintf("INSERT INTO %s (account_id, %s, uid, %s) VALUES (?, ?, ?, ?)", t.name, t.boxCol, t.remoteCol)
+	for _, id := range remoteIDs {
+		if _, ok := result[id]; ok {
+			continue
+		}
+
+		var uids []uint32
+		if err := tx.Select(&uids, lookup, accountID, box, id); err != nil {
+			return nil, err
+		}
+		if len(uids) > 0 {
+			result[id] = uids[0]
+			continue
+		}
+
+		if _, err := tx.Exec(insert, accountID, box, next, id); err != nil {
+			return nil, err
+		}
+		result[id] = next
+		next++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// lookup retorna os UIDs já dados às mensagens na pasta (sem criar novos)
+func (t remoteUIDTable) lookup(accountID int64, box string, remoteIDs []string) (map[string]uint32, error) {
+	var result = make(map[string]uint32)
+	if len(remoteIDs) == 0 {
+		return result, nil
+	}
+
+	var args = []interface{}{accountID, box}
+	for _, id := range remoteIDs {
+		args = append(args, id)
+	}
+	var rows, err = t.selectRows(fmt.Sprintf("%s IN (%s)", t.remoteCol, placeholders(len(remoteIDs))), args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.RemoteID] = row.UID
+	}
+	return result, nil
+}
+
+// remoteIDs retorna o ID remoto de cada UID da pasta
+func (t remoteUIDTable) remoteIDs(accountID int64, box string, uids []uint32) (map[uint32]string, error) {
+	var result = make(map[uint32]string)
+	if len(uids) == 0 {
+		return result, nil
+	}
+
+	var args = []interface{}{accountID, box}
+	for _, uid := range uids {
+		args = append(args, uid)
+	}
+	var rows, err = t.selectRows(fmt.Sprintf("uid IN (%s)", placeholders(len(uids))), args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.UID] = row.RemoteID
+	}
+	return result, nil
+}
+
+// remoteIDsAfter retorna UID -> ID remoto das mensagens da pasta com UID
+// maior que uid (0 retorna todas)
+func (t remoteUIDTable) remoteIDsAfter(accountID int64, box string, uid uint32) (map[uint32]string, error) {
+	var rows, err = t.selectRows("uid > ?", accountID, box, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make(map[uint32]string, len(rows))
+	for _, row := range rows {
+		result[row.UID] = row.RemoteID
+	}
+	return result, nil
+}
+
+// selectRows busca as linhas de uma pasta que satisfazem a condição (args
+// começam por account_id e a pasta)
+func (t remoteUIDTable) selectRows(condition string, args ...interface{}) ([]remoteUID, error) {
+	var rows []remoteUID
+	var err = db.Select(&rows, fmt.Sprintf(`
+		SELECT uid, %s AS remote_id FROM %s
+		WHERE account_id = ? AND %s = ? AND %s`,
+		t.remoteCol, t.name, t.boxCol, condition),
+		args...)
+	return rows, err
+}
+
+// placeholders retorna n "?" separados por vírgula
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
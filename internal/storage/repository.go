@@ -39,6 +39,15 @@ func GetOrCreateAccount(email, name string) (*Account, error) {
 	return &account, nil
 }
 
+// GetAccountByID retorna uma conta pelo ID
+func GetAccountByID(id int64) (*Account, error) {
+	var account Account
+	if err := db.Get(&account, "SELECT * FROM accounts WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
 // === FOLDERS ===
 
 func GetOrCreateFolder(accountID int64, name string) (*Folder, error) {
@@ -109,6 +118,7 @@ func UpsertEmail(e *Email) (int64, string, error) {
 			body_html = excluded.body_html,
 			in_reply_to = excluded.in_reply_to,
 			"references" = excluded."references",
+			raw_headers = COALESCE(NULLIF(excluded.raw_headers, ''), emails.raw_headers),
 			updated_at = CURRENT_TIMESTAMP`,
 		e.AccountID, e.FolderID, e.UID, e.MessageID, e.Subject,
 		e.FromName, e.FromEmail, e.ToAddresses, e.CcAddresses, e.Date,
@@ -151,7 +161,7 @@ func GetEmails(accountID, folderID int64, limit, offset int) ([]EmailSummary, er
 	// If accountID is 0, search by folderID only (folderID is unique)
 	if accountID == 0 {
 		err = db.Select(&emails, `
-			SELECT id, uid, message_id, subject, from_name, from_email, date, is_read, is_starred, is_replied, has_attachments, snippet
+			SELECT id, account_id, uid, message_id, subject, from_name, from_email, date, is_read, is_starred, is_replied, has_attachments, snippet
 			FROM emails
 			WHERE folder_id = ? AND is_archived = 0 AND is_deleted = 0
 			ORDER BY date DESC
@@ -159,7 +169,7 @@ func GetEmails(accountID, folderID int64, limit, offset int) ([]EmailSummary, er
 			folderID, limit, offset)
 	} else {
 		err = db.Select(&emails, `
-			SELECT id, uid, message_id, subject, from_name, from_email, date, is_read, is_starred, is_replied, has_attachments, snippet
+			SELECT id, account_id, uid, message_id, subject, from_name, from_email, date, is_read, is_starred, is_replied, has_attachments, snippet
 			FROM emails
 			WHERE account_id = ? AND folder_id = ? AND is_archived = 0 AND is_deleted = 0
 			ORDER BY date DESC
@@ -169,6 +179,35 @@ func GetEmails(accountID, folderID int64, limit, offset int) ([]EmailSummary, er
 	return emails, err
 }
 
+// GetUnifiedEmails retorna os emails da pasta com esse nome em todas as contas
+// (caixa de entrada unificada), mais recentes primeiro
+func GetUnifiedEmails(folderName string, limit, offset int) ([]EmailSummary, error) {
+	var emails []EmailSummary
+	var err = db.Select(&emails, `
+		SELECT e.id, e.account_id, a.email AS account_email, e.uid, e.message_id, e.subject, e.from_name, e.from_email,
+			e.date, e.is_read, e.is_starred, e.is_replied, e.has_attachments, e.snippet
+		FROM emails e
+		JOIN folders f ON f.id = e.folder_id
+		JOIN accounts a ON a.id = e.account_id
+		WHERE f.name = ? AND e.is_archived = 0 AND e.is_deleted = 0
+		ORDER BY e.date DESC
+		LIMIT ? OFFSET ?`,
+		folderName, limit, offset)
+	return emails, err
+}
+
+// CountUnifiedUnread conta os não lidos da pasta em todas as contas
+func CountUnifiedUnread(folderName string) (int, error) {
+	var count int
+	var err = db.Get(&count, `
+		SELECT COUNT(*)
+		FROM emails e
+		JOIN folders f ON f.id = e.folder_id
+		WHERE f.name = ? AND e.is_archived = 0 AND e.is_deleted = 0 AND e.is_read = 0`,
+		folderName)
+	return count, err
+}
+
 func GetEmailByID(id int64) (*Email, error) {
 	var email Email
 	err := db.Get(&email, "SELECT * FROM emails WHERE id = ?", id)
@@ -226,10 +265,18 @@ func EmailExistsByUID(accountID, folderID int64, uid uint32) (bool, error) {
 
 func GetLatestUID(accountID, folderID int64) (uint32, error) {
 	var uid uint32
-	err := db.Get(&uid, "SELECT COALESCE(MAX(uid), 0) FROM emails WHERE account_id = ? AND folder_id = ?", accountID, folderID)
+	// Ignora a faixa de UIDs dos órfãos estacionados (ver ReconcileFolderUIDs)
+	err := db.Get(&uid, "SELECT COALESCE(MAX(uid), 0) FROM emails WHERE account_id = ? AND folder_id = ? AND uid < ?", accountID, folderID, orphanUIDFloor)
 	return uid, err
 }
 
+// GetFolderUIDs retorna os UIDs dos emails ativos de uma pasta (para purge)
+func GetFolderUIDs(folderID int64) ([]uint32, error) {
+	var uids []uint32
+	err := db.Select(&uids, "SELECT uid FROM emails WHERE folder_id = ? AND is_deleted = 0 AND uid < ?", folderID, orphanUIDFloor)
+	return uids, err
+}
+
 // UpdateEmailBody updates the body_text and body_html of an email (caches IMAP fetch)
 func UpdateEmailBody(id int64, bodyText, bodyHTML string) error {
 	// Also generate snippet if not present
@@ -711,6 +758,22 @@ func MarkDraftSending(id int64) error {
 	return err
 }
 
+// ClaimScheduledDraft marca um draft agendado como sending, se ainda estiver
+// scheduled. Retorna false se outro processo (ou um cancelamento) chegou
+// antes.
+func ClaimScheduledDraft(id int64) (bool, error) {
+	var result, err = db.Exec(`
+		UPDATE drafts SET
+			status = 'sending',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'scheduled'`, id)
+	if err != nil {
+		return false, err
+	}
+	var n, _ = result.RowsAffected()
+	return n > 0, nil
+}
+
 // MarkDraftSent marca draft como enviado
 func MarkDraftSent(id int64) error {
 	_, err := db.Exec(`
@@ -2207,6 +2270,28 @@ func UpdateThreadID(emailID int64, threadID string) error {
 	return err
 }
 
+// GetEmailByMessageID returns the newest email of an account with the given
+// Message-ID, with or without <> (nil if there is none)
+func GetEmailByMessageID(accountID int64, messageID string) (*Email, error) {
+	var id = normalizeMessageID(messageID)
+	if id == "" {
+		return nil, nil
+	}
+
+	var email Email
+	var err = db.Get(&email, `
+		SELECT * FROM emails
+		WHERE account_id = ? AND message_id IN (?, ?) AND is_deleted = 0
+		ORDER BY date DESC LIMIT 1`, accountID, id, "<"+id+">")
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &email, nil
+}
+
 // UpdateThreadIDByMessageID updates thread_id for emails matching a message_id
 // Returns the number of rows updated
 func UpdateThreadIDByMessageID(accountID int64, messageID, threadID string) (int64, error) {
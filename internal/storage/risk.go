@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// === RISCO (autenticação SPF/DKIM/DMARC e phishing) ===
+
+// EmailRisk é o resultado da análise de risco de um email; findings é JSON
+// (formato definido em authresults)
+type EmailRisk struct {
+	EmailID      int64      `db:"email_id"`
+	AccountID    int64      `db:"account_id"`
+	Score        int        `db:"score"`
+	Level        string     `db:"level"`
+	SPF          string     `db:"spf"`
+	DKIM         string     `db:"dkim"`
+	DMARC        string     `db:"dmarc"`
+	DKIMVerified bool       `db:"dkim_verified"`
+	Findings     string     `db:"findings"`
+	AnalyzedAt   SQLiteTime `db:"analyzed_at"`
+}
+
+// SaveEmailRisk grava (ou substitui) a análise de um email
+func SaveEmailRisk(r *EmailRisk) error {
+	var _, err = db.Exec(`
+		INSERT INTO email_risk (email_id, account_id, score, level, spf, dkim, dmarc, dkim_verified, findings, analyzed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(email_id) DO UPDATE SET
+			score = excluded.score,
+			level = excluded.level,
+			spf = excluded.spf,
+			dkim = excluded.dkim,
+			dmarc = excluded.dmarc,
+			dkim_verified = excluded.dkim_verified,
+			findings = excluded.findings,
+			analyzed_at = CURRENT_TIMESTAMP`,
+		r.EmailID, r.AccountID, r.Score, r.Level, r.SPF, r.DKIM, r.DMARC, r.DKIMVerified, r.Findings)
+	return err
+}
+
+// GetEmailRisk retorna a análise de um email (nil se ainda não analisado)
+func GetEmailRisk(emailID int64) (*EmailRisk, error) {
+	var r EmailRisk
+	var err = db.Get(&r, "SELECT * FROM email_risk WHERE email_id = ?", emailID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetRiskForEmails retorna as análises de vários emails, por id
+func GetRiskForEmails(emailIDs []int64) (map[int64]EmailRisk, error) {
+	var result = make(map[int64]EmailRisk)
+	if len(emailIDs) == 0 {
+		return result, nil
+	}
+
+	var placeholders = make([]string, len(emailIDs))
+	var args = make([]interface{}, len(emailIDs))
+	for i, id := range emailIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	var rows []EmailRisk
+	var err = db.Select(&rows, `
+		SELECT * FROM email_risk
+		WHERE email_id IN (`+strings.Join(placeholders, ",")+`)`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		result[r.EmailID] = r
+	}
+	return result, nil
+}
+
+// IsKnownSender indica se já houve contato com o endereço antes do email
+// emailID: é um contato, mandou email antes ou recebeu email da conta
+func IsKnownSender(accountID, emailID int64, address string) (bool, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if address == "" {
+		return false, nil
+	}
+
+	var known bool
+	var err = db.Get(&known, `
+		SELECT
+			EXISTS (
+				SELECT 1 FROM contact_emails ce
+				JOIN contacts c ON c.id = ce.contact_id
+				WHERE c.account_id = ? AND lower(ce.email) = ?
+			)
+			OR EXISTS (
+				SELECT 1 FROM emails e
+				WHERE e.account_id = ? AND lower(e.from_email) = ? AND e.id != ?
+				  AND e.date < (SELECT date FROM emails WHERE id = ?)
+			)
+			OR EXISTS (
+				SELECT 1 FROM sent_emails s
+				WHERE s.account_id = ?
+				  AND (instr(lower(s.to_addresses), ?) > 0 OR instr(lower(COALESCE(s.cc_addresses, '')), ?) > 0)
+			)
+			OR EXISTS (
+				SELECT 1 FROM emails e
+				JOIN accounts a ON a.id = e.account_id AND lower(a.email) = lower(e.from_email)
+				WHERE e.account_id = ? AND instr(lower(e.to_addresses), ?) > 0
+			)`,
+		accountID, address,
+		accountID, address, emailID, emailID,
+		accountID, address, address,
+		accountID, address)
+	return known, err
+}
+
+// GetContactEmailsByName retorna os emails dos contatos com esse nome
+func GetContactEmailsByName(accountID int64, name string) ([]string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+
+	var emails []string
+	var err = db.Select(&emails, `
+		SELECT DISTINCT lower(ce.email)
+		FROM contacts c
+		JOIN contact_emails ce ON ce.contact_id = c.id
+		WHERE c.account_id = ? AND c.display_name = ? COLLATE NOCASE`,
+		accountID, name)
+	return emails, err
+}
+
+// GetKnownDomains retorna os domínios conhecidos da conta: dos contatos, de
+// remetentes já respondidos e das próprias contas
+func GetKnownDomains(accountID int64) ([]string, error) {
+	var domains []string
+	var err = db.Select(&domains, `
+		SELECT lower(substr(ce.email, instr(ce.email, '@') + 1))
+		FROM contact_emails ce
+		JOIN contacts c ON c.id = ce.contact_id
+		WHERE c.account_id = ? AND instr(ce.email, '@') > 0
+		UNION
+		SELECT lower(substr(from_email, instr(from_email, '@') + 1))
+		FROM emails
+		WHERE account_id = ? AND is_replied = 1 AND instr(from_email, '@') > 0
+		UNION
+		SELECT lower(substr(email, instr(email, '@') + 1))
+		FROM accounts
+		WHERE instr(email, '@') > 0`,
+		accountID, accountID)
+	return domains, err
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestEmailRisk tests saving risk reports and the sender lookups the analysis uses
+func TestEmailRisk(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, _ = GetOrCreateAccount("me@example.org", "Me")
+	var inbox, _ = GetOrCreateFolder(account.ID, "INBOX")
+
+	var now = time.Now()
+	var insert = func(uid uint32, from string, age time.Duration) int64 {
+		var email = Email{
+			AccountID: account.ID,
+			FolderID:  inbox.ID,
+			UID:       uid,
+			Subject:   "hello",
+			FromEmail: from,
+			Date:      SQLiteTime{now.Add(-age)},
+		}
+		var id, _, err = UpsertEmail(&email)
+		if err != nil {
+			t.Fatalf("Failed to insert email %d: %v", uid, err)
+		}
+		return id
+	}
+	var first = insert(1, "bob@partner.com", 2*time.Hour)
+	var second = insert(2, "Bob@partner.com", time.Hour)
+	var stranger = insert(3, "eve@unknown.net", time.Minute)
+
+	db.Exec(`INSERT INTO contacts (account_id, resource_name, display_name) VALUES (?, 'people/1', 'Alice Smith')`, account.ID)
+	db.Exec(`INSERT INTO contact_emails (contact_id, email) VALUES (last_insert_rowid(), 'alice@company.com')`)
+
+	for _, tc := range []struct {
+		emailID int64
+		address string
+		want    bool
+	}{
+		{first, "bob@partner.com", false},
+		{second, "bob@partner.com", true},
+		{stranger, "eve@unknown.net", false},
+		{stranger, "Alice@Company.com", true},
+	} {
+		var known, err = IsKnownSender(account.ID, tc.emailID, tc.address)
+		if err != nil {
+			t.Fatalf("IsKnownSender failed: %v", err)
+		}
+		if known != tc.want {
+			t.Errorf("IsKnownSender(%d, %s) = %v, want %v", tc.emailID, tc.address, known, tc.want)
+		}
+	}
+
+	var emails, _ = GetContactEmailsByName(account.ID, "alice smith")
+	if len(emails) != 1 || emails[0] != "alice@company.com" {
+		t.Errorf("Unexpected contact emails: %v", emails)
+	}
+	var domains, _ = GetKnownDomains(account.ID)
+	var found = make(map[string]bool)
+	for _, d := range domains {
+		found[d] = true
+	}
+	if !found["company.com"] || !found["example.org"] || found["unknown.net"] {
+		t.Errorf("Unexpected known domains: %v", domains)
+	}
+
+	var risk = EmailRisk{EmailID: stranger, AccountID: account.ID, Score: 55, Level: "medium", SPF: "softfail", Findings: `[{"reason":"first_time_sender"}]`}
+	if err := SaveEmailRisk(&risk); err != nil {
+		t.Fatalf("SaveEmailRisk failed: %v", err)
+	}
+	risk.Score, risk.Level = 70, "high"
+	if err := SaveEmailRisk(&risk); err != nil {
+		t.Fatalf("SaveEmailRisk (update) failed: %v", err)
+	}
+
+	var saved, err = GetEmailRisk(stranger)
+	if err != nil || saved == nil || saved.Score != 70 || saved.Level != "high" || saved.SPF != "softfail" {
+		t.Fatalf("Unexpected saved risk: %+v, %v", saved, err)
+	}
+	if missing, _ := GetEmailRisk(first); missing != nil {
+		t.Errorf("Expected no risk for an unanalysed email, got %+v", missing)
+	}
+
+	var byID, _ = GetRiskForEmails([]int64{first, stranger})
+	if len(byID) != 1 || byID[stranger].Level != "high" {
+		t.Errorf("Unexpected risks: %+v", byID)
+	}
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// === REGRAS DE FILTRO ===
+
+// MailRule é uma regra de filtro; condições e ações são JSON (formato definido em services)
+type MailRule struct {
+	ID         int64        `db:"id"`
+	AccountID  int64        `db:"account_id"`
+	Name       string       `db:"name"`
+	Enabled    bool         `db:"enabled"`
+	Position   int          `db:"position"`
+	MatchAny   bool         `db:"match_any"`
+	Stop       bool         `db:"stop"`
+	Conditions string       `db:"conditions"`
+	Actions    string       `db:"actions"`
+	HitCount   int          `db:"hit_count"`
+	LastHitAt  sql.NullTime `db:"last_hit_at"`
+	CreatedAt  SQLiteTime   `db:"created_at"`
+	UpdatedAt  SQLiteTime   `db:"updated_at"`
+}
+
+// CreateRule cria uma regra no fim da lista (position = última + 1)
+func CreateRule(rule *MailRule) error {
+	var result, err = db.Exec(`
+		INSERT INTO mail_rules (account_id, name, enabled, position, match_any, stop, conditions, actions)
+		VALUES (?, ?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM mail_rules WHERE account_id = ?), ?, ?, ?, ?)`,
+		rule.AccountID, rule.Name, rule.Enabled, rule.AccountID,
+		rule.MatchAny, rule.Stop, rule.Conditions, rule.Actions)
+	if err != nil {
+		return err
+	}
+
+	var id, _ = result.LastInsertId()
+	var created, getErr = GetRule(id)
+	if getErr != nil || created == nil {
+		rule.ID = id
+		return getErr
+	}
+	*rule = *created
+	return nil
+}
+
+// UpdateRule atualiza nome, ordem, condições e ações de uma regra
+func UpdateRule(rule *MailRule) error {
+	var _, err = db.Exec(`
+		UPDATE mail_rules SET
+			name = ?, enabled = ?, position = ?, match_any = ?, stop = ?,
+			conditions = ?, actions = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		rule.Name, rule.Enabled, rule.Position, rule.MatchAny, rule.Stop,
+		rule.Conditions, rule.Actions, rule.ID)
+	if err != nil {
+		return err
+	}
+	rule.UpdatedAt = SQLiteTime{time.Now()}
+	return nil
+}
+
+// GetRule retorna uma regra por ID (nil se não existir)
+func GetRule(id int64) (*MailRule, error) {
+	var rule MailRule
+	var err = db.Get(&rule, "SELECT * FROM mail_rules WHERE id = ?", id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetRules retorna as regras de uma conta na ordem de avaliação
+func GetRules(accountID int64) ([]MailRule, error) {
+	var rules []MailRule
+	var err = db.Select(&rules, `
+		SELECT * FROM mail_rules
+		WHERE account_id = ?
+		ORDER BY position ASC, id ASC`,
+		accountID)
+	return rules, err
+}
+
+// GetEnabledRules retorna apenas as regras ativas de uma conta
+func GetEnabledRules(accountID int64) ([]MailRule, error) {
+	var rules []MailRule
+	var err = db.Select(&rules, `
+		SELECT * FROM mail_rules
+		WHERE account_id = ? AND enabled = 1
+		ORDER BY position ASC, id ASC`,
+		accountID)
+	return rules, err
+}
+
+// SetRuleEnabled ativa ou desativa uma regra
+func SetRuleEnabled(id int64, enabled bool) error {
+	var _, err = db.Exec(`
+		UPDATE mail_rules SET enabled = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, enabled, id)
+	return err
+}
+
+// RecordRuleHit incrementa o contador de uso da regra
+func RecordRuleHit(id int64) error {
+	var _, err = db.Exec(`
+		UPDATE mail_rules SET hit_count = hit_count + 1, last_hit_at = ?
+		WHERE id = ?`, time.Now(), id)
+	return err
+}
+
+// DeleteRule remove uma regra
+func DeleteRule(id int64) error {
+	var _, err = db.Exec("DELETE FROM mail_rules WHERE id = ?", id)
+	return err
+}
+
+// DeleteAllRules remove todas as regras de uma conta
+func DeleteAllRules(accountID int64) error {
+	var _, err = db.Exec("DELETE FROM mail_rules WHERE account_id = ?", accountID)
+	return err
+}
+
+// ReplaceRules troca todas as regras de uma conta pelas informadas, na ordem
+// recebida, numa única transação: se alguma falhar as regras antigas ficam.
+// Preenche o ID de cada regra criada.
+func ReplaceRules(accountID int64, rules []MailRule) error {
+	var tx, err = db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM mail_rules WHERE account_id = ?", accountID); err != nil {
+		return err
+	}
+
+	var ids = make([]int64, len(rules))
+	for i := range rules {
+		var rule = &rules[i]
+		var result, err = tx.Exec(`
+			INSERT INTO mail_rules (account_id, name, enabled, position, match_any, stop, conditions, actions)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			accountID, rule.Name, rule.Enabled, i,
+			rule.MatchAny, rule.Stop, rule.Conditions, rule.Actions)
+		if err != nil {
+			return err
+		}
+		ids[i], _ = result.LastInsertId()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for i := range rules {
+		rules[i].ID = ids[i]
+		rules[i].AccountID = accountID
+		rules[i].Position = i
+	}
+	return nil
+}
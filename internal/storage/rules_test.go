@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestMailRulesCRUD tests rule ordering, enable toggling and hit counting
+func TestMailRulesCRUD(t *testing.T) {
+	var tmpDir = t.TempDir()
+	var dbPath = filepath.Join(tmpDir, "test.db")
+
+	if err := Init(dbPath); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, err = GetOrCreateAccount("test@example.com", "Test User")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	var names = []string{"first", "second", "third"}
+	var ids []int64
+	for _, name := range names {
+		var rule = MailRule{
+			AccountID:  account.ID,
+			Name:       name,
+			Enabled:    true,
+			Conditions: `[{"field":"from","op":"contains","value":"x"}]`,
+			Actions:    `[{"type":"star"}]`,
+		}
+		if err := CreateRule(&rule); err != nil {
+			t.Fatalf("Failed to create rule %s: %v", name, err)
+		}
+		ids = append(ids, rule.ID)
+	}
+
+	var rules, listErr = GetRules(account.ID)
+	if listErr != nil {
+		t.Fatalf("GetRules failed: %v", listErr)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %d", len(rules))
+	}
+	for i, r := range rules {
+		if r.Name != names[i] || r.Position != i {
+			t.Errorf("Rule %d: got %s at position %d", i, r.Name, r.Position)
+		}
+	}
+
+	// Move the last rule to the top
+	rules[2].Position = -1
+	if err := UpdateRule(&rules[2]); err != nil {
+		t.Fatalf("UpdateRule failed: %v", err)
+	}
+	if err := SetRuleEnabled(ids[1], false); err != nil {
+		t.Fatalf("SetRuleEnabled failed: %v", err)
+	}
+
+	var enabled, _ = GetEnabledRules(account.ID)
+	if len(enabled) != 2 || enabled[0].Name != "third" || enabled[1].Name != "first" {
+		t.Errorf("Unexpected enabled rules: %+v", enabled)
+	}
+
+	if err := RecordRuleHit(ids[0]); err != nil {
+		t.Fatalf("RecordRuleHit failed: %v", err)
+	}
+	var hit, _ = GetRule(ids[0])
+	if hit == nil || hit.HitCount != 1 || !hit.LastHitAt.Valid {
+		t.Errorf("Expected 1 hit with last_hit_at, got %+v", hit)
+	}
+
+	if err := DeleteAllRules(account.ID); err != nil {
+		t.Fatalf("DeleteAllRules failed: %v", err)
+	}
+	if missing, _ := GetRule(ids[0]); missing != nil {
+		t.Error("Rule should be deleted")
+	}
+}
+
+// TestReplaceRulesIsAtomic tests that a failed insert keeps the old rules
+func TestReplaceRulesIsAtomic(t *testing.T) {
+	var tmpDir = t.TempDir()
+	var dbPath = filepath.Join(tmpDir, "test.db")
+
+	if err := Init(dbPath); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, err = GetOrCreateAccount("test@example.com", "Test User")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	var old = MailRule{AccountID: account.ID, Name: "old", Enabled: true, Conditions: "[]", Actions: `[{"type":"star"}]`}
+	if err := CreateRule(&old); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	// Second insert fails halfway through the replace
+	if _, err := db.Exec(`CREATE TRIGGER fail_rule BEFORE INSERT ON mail_rules
+		WHEN NEW.name = 'boom' BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+	var failing = []MailRule{
+		{Name: "new", Enabled: true, Conditions: "[]", Actions: "[]"},
+		{Name: "boom", Enabled: true, Conditions: "[]", Actions: "[]"},
+	}
+	if err := ReplaceRules(account.ID, failing); err == nil {
+		t.Fatal("Expected ReplaceRules to fail")
+	}
+	var rules, _ = GetRules(account.ID)
+	if len(rules) != 1 || rules[0].Name != "old" {
+		t.Errorf("Expected only the old rule after a failed replace, got %+v", rules)
+	}
+
+	// A successful replace swaps them in order
+	var replacement = []MailRule{
+		{Name: "b", Enabled: true, Conditions: "[]", Actions: "[]"},
+		{Name: "a", Enabled: false, Conditions: "[]", Actions: "[]"},
+	}
+	if err := ReplaceRules(account.ID, replacement); err != nil {
+		t.Fatalf("ReplaceRules failed: %v", err)
+	}
+	rules, _ = GetRules(account.ID)
+	if len(rules) != 2 || rules[0].Name != "b" || rules[1].Name != "a" || rules[0].ID != replacement[0].ID {
+		t.Errorf("Unexpected rules after replace: %+v", rules)
+	}
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"sort"
+)
+
+// orphanUIDFloor marca o início da faixa de UIDs reservada para emails órfãos.
+// Quando o UIDVALIDITY muda, emails sem correspondência no servidor são
+// "estacionados" em orphanUIDMax - id: continuam únicos na pasta, não colidem
+// com os UIDs novos do servidor e ficam fora de GetLatestUID.
+const (
+	orphanUIDFloor uint32 = 0xF0000000
+	orphanUIDMax   uint32 = 0xFFFFFFFF
+)
+
+// UIDReconcileResult resume uma reconciliação de UIDs após mudança de UIDVALIDITY
+type UIDReconcileResult struct {
+	Rematched   int      // emails locais re-associados pelo Message-ID
+	Orphaned    int      // emails locais sem correspondência (marcados como deletados)
+	MissingUIDs []uint32 // UIDs do servidor sem email local (precisam ser baixados)
+}
+
+// UpdateFolderUIDValidity salva o UIDVALIDITY atual da pasta
+func UpdateFolderUIDValidity(folderID int64, uidValidity uint32) error {
+	var _, err = db.Exec("UPDATE folders SET uid_validity = ? WHERE id = ?", uidValidity, folderID)
+	return err
+}
+
+// ReconcileFolderUIDs re-associa os emails de uma pasta aos UIDs novos do servidor
+// usando o Message-ID. serverIDs é UID -> Message-ID conforme o servidor.
+// Emails locais sem correspondência são marcados como deletados; nada é apagado.
+func ReconcileFolderUIDs(accountID, folderID int64, serverIDs map[uint32]string) (*UIDReconcileResult, error) {
+	var tx, err = db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Emails locais ativos, em ordem de UID antigo (preserva ordem em duplicados)
+	var local []struct {
+		ID        int64  `db:"id"`
+		MessageID string `db:"message_id"`
+	}
+	err = tx.Select(&local, `
+		SELECT id, COALESCE(message_id, '') AS message_id FROM emails
+		WHERE account_id = ? AND folder_id = ? AND is_deleted = 0 AND uid < ?
+		ORDER BY uid`,
+		accountID, folderID, orphanUIDFloor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Message-ID -> UIDs novos (em ordem crescente, o servidor pode ter duplicados)
+	var serverUIDs = make([]uint32, 0, len(serverIDs))
+	for uid := range serverIDs {
+		serverUIDs = append(serverUIDs, uid)
+	}
+	sort.Slice(serverUIDs, func(i, j int) bool { return serverUIDs[i] < serverUIDs[j] })
+
+	var byMessageID = make(map[string][]uint32)
+	for _, uid := range serverUIDs {
+		var msgID = serverIDs[uid]
+		if msgID != "" {
+			byMessageID[msgID] = append(byMessageID[msgID], uid)
+		}
+	}
+
+	// Estaciona todos os UIDs antigos da pasta para liberar a constraint UNIQUE
+	_, err = tx.Exec(`
+		UPDATE emails SET uid = ? - id
+		WHERE account_id = ? AND folder_id = ? AND uid < ?`,
+		orphanUIDMax, accountID, folderID, orphanUIDFloor)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = &UIDReconcileResult{}
+	var matched = make(map[uint32]bool)
+
+	for _, e := range local {
+		var candidates = byMessageID[e.MessageID]
+		if e.MessageID == "" || len(candidates) == 0 {
+			// Órfão de verdade: sumiu do servidor (ou não tem Message-ID para casar)
+			if _, err := tx.Exec(`
+				UPDATE emails SET is_deleted = 1, updated_at = CURRENT_TIMESTAMP
+				WHERE id = ?`, e.ID); err != nil {
+				return nil, err
+			}
+			result.Orphaned++
+			continue
+		}
+
+		var newUID = candidates[0]
+		byMessageID[e.MessageID] = candidates[1:]
+
+		if _, err := tx.Exec(`
+			UPDATE emails SET uid = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`, newUID, e.ID); err != nil {
+			return nil, err
+		}
+		matched[newUID] = true
+		result.Rematched++
+	}
+
+	for _, uid := range serverUIDs {
+		if !matched[uid] {
+			result.MissingUIDs = append(result.MissingUIDs, uid)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReconcileFolderUIDs tests that a UIDVALIDITY change re-matches emails by Message-ID
+func TestReconcileFolderUIDs(t *testing.T) {
+	var tmpDir = t.TempDir()
+	var dbPath = filepath.Join(tmpDir, "test.db")
+
+	if err := Init(dbPath); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var account, err = GetOrCreateAccount("test@example.com", "Test User")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	var folder, err2 = GetOrCreateFolder(account.ID, "INBOX")
+	if err2 != nil {
+		t.Fatalf("Failed to create folder: %v", err2)
+	}
+
+	// Local emails with old UIDs 1..4 (UID 4 has no Message-ID)
+	var messageIDs = map[uint32]string{1: "<a@x>", 2: "<b@x>", 3: "<c@x>", 4: ""}
+	var ids = make(map[uint32]int64)
+	for uid := uint32(1); uid <= 4; uid++ {
+		var email = Email{
+			AccountID: account.ID,
+			FolderID:  folder.ID,
+			UID:       uid,
+			MessageID: sql.NullString{String: messageIDs[uid], Valid: messageIDs[uid] != ""},
+			Subject:   "Test email",
+			FromEmail: "sender@example.com",
+			Date:      SQLiteTime{time.Now()},
+		}
+		var id, _, err = UpsertEmail(&email)
+		if err != nil {
+			t.Fatalf("Failed to insert email UID %d: %v", uid, err)
+		}
+		ids[uid] = id
+	}
+
+	// Server renumbered the folder: <a> is now 3, <b> is 1, <c> is gone, <d> is new.
+	// The new UID 3 collides with an old local UID on purpose.
+	var serverIDs = map[uint32]string{1: "<b@x>", 2: "<d@x>", 3: "<a@x>"}
+
+	var result, err3 = ReconcileFolderUIDs(account.ID, folder.ID, serverIDs)
+	if err3 != nil {
+		t.Fatalf("ReconcileFolderUIDs failed: %v", err3)
+	}
+
+	if result.Rematched != 2 {
+		t.Errorf("Expected 2 rematched emails, got %d", result.Rematched)
+	}
+	if result.Orphaned != 2 {
+		t.Errorf("Expected 2 orphaned emails, got %d", result.Orphaned)
+	}
+	if len(result.MissingUIDs) != 1 || result.MissingUIDs[0] != 2 {
+		t.Errorf("Expected missing UIDs [2], got %v", result.MissingUIDs)
+	}
+
+	// Rows keep their id (and cached body) but get the new UID
+	var expected = map[int64]uint32{ids[1]: 3, ids[2]: 1}
+	for id, uid := range expected {
+		var got uint32
+		if err := db.Get(&got, "SELECT uid FROM emails WHERE id = ?", id); err != nil {
+			t.Fatalf("Failed to read email %d: %v", id, err)
+		}
+		if got != uid {
+			t.Errorf("Email %d: expected UID %d, got %d", id, uid, got)
+		}
+	}
+
+	// Orphans are marked deleted, not removed
+	for _, id := range []int64{ids[3], ids[4]} {
+		var isDeleted bool
+		if err := db.Get(&isDeleted, "SELECT is_deleted FROM emails WHERE id = ?", id); err != nil {
+			t.Fatalf("Orphan %d was removed: %v", id, err)
+		}
+		if !isDeleted {
+			t.Errorf("Expected orphan %d to be marked deleted", id)
+		}
+	}
+
+	// Parked orphan UIDs must not leak into incremental sync
+	var latestUID, _ = GetLatestUID(account.ID, folder.ID)
+	if latestUID != 3 {
+		t.Errorf("Expected latest UID 3, got %d", latestUID)
+	}
+}
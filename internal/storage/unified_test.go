@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestGetUnifiedEmails tests that the unified inbox merges the INBOX of every account
+func TestGetUnifiedEmails(t *testing.T) {
+	if err := Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	defer Close()
+
+	var work, _ = GetOrCreateAccount("me@work.com", "Work")
+	var home, _ = GetOrCreateAccount("me@home.com", "Home")
+	var workInbox, _ = GetOrCreateFolder(work.ID, "INBOX")
+	var homeInbox, _ = GetOrCreateFolder(home.ID, "INBOX")
+	var homeSent, _ = GetOrCreateFolder(home.ID, "Sent")
+
+	var now = time.Now()
+	var insert = func(accountID, folderID int64, uid uint32, subject string, age time.Duration, deleted bool) {
+		var email = Email{
+			AccountID: accountID,
+			FolderID:  folderID,
+			UID:       uid,
+			Subject:   subject,
+			FromEmail: "sender@example.com",
+			Date:      SQLiteTime{now.Add(-age)},
+			IsDeleted: deleted,
+		}
+		if _, _, err := UpsertEmail(&email); err != nil {
+			t.Fatalf("Failed to insert %q: %v", subject, err)
+		}
+	}
+	insert(work.ID, workInbox.ID, 1, "work old", 3*time.Hour, false)
+	insert(home.ID, homeInbox.ID, 1, "home new", time.Hour, false)
+	insert(work.ID, workInbox.ID, 2, "work newest", time.Minute, false)
+	insert(home.ID, homeInbox.ID, 2, "home deleted", 2*time.Hour, true)
+	insert(home.ID, homeSent.ID, 1, "home sent", 0, false)
+
+	var emails, err = GetUnifiedEmails("INBOX", 10, 0)
+	if err != nil {
+		t.Fatalf("GetUnifiedEmails failed: %v", err)
+	}
+
+	var want = []struct {
+		subject string
+		account string
+	}{
+		{"work newest", "me@work.com"},
+		{"home new", "me@home.com"},
+		{"work old", "me@work.com"},
+	}
+	if len(emails) != len(want) {
+		t.Fatalf("Expected %d emails, got %d", len(want), len(emails))
+	}
+	for i, w := range want {
+		if emails[i].Subject != w.subject || emails[i].AccountEmail != w.account {
+			t.Errorf("emails[%d] = %q (%s), want %q (%s)", i, emails[i].Subject, emails[i].AccountEmail, w.subject, w.account)
+		}
+	}
+	if emails[1].AccountID != home.ID {
+		t.Errorf("AccountID = %d, want %d", emails[1].AccountID, home.ID)
+	}
+}
@@ -33,5 +33,38 @@ func (m *GmailAPIPort) Archive(ctx context.Context, messageID string) error {
 	return args.Error(0)
 }
 
+// ListLabels returns the labels of the mailbox
+func (m *GmailAPIPort) ListLabels(ctx context.Context) ([]ports.Label, error) {
+	var args = m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]ports.Label), args.Error(1)
+}
+
+// CreateLabel creates a user label
+func (m *GmailAPIPort) CreateLabel(ctx context.Context, name string) (*ports.Label, error) {
+	var args = m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ports.Label), args.Error(1)
+}
+
+// ModifyLabels adds and removes labels on a message
+func (m *GmailAPIPort) ModifyLabels(ctx context.Context, messageID string, add, remove []string) error {
+	var args = m.Called(ctx, messageID, add, remove)
+	return args.Error(0)
+}
+
+// ListMessageLabels returns the label IDs of the newest messages
+func (m *GmailAPIPort) ListMessageLabels(ctx context.Context, maxMessages int) (map[string][]string, error) {
+	var args = m.Called(ctx, maxMessages)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string][]string), args.Error(1)
+}
+
 // Ensure GmailAPIPort implements ports.GmailAPIPort
 var _ ports.GmailAPIPort = (*GmailAPIPort)(nil)
@@ -84,6 +84,41 @@ func (m *IMAPPort) GetAllUIDs(ctx context.Context) ([]uint32, error) {
 	return args.Get(0).([]uint32), args.Error(1)
 }
 
+func (m *IMAPPort) FetchMessageIDs(ctx context.Context) (map[uint32]string, error) {
+	var args = m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uint32]string), args.Error(1)
+}
+
+// Flag sync
+func (m *IMAPPort) SupportsCondStore() bool {
+	var args = m.Called()
+	return args.Bool(0)
+}
+
+func (m *IMAPPort) FetchFlagChanges(ctx context.Context, sinceModSeq uint64) ([]ports.FlagUpdate, error) {
+	var args = m.Called(ctx, sinceModSeq)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]ports.FlagUpdate), args.Error(1)
+}
+
+func (m *IMAPPort) FlagChangesIncludeExpunges() bool {
+	var args = m.Called()
+	return args.Bool(0)
+}
+
+func (m *IMAPPort) FetchFlags(ctx context.Context, uids []uint32) ([]ports.FlagUpdate, error) {
+	var args = m.Called(ctx, uids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]ports.FlagUpdate), args.Error(1)
+}
+
 // Search
 func (m *IMAPPort) SearchText(ctx context.Context, query string, limit int) ([]uint32, error) {
 	var args = m.Called(ctx, query, limit)
@@ -147,17 +182,41 @@ func (m *IMAPPort) MoveToFolder(ctx context.Context, uid uint32, folder string)
 	return args.Error(0)
 }
 
+func (m *IMAPPort) CopyToInbox(ctx context.Context, folder string, uid uint32) error {
+	var args = m.Called(ctx, folder, uid)
+	return args.Error(0)
+}
+
 func (m *IMAPPort) Delete(ctx context.Context, uid uint32) error {
 	var args = m.Called(ctx, uid)
 	return args.Error(0)
 }
 
+func (m *IMAPPort) AddKeyword(ctx context.Context, uid uint32, keyword string) error {
+	var args = m.Called(ctx, uid, keyword)
+	return args.Error(0)
+}
+
+func (m *IMAPPort) RemoveKeyword(ctx context.Context, uid uint32, keyword string) error {
+	var args = m.Called(ctx, uid, keyword)
+	return args.Error(0)
+}
+
 // Utility
 func (m *IMAPPort) GetTrashFolder() string {
 	var args = m.Called()
 	return args.String(0)
 }
 
+// Push notifications
+func (m *IMAPPort) Idle(ctx context.Context, mailbox string) (*ports.MailboxUpdate, error) {
+	var args = m.Called(ctx, mailbox)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ports.MailboxUpdate), args.Error(1)
+}
+
 // Attachments
 func (m *IMAPPort) FetchAttachmentMetadata(ctx context.Context, uid uint32) ([]ports.AttachmentInfo, bool, error) {
 	var args = m.Called(ctx, uid)
@@ -57,6 +57,24 @@ func (m *StoragePort) UpdateFolderStats(ctx context.Context, folderID int64, tot
 	return args.Error(0)
 }
 
+func (m *StoragePort) UpdateFolderUIDValidity(ctx context.Context, folderID int64, uidValidity uint32) error {
+	var args = m.Called(ctx, folderID, uidValidity)
+	return args.Error(0)
+}
+
+func (m *StoragePort) UpdateFolderModSeq(ctx context.Context, folderID int64, modSeq uint64) error {
+	var args = m.Called(ctx, folderID, modSeq)
+	return args.Error(0)
+}
+
+func (m *StoragePort) ReconcileFolderUIDs(ctx context.Context, accountID, folderID int64, serverIDs map[uint32]string) (*ports.UIDReconcileResult, error) {
+	var args = m.Called(ctx, accountID, folderID, serverIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ports.UIDReconcileResult), args.Error(1)
+}
+
 // Email operations
 func (m *StoragePort) UpsertEmail(ctx context.Context, accountID, folderID int64, email *ports.EmailContent) (int64, string, error) {
 	var args = m.Called(ctx, accountID, folderID, email)
@@ -71,6 +89,14 @@ func (m *StoragePort) GetEmails(ctx context.Context, folderID int64, limit int)
 	return args.Get(0).([]ports.EmailMetadata), args.Error(1)
 }
 
+func (m *StoragePort) GetUnifiedEmails(ctx context.Context, folderName string, limit int) ([]ports.EmailMetadata, error) {
+	var args = m.Called(ctx, folderName, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]ports.EmailMetadata), args.Error(1)
+}
+
 func (m *StoragePort) GetEmail(ctx context.Context, id int64) (*ports.EmailContent, error) {
 	var args = m.Called(ctx, id)
 	if args.Get(0) == nil {
@@ -108,6 +134,14 @@ func (m *StoragePort) GetAllUIDs(ctx context.Context, folderID int64) ([]uint32,
 	return args.Get(0).([]uint32), args.Error(1)
 }
 
+func (m *StoragePort) GetRecentUIDs(ctx context.Context, accountID, folderID int64, limit int) ([]uint32, error) {
+	var args = m.Called(ctx, accountID, folderID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint32), args.Error(1)
+}
+
 // Email status updates
 func (m *StoragePort) MarkAsRead(ctx context.Context, id int64, read bool) error {
 	var args = m.Called(ctx, id, read)
@@ -134,6 +168,11 @@ func (m *StoragePort) MarkAsReplied(ctx context.Context, id int64, replied bool)
 	return args.Error(0)
 }
 
+func (m *StoragePort) ApplyFlagUpdates(ctx context.Context, accountID, folderID int64, updates []ports.FlagUpdate) (int, error) {
+	var args = m.Called(ctx, accountID, folderID, updates)
+	return args.Int(0), args.Error(1)
+}
+
 // Email content updates
 func (m *StoragePort) UpdateEmailBody(ctx context.Context, id int64, bodyText, bodyHTML string) error {
 	var args = m.Called(ctx, id, bodyText, bodyHTML)
@@ -184,6 +223,91 @@ func (m *StoragePort) DetectAndUpdateThreadID(ctx context.Context, emailID int64
 	return args.Error(0)
 }
 
+func (m *StoragePort) UpdateThreadID(ctx context.Context, emailID int64, threadID string) error {
+	var args = m.Called(ctx, emailID, threadID)
+	return args.Error(0)
+}
+
+// Label operations
+func (m *StoragePort) UpsertLabel(ctx context.Context, accountID int64, label *ports.Label) error {
+	var args = m.Called(ctx, accountID, label)
+	return args.Error(0)
+}
+
+func (m *StoragePort) GetLabels(ctx context.Context, accountID int64) ([]ports.Label, error) {
+	var args = m.Called(ctx, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]ports.Label), args.Error(1)
+}
+
+func (m *StoragePort) DeleteLabel(ctx context.Context, accountID int64, name string) error {
+	var args = m.Called(ctx, accountID, name)
+	return args.Error(0)
+}
+
+func (m *StoragePort) SetMessageLabels(ctx context.Context, accountID int64, messageID string, labels []string) error {
+	var args = m.Called(ctx, accountID, messageID, labels)
+	return args.Error(0)
+}
+
+func (m *StoragePort) AddMessageLabel(ctx context.Context, accountID int64, messageID, label string) error {
+	var args = m.Called(ctx, accountID, messageID, label)
+	return args.Error(0)
+}
+
+func (m *StoragePort) RemoveMessageLabel(ctx context.Context, accountID int64, messageID, label string) error {
+	var args = m.Called(ctx, accountID, messageID, label)
+	return args.Error(0)
+}
+
+func (m *StoragePort) GetLabelsForMessages(ctx context.Context, accountID int64, messageIDs []string) (map[string][]string, error) {
+	var args = m.Called(ctx, accountID, messageIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string][]string), args.Error(1)
+}
+
+func (m *StoragePort) GetEmailsByLabel(ctx context.Context, accountID int64, label string, limit int) ([]ports.EmailMetadata, error) {
+	var args = m.Called(ctx, accountID, label, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]ports.EmailMetadata), args.Error(1)
+}
+
+// Risk operations
+func (m *StoragePort) SaveEmailRisk(ctx context.Context, emailID, accountID int64, risk *ports.RiskInfo) error {
+	var args = m.Called(ctx, emailID, accountID, risk)
+	return args.Error(0)
+}
+
+func (m *StoragePort) GetEmailRisk(ctx context.Context, emailID int64) (*ports.RiskInfo, error) {
+	var args = m.Called(ctx, emailID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ports.RiskInfo), args.Error(1)
+}
+
+func (m *StoragePort) GetRiskForEmails(ctx context.Context, emailIDs []int64) (map[int64]ports.RiskInfo, error) {
+	var args = m.Called(ctx, emailIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int64]ports.RiskInfo), args.Error(1)
+}
+
+func (m *StoragePort) GetSenderKnowledge(ctx context.Context, accountID, emailID int64, fromName, fromEmail string) (*ports.SenderKnowledge, error) {
+	var args = m.Called(ctx, accountID, emailID, fromName, fromEmail)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ports.SenderKnowledge), args.Error(1)
+}
+
 // Draft operations
 func (m *StoragePort) CreateDraft(ctx context.Context, accountID int64, draft *ports.Draft) (*ports.Draft, error) {
 	var args = m.Called(ctx, accountID, draft)
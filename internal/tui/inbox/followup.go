@@ -0,0 +1,42 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Follow-up pelo app: no compose, Ctrl+F escolhe em quanto tempo lembrar se
+// ninguém responder. O follow-up fica preso ao draft e começa a contar
+// quando ele é enviado; sem resposta, a conversa volta para o inbox, vira
+// task e (com compose.follow_up_nudge) ganha um rascunho de cobrança.
+
+// followUpOptions são as opções de follow-up que o Ctrl+F percorre
+var followUpOptions = []time.Duration{0, 24 * time.Hour, 3 * 24 * time.Hour, 7 * 24 * time.Hour}
+
+// nextFollowUp retorna a opção de follow-up seguinte à atual
+func (m Model) nextFollowUp() time.Duration {
+	for i, d := range followUpOptions {
+		if d == m.composeFollowUp {
+			return followUpOptions[(i+1)%len(followUpOptions)]
+		}
+	}
+	return followUpOptions[0]
+}
+
+// followUpLabel descreve um prazo de follow-up ("3 dias")
+func followUpLabel(d time.Duration) string {
+	var days = int(d / (24 * time.Hour))
+	if days == 1 {
+		return "1 dia"
+	}
+	return fmt.Sprintf("%d dias", days)
+}
+
+// setDraftFollowUp coloca o follow-up do compose no draft criado
+func (m Model) setDraftFollowUp(draftID int64) error {
+	if m.app == nil || m.composeFollowUp <= 0 {
+		return nil
+	}
+	return m.app.FollowUps().FollowUpDraft(context.Background(), draftID, m.composeFollowUp)
+}
@@ -61,6 +61,12 @@ func New(account *config.Account, debug bool, app ...ports.App) Model {
 	searchInput.CharLimit = 100
 	searchInput.Width = 40
 
+	// Label input
+	var labelInput = textinput.New()
+	labelInput.Placeholder = "+label, -label ou label"
+	labelInput.CharLimit = 100
+	labelInput.Width = 30
+
 	var s = spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
@@ -94,6 +100,7 @@ func New(account *config.Account, debug bool, app ...ports.App) Model {
 		composeTo:         composeTo,
 		composeSubject:    composeSubject,
 		searchInput:       searchInput,
+		labelInput:        labelInput,
 		debugMode:         debug,
 		debugLogs:         debugLogs,
 		imageCapabilities: &imgCaps,
@@ -156,6 +163,15 @@ func (m Model) loadFolders() tea.Cmd {
 }
 
 func (m Model) syncEmails() tea.Cmd {
+	if m.isUnified() {
+		return m.syncUnified()
+	}
+	if m.isLabelView() {
+		if m.hasLabels() {
+			return m.syncLabels()
+		}
+		return m.loadEmailsFromDB()
+	}
 	return func() tea.Msg {
 		if m.client == nil {
 			return errMsg{err: fmt.Errorf("cliente IMAP não conectado")}
@@ -171,6 +187,17 @@ func (m Model) syncEmails() tea.Cmd {
 			return errMsg{err: fmt.Errorf("erro ao selecionar pasta: %w", err)}
 		}
 
+		// UIDVALIDITY mudou: o SyncService reconcilia por Message-ID (e baixa
+		// os emails que faltam) antes de confiarmos nos UIDs locais. Ele usa a
+		// mesma conexão, onde a pasta acabou de ser selecionada.
+		if m.app != nil {
+			if err := m.app.Sync().CheckUIDValidity(context.Background(), m.currentBox, selectData.UIDValidity); err != nil {
+				storage.LogSyncComplete(syncID, 0, 0, err)
+				return errMsg{err: fmt.Errorf("erro ao reconciliar UIDVALIDITY: %w", err)}
+			}
+			m.dbFolder.UIDValidity = selectData.UIDValidity
+		}
+
 		// Busca último UID que temos no banco
 		var latestUID, _ = storage.GetLatestUID(m.dbAccount.ID, m.dbFolder.ID)
 
@@ -204,6 +231,7 @@ func (m Model) syncEmails() tea.Cmd {
 		}
 
 		// Salva no banco
+		var newIDs []int64
 		for _, email := range emails {
 			var dbEmail = &storage.Email{
 				AccountID:   m.dbAccount.ID,
@@ -218,8 +246,33 @@ func (m Model) syncEmails() tea.Cmd {
 				IsRead:      email.Seen,
 				IsStarred:   email.Flagged,
 				Size:        email.Size,
+				RawHeaders:  email.RawHeaders,
+			}
+			var id, _, upsertErr = storage.UpsertEmail(dbEmail)
+			if upsertErr == nil && id > 0 {
+				newIDs = append(newIDs, id)
+			}
+		}
+
+		// Analisa o risco (SPF/DKIM/DMARC, phishing) dos emails sincronizados
+		if m.app != nil {
+			for _, id := range newIDs {
+				m.app.Risk().Assess(context.Background(), id, nil)
+			}
+		}
+
+		// Aplica regras de filtro apenas aos emails novos da INBOX
+		var filtered int
+		var rulesErr error
+		if latestUID > 0 && m.currentBox == "INBOX" && len(newIDs) > 0 && m.app != nil {
+			var result *ports.RuleRunResult
+			result, rulesErr = m.app.Rules().ApplyToEmails(context.Background(), newIDs)
+			if result != nil {
+				filtered = result.Matched
+				if rulesErr == nil && len(result.Errors) > 0 {
+					rulesErr = result.Errors[0]
+				}
 			}
-			_, _, _ = storage.UpsertEmail(dbEmail)
 		}
 
 		// Detecta emails deletados no servidor
@@ -248,17 +301,32 @@ func (m Model) syncEmails() tea.Cmd {
 			totalInBox = selectData.NumMessages
 		}
 
-		return syncDoneMsg{synced: newCount, total: int(totalInBox), purged: purged, archived: archived}
+		return syncDoneMsg{synced: newCount, total: int(totalInBox), purged: purged, archived: archived, filtered: filtered, rulesErr: rulesErr}
 	}
 }
 
 func (m Model) loadEmailsFromDB() tea.Cmd {
+	var unified = m.isUnified()
+	var label = ""
+	if m.isLabelView() {
+		label = m.currentLabel()
+	}
 	return func() tea.Msg {
+		if unified {
+			var emails, err = storage.GetUnifiedEmails("INBOX", 100, 0)
+			if err != nil {
+				return errMsg{err: err}
+			}
+			return emailsLoadedMsg{emails: withEmailRisk(emails)}
+		}
+		if label != "" {
+			return loadLabelEmails(m.dbAccount.ID, label)
+		}
 		var emails, err = storage.GetEmails(m.dbAccount.ID, m.dbFolder.ID, 100, 0)
 		if err != nil {
 			return errMsg{err: err}
 		}
-		return emailsLoadedMsg{emails: emails}
+		return emailsLoadedMsg{emails: withEmailRisk(withEmailLabels(m.dbAccount.ID, emails))}
 	}
 }
 
@@ -273,7 +341,16 @@ func (m Model) searchDebounce() tea.Cmd {
 
 func (m Model) performSearch(query string) tea.Cmd {
 	var accountID = m.dbAccount.ID
+	// Na caixa unificada a busca cobre todas as contas
+	var accounts []ports.AccountInfo
+	if m.isUnified() {
+		accounts = m.app.GetAllAccounts()
+	}
 	return func() tea.Msg {
+		if len(accounts) > 0 {
+			var results, err = searchAllAccounts(accounts, query, 100)
+			return searchResultsMsg{results: results, query: query, err: err}
+		}
 		var results, err = storage.FuzzySearchEmails(accountID, query, 100)
 		return searchResultsMsg{results: results, query: query, err: err}
 	}
@@ -315,6 +392,9 @@ func (m Model) loadSettingsFolders() tea.Cmd {
 		// Build folder list with selection state
 		var folders []SettingsFolder
 		for _, mb := range mailboxes {
+			if isVirtualBox(mb.Name) {
+				continue
+			}
 			var selected = false
 			for _, sf := range syncFolders {
 				if sf == mb.Name {
@@ -349,6 +429,137 @@ func (m Model) saveSettingsFolders() tea.Cmd {
 	}
 }
 
+// === RULES COMMANDS ===
+
+// rulesFilePath é o arquivo usado para importar/exportar regras em texto
+func rulesFilePath() string {
+	return filepath.Join(config.GetConfigPath(), "rules.txt")
+}
+
+func (m Model) loadRules() tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		if app == nil {
+			return rulesLoadedMsg{err: fmt.Errorf("app not available")}
+		}
+		var list, err = app.Rules().GetRules(context.Background())
+		return rulesLoadedMsg{rules: list, err: err}
+	}
+}
+
+func (m Model) toggleRule(rule ports.Rule) tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		var err = app.Rules().SetRuleEnabled(context.Background(), rule.ID, !rule.Enabled)
+		var state = "ativada"
+		if rule.Enabled {
+			state = "desativada"
+		}
+		return rulesActionMsg{message: fmt.Sprintf("Regra '%s' %s", rule.Name, state), err: err}
+	}
+}
+
+func (m Model) deleteRule(rule ports.Rule) tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		var err = app.Rules().DeleteRule(context.Background(), rule.ID)
+		return rulesActionMsg{message: fmt.Sprintf("Regra '%s' removida", rule.Name), err: err}
+	}
+}
+
+// importRules importa rules.txt; replace apaga as regras atuais antes
+func (m Model) importRules(replace bool) tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		var path = rulesFilePath()
+		var data, err = os.ReadFile(path)
+		if err != nil {
+			return rulesActionMsg{err: fmt.Errorf("erro ao ler %s: %w", path, err)}
+		}
+		var list, importErr = app.Rules().ImportRules(context.Background(), string(data), replace)
+		if importErr != nil {
+			return rulesActionMsg{err: importErr}
+		}
+		return rulesActionMsg{message: fmt.Sprintf("%d regras importadas de %s", len(list), path)}
+	}
+}
+
+func (m Model) exportRules() tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		var text, err = app.Rules().ExportRules(context.Background())
+		if err != nil {
+			return rulesActionMsg{err: err}
+		}
+		var path = rulesFilePath()
+		if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+			return rulesActionMsg{err: fmt.Errorf("erro ao salvar %s: %w", path, err)}
+		}
+		return rulesActionMsg{message: "Regras exportadas para " + path}
+	}
+}
+
+func (m Model) runRulesOnInbox() tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		var result, err = app.Rules().RunRules(context.Background(), "INBOX", 200)
+		if err != nil {
+			return rulesActionMsg{err: err}
+		}
+		var message = fmt.Sprintf("Regras aplicadas: %d de %d emails, %d ações", result.Matched, result.Checked, result.Actions)
+		if len(result.Errors) > 0 {
+			return rulesActionMsg{message: message, err: result.Errors[0]}
+		}
+		return rulesActionMsg{message: message}
+	}
+}
+
+// === CONTACTS COMMANDS ===
+
+// contactsFilePath é o arquivo usado para importar/exportar contatos:
+// contacts.vcf, ou contacts.csv (Google ou Outlook) na importação
+func contactsFilePath(extension string) string {
+	return filepath.Join(config.GetConfigPath(), "contacts"+extension)
+}
+
+func (m Model) importContacts() tea.Cmd {
+	var app = m.app
+	var accountID = m.dbAccount.ID
+	return func() tea.Msg {
+		var path = contactsFilePath(".vcf")
+		var data, err = os.ReadFile(path)
+		if os.IsNotExist(err) {
+			path = contactsFilePath(".csv")
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return contactsActionMsg{err: fmt.Errorf("erro ao ler %s: %w", path, err)}
+		}
+		var result, importErr = app.Contacts().ImportContacts(context.Background(), accountID, data, "")
+		if importErr != nil {
+			return contactsActionMsg{err: importErr}
+		}
+		return contactsActionMsg{message: fmt.Sprintf("Contatos de %s: %d novos, %d mesclados, %d ignorados",
+			path, result.Created, result.Merged, result.Skipped)}
+	}
+}
+
+func (m Model) exportContacts() tea.Cmd {
+	var app = m.app
+	var accountID = m.dbAccount.ID
+	return func() tea.Msg {
+		var data, err = app.Contacts().ExportContacts(context.Background(), accountID, ports.ContactFormatVCard)
+		if err != nil {
+			return contactsActionMsg{err: err}
+		}
+		var path = contactsFilePath(".vcf")
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return contactsActionMsg{err: fmt.Errorf("erro ao salvar %s: %w", path, err)}
+		}
+		return contactsActionMsg{message: "Contatos exportados para " + path}
+	}
+}
+
 // === ACCOUNT SWITCH COMMAND ===
 
 func (m Model) switchAccount(email string) tea.Cmd {
@@ -473,7 +684,7 @@ func (m Model) indexNextBatch() tea.Cmd {
 
 	var accountID = m.dbAccount.ID
 	var client = m.client
-	var currentBox = m.currentBox
+	var currentBox = m.imapBox()
 
 	return func() tea.Msg {
 		// Busca emails para indexar (em lote pequeno para não travar)
@@ -533,12 +744,24 @@ func (m Model) loadEmailForAI() tea.Cmd {
 
 		var email = m.emails[m.selectedEmail]
 
+		if m.isOtherAccount(email) {
+			var content, err = m.app.Email().GetEmail(context.Background(), email.ID)
+			if err != nil {
+				return aiEmailContextMsg{email: &email, content: "", err: nil}
+			}
+			var textContent = content.BodyText
+			if textContent == "" && content.BodyHTML != "" {
+				textContent = htmlToText(content.BodyHTML)
+			}
+			return aiEmailContextMsg{email: &email, content: textContent, err: nil}
+		}
+
 		if m.client == nil {
 			return aiEmailContextMsg{email: &email, content: "", err: nil}
 		}
 
 		// Seleciona a mailbox antes de buscar
-		if _, err := m.client.SelectMailbox(m.currentBox); err != nil {
+		if _, err := m.client.SelectMailbox(m.emailBox(email)); err != nil {
 			return aiEmailContextMsg{email: &email, content: "", err: nil}
 		}
 
@@ -560,59 +783,69 @@ func (m Model) loadEmailForAI() tea.Cmd {
 	}
 }
 
+// aiListContextLimit é quantos emails da lista vão de contexto nas perguntas gerais
+const aiListContextLimit = 50
+
 func (m Model) runAI(prompt string) tea.Cmd {
 	// Copia contexto para a goroutine
 	var emailContext = m.aiEmailContext
 	var emailBody = m.aiEmailBody
+	var app = m.app
+	var header = fmt.Sprintf("[Conta: %s | Pasta: %s]", m.account.Email, m.currentBox)
+	var listed = m.emails[:min(len(m.emails), aiListContextLimit)]
 
-	return func() tea.Msg {
-		var fullPrompt string
+	var fullPrompt string
+	if emailContext != nil {
+		// Prompt com contexto do email selecionado
+		fullPrompt = fmt.Sprintf(`%s
 
-		if emailContext != nil {
-			// Prompt com contexto do email selecionado
-			var emailInfo = fmt.Sprintf(`[Email selecionado]
+[Email selecionado]
 De: %s <%s>
 Assunto: %s
 Data: %s
 ---
 %s
----`, emailContext.FromName, emailContext.FromEmail, emailContext.Subject, emailContext.Date.Time.Format("02/01/2006 15:04"), emailBody)
-
-			fullPrompt = fmt.Sprintf(`[Context: Email database at ~/.config/miau/data/miau.db | Account: %s | Folder: %s]
-[Schema: emails(id, subject, from_name, from_email, date, is_read, is_starred, is_deleted, body_text)]
-[Use sqlite3 to query. Be concise.]
-
-%s
+---
 
 Pergunta do usuário sobre este email:
-%s`, m.account.Email, m.currentBox, emailInfo, prompt)
-		} else {
-			// Prompt geral (sem contexto de email específico)
-			fullPrompt = fmt.Sprintf(`[Context: Email database at ~/.config/miau/data/miau.db | Account: %s | Folder: %s]
-[Schema: emails(id, subject, from_name, from_email, date, is_read, is_starred, is_deleted, body_text)]
-[Use sqlite3 to query. Be concise.]
-
-%s`, m.account.Email, m.currentBox, prompt)
+%s`, header, emailContext.FromName, emailContext.FromEmail, emailContext.Subject,
+			emailContext.Date.Time.Format("02/01/2006 15:04"), emailBody, prompt)
+	} else {
+		// Prompt geral: os emails da lista atual servem de contexto
+		var list strings.Builder
+		for _, e := range listed {
+			var flags = ""
+			if !e.IsRead {
+				flags += " [não lido]"
+			}
+			if e.IsStarred {
+				flags += " [estrela]"
+			}
+			fmt.Fprintf(&list, "- %s | %s <%s> | %s%s\n",
+				e.Date.Time.Format("02/01/2006 15:04"), e.FromName, e.FromEmail, e.Subject, flags)
 		}
+		fullPrompt = fmt.Sprintf(`%s
 
-		// Debug: salva o comando em arquivo de log
-		var logFile, _ = exec.Command("sh", "-c", "echo '['+$(date)+'] Running claude -p ...' >> /tmp/miau-ai.log").Output()
-		_ = logFile
-
-		var cmd = exec.Command("claude", "-p", "--permission-mode", "bypassPermissions", fullPrompt)
-
-		// Log do prompt
-		exec.Command("sh", "-c", fmt.Sprintf("echo 'Prompt: %s' >> /tmp/miau-ai.log", prompt)).Run()
-
-		var output, err = cmd.CombinedOutput()
+[Emails mais recentes da pasta]
+%s
+Pergunta do usuário:
+%s`, header, list.String(), prompt)
+	}
 
-		// Log do resultado
-		exec.Command("sh", "-c", fmt.Sprintf("echo 'Output (%d bytes): %s' >> /tmp/miau-ai.log", len(output), string(output[:min(100, len(output))]))).Run()
+	return func() tea.Msg {
+		if app == nil {
+			return aiResponseMsg{err: fmt.Errorf("application not initialized")}
+		}
+		var aiService = app.AI()
+		if aiService == nil {
+			return aiResponseMsg{err: fmt.Errorf("AI service not available")}
+		}
 
+		var response, err = aiService.Ask(context.Background(), "", fullPrompt)
 		if err != nil {
-			return aiResponseMsg{err: fmt.Errorf("%s: %w", string(output), err)}
+			return aiResponseMsg{err: err}
 		}
-		return aiResponseMsg{response: string(output)}
+		return aiResponseMsg{response: response}
 	}
 }
 
@@ -707,12 +940,23 @@ func (m Model) saveConfig() tea.Cmd {
 }
 
 func (m Model) markAsRead(emailID int64, uid uint32) tea.Cmd {
+	var otherAccount = m.isOtherAccountID(emailID)
+	var folder = m.emailFolder(emailID)
 	return func() tea.Msg {
+		// Email de outra conta: marca pela conexão da conta dele
+		if otherAccount {
+			m.app.Email().MarkAsRead(context.Background(), emailID, true)
+			return markReadMsg{emailID: emailID, uid: uid}
+		}
+
 		// Marca no banco local
 		storage.MarkAsRead(emailID, true)
 
-		// Marca no servidor IMAP
+		// Marca no servidor IMAP (num label, o UID é da pasta do email)
 		if m.client != nil {
+			if folder != "" {
+				m.client.SelectMailbox(folder)
+			}
 			m.client.MarkAsRead(uid)
 		}
 
@@ -726,6 +970,9 @@ func (m Model) sendEmail() tea.Cmd {
 		var subject = strings.TrimSpace(m.composeSubject.Value())
 		var body = m.composeBodyText
 
+		// Respostas saem pela conta do email original (caixa unificada)
+		var account = m.sendingAccount()
+
 		// Carrega config para verificar formato e assinatura
 		var cfg, _ = config.Load()
 		var useHTML = cfg == nil || cfg.Compose.Format != "plain"
@@ -739,10 +986,10 @@ func (m Model) sendEmail() tea.Cmd {
 			emailBody += "</div>"
 
 			// Adiciona assinatura HTML se configurada
-			if m.account.Signature != nil && m.account.Signature.Enabled && m.account.Signature.HTML != "" {
+			if account.Signature != nil && account.Signature.Enabled && account.Signature.HTML != "" {
 				emailBody += "<br><br>"
 				emailBody += "<div style=\"border-top: 1px solid #ccc; padding-top: 10px; margin-top: 10px;\">"
-				emailBody += m.account.Signature.HTML
+				emailBody += account.Signature.HTML
 				emailBody += "</div>"
 			}
 
@@ -752,9 +999,9 @@ func (m Model) sendEmail() tea.Cmd {
 			emailBody = body
 
 			// Adiciona assinatura de texto se configurada
-			if m.account.Signature != nil && m.account.Signature.Enabled && m.account.Signature.Text != "" {
+			if account.Signature != nil && account.Signature.Enabled && account.Signature.Text != "" {
 				emailBody += "\n\n--\n"
-				emailBody += m.account.Signature.Text
+				emailBody += account.Signature.Text
 			}
 		}
 
@@ -765,13 +1012,25 @@ func (m Model) sendEmail() tea.Cmd {
 			references = inReplyTo
 		}
 
+		// S/MIME ou PGP/MIME conforme a config da conta (sign / encrypt)
+		var protect, errProtect = m.protector(account, []string{to})
+		if errProtect != nil {
+			return emailSentMsg{err: errProtect, to: to}
+		}
+
 		// Verifica se deve usar Gmail API
-		if m.account.SendMethod == config.SendMethodGmailAPI && m.account.OAuth2 != nil {
-			return m.sendViaGmailAPI(to, subject, emailBody, useHTML, inReplyTo, references)
+		if account.SendMethod == config.SendMethodGmailAPI && account.OAuth2 != nil {
+			var sent = m.sendViaGmailAPI(account, to, subject, emailBody, useHTML, inReplyTo, references, protect)
+			if failed, ok := sent.(emailSentMsg); ok && failed.err != nil {
+				if queued := m.queueOffline(account, m.offlineRequest(to, subject, body, emailBody, useHTML, inReplyTo, references), "gmail_api", failed.err); queued != nil {
+					return queued
+				}
+			}
+			return sent
 		}
 
 		// Fallback para SMTP
-		var client = smtp.NewClient(m.account)
+		var client = smtp.NewClient(account)
 		var email = &smtp.Email{
 			To:             []string{to},
 			Subject:        subject,
@@ -780,10 +1039,15 @@ func (m Model) sendEmail() tea.Cmd {
 			IsHTML:         useHTML,
 			InReplyTo:      inReplyTo,
 			References:     references,
+			Protect:        protect,
 		}
 
 		var result, err = client.Send(email)
 		if err != nil {
+			// Sem conexão: vai para a outbox e sai quando o servidor voltar
+			if queued := m.queueOffline(account, m.offlineRequest(to, subject, body, emailBody, useHTML, inReplyTo, references), "smtp", err); queued != nil {
+				return queued
+			}
 			return emailSentMsg{err: err, to: to, backend: "smtp"}
 		}
 
@@ -797,16 +1061,16 @@ func (m Model) sendEmail() tea.Cmd {
 	}
 }
 
-func (m Model) sendViaGmailAPI(to, subject, body string, isHTML bool, inReplyTo, references string) tea.Msg {
-	var tokenPath = auth.GetTokenPath(config.GetConfigPath(), m.account.Email)
-	var oauthCfg = auth.GetOAuth2Config(m.account.OAuth2.ClientID, m.account.OAuth2.ClientSecret)
+func (m Model) sendViaGmailAPI(account *config.Account, to, subject, body string, isHTML bool, inReplyTo, references string, protect func([]byte) ([]byte, error)) tea.Msg {
+	var tokenPath = auth.GetTokenPath(config.GetConfigPath(), account.Email)
+	var oauthCfg = auth.GetOAuth2Config(account.OAuth2.ClientID, account.OAuth2.ClientSecret)
 
 	var token, err = auth.GetValidToken(oauthCfg, tokenPath)
 	if err != nil {
 		return emailSentMsg{err: fmt.Errorf("erro ao obter token OAuth2: %w", err), to: to, backend: "gmail_api"}
 	}
 
-	var client = gmail.NewClient(token, oauthCfg, m.account.Email)
+	var client = gmail.NewClient(token, oauthCfg, account.Email)
 
 	// Monta request
 	var req = &gmail.SendRequest{
@@ -816,6 +1080,7 @@ func (m Model) sendViaGmailAPI(to, subject, body string, isHTML bool, inReplyTo,
 		IsHTML:     isHTML,
 		InReplyTo:  inReplyTo,
 		References: references,
+		Protect:    protect,
 	}
 
 	// Adiciona classification se houver (índice 0 = sem classificação)
@@ -837,6 +1102,20 @@ func (m Model) sendViaGmailAPI(to, subject, body string, isHTML bool, inReplyTo,
 	}
 }
 
+// protector retorna a transformação de um envio da conta: S/MIME se a conta
+// tiver identidade S/MIME, senão PGP/MIME (assinatura, criptografia e header
+// Autocrypt), ou nil se não houver
+func (m Model) protector(account *config.Account, recipients []string) (func([]byte) ([]byte, error), error) {
+	if m.app == nil {
+		return nil, nil
+	}
+	var protect, err = m.app.SMIME().Protector(context.Background(), account.Email, recipients, false, false)
+	if err != nil || protect != nil {
+		return protect, err
+	}
+	return m.app.PGP().Protector(context.Background(), account.Email, recipients, false, false)
+}
+
 // checkForBounces verifica se há mensagens de bounce para emails enviados recentemente
 func (m Model) checkForBounces() tea.Cmd {
 	// Copia dados necessários para a goroutine
@@ -1094,6 +1373,36 @@ func scheduleAutoRefresh() tea.Cmd {
 	})
 }
 
+// startIdle inicia o push sync (IMAP IDLE) no SyncService e encaminha os
+// syncs da INBOX para o canal idleEvents
+func (m Model) startIdle() tea.Cmd {
+	var app = m.app
+	var events = m.idleEvents
+	return func() tea.Msg {
+		app.Events().Subscribe(ports.EventTypeSyncCompleted, func(evt ports.Event) {
+			var e, ok = evt.(ports.SyncCompletedEvent)
+			if !ok || !strings.EqualFold(e.Folder, "INBOX") || e.Result == nil {
+				return
+			}
+			if e.Result.NewEmails == 0 && e.Result.DeletedEmails == 0 && e.Result.FlagUpdates == 0 {
+				return
+			}
+			select {
+			case events <- idleSyncMsg{accountID: e.AccountID, newEmails: e.Result.NewEmails, deleted: e.Result.DeletedEmails, flagUpdates: e.Result.FlagUpdates}:
+			default:
+			}
+		})
+		return idleStartedMsg{err: app.Sync().StartIdle(context.Background())}
+	}
+}
+
+// waitForIdleSync aguarda o próximo sync disparado pelo IDLE
+func waitForIdleSync(events chan idleSyncMsg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
 // loadDrafts carrega drafts pendentes do banco
 func (m Model) loadDrafts() tea.Cmd {
 	var accountID int64
@@ -1111,7 +1420,14 @@ func (m Model) loadDrafts() tea.Cmd {
 
 // archiveEmail arquiva um email (local + servidor)
 func (m Model) archiveEmail(emailID int64, uid uint32, messageID string) tea.Cmd {
+	var otherAccount = m.isOtherAccountID(emailID)
+	var folder = m.emailFolder(emailID)
 	return func() tea.Msg {
+		// Email de outra conta: arquiva pela conexão da conta dele
+		if otherAccount {
+			return emailArchivedMsg{emailID: emailID, err: m.app.Email().Archive(context.Background(), emailID)}
+		}
+
 		// 1. Marca como arquivado no banco local
 		if err := storage.MarkAsArchived(emailID, true); err != nil {
 			return emailArchivedMsg{emailID: emailID, err: err}
@@ -1139,6 +1455,9 @@ func (m Model) archiveEmail(emailID int64, uid uint32, messageID string) tea.Cmd
 		// Fallback para IMAP se Gmail API não disponível ou falhou
 		if serverErr != nil || m.account.AuthType != config.AuthTypeOAuth2 {
 			if m.client != nil {
+				if folder != "" {
+					m.client.SelectMailbox(folder)
+				}
 				serverErr = m.client.ArchiveEmail(uid)
 			}
 		}
@@ -1149,7 +1468,14 @@ func (m Model) archiveEmail(emailID int64, uid uint32, messageID string) tea.Cmd
 
 // deleteEmail deleta um email (move para lixeira - local + servidor)
 func (m Model) deleteEmail(emailID int64, uid uint32, messageID string) tea.Cmd {
+	var otherAccount = m.isOtherAccountID(emailID)
+	var folder = m.emailFolder(emailID)
 	return func() tea.Msg {
+		// Email de outra conta: move para lixeira pela conexão da conta dele
+		if otherAccount {
+			return emailDeletedMsg{emailID: emailID, err: m.app.Email().Delete(context.Background(), emailID)}
+		}
+
 		// 1. Marca como deletado no banco local
 		if err := storage.DeleteEmail(emailID); err != nil {
 			return emailDeletedMsg{emailID: emailID, err: err}
@@ -1177,6 +1503,9 @@ func (m Model) deleteEmail(emailID int64, uid uint32, messageID string) tea.Cmd
 		// Fallback para IMAP se Gmail API não disponível ou falhou
 		if serverErr != nil || m.account.AuthType != config.AuthTypeOAuth2 {
 			if m.client != nil {
+				if folder != "" {
+					m.client.SelectMailbox(folder)
+				}
 				var trashFolder = m.client.GetTrashFolder()
 				serverErr = m.client.TrashEmail(uid, trashFolder)
 			}
@@ -1313,8 +1642,20 @@ func (m Model) createScheduledDraft() tea.Cmd {
 			GenerationSource: "manual",
 		}
 
+		// Com o app, o SendService segura o email na outbox pela janela de
+		// desfazer envio e o envia em background (mesmo após reiniciar)
+		if m.app != nil && m.app.Outbox() != nil {
+			draft.Status = storage.DraftStatusDraft
+			var draftID, errCreate = storage.CreateDraft(draft)
+			if errCreate != nil {
+				return draftCreatedMsg{err: errCreate}
+			}
+			draft.ID = draftID
+			return m.holdDraft(draft)
+		}
+
 		// Calcula tempo de envio
-		var delay = time.Duration(cfg.Compose.SendDelaySeconds) * time.Second
+		var delay = cfg.Compose.SendDelay()
 		var sendAt = time.Now().Add(delay)
 		draft.ScheduledSendAt = sql.NullTime{Time: sendAt, Valid: true}
 
@@ -1408,6 +1749,13 @@ func (m Model) sendDraft(draftID int64) tea.Cmd {
 		// Marca como enviando
 		storage.MarkDraftSending(draftID)
 
+		// S/MIME ou PGP/MIME conforme a config da conta
+		var protect, errProtect = m.protector(m.account, []string{draft.ToAddresses})
+		if errProtect != nil {
+			storage.MarkDraftFailed(draftID, errProtect.Error())
+			return draftSentMsg{draftID: draftID, err: errProtect}
+		}
+
 		// Determina backend e envia
 		var backend = "smtp"
 		if m.account.SendMethod == config.SendMethodGmailAPI {
@@ -1416,6 +1764,9 @@ func (m Model) sendDraft(draftID int64) tea.Cmd {
 			var oauthCfg = auth.GetOAuth2Config(m.account.OAuth2.ClientID, m.account.OAuth2.ClientSecret)
 			var token, err = auth.GetValidToken(oauthCfg, tokenPath)
 			if err != nil {
+				if queued := m.queueDraftOffline(draft, "gmail_api", err); queued != nil {
+					return queued
+				}
 				storage.MarkDraftFailed(draftID, err.Error())
 				return draftSentMsg{draftID: draftID, err: err}
 			}
@@ -1428,6 +1779,7 @@ func (m Model) sendDraft(draftID int64) tea.Cmd {
 				InReplyTo:  draft.InReplyTo.String,
 				References: draft.ReferenceIDs.String,
 				IsHTML:     draft.BodyHTML.Valid && draft.BodyHTML.String != "",
+				Protect:    protect,
 			}
 			if draft.BodyHTML.Valid && draft.BodyHTML.String != "" {
 				req.Body = draft.BodyHTML.String
@@ -1435,6 +1787,9 @@ func (m Model) sendDraft(draftID int64) tea.Cmd {
 
 			var _, err2 = client.SendMessage(req)
 			if err2 != nil {
+				if queued := m.queueDraftOffline(draft, "gmail_api", err2); queued != nil {
+					return queued
+				}
 				storage.MarkDraftFailed(draftID, err2.Error())
 				return draftSentMsg{draftID: draftID, err: err2}
 			}
@@ -1450,6 +1805,7 @@ func (m Model) sendDraft(draftID int64) tea.Cmd {
 				References:     draft.ReferenceIDs.String,
 				Classification: draft.Classification.String,
 				IsHTML:         draft.BodyHTML.Valid && draft.BodyHTML.String != "",
+				Protect:        protect,
 			}
 			if draft.BodyHTML.Valid && draft.BodyHTML.String != "" {
 				email.Body = draft.BodyHTML.String
@@ -1457,6 +1813,9 @@ func (m Model) sendDraft(draftID int64) tea.Cmd {
 
 			var _, err = smtpClient.Send(&email)
 			if err != nil {
+				if queued := m.queueDraftOffline(draft, "smtp", err); queued != nil {
+					return queued
+				}
 				storage.MarkDraftFailed(draftID, err.Error())
 				return draftSentMsg{draftID: draftID, err: err}
 			}
@@ -1499,23 +1858,33 @@ func (m Model) openEmailHTML() tea.Cmd {
 
 		var email = m.emails[m.selectedEmail]
 
-		// Tenta buscar do servidor IMAP
-		if m.client == nil {
-			return htmlOpenedMsg{err: fmt.Errorf("não conectado ao servidor")}
-		}
+		var htmlContent string
+		if m.isOtherAccount(email) {
+			// Email de outra conta: busca pela conexão da conta dele
+			var content, err = m.app.Email().GetEmail(context.Background(), email.ID)
+			if err != nil {
+				return htmlOpenedMsg{err: err}
+			}
+			htmlContent = content.BodyHTML
+		} else {
+			// Tenta buscar do servidor IMAP
+			if m.client == nil {
+				return htmlOpenedMsg{err: fmt.Errorf("não conectado ao servidor")}
+			}
 
-		// Seleciona a mailbox antes de buscar
-		if _, err := m.client.SelectMailbox(m.currentBox); err != nil {
-			return htmlOpenedMsg{err: fmt.Errorf("erro ao selecionar pasta: %w", err)}
-		}
+			// Seleciona a mailbox antes de buscar
+			if _, err := m.client.SelectMailbox(m.emailBox(email)); err != nil {
+				return htmlOpenedMsg{err: fmt.Errorf("erro ao selecionar pasta: %w", err)}
+			}
 
-		var rawData, err = m.client.FetchEmailRaw(email.UID)
-		if err != nil {
-			return htmlOpenedMsg{err: err}
-		}
+			var rawData, err = m.client.FetchEmailRaw(email.UID)
+			if err != nil {
+				return htmlOpenedMsg{err: err}
+			}
 
-		// Parseia o email para extrair HTML
-		var htmlContent = extractHTML(rawData)
+			// Parseia o email para extrair HTML
+			htmlContent = extractHTML(rawData)
+		}
 		if htmlContent == "" {
 			return htmlOpenedMsg{err: fmt.Errorf("email não contém HTML")}
 		}
@@ -1562,6 +1931,20 @@ func (m Model) loadImageAttachments() tea.Cmd {
 		}
 	}
 
+	// Email de outra conta: anexos vêm pela conexão da conta dele
+	if emailID := m.otherAccountEmailID(); emailID != 0 {
+		return func() tea.Msg {
+			var attachments, err = m.loadOtherAccountAttachments(emailID)
+			var images []Attachment
+			for _, att := range attachments {
+				if strings.HasPrefix(att.ContentType, "image/") {
+					images = append(images, att)
+				}
+			}
+			return imageAttachmentsMsg{attachments: images, err: err}
+		}
+	}
+
 	if m.client == nil {
 		return func() tea.Msg {
 			return imageAttachmentsMsg{err: fmt.Errorf("não conectado ao servidor")}
@@ -1569,7 +1952,7 @@ func (m Model) loadImageAttachments() tea.Cmd {
 	}
 
 	var client = m.client
-	var currentBox = m.currentBox
+	var currentBox = m.selectedEmailBox()
 
 	return func() tea.Msg {
 		// Seleciona a mailbox antes de buscar
@@ -1610,6 +1993,14 @@ func (m Model) loadAllAttachments() tea.Cmd {
 		}
 	}
 
+	// Email de outra conta: anexos vêm pela conexão da conta dele
+	if emailID := m.otherAccountEmailID(); emailID != 0 {
+		return func() tea.Msg {
+			var attachments, err = m.loadOtherAccountAttachments(emailID)
+			return allAttachmentsMsg{attachments: attachments, err: err}
+		}
+	}
+
 	if m.client == nil {
 		return func() tea.Msg {
 			return allAttachmentsMsg{err: fmt.Errorf("não conectado ao servidor IMAP")}
@@ -1617,7 +2008,7 @@ func (m Model) loadAllAttachments() tea.Cmd {
 	}
 
 	var client = m.client
-	var currentBox = m.currentBox
+	var currentBox = m.selectedEmailBox()
 
 	return func() tea.Msg {
 		// Seleciona a mailbox antes de buscar
@@ -1819,12 +2210,16 @@ func (m Model) loadEmailContent() tea.Cmd {
 
 		var email = m.emails[m.selectedEmail]
 
+		if m.isOtherAccount(email) {
+			return m.loadOtherAccountContent(email)
+		}
+
 		if m.client == nil {
 			return emailContentMsg{err: fmt.Errorf("não conectado ao servidor")}
 		}
 
 		// Seleciona a mailbox antes de buscar
-		if _, err := m.client.SelectMailbox(m.currentBox); err != nil {
+		if _, err := m.client.SelectMailbox(m.emailBox(email)); err != nil {
 			return emailContentMsg{err: fmt.Errorf("erro ao selecionar pasta: %w", err)}
 		}
 
@@ -1833,6 +2228,28 @@ func (m Model) loadEmailContent() tea.Cmd {
 			return emailContentMsg{err: err}
 		}
 
+		// Análise de risco com o email completo (verifica o DKIM), antes de descriptografar
+		var risk *ports.RiskInfo
+		if m.app != nil {
+			risk, _ = m.app.Risk().Assess(context.Background(), email.ID, rawData)
+		}
+
+		// S/MIME e PGP/MIME: descriptografa e verifica a assinatura antes de extrair o texto
+		var security *ports.SecurityInfo
+		if m.app != nil {
+			if opened, err := m.app.SMIME().Open(context.Background(), rawData); err == nil {
+				rawData, security = opened.Raw, opened.Security
+			}
+			if security == nil {
+				if opened, err := m.app.PGP().Open(context.Background(), rawData); err == nil {
+					rawData, security = opened.Raw, opened.Security
+				}
+			}
+		}
+
+		// Convite de reunião: importa para o calendário e mostra o cartão
+		var invite = m.loadInvite(email.ID, rawData)
+
 		// Tenta extrair texto plain primeiro, depois HTML convertido
 		var textContent = extractText(rawData)
 		if textContent == "" {
@@ -1842,7 +2259,7 @@ func (m Model) loadEmailContent() tea.Cmd {
 			}
 		}
 
-		if textContent == "" {
+		if textContent == "" && invite == nil {
 			return emailContentMsg{err: fmt.Errorf("email sem conteúdo de texto")}
 		}
 
@@ -1854,7 +2271,7 @@ func (m Model) loadEmailContent() tea.Cmd {
 			}
 		}
 
-		return emailContentMsg{content: textContent}
+		return emailContentMsg{content: textContent, security: security, risk: risk, invite: invite}
 	}
 }
 
@@ -1897,12 +2314,23 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.String() {
 			case "enter":
 				// Cancela o envio - volta para draft
-				storage.CancelDraft(m.scheduledDraft.ID)
+				if m.holding() {
+					// Segurado na outbox: só cancela se o envio ainda não começou
+					if err := m.app.Send().CancelPendingSend(context.Background(), m.scheduledDraft.ID); err != nil {
+						m.log("❌ Não foi possível cancelar o envio: %v", err)
+						m.aiResponse = errorStyle.Render("Tarde demais para cancelar: " + err.Error())
+						m.showUndoOverlay = false
+						m.scheduledDraft = nil
+						return m, m.loadOutbox()
+					}
+				} else {
+					storage.CancelDraft(m.scheduledDraft.ID)
+				}
 				m.log("🚫 Envio cancelado, draft salvo")
 				m.aiResponse = infoStyle.Render("📝 Envio cancelado. Draft salvo.")
 				m.showUndoOverlay = false
 				m.scheduledDraft = nil
-				return m, m.loadDrafts()
+				return m, tea.Batch(m.loadDrafts(), m.loadOutbox())
 			case "esc":
 				// Fecha overlay mas continua o envio
 				m.showUndoOverlay = false
@@ -1915,6 +2343,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Verifica drafts panel
 		if m.showDrafts {
+			// Emails da outbox ficam abaixo dos drafts
+			if item := m.selectedOutboxItem(); item != nil && m.app != nil {
+				switch msg.String() {
+				case "e":
+					return m, m.outboxAction(item.ID, "edit")
+				case "s", "r":
+					return m, m.outboxAction(item.ID, "retry")
+				case "x":
+					return m, m.outboxAction(item.ID, "cancel")
+				}
+			}
 			switch msg.String() {
 			case "esc", "d":
 				m.showDrafts = false
@@ -1925,13 +2364,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			case "down", "j":
-				if m.selectedDraft < len(m.drafts)-1 {
+				if m.selectedDraft < len(m.drafts)+len(m.outbox)-1 {
 					m.selectedDraft++
 				}
 				return m, nil
 			case "e":
 				// Editar draft
-				if len(m.drafts) > 0 {
+				if m.selectedDraft < len(m.drafts) {
 					var draft = m.drafts[m.selectedDraft]
 					m.showDrafts = false
 					m.showCompose = true
@@ -1944,11 +2383,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			case "s":
 				// Enviar draft (agenda com delay)
-				if len(m.drafts) > 0 {
+				if m.selectedDraft < len(m.drafts) {
 					var draft = m.drafts[m.selectedDraft]
 					if draft.Status == storage.DraftStatusDraft {
+						if m.app != nil && m.app.Outbox() != nil {
+							m.log("📤 Draft #%d enviado para a outbox", draft.ID)
+							return m, func() tea.Msg { return m.holdDraft(&draft) }
+						}
 						var cfg, _ = config.Load()
-						var delay = time.Duration(cfg.Compose.SendDelaySeconds) * time.Second
+						var delay = cfg.Compose.SendDelay()
 						storage.ScheduleDraft(draft.ID, time.Now().Add(delay))
 						m.log("📤 Draft #%d agendado para envio", draft.ID)
 						// Recarrega draft para obter dados atualizados
@@ -1962,7 +2405,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			case "x":
 				// Cancelar/deletar draft (move para histórico permanente)
-				if len(m.drafts) > 0 {
+				if m.selectedDraft < len(m.drafts) {
 					var draft = m.drafts[m.selectedDraft]
 					storage.ArchiveDraftPermanently(draft.ID, "deleted")
 					m.log("🗑️ Draft #%d arquivado no histórico", draft.ID)
@@ -2141,6 +2584,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Settings mode
 		if m.showSettings {
+			// Confirmação de substituir todas as regras (I): só "y" confirma
+			if m.rulesConfirmReplace {
+				m.rulesConfirmReplace = false
+				if msg.String() == "y" {
+					m.log("📜 Substituindo regras por %s...", rulesFilePath())
+					return m, m.importRules(true)
+				}
+				m.log("📜 Importação cancelada")
+				return m, nil
+			}
 			switch msg.String() {
 			case "ctrl+c":
 				return m, tea.Quit
@@ -2152,12 +2605,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			case "tab", "right", "l":
 				// Next tab
-				m.settingsTab = (m.settingsTab + 1) % 4 // 4 tabs: Folders, Sync, Indexer, About
+				m.settingsTab = (m.settingsTab + 1) % 6 // 6 tabs: Folders, Sync, Indexer, Rules, Contacts, About
 				m.settingsSelection = 0
 				return m, nil
 			case "shift+tab", "left", "h":
 				// Previous tab
-				m.settingsTab = (m.settingsTab + 3) % 4 // +3 same as -1 mod 4
+				m.settingsTab = (m.settingsTab + 5) % 6 // +5 same as -1 mod 6
 				m.settingsSelection = 0
 				return m, nil
 			case "up", "k":
@@ -2165,7 +2618,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					if m.settingsSelection > 0 {
 						m.settingsSelection--
 					}
-				} else if m.settingsTab == 2 { // Indexer tab
+				} else if m.settingsTab == 2 || m.settingsTab == 3 { // Indexer / Rules tab
 					if m.settingsSelection > 0 {
 						m.settingsSelection--
 					}
@@ -2180,6 +2633,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					if m.settingsSelection < 4 { // 5 opções no indexer
 						m.settingsSelection++
 					}
+				} else if m.settingsTab == 3 { // Rules tab
+					if m.settingsSelection < len(m.settingsRules)-1 {
+						m.settingsSelection++
+					}
 				}
 				return m, nil
 			case "enter", " ":
@@ -2190,6 +2647,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				} else if m.settingsTab == 2 { // Indexer tab
 					return m, m.handleSettingsAction()
+				} else if m.settingsTab == 3 && m.settingsSelection < len(m.settingsRules) && m.app != nil { // Rules tab - ativa/desativa
+					return m, m.toggleRule(m.settingsRules[m.settingsSelection])
 				}
 				return m, nil
 			case "s":
@@ -2199,6 +2658,48 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, m.saveSettingsFolders()
 				}
 				return m, nil
+			case "d":
+				// Remove regra selecionada
+				if m.settingsTab == 3 && m.settingsSelection < len(m.settingsRules) && m.app != nil {
+					return m, m.deleteRule(m.settingsRules[m.settingsSelection])
+				}
+				return m, nil
+			case "i":
+				// Importa regras de ~/.config/miau/rules.txt (somadas às atuais)
+				if m.settingsTab == 3 && m.app != nil {
+					m.log("📜 Importando regras de %s...", rulesFilePath())
+					return m, m.importRules(false)
+				}
+				// Importa contatos de ~/.config/miau/contacts.vcf (ou .csv)
+				if m.settingsTab == 4 && m.app != nil && m.dbAccount != nil {
+					m.log("👥 Importando contatos...")
+					return m, m.importContacts()
+				}
+				return m, nil
+			case "I":
+				// Substitui as regras pelas de rules.txt, depois de confirmar
+				if m.settingsTab == 3 && m.app != nil {
+					m.rulesConfirmReplace = true
+					m.log("📜 Substituir todas as regras pelas de %s? [y] confirmar, [n] cancelar", rulesFilePath())
+				}
+				return m, nil
+			case "e":
+				// Exporta regras para ~/.config/miau/rules.txt
+				if m.settingsTab == 3 && m.app != nil {
+					return m, m.exportRules()
+				}
+				// Exporta contatos para ~/.config/miau/contacts.vcf
+				if m.settingsTab == 4 && m.app != nil && m.dbAccount != nil {
+					return m, m.exportContacts()
+				}
+				return m, nil
+			case "r":
+				// Aplica regras aos emails recentes da INBOX
+				if m.settingsTab == 3 && m.app != nil {
+					m.log("📜 Aplicando regras na INBOX...")
+					return m, m.runRulesOnInbox()
+				}
+				return m, nil
 			case "+", "=":
 				// Aumenta velocidade do indexador
 				if m.settingsTab == 2 && m.indexState != nil && m.settingsSelection == 1 {
@@ -2227,6 +2728,31 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil // Bloqueia outras teclas no settings
 		}
 
+		// Prompt de labels
+		if m.labelMode {
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "esc":
+				m.labelMode = false
+				m.labelInput.Blur()
+				m.labelInput.SetValue("")
+				return m, nil
+			case "enter":
+				m.labelMode = false
+				m.labelInput.Blur()
+				var input = m.labelInput.Value()
+				m.labelInput.SetValue("")
+				if strings.TrimSpace(input) == "" || m.selectedEmail >= len(m.emails) {
+					return m, nil
+				}
+				return m, m.editLabel(m.emails[m.selectedEmail], input)
+			}
+			var cmd tea.Cmd
+			m.labelInput, cmd = m.labelInput.Update(msg)
+			return m, cmd
+		}
+
 		// Search mode
 		if m.searchMode {
 			switch msg.String() {
@@ -2344,7 +2870,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.showDrafts = true
 					m.selectedDraft = 0
 					m.editingDraftID = nil
-					return m, m.loadDrafts()
+					return m, tea.Batch(m.loadDrafts(), m.loadOutbox())
 				}
 			case "up":
 				if m.aiScrollOffset > 0 {
@@ -2387,6 +2913,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.log("📎 Tecla 'x' pressionada no viewer - abrindo anexos (app=%v)", m.app != nil)
 				m.attachmentsLoading = true
 				return m, m.loadAllAttachments()
+			case "a", "t", "d":
+				// Responde o convite de reunião
+				if m.viewerInvite != nil && m.viewerInvite.CanRespond && m.app != nil {
+					m.viewerInviteStatus = statusStyle.Render("Enviando resposta...")
+					return m, m.respondInvite(inviteResponses[msg.String()])
+				}
 			}
 			// Passa eventos de scroll para o viewport
 			var cmd tea.Cmd
@@ -2408,17 +2940,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 					return m, nil
 				case "down", "j":
-					if m.selectedSchedulePreset < len(m.schedulePresets)-1 {
+					if m.selectedSchedulePreset < m.schedulePickerSize()-1 {
 						m.selectedSchedulePreset++
 					}
 					return m, nil
+				case "t":
+					// Alterna o horário do destinatário (só com o app)
+					if m.app != nil {
+						m.scheduleRecipientTZ = !m.scheduleRecipientTZ
+					}
+					return m, nil
 				case "enter":
-					// Schedule with selected preset
-					if m.selectedSchedulePreset >= 0 && m.selectedSchedulePreset < len(m.schedulePresets) {
-						var preset = m.schedulePresets[m.selectedSchedulePreset]
+					// Schedule with selected preset (or recurrence)
+					if m.selectedSchedulePreset >= 0 && m.selectedSchedulePreset < m.schedulePickerSize() {
 						m.showSchedulePresets = false
 						m.composeSending = true
-						return m, m.createScheduledDraftWithTime(preset.Time)
+						return m, m.scheduleFromPicker(m.selectedSchedulePreset)
 					}
 					return m, nil
 				}
@@ -2430,9 +2967,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			case "esc":
 				m.showCompose = false
+				m.composeFollowUp = 0
 				m.composeTo.Blur()
 				m.composeSubject.Blur()
 				return m, nil
+			case "ctrl+f":
+				// Follow-up: lembrar se ninguém responder (precisa do app)
+				if m.app != nil {
+					m.composeFollowUp = m.nextFollowUp()
+				}
+				return m, nil
 			case "tab":
 				// Cicla entre campos: To(0), Subject(1), Body(2), Classification(3)
 				m.composeFocus = (m.composeFocus + 1) % 4
@@ -2486,6 +3030,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					// Carrega presets do serviço
 					if m.app != nil {
 						m.schedulePresets = m.app.Schedule().GetSchedulePresets()
+						m.recurrencePresets = m.app.Schedule().GetRecurrencePresets()
 					}
 					m.showSchedulePresets = true
 					m.selectedSchedulePreset = 0
@@ -2567,8 +3112,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.showFolders = false
 				m.state = stateSyncing
 				m.selectedEmail = 0
-				// Cria/obtém a pasta no DB
-				var folder, _ = storage.GetOrCreateFolder(m.dbAccount.ID, m.currentBox)
+				// Cria/obtém a pasta no DB (na caixa unificada, a INBOX da conta atual)
+				var folder, _ = storage.GetOrCreateFolder(m.dbAccount.ID, m.imapBox())
 				m.dbFolder = folder
 				return m, m.syncEmails()
 			}
@@ -2710,7 +3255,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Abre drafts panel
 			m.showDrafts = true
 			m.selectedDraft = 0
-			return m, m.loadDrafts()
+			return m, tea.Batch(m.loadDrafts(), m.loadOutbox())
 
 		case "G":
 			// Switch to Desktop GUI
@@ -2789,6 +3334,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, m.markAsRead(email.ID, email.UID)
 			}
 
+		case "l":
+			// Adiciona/remove label do Gmail do email selecionado
+			if !m.showFolders && len(m.emails) > 0 && m.hasLabels() && !m.isOtherAccount(m.emails[m.selectedEmail]) {
+				m.labelMode = true
+				m.labelInput.Focus()
+				m.labelInput.SetValue("")
+				return m, nil
+			}
+
 		case "*":
 			// Star/unstar email - batch if in visual mode with selections
 			if !m.showFolders && len(m.emails) > 0 {
@@ -2832,7 +3386,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.settingsTab = 0
 				m.settingsSelection = 0
 				m.log("⚙️ Abrindo configurações")
-				return m, tea.Batch(m.loadIndexState(), m.loadSettingsFolders())
+				var cmds = []tea.Cmd{m.loadIndexState(), m.loadSettingsFolders()}
+				if m.app != nil {
+					cmds = append(cmds, m.loadRules())
+				}
+				return m, tea.Batch(cmds...)
 			}
 
 		case "@":
@@ -2942,15 +3500,28 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.app.SetIMAPClient(m.client)
 			m.log("🔗 IMAP client compartilhado com Application")
 		}
+		// Push sync: inicia IDLE na INBOX (só na primeira conexão)
+		var idleCmd tea.Cmd
+		if m.app != nil && m.idleEvents == nil {
+			m.idleEvents = make(chan idleSyncMsg, 16)
+			idleCmd = m.startIdle()
+		}
+		// Envios em background da outbox (desfazer envio, sem conexão)
+		var outboxCmd tea.Cmd
+		if m.app != nil && m.app.Outbox() != nil && m.outboxEvents == nil {
+			m.outboxEvents = make(chan outboxChangedMsg, 16)
+			outboxCmd = m.watchOutbox()
+		}
 		// Se já temos emails do cache, faz sync em background sem bloquear UI
 		if m.state == stateReady {
-			return m, m.loadFolders()
+			return m, tea.Batch(m.loadFolders(), idleCmd, outboxCmd)
 		}
 		m.state = stateLoadingFolders
-		return m, m.loadFolders()
+		return m, tea.Batch(m.loadFolders(), idleCmd, outboxCmd)
 
 	case foldersLoadedMsg:
 		m.log("📂 %d pastas carregadas", len(msg.mailboxes))
+		var unified = m.isUnified()
 		m.mailboxes = msg.mailboxes
 
 		// Salva pastas no DB e encontra INBOX
@@ -2973,13 +3544,31 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// Caixa unificada no topo (com mais de uma conta); mantém aberta se já estava
+		var mailboxes = m.withUnifiedInbox(m.mailboxes)
+		if len(mailboxes) > len(m.mailboxes) {
+			m.mailboxes = mailboxes
+			m.selectedBox++
+			if unified {
+				m.selectedBox = 0
+				m.currentBox = ports.UnifiedInbox
+			}
+		}
+
+		// Labels do Gmail no fim (os já sincronizados); o sync deles roda junto
+		m.mailboxes = m.withLabels(m.mailboxes)
+		var labelsCmd tea.Cmd
+		if m.hasLabels() {
+			labelsCmd = m.syncLabels()
+		}
+
 		// Sync em background - não muda state se já estamos ready
 		var latestUID2, _ = storage.GetLatestUID(m.dbAccount.ID, m.dbFolder.ID)
 		m.log("🔄 Iniciando sync... (lastUID=%d)", latestUID2)
 		if m.state != stateReady {
 			m.state = stateSyncing
 		}
-		return m, m.syncEmails()
+		return m, tea.Batch(m.syncEmails(), labelsCmd)
 
 	case syncProgressMsg:
 		m.syncStatus = msg.status
@@ -2993,6 +3582,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.log("✅ Sync: %d novos, %d removidos (total servidor: %d)", msg.synced, msg.purged, msg.total)
 		}
+		if msg.filtered > 0 {
+			m.log("📜 Regras: %d emails filtrados", msg.filtered)
+		}
+		if msg.rulesErr != nil {
+			m.log("❌ Erro nas regras: %v", msg.rulesErr)
+		}
+		for _, failed := range msg.failed {
+			m.log("❌ Sync falhou em %s", failed)
+		}
 		// Mostra notificação de emails por 3 segundos (inclusive 0)
 		m.newEmailCount = msg.synced
 		m.newEmailShowTime = time.Now().Add(3 * time.Second)
@@ -3005,6 +3603,34 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.autoRefreshEnabled = true
 		return m, tea.Batch(m.loadEmailsFromDB(), scheduleAutoRefresh())
 
+	case labelsSyncedMsg:
+		if msg.err != nil {
+			m.log("❌ Erro no sync de labels: %v", msg.err)
+		} else {
+			m.log("🏷 Labels: %d mensagens atualizadas", msg.updated)
+		}
+		m.mailboxes = m.withLabels(m.mailboxes)
+		if m.selectedBox >= len(m.mailboxes) {
+			m.selectedBox = len(m.mailboxes) - 1
+		}
+		if m.isLabelView() || msg.err == nil {
+			return m, m.loadEmailsFromDB()
+		}
+		return m, nil
+
+	case labelEditedMsg:
+		if msg.err != nil {
+			m.log("❌ Erro no label %s: %v", msg.label, msg.err)
+			return m, nil
+		}
+		if msg.added {
+			m.log("🏷 Label %s adicionado", msg.label)
+		} else {
+			m.log("🏷 Label %s removido", msg.label)
+		}
+		m.mailboxes = m.withLabels(m.mailboxes)
+		return m, m.loadEmailsFromDB()
+
 	case emailsLoadedMsg:
 		m.log("📧 %d emails carregados do cache", len(msg.emails))
 		// Não sobrescreve se filtro está ativo (evita race condition)
@@ -3017,8 +3643,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		// Sempre vai para ready quando temos emails do cache
 		m.state = stateReady
-		// Inicia verificação de snoozes
-		return m, scheduleSnoozeCheck()
+		// Inicia verificação de snoozes e atualiza a outbox
+		return m, tea.Batch(scheduleSnoozeCheck(), m.loadOutbox())
 
 	case configSavedMsg:
 		return m, nil
@@ -3207,6 +3833,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.err != nil {
 			m.showAI = true
 			m.aiResponse = errorStyle.Render(fmt.Sprintf("❌ Erro ao enviar para %s:\n%s", msg.to, msg.err.Error()))
+		} else if msg.queued != 0 {
+			m.log("📡 Sem conexão, email para %s na outbox (#%d)", msg.to, msg.queued)
+			m.showCompose = false
+			m.showAI = true
+			m.aiResponse = infoStyle.Render(fmt.Sprintf(`📡 Sem conexão com o servidor
+
+📤 Para: %s
+
+O email ficou na outbox e será enviado assim que a conexão voltar.
+[d] Drafts mostra a outbox (reenviar, editar ou cancelar).`, msg.to))
+			return m, m.loadOutbox()
 		} else {
 			// Marca como respondido se era um reply
 			if m.composeReplyTo != nil {
@@ -3261,6 +3898,10 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 
 	case emailContentMsg:
 		m.viewerLoading = false
+		m.viewerSecurity = msg.security
+		m.viewerRisk = msg.risk
+		m.viewerInvite = msg.invite
+		m.viewerInviteStatus = ""
 		if msg.err != nil {
 			m.showViewer = false
 			m.showAI = true
@@ -3271,12 +3912,35 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 		m.viewerViewport = viewport.New(m.width-4, m.height-8)
 		m.viewerViewport.SetContent(msg.content)
 
+		// Atualiza o badge de risco na lista (a análise com o email completo pode mudar o nível)
+		if msg.risk != nil && m.viewerEmail != nil {
+			for i := range m.emails {
+				if m.emails[i].ID == m.viewerEmail.ID {
+					m.emails[i].RiskLevel = string(msg.risk.Level)
+					break
+				}
+			}
+		}
+
 		// Marca como lido
 		if m.viewerEmail != nil && !m.viewerEmail.IsRead {
 			return m, m.markAsRead(m.viewerEmail.ID, m.viewerEmail.UID)
 		}
 		return m, nil
 
+	case inviteRespondedMsg:
+		if msg.err != nil {
+			m.log("❌ Erro ao responder convite: %v", msg.err)
+			m.viewerInviteStatus = errorStyle.Render("Erro ao enviar resposta: " + msg.err.Error())
+			return m, nil
+		}
+		m.log("📅 Convite respondido: %s", msg.response)
+		if msg.invite != nil && m.viewerInvite != nil && msg.invite.EmailID == m.viewerInvite.EmailID {
+			m.viewerInvite = msg.invite
+		}
+		m.viewerInviteStatus = successStyle.Render("Resposta enviada ao organizador")
+		return m, nil
+
 	case markReadMsg:
 		// Atualiza na lista local
 		for i := range m.emails {
@@ -3427,12 +4091,26 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 	// === DRAFT HANDLERS ===
 
 	case draftSendTickMsg:
+		// Email segurado na outbox: o app envia quando a janela de desfazer
+		// acaba, aqui só fecha o overlay
+		if m.holding() && !time.Now().Before(m.scheduledDraft.ScheduledSendAt.Time) {
+			m.showUndoOverlay = false
+			m.scheduledDraft = nil
+			return m, tea.Batch(m.loadDrafts(), m.loadOutbox(), scheduleDraftSend())
+		}
+
 		// Verifica se há drafts prontos para envio
 		var readyDrafts, err = storage.GetScheduledDraftsReady()
 		if err != nil {
 			return m, scheduleDraftSend()
 		}
 
+		// Com o app, o ScheduleService envia (ele também roda em background
+		// e cria as ocorrências dos envios recorrentes)
+		if len(readyDrafts) > 0 && m.app != nil {
+			return m, tea.Batch(m.processSchedules(), scheduleDraftSend())
+		}
+
 		// Envia o primeiro draft pronto
 		if len(readyDrafts) > 0 {
 			var draft = readyDrafts[0]
@@ -3443,7 +4121,7 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 		// Verifica se ainda há drafts agendados (não prontos ainda)
 		if m.dbAccount != nil {
 			var pending, _ = storage.CountPendingDrafts(m.dbAccount.ID)
-			if pending > 0 {
+			if pending > 0 || m.holding() {
 				return m, scheduleDraftSend()
 			}
 		}
@@ -3475,6 +4153,46 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 		}
 		return m, nil
 
+	// === PUSH SYNC (IDLE) HANDLERS ===
+
+	case idleStartedMsg:
+		if msg.err != nil {
+			m.log("⚠️ Push (IDLE) indisponível: %v", msg.err)
+			return m, nil
+		}
+		m.idleActive = m.app.Sync().IsIdling()
+		if m.idleActive {
+			m.log("⚡ Push ativo na INBOX (IMAP IDLE)")
+		}
+		return m, waitForIdleSync(m.idleEvents)
+
+	case idleSyncMsg:
+		// Syncs das outras contas (em background) só interessam à caixa unificada
+		var currentAccount = msg.accountID == 0 || (m.dbAccount != nil && msg.accountID == m.dbAccount.ID)
+		if !currentAccount && !m.isUnified() {
+			return m, waitForIdleSync(m.idleEvents)
+		}
+		m.log("⚡ Push: %d novos, %d removidos, %d flags alteradas", msg.newEmails, msg.deleted, msg.flagUpdates)
+		m.newEmailCount = msg.newEmails
+		m.newEmailShowTime = time.Now().Add(3 * time.Second)
+		if m.state == stateReady && (m.isUnified() || strings.EqualFold(m.currentBox, "INBOX")) {
+			return m, tea.Batch(m.loadEmailsFromDB(), waitForIdleSync(m.idleEvents))
+		}
+		return m, waitForIdleSync(m.idleEvents)
+
+	// === OUTBOX HANDLER ===
+
+	case outboxChangedMsg:
+		// Email segurado para desfazer envio começou a sair: fecha o overlay
+		if m.scheduledDraft != nil && m.scheduledDraft.ID == msg.draftID && msg.status != ports.DraftStatusQueued {
+			m.scheduledDraft = nil
+			m.showUndoOverlay = false
+		}
+		if msg.status == ports.DraftStatusSent {
+			m.log("📨 Email #%d da outbox enviado", msg.draftID)
+		}
+		return m, tea.Batch(m.loadOutbox(), waitForOutboxChange(m.outboxEvents))
+
 	// === AUTO-REFRESH HANDLER ===
 
 	case autoRefreshTickMsg:
@@ -3482,6 +4200,12 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 			return m, scheduleAutoRefresh()
 		}
 
+		// Com push ativo a INBOX é atualizada pelo IDLE, não precisa de polling
+		if m.idleActive && strings.EqualFold(m.currentBox, "INBOX") {
+			m.autoRefreshStart = time.Now()
+			return m, scheduleAutoRefresh()
+		}
+
 		// Calcula tempo desde último refresh (adiciona 1s de buffer para barra completar)
 		var elapsed = time.Since(m.autoRefreshStart)
 		if elapsed >= autoRefreshInterval+time.Second {
@@ -3501,8 +4225,13 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 			return m, nil
 		}
 
+		if err := m.setDraftFollowUp(msg.draft.ID); err != nil {
+			m.log("❌ Follow-up do draft %d: %v", msg.draft.ID, err)
+		}
+
 		m.showCompose = false
 		m.composeSending = false
+		m.composeFollowUp = 0
 		m.scheduledDraft = msg.draft
 		m.showUndoOverlay = true
 		m.composeTo.SetValue("")
@@ -3513,10 +4242,43 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 		// Inicia scheduler de envio se não estiver rodando
 		return m, tea.Batch(m.loadDrafts(), scheduleDraftSend())
 
+	case draftRecurringMsg:
+		m.composeSending = false
+		if msg.err != nil {
+			m.aiResponse = errorStyle.Render("Erro ao agendar envio recorrente: " + msg.err.Error())
+			return m, m.loadDrafts()
+		}
+
+		m.showCompose = false
+		m.composeFollowUp = 0
+		m.composeTo.SetValue("")
+		m.composeSubject.SetValue("")
+		m.composeBodyText = ""
+		m.editingDraftID = nil
+
+		var next = "—"
+		if msg.schedule.NextRunAt != nil {
+			next = msg.schedule.NextRunAt.Local().Format("02/01 15:04")
+		}
+		m.log("🔁 Envio recorrente #%d criado (%s)", msg.schedule.ID, msg.schedule.Rule)
+		m.aiResponse = successStyle.Render(fmt.Sprintf("🔁 Envio recorrente: %s\nPróximo envio: %s", msg.label, next))
+		return m, m.loadDrafts()
+
+	case schedulesProcessedMsg:
+		if msg.err != nil {
+			m.log("❌ Erro ao enviar drafts agendados: %v", msg.err)
+		} else if msg.sent > 0 {
+			m.log("📤 %d draft(s) agendado(s) enviado(s)", msg.sent)
+		}
+		return m, tea.Batch(m.loadDrafts(), m.loadOutbox())
+
 	case draftSentMsg:
 		if msg.err != nil {
 			m.log("❌ Erro ao enviar draft: %v", msg.err)
 			m.aiResponse = errorStyle.Render("Erro no envio: " + msg.err.Error())
+		} else if msg.queued {
+			m.log("📡 Sem conexão, draft #%d na outbox", msg.draftID)
+			m.aiResponse = infoStyle.Render(fmt.Sprintf("📡 Sem conexão: email para %s ficou na outbox\nSerá enviado assim que a conexão voltar.", msg.to))
 		} else {
 			m.log("✅ Draft enviado via %s para %s", msg.backend, msg.to)
 			var backendMsg = "SMTP"
@@ -3530,7 +4292,7 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 			m.scheduledDraft = nil
 			m.showUndoOverlay = false
 		}
-		return m, tea.Batch(m.loadDrafts(), m.syncEmails())
+		return m, tea.Batch(m.loadDrafts(), m.loadOutbox(), m.syncEmails())
 
 	case draftsLoadedMsg:
 		if msg.err != nil {
@@ -3541,6 +4303,39 @@ Se houver rejeição, você será alertado.`, msg.to, msg.host, msg.port)
 		}
 		return m, nil
 
+	case outboxLoadedMsg:
+		if msg.err != nil {
+			m.log("❌ Erro ao carregar outbox: %v", msg.err)
+			return m, nil
+		}
+		m.outbox = msg.items
+		if m.selectedDraft >= len(m.drafts)+len(m.outbox) && m.selectedDraft > 0 {
+			m.selectedDraft = len(m.drafts) + len(m.outbox) - 1
+		}
+		return m, nil
+
+	case outboxActionMsg:
+		if msg.err != nil {
+			m.log("❌ Outbox: %v", msg.err)
+			m.aiResponse = errorStyle.Render("Erro na outbox: " + msg.err.Error())
+			return m, m.loadOutbox()
+		}
+		m.log("📤 Outbox: %s #%d", msg.action, msg.id)
+		if msg.action == "edit" {
+			// Cancelado na outbox, o email volta a ser draft e abre no compose
+			if draft, err := storage.GetDraftByID(msg.id); err == nil {
+				m.showDrafts = false
+				m.showCompose = true
+				m.composeTo.SetValue(draft.ToAddresses)
+				m.composeSubject.SetValue(draft.Subject)
+				m.composeBodyText = draft.BodyText.String
+				m.composeFocus = 2 // Foca no body
+				m.editingDraftID = &draft.ID
+				return m, tea.Batch(m.loadDrafts(), m.loadOutbox(), textinput.Blink)
+			}
+		}
+		return m, tea.Batch(m.loadDrafts(), m.loadOutbox())
+
 	case bounceFoundMsg:
 		m.log("🚨 BOUNCE detectado para %s!", msg.originalTo)
 
@@ -3653,6 +4448,35 @@ Verifique as configurações ou contate o administrador.`,
 		}
 		return m, nil
 
+	case rulesLoadedMsg:
+		if msg.err != nil {
+			m.log("❌ Erro ao carregar regras: %v", msg.err)
+			return m, nil
+		}
+		m.settingsRules = msg.rules
+		// Mantém a seleção dentro da lista após remover regras
+		if m.settingsTab == 3 && m.settingsSelection >= len(m.settingsRules) && len(m.settingsRules) > 0 {
+			m.settingsSelection = len(m.settingsRules) - 1
+		}
+		return m, nil
+
+	case rulesActionMsg:
+		if msg.message != "" {
+			m.log("📜 %s", msg.message)
+		}
+		if msg.err != nil {
+			m.log("❌ Erro nas regras: %v", msg.err)
+		}
+		return m, m.loadRules()
+
+	case contactsActionMsg:
+		if msg.err != nil {
+			m.log("❌ Erro nos contatos: %v", msg.err)
+			return m, nil
+		}
+		m.log("👥 %s", msg.message)
+		return m, nil
+
 	case accountSwitchedMsg:
 		if msg.err != nil {
 			m.log("❌ Erro ao trocar conta: %v", msg.err)
@@ -3938,6 +4762,7 @@ func (m Model) viewInbox() string {
 	if len(m.drafts) > 0 {
 		draftIndicator = infoStyle.Render(fmt.Sprintf(" 📝%d ", len(m.drafts)))
 	}
+	draftIndicator += m.outboxIndicator()
 	if activeAlerts > 0 {
 		alertIndicator = errorStyle.Render(fmt.Sprintf(" 🚨%d ", activeAlerts))
 	}
@@ -4005,6 +4830,12 @@ func (m Model) viewInbox() string {
 		searchBanner = searchBoxStyle.Render(fmt.Sprintf("🔍 Buscar: %s%s", m.searchInput.View(), resultInfo))
 	}
 
+	// Label banner (prompt de edição de labels)
+	var labelBanner = ""
+	if m.labelMode {
+		labelBanner = m.renderLabelBanner()
+	}
+
 	// Filter banner (quando em modo de preview de operação em lote)
 	var filterBanner = ""
 	if m.filterActive && m.pendingBatchOp != nil {
@@ -4028,7 +4859,10 @@ func (m Model) viewInbox() string {
 
 	// Auto-refresh timer indicator
 	var timerIndicator = ""
-	if m.autoRefreshEnabled && m.state == stateReady {
+	if m.idleActive && m.state == stateReady && strings.EqualFold(m.currentBox, "INBOX") {
+		var timerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+		timerIndicator = timerStyle.Render(" ⚡ push ")
+	} else if m.autoRefreshEnabled && m.state == stateReady {
 		var elapsed = time.Since(m.autoRefreshStart)
 		var progress = float64(elapsed) / float64(autoRefreshInterval)
 		if progress > 1 {
@@ -4056,6 +4890,8 @@ func (m Model) viewInbox() string {
 	var footer string
 	if m.searchMode {
 		footer = subtitleStyle.Render(" ↑↓:navegar  Enter:selecionar  Esc:cancelar  /:buscar ")
+	} else if m.labelMode {
+		footer = subtitleStyle.Render(" Enter:aplicar  Esc:cancelar  +nome:adicionar  -nome:remover ")
 	} else if m.filterActive {
 		footer = subtitleStyle.Render(" y:CONFIRMAR operação  n/Esc:CANCELAR e voltar  ↑↓:navegar preview ")
 	} else if m.showAI {
@@ -4098,7 +4934,14 @@ func (m Model) viewInbox() string {
 	}
 
 	var view string
-	if searchBanner != "" {
+	if labelBanner != "" {
+		view = lipgloss.JoinVertical(lipgloss.Left,
+			header,
+			labelBanner,
+			content,
+			footer,
+		)
+	} else if searchBanner != "" {
 		view = lipgloss.JoinVertical(lipgloss.Left,
 			header,
 			searchBanner,
@@ -4136,6 +4979,12 @@ func (m Model) renderFolders() string {
 	lines = append(lines, "")
 
 	for i, mb := range m.mailboxes {
+		// Labels vêm depois das pastas, com um título próprio
+		if strings.HasPrefix(mb.Name, labelBoxPrefix) && (i == 0 || !strings.HasPrefix(m.mailboxes[i-1].Name, labelBoxPrefix)) {
+			lines = append(lines, "")
+			lines = append(lines, folderStyle.Render("  Labels  "))
+		}
+
 		var line string
 		var name = truncate(mb.Name, 20)
 
@@ -4197,14 +5046,22 @@ func (m Model) renderEmailList() string {
 
 	for i := start; i < end; i++ {
 		var email = m.emails[i]
-		var line = m.formatEmailLine(email, emailWidth)
+
+		// Caixa unificada: selo colorido da conta antes da linha
+		var badge = ""
+		var lineWidth = emailWidth
+		if email.AccountEmail != "" {
+			badge = accountBadge(email.AccountEmail)
+			lineWidth -= accountBadgeWidth
+		}
+		var line = m.formatEmailLine(email, lineWidth)
 
 		if i == m.selectedEmail {
-			lines = append(lines, selectedStyle.Render(line))
+			lines = append(lines, badge+selectedStyle.Render(line))
 		} else if email.IsRead {
-			lines = append(lines, readStyle.Render(line))
+			lines = append(lines, badge+readStyle.Render(line))
 		} else {
-			lines = append(lines, unreadStyle.Render(line))
+			lines = append(lines, badge+unreadStyle.Render(line))
 		}
 	}
 
@@ -4265,7 +5122,7 @@ func (m Model) formatEmailLine(email storage.EmailSummary, width int) string {
 		subjectWidth -= 1 // Already accounted for 2 cols, just need 1 more for space
 	}
 
-	var subject = truncateWidth(email.Subject, subjectWidth)
+	var subject = truncateWidth(riskBadge(email.RiskLevel)+m.renderLabelChips(email.Labels)+email.Subject, subjectWidth)
 	var date = email.Date.Format("02/01 15:04")
 
 	// Pad subject to align (use visual width)
@@ -4438,6 +5295,15 @@ func (m Model) viewEmailViewer() string {
 		header = titleStyle.Render("miau 🐱") + " - " + subtitleStyle.Render(m.viewerEmail.Subject) + attachmentIndicator + "\n"
 		header += infoStyle.Render(fmt.Sprintf("De: %s <%s>", m.viewerEmail.FromName, m.viewerEmail.FromEmail)) + "\n"
 		header += subtitleStyle.Render(m.viewerEmail.Date.Time.Format("02/01/2006 15:04"))
+		if m.viewerSecurity != nil {
+			header += "\n" + securityLine(m.viewerSecurity)
+		}
+		if m.viewerRisk != nil && m.viewerRisk.Level.Warns() {
+			header += "\n" + riskLine(m.viewerRisk)
+		}
+		if m.viewerInvite != nil {
+			header += "\n" + inviteCard(m.viewerInvite, m.viewerInviteStatus)
+		}
 	}
 
 	// Conteúdo
@@ -4481,6 +5347,52 @@ func (m Model) viewEmailViewer() string {
 	return viewerContent
 }
 
+// securityLine descreve o status PGP/MIME ou S/MIME do email aberto no viewer
+func securityLine(sec *ports.SecurityInfo) string {
+	var parts []string
+	switch sec.Protocol {
+	case ports.ProtocolSMIME:
+		parts = append(parts, infoStyle.Render("S/MIME"))
+	case ports.ProtocolPGP:
+		parts = append(parts, infoStyle.Render("PGP"))
+	}
+	if sec.Encrypted {
+		if sec.Signature == ports.SignatureNone && sec.Error != "" {
+			parts = append(parts, errorStyle.Render("🔒 Criptografado - não foi possível descriptografar: "+sec.Error))
+			return strings.Join(parts, "  ")
+		}
+		parts = append(parts, successStyle.Render("🔒 Criptografado"))
+	}
+
+	switch sec.Signature {
+	case ports.SignatureValid:
+		parts = append(parts, successStyle.Render("✔ Assinatura válida de "+sec.Signer))
+	case ports.SignatureMismatch:
+		parts = append(parts, errorStyle.Render("⚠ Assinatura válida, mas a chave ("+sec.Signer+") não é do remetente"))
+	case ports.SignatureUnknownKey:
+		parts = append(parts, infoStyle.Render("? Assinado com chave desconhecida "+sec.KeyID))
+	case ports.SignatureInvalid:
+		var reason = ""
+		if sec.Error != "" {
+			reason = ": " + sec.Error
+		}
+		parts = append(parts, errorStyle.Render("✘ Assinatura inválida"+reason))
+	}
+
+	// Cadeia do certificado (só S/MIME)
+	switch sec.Chain {
+	case ports.ChainTrusted:
+		parts = append(parts, successStyle.Render("🛡 Certificado confiável"))
+	case ports.ChainUntrusted:
+		parts = append(parts, errorStyle.Render("⚠ Certificado de CA não confiável"))
+	case ports.ChainExpired:
+		parts = append(parts, errorStyle.Render("⚠ Certificado expirado"))
+	case ports.ChainInvalid:
+		parts = append(parts, errorStyle.Render("✘ Cadeia de certificados inválida"))
+	}
+	return strings.Join(parts, "  ")
+}
+
 func (m Model) viewDebugPanel() string {
 	var debugBoxStyle = lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
@@ -4827,7 +5739,7 @@ func (m Model) viewSettings() string {
 	var header = titleStyle.Render("miau 🐱") + " - " + infoStyle.Render("Configurações")
 
 	// Tabs
-	var tabs = []string{"📁 Folders", "🔄 Sync", "📚 Indexer", "ℹ About"}
+	var tabs = []string{"📁 Folders", "🔄 Sync", "📚 Indexer", "📜 Rules", "👥 Contacts", "ℹ About"}
 	var tabLine = "  "
 	for i, tab := range tabs {
 		if i == m.settingsTab {
@@ -4963,7 +5875,64 @@ func (m Model) viewSettings() string {
 		lines = append(lines, subtitleStyle.Render("  A indexação permite busca no conteúdo completo"))
 		lines = append(lines, subtitleStyle.Render("  dos emails, não apenas assunto e remetente."))
 
-	case 3: // About tab
+	case 3: // Rules tab
+		lines = append(lines, infoStyle.Render("  Regras de filtro (aplicadas a emails novos na INBOX):"))
+		lines = append(lines, "")
+
+		if len(m.settingsRules) == 0 {
+			lines = append(lines, subtitleStyle.Render("   Nenhuma regra configurada."))
+			lines = append(lines, subtitleStyle.Render("   Crie "+rulesFilePath()+" e pressione i para importar."))
+		}
+
+		var maxVisible = 12
+		var startIdx = 0
+		if m.settingsSelection >= maxVisible {
+			startIdx = m.settingsSelection - maxVisible + 1
+		}
+		var endIdx = startIdx + maxVisible
+		if endIdx > len(m.settingsRules) {
+			endIdx = len(m.settingsRules)
+		}
+
+		for i := startIdx; i < endIdx; i++ {
+			var r = m.settingsRules[i]
+			var checkbox = "☐"
+			if r.Enabled {
+				checkbox = "☑"
+			}
+			var actions []string
+			for _, a := range r.Actions {
+				actions = append(actions, string(a.Type))
+			}
+			var line = fmt.Sprintf("  %s %s → %s (%d)", checkbox, r.Name, strings.Join(actions, ", "), r.HitCount)
+			if i == m.settingsSelection {
+				lines = append(lines, selectedStyle.Render(" ➤"+line))
+			} else {
+				lines = append(lines, subtitleStyle.Render("   "+line))
+			}
+		}
+
+		lines = append(lines, "")
+		lines = append(lines, infoStyle.Render("  Space: ativar/desativar  d: remover  r: aplicar na INBOX"))
+		lines = append(lines, infoStyle.Render("  i: importar (somar)  I: importar substituindo  e: exportar ("+rulesFilePath()+")"))
+		if m.rulesConfirmReplace {
+			lines = append(lines, "")
+			lines = append(lines, errorStyle.Render("  Substituir todas as regras? y: confirmar  n: cancelar"))
+		}
+
+	case 4: // Contacts tab
+		lines = append(lines, infoStyle.Render("  Importação e exportação de contatos:"))
+		lines = append(lines, "")
+		lines = append(lines, subtitleStyle.Render("   i: importa "+contactsFilePath(".vcf")))
+		lines = append(lines, subtitleStyle.Render("      (ou "+contactsFilePath(".csv")+", do Google ou Outlook)"))
+		lines = append(lines, subtitleStyle.Render("   e: exporta todos os contatos para "+contactsFilePath(".vcf")))
+		lines = append(lines, "")
+		lines = append(lines, subtitleStyle.Render("   Contatos com um email já conhecido são mesclados,"))
+		lines = append(lines, subtitleStyle.Render("   mantendo o histórico de interações."))
+		lines = append(lines, "")
+		lines = append(lines, infoStyle.Render("  Tab: próxima aba  Esc: fechar"))
+
+	case 5: // About tab
 		lines = append(lines, "")
 		lines = append(lines, titleStyle.Render("  miau 🐱"))
 		lines = append(lines, infoStyle.Render("  Mail Intelligence Assistant Utility"))
@@ -4994,6 +5963,10 @@ func (m Model) viewSettings() string {
 		footer = subtitleStyle.Render(" ↑↓:navegar  Space:toggle  s:salvar  Tab/←→:aba  Esc:fechar ")
 	case 2:
 		footer = subtitleStyle.Render(" ↑↓:navegar  Enter:selecionar  +/-:velocidade  Tab/←→:aba  Esc:fechar ")
+	case 3:
+		footer = subtitleStyle.Render(" ↑↓:navegar  Space:toggle  d:remover  i:importar  I:substituir  e:exportar  r:aplicar  Tab/←→:aba  Esc:fechar ")
+	case 4:
+		footer = subtitleStyle.Render(" i:importar  e:exportar  Tab/←→:aba  Esc:fechar ")
 	default:
 		footer = subtitleStyle.Render(" Tab/←→:navegar abas  Esc:fechar ")
 	}
@@ -5034,6 +6007,9 @@ func (m Model) viewCompose() string {
 	} else {
 		indicators += subtitleStyle.Render(" [Sem assinatura] ")
 	}
+	if m.composeFollowUp > 0 {
+		indicators += infoStyle.Render(" [⏰ Follow-up: " + followUpLabel(m.composeFollowUp) + "] ")
+	}
 	header += "  " + indicators
 
 	// Campos
@@ -5115,9 +6091,13 @@ func (m Model) viewCompose() string {
 	if m.composeSending {
 		footer = statusStyle.Render(" Enviando... ")
 	} else if m.showSchedulePresets {
-		footer = subtitleStyle.Render(" ↑↓:selecionar  Enter:agendar  Esc:cancelar ")
+		footer = subtitleStyle.Render(" ↑↓:selecionar  Enter:agendar  t:horário do destinatário  Esc:cancelar ")
 	} else {
-		footer = subtitleStyle.Render(" Tab:próximo campo  ←→:classificação  Ctrl+S:enviar  Ctrl+L:agendar  Esc:cancelar ")
+		var followUpHint string
+		if m.app != nil {
+			followUpHint = "  Ctrl+F:follow-up"
+		}
+		footer = subtitleStyle.Render(" Tab:próximo campo  ←→:classificação  Ctrl+S:enviar  Ctrl+L:agendar" + followUpHint + "  Esc:cancelar ")
 	}
 
 	var content = lipgloss.JoinVertical(lipgloss.Left,
@@ -5144,6 +6124,21 @@ func (m Model) viewCompose() string {
 			}
 			presetItems += item + "\n"
 		}
+		if len(m.recurrencePresets) > 0 {
+			presetItems += infoStyle.Render("Repetir:") + "\n"
+		}
+		for i, preset := range m.recurrencePresets {
+			var item string
+			if len(m.schedulePresets)+i == m.selectedSchedulePreset {
+				item = selectedStyle.Render(" → 🔁 " + preset.Label + " ")
+			} else {
+				item = subtitleStyle.Render("   🔁 " + preset.Label)
+			}
+			presetItems += item + "\n"
+		}
+		if m.scheduleRecipientTZ {
+			presetItems += successStyle.Render("🌐 No horário do destinatário") + "\n"
+		}
 		scheduleSection = presetStyle.Render(presetTitle + "\n" + strings.TrimSuffix(presetItems, "\n"))
 	}
 
@@ -5497,7 +6492,26 @@ func (m Model) viewDraftsPanel(baseView string) string {
 		}
 	}
 
+	if len(m.outbox) > 0 {
+		lines = append(lines, "", headerStyle.Render("📤 Outbox (sem conexão)"))
+		for i, item := range m.outbox {
+			var line = fmt.Sprintf(" %s │ %s │ %s",
+				truncate(outboxStatus(item), 14),
+				truncate(item.To, 20),
+				truncate(item.Subject, 18))
+
+			if len(m.drafts)+i == m.selectedDraft {
+				lines = append(lines, selectedStyle.Render(line))
+			} else {
+				lines = append(lines, line)
+			}
+		}
+	}
+
 	var footer = subtitleStyle.Render(" ↑↓:navegar  e:editar  s:enviar  x:deletar  Esc:voltar ")
+	if m.selectedOutboxItem() != nil {
+		footer = subtitleStyle.Render(" ↑↓:navegar  e:editar  r:reenviar agora  x:cancelar  Esc:voltar ")
+	}
 
 	var content = lipgloss.JoinVertical(lipgloss.Left,
 		header,
@@ -0,0 +1,144 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	emailparser "github.com/opik/miau/internal/email"
+	"github.com/opik/miau/internal/ports"
+)
+
+// Convites de reunião: emails com uma parte text/calendar viram um evento
+// do calendário (event_type meeting) ao serem abertos, e o viewer mostra o
+// cartão do convite. Com a:aceitar, t:talvez e d:recusar a resposta vai para
+// o organizador como um iTIP REPLY.
+
+// inviteResponses são as teclas do viewer que respondem um convite
+var inviteResponses = map[string]ports.InviteResponse{
+	"a": ports.InviteAccepted,
+	"t": ports.InviteTentative,
+	"d": ports.InviteDeclined,
+}
+
+// inviteResponseLabels descrevem a resposta do usuário
+var inviteResponseLabels = map[ports.InviteResponse]string{
+	ports.InviteNeedsAction: "sem resposta",
+	ports.InviteAccepted:    "✓ aceito",
+	ports.InviteTentative:   "? talvez",
+	ports.InviteDeclined:    "✗ recusado",
+}
+
+// loadInvite retorna o convite do email aberto: o já importado ou, se não
+// houver, o da parte text/calendar da mensagem (nil se não for um convite)
+func (m Model) loadInvite(emailID int64, rawData []byte) *ports.Invite {
+	if m.app == nil {
+		return nil
+	}
+	var invites = m.app.Invites()
+	if invite, err := invites.GetInvite(context.Background(), emailID); err == nil && invite != nil {
+		return invite
+	}
+
+	var data = emailparser.ExtractCalendar(rawData)
+	if data == nil {
+		return nil
+	}
+	var invite, err = invites.Import(context.Background(), emailID, data)
+	if err != nil {
+		m.log("❌ Erro ao importar convite: %v", err)
+		return nil
+	}
+	return invite
+}
+
+// respondInvite envia a resposta ao convite do email aberto
+func (m Model) respondInvite(response ports.InviteResponse) tea.Cmd {
+	var invite = m.viewerInvite
+	var app = m.app
+	return func() tea.Msg {
+		var updated, err = app.Invites().Respond(context.Background(), invite.EmailID, response)
+		return inviteRespondedMsg{invite: updated, response: response, err: err}
+	}
+}
+
+// inviteCard descreve o convite do email aberto no viewer
+func inviteCard(invite *ports.Invite, status string) string {
+	var title = "📅 Convite: "
+	switch {
+	case invite.Cancelled:
+		title = "📅 Cancelado: "
+	case invite.Method == "REPLY":
+		title = "📅 Resposta: "
+	case invite.Kind == "VTODO":
+		title = "📅 Tarefa: "
+	}
+
+	var lines = []string{unreadStyle.Render(title + invite.Title)}
+	lines = append(lines, infoStyle.Render("Quando: "+inviteWhen(invite)))
+	if invite.RRule != "" {
+		lines = append(lines, infoStyle.Render("Repete: "+invite.RRule))
+	}
+	if invite.Location != "" {
+		lines = append(lines, infoStyle.Render("Onde: "+invite.Location))
+	}
+	if invite.Organizer.Email != "" {
+		lines = append(lines, infoStyle.Render("Organizador: "+inviteAttendeeName(invite.Organizer)))
+	}
+	if len(invite.Attendees) > 0 {
+		var names = make([]string, 0, len(invite.Attendees))
+		for _, a := range invite.Attendees {
+			names = append(names, inviteAttendeeName(a))
+		}
+		lines = append(lines, subtitleStyle.Render(fmt.Sprintf("Participantes (%d): %s", len(names), strings.Join(names, ", "))))
+	}
+
+	switch {
+	case invite.Outdated:
+		lines = append(lines, subtitleStyle.Render("Este convite foi atualizado por um email mais recente"))
+	case invite.CanRespond:
+		var response = "Sua resposta: " + inviteResponseLabels[invite.Response]
+		lines = append(lines, infoStyle.Render(response)+subtitleStyle.Render("   a:aceitar  t:talvez  d:recusar"))
+	}
+	if status != "" {
+		lines = append(lines, status)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inviteWhen descreve o horário do convite no fuso local
+func inviteWhen(invite *ports.Invite) string {
+	var start = invite.Start.Local()
+	if invite.AllDay {
+		var when = start.Format("Mon 02/01/2006")
+		if invite.End != nil && invite.End.Sub(invite.Start) > 24*time.Hour {
+			when += " - " + invite.End.Add(-24*time.Hour).Local().Format("Mon 02/01/2006")
+		}
+		return when + " (dia todo)"
+	}
+
+	var when = start.Format("Mon 02/01/2006 15:04")
+	if invite.End != nil {
+		var end = invite.End.Local()
+		if end.YearDay() == start.YearDay() && end.Year() == start.Year() {
+			when += "-" + end.Format("15:04")
+		} else {
+			when += " - " + end.Format("Mon 02/01/2006 15:04")
+		}
+	}
+	return when
+}
+
+// inviteAttendeeName retorna o nome (ou o email) de um participante
+func inviteAttendeeName(a ports.InviteAttendee) string {
+	var name = a.Email
+	if a.Name != "" {
+		name = a.Name
+	}
+	if a.Optional {
+		name += " (opcional)"
+	}
+	return name
+}
@@ -0,0 +1,211 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/opik/miau/internal/config"
+	"github.com/opik/miau/internal/imap"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+)
+
+// Labels do Gmail: aparecem na barra de pastas depois das pastas IMAP, como
+// pastas virtuais com o prefixo labelBoxPrefix. Uma mensagem com vários
+// labels aparece uma vez em cada um (mesmo guardada em várias pastas IMAP);
+// os labels são sincronizados e alterados pelo Application, via API do Gmail.
+
+// labelBoxPrefix marca os itens da barra de pastas que são labels
+const labelBoxPrefix = "🏷 "
+
+// hiddenLabelChips são labels de sistema que não viram selo na lista
+var hiddenLabelChips = map[string]bool{
+	"INBOX":     true,
+	"SENT":      true,
+	"STARRED":   true,
+	"IMPORTANT": true,
+}
+
+// hasLabels indica se a conta atual tem labels (Gmail com OAuth2)
+func (m Model) hasLabels() bool {
+	return m.app != nil && m.account != nil && m.account.AuthType == config.AuthTypeOAuth2
+}
+
+// isLabelView indica se um label está aberto
+func (m Model) isLabelView() bool {
+	return strings.HasPrefix(m.currentBox, labelBoxPrefix)
+}
+
+// currentLabel retorna o nome do label aberto
+func (m Model) currentLabel() string {
+	return strings.TrimPrefix(m.currentBox, labelBoxPrefix)
+}
+
+// isVirtualBox indica se o item da barra de pastas não existe no servidor
+func isVirtualBox(name string) bool {
+	return name == ports.UnifiedInbox || strings.HasPrefix(name, labelBoxPrefix)
+}
+
+// withLabels (re)coloca os labels da conta no fim da lista de pastas
+func (m Model) withLabels(mailboxes []imap.Mailbox) []imap.Mailbox {
+	var result []imap.Mailbox
+	for _, mb := range mailboxes {
+		if !strings.HasPrefix(mb.Name, labelBoxPrefix) {
+			result = append(result, mb)
+		}
+	}
+	if m.dbAccount == nil {
+		return result
+	}
+
+	var labels, _ = storage.GetLabels(m.dbAccount.ID)
+	for _, l := range labels {
+		if l.Name == "INBOX" || l.Name == "SENT" {
+			continue // já são pastas
+		}
+		result = append(result, imap.Mailbox{
+			Name:     labelBoxPrefix + l.Name,
+			Messages: uint32(l.TotalMessages),
+			Unseen:   uint32(l.UnreadMessages),
+		})
+	}
+	return result
+}
+
+// syncLabels busca os labels no Gmail
+func (m Model) syncLabels() tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		var updated, err = app.Email().SyncLabels(context.Background())
+		return labelsSyncedMsg{updated: updated, err: err}
+	}
+}
+
+// loadLabelEmails carrega as mensagens do label aberto, com os labels de cada uma
+func loadLabelEmails(accountID int64, label string) tea.Msg {
+	var emails, err = storage.GetEmailsByLabel(accountID, label, 100, 0)
+	if err != nil {
+		return errMsg{err: err}
+	}
+	return emailsLoadedMsg{emails: withEmailRisk(withEmailLabels(accountID, emails))}
+}
+
+// withEmailLabels preenche os labels de cada email (da mesma conta)
+func withEmailLabels(accountID int64, emails []storage.EmailSummary) []storage.EmailSummary {
+	var messageIDs []string
+	for _, e := range emails {
+		if e.MessageID.Valid && e.MessageID.String != "" {
+			messageIDs = append(messageIDs, e.MessageID.String)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return emails
+	}
+
+	var labels, err = storage.GetLabelsForMessages(accountID, messageIDs)
+	if err != nil {
+		return emails
+	}
+	for i := range emails {
+		emails[i].Labels = labels[strings.Trim(emails[i].MessageID.String, "<>")]
+	}
+	return emails
+}
+
+// emailFolder retorna a pasta IMAP de um email da lista, quando ela não é a
+// pasta aberta (visão por label), ou ""
+func (m Model) emailFolder(emailID int64) string {
+	for _, email := range m.emails {
+		if email.ID == emailID {
+			return email.FolderName
+		}
+	}
+	return ""
+}
+
+// emailBox retorna a pasta IMAP onde buscar um email
+func (m Model) emailBox(email storage.EmailSummary) string {
+	if email.FolderName != "" {
+		return email.FolderName
+	}
+	return m.imapBox()
+}
+
+// editLabel aplica a edição digitada no prompt de labels: "+nome" adiciona,
+// "-nome" remove e "nome" alterna
+func (m Model) editLabel(email storage.EmailSummary, input string) tea.Cmd {
+	var app = m.app
+	input = strings.TrimSpace(input)
+	return func() tea.Msg {
+		var add = true
+		var name = input
+		switch {
+		case strings.HasPrefix(input, "+"):
+			name = strings.TrimSpace(input[1:])
+		case strings.HasPrefix(input, "-"):
+			add = false
+			name = strings.TrimSpace(input[1:])
+		default:
+			for _, l := range email.Labels {
+				if strings.EqualFold(l, name) {
+					add = false
+					break
+				}
+			}
+		}
+		if name == "" {
+			return labelEditedMsg{err: fmt.Errorf("nome do label vazio")}
+		}
+
+		var err error
+		if add {
+			err = app.Email().AddLabel(context.Background(), email.ID, name)
+		} else {
+			err = app.Email().RemoveLabel(context.Background(), email.ID, name)
+		}
+		return labelEditedMsg{label: name, added: add, err: err}
+	}
+}
+
+// renderLabelChips renderiza os labels de usuário de um email
+func (m Model) renderLabelChips(labels []string) string {
+	var chips []string
+	for _, l := range labels {
+		if hiddenLabelChips[l] || (m.isLabelView() && l == m.currentLabel()) {
+			continue
+		}
+		chips = append(chips, "["+l+"]")
+	}
+	if len(chips) == 0 {
+		return ""
+	}
+	return strings.Join(chips, " ") + " "
+}
+
+// renderLabelBanner renderiza o prompt de edição de labels
+func (m Model) renderLabelBanner() string {
+	var current = "nenhum"
+	if m.selectedEmail < len(m.emails) && len(m.emails[m.selectedEmail].Labels) > 0 {
+		current = strings.Join(m.emails[m.selectedEmail].Labels, ", ")
+	}
+	return lipgloss.NewStyle().
+		Background(lipgloss.Color("#4ECDC4")).
+		Foreground(lipgloss.Color("#000000")).
+		Bold(true).
+		Padding(0, 1).
+		Render(fmt.Sprintf("🏷 Label: %s  (atuais: %s)  +add -remover", m.labelInput.View(), current))
+}
+
+// selectedEmailBox é o emailBox do email aberto (ou selecionado)
+func (m Model) selectedEmailBox() string {
+	if m.viewerEmail != nil {
+		return m.emailBox(*m.viewerEmail)
+	}
+	if m.selectedEmail < len(m.emails) {
+		return m.emailBox(m.emails[m.selectedEmail])
+	}
+	return m.imapBox()
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/opik/miau/internal/imap"
+	"github.com/opik/miau/internal/ports"
 	"github.com/opik/miau/internal/storage"
 )
 
@@ -30,6 +31,20 @@ type syncDoneMsg struct {
 	total    int
 	purged   int
 	archived int // emails movidos para arquivo permanente
+	filtered int // emails novos que casaram com alguma regra
+	rulesErr error
+	failed   []string // contas que falharam no sync da caixa unificada
+}
+
+type labelsSyncedMsg struct {
+	updated int // mensagens com labels atualizados
+	err     error
+}
+
+type labelEditedMsg struct {
+	label string
+	added bool
+	err   error
 }
 
 type emailsLoadedMsg struct {
@@ -52,8 +67,17 @@ type htmlOpenedMsg struct {
 }
 
 type emailContentMsg struct {
-	content string
-	err     error
+	content  string
+	security *ports.SecurityInfo // status PGP/MIME (nil se não assinado/criptografado)
+	risk     *ports.RiskInfo     // análise de risco (nil se não analisado)
+	invite   *ports.Invite       // convite de reunião (nil se não for um convite)
+	err      error
+}
+
+type inviteRespondedMsg struct {
+	invite   *ports.Invite
+	response ports.InviteResponse
+	err      error
 }
 
 type aiEmailContextMsg struct {
@@ -69,6 +93,7 @@ type emailSentMsg struct {
 	to      string
 	msgID   string
 	backend string // "smtp" ou "gmail_api"
+	queued  int64  // sem conexão: id do draft na outbox
 }
 
 type markReadMsg struct {
@@ -106,6 +131,7 @@ type draftSentMsg struct {
 	draftID int64
 	to      string
 	backend string
+	queued  bool // sem conexão: foi para a outbox
 	err     error
 }
 
@@ -123,6 +149,40 @@ type draftsLoadedMsg struct {
 	accountID int64
 }
 
+// Outbox (envios pendentes por falta de conexão)
+type outboxLoadedMsg struct {
+	items []ports.OutboxItem
+	err   error
+}
+
+type outboxActionMsg struct {
+	id     int64
+	action string // "retry", "cancel" ou "edit" (cancela e abre no compose)
+	err    error
+}
+
+// outboxChangedMsg é uma mudança na outbox feita pelo app (envio em
+// background, nova tentativa, desfazer envio)
+type outboxChangedMsg struct {
+	draftID int64
+	status  ports.DraftStatus
+}
+
+// draftRecurringMsg é o resultado de transformar o draft do compose num
+// envio recorrente
+type draftRecurringMsg struct {
+	schedule *ports.RecurringSchedule
+	label    string
+	err      error
+}
+
+// schedulesProcessedMsg é o resultado do ScheduleService enviando os drafts
+// agendados prontos
+type schedulesProcessedMsg struct {
+	sent int
+	err  error
+}
+
 // Archive/Delete messages
 type emailArchivedMsg struct {
 	emailID int64
@@ -223,8 +283,37 @@ type settingsSavedMsg struct {
 	err error
 }
 
+// Regras de filtro
+type rulesLoadedMsg struct {
+	rules []ports.Rule
+	err   error
+}
+
+type rulesActionMsg struct {
+	message string
+	err     error
+}
+
+// Importação/exportação de contatos
+type contactsActionMsg struct {
+	message string
+	err     error
+}
+
 // Account switch message
 type accountSwitchedMsg struct {
 	email string
 	err   error
 }
+
+// Push sync (IMAP IDLE) messages
+type idleStartedMsg struct {
+	err error
+}
+
+type idleSyncMsg struct {
+	accountID   int64
+	newEmails   int
+	deleted     int
+	flagUpdates int
+}
@@ -0,0 +1,206 @@
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/opik/miau/internal/config"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/services"
+	"github.com/opik/miau/internal/smtp"
+	"github.com/opik/miau/internal/storage"
+)
+
+// Outbox: emails que não saíram por falta de conexão ficam na outbox e são
+// enviados em background pelo app (com novas tentativas). Aparecem no painel
+// de drafts, abaixo dos drafts pendentes. Com o app, a janela de desfazer
+// envio também é a outbox: o email fica lá até a janela acabar.
+
+// loadOutbox carrega a outbox da conta atual
+func (m Model) loadOutbox() tea.Cmd {
+	var app = m.app
+	var accountID int64
+	if m.dbAccount != nil {
+		accountID = m.dbAccount.ID
+	}
+	return func() tea.Msg {
+		if app == nil || app.Outbox() == nil {
+			return outboxLoadedMsg{}
+		}
+		var items, err = app.Outbox().List(context.Background())
+		if err != nil {
+			return outboxLoadedMsg{err: err}
+		}
+		var mine []ports.OutboxItem
+		for _, item := range items {
+			if item.AccountID == accountID {
+				mine = append(mine, item)
+			}
+		}
+		return outboxLoadedMsg{items: mine}
+	}
+}
+
+// watchOutbox assina as mudanças da outbox e aguarda a primeira
+func (m Model) watchOutbox() tea.Cmd {
+	var app = m.app
+	var events = m.outboxEvents
+	return func() tea.Msg {
+		app.Events().Subscribe(ports.EventTypeOutboxChanged, func(evt ports.Event) {
+			var e, ok = evt.(ports.OutboxChangedEvent)
+			if !ok {
+				return
+			}
+			select {
+			case events <- outboxChangedMsg{draftID: e.DraftID, status: e.Status}:
+			default:
+			}
+		})
+		return <-events
+	}
+}
+
+// waitForOutboxChange aguarda a próxima mudança da outbox
+func waitForOutboxChange(events chan outboxChangedMsg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// holdDraft envia um draft pelo SendService do app. Com janela de desfazer
+// envio ele fica na outbox até a janela acabar (overlay de undo); sem janela
+// sai na hora.
+func (m Model) holdDraft(draft *storage.Draft) tea.Msg {
+	var result, err = m.app.Send().SendDraft(context.Background(), draft.ID)
+	if err != nil {
+		return draftSentMsg{draftID: draft.ID, to: draft.ToAddresses, err: err}
+	}
+
+	switch {
+	case result.Pending:
+		draft.Status = storage.DraftStatusQueued
+		draft.ScheduledSendAt = sql.NullTime{Time: result.SendAt, Valid: true}
+		return draftScheduledMsg{draft: draft, sendAt: result.SendAt}
+	case result.Queued:
+		return draftSentMsg{draftID: draft.ID, to: draft.ToAddresses, queued: true}
+	}
+
+	var backend = "smtp"
+	if m.account.SendMethod == config.SendMethodGmailAPI {
+		backend = "gmail_api"
+	}
+	return draftSentMsg{draftID: draft.ID, to: draft.ToAddresses, backend: backend}
+}
+
+// holding indica se o overlay de undo mostra um email segurado na outbox
+func (m Model) holding() bool {
+	return m.showUndoOverlay && m.scheduledDraft != nil && m.scheduledDraft.Status == storage.DraftStatusQueued
+}
+
+// queueOffline coloca na outbox um envio que falhou por falta de conexão.
+// Retorna nil se o erro não for de conexão (ou não houver app).
+func (m Model) queueOffline(account *config.Account, req *ports.SendRequest, backend string, err error) tea.Msg {
+	if m.app == nil || m.app.Outbox() == nil || !services.IsConnectivityError(err) {
+		return nil
+	}
+	if dbAccount, errAccount := storage.GetOrCreateAccount(account.Email, account.Name); errAccount == nil {
+		req.AccountID = dbAccount.ID
+	}
+	var item, errQueue = m.app.Outbox().Queue(context.Background(), req)
+	if errQueue != nil {
+		return nil
+	}
+	return emailSentMsg{to: strings.Join(req.To, ", "), backend: backend, queued: item.ID}
+}
+
+// offlineRequest monta o envio do compose para a outbox; body é o texto
+// digitado e emailBody o corpo final (HTML ou texto com assinatura)
+func (m Model) offlineRequest(to, subject, body, emailBody string, isHTML bool, inReplyTo, references string) *ports.SendRequest {
+	var req = &ports.SendRequest{
+		To:             []string{to},
+		Subject:        subject,
+		BodyText:       emailBody,
+		InReplyTo:      inReplyTo,
+		ReferenceIDs:   references,
+		Classification: smtp.Classifications[m.composeClassification],
+	}
+	if isHTML {
+		req.BodyText = body
+		req.BodyHTML = emailBody
+	}
+	if m.composeReplyTo != nil {
+		var id = m.composeReplyTo.ID
+		req.ReplyToEmailID = &id
+	}
+	return req
+}
+
+// queueDraftOffline coloca na outbox um draft cujo envio falhou por falta de
+// conexão. Retorna nil se o erro não for de conexão (ou não houver app).
+func (m Model) queueDraftOffline(draft *storage.Draft, backend string, err error) tea.Msg {
+	if m.app == nil || m.app.Outbox() == nil || !services.IsConnectivityError(err) {
+		return nil
+	}
+	if _, errQueue := m.app.Outbox().QueueDraft(context.Background(), draft.ID, nil); errQueue != nil {
+		return nil
+	}
+	return draftSentMsg{draftID: draft.ID, to: draft.ToAddresses, backend: backend, queued: true}
+}
+
+// selectedOutboxItem retorna o email da outbox selecionado no painel de
+// drafts (nil se a seleção for um draft)
+func (m Model) selectedOutboxItem() *ports.OutboxItem {
+	var i = m.selectedDraft - len(m.drafts)
+	if i < 0 || i >= len(m.outbox) {
+		return nil
+	}
+	return &m.outbox[i]
+}
+
+// outboxIndicator é o indicador da outbox na barra de status ("" se vazia)
+func (m Model) outboxIndicator() string {
+	if len(m.outbox) == 0 {
+		return ""
+	}
+	for _, item := range m.outbox {
+		if item.Status == ports.DraftStatusFailed {
+			return errorStyle.Render(fmt.Sprintf(" 📤%d ", len(m.outbox)))
+		}
+	}
+	return infoStyle.Render(fmt.Sprintf(" 📤%d ", len(m.outbox)))
+}
+
+// outboxAction executa retry/cancelamento de um email da outbox
+func (m Model) outboxAction(id int64, action string) tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		var err error
+		switch action {
+		case "retry":
+			err = app.Outbox().Retry(context.Background(), id)
+		case "cancel", "edit":
+			err = app.Outbox().Cancel(context.Background(), id)
+		}
+		return outboxActionMsg{id: id, action: action, err: err}
+	}
+}
+
+// outboxStatus descreve o estado de um email da outbox no painel
+func outboxStatus(item ports.OutboxItem) string {
+	switch item.Status {
+	case ports.DraftStatusSending:
+		return "📤 enviando"
+	case ports.DraftStatusFailed:
+		return fmt.Sprintf("❌ falhou (%d tentativas)", item.Attempts)
+	}
+	if item.NextAttemptAt != nil {
+		if remaining := time.Until(*item.NextAttemptAt); remaining > 0 {
+			return fmt.Sprintf("🔁 em %s", remaining.Round(time.Second))
+		}
+	}
+	return "📡 aguardando conexão"
+}
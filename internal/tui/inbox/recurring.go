@@ -0,0 +1,98 @@
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/rrule"
+	"github.com/opik/miau/internal/storage"
+)
+
+// Agendamento pelo app: no picker do Ctrl+L, abaixo dos presets de horário,
+// ficam as regras de envio recorrente do ScheduleService (cada ocorrência é
+// um clone do draft). "t" alterna o horário do destinatário: 9:00 vira 9:00
+// no fuso salvo no contato do primeiro destinatário.
+
+// schedulePickerSize é o número de opções do picker de agendamento
+func (m Model) schedulePickerSize() int {
+	return len(m.schedulePresets) + len(m.recurrencePresets)
+}
+
+// scheduleFromPicker agenda o compose com a opção selecionada no picker
+func (m Model) scheduleFromPicker(i int) tea.Cmd {
+	if i < len(m.schedulePresets) {
+		var sendAt = m.schedulePresets[i].Time
+		if m.app != nil && m.scheduleRecipientTZ {
+			return m.createScheduledDraftForRecipient(sendAt)
+		}
+		return m.createScheduledDraftWithTime(sendAt)
+	}
+	return m.createRecurringDraft(m.recurrencePresets[i-len(m.schedulePresets)], m.scheduleRecipientTZ)
+}
+
+// createScheduledDraftForRecipient cria o draft agendado e o reagenda para
+// o mesmo horário no fuso do destinatário
+func (m Model) createScheduledDraftForRecipient(sendAt time.Time) tea.Cmd {
+	var app = m.app
+	var create = m.createScheduledDraftWithTime(sendAt)
+	return func() tea.Msg {
+		var msg, ok = create().(draftScheduledMsg)
+		if !ok || msg.err != nil {
+			return msg
+		}
+		var opts = ports.ScheduleOptions{RecipientTimeZone: true}
+		var at, err = app.Schedule().ScheduleDraftAt(context.Background(), msg.draft.ID, sendAt, opts)
+		if err != nil {
+			storage.CancelDraft(msg.draft.ID)
+			return draftScheduledMsg{err: err}
+		}
+		msg.sendAt = at
+		msg.draft.ScheduledSendAt = sql.NullTime{Time: at, Valid: true}
+		return msg
+	}
+}
+
+// createRecurringDraft cria o draft do compose e o transforma no modelo de
+// um envio recorrente
+func (m Model) createRecurringDraft(preset ports.RecurrencePresetInfo, recipientTZ bool) tea.Cmd {
+	var app = m.app
+
+	// Até virar modelo, o draft fica agendado para a próxima ocorrência
+	var now = time.Now()
+	var next = now.Add(time.Hour)
+	if r, err := rrule.Parse(preset.Rule); err == nil {
+		if t := r.Next(now, now); !t.IsZero() {
+			next = t
+		}
+	}
+	var create = m.createScheduledDraftWithTime(next)
+
+	return func() tea.Msg {
+		var msg, ok = create().(draftScheduledMsg)
+		if !ok {
+			return msg
+		}
+		if msg.err != nil {
+			return draftRecurringMsg{err: msg.err}
+		}
+		var opts = ports.ScheduleOptions{RecipientTimeZone: recipientTZ}
+		var schedule, err = app.Schedule().ScheduleRecurring(context.Background(), msg.draft.ID, preset.Rule, time.Time{}, opts)
+		if err != nil {
+			storage.CancelDraft(msg.draft.ID)
+			return draftRecurringMsg{err: err}
+		}
+		return draftRecurringMsg{schedule: schedule, label: preset.Label}
+	}
+}
+
+// processSchedules envia pelo ScheduleService os drafts agendados prontos
+func (m Model) processSchedules() tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		var sent, err = app.Schedule().ProcessDueSchedules(context.Background())
+		return schedulesProcessedMsg{sent: sent, err: err}
+	}
+}
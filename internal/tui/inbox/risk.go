@@ -0,0 +1,76 @@
+package inbox
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+)
+
+// Risco: emails com autenticação falha (SPF/DKIM/DMARC) ou sinais de phishing
+// ganham um badge na lista e um aviso no viewer. A análise é feita no sync
+// (só com os cabeçalhos) e refeita ao abrir o email (com o DKIM verificado).
+
+// riskReasons descreve cada motivo da análise de risco; o detalhe entra no
+// %s ou, se não houver, entre parênteses
+var riskReasons = map[string]string{
+	"dmarc_fail":        "DMARC falhou",
+	"dkim_fail":         "assinatura DKIM inválida",
+	"spf_fail":          "SPF falhou",
+	"spf_softfail":      "SPF suspeito (softfail)",
+	"address_in_name":   "nome mostra outro endereço",
+	"contact_spoof":     "nome de um contato com outro endereço",
+	"lookalike_domain":  "domínio parecido com %s",
+	"punycode_domain":   "domínio internacionalizado (punycode)",
+	"link_mismatch":     "link aponta para outro site",
+	"first_time_sender": "primeiro email deste remetente",
+}
+
+// withEmailRisk preenche o nível de risco de cada email
+func withEmailRisk(emails []storage.EmailSummary) []storage.EmailSummary {
+	var ids = make([]int64, len(emails))
+	for i, e := range emails {
+		ids[i] = e.ID
+	}
+
+	var risks, err = storage.GetRiskForEmails(ids)
+	if err != nil {
+		return emails
+	}
+	for i := range emails {
+		emails[i].RiskLevel = risks[emails[i].ID].Level
+	}
+	return emails
+}
+
+// riskBadge retorna o badge da lista para o nível de risco ("" se não avisa)
+func riskBadge(level string) string {
+	if !ports.RiskLevel(level).Warns() {
+		return ""
+	}
+	return "⚠ "
+}
+
+// riskLine descreve o aviso de risco do email aberto no viewer
+func riskLine(risk *ports.RiskInfo) string {
+	var title = "⚠ Risco médio"
+	if risk.Level == ports.RiskHigh {
+		title = "⚠ Risco alto"
+	}
+
+	var reasons []string
+	for _, f := range risk.Findings {
+		var reason = riskReasons[f.Reason]
+		if reason == "" {
+			reason = f.Reason
+		}
+		if strings.Contains(reason, "%s") {
+			reason = fmt.Sprintf(reason, f.Detail)
+		} else if f.Detail != "" {
+			reason += " (" + f.Detail + ")"
+		}
+		reasons = append(reasons, reason)
+	}
+	return errorStyle.Render(fmt.Sprintf("%s (%d): %s", title, risk.Score, strings.Join(reasons, ", ")))
+}
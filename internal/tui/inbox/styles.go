@@ -54,4 +54,7 @@ var (
 	statusStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#888888")).
 			Italic(true)
+
+	// accountBadgeColors colore o selo de cada conta na caixa unificada
+	accountBadgeColors = []lipgloss.Color{"#4ECDC4", "#FFD93D", "#A29BFE", "#6BCB77", "#FF9F43", "#74B9FF"}
 )
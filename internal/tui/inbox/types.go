@@ -64,10 +64,14 @@ type Model struct {
 	// Spinner
 	spinner spinner.Model
 	// Email viewer
-	showViewer     bool
-	viewerViewport viewport.Model
-	viewerEmail    *storage.EmailSummary
-	viewerLoading  bool
+	showViewer         bool
+	viewerViewport     viewport.Model
+	viewerEmail        *storage.EmailSummary
+	viewerLoading      bool
+	viewerSecurity     *ports.SecurityInfo // assinatura/criptografia PGP do email aberto
+	viewerRisk         *ports.RiskInfo     // análise de risco (SPF/DKIM/DMARC, phishing) do email aberto
+	viewerInvite       *ports.Invite       // convite de reunião (text/calendar) do email aberto
+	viewerInviteStatus string              // envio da resposta ao convite
 	// Compose
 	showCompose           bool
 	composeTo             textinput.Model
@@ -77,11 +81,14 @@ type Model struct {
 	composeFocus          int // 0=To, 1=Subject, 2=Body, 3=Classification
 	composeSending        bool
 	composeReplyTo        *storage.EmailSummary
-	composeClassification int // índice em smtp.Classifications
+	composeClassification int           // índice em smtp.Classifications
+	composeFollowUp       time.Duration // lembrar se ninguém responder (0 = não)
 	// Schedule send
 	showSchedulePresets    bool
 	selectedSchedulePreset int
 	schedulePresets        []ports.SchedulePresetInfo
+	recurrencePresets      []ports.RecurrencePresetInfo
+	scheduleRecipientTZ    bool // agenda no fuso do destinatário (salvo no contato)
 	// Debug
 	debugMode   bool
 	debugLogs   []string
@@ -97,6 +104,8 @@ type Model struct {
 	editingDraftID  *int64         // Se estamos editando um draft existente
 	scheduledDraft  *storage.Draft // Draft atualmente agendado (para overlay de undo)
 	showUndoOverlay bool
+	outbox          []ports.OutboxItem    // Emails na outbox (sem conexão), abaixo dos drafts
+	outboxEvents    chan outboxChangedMsg // Mudanças na outbox feitas pelo app (envios em background)
 	// Batch operation filter mode
 	filterActive      bool                    // Modo de filtro ativo (preview de batch op)
 	filterDescription string                  // "Arquivar 15 emails de zaqueu@..."
@@ -107,12 +116,17 @@ type Model struct {
 	searchInput   textinput.Model        // Input de busca
 	searchResults []storage.EmailSummary // Resultados da busca
 	searchQuery   string                 // Query atual (para highlight)
+	// Labels do Gmail
+	labelMode  bool            // Prompt de edição de labels aberto
+	labelInput textinput.Model // Input do prompt ("+nome", "-nome" ou "nome")
 	// Settings
 	showSettings      bool                       // Menu de configurações aberto
 	settingsSelection int                        // Item selecionado no menu/lista
-	settingsTab       int                        // Tab atual: 0=Folders, 1=Sync, 2=Indexer, 3=About
+	settingsTab       int                        // Tab atual: 0=Folders, 1=Sync, 2=Indexer, 3=Rules, 4=Contacts, 5=About
 	settingsFolders   []SettingsFolder           // Lista de pastas para configuração
 	settingsSyncFolders []string                 // Pastas selecionadas para sync
+	settingsRules     []ports.Rule               // Regras de filtro da conta
+	rulesConfirmReplace bool                     // Aguardando y/n para substituir as regras (I)
 	indexState        *storage.ContentIndexState // Estado do indexador
 	indexerRunning    bool                       // Se o indexador está ativo nesta sessão
 	// Image preview
@@ -131,6 +145,9 @@ type Model struct {
 	autoRefreshInterval time.Duration // Intervalo de auto-refresh (default 5min)
 	autoRefreshStart    time.Time     // Quando começou o timer atual
 	autoRefreshEnabled  bool          // Se o auto-refresh está habilitado
+	// Push sync (IMAP IDLE via SyncService)
+	idleEvents chan idleSyncMsg // Syncs disparados pelo IDLE na INBOX
+	idleActive bool             // Se o push está ativo (auto-refresh da INBOX desligado)
 	// New email notification
 	newEmailCount    int       // Quantidade de novos emails no último sync
 	newEmailShowTime time.Time // Quando mostrar até (para fade out)
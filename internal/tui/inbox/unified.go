@@ -0,0 +1,216 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/opik/miau/internal/config"
+	"github.com/opik/miau/internal/imap"
+	"github.com/opik/miau/internal/ports"
+	"github.com/opik/miau/internal/storage"
+)
+
+// Caixa unificada: com mais de uma conta configurada, a pasta virtual
+// ports.UnifiedInbox mostra a INBOX de todas as contas juntas. O cliente IMAP
+// do TUI é só da conta atual; emails das outras contas são lidos e alterados
+// pelo Application, que mantém uma conexão por conta.
+
+// accountBadgeWidth é a largura do selo da conta na lista de emails
+const accountBadgeWidth = 14
+
+// hasUnifiedInbox indica se a caixa unificada está disponível
+func (m Model) hasUnifiedInbox() bool {
+	return m.app != nil && len(m.app.GetAllAccounts()) > 1
+}
+
+// isUnified indica se a caixa unificada está aberta
+func (m Model) isUnified() bool {
+	return m.currentBox == ports.UnifiedInbox
+}
+
+// imapBox retorna a pasta IMAP da visão atual (a caixa unificada e os
+// labels usam a INBOX; emails de um label têm a própria pasta, ver emailBox)
+func (m Model) imapBox() string {
+	if m.isUnified() || m.isLabelView() {
+		return "INBOX"
+	}
+	return m.currentBox
+}
+
+// isOtherAccount indica se o email é de outra conta que não a atual
+func (m Model) isOtherAccount(email storage.EmailSummary) bool {
+	return m.app != nil && m.dbAccount != nil && email.AccountID != 0 && email.AccountID != m.dbAccount.ID
+}
+
+// isOtherAccountID é o isOtherAccount de um email da lista atual pelo ID
+func (m Model) isOtherAccountID(emailID int64) bool {
+	for _, email := range m.emails {
+		if email.ID == emailID {
+			return m.isOtherAccount(email)
+		}
+	}
+	return false
+}
+
+// otherAccountEmailID retorna o ID do email aberto (ou selecionado) se ele
+// for de outra conta, ou 0
+func (m Model) otherAccountEmailID() int64 {
+	var email *storage.EmailSummary
+	if m.viewerEmail != nil {
+		email = m.viewerEmail
+	} else if m.selectedEmail < len(m.emails) {
+		email = &m.emails[m.selectedEmail]
+	}
+	if email == nil || !m.isOtherAccount(*email) {
+		return 0
+	}
+	return email.ID
+}
+
+// loadOtherAccountContent carrega o texto de um email de outra conta
+func (m Model) loadOtherAccountContent(email storage.EmailSummary) tea.Msg {
+	var content, err = m.app.Email().GetEmail(context.Background(), email.ID)
+	if err != nil {
+		return emailContentMsg{err: err}
+	}
+
+	var textContent = content.BodyText
+	if textContent == "" && content.BodyHTML != "" {
+		textContent = htmlToText(content.BodyHTML)
+	}
+	if textContent == "" && content.Invite == nil {
+		return emailContentMsg{err: fmt.Errorf("email sem conteúdo de texto")}
+	}
+
+	if len(content.Attachments) > 0 {
+		var attachments = make([]Attachment, len(content.Attachments))
+		for i, att := range content.Attachments {
+			attachments[i] = Attachment{Filename: att.Filename, ContentType: att.ContentType, ContentID: att.ContentID, Size: att.Size, IsInline: att.IsInline}
+		}
+		textContent += "\n\n" + renderAttachmentList(attachments)
+	}
+
+	return emailContentMsg{content: textContent, security: content.Security, risk: content.Risk, invite: content.Invite}
+}
+
+// loadOtherAccountAttachments baixa os anexos de um email de outra conta
+func (m Model) loadOtherAccountAttachments(emailID int64) ([]Attachment, error) {
+	var ctx = context.Background()
+	var list, err = m.app.Attachment().GetAttachments(ctx, emailID)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []Attachment
+	for _, att := range list {
+		var data, err = m.app.Attachment().DownloadByPart(ctx, emailID, att.PartNumber)
+		if err != nil {
+			return attachments, fmt.Errorf("erro ao baixar %s: %w", att.Filename, err)
+		}
+		attachments = append(attachments, Attachment{
+			Filename:    att.Filename,
+			ContentType: att.ContentType,
+			ContentID:   att.ContentID,
+			Size:        int64(len(data)),
+			Data:        data,
+			IsInline:    att.IsInline,
+		})
+	}
+	return attachments, nil
+}
+
+// sendingAccount retorna a conta que envia o email em composição: respostas
+// a emails de outra conta saem por ela
+func (m Model) sendingAccount() *config.Account {
+	var replyTo = m.composeReplyTo
+	if replyTo == nil || replyTo.AccountEmail == "" || !m.isOtherAccount(*replyTo) {
+		return m.account
+	}
+
+	var cfg, err = config.Load()
+	if err != nil {
+		return m.account
+	}
+	for i := range cfg.Accounts {
+		if strings.EqualFold(cfg.Accounts[i].Email, replyTo.AccountEmail) {
+			return &cfg.Accounts[i]
+		}
+	}
+	return m.account
+}
+
+// withUnifiedInbox coloca a caixa unificada no topo da lista de pastas
+func (m Model) withUnifiedInbox(mailboxes []imap.Mailbox) []imap.Mailbox {
+	if !m.hasUnifiedInbox() {
+		return mailboxes
+	}
+	var unread, _ = storage.CountUnifiedUnread("INBOX")
+	return append([]imap.Mailbox{{Name: ports.UnifiedInbox, Unseen: uint32(unread)}}, mailboxes...)
+}
+
+// syncUnified sincroniza a INBOX de todas as contas em paralelo
+func (m Model) syncUnified() tea.Cmd {
+	var app = m.app
+	return func() tea.Msg {
+		var done syncDoneMsg
+		for _, r := range app.SyncAccounts(context.Background(), "INBOX") {
+			if r.Err != nil {
+				done.failed = append(done.failed, fmt.Sprintf("%s: %v", r.Account.Email, r.Err))
+				continue
+			}
+			if r.Result != nil {
+				done.synced += r.Result.NewEmails
+				done.purged += r.Result.DeletedEmails
+			}
+		}
+		return done
+	}
+}
+
+// searchAllAccounts faz a busca fuzzy em todas as contas, mais recentes primeiro
+func searchAllAccounts(accounts []ports.AccountInfo, query string, limit int) ([]storage.EmailSummary, error) {
+	var results []storage.EmailSummary
+	var lastErr error
+	for _, account := range accounts {
+		var found, err = storage.FuzzySearchEmails(account.ID, query, limit)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for i := range found {
+			found[i].AccountID = account.ID
+			found[i].AccountEmail = account.Email
+		}
+		results = append(results, found...)
+	}
+	if len(results) == 0 {
+		return nil, lastErr
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Date.Time.After(results[j].Date.Time)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// accountBadge renderiza o selo colorido da conta de um email
+func accountBadge(accountEmail string) string {
+	var h = fnv.New32a()
+	h.Write([]byte(strings.ToLower(accountEmail)))
+	var color = accountBadgeColors[h.Sum32()%uint32(len(accountBadgeColors))]
+
+	var label = truncate(accountEmail, accountBadgeWidth-1)
+	return lipgloss.NewStyle().
+		Foreground(color).
+		Bold(true).
+		Width(accountBadgeWidth).
+		Render(label)
+}
@@ -0,0 +1,315 @@
+// Package vcard reads and writes vCards (3.0, RFC 2426, and 4.0, RFC 6350),
+// the contacts kept on CardDAV servers and exchanged as .vcf files.
+//
+// vCards share the content line syntax of iCalendar, so the component tree
+// of package ical carries them: Parse reads the names, emails, phones, time
+// zone and photo of each card, and Encode writes a card back keeping the
+// properties miau doesn't edit (addresses, notes, birthdays...).
+package vcard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opik/miau/internal/ical"
+)
+
+// ProdID identifies miau in the cards it writes
+const ProdID = "-//miau//miau//EN"
+
+// Email is an EMAIL property
+type Email struct {
+	Address   string
+	Type      string // home, work, other... ("" if none)
+	Preferred bool
+}
+
+// Phone is a TEL property
+type Phone struct {
+	Number    string
+	Type      string // mobile, home, work, fax... ("" if none)
+	Preferred bool
+}
+
+// Photo is the PHOTO of a card: inline data or a link
+type Photo struct {
+	Data      []byte
+	MediaType string // image/jpeg, image/png...
+	URL       string
+}
+
+// Card is a parsed VCARD
+type Card struct {
+	Version    string // "3.0" or "4.0"
+	UID        string
+	Name       string // FN
+	GivenName  string
+	FamilyName string
+	Emails     []Email
+	Phones     []Phone
+	TimeZone   string // TZ, when it is an IANA name ("" otherwise)
+	Photo      Photo
+
+	component *ical.Component
+}
+
+// Parse reads every VCARD of data (a .vcf file may hold many)
+func Parse(data []byte) ([]*Card, error) {
+	var roots, err = ical.DecodeAll(data)
+	if err != nil {
+		return nil, fmt.Errorf("vcard: %w", err)
+	}
+	var cards = make([]*Card, 0, len(roots))
+	for _, c := range roots {
+		if c.Name != "VCARD" {
+			return nil, fmt.Errorf("vcard: expected VCARD, got %s", c.Name)
+		}
+		cards = append(cards, parseCard(c))
+	}
+	return cards, nil
+}
+
+// ParseOne reads a single vCard, like the object of a CardDAV server
+func ParseOne(data []byte) (*Card, error) {
+	var cards, err = Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) != 1 {
+		return nil, fmt.Errorf("vcard: expected one card, got %d", len(cards))
+	}
+	return cards[0], nil
+}
+
+func parseCard(c *ical.Component) *Card {
+	var card = &Card{
+		Version:   c.Text("VERSION"),
+		UID:       c.Text("UID"),
+		Name:      c.Text("FN"),
+		component: c,
+	}
+	if p := c.Get("N"); p != nil {
+		var parts = splitStructured(p.Value)
+		card.FamilyName = parts[0]
+		if len(parts) > 1 {
+			card.GivenName = parts[1]
+		}
+	}
+	if card.Name == "" {
+		card.Name = strings.TrimSpace(card.GivenName + " " + card.FamilyName)
+	}
+
+	for _, p := range c.GetAll("EMAIL") {
+		var address = strings.TrimPrefix(ical.UnescapeText(p.Value), "mailto:")
+		if address == "" {
+			continue
+		}
+		var kind, preferred = types(&p)
+		card.Emails = append(card.Emails, Email{Address: address, Type: kind, Preferred: preferred})
+	}
+	for _, p := range c.GetAll("TEL") {
+		var number = strings.TrimPrefix(ical.UnescapeText(p.Value), "tel:")
+		if number == "" {
+			continue
+		}
+		var kind, preferred = types(&p)
+		if kind == "cell" {
+			kind = "mobile"
+		}
+		card.Phones = append(card.Phones, Phone{Number: number, Type: kind, Preferred: preferred})
+	}
+	if card.Name == "" && len(card.Emails) > 0 {
+		card.Name = card.Emails[0].Address
+	}
+
+	// TZ is an IANA name (4.0, or 3.0 with VALUE=text) or a UTC offset,
+	// which says nothing about daylight saving time and is ignored
+	if tz := c.Text("TZ"); strings.Contains(tz, "/") {
+		if _, err := time.LoadLocation(tz); err == nil {
+			card.TimeZone = tz
+		}
+	}
+
+	if p := c.Get("PHOTO"); p != nil {
+		card.Photo = parsePhoto(p)
+	}
+	return card
+}
+
+// types returns the main TYPE of a property (the first that isn't pref,
+// internet or voice) and whether it is the preferred one
+func types(p *ical.Property) (kind string, preferred bool) {
+	for _, t := range strings.Split(strings.ToLower(p.Param("TYPE")), ",") {
+		switch t = strings.TrimSpace(t); t {
+		case "pref":
+			preferred = true
+		case "", "internet", "voice", "x400":
+		default:
+			if kind == "" {
+				kind = t
+			}
+		}
+	}
+	if p.Param("PREF") == "1" {
+		preferred = true
+	}
+	return kind, preferred
+}
+
+// parsePhoto reads an inline photo (3.0 ENCODING=b, 4.0 data: URI) or a
+// link to one
+func parsePhoto(p *ical.Property) Photo {
+	var encoding = strings.ToLower(p.Param("ENCODING"))
+	if encoding == "b" || encoding == "base64" {
+		var data, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(p.Value), ""))
+		if err != nil {
+			return Photo{}
+		}
+		return Photo{Data: data, MediaType: mediaType(p.Param("TYPE"))}
+	}
+
+	if rest, ok := strings.CutPrefix(p.Value, "data:"); ok {
+		var header, payload, found = strings.Cut(rest, ",")
+		var mime, isBase64 = strings.CutSuffix(header, ";base64")
+		if !found || !isBase64 {
+			return Photo{}
+		}
+		var data, err = base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return Photo{}
+		}
+		return Photo{Data: data, MediaType: mime}
+	}
+	if strings.HasPrefix(p.Value, "http://") || strings.HasPrefix(p.Value, "https://") {
+		return Photo{URL: p.Value}
+	}
+	return Photo{}
+}
+
+// mediaType turns a 3.0 photo TYPE (JPEG, PNG...) into a media type
+func mediaType(kind string) string {
+	kind = strings.ToLower(kind)
+	if kind == "" || strings.Contains(kind, "/") {
+		return kind
+	}
+	return "image/" + kind
+}
+
+// splitStructured splits a structured value (N: family;given;additional;
+// prefixes;suffixes) on the unescaped semicolons
+func splitStructured(value string) []string {
+	var parts []string
+	var start = 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ';':
+			parts = append(parts, ical.UnescapeText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, ical.UnescapeText(value[start:]))
+}
+
+// New creates a vCard 3.0, the version every server and address book
+// reads
+func New(uid string) *Card {
+	var c = &ical.Component{Name: "VCARD"}
+	c.Add("VERSION", "3.0", nil)
+	c.Add("PRODID", ProdID, nil)
+	c.Add("UID", uid, nil)
+	return &Card{Version: "3.0", UID: uid, component: c}
+}
+
+// Encode writes the card with the fields of c. Properties miau doesn't
+// edit are kept; those it edits are written in the card's version.
+func (c *Card) Encode() []byte {
+	var comp = c.component
+	if comp == nil {
+		comp = New(c.UID).component
+	}
+	var v4 = c.Version == "4.0"
+
+	comp.Set("UID", c.UID, nil)
+	comp.Set("FN", ical.EscapeText(c.Name), nil)
+	comp.Set("N", updateName(comp, c.FamilyName, c.GivenName), nil)
+
+	comp.Remove("EMAIL")
+	for _, e := range c.Emails {
+		comp.Add("EMAIL", ical.EscapeText(e.Address), typeParams(e.Type, e.Preferred, v4))
+	}
+	comp.Remove("TEL")
+	for _, p := range c.Phones {
+		var kind = p.Type
+		if kind == "mobile" {
+			kind = "cell"
+		}
+		comp.Add("TEL", ical.EscapeText(p.Number), typeParams(kind, p.Preferred, v4))
+	}
+
+	comp.Remove("TZ")
+	if c.TimeZone != "" {
+		if v4 {
+			comp.Add("TZ", c.TimeZone, nil)
+		} else {
+			comp.Add("TZ", c.TimeZone, map[string]string{"VALUE": "text"})
+		}
+	}
+
+	comp.Remove("PHOTO")
+	switch {
+	case len(c.Photo.Data) > 0 && v4:
+		comp.Add("PHOTO", "data:"+c.Photo.MediaType+";base64,"+base64.StdEncoding.EncodeToString(c.Photo.Data), nil)
+	case len(c.Photo.Data) > 0:
+		var params = map[string]string{"ENCODING": "b"}
+		if kind := strings.TrimPrefix(c.Photo.MediaType, "image/"); kind != "" {
+			params["TYPE"] = strings.ToUpper(kind)
+		}
+		comp.Add("PHOTO", base64.StdEncoding.EncodeToString(c.Photo.Data), params)
+	case c.Photo.URL != "" && v4:
+		comp.Add("PHOTO", c.Photo.URL, nil)
+	case c.Photo.URL != "":
+		comp.Add("PHOTO", c.Photo.URL, map[string]string{"VALUE": "uri"})
+	}
+
+	c.component = comp
+	return comp.Encode()
+}
+
+// updateName writes N with the family and given names, keeping the other
+// parts (additional names, prefixes, suffixes)
+func updateName(comp *ical.Component, family, given string) string {
+	var parts = []string{"", "", "", "", ""}
+	if p := comp.Get("N"); p != nil {
+		for i, part := range splitStructured(p.Value) {
+			if i < len(parts) {
+				parts[i] = part
+			}
+		}
+	}
+	parts[0], parts[1] = family, given
+	for i := range parts {
+		parts[i] = ical.EscapeText(parts[i])
+	}
+	return strings.Join(parts, ";")
+}
+
+// typeParams returns the TYPE of an email or phone; the preferred one is
+// marked with PREF=1 (4.0) or TYPE=pref (3.0, when there is no other type:
+// address books also take the first one as the preferred)
+func typeParams(kind string, preferred, v4 bool) map[string]string {
+	var params = map[string]string{}
+	switch {
+	case kind != "":
+		params["TYPE"] = kind
+	case preferred && !v4:
+		params["TYPE"] = "pref"
+	}
+	if preferred && v4 {
+		params["PREF"] = "1"
+	}
+	return params
+}
@@ -0,0 +1,154 @@
+package vcard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// appleCard is a vCard 3.0 as exported by macOS Contacts, with an inline
+// photo folded over several lines
+const appleCard = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"PRODID:-//Apple Inc.//macOS 14.0//EN\r\n" +
+	"N:Doe;Jane;Q.;Dr.;\r\n" +
+	"FN:Dr. Jane Doe\r\n" +
+	"ORG:Example\\, Inc.;\r\n" +
+	"EMAIL;type=INTERNET;type=WORK;type=pref:jane@example.com\r\n" +
+	"EMAIL;TYPE=INTERNET,HOME:jane.doe@example.org\r\n" +
+	"TEL;TYPE=CELL,VOICE,pref:+1 555 0100\r\n" +
+	"TEL;TYPE=WORK:+1 555 0199\r\n" +
+	"ADR;TYPE=HOME;TYPE=pref:;;1 Main St;Springfield;;;\r\n" +
+	"TZ;VALUE=text:America/New_York\r\n" +
+	"PHOTO;ENCODING=b;TYPE=JPEG:/9j/4AAQSk\r\n" +
+	" ZJRgABAQ==\r\n" +
+	"UID:0a1b2c3d-apple\r\n" +
+	"END:VCARD\r\n"
+
+// nextcloudCard is a vCard 4.0 with a data: URI photo
+const nextcloudCard = "BEGIN:VCARD\r\n" +
+	"VERSION:4.0\r\n" +
+	"UID:urn:uuid:4fbe8971-0bc3-424c-9c26-36c3e1eff6b1\r\n" +
+	"FN:Bob\r\n" +
+	"EMAIL;TYPE=work;PREF=1:bob@example.com\r\n" +
+	"TEL;VALUE=uri;TYPE=home:tel:+44-20-7946-0000\r\n" +
+	"TZ:-0500\r\n" +
+	"PHOTO:data:image/png;base64,iVBORw0KGgo=\r\n" +
+	"END:VCARD\r\n"
+
+// TestParse tests reading 3.0 and 4.0 cards from one .vcf
+func TestParse(t *testing.T) {
+	var cards, err = Parse([]byte(appleCard + nextcloudCard))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Expected 2 cards, got %d", len(cards))
+	}
+
+	var jane = cards[0]
+	if jane.Version != "3.0" || jane.UID != "0a1b2c3d-apple" || jane.Name != "Dr. Jane Doe" || jane.GivenName != "Jane" || jane.FamilyName != "Doe" {
+		t.Errorf("Unexpected card: %+v", jane)
+	}
+	if len(jane.Emails) != 2 || jane.Emails[1] != (Email{Address: "jane.doe@example.org", Type: "home"}) {
+		t.Errorf("Unexpected emails: %+v", jane.Emails)
+	}
+	if len(jane.Phones) != 2 || jane.Phones[0] != (Phone{Number: "+1 555 0100", Type: "mobile", Preferred: true}) || jane.Phones[1].Type != "work" {
+		t.Errorf("Unexpected phones: %+v", jane.Phones)
+	}
+	if jane.TimeZone != "America/New_York" {
+		t.Errorf("Expected the IANA time zone, got %q", jane.TimeZone)
+	}
+	if jane.Photo.MediaType != "image/jpeg" || !bytes.HasPrefix(jane.Photo.Data, []byte{0xff, 0xd8}) {
+		t.Errorf("Unexpected photo: %+v", jane.Photo)
+	}
+
+	var bob = cards[1]
+	if bob.Version != "4.0" || bob.UID != "urn:uuid:4fbe8971-0bc3-424c-9c26-36c3e1eff6b1" || bob.Name != "Bob" {
+		t.Errorf("Unexpected card: %+v", bob)
+	}
+	if len(bob.Emails) != 1 || bob.Emails[0] != (Email{Address: "bob@example.com", Type: "work", Preferred: true}) {
+		t.Errorf("Unexpected emails: %+v", bob.Emails)
+	}
+	if len(bob.Phones) != 1 || bob.Phones[0].Number != "+44-20-7946-0000" {
+		t.Errorf("Unexpected phones: %+v", bob.Phones)
+	}
+	if bob.TimeZone != "" {
+		t.Errorf("Expected a UTC offset to be ignored, got %q", bob.TimeZone)
+	}
+	if bob.Photo.MediaType != "image/png" || len(bob.Photo.Data) != 8 {
+		t.Errorf("Unexpected photo: %+v", bob.Photo)
+	}
+}
+
+// TestEncode tests writing a card back, keeping what miau doesn't edit
+func TestEncode(t *testing.T) {
+	var card, err = ParseOne([]byte(appleCard))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	card.GivenName = "Janet"
+	card.Name = "Janet Doe"
+	card.Emails = card.Emails[:1]
+	card.TimeZone = "Europe/Lisbon"
+
+	var data = string(card.Encode())
+	for _, want := range []string{
+		"FN:Janet Doe\r\n",
+		"N:Doe;Janet;Q.;Dr.;\r\n",
+		"ORG:Example\\, Inc.;\r\n",
+		"ADR;TYPE=HOME,pref:;;1 Main St;Springfield;;;\r\n",
+		"EMAIL;TYPE=work:jane@example.com\r\n",
+		"TEL;TYPE=cell:+1 555 0100\r\n",
+		"TZ;VALUE=text:Europe/Lisbon\r\n",
+		"PHOTO;ENCODING=b;TYPE=JPEG:/9j/4AAQSkZJRgABAQ==\r\n",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("Expected %q in:\n%s", want, data)
+		}
+	}
+	if strings.Contains(data, "jane.doe@example.org") {
+		t.Errorf("Expected the removed email to be gone:\n%s", data)
+	}
+
+	var again, err2 = ParseOne([]byte(data))
+	if err2 != nil || again.Name != "Janet Doe" || len(again.Emails) != 1 || again.TimeZone != "Europe/Lisbon" || !bytes.Equal(again.Photo.Data, card.Photo.Data) {
+		t.Errorf("Unexpected card after a round trip: %+v, %v", again, err2)
+	}
+}
+
+// TestNew tests a card created by miau
+func TestNew(t *testing.T) {
+	var card = New("new@miau")
+	card.Name = "Ana Silva"
+	card.GivenName, card.FamilyName = "Ana", "Silva"
+	card.Emails = []Email{{Address: "ana@example.com", Preferred: true}}
+	card.Phones = []Phone{{Number: "+55 11 5555-0000", Type: "mobile"}}
+
+	var data = string(card.Encode())
+	if !strings.HasPrefix(data, "BEGIN:VCARD\r\nVERSION:3.0\r\nPRODID:"+ProdID+"\r\nUID:new@miau\r\n") {
+		t.Errorf("Unexpected header:\n%s", data)
+	}
+	for _, want := range []string{"N:Silva;Ana;;;\r\n", "EMAIL;TYPE=pref:ana@example.com\r\n", "TEL;TYPE=cell:+55 11 5555-0000\r\n"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("Expected %q in:\n%s", want, data)
+		}
+	}
+
+	var v4 = &Card{Version: "4.0", UID: "v4@miau", Name: "Bob", Emails: []Email{{Address: "bob@example.com", Type: "work", Preferred: true}}}
+	if data := string(v4.Encode()); !strings.Contains(data, "EMAIL;PREF=1;TYPE=work:bob@example.com\r\n") {
+		t.Errorf("Unexpected 4.0 email:\n%s", data)
+	}
+}
+
+// TestParseErrors tests malformed files
+func TestParseErrors(t *testing.T) {
+	for _, data := range []string{"", "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", "BEGIN:VCARD\r\nFN:x\r\n"} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+	if _, err := ParseOne([]byte(appleCard + appleCard)); err == nil {
+		t.Errorf("Expected an error for two cards")
+	}
+}